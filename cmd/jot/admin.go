@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/veritome/jot/internal/idgen"
+	"github.com/veritome/jot/internal/remap"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleAdminCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot admin <remap-ids>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "remap-ids":
+		handleRemapIDs(args[1:])
+	default:
+		fmt.Printf("Unknown admin command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handleRemapIDs moves entries from legacy sequential IDs to those of
+// entry.id_format, or of --format
+func handleRemapIDs(args []string) {
+	fs := flag.NewFlagSet("admin remap-ids", flag.ExitOnError)
+	format := fs.String("format", "", "ID format to move to (default: entry.id_format)")
+	dryRun := fs.Bool("dry-run", false, "Report every change without making any")
+	yes := fs.Bool("yes", false, "Remap without asking for confirmation")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot admin remap-ids [--format spec] [--dry-run] [--yes]")
+		os.Exit(1)
+	}
+
+	if *format == "" {
+		*format = confirmConfig().String("entry.id_format")
+	}
+	gen, err := idgen.New(*format)
+	if err != nil {
+		fmt.Printf("Error: invalid ID format: %v\n", err)
+		os.Exit(1)
+	}
+
+	plan, err := remap.NewPlan(gen)
+	if err != nil {
+		fmt.Printf("Error planning remapping: %v\n", err)
+		if *format == "sequential" {
+			fmt.Println("Set entry.id_format, e.g. `jot config set entry.id_format date`, or pass --format")
+		}
+		os.Exit(1)
+	}
+
+	if plan.Resumed {
+		fmt.Printf("Resuming the remapping started %s; its IDs are kept\n", plan.StartedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if *dryRun || !plan.Resumed {
+		printRemapPlan(plan)
+	}
+	if *dryRun {
+		return
+	}
+	if len(plan.Order) == 0 {
+		return
+	}
+
+	if !plan.Resumed {
+		fmt.Println("Rollups listing remapped entries get a new body, keeping the old one as a revision,")
+		fmt.Println("and jot undo forgets the operations on remapped entries.")
+		if !confirm(*yes, fmt.Sprintf("Remap %d entry IDs?", len(plan.Order)), "remap entry IDs") {
+			return
+		}
+	}
+
+	interactive := ui.IsTerminal()
+	result, err := plan.Apply(func(done, total int) {
+		if interactive {
+			fmt.Printf("\rRenaming entries: %d/%d", done, total)
+		}
+	})
+	if interactive {
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("Error remapping entry IDs: %v\n", err)
+		fmt.Println("Progress has been saved; run `jot admin remap-ids` again to resume")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Remapped %d entries; rewrote the references of %d\n", result.Renamed, result.References)
+	if result.Forgotten > 0 {
+		fmt.Printf("Forgot %d operations jot undo could have reversed\n", result.Forgotten)
+	}
+}
+
+// printRemapPlan shows every ID the plan changes and keeps, and the
+// entries whose references it checks
+func printRemapPlan(plan *remap.Plan) {
+	if len(plan.Order) == 0 {
+		fmt.Println("No entries have legacy IDs to remap")
+	} else {
+		fmt.Printf("%d entries would be renamed:\n", len(plan.Order))
+		for _, id := range plan.Order {
+			fmt.Printf("  %s -> %s\n", id, plan.IDs[id])
+		}
+	}
+
+	if len(plan.References) > 0 {
+		fmt.Printf("%d entries would have their references checked and rewritten:\n", len(plan.References))
+		for _, id := range plan.References {
+			fmt.Printf("  %s\n", id)
+		}
+	}
+
+	if len(plan.Kept) > 0 {
+		ids := make([]string, 0, len(plan.Kept))
+		for id := range plan.Kept {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		fmt.Printf("%d legacy IDs would be kept:\n", len(ids))
+		for _, id := range ids {
+			fmt.Printf("  %s (%s)\n", id, plan.Kept[id])
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleArchiveCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot archive <year|thaw|list> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "year":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot archive year <YYYY>")
+			os.Exit(1)
+		}
+		handleArchiveYear(parseArchiveYear(args[1]))
+
+	case "thaw":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot archive thaw <YYYY>")
+			os.Exit(1)
+		}
+		year := parseArchiveYear(args[1])
+		n, err := entry.Thaw(year)
+		if err != nil {
+			fmt.Printf("Error thawing archive: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Thawed %s archived in %d\n", plural(n, "entry"), year)
+
+	case "list":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot archive list")
+			os.Exit(1)
+		}
+		archives, err := entry.Archives()
+		if err != nil {
+			fmt.Printf("Error listing archives: %v\n", err)
+			os.Exit(1)
+		}
+		if len(archives) == 0 {
+			fmt.Println("No archived years; archive one with `jot archive year <YYYY>`")
+			return
+		}
+		for _, a := range archives {
+			fmt.Printf("  %d  %-12s  %8s  archived %s\n", a.Year, plural(len(a.Entries), "entry"), formatBytes(a.Size), ui.FormatTime(a.Archived))
+		}
+
+	default:
+		fmt.Printf("Unknown archive command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// parseArchiveYear reads a year that can be archived: a past one
+func parseArchiveYear(s string) int {
+	year, err := strconv.Atoi(s)
+	if err != nil || year < 1 {
+		fmt.Printf("Invalid year '%s': expected YYYY\n", s)
+		os.Exit(1)
+	}
+	if year >= time.Now().Year() {
+		fmt.Printf("Only past years can be archived, not %d\n", year)
+		os.Exit(1)
+	}
+	return year
+}
+
+func handleArchiveYear(year int) {
+	var ids []string
+	kept := 0
+	for _, name := range journalCollection.List() {
+		j := journal.FromType(journalCollection.Journals[name])
+		entries, err := j.GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			if e.Created.Local().Year() != year {
+				continue
+			}
+			// Shared entries live in another folder, and verifying an
+			// append-only chain reads every entry
+			if j.IsShared() || j.AppendOnly {
+				kept++
+				continue
+			}
+			ids = append(ids, e.ID)
+		}
+	}
+
+	earlier, err := entry.Archives()
+	if err != nil {
+		fmt.Printf("Error listing archives: %v\n", err)
+		os.Exit(1)
+	}
+	if len(ids) == 0 {
+		for _, a := range earlier {
+			if a.Year == year {
+				fmt.Printf("%d is already archived, with %s\n", year, plural(len(a.Entries), "entry"))
+				return
+			}
+		}
+		fmt.Printf("No entries written in %d to archive\n", year)
+		return
+	}
+
+	a, err := entry.ArchiveYear(year, ids)
+	if err != nil {
+		fmt.Printf("Error archiving %d: %v\n", year, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Archived %s written in %d into %s\n", plural(len(a.Entries), "entry"), year, formatBytes(a.Size))
+	if kept > 0 {
+		fmt.Printf("%s in shared or append-only journals stay as they are\n", plural(kept, "entry"))
+	}
+	fmt.Println("They are left out of listings and searches until read again; run `jot gc` to free the space of their blobs")
+}
+
+// thawPeriod thaws the archived years between from and to, either of which
+// may be zero for no limit, so reading that period finds their entries
+func thawPeriod(from, to time.Time) {
+	archives, err := entry.Archives()
+	if err != nil {
+		fmt.Printf("Error listing archives: %v\n", err)
+		os.Exit(1)
+	}
+	thawed := false
+	for _, a := range archives {
+		if (!from.IsZero() && a.Year < from.Year()) || (!to.IsZero() && a.Year > to.Year()) {
+			continue
+		}
+		if _, err := entry.Thaw(a.Year); err != nil {
+			fmt.Printf("Error thawing archive: %v\n", err)
+			os.Exit(1)
+		}
+		thawed = true
+	}
+	if thawed {
+		loadCollection()
+	}
+}
+
+// thawEntries thaws the archived years holding any of the entries with the
+// given IDs, for commands that go through every entry of a journal
+func thawEntries(ids []string) {
+	archived, err := entry.ArchivedIDs()
+	if err != nil {
+		fmt.Printf("Error listing archives: %v\n", err)
+		os.Exit(1)
+	}
+	thawed := make(map[int]bool)
+	for _, id := range ids {
+		year, ok := archived[id]
+		if !ok || thawed[year] {
+			continue
+		}
+		if _, err := entry.Thaw(year); err != nil {
+			fmt.Printf("Error thawing archive: %v\n", err)
+			os.Exit(1)
+		}
+		thawed[year] = true
+	}
+	if len(thawed) > 0 {
+		loadCollection()
+	}
+}
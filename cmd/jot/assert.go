@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/backup"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/goal"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/internal/when"
+)
+
+// assertion is the outcome of one check of jot assert
+type assertion struct {
+	ok   bool
+	what string // What was found, such as "last entry in 'work' today"
+}
+
+func handleAssertCommand(journalName string, args []string) {
+	const usage = "Usage: jot assert [--journal name] [--wrote-today] [--wrote-within age] [--goal-met] [--snapshot-within age] [--quiet]"
+	fs := flag.NewFlagSet("assert", flag.ExitOnError)
+	name := fs.String("journal", journalName, "Only check this journal (default: every journal)")
+	wroteToday := fs.Bool("wrote-today", false, "An entry is dated today")
+	wroteWithin := fs.String("wrote-within", "", "The newest entry is at most this old: 36h, 3d, 2w or a date")
+	goalMet := fs.Bool("goal-met", false, "The writing goal of the current day or week is met")
+	snapshotWithin := fs.String("snapshot-within", "", "The newest snapshot is at most this old: 26h, 2d or a date")
+	quiet := fs.Bool("quiet", false, "Print nothing; only the exit status tells")
+	if rest := parseArgs(fs, args); len(rest) != 0 || (!*wroteToday && *wroteWithin == "" && !*goalMet && *snapshotWithin == "") {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(journalCollection.Journals))
+	if *name != "" {
+		if _, exists := journalCollection.Journals[*name]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", *name)
+			os.Exit(1)
+		}
+		names = append(names, *name)
+	} else {
+		for n := range journalCollection.Journals {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+	scope := "any journal"
+	if *name != "" {
+		scope = fmt.Sprintf("'%s'", *name)
+	}
+
+	now := time.Now()
+	var results []assertion
+	if *wroteToday || *wroteWithin != "" {
+		newest, today := newestEntry(names, now)
+		if *wroteToday {
+			if today > 0 {
+				results = append(results, assertion{true, fmt.Sprintf("%s dated today in %s", plural(today, "entry"), scope)})
+			} else {
+				results = append(results, assertion{false, fmt.Sprintf("no entry dated today in %s", scope)})
+			}
+		}
+		if *wroteWithin != "" {
+			since, err := within(*wroteWithin, now)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if newest.IsZero() {
+				results = append(results, assertion{false, fmt.Sprintf("no entries in %s", scope)})
+			} else {
+				results = append(results, assertion{!newest.Before(since), fmt.Sprintf("newest entry in %s dated %s", scope, ui.FormatTime(newest))})
+			}
+		}
+	}
+	if *goalMet {
+		results = append(results, goalAssertions(names, *name != "", now)...)
+	}
+	if *snapshotWithin != "" {
+		since, err := within(*snapshotWithin, now)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		snapshots, err := backup.List()
+		if err != nil {
+			fmt.Printf("Error listing snapshots: %v\n", err)
+			os.Exit(1)
+		}
+		if len(snapshots) == 0 {
+			results = append(results, assertion{false, "no snapshots taken"})
+		} else {
+			taken := snapshots[0].Taken
+			results = append(results, assertion{!taken.Before(since), fmt.Sprintf("newest snapshot taken %s", ui.FormatTime(taken))})
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		status := "ok  "
+		if !r.ok {
+			status = "FAIL"
+			failed++
+		}
+		if !*quiet {
+			fmt.Printf("%s %s\n", status, r.what)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// newestEntry returns the date of the newest entry of the named journals,
+// zero when they have none, and how many entries are dated today. Nothing
+// is decrypted.
+func newestEntry(names []string, now time.Time) (time.Time, int) {
+	var newest time.Time
+	today := 0
+	for _, name := range names {
+		entries, err := journal.FromType(journalCollection.Journals[name]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			if e.Created.After(newest) {
+				newest = e.Created
+			}
+			if sameDay(e.Created, now) {
+				today++
+			}
+		}
+	}
+	return newest, today
+}
+
+// sameDay reports whether a and b fall on the same local day
+func sameDay(a, b time.Time) bool {
+	return a.Local().Format("2006-01-02") == b.Local().Format("2006-01-02")
+}
+
+// goalAssertions checks the goal of each named journal, or of every
+// journal with a goal when only is false
+func goalAssertions(names []string, only bool, now time.Time) []assertion {
+	// Data written before the index existed, or synced in from another
+	// machine, is indexed on first use
+	if journalCollection.Index == nil {
+		if _, err := entry.RebuildIndex(); err != nil {
+			fmt.Printf("Error building index: %v\n", err)
+			os.Exit(1)
+		}
+		loadCollection()
+	}
+
+	var results []assertion
+	for _, name := range names {
+		if journalCollection.Journals[name].Goal == nil {
+			if only {
+				results = append(results, assertion{false, fmt.Sprintf("'%s' has no goal", name)})
+			}
+			continue
+		}
+		s, err := goal.Compute(journalCollection, name, now)
+		if err != nil {
+			fmt.Printf("Error computing goal: %v\n", err)
+			os.Exit(1)
+		}
+		current := "today"
+		if s.Goal.Period == goal.Weekly {
+			current = "this week"
+		}
+		results = append(results, assertion{s.Met(), fmt.Sprintf("goal of '%s': %d/%d %s", name, s.Done, s.Goal.Entries, current)})
+	}
+	if len(results) == 0 {
+		results = append(results, assertion{false, "no journal has a goal"})
+	}
+	return results
+}
+
+// within returns the earliest time an age allows: a duration such as 36h,
+// or an age or date when.Ago accepts, reaching back to the start of that day
+func within(age string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(age); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return when.Ago(age, now)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veritome/jot/internal/backup"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"golang.org/x/term"
+)
+
+func handleBackupCommand(args []string) {
+	const usage = "Usage: jot backup <now|create|run|schedule|list|extract> [args]"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "now", "create":
+		fs := flag.NewFlagSet("backup "+args[0], flag.ExitOnError)
+		stdout := fs.Bool("stdout", false, "Write the encrypted snapshot to standard output instead")
+		if rest := parseArgs(fs, args[1:]); len(rest) != 0 {
+			fmt.Printf("Usage: jot backup %s [--stdout]\n", args[0])
+			os.Exit(1)
+		}
+		if *stdout {
+			// Messages go to stderr so that they stay out of the pipe
+			if term.IsTerminal(int(os.Stdout.Fd())) {
+				fmt.Fprintln(os.Stderr, "Not writing a snapshot to the terminal; pipe it into another command or a file")
+				os.Exit(1)
+			}
+			if _, err := backup.Stream(os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error taking snapshot: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		snapshot, err := backup.Take(time.Now())
+		if err != nil {
+			fmt.Printf("Error taking snapshot: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %s (%s)\n", snapshot.Path, formatBytes(snapshot.Size))
+		pruned, err := backup.Prune(backupConfig().Int("backup.keep"))
+		printPruned(pruned)
+		if err != nil {
+			fmt.Printf("Error pruning snapshots: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "run":
+		// Run by cron or a timer; quiet unless something happened
+		if len(args) != 1 {
+			fmt.Println("Usage: jot backup run")
+			os.Exit(1)
+		}
+		snapshot, pruned, err := backup.RunDue(time.Now())
+		if snapshot != nil {
+			fmt.Printf("Saved %s (%s)\n", snapshot.Path, formatBytes(snapshot.Size))
+		}
+		printPruned(pruned)
+		if err != nil {
+			fmt.Printf("Error taking snapshot: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "schedule":
+		fs := flag.NewFlagSet("backup schedule", flag.ExitOnError)
+		keep := fs.Int("keep", -1, "Snapshots to keep (default: backup.keep)")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 || *keep < -1 {
+			fmt.Println("Usage: jot backup schedule <hourly|daily|weekly|off> [--keep N]")
+			os.Exit(1)
+		}
+		cfg := backupConfig()
+		if err := cfg.Set("backup.schedule", rest[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *keep >= 0 {
+			if err := cfg.Set("backup.keep", fmt.Sprint(*keep)); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := cfg.Save(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		warnEnvOverride(cfg, "backup.schedule")
+		if rest[0] == "off" {
+			fmt.Println("Scheduled snapshots are off")
+			return
+		}
+
+		dir, err := backup.Dir()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		kept := "all of them kept"
+		if n := cfg.Int("backup.keep"); n > 0 {
+			kept = fmt.Sprintf("the newest %d kept", n)
+		}
+		fmt.Printf("Taking a snapshot %s into %s, %s\n", rest[0], dir, kept)
+		binary, err := os.Executable()
+		if err != nil {
+			binary = "jot"
+		}
+		fmt.Println("jot daemon takes them while it runs. Otherwise, add this line to your crontab (crontab -e):")
+		fmt.Printf("  0 * * * * %q backup run\n", binary)
+
+	case "list":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot backup list")
+			os.Exit(1)
+		}
+		snapshots, err := backup.List()
+		if err != nil {
+			fmt.Printf("Error listing snapshots: %v\n", err)
+			os.Exit(1)
+		}
+		if len(snapshots) == 0 {
+			fmt.Println("No snapshots yet; take one with jot backup now")
+			return
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s  %8s  %s\n", s.Taken.Format("2006-01-02 15:04:05"), formatBytes(s.Size), s.Path)
+		}
+
+	case "extract":
+		fs := flag.NewFlagSet("backup extract", flag.ExitOnError)
+		output := fs.String("output", "", "New directory to unpack the snapshot into")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 || *output == "" {
+			fmt.Println("Usage: jot backup extract <snapshot|-> --output <dir>")
+			os.Exit(1)
+		}
+		keys, err := crypto.Keyring()
+		if err != nil {
+			fmt.Printf("Error loading keys: %v\n", err)
+			os.Exit(1)
+		}
+		defer crypto.ClearAll(keys)
+		n, err := backup.Extract(rest[0], *output, keys)
+		if err != nil {
+			fmt.Printf("Error extracting snapshot: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Extracted %s into %s\n", plural(n, "file"), *output)
+		fmt.Println("Snapshots hold no keys; to make it the data directory, run:")
+		fmt.Printf("  jot restore --from-keys <key backup> --from-data %s\n", filepath.Clean(*output))
+
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
+
+// backupConfig loads the config holding the backup.* settings, exiting when
+// it cannot be read
+func backupConfig() *config.Config {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// printPruned says how many old snapshots were deleted, if any
+func printPruned(pruned []backup.Snapshot) {
+	if len(pruned) > 0 {
+		fmt.Printf("Deleted %s older than the newest kept\n", plural(len(pruned), "snapshot"))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/veritome/jot/internal/bookmark"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// fetchTimeout bounds reading a bookmarked page, so a slow site only delays
+// saving the bare link
+const fetchTimeout = 10 * time.Second
+
+func handleBookmarkCommand(journalName string, args []string) {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("bookmark", flag.ExitOnError)
+	fs.Var(&entryTags, "tag", "Tag the bookmark (repeatable)")
+	fs.StringVar(&entryTitle, "title", entryTitle, "Title of the bookmark (default: the page's title)")
+	fs.BoolVar(&allowDuplicates, "allow-duplicates", allowDuplicates, "Save the link even when it is already bookmarked")
+	noFetch := fs.Bool("no-fetch", !cfg.Bool("bookmark.fetch"), "Save the link without reading the page")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot [-j <journal>] bookmark <url> [--tag tag] [--title title] [--no-fetch]")
+		os.Exit(1)
+	}
+	link, err := bookmark.NormalizeURL(rest[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if journalName == "" {
+		journalName = cfg.String("bookmark.journal")
+		if _, exists := journalCollection.Journals[journalName]; !exists {
+			j, err := journal.New(journalName)
+			if err != nil {
+				fmt.Printf("Error creating journal: %v\n", err)
+				os.Exit(1)
+			}
+			if err := journalCollection.AddJournal(j.AsType()); err != nil {
+				fmt.Printf("Error adding journal: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Created journal: %s\n", journalName)
+		}
+	}
+	if j, exists := journalCollection.Journals[journalName]; exists && !allowDuplicates {
+		saved, err := findBookmark(journal.FromType(j), link)
+		if err != nil {
+			fmt.Printf("Error checking for duplicates: %v\n", err)
+			os.Exit(1)
+		}
+		if saved != nil {
+			fmt.Printf("Skipped: %s was bookmarked in entry %s on %s; use --allow-duplicates to save it again\n",
+				link, saved.ID, ui.FormatTime(saved.Created))
+			return
+		}
+	}
+
+	b := &types.Bookmark{URL: link}
+	if !*noFetch {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		page, err := bookmark.Fetch(ctx, link)
+		cancel()
+		if err != nil {
+			fmt.Printf("Could not read the page, saving the link alone: %v\n", err)
+		} else {
+			fetched := time.Now()
+			b.Title, b.Description, b.Fetched = page.Title, page.Description, &fetched
+		}
+	}
+	if entryTitle == "" {
+		entryTitle = b.Title
+		if entryTitle == "" {
+			entryTitle = bookmark.FallbackTitle(link)
+		}
+	}
+
+	handleNewEntry(journalName, bookmark.Body(b), nil, b)
+}
+
+// findBookmark returns the entry of j bookmarking link, or nil when there
+// is none. Only bookmark entries are decrypted.
+func findBookmark(j *journal.Journal, link string) (*entry.Entry, error) {
+	entries, err := j.GetEntries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if len(e.Link) == 0 {
+			continue
+		}
+		b, err := e.GetBookmark()
+		if err != nil {
+			return nil, err
+		}
+		if b.URL == link {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/export"
+	"github.com/veritome/jot/internal/importer"
+	"golang.org/x/term"
+)
+
+// bundlePassphraseEnv holds the passphrase of encrypted bundles for scripts
+// and scheduled backups, which have no terminal to ask on
+const bundlePassphraseEnv = "JOT_BUNDLE_PASSPHRASE"
+
+// readPassphrase returns the passphrase of an encrypted bundle from
+// JOT_BUNDLE_PASSPHRASE, or asks for it, twice when confirm is set
+func readPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv(bundlePassphraseEnv); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for the passphrase on; set %s", bundlePassphraseEnv)
+	}
+
+	fmt.Print("Bundle passphrase: ")
+	p, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if len(p) == 0 {
+		return "", errors.New("the passphrase cannot be empty")
+	}
+	if confirm {
+		fmt.Print("Repeat the passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if string(again) != string(p) {
+			return "", errors.New("the passphrases do not match")
+		}
+	}
+	return string(p), nil
+}
+
+// handleImportBundle restores the entries of an encrypted bundle written by
+// jot export --format encrypted-bundle, optionally only those of some
+// journals or dates. Entries already in jot are skipped like any import.
+func handleImportBundle(args []string) {
+	fs := flag.NewFlagSet("import encrypted-bundle", flag.ExitOnError)
+	var only stringList
+	fs.Var(&only, "journal", "Only restore the entries of this journal (repeatable)")
+	fromFlag := fs.String("from", "", "Only entries written on or after this date (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Only entries written on or before this date (YYYY-MM-DD)")
+	dryRun := fs.Bool("dry-run", false, "Show what would be restored without saving anything")
+	allow := fs.Bool("allow-duplicates", allowDuplicates, "Restore entries even when an entry with the same text and date exists")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot import encrypted-bundle <file> [--journal name] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--dry-run] [--allow-duplicates]")
+		os.Exit(1)
+	}
+	from, to := parseSearchDate(*fromFlag), parseSearchDate(*toFlag)
+	if !to.IsZero() {
+		// Entries written during the last day count
+		to = to.AddDate(0, 0, 1)
+	}
+
+	f, err := os.Open(rest[0])
+	if err != nil {
+		fmt.Printf("Error opening bundle: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	passphrase, err := readPassphrase(false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	b, err := export.ReadBundle(f, passphrase)
+	if err != nil {
+		fmt.Printf("Error reading bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range only {
+		wanted[name] = true
+	}
+	inBundle := make(map[string]bool)
+	var notes []importer.Note
+	for _, e := range b.Entries {
+		inBundle[e.Journal] = true
+		if len(wanted) > 0 && !wanted[e.Journal] {
+			continue
+		}
+		if (!from.IsZero() && e.Created.Before(from)) || (!to.IsZero() && !e.Created.Before(to)) {
+			continue
+		}
+		notes = append(notes, importer.Note{
+			Source:    fmt.Sprintf("entry %s of '%s'", e.ID, e.Journal),
+			Journal:   e.Journal,
+			Created:   e.Created,
+			Title:     e.Title,
+			Tags:      e.Tags,
+			Body:      e.Body,
+			Sensitive: e.Sensitive,
+			State:     e.State,
+			Checkin:   e.Checkin,
+			Bookmark:  e.Bookmark,
+			Fields:    e.Fields,
+			Rollup:    e.Rollup,
+		})
+	}
+	for _, name := range only {
+		if !inBundle[name] {
+			fmt.Printf("Warning: the bundle has no entries of journal '%s'\n", name)
+		}
+	}
+	sort.SliceStable(notes, func(a, b int) bool { return notes[a].Created.Before(notes[b].Created) })
+
+	fmt.Printf("Bundle written %s holds %s\n", b.Created.Local().Format(time.RFC1123), plural(len(b.Entries), "entry"))
+	importNotes(notes, "", *dryRun, *allow)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/checkin"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/ui"
+	"golang.org/x/term"
+)
+
+func handleCheckinCommand(journalName string, args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "templates":
+			handleCheckinTemplates(args[1:])
+			return
+		case "stats":
+			handleCheckinStats(args[1:])
+			return
+		}
+	}
+	if len(args) > 1 {
+		fmt.Println("Usage: jot [-j <journal>] checkin [template]")
+		os.Exit(1)
+	}
+
+	name := checkin.DefaultTemplate
+	if len(args) == 1 {
+		name = args[0]
+	}
+	t, err := checkin.Load(name)
+	if err != nil {
+		fmt.Printf("Error loading check-in template: %v\n", err)
+		os.Exit(1)
+	}
+
+	c := &types.Checkin{Template: t.Name}
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	reader := bufio.NewReader(os.Stdin)
+	if interactive {
+		fmt.Println("Leave an answer empty to skip the question")
+	}
+	ended := false
+	for _, q := range t.Questions {
+		for !ended {
+			fmt.Printf("%s (%s): ", q.Text, checkin.Hint(q.Kind))
+			line, err := reader.ReadString('\n')
+			if err == io.EOF && line == "" {
+				fmt.Println()
+				// Ctrl+D cancels; piped answers may simply stop early
+				if interactive {
+					fmt.Println("Check-in cancelled")
+					os.Exit(1)
+				}
+				ended = true
+				break
+			}
+			if err != nil && err != io.EOF {
+				fmt.Printf("Error reading answer: %v\n", err)
+				os.Exit(1)
+			}
+			if !interactive {
+				// Piped answers are echoed so the transcript reads well
+				fmt.Println(strings.TrimSpace(line))
+			}
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			value, err := checkin.ParseAnswer(q.Kind, line)
+			if err != nil {
+				if !interactive {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("  %v\n", err)
+				continue
+			}
+			c.Answers = append(c.Answers, types.Answer{Question: q.Text, Kind: q.Kind, Value: value})
+			break
+		}
+	}
+	if len(c.Answers) == 0 {
+		fmt.Println("Nothing answered, check-in discarded")
+		return
+	}
+
+	handleNewEntry(journalName, checkin.Body(c), c, nil)
+}
+
+func handleCheckinTemplates(args []string) {
+	if len(args) != 0 {
+		fmt.Println("Usage: jot checkin templates")
+		os.Exit(1)
+	}
+	templates, err := checkin.List()
+	if err != nil {
+		fmt.Printf("Error loading check-in templates: %v\n", err)
+		os.Exit(1)
+	}
+	for i, t := range templates {
+		if i > 0 {
+			fmt.Println()
+		}
+		builtin := ""
+		if t.Builtin {
+			builtin = " (built in)"
+		}
+		fmt.Printf("%s%s\n", t.Name, builtin)
+		for _, q := range t.Questions {
+			fmt.Printf("  %s [%s]\n", q.Text, checkin.Hint(q.Kind))
+		}
+	}
+	if dir, err := checkin.Dir(); err == nil {
+		fmt.Printf("\nAdd templates as <name>.txt in %s\n", dir)
+	}
+}
+
+func handleCheckinStats(args []string) {
+	fs := flag.NewFlagSet("checkin stats", flag.ExitOnError)
+	journalFlag := fs.String("journal", "", "Only chart check-ins in this journal")
+	days := fs.Int("days", 30, "Number of days to chart")
+	rest := parseArgs(fs, args)
+	if len(rest) > 1 || *days < 1 {
+		fmt.Println("Usage: jot checkin stats [template] [--journal name] [--days N]")
+		os.Exit(1)
+	}
+	name := checkin.DefaultTemplate
+	if len(rest) == 1 {
+		name = rest[0]
+	}
+	if ui.Discreet() {
+		fmt.Println("Check-in answers are hidden in discreet mode")
+		return
+	}
+
+	var names []string
+	if *journalFlag != "" {
+		if _, exists := journalCollection.Journals[*journalFlag]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", *journalFlag)
+			os.Exit(1)
+		}
+		names = []string{*journalFlag}
+	} else {
+		for n := range journalCollection.Journals {
+			names = append(names, n)
+		}
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -*days)
+	var records []checkin.Record
+	for _, n := range names {
+		entries, err := journal.FromType(journalCollection.Journals[n]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			// Only check-ins in range are decrypted
+			if len(e.Answers) == 0 || e.Created.Before(since) {
+				continue
+			}
+			c, err := e.GetCheckin()
+			if err != nil {
+				fmt.Printf("Error reading check-in: %v\n", err)
+				os.Exit(1)
+			}
+			records = append(records, checkin.Record{Created: e.Created, Checkin: c})
+		}
+	}
+	sort.SliceStable(records, func(a, b int) bool { return records[a].Created.Before(records[b].Created) })
+
+	series := checkin.Stats(records, name, now, *days)
+	if len(series) == 0 {
+		fmt.Printf("No '%s' check-ins in the last %d days; answer one with `jot checkin %s`\n", name, *days, name)
+		return
+	}
+
+	width := 0
+	for _, s := range series {
+		if n := len([]rune(s.Question)); n > width {
+			width = n
+		}
+	}
+	first := now.AddDate(0, 0, -(*days - 1)).Format("Jan 2")
+	last := now.Format("Jan 2")
+	fmt.Printf("'%s' check-ins, last %d days\n", name, *days)
+	axis := first + strings.Repeat(" ", maxInt(*days-len(first)-len(last), 1)) + last
+	fmt.Printf("  %-*s  %s\n", width, "", axis)
+	for _, s := range series {
+		var summary string
+		switch s.Kind {
+		case checkin.Number:
+			summary = fmt.Sprintf("avg %s, min %s, max %s", formatNumber(s.Mean), formatNumber(s.Min), formatNumber(s.Max))
+		case checkin.YesNo:
+			summary = fmt.Sprintf("yes on %d of %s", s.Yes, plural(s.Answered, "day"))
+		default:
+			fmt.Printf("  %-*s  answered on %s\n", width, s.Question, plural(s.Answered, "day"))
+			continue
+		}
+		min, max := s.Min, s.Max
+		if s.Kind == checkin.YesNo {
+			min, max = 0, 1
+		}
+		fmt.Printf("  %-*s  %s  %s\n", width, s.Question, ui.Sparkline(s.Values, min, max), summary)
+	}
+}
+
+// formatNumber renders a number with at most one decimal
+func formatNumber(v float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", v), ".0")
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
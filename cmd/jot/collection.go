@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/trash"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Orders of jot collection --sort
+const (
+	sortByName    = "name"
+	sortByEntries = "entries"
+	sortByRecent  = "recent"
+)
+
+// collectionRow is one journal of jot collection
+type collectionRow struct {
+	name    string
+	entries int
+	created time.Time
+	last    string // Local date of the newest entry, YYYY-MM-DD; empty without entries
+	status  []string
+}
+
+// handleCollectionCommand lists every journal with its entry count,
+// creation and last entry dates and status, followed by those in the trash
+func handleCollectionCommand(args []string) {
+	fs := flag.NewFlagSet("collection", flag.ExitOnError)
+	order := fs.String("sort", sortByName, "Order of the journals: name, entries (most first) or recent (latest entry first)")
+	if rest := parseArgs(fs, args); len(rest) != 0 || (*order != sortByName && *order != sortByEntries && *order != sortByRecent) {
+		fmt.Println("Usage: jot collection [--sort name|entries|recent]")
+		os.Exit(1)
+	}
+
+	trashed, err := trash.List()
+	if err != nil {
+		fmt.Printf("Error reading trash: %v\n", err)
+		os.Exit(1)
+	}
+	if len(journalCollection.Journals) == 0 && len(trashed) == 0 {
+		fmt.Println("No journals found")
+		return
+	}
+
+	ensureIndex()
+	last := journalCollection.LastIndexedDates()
+	defaultName := defaultJournal()
+	var rows []collectionRow
+	for _, name := range journalCollection.List() {
+		j := journalCollection.Journals[name]
+		row := journalRow(j)
+		row.last = last[name]
+		if name == defaultName {
+			row.status = append([]string{"default"}, row.status...)
+		}
+		rows = append(rows, row)
+	}
+	sortCollectionRows(rows, *order)
+
+	// Trashed journals come last, most recently deleted first
+	sort.Slice(trashed, func(a, b int) bool { return trashed[a].DeletedAt.After(trashed[b].DeletedAt) })
+	for _, item := range trashed {
+		row := journalRow(item.Journal)
+		row.status = append(row.status, "trashed "+item.DeletedAt.Local().Format("2006-01-02"))
+		rows = append(rows, row)
+	}
+
+	width := len("Journal")
+	for _, r := range rows {
+		if n := len([]rune(r.name)); n > width {
+			width = n
+		}
+	}
+	fmt.Printf("%-*s  %7s  %-10s  %-10s  %s\n", width, "Journal", "Entries", "Created", "Last entry", "Status")
+	for _, r := range rows {
+		created := "-"
+		if !r.created.IsZero() {
+			created = r.created.Local().Format("2006-01-02")
+		}
+		name := r.name + strings.Repeat(" ", width-len([]rune(r.name)))
+		line := fmt.Sprintf("%s  %7d  %-10s  %-10s  %s", name, r.entries, created, orDash(r.last), strings.Join(r.status, ", "))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+// journalRow fills in what a journal records about itself
+func journalRow(j *types.Journal) collectionRow {
+	row := collectionRow{name: j.Name, entries: len(j.EntryIDs), created: j.Created}
+	if j.AppendOnly {
+		row.status = append(row.status, "append-only")
+	}
+	if j.SharedDir != "" {
+		row.status = append(row.status, "shared")
+	}
+	return row
+}
+
+// sortCollectionRows orders rows already sorted by name by entry count or
+// latest entry, most first, keeping ties in name order
+func sortCollectionRows(rows []collectionRow, order string) {
+	switch order {
+	case sortByEntries:
+		sort.SliceStable(rows, func(a, b int) bool { return rows[a].entries > rows[b].entries })
+	case sortByRecent:
+		sort.SliceStable(rows, func(a, b int) bool { return rows[a].last > rows[b].last })
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/veritome/jot/internal/checkin"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/fsutil"
+	"github.com/veritome/jot/internal/idgen"
+	"github.com/veritome/jot/internal/prompt"
+	"github.com/veritome/jot/internal/route"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/template"
+)
+
+// configBundle is the file written by `jot config export`. It holds the
+// user's setup but never keys, entries or the settings in exportExcluded.
+type configBundle struct {
+	Version   int               `json:"version"`
+	Settings  map[string]string `json:"settings"`            // Explicitly set config values
+	Prompts   *string           `json:"prompts,omitempty"`   // Contents of prompts.txt, if customised
+	Templates map[string]string `json:"templates,omitempty"` // File name -> contents, from templates/
+	Checkins  map[string]string `json:"checkins,omitempty"`  // File name -> contents, from checkins/
+}
+
+// exportExcluded are the settings jot config export leaves out, as
+// patterns: secrets such as webhook URLs, and paths that only make sense on
+// this machine
+var exportExcluded = []string{
+	"digest.*_url",
+	"data_dir",
+	"crypto.key_dir",
+	"crypto.fido2_device",
+	"server.token_file",
+	"watch.dir",
+	"backup.dir",
+	"timestamp.ca_file",
+}
+
+// excludedFromExport reports whether the setting name matches exportExcluded
+func excludedFromExport(name string) bool {
+	for _, pattern := range exportExcluded {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConfig loads the config file and applies process-wide settings
+func applyConfig() {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch cfg.String("color") {
+	case "never":
+		lipgloss.SetColorProfile(termenv.Ascii)
+	case "always":
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	}
+}
+
+// defaultCommand returns the arguments jot runs with when given none, as
+// chosen by default_command; none shows the usage
+func defaultCommand() []string {
+	cfg, err := config.Current()
+	if err != nil {
+		return nil
+	}
+	switch cfg.String("default_command") {
+	case "find":
+		return []string{"find"}
+	case "new":
+		return []string{"new"}
+	case "today":
+		today := time.Now().Format("2006-01-02")
+		return []string{"search", "--from", today, "--to", today}
+	}
+	return nil
+}
+
+func handleConfigCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot config <list|get|set|unset|path|export|import> [args]")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		for _, k := range config.Keys() {
+			value, _ := cfg.Get(k.Name)
+			source := "default"
+			if cfg.FromEnv(k.Name) {
+				source = config.EnvName(k.Name)
+			} else if cfg.IsSet(k.Name) {
+				source = "set"
+			}
+			fmt.Printf("%-22s = %-28q (%s) %s\n", k.Name, value, source, k.Description)
+		}
+
+	case "get":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot config get <key>")
+			os.Exit(1)
+		}
+		value, err := cfg.Get(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(value)
+
+	case "set":
+		if len(args) != 3 {
+			fmt.Println("Usage: jot config set <key> <value>")
+			os.Exit(1)
+		}
+		if err := cfg.Set(args[1], args[2]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if args[1] == "entry.id_format" {
+			// Render a sample so bad layouts are caught now rather than on the next entry
+			gen, err := idgen.New(args[2])
+			if err == nil {
+				_, err = gen.Next(nil, time.Now())
+			}
+			if err != nil {
+				fmt.Printf("Error: invalid entry.id_format: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if args[1] == "capture.routes" {
+			if _, err := route.Parse(args[2]); err != nil {
+				fmt.Printf("Error: invalid capture.routes: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := cfg.Save(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Set %s = %s\n", args[1], args[2])
+		warnEnvOverride(cfg, args[1])
+
+	case "unset":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot config unset <key>")
+			os.Exit(1)
+		}
+		if err := cfg.Unset(args[1]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := cfg.Save(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Unset %s\n", args[1])
+		warnEnvOverride(cfg, args[1])
+
+	case "path":
+		path, err := config.Path()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+
+	case "export":
+		handleConfigExport(cfg, args[1:])
+
+	case "import":
+		handleConfigImport(cfg, args[1:])
+
+	default:
+		fmt.Printf("Unknown config command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// warnEnvOverride points out that a change to the config file has no
+// effect while an environment variable gives the setting
+func warnEnvOverride(cfg *config.Config, name string) {
+	if cfg.FromEnv(name) {
+		fmt.Printf("Note: %s is set and takes precedence over the config file\n", config.EnvName(name))
+	}
+}
+
+func handleConfigExport(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("config export", flag.ExitOnError)
+	output := fs.String("output", "", "Write to this file instead of stdout")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot config export [--output file]")
+		os.Exit(1)
+	}
+
+	bundle := configBundle{Version: 1, Settings: make(map[string]string)}
+	for _, k := range config.Keys() {
+		if excludedFromExport(k.Name) {
+			continue
+		}
+		if value, ok := cfg.Stored(k.Name); ok {
+			bundle.Settings[k.Name] = value
+		}
+	}
+
+	promptsPath, err := prompt.Path()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if data, err := os.ReadFile(promptsPath); err == nil {
+		prompts := string(data)
+		bundle.Prompts = &prompts
+	} else if !os.IsNotExist(err) {
+		fmt.Printf("Error reading prompts: %v\n", err)
+		os.Exit(1)
+	}
+	if bundle.Templates, err = readBundleDir(template.Dir); err != nil {
+		fmt.Printf("Error reading templates: %v\n", err)
+		os.Exit(1)
+	}
+	if bundle.Checkins, err = readBundleDir(checkin.Dir); err != nil {
+		fmt.Printf("Error reading check-in templates: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding settings: %v\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := fsutil.WriteFileAtomic(*output, data, 0600); err != nil {
+		fmt.Printf("Error writing %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported %d settings and %d templates to %s\n",
+		len(bundle.Settings), len(bundle.Templates)+len(bundle.Checkins), *output)
+}
+
+// readBundleDir returns the files of the directory dir returns, nil when
+// it does not exist
+func readBundleDir(dir func() (string, error)) (map[string]string, error) {
+	dirPath, err := dir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := storage.ReadDir(dirPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	contents := make(map[string]string, len(files))
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		data, err := storage.ReadFile(filepath.Join(dirPath, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		contents[file.Name()] = string(data)
+	}
+	return contents, nil
+}
+
+// writeBundleDir writes the files of a bundle into the directory dir
+// returns, replacing those of the same name
+func writeBundleDir(dir func() (string, error), contents map[string]string) error {
+	dirPath, err := dir()
+	if err != nil {
+		return err
+	}
+	for name := range contents {
+		if filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("invalid file name %q", name)
+		}
+	}
+	if err := storage.MkdirAll(dirPath, 0700); err != nil {
+		return err
+	}
+	for name, text := range contents {
+		if err := storage.WriteFile(filepath.Join(dirPath, name), []byte(text), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handleConfigImport(cfg *config.Config, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: jot config import <file>")
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	var bundle configBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		fmt.Printf("Error parsing %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	if bundle.Version != 1 {
+		fmt.Printf("Unsupported settings file version %d\n", bundle.Version)
+		os.Exit(1)
+	}
+
+	// Validate everything before changing anything
+	names := make([]string, 0, len(bundle.Settings))
+	for name := range bundle.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	imported := 0
+	for _, name := range names {
+		if _, ok := config.Lookup(name); !ok {
+			fmt.Printf("Skipping unknown setting '%s'\n", name)
+			continue
+		}
+		if excludedFromExport(name) {
+			fmt.Printf("Skipping machine-specific or secret setting '%s'\n", name)
+			continue
+		}
+		if err := cfg.Set(name, bundle.Settings[name]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		imported++
+	}
+
+	if err := cfg.Save(); err != nil {
+		fmt.Printf("Error saving config: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d settings\n", imported)
+
+	// The prompt list lives in the data directory, which the import may
+	// have just changed
+	if bundle.Prompts != nil {
+		path, err := prompt.Path()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			fmt.Printf("Error creating data directory: %v\n", err)
+			os.Exit(1)
+		}
+		if err := fsutil.WriteFileAtomic(path, []byte(*bundle.Prompts), 0600); err != nil {
+			fmt.Printf("Error writing prompts: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported writing prompts to %s\n", path)
+	}
+	if err := writeBundleDir(template.Dir, bundle.Templates); err != nil {
+		fmt.Printf("Error writing templates: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeBundleDir(checkin.Dir, bundle.Checkins); err != nil {
+		fmt.Printf("Error writing check-in templates: %v\n", err)
+		os.Exit(1)
+	}
+	if n := len(bundle.Templates) + len(bundle.Checkins); n > 0 {
+		fmt.Printf("Imported %d templates\n", n)
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleCountersignCommand(journalName string, args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot countersign <id> --key <file.pub> [--signature file] | sign <request> --key <file> | keygen <file> | verify <id> | verify-log")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "keygen":
+		handleWitnessKeygen(args[1:])
+	case "sign":
+		handleWitnessSign(args[1:])
+	case "verify":
+		handleVerifyCountersigns(journalName, args[1:])
+	case "verify-log":
+		handleVerifyCountersignLog(args[1:])
+	default:
+		handleCountersign(journalName, args)
+	}
+}
+
+// witnessCommand reports whether args are a countersign command run by the
+// witness, which needs no journal of its own
+func witnessCommand(args []string) bool {
+	return len(args) > 1 && args[0] == "countersign" && (args[1] == "keygen" || args[1] == "sign")
+}
+
+func handleWitnessKeygen(args []string) {
+	fs := flag.NewFlagSet("countersign keygen", flag.ExitOnError)
+	name := fs.String("name", "", "Witness name recorded with every countersignature (default: the file name)")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot countersign keygen <file> [--name witness]")
+		os.Exit(1)
+	}
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(rest[0]), filepath.Ext(rest[0]))
+	}
+
+	key, err := crypto.GenerateWitnessKey(*name, rest[0])
+	if err != nil {
+		fmt.Printf("Error creating witness key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Created witness key for '%s'\n", key.Name)
+	fmt.Printf("  Private key: %s (keep it with the witness)\n", rest[0])
+	fmt.Printf("  Public key:  %s.pub %s\n", rest[0], key.PublicKeyString())
+}
+
+// findEntry loads an entry by ID from the named journal or, when none is
+// given, from whichever journal holds it
+func findEntry(journalName, id string) (string, *entry.Entry) {
+	if journalName != "" {
+		return journalName, loadJournalEntry(journalName, id)
+	}
+
+	names := make([]string, 0, len(journalCollection.Journals))
+	for name := range journalCollection.Journals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wrappedJ := journal.FromType(journalCollection.Journals[name])
+		holds := wrappedJ.IsShared()
+		for _, entryID := range wrappedJ.EntryIDs {
+			holds = holds || entryID == id
+		}
+		if !holds {
+			continue
+		}
+		e, err := wrappedJ.LoadEntry(id)
+		if err != nil {
+			if wrappedJ.IsShared() {
+				continue
+			}
+			fmt.Printf("Error loading entry: %v\n", err)
+			os.Exit(1)
+		}
+		return name, e
+	}
+	fmt.Printf("Entry %s not found in any journal\n", id)
+	os.Exit(1)
+	return "", nil
+}
+
+// handleCountersign exports a request for the witness to countersign an
+// entry or, given the signature they made of it, checks it against their
+// public key and stores it. The witness's private key never comes near the
+// journal.
+func handleCountersign(journalName string, args []string) {
+	fs := flag.NewFlagSet("countersign", flag.ExitOnError)
+	keyFile := fs.String("key", "", "Public key of the witness, made with jot countersign keygen")
+	signature := fs.String("signature", "", "Countersignature made by the witness with jot countersign sign")
+	output := fs.String("output", "", "Where to write the request (default: <id>.countersign.json)")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 || *keyFile == "" {
+		fmt.Println("Usage: jot countersign <id> --key <file.pub> [--signature file] [--output file] [--journal name]")
+		os.Exit(1)
+	}
+
+	witness, err := crypto.ReadWitnessKey(*keyFile)
+	if err != nil {
+		fmt.Printf("Error reading witness key: %v\n", err)
+		os.Exit(1)
+	}
+	if witness.Private != nil {
+		fmt.Printf("Error: %s is a private key, which stays with the witness; pass their public key (%s.pub)\n", *keyFile, *keyFile)
+		os.Exit(1)
+	}
+	_, e := findEntry(journalName, rest[0])
+
+	if *signature == "" {
+		exportCountersignRequest(e, *keyFile, *output)
+		return
+	}
+
+	var w entry.Witnessed
+	if err := readJSONFile(*signature, &w); err != nil {
+		fmt.Printf("Error reading countersignature: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.AddCountersignature(&w, witness); err != nil {
+		fmt.Printf("Error countersigning entry: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.Save(); err != nil {
+		fmt.Printf("Error saving entry: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Entry %s countersigned by '%s' at %s\n", e.ID, w.Witness, ui.FormatTime(w.Signed))
+}
+
+// exportCountersignRequest writes the request for the witness with the
+// public key in keyFile to countersign e
+func exportCountersignRequest(e *entry.Entry, keyFile, output string) {
+	r, err := e.CountersignRequest()
+	if err != nil {
+		fmt.Printf("Error preparing countersignature request: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "" {
+		output = e.ID + ".countersign.json"
+	}
+	if err := writeJSONFile(output, r); err != nil {
+		fmt.Printf("Error writing countersignature request: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote the request to countersign entry %s to %s\n", e.ID, output)
+	fmt.Println("It holds the entry's text; give it to the witness privately and delete it once signed.")
+	fmt.Printf("The witness runs:  jot countersign sign %s --key <their key>\n", output)
+	fmt.Printf("Then import it:    jot countersign %s --key %s --signature <their signature>\n", e.ID, keyFile)
+}
+
+// handleWitnessSign countersigns a request on the witness's machine, after
+// showing them the entry
+func handleWitnessSign(args []string) {
+	fs := flag.NewFlagSet("countersign sign", flag.ExitOnError)
+	keyFile := fs.String("key", "", "Witness key made with jot countersign keygen")
+	output := fs.String("output", "", "Where to write the countersignature (default: <id>.countersignature.json)")
+	yes := fs.Bool("yes", false, "Sign without showing the entry and asking for confirmation")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 || *keyFile == "" {
+		fmt.Println("Usage: jot countersign sign <request> --key <file> [--output file] [--yes]")
+		os.Exit(1)
+	}
+
+	key, err := crypto.ReadWitnessSigningKey(*keyFile)
+	if err != nil {
+		fmt.Printf("Error reading witness key: %v\n", err)
+		os.Exit(1)
+	}
+	var r entry.CountersignRequest
+	if err := readJSONFile(rest[0], &r); err != nil {
+		fmt.Printf("Error reading countersignature request: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The witness signs what they were shown, so they see it first
+	if !*yes {
+		fmt.Printf("%s %s\n%s\n\n", r.Entry, ui.FormatTime(r.Created), r.Body)
+	}
+	if !confirm(*yes, fmt.Sprintf("Countersign this entry as '%s'?", key.Name), "countersign") {
+		return
+	}
+
+	w, err := entry.SignCountersignRequest(&r, key)
+	if err != nil {
+		fmt.Printf("Error countersigning entry: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = r.Entry + ".countersignature.json"
+	}
+	if err := writeJSONFile(*output, w); err != nil {
+		fmt.Printf("Error writing countersignature: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Countersigned entry %s as '%s'; wrote the signature to %s\n", r.Entry, w.Witness, *output)
+	fmt.Println("Give it back to the journal's owner, and keep a copy: `jot countersign verify-log --signature` checks it is still there.")
+}
+
+// readJSONFile decodes the JSON file at path into v
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile writes v as JSON to a new file at path, readable only by
+// the user
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// handleVerifyCountersignLog checks the countersignature log, and that a
+// witness's countersignature is in it
+func handleVerifyCountersignLog(args []string) {
+	fs := flag.NewFlagSet("countersign verify-log", flag.ExitOnError)
+	signature := fs.String("signature", "", "Also check that this countersignature is logged")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot countersign verify-log [--signature file]")
+		os.Exit(1)
+	}
+
+	problems, err := entry.VerifyCountersignLog(entryCountersignatures)
+	if err != nil {
+		fmt.Printf("Error verifying countersignature log: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range problems {
+		fmt.Printf("  %s\n", p)
+	}
+	intact := len(problems) == 0
+
+	if *signature != "" {
+		var w entry.Witnessed
+		if err := readJSONFile(*signature, &w); err != nil {
+			fmt.Printf("Error reading countersignature: %v\n", err)
+			os.Exit(1)
+		}
+		logged, err := entry.CountersignLogged(&w)
+		if err != nil {
+			fmt.Printf("Error verifying countersignature log: %v\n", err)
+			os.Exit(1)
+		}
+		if !logged {
+			intact = false
+			fmt.Printf("  The countersignature by '%s' of entry %s is not in the log\n", w.Witness, w.Entry)
+		}
+	}
+
+	if !intact {
+		os.Exit(1)
+	}
+	fmt.Println("Countersignature log intact")
+}
+
+// entryCountersignatures returns the countersignatures of the entry id, and
+// false when the entry does not exist
+func entryCountersignatures(id string) ([]types.Countersignature, bool, error) {
+	e, err := entry.Load(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	cs, err := e.Countersignatures()
+	return cs, true, err
+}
+
+func handleVerifyCountersigns(journalName string, args []string) {
+	fs := flag.NewFlagSet("countersign verify", flag.ExitOnError)
+	keyFile := fs.String("key", "", "Only accept countersignatures by this witness key")
+	statement := fs.Bool("statement", false, "Print the signed statements, to check them with other ed25519 tools")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot countersign verify <id> [--journal name] [--key file.pub] [--statement]")
+		os.Exit(1)
+	}
+
+	var trusted *crypto.WitnessKey
+	if *keyFile != "" {
+		var err error
+		if trusted, err = crypto.ReadWitnessKey(*keyFile); err != nil {
+			fmt.Printf("Error reading witness key: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	name, e := findEntry(journalName, rest[0])
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		fmt.Printf("Error decrypting entry: %v\n", err)
+		os.Exit(1)
+	}
+	cs, err := e.Countersignatures()
+	if err != nil {
+		fmt.Printf("Error reading countersignatures: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Entry %s (%s), written %s\n", e.ID, name, ui.FormatTime(e.Created))
+	shown, valid, invalid, unlogged := 0, 0, 0, 0
+	for _, c := range cs {
+		if trusted != nil && c.PublicKey != trusted.PublicKeyString() {
+			continue
+		}
+		shown++
+		status := e.VerifyCountersignature(c, body)
+		switch status {
+		case entry.ProofValid:
+			valid++
+		case entry.ProofInvalid:
+			invalid++
+		}
+		fmt.Printf("  %-12s %-16s signed %s  %s\n", status, c.Witness, ui.FormatTime(c.Signed), c.PublicKey)
+		if c.Previous != "" {
+			logged, err := entry.CountersignLogged(&entry.Witnessed{Countersignature: c})
+			if err != nil {
+				fmt.Printf("Error reading countersignature log: %v\n", err)
+				os.Exit(1)
+			}
+			if !logged {
+				unlogged++
+				fmt.Println("               missing from the countersignature log; see `jot countersign verify-log`")
+			}
+		}
+		if *statement {
+			fmt.Printf("\n%s%s\n\n", e.CountersignStatement(c), c.Signature)
+		}
+	}
+
+	switch {
+	case shown == 0 && trusted != nil:
+		fmt.Printf("  Not countersigned by '%s'\n", trusted.Name)
+		os.Exit(1)
+	case shown == 0:
+		fmt.Println("  Not countersigned")
+		os.Exit(1)
+	case invalid > 0:
+		fmt.Printf("%s do not match the entry\n", plural(invalid, "countersignature"))
+		os.Exit(1)
+	case unlogged > 0:
+		fmt.Printf("%s missing from the countersignature log\n", plural(unlogged, "countersignature"))
+		os.Exit(1)
+	case valid == 0:
+		fmt.Println("The entry was edited after every countersignature; see `jot journal history`")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/veritome/jot/internal/backup"
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/prompt"
+	"github.com/veritome/jot/internal/template"
+)
+
+func handleDaemonCommand(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:7777", "Address to serve the API on")
+	grpcAddr := fs.String("grpc-addr", "", "Also serve the gRPC API on this address")
+	noAPI := fs.Bool("no-api", false, "Do not serve the API")
+	interval := fs.Duration("interval", 2*time.Second, "How often to check the data directory for changes")
+	drain := fs.Duration("shutdown-timeout", 10*time.Second, "How long to let open requests finish when stopping")
+	delay := fs.Duration("drain-delay", 0, "How long health checks fail before new requests are refused when stopping")
+	if rest := parseArgs(fs, args); len(rest) != 0 || *interval <= 0 || *delay < 0 {
+		fmt.Println("Usage: jot daemon [--addr host:port] [--grpc-addr host:port] [--no-api] [--interval 2s] [--shutdown-timeout 10s] [--drain-delay 5s]")
+		os.Exit(1)
+	}
+
+	// Unlock before opening the socket, so the daemon never asks itself
+	if err := crypto.Unlock(); err != nil {
+		fmt.Printf("Error unlocking keys: %v\n", err)
+		os.Exit(1)
+	}
+	agent, err := crypto.ListenAgent()
+	if err != nil {
+		fmt.Printf("Error starting key agent: %v\n", err)
+		os.Exit(1)
+	}
+	agentPath, _ := crypto.AgentPath()
+	defer os.Remove(agentPath)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		agent.Close()
+	}()
+	go func() {
+		if err := crypto.ServeAgent(agent); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}()
+	if crypto.FIDO2Enrolled() {
+		fmt.Println("Keys unlocked; jot commands will not ask for the security key while the daemon runs")
+	}
+
+	// A rebuild under way stops at the end of its batch before exiting
+	indexDone := make(chan struct{})
+	go func() {
+		defer close(indexDone)
+		maintainIndex(ctx, *interval)
+	}()
+	defer func() { <-indexDone }()
+	go createDrafts(ctx)
+	go sendPromptReminders(ctx)
+	go takeSnapshots(ctx)
+
+	if *noAPI {
+		fmt.Println("jot daemon running; press Ctrl+C to stop")
+		<-ctx.Done()
+		return
+	}
+	token, tokenPath := loadAPIToken(false)
+	fmt.Printf("jot daemon serving the API on http://%s (token in %s)\n", *addr, tokenPath)
+	if *grpcAddr != "" {
+		fmt.Printf("jot daemon serving the gRPC API on %s\n", *grpcAddr)
+	}
+	serveAPI(ctx, *addr, *grpcAddr, token, *delay, *drain)
+}
+
+// maintainIndex rebuilds the index whenever the journals change in a way
+// it does not reflect, such as when a sync tool brings in another
+// machine's entries, so the next search does not have to. It is built in
+// batches, like jot index rebuild --throttle, so a large archive does not
+// hold the data lock for long.
+func maintainIndex(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		if changed := collection.Modified(); changed.After(last) {
+			last = changed
+			if err := refreshIndex(ctx); err != nil && ctx.Err() == nil {
+				fmt.Printf("Error updating index: %v\n", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// draftInterval is how often the daemon checks for scheduled drafts; they
+// are due at a minute of the day
+const draftInterval = time.Minute
+
+// createDrafts adds the drafts of scheduled templates as they come due,
+// reporting a failure once rather than every minute
+func createDrafts(ctx context.Context) {
+	ticker := time.NewTicker(draftInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		created, err := template.RunDue(time.Now())
+		printCreatedDrafts(created)
+		switch {
+		case err == nil:
+			lastErr = ""
+		case err.Error() != lastErr:
+			lastErr = err.Error()
+			fmt.Printf("Error: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendPromptReminders reminds of the journal prompts that come due, checking
+// as often as for scheduled drafts
+func sendPromptReminders(ctx context.Context) {
+	ticker := time.NewTicker(draftInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		due, err := prompt.RunDue(time.Now())
+		remindPrompts(due)
+		switch {
+		case err == nil:
+			lastErr = ""
+		case err.Error() != lastErr:
+			lastErr = err.Error()
+			fmt.Printf("Error: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backupInterval is how often the daemon checks whether a snapshot is due
+const backupInterval = time.Minute
+
+// takeSnapshots takes the snapshots backup.schedule calls for while the
+// daemon runs, reporting a failure once rather than every minute
+func takeSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(backupInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		snapshot, pruned, err := backup.RunDue(time.Now())
+		if snapshot != nil {
+			fmt.Printf("%s Saved %s\n", time.Now().Format("15:04:05"), snapshot.Path)
+		}
+		printPruned(pruned)
+		switch {
+		case err == nil:
+			lastErr = ""
+		case err.Error() != lastErr:
+			lastErr = err.Error()
+			fmt.Printf("Error taking snapshot: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func refreshIndex(ctx context.Context) error {
+	coll, err := collection.Load()
+	if err != nil {
+		return err
+	}
+	// Another jot process may already be building it
+	if !coll.IndexStale() || runningIndexBuild() != nil {
+		return nil
+	}
+	n, err := throttledIndexBuild(ctx, false)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s Rebuilt the index: %s\n", time.Now().Format("15:04:05"), plural(n, "entry"))
+	return nil
+}
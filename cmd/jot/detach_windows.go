@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess starts a process without a console
+const detachedProcess = 0x00000008
+
+// detach starts cmd without a console and outside jot's process group, so
+// that it outlives the console jot was run from
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
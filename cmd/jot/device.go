@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/atrest"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/device"
+	"github.com/veritome/jot/internal/rotate"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/ui"
+	"golang.org/x/term"
+)
+
+func handleDeviceCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot device <add|approve|revoke|list> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot device add <name>")
+			os.Exit(1)
+		}
+		r, err := device.NewRequest(args[1])
+		if err != nil {
+			fmt.Printf("Error adding device: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("This device has a key of its own; approve it on a device that already reads your journals:\n\n")
+		fmt.Printf("  jot device approve %s %s\n\n", r.Name, r.PublicKey)
+		fmt.Printf("Go ahead only if it shows this code: %s\n", device.Code(r.Name, r.PublicKey))
+		fmt.Println("Once approved and synced, entries written on either device can be read on both")
+
+	case "approve":
+		handleDeviceApprove(args)
+
+	case "revoke":
+		handleDeviceRevoke(args)
+
+	case "list":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot device list")
+			os.Exit(1)
+		}
+		self, err := device.Self()
+		if err != nil {
+			fmt.Printf("Error loading devices: %v\n", err)
+			os.Exit(1)
+		}
+		unpinned, err := device.Unpinned()
+		if err != nil {
+			fmt.Printf("Error loading devices: %v\n", err)
+			os.Exit(1)
+		}
+		if len(journalCollection.Devices) == 0 {
+			fmt.Println("No devices approved; add one with `jot device add <name>` on the new device")
+		}
+		for _, d := range journalCollection.Devices {
+			var notes string
+			if d.Primary {
+				notes += " (primary)"
+			}
+			if self != nil && d.PublicKey == self.PublicKey {
+				notes += " (this device)"
+			}
+			if d.Revoked != nil {
+				notes += fmt.Sprintf(" (revoked %s)", ui.FormatTime(*d.Revoked))
+			}
+			for _, u := range unpinned {
+				if u.PublicKey == d.PublicKey {
+					notes += " (not approved on this device)"
+				}
+			}
+			fmt.Printf("  %-16s added %s%s\n", d.Name, ui.FormatTime(d.Added), notes)
+		}
+		if self != nil && self.Revoked == nil {
+			fmt.Printf("Code of this device: %s\n", device.Code(self.Name, self.PublicKey))
+		}
+		if len(unpinned) > 0 {
+			fmt.Println("Entries are not encrypted to devices not approved on this device. Approve each one here")
+			fmt.Println("only if the code it shows in `jot device list` matches:")
+			for _, d := range unpinned {
+				fmt.Printf("  jot device approve %s %s\n", d.Name, d.PublicKey)
+			}
+		}
+		r, err := device.PendingRequest()
+		if err != nil {
+			fmt.Printf("Error loading device request: %v\n", err)
+			os.Exit(1)
+		}
+		if r != nil {
+			fmt.Printf("This device asked to be approved as '%s' %s; run `jot device add %s` to see the request again\n",
+				r.Name, ui.FormatTime(r.Requested), r.Name)
+		}
+
+	default:
+		fmt.Printf("Unknown device command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleDeviceApprove(args []string) {
+	fs := flag.NewFlagSet("device approve", flag.ExitOnError)
+	host, _ := os.Hostname()
+	as := fs.String("as", host, "Name this device is recorded under when approving the first other one")
+	rest := parseArgs(fs, args[1:])
+	if len(rest) != 2 {
+		fmt.Println("Usage: jot device approve <name> <public-key> [--as name]")
+		os.Exit(1)
+	}
+	name, publicKey := rest[0], rest[1]
+
+	// The code is what proves the key came from the new device, so it is
+	// always compared by hand and there is no --yes
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !ui.IsTerminal() {
+		fmt.Println("Error approving device: its code has to be compared on a terminal")
+		os.Exit(1)
+	}
+	fmt.Printf("Code of device '%s': %s\n", name, device.Code(name, publicKey))
+	if !confirm(false, "Does the new device show exactly the same code?", "approve a device") {
+		return
+	}
+	if _, err := device.Approve(name, publicKey, *as); err != nil {
+		fmt.Printf("Error approving device: %v\n", err)
+		os.Exit(1)
+	}
+	// The new device opens the rest of the data directory with the master key
+	if err := atrest.Share(); err != nil {
+		fmt.Printf("Error sharing the master key with the device: %v\n", err)
+		os.Exit(1)
+	}
+	if len(journalCollection.Devices) == 0 {
+		fmt.Printf("Recorded this device as '%s', the primary device\n", *as)
+	}
+	fmt.Printf("Approved device '%s'; new entries are also encrypted to its key\n", name)
+	fmt.Println("Run `jot key rotate` to re-encrypt existing entries for it as well, then sync both devices")
+}
+
+func handleDeviceRevoke(args []string) {
+	fs := flag.NewFlagSet("device revoke", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "Revoke without asking for confirmation")
+	rest := parseArgs(fs, args[1:])
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot device revoke <name> [--yes]")
+		os.Exit(1)
+	}
+	name := rest[0]
+
+	var d *types.Device
+	for _, candidate := range journalCollection.Devices {
+		if candidate.Name == name {
+			d = candidate
+		}
+	}
+	if d == nil {
+		fmt.Printf("Device '%s' does not exist\n", name)
+		os.Exit(1)
+	}
+	if d.Revoked == nil && !confirm(*yes, fmt.Sprintf("Revoke device '%s'? Entries sealed to its key will be re-encrypted without it.", name), "revoke a device") {
+		return
+	}
+	wasRevoked := d.Revoked != nil
+	d, err := device.Revoke(name)
+	if err != nil {
+		fmt.Printf("Error revoking device: %v\n", err)
+		os.Exit(1)
+	}
+	if wasRevoked {
+		fmt.Printf("Device '%s' was revoked %s; re-encrypting entries still sealed to its key\n", name, ui.FormatTime(*d.Revoked))
+	} else {
+		fmt.Printf("Revoked device '%s'; new entries are no longer encrypted to its key\n", name)
+	}
+
+	key, err := (crypto.Recipient{PublicKey: d.PublicKey}).Key()
+	if err != nil {
+		fmt.Printf("Error reading device key: %v\n", err)
+		os.Exit(1)
+	}
+	interactive := ui.IsTerminal()
+	result, err := rotate.Revoke(key, func(done, total int) {
+		if interactive {
+			fmt.Printf("\rChecking entries: %d/%d", done, total)
+		}
+	})
+	if interactive {
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("Error re-encrypting entries: %v\n", err)
+		if !errors.Is(err, crypto.ErrPrivateKeyOffline) {
+			fmt.Printf("Run `jot device revoke %s` again to finish\n", name)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("Re-encrypted %s without its key\n", plural(result.Reencrypted, "entry"))
+
+	// The device knows the master key, so the rest of the data directory
+	// is sealed under a new one
+	sealed, err := atrest.Rotate()
+	if err != nil {
+		fmt.Printf("Error replacing the master key: %v\n", err)
+		fmt.Printf("Run `jot device revoke %s` again to finish\n", name)
+		os.Exit(1)
+	}
+	fmt.Printf("Sealed %s under a new master key\n", plural(sealed, "file"))
+	if len(result.Skipped) > 0 {
+		fmt.Printf("%d sealed entries in append-only journals stay readable with its key\n", len(result.Skipped))
+	}
+	fmt.Printf("Once the other devices have synced, run `jot device revoke %s` again to cover entries they wrote meanwhile\n", name)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/digest"
+	"github.com/veritome/jot/internal/notify"
+	"golang.org/x/term"
+)
+
+// digestURLKeys maps each webhook target to the setting holding its URL
+var digestURLKeys = map[digest.Target]string{
+	digest.Slack:   "digest.slack_url",
+	digest.Discord: "digest.discord_url",
+	digest.Webhook: "digest.webhook_url",
+}
+
+func handleDigestCommand(args []string) {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	post := fs.String("post", "", "Send the digest to slack, discord or webhook")
+	days := fs.Int("days", cfg.Int("digest.days"), "Number of days to summarise")
+	yes := fs.Bool("yes", false, "Post without asking for confirmation")
+	notifyFlag := fs.Bool("notify", false, "Show the digest as a desktop notification")
+	if rest := parseArgs(fs, args); len(rest) != 0 || *days < 1 {
+		fmt.Println("Usage: jot digest [--post slack|discord|webhook] [--notify] [--days N] [--yes]")
+		os.Exit(1)
+	}
+
+	stats, err := digest.Compute(journalCollection.Journals, time.Now(), *days)
+	if err != nil {
+		fmt.Printf("Error computing digest: %v\n", err)
+		os.Exit(1)
+	}
+	savePreviews()
+
+	tmpl := cfg.String("digest.template")
+	if tmpl == "" {
+		tmpl = digest.DefaultMessage
+	}
+	stats.Text, err = digest.Render(tmpl, stats)
+	if err != nil {
+		fmt.Printf("Error rendering digest.template: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *notifyFlag {
+		if err := notify.Send("jot digest", stats.Text); err != nil {
+			fmt.Printf("Warning: failed to show notification: %v\n", err)
+		}
+	}
+	if *post == "" {
+		if !*notifyFlag {
+			fmt.Println(stats.Text)
+		}
+		return
+	}
+
+	target := digest.Target(*post)
+	urlKey, ok := digestURLKeys[target]
+	if !ok {
+		fmt.Printf("Unknown digest target '%s': expected slack, discord or webhook\n", *post)
+		os.Exit(1)
+	}
+	url := cfg.String(urlKey)
+	if url == "" {
+		fmt.Printf("No webhook URL configured; set one with `jot config set %s <url>`\n", urlKey)
+		os.Exit(1)
+	}
+
+	payloadTmpl := cfg.String("digest.payload")
+	if payloadTmpl == "" {
+		payloadTmpl = digest.DefaultPayload
+	}
+	payload, err := digest.Payload(target, stats, payloadTmpl)
+	if err != nil {
+		fmt.Printf("Error building payload: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Nothing is sent without the user seeing it first, unless --yes is given
+	if !*yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Println("Refusing to post without confirmation; pass --yes to post non-interactively")
+			os.Exit(1)
+		}
+		fmt.Printf("The following will be sent to %s:\n\n%s\n\nPost it? (y/N): ", target, payload)
+		response, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			fmt.Printf("Error reading response: %v\n", err)
+			os.Exit(1)
+		}
+		response = strings.TrimSpace(response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Operation cancelled")
+			return
+		}
+	}
+
+	if err := digest.Post(context.Background(), url, payload); err != nil {
+		fmt.Printf("Error posting digest: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Posted digest to %s\n", target)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/doctor"
+)
+
+func handleDoctorCommand(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "Repair the problems that can be repaired safely")
+	orphans := fs.String("orphans", doctor.AdoptOrphans, "How to fix entries of journals that no longer exist: adopt (into 'recovered') or recreate (their journal)")
+	isolation := fs.Bool("check-isolation", false, "Check instead that other users of this machine cannot reach jot's files, lock, socket or drafts")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot doctor [--fix] [--orphans adopt|recreate] [--check-isolation]")
+		os.Exit(1)
+	}
+
+	if *isolation {
+		report, err := doctor.CheckIsolation(*fix)
+		if err != nil {
+			fmt.Printf("Error checking isolation: %v\n", err)
+			os.Exit(1)
+		}
+		printDoctorReport(report, fmt.Sprintf("Checked %s for access by other users: ", plural(report.Files, "file")), "jot doctor --check-isolation --fix")
+		return
+	}
+
+	report, err := doctor.Check(*fix, *orphans)
+	if err != nil {
+		fmt.Printf("Error checking data directory: %v\n", err)
+		os.Exit(1)
+	}
+	printDoctorReport(report, fmt.Sprintf("Checked %d journals and %d entries: ", report.Journals, report.Entries), "jot doctor --fix")
+}
+
+// printDoctorReport lists the problems of report after checked, which says
+// what was looked at, points to fixCommand for those it can repair and
+// exits with an error while any remain
+func printDoctorReport(report *doctor.Report, checked, fixCommand string) {
+	fixable := 0
+	for _, p := range report.Problems {
+		switch {
+		case p.Fixed:
+			fmt.Printf("  fixed  %s: %s\n", p.Subject, p.Message)
+		case p.Fixable:
+			fmt.Printf("  fix    %s: %s\n", p.Subject, p.Message)
+			fixable++
+		default:
+			fmt.Printf("  error  %s: %s\n", p.Subject, p.Message)
+		}
+	}
+
+	fmt.Print(checked)
+	if len(report.Problems) == 0 {
+		fmt.Println("no problems found")
+		return
+	}
+	fmt.Printf("%d problems, %d unresolved\n", len(report.Problems), report.Unresolved())
+	if fixable > 0 {
+		fmt.Printf("Run `%s` to repair %d of them\n", fixCommand, fixable)
+	}
+	if report.Unresolved() > 0 {
+		os.Exit(1)
+	}
+}
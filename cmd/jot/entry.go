@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/internal/when"
+)
+
+const entrySetUsage = "Usage: jot entry set <id> [--created \"YYYY-MM-DD HH:MM\"] [--journal name] [--tag +tag] [--tag -tag] [--title text]"
+
+func handleEntryCommand(args []string) {
+	if len(args) == 0 || args[0] != "set" {
+		fmt.Println("Usage: jot entry set <id> [options]")
+		os.Exit(1)
+	}
+	handleEntrySet(args[1:])
+}
+
+// handleEntrySet changes the date, journal, tags or title of an entry
+// without touching its body, recording the change for jot undo
+func handleEntrySet(args []string) {
+	fs := flag.NewFlagSet("entry set", flag.ExitOnError)
+	created := fs.String("created", "", "New date and time: YYYY-MM-DD HH:MM, or a date such as yesterday keeping the time of day")
+	dest := fs.String("journal", "", "Journal to move the entry into")
+	var tags stringList
+	fs.Var(&tags, "tag", "+tag adds a tag and -tag removes one (repeatable)")
+	title := fs.String("title", "", "New title; \"-\" removes it")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 || (*created == "" && *dest == "" && len(tags) == 0 && *title == "") {
+		fmt.Println(entrySetUsage)
+		os.Exit(1)
+	}
+	id := rest[0]
+
+	e, err := entry.Load(id)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	j, exists := journalCollection.JournalByID(e.JournalID)
+	if !exists {
+		fmt.Printf("Entry %s belongs to no journal; run jot doctor\n", id)
+		os.Exit(1)
+	}
+	source := journal.FromType(j)
+	if source.AppendOnly {
+		fmt.Printf("Journal '%s' is append-only; its entries cannot be changed\n", source.Name)
+		os.Exit(1)
+	}
+	if source.IsShared() {
+		fmt.Printf("Journal '%s' is shared; its entries cannot be changed with jot entry set\n", source.Name)
+		os.Exit(1)
+	}
+	if *dest == source.Name {
+		*dest = ""
+	}
+	if *dest != "" {
+		if _, exists := journalCollection.Journals[*dest]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", *dest)
+			os.Exit(1)
+		}
+	}
+
+	before, err := oplog.ReadEntry(id)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var changed []string
+	if *created != "" {
+		t, err := parseCreated(*created, e.Created)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !t.Equal(e.Created) {
+			e.Created = t
+			changed = append(changed, "date")
+		}
+	}
+	if len(tags) > 0 {
+		current, err := e.GetTags()
+		if err != nil {
+			fmt.Printf("Error reading tags: %v\n", err)
+			os.Exit(1)
+		}
+		next := applyTagChanges(current, tags)
+		if strings.Join(next, ",") != strings.Join(current, ",") {
+			if err := e.SetTags(next); err != nil {
+				fmt.Printf("Error tagging entry: %v\n", err)
+				os.Exit(1)
+			}
+			changed = append(changed, "tags")
+		}
+	}
+	if *title != "" {
+		current, err := e.GetTitle()
+		if err != nil {
+			fmt.Printf("Error reading title: %v\n", err)
+			os.Exit(1)
+		}
+		next := *title
+		if next == "-" {
+			next = ""
+		}
+		if strings.TrimSpace(next) != current {
+			if err := e.SetTitle(next); err != nil {
+				fmt.Printf("Error setting title: %v\n", err)
+				os.Exit(1)
+			}
+			changed = append(changed, "title")
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := e.Save(); err != nil {
+			fmt.Printf("Error saving entry: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	now := source.AsType()
+	if *dest != "" {
+		if err := source.MoveEntry(id, *dest); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		now = journalCollection.Journals[*dest]
+		changed = append(changed, "journal")
+	}
+	if len(changed) == 0 {
+		fmt.Println("No changes made")
+		return
+	}
+
+	op := &oplog.Op{Journal: now.Name, JournalID: now.ID, EntryID: id, Changed: changed}
+	if *dest != "" {
+		op.MovedFrom, op.MovedFromID = source.Name, source.ID
+	}
+	if err := oplog.RecordEntryChange(op, before); err != nil {
+		fmt.Printf("Warning: failed to record operation for jot undo: %v\n", err)
+	}
+	fmt.Printf("Changed the %s of entry %s", strings.Join(changed, ", "), id)
+	if *dest != "" {
+		fmt.Printf(", now in '%s'", *dest)
+	}
+	fmt.Println()
+	if *created != "" {
+		fmt.Printf("Dated %s\n", ui.FormatTime(e.Created))
+	}
+}
+
+// parseCreated reads a new date for an entry written at current: a date
+// and a time of day separated by a space, or only a date, which keeps the
+// entry's time of day. Dates and times take the forms of --date and --time.
+func parseCreated(value string, current time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	now := time.Now()
+	if i := strings.LastIndex(value, " "); i > 0 {
+		date, clock := value[:i], value[i+1:]
+		if when.IsClock(clock) {
+			return when.Parse(date, clock, now)
+		}
+	}
+	return when.Parse(value, current.In(now.Location()).Format("15:04"), now)
+}
+
+// applyTagChanges adds the tags given as +tag or tag and removes those given
+// as -tag
+func applyTagChanges(current []string, changes []string) []string {
+	tags := append([]string(nil), current...)
+	for _, c := range changes {
+		if strings.HasPrefix(c, "-") {
+			remove := entry.NormalizeTags([]string{c[1:]})
+			kept := tags[:0]
+			for _, t := range tags {
+				if len(remove) == 0 || t != remove[0] {
+					kept = append(kept, t)
+				}
+			}
+			tags = kept
+			continue
+		}
+		tags = append(tags, strings.TrimPrefix(c, "+"))
+	}
+	return entry.NormalizeTags(tags)
+}
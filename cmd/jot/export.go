@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/export"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleExportJournal(args []string) {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "text", "Export format: text, html, logseq, json, csv or encrypted-bundle")
+	theme := fs.String("theme", cfg.String("export.html_theme"), "Colour theme of --format html: "+strings.Join(export.Themes, ", "))
+	armor := fs.Bool("armor", false, "Encrypt each entry as an ASCII-armored OpenPGP message")
+	recipient := fs.String("recipient", "", "GPG key to encrypt to with --armor (default crypto.gpg_recipient)")
+	output := fs.String("output", "", "Write to this file instead of stdout, or to this directory with --format html or logseq")
+	anonymize := fs.Bool("anonymize", false, "Replace listed names, email addresses and phone numbers with placeholders")
+	yes := fs.Bool("yes", false, "Export decrypted entries without asking, when confirm.plaintext_export is set")
+	names := parseArgs(fs, args[1:])
+	// Data formats and Logseq pages carry the journal of each entry, so they
+	// can hold all of them
+	bundle := *format == "encrypted-bundle"
+	logseq := *format == "logseq"
+	data := *format == "json" || *format == "csv" || bundle
+	if len(names) > 1 || (len(names) == 0 && !data && !logseq) || (*format != "text" && *format != "html" && !logseq && !data) {
+		fmt.Println("Usage: jot export <journal> [--format text|html|logseq|json|csv|encrypted-bundle] [--theme name] [--armor] [--recipient key] [--anonymize] [--output path] [--yes]")
+		fmt.Println("       jot export --format logseq|json|csv|encrypted-bundle [--anonymize] [--output path] [--yes]")
+		os.Exit(1)
+	}
+	if bundle && *output == "" {
+		fmt.Println("--format encrypted-bundle needs a file: --output <file>")
+		os.Exit(1)
+	}
+	if *armor && *format != "text" {
+		fmt.Printf("--armor cannot be combined with --format %s\n", *format)
+		os.Exit(1)
+	}
+
+	var journals []*journal.Journal
+	if len(names) == 0 {
+		sorted := make([]string, 0, len(journalCollection.Journals))
+		for name := range journalCollection.Journals {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			journals = append(journals, journal.FromType(journalCollection.Journals[name]))
+		}
+		if len(journals) == 0 {
+			fmt.Println("No journals found")
+			os.Exit(1)
+		}
+	} else {
+		j, exists := journalCollection.Journals[names[0]]
+		if !exists {
+			fmt.Printf("Journal '%s' does not exist\n", names[0])
+			os.Exit(1)
+		}
+		journals = append(journals, journal.FromType(j))
+	}
+	wrappedJ := journals[0]
+
+	var anon *export.Anonymizer
+	if *anonymize {
+		if anon, err = export.LoadAnonymizer(); err != nil {
+			fmt.Printf("Error loading names to anonymize: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if !bundle && !*armor && cfg.Bool("confirm.plaintext_export") {
+		what := fmt.Sprintf("journal '%s'", wrappedJ.Name)
+		if len(names) == 0 {
+			what = "every journal"
+		}
+		to := "the terminal"
+		if *output != "" {
+			to = *output
+		}
+		if !confirm(*yes, fmt.Sprintf("Export %s decrypted to %s?", what, to), "export decrypted entries") {
+			return
+		}
+	}
+
+	// Exports hold every entry, so archived years are thawed first
+	var ids []string
+	for _, j := range journals {
+		ids = append(ids, j.EntryIDs...)
+	}
+	thawEntries(ids)
+
+	if *format == "html" {
+		exportHTML(wrappedJ, *output, *theme, anon)
+		return
+	}
+	if logseq {
+		exportLogseq(journals, *output, anon)
+		return
+	}
+
+	if *armor && *recipient == "" {
+		*recipient = cfg.String("crypto.gpg_recipient")
+		if *recipient == "" {
+			fmt.Println("No GPG recipient: pass --recipient or set crypto.gpg_recipient")
+			os.Exit(1)
+		}
+	}
+
+	// Plain text export masks entries on screen in discreet mode; the other
+	// decrypted formats cannot, so they only go to files
+	if ui.Discreet() && *output == "" && (data || anon != nil) && !bundle {
+		fmt.Println("Not printing decrypted entries in discreet mode; write them to a file with --output")
+		os.Exit(1)
+	}
+
+	var passphrase string
+	if bundle {
+		if passphrase, err = readPassphrase(true); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Printf("Error creating export file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	exported := fmt.Sprintf("journal '%s'", wrappedJ.Name)
+	switch {
+	case data:
+		var entries []export.Entry
+		for _, j := range journals {
+			entries = append(entries, exportEntries(j, anon)...)
+		}
+		sort.SliceStable(entries, func(a, b int) bool { return entries[a].Created.Before(entries[b].Created) })
+		switch *format {
+		case "json":
+			err = export.JSON(w, entries)
+		case "encrypted-bundle":
+			err = export.WriteBundle(w, passphrase, entries)
+		default:
+			err = export.CSV(w, entries)
+		}
+		exported = fmt.Sprintf("%d entries", len(entries))
+	case *armor:
+		err = exportArmored(w, exportEntries(wrappedJ, anon), *recipient)
+	case anon != nil:
+		for i, e := range exportEntries(wrappedJ, anon) {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			ui.PrintEntry(w, e.ID, e.Created, e.Author, e.Title, e.Tags, e.Fields, e.Body)
+		}
+	default:
+		err = ui.PrintEntries(w, wrappedJ, nil)
+	}
+	if err != nil {
+		fmt.Printf("Error exporting journal: %v\n", err)
+		os.Exit(1)
+	}
+	if *output != "" {
+		fmt.Printf("Exported %s to %s\n", exported, *output)
+	}
+}
+
+// exportEntries decrypts the entries of j for exporting, anonymized when
+// anon is set
+func exportEntries(j *journal.Journal, anon *export.Anonymizer) []export.Entry {
+	entries, err := export.Load(j)
+	if err != nil {
+		fmt.Printf("Error exporting journal: %v\n", err)
+		os.Exit(1)
+	}
+	if anon != nil {
+		anon.Entries(entries)
+	}
+	return entries
+}
+
+// exportHTML writes j as a static site to the directory dir
+func exportHTML(j *journal.Journal, dir, theme string, anon *export.Anonymizer) {
+	if dir == "" {
+		fmt.Println("--format html needs a directory: --output <dir>")
+		os.Exit(1)
+	}
+
+	entries := exportEntries(j, anon)
+	if err := export.HTML(dir, j.Name, entries, theme); err != nil {
+		fmt.Printf("Error exporting journal: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported %d entries of '%s' to %s\n", len(entries), j.Name, filepath.Join(dir, "index.html"))
+	fmt.Println("The site holds decrypted entries; keep it somewhere private")
+}
+
+// exportLogseq writes the entries of journals as day pages of the Logseq
+// graph in dir
+func exportLogseq(journals []*journal.Journal, dir string, anon *export.Anonymizer) {
+	if dir == "" {
+		fmt.Println("--format logseq needs the graph's directory: --output <dir>")
+		os.Exit(1)
+	}
+
+	var entries []export.Entry
+	for _, j := range journals {
+		entries = append(entries, exportEntries(j, anon)...)
+	}
+	days, err := export.Logseq(dir, entries)
+	if err != nil {
+		fmt.Printf("Error exporting to Logseq: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported %s to %s in %s\n", plural(len(entries), "entry"), plural(days, "day page"), filepath.Join(dir, "journals"))
+}
+
+// exportArmored writes every entry as its own armored OpenPGP message,
+// preceded by a line with the entry ID and date
+func exportArmored(w io.Writer, entries []export.Entry, recipient string) error {
+	for i, e := range entries {
+		armored, err := crypto.ArmorGPG(e.Body, recipient)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt entry %s: %w", e.ID, err)
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s %s\n%s", e.ID, ui.FormatTime(e.Created), armored)
+	}
+
+	return nil
+}
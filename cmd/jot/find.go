@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleFindCommand(args []string) {
+	fs := flag.NewFlagSet("find", flag.ExitOnError)
+	journalFlag := fs.String("journal", "", "Only search this journal")
+	raw := fs.Bool("raw", false, "Show the opened entry as written instead of rendering Markdown")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot find [--journal name] [--raw]")
+		os.Exit(1)
+	}
+	ui.SetRaw(*raw)
+
+	names := make([]string, 0, len(journalCollection.Journals))
+	for name := range journalCollection.Journals {
+		if *journalFlag == "" || name == *journalFlag {
+			names = append(names, name)
+		}
+	}
+	if *journalFlag != "" && len(names) == 0 {
+		fmt.Printf("Journal '%s' does not exist\n", *journalFlag)
+		os.Exit(1)
+	}
+	sort.Strings(names)
+	journals := make([]*journal.Journal, 0, len(names))
+	for _, name := range names {
+		journals = append(journals, journal.FromType(journalCollection.Journals[name]))
+	}
+
+	result, err := ui.HandleFind(journals)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if result == nil {
+		return
+	}
+
+	switch result.Action {
+	case ui.FindOpen:
+		e := loadJournalEntry(result.Journal, result.EntryID)
+		if ui.Discreet() {
+			fmt.Printf("%s %s (%s)\n%s\n", e.ID, ui.FormatTime(e.Created), result.Journal, ui.HiddenPreview())
+			return
+		}
+		body, err := e.GetDecryptedBody()
+		if err != nil {
+			fmt.Printf("Error decrypting entry: %v\n", err)
+			os.Exit(1)
+		}
+		title, err := e.GetTitle()
+		if err != nil {
+			fmt.Printf("Error reading title: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s %s (%s)\n", e.ID, ui.FormatTime(e.Created), result.Journal)
+		if title != "" {
+			fmt.Printf("# %s\n", title)
+		}
+		fmt.Println(ui.RenderMarkdown(body))
+	case ui.FindEdit:
+		handleEditEntry([]string{"edit", result.Journal, result.EntryID})
+	case ui.FindDelete:
+		handleJournalCommand([]string{"delete-entry", result.Journal, result.EntryID})
+	}
+}
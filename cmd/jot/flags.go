@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+	"golang.org/x/term"
+)
+
+// parseArgs parses fs from args, allowing flags to appear before, between or
+// after positional arguments, and returns the positional arguments in order.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// confirm asks question and reports whether the answer was yes, without
+// asking when yes is already set by --yes. Without a terminal to ask on it
+// exits, explaining that refused needs --yes.
+func confirm(yes bool, question, refused string) bool {
+	if yes {
+		return true
+	}
+	response := ask(fmt.Sprintf("%s (y/N): ", question), refused)
+	if response != "y" && response != "Y" {
+		fmt.Println("Operation cancelled")
+		return false
+	}
+	return true
+}
+
+// confirmPhrase asks question like confirm, but goes ahead only when phrase
+// is typed out in full, for deletions that cannot be undone
+func confirmPhrase(yes bool, question, phrase, refused string) bool {
+	if yes {
+		return true
+	}
+	if ask(fmt.Sprintf("%s\nType %q to confirm: ", question, phrase), refused) != phrase {
+		fmt.Println("Operation cancelled")
+		return false
+	}
+	return true
+}
+
+// ask shows prompt and returns the line typed in answer. Without a terminal
+// to ask on it exits, explaining that refused needs --yes.
+func ask(prompt, refused string) string {
+	// The question must be seen as well as answered, so output piped to a
+	// file counts as no terminal too
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Printf("Refusing to %s without confirmation; pass --yes to run non-interactively\n", refused)
+		os.Exit(1)
+	}
+	fmt.Print(prompt)
+	response, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Printf("Error reading response: %v\n", err)
+		os.Exit(1)
+	}
+	return strings.TrimSpace(response)
+}
+
+// confirmConfig returns the configuration holding the confirm.* settings,
+// exiting when it cannot be loaded
+func confirmConfig() *config.Config {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// confirmDelete asks question like confirm when deleting n entries is above
+// confirm.delete_entries, and otherwise goes ahead without asking
+func confirmDelete(yes bool, n int, question, refused string) bool {
+	if limit := confirmConfig().Int("confirm.delete_entries"); limit > 0 && n <= limit {
+		return true
+	}
+	return confirm(yes, question, refused)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/gc"
+)
+
+func handleGCCommand(args []string) {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Report what would be removed without removing anything")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot gc [--dry-run]")
+		os.Exit(1)
+	}
+
+	result, err := gc.Run(*dryRun)
+	if err != nil {
+		fmt.Printf("Error collecting garbage: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d blobs, %d in use", result.Blobs, result.Referenced)
+	if result.Shared > 0 {
+		fmt.Printf(" (%d shared, saving %s)", result.Shared, formatBytes(result.Saved))
+	}
+	fmt.Println()
+
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d unreferenced blobs, freeing %s\n", verb, result.Removed, formatBytes(result.Freed))
+	if result.Recent > 0 {
+		fmt.Printf("Kept %d unreferenced blobs written in the last hour\n", result.Recent)
+	}
+}
+
+// formatBytes renders a size in bytes with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/goal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleGoalCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot goal <set|clear|status> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "set":
+		fs := flag.NewFlagSet("goal set", flag.ExitOnError)
+		journalFlag := fs.String("journal", "", "Journal to set the goal for (default journal if omitted)")
+		daily := fs.Int("daily", 0, "Entries to write every day")
+		weekly := fs.Int("weekly", 0, "Entries to write every week")
+		if rest := parseArgs(fs, args[1:]); len(rest) != 0 || (*daily == 0) == (*weekly == 0) {
+			fmt.Println("Usage: jot goal set [--journal name] (--daily N | --weekly N)")
+			os.Exit(1)
+		}
+
+		period, entries := goal.Daily, *daily
+		if *weekly != 0 {
+			period, entries = goal.Weekly, *weekly
+		}
+		g, err := goal.New(period, entries, time.Now())
+		if err != nil {
+			fmt.Printf("Error setting goal: %v\n", err)
+			os.Exit(1)
+		}
+		name := goalJournal(*journalFlag)
+		if err := goal.Set(name, g); err != nil {
+			fmt.Printf("Error setting goal: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Goal for '%s': %s\n", name, describeGoal(g.Period, g.Entries))
+
+	case "clear":
+		fs := flag.NewFlagSet("goal clear", flag.ExitOnError)
+		journalFlag := fs.String("journal", "", "Journal to clear the goal of (default journal if omitted)")
+		if rest := parseArgs(fs, args[1:]); len(rest) != 0 {
+			fmt.Println("Usage: jot goal clear [--journal name]")
+			os.Exit(1)
+		}
+
+		name := goalJournal(*journalFlag)
+		if journalCollection.Journals[name].Goal == nil {
+			fmt.Printf("Journal '%s' has no goal\n", name)
+			return
+		}
+		if err := goal.Set(name, nil); err != nil {
+			fmt.Printf("Error clearing goal: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cleared the goal of '%s'\n", name)
+
+	case "status":
+		fs := flag.NewFlagSet("goal status", flag.ExitOnError)
+		journalFlag := fs.String("journal", "", "Only show this journal's goal")
+		short := fs.Bool("short", false, "Print a single line for shell prompts")
+		if rest := parseArgs(fs, args[1:]); len(rest) != 0 {
+			fmt.Println("Usage: jot goal status [--journal name] [--short]")
+			os.Exit(1)
+		}
+		handleGoalStatus(*journalFlag, *short)
+
+	default:
+		fmt.Printf("Unknown goal command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// goalJournal returns the journal named by --journal, or the default
+// journal, exiting if it does not exist
+func goalJournal(name string) string {
+	if name == "" {
+		name = defaultJournal()
+	}
+	if _, exists := journalCollection.Journals[name]; !exists {
+		fmt.Printf("Journal '%s' does not exist\n", name)
+		os.Exit(1)
+	}
+	return name
+}
+
+func handleGoalStatus(journalName string, short bool) {
+	var names []string
+	if journalName != "" {
+		names = []string{goalJournal(journalName)}
+	} else {
+		for name, j := range journalCollection.Journals {
+			if j.Goal != nil {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	if len(names) == 0 || (journalName != "" && journalCollection.Journals[journalName].Goal == nil) {
+		// A prompt stays quiet rather than nagging about goals never set
+		if short {
+			return
+		}
+		if journalName != "" {
+			fmt.Printf("Journal '%s' has no goal; set one with `jot goal set --journal %s --daily 1`\n", journalName, journalName)
+		} else {
+			fmt.Println("No writing goals; set one with `jot goal set --daily 1`")
+		}
+		return
+	}
+
+	// Data written before the index existed, or synced in from another
+	// machine, is indexed on first use
+	if journalCollection.Index == nil {
+		if _, err := entry.RebuildIndex(); err != nil {
+			fmt.Printf("Error building index: %v\n", err)
+			os.Exit(1)
+		}
+		loadCollection()
+	}
+
+	now := time.Now()
+	var parts []string
+	for i, name := range names {
+		s, err := goal.Compute(journalCollection, name, now)
+		if err != nil {
+			fmt.Printf("Error computing goal: %v\n", err)
+			os.Exit(1)
+		}
+		unit := periodUnit(s.Goal.Period)
+
+		if short {
+			parts = append(parts, fmt.Sprintf("%s %d/%d %d%c", name, s.Done, s.Goal.Entries, s.Streak, unit[0]))
+			continue
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s: %s\n", name, describeGoal(s.Goal.Period, s.Goal.Entries))
+		current := "Today"
+		if s.Goal.Period == goal.Weekly {
+			current = "This week"
+		}
+		fmt.Printf("  %-10s %s %d/%d\n", current, ui.ProgressBar(s.Done, s.Goal.Entries, 20), s.Done, s.Goal.Entries)
+		fmt.Printf("  %-10s %s (best %s)\n", "Streak", plural(s.Streak, unit), plural(s.Best, unit))
+		if s.Checked > 0 {
+			fmt.Printf("  %-10s %d of the last %s\n", "Missed", s.Missed, plural(s.Checked, unit))
+		}
+		recent := make([]string, len(s.Recent))
+		for k, n := range s.Recent {
+			recent[k] = fmt.Sprint(n)
+		}
+		fmt.Printf("  %-10s %s (last %d %ss, oldest first)\n", "Entries", strings.Join(recent, " "), len(s.Recent), unit)
+	}
+	if short {
+		fmt.Println(strings.Join(parts, " "))
+	}
+}
+
+// describeGoal renders a goal as "2 entries a day"
+func describeGoal(period string, entries int) string {
+	return fmt.Sprintf("%s a %s", plural(entries, "entry"), periodUnit(period))
+}
+
+// periodUnit returns the name of one goal period
+func periodUnit(period string) string {
+	if period == goal.Weekly {
+		return "week"
+	}
+	return "day"
+}
+
+// plural renders n with the noun, pluralised unless n is one
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if noun == "entry" {
+		return fmt.Sprintf("%d entries", n)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
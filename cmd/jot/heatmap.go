@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// handleHeatmapCommand shows how much was written each day of a year, in
+// one journal or all of them, as a grid of shaded days
+func handleHeatmapCommand(args []string) {
+	fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	year := fs.Int("year", 0, "Calendar year to show (defaults to the last twelve months)")
+	rest := parseArgs(fs, args)
+	if len(rest) > 1 || *year < 0 {
+		fmt.Println("Usage: jot heatmap [journal] [--year YYYY]")
+		os.Exit(1)
+	}
+
+	var names []string
+	if len(rest) == 1 {
+		if _, exists := journalCollection.Journals[rest[0]]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", rest[0])
+			os.Exit(1)
+		}
+		names = rest
+	} else {
+		for n := range journalCollection.Journals {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	start, end := today.AddDate(-1, 0, 1), today.AddDate(0, 0, 1)
+	label := "the last twelve months"
+	if *year != 0 {
+		start = time.Date(*year, time.January, 1, 0, 0, 0, 0, time.Local)
+		end = start.AddDate(1, 0, 0)
+		label = fmt.Sprint(*year)
+		if end.After(today) {
+			// The rest of this year has nothing to show yet
+			end = today.AddDate(0, 0, 1)
+		}
+		if !start.Before(end) {
+			fmt.Printf("%d has not started yet\n", *year)
+			os.Exit(1)
+		}
+	}
+
+	days := int(end.Sub(start).Hours()/24 + 0.5)
+	words := make([]int, days)
+	entries, total := 0, 0
+	for _, n := range names {
+		list, err := journal.FromType(journalCollection.Journals[n]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range list {
+			// Only entries in range are decrypted
+			created := e.Created.Local()
+			if created.Before(start) || !created.Before(end) {
+				continue
+			}
+			preview, err := e.GetPreview()
+			if err != nil {
+				fmt.Printf("Error decrypting entry %s: %v\n", e.ID, err)
+				os.Exit(1)
+			}
+			day := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.Local)
+			n := preview.Words
+			words[int(day.Sub(start).Hours()/24+0.5)] += n
+			entries++
+			total += n
+		}
+	}
+	savePreviews()
+
+	scope := "every journal"
+	if len(rest) == 1 {
+		scope = fmt.Sprintf("'%s'", rest[0])
+	}
+	fmt.Printf("Words written in %s, %s\n\n", scope, label)
+	fmt.Print(ui.Heatmap(start, words))
+
+	written, streak, longest, busiest := 0, 0, 0, 0
+	for i, w := range words {
+		if w == 0 {
+			streak = 0
+			continue
+		}
+		written++
+		if streak++; streak > longest {
+			longest = streak
+		}
+		if w > words[busiest] {
+			busiest = i
+		}
+	}
+	fmt.Printf("\n%s in %s on %s of %d", plural(total, "word"), plural(entries, "entry"), plural(written, "day"), days)
+	if written > 0 {
+		fmt.Printf("; longest streak %s, most on %s (%s)",
+			plural(longest, "day"), start.AddDate(0, 0, busiest).Format("Mon Jan 2"), plural(words[busiest], "word"))
+	}
+	fmt.Println()
+}
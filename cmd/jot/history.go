@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// loadJournalEntry loads an entry and verifies that it belongs to the named journal
+func loadJournalEntry(journalName, entryID string) *entry.Entry {
+	j, exists := journalCollection.Journals[journalName]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", journalName)
+		os.Exit(1)
+	}
+
+	e, err := journal.FromType(j).LoadEntry(entryID)
+	if err != nil {
+		fmt.Printf("Error loading entry: %v\n", err)
+		os.Exit(1)
+	}
+
+	if e.JournalID != j.ID {
+		fmt.Printf("Entry %s does not belong to journal '%s'\n", entryID, journalName)
+		os.Exit(1)
+	}
+
+	return e
+}
+
+func handleEditEntry(args []string) {
+	if len(args) != 3 {
+		fmt.Println("Usage: jot journal edit <journal-name> <entry-id>")
+		os.Exit(1)
+	}
+	e := loadJournalEntry(args[1], args[2])
+
+	current, err := e.GetDecryptedBody()
+	if err != nil {
+		fmt.Printf("Error decrypting entry: %v\n", err)
+		os.Exit(1)
+	}
+
+	text, ok, err := ui.HandleCompose(fmt.Sprintf("Editing entry %s", e.ID), current)
+	if err != nil {
+		fmt.Printf("Error editing entry: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok || text == current {
+		fmt.Println("No changes made")
+		return
+	}
+
+	// Append-only journals never rewrite an entry; the edit becomes a new
+	// entry that supersedes the old one
+	wrappedJ := journal.FromType(journalCollection.Journals[args[1]])
+	if wrappedJ.AppendOnly {
+		next, err := wrappedJ.NewEntry(text)
+		if err != nil {
+			fmt.Printf("Error creating entry: %v\n", err)
+			os.Exit(1)
+		}
+		next.Supersedes = e.ID
+		saveNewEntry(wrappedJ, next)
+		fmt.Printf("Entry %s superseded by %s\n", e.ID, next.ID)
+		return
+	}
+
+	if err := e.Update(text); err != nil {
+		fmt.Printf("Error updating entry: %v\n", err)
+		os.Exit(1)
+	}
+	e.Draft = false
+	if err := e.Save(); err != nil {
+		fmt.Printf("Error saving entry: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Entry %s updated\n", e.ID)
+}
+
+func handleEntryHistory(args []string) {
+	fs := flag.NewFlagSet("journal history", flag.ExitOnError)
+	reveal := fs.Bool("reveal", false, "Show the previews of a sensitive entry, even in discreet mode")
+	if args = parseArgs(fs, args[1:]); len(args) != 2 {
+		fmt.Println("Usage: jot journal history <journal-name> <entry-id> [--reveal]")
+		os.Exit(1)
+	}
+	e := loadJournalEntry(args[0], args[1])
+
+	if len(e.Revisions) == 0 {
+		fmt.Printf("Entry %s has no previous revisions\n", e.ID)
+		return
+	}
+
+	fmt.Printf("Revisions of entry %s:\n", e.ID)
+	for i := len(e.Revisions) - 1; i >= 0; i-- {
+		r := &e.Revisions[i]
+		text, err := e.DecryptRevision(r)
+		if err != nil {
+			fmt.Printf("Error decrypting revision %d: %v\n", r.Number, err)
+			os.Exit(1)
+		}
+		line := preview(text, 50)
+		if ui.Masked(e.Sensitive) && !*reveal {
+			line = ui.HiddenPreview()
+		}
+		fmt.Printf("  %3d  replaced %s  %s\n", r.Number, ui.FormatTime(r.Replaced), line)
+	}
+}
+
+func handleEntryRevert(args []string) {
+	if len(args) != 4 {
+		fmt.Println("Usage: jot journal revert <journal-name> <entry-id> <revision>")
+		os.Exit(1)
+	}
+	e := loadJournalEntry(args[1], args[2])
+
+	number, err := strconv.Atoi(args[3])
+	if err != nil {
+		fmt.Printf("Invalid revision '%s'\n", args[3])
+		os.Exit(1)
+	}
+
+	if err := e.Revert(number); err != nil {
+		fmt.Printf("Error reverting entry: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.Save(); err != nil {
+		fmt.Printf("Error saving entry: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Entry %s reverted to revision %d\n", e.ID, number)
+}
+
+func handleEntrySensitive(args []string) {
+	fs := flag.NewFlagSet("journal sensitive", flag.ExitOnError)
+	off := fs.Bool("off", false, "Show the entry in list views again")
+	if args = parseArgs(fs, args[1:]); len(args) != 2 {
+		fmt.Println("Usage: jot journal sensitive <journal-name> <entry-id> [--off]")
+		os.Exit(1)
+	}
+	e := loadJournalEntry(args[0], args[1])
+
+	if e.Sensitive == !*off {
+		fmt.Println("No changes made")
+		return
+	}
+	e.Sensitive = !*off
+	if err := e.Save(); err != nil {
+		fmt.Printf("Error saving entry: %v\n", err)
+		os.Exit(1)
+	}
+
+	if e.Sensitive {
+		fmt.Printf("Entry %s is now hidden in list views until revealed\n", e.ID)
+	} else {
+		fmt.Printf("Entry %s is no longer marked sensitive\n", e.ID)
+	}
+}
+
+// preview returns the first line of text, shortened to at most n runes
+func preview(text string, n int) string {
+	line := strings.SplitN(strings.TrimSpace(text), "\n", 2)[0]
+	runes := []rune(line)
+	if len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return line
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/importer"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleImportCommand(journalName string, args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot import <markdown|simplenote|standard-notes|encrypted-bundle> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "markdown":
+		handleImportMarkdown(journalName, args[1:])
+	case "simplenote":
+		handleImportApp("simplenote", importer.Simplenote, journalName, args[1:])
+	case "standard-notes":
+		handleImportApp("standard-notes", importer.StandardNotes, journalName, args[1:])
+	case "encrypted-bundle":
+		handleImportBundle(args[1:])
+	default:
+		fmt.Printf("Unknown import format: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleImportMarkdown(journalName string, args []string) {
+	fs := flag.NewFlagSet("import markdown", flag.ExitOnError)
+	journalFlag := fs.String("journal", journalName, "Journal for notes that do not name one")
+	folders := fs.String("folders", importer.FoldersNone, "What subfolders mean: none, journals or tags")
+	dryRun := fs.Bool("dry-run", false, "Show what would be imported without saving anything")
+	allow := fs.Bool("allow-duplicates", allowDuplicates, "Import notes even when an entry with the same text and date exists")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot import markdown <dir> [--journal name] [--folders none|journals|tags] [--dry-run] [--allow-duplicates]")
+		os.Exit(1)
+	}
+	valid := false
+	for _, mode := range importer.FolderModes {
+		valid = valid || mode == *folders
+	}
+	if !valid {
+		fmt.Printf("Invalid --folders %q; use %s\n", *folders, strings.Join(importer.FolderModes, ", "))
+		os.Exit(1)
+	}
+
+	notes, err := importer.Markdown(rest[0], *folders)
+	if err != nil {
+		fmt.Printf("Error reading notes: %v\n", err)
+		os.Exit(1)
+	}
+	importNotes(notes, *journalFlag, *dryRun, *allow)
+}
+
+// handleImportApp imports the export of another note-taking app, read by
+// read, into one journal
+func handleImportApp(format string, read func(path string) ([]importer.Note, error), journalName string, args []string) {
+	fs := flag.NewFlagSet("import "+format, flag.ExitOnError)
+	journalFlag := fs.String("journal", journalName, "Journal to import into (default journal if omitted)")
+	dryRun := fs.Bool("dry-run", false, "Show what would be imported without saving anything")
+	allow := fs.Bool("allow-duplicates", allowDuplicates, "Import notes even when an entry with the same text and date exists")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Printf("Usage: jot import %s <export> [--journal name] [--dry-run] [--allow-duplicates]\n", format)
+		os.Exit(1)
+	}
+
+	notes, err := read(rest[0])
+	if err != nil {
+		fmt.Printf("Error reading notes: %v\n", err)
+		os.Exit(1)
+	}
+	importNotes(notes, *journalFlag, *dryRun, *allow)
+}
+
+// importNotes saves notes as entries, creating the journals they name.
+// Notes naming no journal go to journalName or the default journal. Notes
+// already imported, or otherwise duplicating an entry, are skipped unless
+// allowDuplicates is set.
+func importNotes(notes []importer.Note, journalName string, dryRun, allowDuplicates bool) {
+	if len(notes) == 0 {
+		fmt.Println("No notes found to import")
+		return
+	}
+	if journalName == "" {
+		journalName = defaultJournal()
+	}
+
+	// Work out every target journal before anything is written
+	targets := make([]string, len(notes))
+	var names []string
+	seen := make(map[string]bool)
+	for i, n := range notes {
+		name := journalName
+		if n.Journal != "" {
+			name = collection.NormalizeName(n.Journal)
+		}
+		if name == "" {
+			fmt.Println("No default journal set. Please specify a journal with --journal or set a default journal.")
+			os.Exit(1)
+		}
+		if j, exists := journalCollection.Journals[name]; exists && j.AppendOnly {
+			fmt.Printf("Journal '%s' is append-only; imported entries cannot be dated in the past\n", name)
+			os.Exit(1)
+		}
+		targets[i] = name
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	journals := make(map[string]*journal.Journal)
+	for _, name := range names {
+		if j, exists := journalCollection.Journals[name]; exists {
+			journals[name] = journal.FromType(j)
+			continue
+		}
+		if dryRun {
+			continue
+		}
+		j, err := journal.New(name)
+		if err != nil {
+			fmt.Printf("Error creating journal: %v\n", err)
+			os.Exit(1)
+		}
+		if err := journalCollection.AddJournal(j.AsType()); err != nil {
+			fmt.Printf("Error adding journal: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created journal: %s\n", name)
+		journals[name] = j
+	}
+
+	imported := make(map[string]int)
+	skipped := 0
+	for i, n := range notes {
+		j := journals[targets[i]]
+		status := ""
+		if j != nil && !allowDuplicates {
+			dup, err := j.FindDuplicate(n.Body, n.Created)
+			if err != nil {
+				fmt.Printf("Error checking for duplicates: %v\n", err)
+				os.Exit(1)
+			}
+			if dup != nil {
+				skipped++
+				status = fmt.Sprintf(", duplicate of %s", dup.ID)
+				if !dryRun {
+					fmt.Printf("Skipped %s: same text as entry %s\n", n.Source, dup.ID)
+					continue
+				}
+			}
+		}
+		if dryRun {
+			fmt.Printf("%s  %-12s %s%s  (%s%s)\n", ui.FormatTime(n.Created), targets[i],
+				ui.Summary(n.Title, n.Body, 50), ui.FormatTags(entry.NormalizeTags(n.Tags)), n.Source, status)
+			if status == "" {
+				imported[targets[i]]++
+			}
+			continue
+		}
+
+		e, err := j.NewEntry(n.Body)
+		if err != nil {
+			fmt.Printf("Error importing %s: %v\n", n.Source, err)
+			os.Exit(1)
+		}
+		if len(n.Tags) > 0 {
+			if err := e.SetTags(n.Tags); err != nil {
+				fmt.Printf("Error tagging %s: %v\n", n.Source, err)
+				os.Exit(1)
+			}
+		}
+		if n.Title != "" {
+			if err := e.SetTitle(n.Title); err != nil {
+				fmt.Printf("Error setting title of %s: %v\n", n.Source, err)
+				os.Exit(1)
+			}
+		}
+		if err := restoreNoteMetadata(e, n); err != nil {
+			fmt.Printf("Error importing %s: %v\n", n.Source, err)
+			os.Exit(1)
+		}
+		e.Created = n.Created
+		if err := j.SaveEntry(e); err != nil {
+			fmt.Printf("Error importing %s: %v\n", n.Source, err)
+			os.Exit(1)
+		}
+		imported[targets[i]]++
+	}
+
+	if dryRun {
+		fmt.Println()
+	}
+	for _, name := range names {
+		if imported[name] == 0 {
+			continue
+		}
+		switch {
+		case !dryRun:
+			fmt.Printf("Imported %s into '%s'\n", plural(imported[name], "entry"), name)
+			warnQuota(journals[name].Journal)
+		case journals[name] == nil:
+			fmt.Printf("Would import %s into '%s' (new journal)\n", plural(imported[name], "entry"), name)
+		default:
+			fmt.Printf("Would import %s into '%s'\n", plural(imported[name], "entry"), name)
+		}
+	}
+	if skipped > 0 {
+		verb := "Skipped"
+		if dryRun {
+			verb = "Would skip"
+		}
+		fmt.Printf("%s %s already in jot; use --allow-duplicates to import them anyway\n", verb, plural(skipped, "duplicate"))
+	}
+}
+
+// restoreNoteMetadata sets what only notes restored from a bundle carry on
+// the entry made from n
+func restoreNoteMetadata(e *entry.Entry, n importer.Note) error {
+	e.Sensitive = n.Sensitive
+	if n.State != "" {
+		if err := e.SetState(n.State); err != nil {
+			return err
+		}
+	}
+	if n.Checkin != nil {
+		if err := e.SetCheckin(n.Checkin); err != nil {
+			return err
+		}
+	}
+	if n.Bookmark != nil {
+		if err := e.SetBookmark(n.Bookmark); err != nil {
+			return err
+		}
+	}
+	if n.Rollup != nil {
+		if err := e.SetRollup(n.Rollup); err != nil {
+			return err
+		}
+	}
+	if n.Fields != nil {
+		return e.SetFields(n.Fields)
+	}
+	return nil
+}
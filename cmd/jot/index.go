@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleIndexCommand(args []string) {
+	const usage = "Usage: jot index rebuild [--background | --throttle]\n       jot index status"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "rebuild":
+		fs := flag.NewFlagSet("index rebuild", flag.ExitOnError)
+		background := fs.Bool("background", false, "Build the index in a process of its own and return at once")
+		throttle := fs.Bool("throttle", false, "Build the index in batches with a pause after each, showing progress")
+		if rest := parseArgs(fs, args[1:]); len(rest) != 0 || (*background && *throttle) {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+
+		switch {
+		case *background:
+			if build := runningIndexBuild(); build != nil {
+				fmt.Printf("The index is already being built: %d of %s done\n", build.Done, plural(build.Total, "entry"))
+				return
+			}
+			if err := startIndexBuild(); err != nil {
+				fmt.Printf("Error starting index build: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Building the index in the background; follow it with jot index status")
+		case *throttle:
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			n, err := throttledIndexBuild(ctx, ui.IsTerminal())
+			if err != nil {
+				fmt.Printf("Error rebuilding index: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Rebuilt the tag and date index from %d entries\n", n)
+		default:
+			n, err := entry.RebuildIndex()
+			if err != nil {
+				fmt.Printf("Error rebuilding index: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Rebuilt the tag and date index from %d entries\n", n)
+		}
+
+	case "status":
+		if len(args) != 1 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		printIndexStatus()
+
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
+
+// throttledIndexBuild builds the index in batches of index.batch_size
+// entries, pausing index.pause_ms after each, overwriting a progress line
+// on the terminal when show is set
+func throttledIndexBuild(ctx context.Context, show bool) (int, error) {
+	cfg, err := config.Current()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load config: %w", err)
+	}
+	pause := time.Duration(cfg.Int("index.pause_ms")) * time.Millisecond
+	n, err := entry.BuildIndex(ctx, cfg.Int("index.batch_size"), pause, func(b *entry.IndexBuild) {
+		if show {
+			fmt.Printf("\rIndexed %d of %s", b.Done, plural(b.Total, "entry"))
+		}
+	})
+	if show {
+		fmt.Println()
+	}
+	return n, err
+}
+
+// startIndexBuild runs jot index rebuild --throttle in a process of its own
+// and returns without waiting for it
+func startIndexBuild() error {
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate jot binary: %w", err)
+	}
+	cmd := exec.Command(binary, "index", "rebuild", "--throttle")
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// runningIndexBuild returns the progress of an index build under way in
+// any jot process, or nil if there is none
+func runningIndexBuild() *entry.IndexBuild {
+	build, err := entry.ReadIndexBuild()
+	if err != nil || build == nil || !build.Running() {
+		return nil
+	}
+	return build
+}
+
+func printIndexStatus() {
+	switch {
+	case journalCollection.Index != nil:
+		fmt.Printf("The index is current: %s\n", plural(journalCollection.IndexedEntries(), "entry"))
+	case journalCollection.IndexStale():
+		fmt.Println("The index does not match the journals and will be rebuilt when next needed")
+	default:
+		fmt.Println("There is no index yet")
+	}
+
+	build, err := entry.ReadIndexBuild()
+	if err != nil {
+		fmt.Printf("Error reading index build progress: %v\n", err)
+		os.Exit(1)
+	}
+	if build == nil {
+		return
+	}
+	started := build.Started.Local().Format("2006-01-02 15:04:05")
+	switch {
+	case build.Running():
+		percent := 100
+		if build.Total > 0 {
+			percent = 100 * build.Done / build.Total
+		}
+		fmt.Printf("Building in the background since %s (process %d): %d of %s done (%d%%)",
+			started, build.PID, build.Done, plural(build.Total, "entry"), percent)
+		if elapsed := time.Since(build.Started); build.Done > 0 && build.Done < build.Total {
+			left := elapsed * time.Duration(build.Total-build.Done) / time.Duration(build.Done)
+			fmt.Printf(", about %s left", left.Round(time.Second))
+		}
+		fmt.Println()
+	case build.Error != "":
+		fmt.Printf("The build started %s stopped after %d of %s: %s\n", started, build.Done, plural(build.Total, "entry"), build.Error)
+	case build.Finished.IsZero():
+		fmt.Printf("The build started %s stopped responding after %d of %s; run jot index rebuild --background to start over\n",
+			started, build.Done, plural(build.Total, "entry"))
+	default:
+		fmt.Printf("Last built %s from %s in %s\n", started, plural(build.Total, "entry"),
+			build.Finished.Sub(build.Started).Round(time.Second))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/atrest"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/rotate"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleKeyCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot key <rotate|status|enroll-fido2|offline|online>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "rotate":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot key rotate")
+			os.Exit(1)
+		}
+		handleKeyRotate()
+
+	case "enroll-fido2":
+		handleEnrollFIDO2(args)
+
+	case "offline":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot key offline <dir>")
+			os.Exit(1)
+		}
+		n, err := crypto.MoveKeysOffline(args[1])
+		if err != nil {
+			fmt.Printf("Error moving private keys: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Moved %d private keys to %s\n", n, args[1])
+		fmt.Println("New entries can still be written; reading them needs `jot key online`")
+
+	case "online":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot key online <dir>")
+			os.Exit(1)
+		}
+		n, err := crypto.BringKeysOnline(args[1])
+		if err != nil {
+			fmt.Printf("Error restoring private keys: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored %d private keys from %s\n", n, args[1])
+
+	case "status":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot key status")
+			os.Exit(1)
+		}
+		if !crypto.HasPrivateKey() {
+			fmt.Println("The private key is offline; entries can be written but not read")
+		} else if crypto.FIDO2Enrolled() {
+			fmt.Println("Private keys are wrapped by a FIDO2 security key")
+		}
+		state, err := rotate.Status()
+		if err != nil {
+			fmt.Printf("Error reading rotation state: %v\n", err)
+			os.Exit(1)
+		}
+		if state == nil {
+			fmt.Println("No key rotation in progress")
+			return
+		}
+		fmt.Printf("Key rotation started %s is unfinished (%s phase, %d entries done)\n",
+			ui.FormatTime(state.StartedAt), state.Phase, len(state.Done))
+		fmt.Println("Run `jot key rotate` to resume it")
+
+	default:
+		fmt.Printf("Unknown key command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleEnrollFIDO2(args []string) {
+	fs := flag.NewFlagSet("key enroll-fido2", flag.ExitOnError)
+	device := fs.String("device", "", "Security key device (default crypto.fido2_device or the first one found)")
+	if rest := parseArgs(fs, args[1:]); len(rest) != 0 {
+		fmt.Println("Usage: jot key enroll-fido2 [--device path]")
+		os.Exit(1)
+	}
+
+	if err := crypto.EnrollFIDO2(*device); err != nil {
+		fmt.Printf("Error enrolling security key: %v\n", err)
+		os.Exit(1)
+	}
+	if err := atrest.WrapKeys(); err != nil {
+		fmt.Printf("Error wrapping the master key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Security key enrolled; reading entries now requires it")
+	fmt.Println("If you lose it, your entries cannot be decrypted. Consider adding an")
+	fmt.Println("offline recovery key with `jot recipients add` and `jot key rotate`")
+}
+
+func handleKeyRotate() {
+	interactive := ui.IsTerminal()
+	result, err := rotate.Run(func(done, total int) {
+		if interactive {
+			fmt.Printf("\rRe-encrypting entries: %d/%d", done, total)
+		}
+	})
+	if interactive {
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("Error rotating key: %v\n", err)
+		if !errors.Is(err, crypto.ErrPrivateKeyOffline) {
+			fmt.Println("Progress has been saved; run `jot key rotate` again to resume")
+		}
+		os.Exit(1)
+	}
+
+	if result.Resumed {
+		fmt.Println("Resumed unfinished key rotation")
+	}
+	fmt.Printf("Rotated encryption key; re-encrypted %d entries\n", result.Reencrypted)
+	if len(result.Skipped) > 0 {
+		fmt.Printf("%d sealed entries in append-only journals keep the retired key\n", len(result.Skipped))
+	}
+}
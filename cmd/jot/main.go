@@ -1,23 +1,55 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 
+	"github.com/veritome/jot/internal/atrest"
 	"github.com/veritome/jot/internal/collection"
-	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/enrich"
 	"github.com/veritome/jot/internal/entry"
 	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/mood"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
 	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/internal/when"
 )
 
 var journalCollection *collection.Collection
 
+// entryTags holds the --tag flags applied to entries created by this run
+var entryTags stringList
+
+// entryTitle holds the --title flag applied to entries created by this run
+var entryTitle string
+
+// entrySensitive holds the --sensitive flag hiding entries created by this
+// run from list views
+var entrySensitive bool
+
+// entryTask makes the entry created by this run a task, as jot todo does
+var entryTask bool
+
+// entryMood, entryEnergy, entryWeather and entryLocation hold the fields
+// given to entries created by this run
+var entryMood, entryEnergy, entryWeather, entryLocation string
+
+// allowDuplicates holds the --allow-duplicates flag, saving entries even
+// when their journal already has one with the same text written around the
+// same time
+var allowDuplicates bool
+
+// entryDate and entryTime hold the --date and --time flags backdating
+// entries created by this run
+var entryDate, entryTime string
+
 var collectionCommands = map[string]bool{
 	"collection": true,
 	"c":          true,
@@ -28,7 +60,25 @@ var journalCommands = map[string]bool{
 	"j":       true,
 }
 
-func init() {
+// commands lists the other commands, so that --remote can tell them from
+// the text of a new entry
+var commands = map[string]bool{
+	"admin": true, "archive": true, "assert": true, "backup": true,
+	"bookmark": true, "checkin": true, "config": true, "countersign": true,
+	"daemon": true, "device": true, "digest": true, "doctor": true,
+	"entry": true, "export": true, "find": true, "gc": true, "goal": true,
+	"heatmap": true, "import": true, "index": true, "key": true, "new": true,
+	"next": true, "nuke": true, "onthisday": true, "prompt": true,
+	"random": true, "read": true, "recipients": true, "remind": true,
+	"restore": true, "rollup": true, "search": true, "sed": true,
+	"self-update": true, "serve": true, "stats": true, "sync": true,
+	"tags": true, "template": true, "timestamp": true, "todo": true,
+	"token": true, "triage": true, "undo": true, "version": true,
+	"watch": true, "write": true,
+}
+
+// loadCollection loads the local collection into journalCollection
+func loadCollection() {
 	var err error
 	journalCollection, err = collection.Load()
 	if err != nil {
@@ -38,10 +88,32 @@ func init() {
 }
 
 func main() {
+	// Everything written to the data directory is encrypted at rest
+	storage.Use(atrest.Wrap(storage.Disk{}))
+
 	journalFlag := flag.String("journal", "", "Specify journal name for the entry")
+	remoteFlag := flag.String("remote", "", "URL of a jot server to use instead of local storage")
+	remoteYes := flag.Bool("yes", false, "Change entries on the --remote server without asking when confirm.remote is set")
+	flag.Var(&entryTags, "tag", "Tag the new entry (repeatable)")
+	flag.StringVar(&entryTitle, "title", "", "Title of the new entry")
+	flag.BoolVar(&entrySensitive, "sensitive", false, "Hide the new entry's preview in list views until revealed")
+	flag.StringVar(&entryMood, "mood", "", "Mood of the new entry: 1 to 10, or an emoji")
+	flag.StringVar(&entryEnergy, "energy", "", "Energy level of the new entry: 1 to 10")
+	flag.StringVar(&entryWeather, "weather", "", "Weather of the new entry")
+	flag.StringVar(&entryLocation, "location", "", "Where the new entry was written")
+	flag.StringVar(&entryDate, "date", "", "Date the new entry is about: YYYY-MM-DD, yesterday, last friday, ...")
+	flag.StringVar(&entryTime, "time", "", "Time of day the new entry is about: HH:MM or 3pm")
+	flag.BoolVar(&allowDuplicates, "allow-duplicates", false, "Save new entries even when they duplicate an existing one")
+	discreet := flag.Bool("discreet", false, "Show only entry IDs and dates, for presenting or pairing")
 	flag.Parse()
+	if *discreet {
+		ui.SetDiscreet(true)
+	}
 
 	args := flag.Args()
+	if len(args) == 0 {
+		args = defaultCommand()
+	}
 	if len(args) == 0 {
 		const usage = `Usage: jot [OPTIONS] [COMMAND] [ARGS...]
     __
@@ -56,24 +128,194 @@ A simple, secure journaling tool.
 
 Options:
   -j, --journal <name>    Specify journal name for the entry
+  --remote <url>          Use a remote jot server (token from JOT_TOKEN)
+  --tag <tag>             Tag the new entry (repeatable)
+  --title <title>         Give the new entry a title
+  --sensitive             Hide the new entry's preview in list views until revealed
+  --mood <1-10|emoji>     Record the new entry's mood
+  --energy <1-10>         Record the new entry's energy level
+  --weather <text>        Record the weather with the new entry
+  --location <text>       Record where the new entry was written
+  --discreet              Show only entry IDs and dates, for presenting or pairing
+  --date <date>           Backdate the new entry (YYYY-MM-DD, yesterday, last friday, 3 days ago)
+  --time <time>           Set the new entry's time of day (HH:MM, 3pm)
+  --allow-duplicates      Save entries even when their journal has one with the same text from around the same time
 
 Commands:
   <entry text>            Create a new entry in the default journal
-  collection, c           List all journals
+  new                     Write a new entry in the editor
+  admin remap-ids [--format spec] [--dry-run]  Move entries from legacy IDs such as 0042 to the entry.id_format scheme
+  archive <command>       Move the entries of a past year into one compressed, encrypted file
+  backup <command>        Take, schedule, list and extract encrypted snapshots of the data directory
+  bookmark <url> [--no-fetch]  Save a link with the page's title and description as an entry
+  checkin [template]      Answer a check-in's questions (hours slept, exercise, ...) as an entry
+  collection, c [--sort name|entries|recent]  List all journals with their entry counts and dates
+  config <command>        View and change settings
+  countersign <command>   Have a witness sign entries as proof they saw them
+  device <command>        Give another machine a key of its own to read and write your journals
+  daemon [--addr host:port] [--no-api]  Unlock the keys once, keep the index current and serve the API
+  doctor [--fix] [--orphans adopt|recreate] [--check-isolation]  Check the data directory for problems, or for access by other users
+  entry set <id> [--created date] [--journal name] [--tag +t|-t] [--title t]  Change an entry's date, journal, tags or title
+  export [journal] [--format text|html|logseq|json|csv|encrypted-bundle] [--anonymize]  Export entries as text, a static HTML site, Logseq daily notes, JSON, CSV or a passphrase-encrypted bundle
+  find [--journal name] [--raw]  Fuzzy-find entries interactively, with a preview
+  gc [--dry-run]          Remove stored bodies no entry refers to anymore
+  goal <command>          Set writing goals and track streaks
+  assert [--wrote-today] [--wrote-within age] [--goal-met] [--snapshot-within age]  Exit non-zero unless journaling is on track, for cron jobs
+  heatmap [journal] [--year YYYY]  Show words written per day as a grid, like a contribution graph
+  import markdown <dir> [--folders none|journals|tags] [--dry-run]  Import a folder of Markdown notes, such as an Obsidian vault
+  import simplenote|standard-notes <export> [--journal name] [--dry-run]  Import notes from a Simplenote export or Standard Notes backup
+  import encrypted-bundle <file> [--journal name] [--from date] [--to date] [--dry-run]  Restore entries from an encrypted bundle
+  digest [--post target] [--notify]  Summarise recent journaling, optionally posting to a webhook or as a notification
+  index rebuild [--background | --throttle]  Regenerate the tag and date search index
+  index status            Show whether the index is current and how far a background build has got
   journal, j <command>    Manage journals
-  nuke                    Delete all data and reset JOT
+  key <command>           Manage the encryption key
+  next [journal] [--peek | --archive] [--list]  Show the oldest unread entry and mark it read
+  onthisday [--date MM-DD]  Show entries written on this day in past years
+  prompt [journal]        Write an entry answering today's writing prompt, or the questions of a journal
+  prompt <command>        Give journals questions of their own and reminders of them
+  random [journal] [--before 1y] [--older]  Show a random past entry; n shows another one
+  read [journal] [--on date | --month YYYY-MM] [--raw]  Read a journal's entries, or only those of one day or month
+  rollup [--week | --month] [--date date] [--list]  Review a week or month in an entry linking to its entries
+  recipients <command>    Manage extra public keys new entries are encrypted to
+  tags <command>          List, find and migrate entry tags
+  stats [--journal name] [--months N]  Show average mood and energy per month
+  timestamp <id>          Have a timestamping authority prove an entry existed, without revealing it
+  timestamp verify <id> [--ca file] [--export dir]  Check an entry's timestamps
+  template <command>      Write entries from templates, or have drafts created on a schedule
+  todo <text>             Add a task; todo list shows open tasks, todo done <id> completes one
+  triage [journal]        Step through entries, moving, tagging, starring or deleting each with one key
+  undo [--list]           Reverse the last entry creation, deletion or change, journal deletion or default change
+  remind <command>        Manage the daily writing reminder
+  restore --from-keys <dir> --from-data <dir>  Rebuild the data directory from separate key and data backups
+  search [text] [--title t] [--tag t] [--from date] [--to date] [--when period] [--on date] [--mood 6-10]  Find entries
+  sed <old/new> [--search text] [--dry-run] [--yes]  Replace text across entries, confirming each change
+  self-update [--check]   Update jot to the latest release
+  sync [pull] [--backend s3] [--dry-run]  Push the encrypted data to a bucket, or pull it from there
+  serve [--addr host:port] [--grpc-addr host:port]  Serve the HTTP (and gRPC) API for other tools (default 127.0.0.1:7777)
+  token <new|list|revoke>  Manage API tokens limited to reading or adding to one journal
+  version                 Show the jot version
+  watch [--dir path]      Turn text files dropped into a folder into entries
+  write [--goal 750]      Distraction-free writing session with a word goal
+  nuke [--journal name | --keep-keys] [--dry-run] [--yes]  Delete all data and reset JOT, or one journal for good
 
 Journal Commands:
-  new <name>             Create a new journal
-  delete <name>          Delete an existing journal
+  new <name> [--append-only]  Create a new journal
+  new <name> --shared <folder> --as <member>  Create a journal shared through a synced folder
+  join <folder> <name>   Add a shared journal someone made you a member of
+  members <name> [--accept]  List the members of a shared journal, confirming changes
+  add-member <name> <member> <public-key> <signing-key>  Let a member read and write new shared entries
+  remove-member <name> <member>  Stop encrypting new shared entries to a member
+  reshare <name>         Re-encrypt a shared journal's entries for its current members
+  delete <name> [--dry-run] [--yes]  Move a journal and its entries to the trash
+  restore <name>         Restore a journal from the trash
+  quota <name> [--entries N] [--mb N] [--clear]  Warn once a journal grows past soft limits
+  trash                  List deleted journals that can be restored
   default <name>         Set the default journal
-  read <name>            Display all entries in a journal
+  read <name> [--raw] [--mood r] [--energy r] [--weather t] [--location t]  Display a journal's entries, optionally only those with these fields
   describe <name>        Show journal metadata
-  delete-entry <name> <id>  Delete an entry from a journal
+  export <name> [--armor]  Export entries as text or GPG-armored messages (see jot export)
+  merge <source> <dest>  Move all entries into another journal and delete the source
+  rename <name> <new-name>  Rename a journal
+  delete-entry <name> [<id> [--dry-run] [--yes]]  Delete an entry from a journal
+  edit <name> <id>       Edit an entry, keeping the previous version
+  history <name> <id> [--reveal]  List previous versions of an entry
+  revert <name> <id> <rev>  Restore a previous version of an entry
+  sensitive <name> <id> [--off]  Hide an entry's preview in list views until revealed
+  mark <name> <id> <unread|read|archived>  Set an entry's state for jot next
+  verify <name>          Verify the hash chain of an append-only journal
+
+Config Commands:
+  list                   Show all settings and their values
+  get <key>              Show a setting
+  set <key> <value>      Change a setting
+  unset <key>            Restore a setting's default
+  path                   Show the config file location
+  export [--output file] Write settings and writing prompts to a file
+  import <file>          Apply settings and prompts exported on another machine
+
+Key Commands:
+  rotate                 Re-encrypt all entries with a new key (resumes if interrupted)
+  status                 Show whether a key rotation is unfinished
+  enroll-fido2 [--device path]  Require a FIDO2 security key to unwrap private keys
+  offline <dir>          Move the private keys to <dir>; entries can still be written
+  online <dir>           Copy the private keys back from <dir> to read entries
+
+Archive Commands:
+  year <YYYY>            Archive the entries written in a past year; reading them again thaws it
+  thaw <YYYY>            Bring the entries of an archived year back
+  list                   Show archived years
+
+Device Commands:
+  add <name>             Generate this machine's key and show the request to approve elsewhere
+  approve <name> <public-key> [--as name]  Compare a new device's code and let it read new entries
+  revoke <name> [--yes]  Stop encrypting to a device and re-encrypt what it could read
+  list                   Show the approved devices
+
+Recipients Commands:
+  add <name> <public-key>  Also encrypt new entries to this key
+  remove <name>          Stop encrypting new entries to this key
+  list                   Show your public key and all recipients
+
+Tags Commands:
+  list [--journal name]  Show every tag and how many entries carry it
+  find <tag>             List entries with a tag
+  migrate                Rewrite stored tags in the current metadata.mode
+
+Checkin Commands:
+  <template>             Answer the template's questions (default: daily)
+  templates              List check-in templates and their questions
+  stats [template] [--days N] [--journal name]  Chart answers over time
+
+Countersign Commands:
+  <id> --key <file.pub> [--output file]  Write a request for the witness to countersign an entry
+  <id> --key <file.pub> --signature <file>  Check the witness's countersignature against their key and store it
+  sign <request> --key <file> [--yes]  As the witness, show the entry and sign it with your key
+  keygen <file> [--name witness]  Create a witness key pair: <file> and <file>.pub
+  verify <id> [--key file.pub] [--statement]  Check an entry's countersignatures
+  verify-log [--signature file]  Check that no countersignature was removed
+
+Template Commands:
+  list                   Show templates and when drafts are created from them
+  edit <name>            Create or change a template
+  delete <name>          Delete a template
+  use <name> [--journal name]  Write an entry starting from a template
+  schedule <name> --every <day|weekday|month|monday...> [--at HH:MM] [--journal name]  Add a draft from the template on a schedule
+  unschedule <name> [--journal name]  Stop adding drafts from the template
+  run                    Add the drafts that are due (jot daemon does this by itself)
+  drafts [journal]       List drafts not filled in yet
+
+Goal Commands:
+  set [--journal name] --daily N|--weekly N  Aim to write N entries a day or week
+  clear [--journal name]  Remove a journal's goal
+  status [--journal name] [--short]  Show progress, streaks and missed days; --short for shell prompts
+
+Prompt Commands:
+  edit <journal>         Set the questions a journal asks, one per line
+  schedule <journal> --every <day|weekday|month|monday...> [--at HH:MM]  Be reminded of the questions on a schedule
+  unschedule <journal>   Stop reminders of a journal's questions
+  remove <journal>       Remove a journal's questions
+  list                   Show journals with questions and when they are next due
+  run                    Remind of the questions that are due (jot daemon does this by itself)
+
+Todo Commands:
+  <text> [--tag tag]     Add a task to the journal given with --journal, or the default one
+  list [--journal name] [--done]  List open tasks across journals, or completed ones
+  done <id>              Mark a task done, recording when
+  reopen <id>            Mark a completed task open again
+
+Remind Commands:
+  install [--at HH:MM]   Schedule a daily reminder (cron, systemd or launchd)
+  remove                 Remove the scheduled reminder
+  run                    Show the reminder (invoked by the scheduler)
 
 Examples:
   jot "Had a great day today"                    Create entry in default journal
   jot -j work "Important meeting notes"          Create entry in "work" journal
+  jot --tag idea "Try a standing desk"           Create a tagged entry
+  jot --title "Trip planning" "Book the ferry"   Create an entry with a title
+  jot --mood 7 --weather rain "Quiet day"        Create an entry with its mood and weather
+  jot --date yesterday "Forgot to write this"    Create an entry dated yesterday
   jot journal new work                           Create a new journal called "work"
   jot journal read work                          Read all entries in "work" journal
   jot journal delete-entry work 0001             Delete entry 0001 from "work" journal
@@ -83,26 +325,320 @@ For more information, visit: https://github.com/veritome/jot`
 		os.Exit(1)
 	}
 
+	applyConfig()
+
+	// Config commands must work even when the data directory is unusable
+	if args[0] == "config" {
+		handleConfigCommand(args[1:])
+		return
+	}
+
+	// Restoring must not create keys in the data directory it replaces
+	if args[0] == "restore" {
+		handleRestoreCommand(args[1:])
+		return
+	}
+
+	// Snapshots are taken and extracted as the files are, without loading them
+	if args[0] == "backup" {
+		handleBackupCommand(args[1:])
+		return
+	}
+
+	// Pulling onto a fresh machine must not create a collection or keys
+	if args[0] == "sync" {
+		handleSyncCommand(args[1:])
+		return
+	}
+
+	// Witnesses sign on machines of their own, which may have no journals
+	if witnessCommand(args) {
+		handleCountersignCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Remote mode never touches local keys or data
+	if *remoteFlag != "" {
+		handleRemote(*remoteFlag, *journalFlag, args, *remoteYes)
+		return
+	}
+
+	// Doctor seals files of earlier versions, which the collection may be
+	// one of, before it can be loaded
+	if args[0] == "doctor" {
+		handleDoctorCommand(args[1:])
+		return
+	}
+	loadCollection()
+
 	// Handle nuke command
 	if args[0] == "nuke" {
-		handleNukeCommand()
+		handleNukeCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle onthisday command
+	if args[0] == "onthisday" {
+		handleOnThisDayCommand(args[1:])
+		return
+	}
+
+	// Handle random command
+	if args[0] == "random" {
+		handleRandomCommand(args[1:])
+		return
+	}
+
+	// Handle prompt command
+	if args[0] == "prompt" {
+		handlePromptCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle checkin command
+	if args[0] == "checkin" {
+		handleCheckinCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle rollup command
+	if args[0] == "rollup" {
+		handleRollupCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle read command
+	if args[0] == "read" {
+		handleReadCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle next command
+	if args[0] == "next" {
+		handleNextCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle template command
+	if args[0] == "template" {
+		handleTemplateCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle triage command
+	if args[0] == "triage" {
+		handleTriageCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle undo command
+	if args[0] == "undo" {
+		handleUndoCommand(args[1:])
+		return
+	}
+
+	// Handle todo command
+	if args[0] == "todo" {
+		handleTodoCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle bookmark command
+	if args[0] == "bookmark" {
+		handleBookmarkCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle archive command
+	if args[0] == "archive" {
+		handleArchiveCommand(args[1:])
+		return
+	}
+
+	// Handle gc command
+	if args[0] == "gc" {
+		handleGCCommand(args[1:])
+		return
+	}
+
+	// Handle digest command
+	if args[0] == "digest" {
+		handleDigestCommand(args[1:])
+		return
+	}
+
+	// Handle export command; jot journal export is the same
+	if args[0] == "export" {
+		handleExportJournal(args)
+		return
+	}
+
+	// Handle import command
+	if args[0] == "import" {
+		handleImportCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle countersign command
+	if args[0] == "countersign" {
+		handleCountersignCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle timestamp command
+	if args[0] == "timestamp" {
+		handleTimestampCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle goal command
+	if args[0] == "goal" {
+		handleGoalCommand(args[1:])
+		return
+	}
+
+	// Handle assert command
+	if args[0] == "assert" {
+		handleAssertCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle key command
+	if args[0] == "key" {
+		handleKeyCommand(args[1:])
+		return
+	}
+
+	// Handle device command
+	if args[0] == "device" {
+		handleDeviceCommand(args[1:])
+		return
+	}
+
+	// Handle recipients command
+	if args[0] == "recipients" {
+		handleRecipientsCommand(args[1:])
+		return
+	}
+
+	// Handle search command
+	if args[0] == "search" {
+		handleSearchCommand(args[1:])
+		return
+	}
+
+	// Handle entry command
+	if args[0] == "entry" {
+		handleEntryCommand(args[1:])
+		return
+	}
+
+	// Handle sed command
+	if args[0] == "sed" {
+		handleSedCommand(args[1:])
+		return
+	}
+
+	// Handle heatmap command
+	if args[0] == "heatmap" {
+		handleHeatmapCommand(args[1:])
+		return
+	}
+
+	// Handle stats command
+	if args[0] == "stats" {
+		handleStatsCommand(args[1:])
+		return
+	}
+
+	// Handle find command
+	if args[0] == "find" {
+		handleFindCommand(args[1:])
+		return
+	}
+
+	// Handle index command
+	if args[0] == "index" {
+		handleIndexCommand(args[1:])
+		return
+	}
+
+	// Handle tags command
+	if args[0] == "tags" {
+		handleTagsCommand(args[1:])
+		return
+	}
+
+	// Handle remind command
+	if args[0] == "remind" {
+		handleRemindCommand(args[1:])
+		return
+	}
+
+	// Handle version command
+	if args[0] == "version" {
+		fmt.Printf("jot %s\n", version)
+		return
+	}
+
+	// Handle serve command
+	if args[0] == "serve" {
+		handleServeCommand(args[1:])
+		return
+	}
+
+	// Handle token command
+	if args[0] == "token" {
+		handleTokenCommand(args[1:])
+		return
+	}
+
+	// Handle daemon command
+	if args[0] == "daemon" {
+		handleDaemonCommand(args[1:])
+		return
+	}
+
+	// Handle new command
+	if args[0] == "new" {
+		handleNewCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle write command
+	if args[0] == "write" {
+		handleWriteCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle watch command
+	if args[0] == "watch" {
+		handleWatchCommand(*journalFlag, args[1:])
+		return
+	}
+
+	// Handle admin command
+	if args[0] == "admin" {
+		handleAdminCommand(args[1:])
+		return
+	}
+
+	// Handle self-update command
+	if args[0] == "self-update" {
+		handleSelfUpdateCommand(args[1:])
 		return
 	}
 
 	// Handle collection command
 	if collectionCommands[args[0]] {
-		if len(args) != 1 {
-			fmt.Println("Usage: jot collection")
-			os.Exit(1)
-		}
-		handleCollectionCommand()
+		handleCollectionCommand(args[1:])
 		return
 	}
 
 	// Handle journal management commands
 	if journalCommands[args[0]] {
 		if len(args) < 2 {
-			fmt.Println("Usage: jot journal <new|delete|default|read|describe|delete-entry> [args]")
+			fmt.Println("Usage: jot journal <new|delete|restore|trash|default|read|describe|export|merge|delete-entry|edit|history|revert|sensitive|mark|verify> [args]")
 			os.Exit(1)
 		}
 		handleJournalCommand(args[1:])
@@ -112,25 +648,11 @@ For more information, visit: https://github.com/veritome/jot`
 	// At this point, all remaining args should be considered entry text
 	// No need to process args[0] differently as it's not a command
 	entryText := strings.Join(args, " ")
-	handleEntry(*journalFlag, entryText)
-}
-
-func handleCollectionCommand() {
-	journals := journalCollection.List()
-	if len(journals) == 0 {
-		fmt.Println("No journals found")
-		return
-	}
-
-	// Sort journals alphabetically
-	sort.Strings(journals)
-
-	fmt.Println("Available Journals:")
-	fmt.Println("------------------")
-	for _, name := range journals {
-		fmt.Printf("  %s\n", name)
+	journalName := *journalFlag
+	if journalName == "" {
+		journalName, entryText = routeEntry(entryText)
 	}
-	fmt.Println("\nNote: * indicates default journal")
+	handleEntry(journalName, entryText)
 }
 
 func handleJournalCommand(args []string) {
@@ -141,59 +663,132 @@ func handleJournalCommand(args []string) {
 
 	switch args[0] {
 	case "new":
-		if len(args) != 2 {
-			fmt.Println("Usage: jot journal new <name>")
+		fs := flag.NewFlagSet("journal new", flag.ExitOnError)
+		appendOnly := fs.Bool("append-only", false, "Never allow entries to be edited or deleted")
+		shared := fs.String("shared", "", "Keep the entries in this synced folder, readable by every member")
+		as := fs.String("as", "", "Your member name in a shared journal")
+		names := parseArgs(fs, args[1:])
+		if len(names) != 1 || (*shared != "" && *as == "") {
+			fmt.Println("Usage: jot journal new <name> [--append-only | --shared <folder> --as <member>]")
+			os.Exit(1)
+		}
+		if *shared != "" && *appendOnly {
+			fmt.Println("Shared journals cannot be append-only")
+			os.Exit(1)
+		}
+		name := collection.NormalizeName(names[0])
+		if name == "" {
+			fmt.Println("Journal name cannot be empty")
 			os.Exit(1)
 		}
-		j, err := journal.New(args[1])
+		if _, exists := journalCollection.Journals[name]; exists {
+			fmt.Printf("Error adding journal: journal '%s' already exists\n", name)
+			os.Exit(1)
+		}
+		var j *journal.Journal
+		var err error
+		if *shared != "" {
+			j, err = journal.NewShared(name, *shared, *as)
+		} else {
+			j, err = journal.New(name)
+		}
 		if err != nil {
 			fmt.Printf("Error creating journal: %v\n", err)
 			os.Exit(1)
 		}
+		j.AppendOnly = *appendOnly
+		similar := journalCollection.SimilarJournal(name)
 		if err := journalCollection.AddJournal(j.AsType()); err != nil {
 			fmt.Printf("Error adding journal: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Created journal: %s\n", args[1])
-
-	case "delete":
-		if len(args) != 2 {
-			fmt.Println("Usage: jot journal delete <name>")
-			os.Exit(1)
+		if j.AppendOnly {
+			fmt.Printf("Created append-only journal: %s\n", name)
+		} else if j.IsShared() {
+			fmt.Printf("Created shared journal '%s' in %s\n", name, j.SharedDir)
+			fmt.Println("Add members with `jot journal add-member`, then share the folder with them")
+		} else {
+			fmt.Printf("Created journal: %s\n", name)
 		}
-		if err := journalCollection.RemoveJournal(args[1]); err != nil {
-			fmt.Printf("Error deleting journal: %v\n", err)
-			os.Exit(1)
+		if similar != "" {
+			fmt.Printf("Warning: journal '%s' differs from '%s' only by case or whitespace\n", name, similar)
 		}
-		fmt.Printf("Deleted journal: %s\n", args[1])
+
+	case "join":
+		handleJoinJournal(args)
+
+	case "members":
+		handleJournalMembers(args)
+
+	case "add-member":
+		handleAddMember(args)
+
+	case "remove-member":
+		handleRemoveMember(args)
+
+	case "reshare":
+		handleReshare(args)
+
+	case "delete":
+		handleDeleteJournal(args)
+
+	case "quota":
+		handleQuotaCommand(args)
+
+	case "restore":
+		handleRestoreJournal(args)
+
+	case "trash":
+		handleListTrash(args)
 
 	case "default":
 		if len(args) != 2 {
 			fmt.Println("Usage: jot journal default <name>")
 			os.Exit(1)
 		}
+		previous := journalCollection.DefaultJournal
+		previousName := journalCollection.GetDefaultJournal()
 		if err := journalCollection.SetDefaultJournal(args[1]); err != nil {
 			fmt.Printf("Error setting default journal: %v\n", err)
 			os.Exit(1)
 		}
+		if journalCollection.DefaultJournal != previous {
+			recordOp(&oplog.Op{
+				Kind:            oplog.DefaultChange,
+				Journal:         args[1],
+				JournalID:       journalCollection.DefaultJournal,
+				PreviousDefault: previous,
+				PreviousName:    previousName,
+			})
+		}
 		fmt.Printf("Set default journal to: %s\n", args[1])
 
 	case "read":
-		if len(args) != 2 {
-			fmt.Println("Usage: jot journal read <name>")
+		fs := flag.NewFlagSet("journal read", flag.ExitOnError)
+		filterFlags := fieldFlags(fs)
+		raw := fs.Bool("raw", false, "Show entries as written instead of rendering Markdown")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 {
+			fmt.Println("Usage: jot journal read <name> [--raw] [--mood range] [--energy range] [--weather text] [--location text]")
 			os.Exit(1)
 		}
-		j, exists := journalCollection.Journals[args[1]]
+		ui.SetRaw(*raw)
+		j, exists := journalCollection.Journals[rest[0]]
 		if !exists {
-			fmt.Printf("Journal '%s' does not exist\n", args[1])
+			fmt.Printf("Journal '%s' does not exist\n", rest[0])
 			os.Exit(1)
 		}
 
 		wrappedJ := journal.FromType(j)
-		if err := ui.HandleShowEntries(wrappedJ); err != nil {
+		var keep func(*entry.Entry) (bool, error)
+		if filter := filterFlags(); !filter.Empty() {
+			keep = func(e *entry.Entry) (bool, error) { return matchFields(e, filter) }
+		}
+		if err := ui.HandleShowEntries(wrappedJ, keep); err != nil {
 			fmt.Printf("Error displaying entries: %v\n", err)
 			os.Exit(1)
 		}
+		savePreviews()
 
 	case "describe":
 		if len(args) != 2 {
@@ -209,12 +804,62 @@ func handleJournalCommand(args []string) {
 		wrappedJ := journal.FromType(j)
 		fmt.Println(wrappedJ.Describe())
 
+	case "export":
+		handleExportJournal(args)
+
+	case "merge":
+		if len(args) != 3 {
+			fmt.Println("Usage: jot journal merge <source> <dest>")
+			os.Exit(1)
+		}
+		j, exists := journalCollection.Journals[args[1]]
+		if !exists {
+			fmt.Printf("Journal '%s' does not exist\n", args[1])
+			os.Exit(1)
+		}
+		moved, err := journal.FromType(j).MergeInto(args[2])
+		if err != nil {
+			fmt.Printf("Error merging journals: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Moved %d entries from '%s' to '%s' and deleted '%s'\n", moved, args[1], args[2], args[1])
+		if cfg, err := config.Current(); err == nil && cfg.String("default_journal") == args[1] {
+			fmt.Printf("Note: default_journal is still set to '%s'; run `jot config set default_journal %s`\n", args[1], args[2])
+		}
+
+	case "rename":
+		if len(args) != 3 {
+			fmt.Println("Usage: jot journal rename <name> <new-name>")
+			os.Exit(1)
+		}
+		newName := collection.NormalizeName(args[2])
+		if newName == "" {
+			fmt.Println("Journal name cannot be empty")
+			os.Exit(1)
+		}
+		if err := journalCollection.RenameJournal(args[1], newName); err != nil {
+			fmt.Printf("Error renaming journal: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Renamed journal '%s' to '%s'\n", args[1], newName)
+		if cfg, err := config.Current(); err == nil {
+			for _, key := range []string{"default_journal", "watch.journal"} {
+				if cfg.String(key) == args[1] {
+					fmt.Printf("Note: %s is still set to '%s'; run `jot config set %s %s`\n", key, args[1], key, newName)
+				}
+			}
+		}
+
 	case "delete-entry":
-		if len(args) < 2 {
-			fmt.Println("Usage: jot journal delete-entry <journal-name> [entry-id]")
+		fs := flag.NewFlagSet("journal delete-entry", flag.ExitOnError)
+		dryRun := fs.Bool("dry-run", false, "Show the entry that would be deleted without deleting it")
+		yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) < 1 || len(rest) > 2 || (*dryRun && len(rest) != 2) {
+			fmt.Println("Usage: jot journal delete-entry <journal-name> [entry-id [--dry-run] [--yes]]")
 			os.Exit(1)
 		}
-		journalName := args[1]
+		journalName := rest[0]
 
 		j, exists := journalCollection.Journals[journalName]
 		if !exists {
@@ -225,41 +870,75 @@ func handleJournalCommand(args []string) {
 		wrappedJ := journal.FromType(j)
 
 		// If no entry ID is provided, use interactive mode
-		if len(args) == 2 {
+		if len(rest) == 1 {
 			if err := ui.HandleInteractiveDelete(wrappedJ); err != nil {
 				fmt.Printf("Error in interactive delete: %v\n", err)
 				os.Exit(1)
 			}
+			savePreviews()
 			return
 		}
 
 		// Otherwise, proceed with single entry deletion
-		entryID := args[2]
-		e, err := entry.Load(entryID)
+		entryID := rest[1]
+		e, err := wrappedJ.LoadEntry(entryID)
 		if err != nil {
 			fmt.Printf("Error loading entry: %v\n", err)
 			os.Exit(1)
 		}
 
 		// Verify the entry belongs to the specified journal
-		if e.JournalID != journalName {
+		if e.JournalID != j.ID {
 			fmt.Printf("Entry %s does not belong to journal '%s'\n", entryID, journalName)
 			os.Exit(1)
 		}
 
-		// Delete the entry from storage
-		if err := e.Delete(); err != nil {
+		summary := fmt.Sprintf("entry %s (%s) from journal '%s': %s", entryID, ui.FormatTime(e.Created), journalName, queuePreview(e))
+		if *dryRun {
+			fmt.Printf("Would delete %s\n", summary)
+			return
+		}
+		if !confirmDelete(*yes, 1, fmt.Sprintf("Delete %s?", summary), "delete an entry") {
+			return
+		}
+
+		if err := wrappedJ.DeleteEntry(e); err != nil {
 			fmt.Printf("Error deleting entry: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Remove the entry from the journal
-		if err := wrappedJ.RemoveEntry(entryID); err != nil {
-			fmt.Printf("Error removing entry from journal: %v\n", err)
+		fmt.Printf("Entry %s deleted from journal '%s'\n", entryID, journalName)
+
+	case "verify":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot journal verify <name>")
+			os.Exit(1)
+		}
+		j, exists := journalCollection.Journals[args[1]]
+		if !exists {
+			fmt.Printf("Journal '%s' does not exist\n", args[1])
 			os.Exit(1)
 		}
+		if err := journal.FromType(j).Verify(); err != nil {
+			fmt.Printf("Verification failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Journal '%s' verified: %d entries, chain intact\n", args[1], len(j.EntryIDs))
 
-		fmt.Printf("Entry %s deleted from journal '%s'\n", entryID, journalName)
+	case "edit":
+		handleEditEntry(args)
+
+	case "history":
+		handleEntryHistory(args)
+
+	case "revert":
+		handleEntryRevert(args)
+
+	case "sensitive":
+		handleEntrySensitive(args)
+
+	case "mark":
+		handleEntryMark(args)
 
 	default:
 		fmt.Printf("Unknown command: %s\n", args[0])
@@ -268,8 +947,14 @@ func handleJournalCommand(args []string) {
 }
 
 func handleEntry(journalName, text string) {
+	handleNewEntry(journalName, text, nil, nil)
+}
+
+// handleNewEntry creates an entry like handleEntry, storing the answers of
+// a check-in with it when checkin is set
+func handleNewEntry(journalName, text string, checkin *types.Checkin, bookmark *types.Bookmark) {
 	if journalName == "" {
-		journalName = journalCollection.GetDefaultJournal()
+		journalName = defaultJournal()
 		if journalName == "" {
 			fmt.Println("No default journal set. Please specify a journal with --journal or set a default journal.")
 			os.Exit(1)
@@ -285,63 +970,155 @@ func handleEntry(journalName, text string) {
 
 	wrappedJ := journal.FromType(j)
 
-	// Create new entry
-	e, err := entry.New(journalName, text)
+	fields, err := newEntryFields()
 	if err != nil {
-		fmt.Printf("Error creating entry: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Save the entry
-	if err := e.Save(); err != nil {
-		fmt.Printf("Error saving entry: %v\n", err)
-		os.Exit(1)
+	backdated := entryDate != "" || entryTime != ""
+	var created time.Time
+	if backdated {
+		if wrappedJ.AppendOnly {
+			fmt.Printf("Journal '%s' is append-only; its entries are always dated when written\n", journalName)
+			os.Exit(1)
+		}
+		var err error
+		if created, err = when.Parse(entryDate, entryTime, time.Now()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// Add entry to journal
-	if err := wrappedJ.AddEntry(e.ID); err != nil {
-		fmt.Printf("Error adding entry to journal: %v\n", err)
-		os.Exit(1)
+	if !allowDuplicates {
+		at := created
+		if !backdated {
+			at = time.Now()
+		}
+		dup, err := wrappedJ.FindDuplicate(text, at)
+		if err != nil {
+			fmt.Printf("Error checking for duplicates: %v\n", err)
+			os.Exit(1)
+		}
+		if dup != nil {
+			fmt.Printf("Skipped: entry %s in '%s' has the same text and was written %s; use --allow-duplicates to add it anyway\n",
+				dup.ID, journalName, ui.FormatTime(dup.Created))
+			return
+		}
 	}
 
-	fmt.Printf("Entry added to journal '%s'\n", journalName)
-}
+	// Weather and location are only looked up for entries written now
+	if !backdated && enrich.Enabled() {
+		if fields == nil {
+			fields = &types.Fields{}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := enrich.Fill(ctx, fields)
+		cancel()
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		if *fields == (types.Fields{}) {
+			fields = nil
+		}
+	}
 
-func handleNukeCommand() {
-	fmt.Print("WARNING: This will delete all journals and entries. Are you sure? (y/N): ")
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+	// Create new entry
+	e, err := wrappedJ.NewEntry(text)
 	if err != nil {
-		fmt.Printf("Error reading response: %v\n", err)
+		fmt.Printf("Error creating entry: %v\n", err)
 		os.Exit(1)
 	}
-
-	response = strings.TrimSpace(response)
-	if response != "y" && response != "Y" {
-		fmt.Println("Operation cancelled")
-		return
+	if checkin != nil {
+		if err := e.SetCheckin(checkin); err != nil {
+			fmt.Printf("Error saving check-in: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if bookmark != nil {
+		if err := e.SetBookmark(bookmark); err != nil {
+			fmt.Printf("Error saving bookmark: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if len(entryTags) > 0 {
+		if err := e.SetTags(entryTags); err != nil {
+			fmt.Printf("Error tagging entry: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if entryTitle != "" {
+		if err := e.SetTitle(entryTitle); err != nil {
+			fmt.Printf("Error setting title: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if fields != nil {
+		if err := e.SetFields(fields); err != nil {
+			fmt.Printf("Error saving fields: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if backdated {
+		e.Created = created
 	}
+	e.Sensitive = entrySensitive
+	e.Task = entryTask
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Printf("Error getting home directory: %v\n", err)
-		os.Exit(1)
+	saveNewEntry(wrappedJ, e)
+
+	fmt.Printf("Entry added to journal '%s'\n", journalName)
+	warnQuota(wrappedJ.Journal)
+}
+
+// newEntryFields checks the --mood, --energy, --weather and --location
+// flags, returning nil when none is given
+func newEntryFields() (*types.Fields, error) {
+	f := &types.Fields{
+		Weather:  strings.TrimSpace(entryWeather),
+		Location: strings.TrimSpace(entryLocation),
 	}
+	var err error
+	if entryMood != "" {
+		if f.Mood, err = mood.ParseMood(entryMood); err != nil {
+			return nil, err
+		}
+	}
+	if entryEnergy != "" {
+		if f.Energy, err = mood.ParseEnergy(entryEnergy); err != nil {
+			return nil, err
+		}
+	}
+	if *f == (types.Fields{}) {
+		return nil, nil
+	}
+	return f, nil
+}
 
-	// Remove .jot directory
-	jotDir := filepath.Join(homeDir, ".jot")
-	if err := os.RemoveAll(jotDir); err != nil {
-		fmt.Printf("Error removing .jot directory: %v\n", err)
+// saveNewEntry saves a freshly created entry into the journal, recording it
+// for jot undo unless the journal could not take it back out
+func saveNewEntry(wrappedJ *journal.Journal, e *entry.Entry) {
+	if err := wrappedJ.SaveEntry(e); err != nil {
+		fmt.Printf("Error adding entry to journal: %v\n", err)
 		os.Exit(1)
 	}
+	if wrappedJ.AppendOnly || wrappedJ.IsShared() {
+		return
+	}
+	recordOp(&oplog.Op{Kind: oplog.EntryCreate, Journal: wrappedJ.Name, JournalID: wrappedJ.ID, EntryID: e.ID})
+}
 
-	// Generate new NaCl keys
-	if _, err := crypto.GenerateNaclKey(); err != nil {
-		fmt.Printf("Error generating new NaCl keys: %v\n", err)
-		os.Exit(1)
+// savePreviews writes back the previews computed by a list or statistics
+// view, warning when it cannot
+func savePreviews() {
+	if err := entry.SavePreviews(); err != nil {
+		fmt.Printf("Warning: failed to save preview cache: %v\n", err)
 	}
+}
 
-	fmt.Println("All data has been deleted and encryption keys have been regenerated.")
+// defaultJournal returns the journal used when none is given on the command line
+func defaultJournal() string {
+	return journalCollection.ResolveDefaultJournal()
 }
 
 // entryItem represents a journal entry in the interactive list
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// handleNextCommand shows the oldest unread entry of a journal and marks
+// it read, so that a journal can be worked through like a queue
+func handleNextCommand(journalName string, args []string) {
+	fs := flag.NewFlagSet("next", flag.ExitOnError)
+	peek := fs.Bool("peek", false, "Show the entry without marking it read")
+	archive := fs.Bool("archive", false, "Mark the entry archived instead of read")
+	list := fs.Bool("list", false, "List every unread entry instead")
+	rest := parseArgs(fs, args)
+	if len(rest) > 1 || (*peek && *archive) {
+		fmt.Println("Usage: jot next [journal] [--peek | --archive] [--list]")
+		os.Exit(1)
+	}
+	if len(rest) == 1 {
+		journalName = rest[0]
+	}
+	if journalName == "" {
+		journalName = defaultJournal()
+		if journalName == "" {
+			fmt.Println("No default journal set. Please specify a journal.")
+			os.Exit(1)
+		}
+	}
+	j, exists := journalCollection.Journals[journalName]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", journalName)
+		os.Exit(1)
+	}
+
+	entries, err := journal.FromType(j).GetEntries()
+	if err != nil {
+		fmt.Printf("Error loading entries: %v\n", err)
+		os.Exit(1)
+	}
+	var unread []*entry.Entry
+	for _, e := range entries {
+		if e.GetState() == entry.StateUnread {
+			unread = append(unread, e)
+		}
+	}
+	if len(unread) == 0 {
+		fmt.Printf("No unread entries in '%s'\n", journalName)
+		return
+	}
+
+	if *list {
+		for _, e := range unread {
+			fmt.Printf("%s  %s  %s\n", e.ID, ui.FormatTime(e.Created), queuePreview(e))
+		}
+		fmt.Printf("%s unread in '%s'\n", plural(len(unread), "entry"), journalName)
+		return
+	}
+
+	e := unread[0]
+	if ui.Discreet() {
+		fmt.Printf("%s %s\n%s\n", e.ID, ui.FormatTime(e.Created), ui.HiddenPreview())
+	} else {
+		content, err := e.GetDecryptedBody()
+		if err != nil {
+			fmt.Printf("Error decrypting entry: %v\n", err)
+			os.Exit(1)
+		}
+		tags, err := e.GetTags()
+		if err != nil {
+			fmt.Printf("Error reading tags: %v\n", err)
+			os.Exit(1)
+		}
+		title, err := e.GetTitle()
+		if err != nil {
+			fmt.Printf("Error reading title: %v\n", err)
+			os.Exit(1)
+		}
+		fields, err := e.GetFields()
+		if err != nil {
+			fmt.Printf("Error reading fields: %v\n", err)
+			os.Exit(1)
+		}
+		ui.PrintEntry(os.Stdout, e.ID, e.Created, e.Author, title, tags, fields, content)
+	}
+	if *peek {
+		return
+	}
+
+	state := entry.StateRead
+	if *archive {
+		state = entry.StateArchived
+	}
+	setEntryState(e, state)
+	fmt.Printf("\nMarked %s %s; %s unread left in '%s'\n", e.ID, state, plural(len(unread)-1, "entry"), journalName)
+}
+
+func handleEntryMark(args []string) {
+	if len(args) != 4 {
+		fmt.Printf("Usage: jot journal mark <journal-name> <entry-id> <%s>\n", strings.Join(entry.States, "|"))
+		os.Exit(1)
+	}
+	e := loadJournalEntry(args[1], args[2])
+
+	if e.GetState() == args[3] {
+		fmt.Println("No changes made")
+		return
+	}
+	setEntryState(e, args[3])
+	fmt.Printf("Entry %s is now %s\n", e.ID, e.GetState())
+}
+
+// setEntryState changes the state of e and saves it
+func setEntryState(e *entry.Entry, state string) {
+	if err := e.SetState(state); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.Save(); err != nil {
+		fmt.Printf("Error saving entry: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// queuePreview returns the title or first line of e for one-line listings
+// such as jot next --list, hiding it like list views do
+func queuePreview(e *entry.Entry) string {
+	if ui.Masked(e.Sensitive) {
+		return ui.HiddenPreview()
+	}
+	title, err := e.GetTitle()
+	if err != nil {
+		fmt.Printf("Error reading title: %v\n", err)
+		os.Exit(1)
+	}
+	if title != "" {
+		return title
+	}
+	content, err := e.GetDecryptedBody()
+	if err != nil {
+		fmt.Printf("Error decrypting entry: %v\n", err)
+		os.Exit(1)
+	}
+	return preview(content, 60)
+}
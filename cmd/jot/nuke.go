@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/gc"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/trash"
+)
+
+// nukePhrase must be typed to delete all data
+const nukePhrase = "delete all data"
+
+func handleNukeCommand(journalName string, args []string) {
+	fs := flag.NewFlagSet("nuke", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "List what would be deleted without deleting anything")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	keepKeys := fs.Bool("keep-keys", false, "Delete the data but keep the key pairs")
+	name := fs.String("journal", journalName, "Delete only this journal, for good")
+	if rest := parseArgs(fs, args); len(rest) != 0 || (*keepKeys && *name != "") {
+		fmt.Println("Usage: jot nuke [--journal name | --keep-keys] [--dry-run] [--yes]")
+		os.Exit(1)
+	}
+
+	if *name != "" {
+		nukeJournal(*name, *dryRun, *yes)
+		return
+	}
+
+	jotDir, err := config.DataDir()
+	if err != nil {
+		fmt.Printf("Error getting data directory: %v\n", err)
+		os.Exit(1)
+	}
+	keyDir, err := crypto.KeyPairDir()
+	if err != nil {
+		fmt.Printf("Error getting key directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(journalCollection.Journals))
+	entries := 0
+	for name, j := range journalCollection.Journals {
+		names = append(names, name)
+		entries += len(j.EntryIDs)
+	}
+	sort.Strings(names)
+	trashed, err := trash.List()
+	if err != nil {
+		fmt.Printf("Error reading trash: %v\n", err)
+		os.Exit(1)
+	}
+	for _, item := range trashed {
+		entries += len(item.Journal.EntryIDs)
+	}
+	keys := "including the encryption keys"
+	if *keepKeys {
+		keys = fmt.Sprintf("but not the key pairs in %s", keyDir)
+	}
+	what := fmt.Sprintf("%s with %s and everything else in %s, %s",
+		plural(len(names)+len(trashed), "journal"), plural(entries, "entry"), jotDir, keys)
+	if *dryRun {
+		for _, name := range names {
+			fmt.Printf("  %-20s %s\n", name, plural(len(journalCollection.Journals[name].EntryIDs), "entry"))
+		}
+		for _, item := range trashed {
+			fmt.Printf("  %-20s %s (in the trash)\n", item.Journal.Name, plural(len(item.Journal.EntryIDs), "entry"))
+		}
+		if *keepKeys {
+			fmt.Printf("Would delete %s\n", what)
+		} else {
+			fmt.Printf("Would delete %s, then generate new keys\n", what)
+		}
+		return
+	}
+	if !confirmPhrase(*yes, fmt.Sprintf("WARNING: This will delete %s. It cannot be undone.", what), nukePhrase, "delete all data") {
+		return
+	}
+
+	if *keepKeys {
+		if err := removeAllExcept(filepath.Clean(jotDir), filepath.Clean(keyDir)); err != nil {
+			fmt.Printf("Error removing data: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("All data has been deleted; the encryption keys were kept.")
+		return
+	}
+
+	// Remove the data directory
+	if err := os.RemoveAll(jotDir); err != nil {
+		fmt.Printf("Error removing data directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Generate new NaCl keys
+	if _, err := crypto.GenerateNaclKey(); err != nil {
+		fmt.Printf("Error generating new NaCl keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("All data has been deleted and encryption keys have been regenerated.")
+}
+
+// nukeJournal deletes a journal and its entries for good, skipping the
+// trash, along with the copies jot undo keeps of them
+func nukeJournal(name string, dryRun, yes bool) {
+	j, exists := journalCollection.Journals[name]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", name)
+		os.Exit(1)
+	}
+	if j.AppendOnly {
+		fmt.Printf("Error deleting journal: journal '%s' is append-only and cannot be deleted\n", name)
+		os.Exit(1)
+	}
+	if j.SharedDir != "" {
+		fmt.Printf("Error deleting journal: journal '%s' is shared and its entries in %s belong to every member; "+
+			"stop following it with jot journal delete\n", name, j.SharedDir)
+		os.Exit(1)
+	}
+
+	what := fmt.Sprintf("journal '%s' and its %s", name, plural(len(j.EntryIDs), "entry"))
+	if dryRun {
+		printEntryList(j)
+		fmt.Printf("Would delete %s for good, without moving them to the trash\n", what)
+		return
+	}
+	if !confirmPhrase(yes, fmt.Sprintf("WARNING: This will delete %s for good. It cannot be restored or undone.", what), name, "delete a journal") {
+		return
+	}
+
+	if err := journal.FromType(j).Delete(); err != nil {
+		fmt.Printf("Error deleting journal: %v\n", err)
+		os.Exit(1)
+	}
+	if err := forgetJournalOps(j.ID); err != nil {
+		fmt.Printf("Error clearing undo history: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Deleted %s for good\n", what)
+
+	// Long entries leave their bodies in the blob store
+	result, err := gc.Run(false)
+	if err != nil {
+		fmt.Printf("Error removing unused blobs: %v\n", err)
+		os.Exit(1)
+	}
+	if result.Removed > 0 {
+		fmt.Printf("Removed %d unreferenced blobs, freeing %s\n", result.Removed, formatBytes(result.Freed))
+	}
+	if result.Recent > 0 {
+		fmt.Printf("Kept %d unreferenced blobs written in the last hour; run jot gc later to remove them\n", result.Recent)
+	}
+}
+
+// forgetJournalOps drops the operations on the journal with the given ID
+// from the undo history, with the entries kept for them
+func forgetJournalOps(id string) error {
+	ops, err := oplog.List()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.JournalID == id || op.MovedFromID == id {
+			if err := op.Drop(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeAllExcept deletes everything in dir except keep and the directories
+// leading to it
+func removeAllExcept(dir, keep string) error {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name())
+		switch {
+		case path == keep:
+		case f.IsDir() && strings.HasPrefix(keep, path+string(filepath.Separator)):
+			if err := removeAllExcept(path, keep); err != nil {
+				return err
+			}
+		default:
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
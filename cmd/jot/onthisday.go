@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// journalEntry pairs an entry with the name of the journal it was found in
+type journalEntry struct {
+	journal string
+	entry   *entry.Entry
+}
+
+func handleOnThisDayCommand(args []string) {
+	fs := flag.NewFlagSet("onthisday", flag.ExitOnError)
+	dateFlag := fs.String("date", "", "Month and day to look up as MM-DD (defaults to today)")
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fmt.Println("Usage: jot onthisday [--date MM-DD]")
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	month, day := now.Month(), now.Day()
+	if *dateFlag != "" {
+		t, err := time.Parse("01-02", *dateFlag)
+		if err != nil {
+			fmt.Printf("Invalid date '%s': expected MM-DD\n", *dateFlag)
+			os.Exit(1)
+		}
+		month, day = t.Month(), t.Day()
+	}
+
+	var matches []journalEntry
+	for name, j := range journalCollection.Journals {
+		entries, err := journal.FromType(j).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries for journal '%s': %v\n", name, err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			created := e.Created.Local()
+			if created.Year() < now.Year() && created.Month() == month && created.Day() == day {
+				matches = append(matches, journalEntry{journal: name, entry: e})
+			}
+		}
+	}
+
+	// Format against a leap year so that 02-29 is labelled correctly
+	label := time.Date(2000, month, day, 0, 0, 0, 0, time.Local).Format("January 2")
+	if len(matches) == 0 {
+		fmt.Printf("No entries from previous years on %s\n", label)
+		return
+	}
+
+	// Most recent year first
+	sort.Slice(matches, func(a, b int) bool {
+		return matches[a].entry.Created.After(matches[b].entry.Created)
+	})
+
+	fmt.Printf("On this day, %s:\n", label)
+	for _, m := range matches {
+		content := ui.HiddenPreview()
+		if !ui.Masked(m.entry.Sensitive) {
+			var err error
+			if content, err = m.entry.GetDecryptedBody(); err != nil {
+				fmt.Printf("Error decrypting entry %s: %v\n", m.entry.ID, err)
+				os.Exit(1)
+			}
+		}
+		years := now.Year() - m.entry.Created.Local().Year()
+		ago := "1 year ago"
+		if years != 1 {
+			ago = fmt.Sprintf("%d years ago", years)
+		}
+		fmt.Printf("\n%d (%s) | %s | %s\n%s\n", m.entry.Created.Local().Year(), ago, m.journal, m.entry.ID, content)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/notify"
+	"github.com/veritome/jot/internal/prompt"
+	"github.com/veritome/jot/internal/template"
+	"github.com/veritome/jot/internal/ui"
+)
+
+const promptUsage = "Usage: jot prompt [journal] | edit <journal> | schedule <journal> --every <day|weekday|month|monday...> [--at HH:MM] | unschedule <journal> | remove <journal> | list | run"
+
+func handlePromptCommand(journalName string, args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "edit":
+			handlePromptEdit(args[1:])
+			return
+		case "schedule":
+			handlePromptSchedule(args[1:])
+			return
+		case "unschedule", "remove":
+			if len(args) != 2 {
+				fmt.Printf("Usage: jot prompt %s <journal>\n", args[0])
+				os.Exit(1)
+			}
+			name := goalJournal(args[1])
+			if args[0] == "remove" {
+				if err := prompt.Remove(name); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("Removed the prompt of '%s'\n", name)
+				return
+			}
+			if err := prompt.Unschedule(name); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("No more reminders of the prompt of '%s'\n", name)
+			return
+		case "list":
+			handlePromptList(args[1:])
+			return
+		case "run":
+			if len(args) != 1 {
+				fmt.Println("Usage: jot prompt run")
+				os.Exit(1)
+			}
+			due, err := prompt.RunDue(time.Now())
+			remindPrompts(due)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	if len(args) > 1 {
+		fmt.Println(promptUsage)
+		os.Exit(1)
+	}
+
+	// A journal named on its own, or with --journal, asks its own questions
+	name := journalName
+	if len(args) == 1 {
+		name = goalJournal(args[0])
+	}
+	if name != "" {
+		if j, exists := journalCollection.Journals[name]; exists && j.Prompt != nil {
+			handleJournalPrompt(name, j.Prompt.Questions)
+			return
+		}
+		if len(args) == 1 {
+			fmt.Printf("Journal '%s' has no prompt; add its questions with `jot prompt edit %s`\n", name, name)
+			os.Exit(1)
+		}
+	}
+
+	prompts, err := prompt.Load()
+	if err != nil {
+		fmt.Printf("Error loading prompts: %v\n", err)
+		os.Exit(1)
+	}
+	today := prompt.ForDay(prompts, time.Now())
+
+	text, ok, err := ui.HandleCompose(today, "")
+	if err != nil {
+		fmt.Printf("Error composing entry: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		fmt.Println("Nothing written, entry discarded")
+		return
+	}
+
+	handleEntry(journalName, fmt.Sprintf("> %s\n\n%s", today, text))
+}
+
+// handleJournalPrompt walks through the questions of a journal's prompt
+// and writes the answers to it as one entry
+func handleJournalPrompt(journalName string, questions []string) {
+	answers, ok, err := ui.HandleForm(journalName, questions)
+	if err != nil {
+		fmt.Printf("Error answering prompt: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		fmt.Println("Nothing answered, entry discarded")
+		return
+	}
+	handleEntry(journalName, prompt.Body(questions, answers))
+}
+
+func handlePromptEdit(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: jot prompt edit <journal>")
+		os.Exit(1)
+	}
+	name := goalJournal(args[0])
+
+	var current string
+	if p := journalCollection.Journals[name].Prompt; p != nil {
+		current = strings.Join(p.Questions, "\n")
+	}
+	text, ok, err := ui.HandleCompose(fmt.Sprintf("Questions of '%s', one per line", name), current)
+	if err != nil {
+		fmt.Printf("Error editing prompt: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok || text == current {
+		fmt.Println("No changes made")
+		return
+	}
+	questions, err := prompt.ParseQuestions(text)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := prompt.Set(name, questions); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("'%s' asks %s; answer them with `jot prompt %s`\n", name, plural(len(questions), "question"), name)
+}
+
+func handlePromptSchedule(args []string) {
+	fs := flag.NewFlagSet("prompt schedule", flag.ExitOnError)
+	every := fs.String("every", "", "day, weekday, month or a day of the week such as monday")
+	at := fs.String("at", prompt.DefaultAt, "Time of day to be reminded, HH:MM")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 || *every == "" {
+		fmt.Println("Usage: jot prompt schedule <journal> --every <day|weekday|month|monday...> [--at HH:MM]")
+		os.Exit(1)
+	}
+	period, err := template.ParseEvery(*every)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	clock, err := template.ParseAt(*at)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	name := goalJournal(rest[0])
+	now := time.Now()
+	p, err := prompt.Schedule(name, period, clock, now)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("You will be reminded of the prompt of '%s' every %s at %s, first on %s\n",
+		name, p.Every, p.At, ui.FormatTime(prompt.Next(p, now)))
+	fmt.Println("Reminders are sent by jot daemon, or by jot prompt run from a scheduler")
+}
+
+func handlePromptList(args []string) {
+	if len(args) != 0 {
+		fmt.Println("Usage: jot prompt list")
+		os.Exit(1)
+	}
+	now := time.Now()
+	found := false
+	for _, name := range journalCollection.List() {
+		p := journalCollection.Journals[name].Prompt
+		if p == nil {
+			continue
+		}
+		if found {
+			fmt.Println()
+		}
+		found = true
+		schedule := ""
+		if p.Every != "" {
+			schedule = fmt.Sprintf(", every %s at %s, next %s", p.Every, p.At, ui.FormatTime(prompt.Next(p, now)))
+		}
+		fmt.Printf("%s%s\n", name, schedule)
+		for _, q := range p.Questions {
+			fmt.Printf("  %s\n", q)
+		}
+	}
+	if !found {
+		fmt.Println("No journal has a prompt; add one with `jot prompt edit <journal>`")
+	}
+}
+
+// remindPrompts prints a reminder for each journal whose prompt came due,
+// also showing it as a notification
+func remindPrompts(due []string) {
+	for _, name := range due {
+		message := fmt.Sprintf("Time to answer the questions of '%s': jot prompt %s", name, name)
+		fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), message)
+		if err := notify.Desktop("Time to jot!", message); err != nil {
+			fmt.Printf("Warning: failed to show notification: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veritome/jot/internal/quota"
+	"github.com/veritome/jot/internal/types"
+)
+
+func handleQuotaCommand(args []string) {
+	fs := flag.NewFlagSet("journal quota", flag.ExitOnError)
+	entries := fs.Int("entries", 0, "Warn once the journal holds more entries than this")
+	megabytes := fs.Int("mb", 0, "Warn once the journal takes up more megabytes than this")
+	clear := fs.Bool("clear", false, "Remove the journal's quota")
+	rest := parseArgs(fs, args[1:])
+	if len(rest) != 1 || (*clear && (*entries != 0 || *megabytes != 0)) {
+		fmt.Println("Usage: jot journal quota <name> [--entries N] [--mb N] [--clear]")
+		os.Exit(1)
+	}
+	name := rest[0]
+	j, exists := journalCollection.Journals[name]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", name)
+		os.Exit(1)
+	}
+
+	switch {
+	case *clear:
+		if j.Quota == nil {
+			fmt.Printf("Journal '%s' has no quota\n", name)
+			return
+		}
+		if err := quota.Set(name, nil); err != nil {
+			fmt.Printf("Error clearing quota: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cleared the quota of '%s'\n", name)
+
+	case *entries != 0 || *megabytes != 0:
+		q, err := quota.New(*entries, *megabytes)
+		if err != nil {
+			fmt.Printf("Error setting quota: %v\n", err)
+			os.Exit(1)
+		}
+		if err := quota.Set(name, q); err != nil {
+			fmt.Printf("Error setting quota: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Quota for '%s': %s\n", name, describeQuota(q, nil))
+		j.Quota = q
+		warnQuota(j)
+
+	default:
+		u, err := quota.Measure(j)
+		if err != nil {
+			fmt.Printf("Error measuring journal: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Journal '%s' holds %s taking up %s\n", name, plural(u.Entries, "entry"), formatBytes(u.Bytes))
+		switch {
+		case j.Quota == nil:
+			fmt.Printf("It has no quota; set one with `jot journal quota %s --entries N` or `--mb N`\n", name)
+		case u.Over(j.Quota):
+			fmt.Printf("Quota: %s (exceeded)\n", describeQuota(j.Quota, nil))
+		default:
+			fmt.Printf("Quota: %s\n", describeQuota(j.Quota, nil))
+		}
+	}
+}
+
+// describeQuota renders the limits of q, or what u takes up against them
+// when u is not nil, such as "500 entries, 10.0 MiB"
+func describeQuota(q *types.Quota, u *quota.Usage) string {
+	var parts []string
+	if q.Entries > 0 {
+		n := q.Entries
+		if u != nil {
+			n = u.Entries
+		}
+		parts = append(parts, plural(n, "entry"))
+	}
+	if q.Bytes > 0 {
+		n := q.Bytes
+		if u != nil {
+			n = u.Bytes
+		}
+		parts = append(parts, formatBytes(n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// warnQuota warns when journal j is over its quota, suggesting how to make
+// room. Entries are added all the same.
+func warnQuota(j *types.Journal) {
+	u, over, err := quota.Check(j)
+	if err != nil {
+		fmt.Printf("Warning: failed to check the quota of '%s': %v\n", j.Name, err)
+		return
+	}
+	if !over {
+		return
+	}
+	fmt.Printf("Warning: journal '%s' is over its quota of %s with %s\n", j.Name, describeQuota(j.Quota, nil), describeQuota(j.Quota, u))
+	fmt.Printf("Archive older entries with `jot journal export %s` and delete them, or raise the limit with `jot journal quota %s`\n", j.Name, j.Name)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/internal/when"
+)
+
+// handleRandomCommand resurfaces past entries at random, from one journal or
+// all of them, for re-reading
+func handleRandomCommand(args []string) {
+	fs := flag.NewFlagSet("random", flag.ExitOnError)
+	before := fs.String("before", "", "Only pick entries older than an age such as 30d, 6m or 1y, or a date")
+	older := fs.Bool("older", false, "Favour older entries")
+	rest := parseArgs(fs, args)
+	if len(rest) > 1 {
+		fmt.Println("Usage: jot random [journal] [--before 1y] [--older]")
+		os.Exit(1)
+	}
+
+	var names []string
+	if len(rest) == 1 {
+		if _, exists := journalCollection.Journals[rest[0]]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", rest[0])
+			os.Exit(1)
+		}
+		names = rest
+	} else {
+		for n := range journalCollection.Journals {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+
+	now := time.Now()
+	cutoff := now
+	if *before != "" {
+		var err error
+		if cutoff, err = when.Ago(*before, now); err != nil {
+			fmt.Printf("Invalid --before: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var candidates []journalEntry
+	for _, n := range names {
+		entries, err := journal.FromType(journalCollection.Journals[n]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries for journal '%s': %v\n", n, err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			if e.Created.Before(cutoff) {
+				candidates = append(candidates, journalEntry{journal: n, entry: e})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		if *before != "" {
+			fmt.Printf("No entries written before %s\n", cutoff.Format("January 2, 2006"))
+		} else {
+			fmt.Println("No entries to pick from")
+		}
+		return
+	}
+
+	// An entry's weight is its age in days, so that one from years ago
+	// comes up far more often than one from last week
+	weight := func(c journalEntry) float64 {
+		if !*older {
+			return 1
+		}
+		return now.Sub(c.entry.Created).Hours()/24 + 1
+	}
+
+	// Entries are picked without replacement, so "another one" never
+	// shows the same entry twice
+	next := func() (*entry.Entry, string, error) {
+		if len(candidates) == 0 {
+			return nil, "", nil
+		}
+		total := 0.0
+		for _, c := range candidates {
+			total += weight(c)
+		}
+		i, r := 0, rand.Float64()*total
+		for ; i < len(candidates)-1; i++ {
+			if r -= weight(candidates[i]); r < 0 {
+				break
+			}
+		}
+		c := candidates[i]
+		candidates = append(candidates[:i], candidates[i+1:]...)
+		return c.entry, fmt.Sprintf("From '%s', %s", c.journal, age(c.entry.Created, now)), nil
+	}
+
+	if err := ui.HandleRandom(next); err != nil {
+		fmt.Printf("Error showing entry: %v\n", err)
+		os.Exit(1)
+	}
+	savePreviews()
+}
+
+// age says how long ago t was, roughly: in years, months, weeks or days
+func age(t, now time.Time) string {
+	days := int(now.Sub(t).Hours() / 24)
+	switch {
+	case days >= 365:
+		return plural(days/365, "year") + " ago"
+	case days >= 60:
+		return plural(days/30, "month") + " ago"
+	case days >= 14:
+		return plural(days/7, "week") + " ago"
+	case days >= 2:
+		return plural(days, "day") + " ago"
+	case days == 1:
+		return "yesterday"
+	}
+	return "today"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/internal/when"
+)
+
+const readUsage = "Usage: jot read [journal] [--on date | --month YYYY-MM] [--raw] [--mood range] [--energy range] [--weather text] [--location text]"
+
+// handleReadCommand shows the entries of a journal like jot journal read,
+// optionally only those written on one day or in one month
+func handleReadCommand(journalName string, args []string) {
+	fs := flag.NewFlagSet("read", flag.ExitOnError)
+	on := fs.String("on", "", "Only entries written on this day: YYYY-MM-DD, yesterday, last friday, ...")
+	month := fs.String("month", "", "Only entries written in this month: YYYY-MM")
+	raw := fs.Bool("raw", false, "Show entries as written instead of rendering Markdown")
+	filterFlags := fieldFlags(fs)
+	rest := parseArgs(fs, args)
+	if len(rest) > 1 || (*on != "" && *month != "") {
+		fmt.Println(readUsage)
+		os.Exit(1)
+	}
+	if len(rest) == 1 {
+		journalName = rest[0]
+	}
+	if journalName == "" {
+		journalName = defaultJournal()
+		if journalName == "" {
+			fmt.Println("No default journal set. Please specify a journal.")
+			os.Exit(1)
+		}
+	}
+	j, exists := journalCollection.Journals[journalName]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", journalName)
+		os.Exit(1)
+	}
+	ui.SetRaw(*raw)
+	filter := filterFlags()
+
+	var keep func(*entry.Entry) (bool, error)
+	if !filter.Empty() {
+		keep = func(e *entry.Entry) (bool, error) { return matchFields(e, filter) }
+	}
+	if *on != "" || *month != "" {
+		from, to, label := readPeriod(*on, *month)
+		thawPeriod(from, to)
+		ensureIndex()
+		inRange := journalCollection.IndexedDates(from, to)
+		if journalCollection.Index != nil {
+			found := false
+			for _, id := range j.EntryIDs {
+				found = found || inRange[id]
+			}
+			if !found {
+				fmt.Printf("No entries in '%s' %s\n", journalName, label)
+				return
+			}
+		}
+		keep = func(e *entry.Entry) (bool, error) {
+			if journalCollection.Index == nil {
+				// The index is being built; go by the entry itself
+				if !createdWithin(e.Created, from, to) {
+					return false, nil
+				}
+			} else if !inRange[e.ID] {
+				return false, nil
+			}
+			return matchFields(e, filter)
+		}
+	}
+
+	if err := ui.HandleShowEntries(journal.FromType(j), keep); err != nil {
+		fmt.Printf("Error displaying entries: %v\n", err)
+		os.Exit(1)
+	}
+	savePreviews()
+}
+
+// readPeriod returns the first and last local day of --on or --month, and
+// how to describe them, exiting when the date is invalid
+func readPeriod(on, month string) (time.Time, time.Time, string) {
+	if on != "" {
+		day, err := when.Parse(on, "00:00", time.Now())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return day, day, "on " + day.Format("Monday, January 2, 2006")
+	}
+	first, err := time.ParseInLocation("2006-01", month, time.Local)
+	if err != nil {
+		fmt.Printf("Invalid month '%s': expected YYYY-MM\n", month)
+		os.Exit(1)
+	}
+	return first, first.AddDate(0, 1, -1), "in " + first.Format("January 2006")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleRecipientsCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot recipients <add|remove|list> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 {
+			fmt.Println("Usage: jot recipients add <name> <public-key>")
+			os.Exit(1)
+		}
+		if err := crypto.AddRecipient(args[1], args[2]); err != nil {
+			fmt.Printf("Error adding recipient: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added recipient '%s'; new entries are also encrypted to their key\n", args[1])
+		fmt.Println("Run `jot key rotate` to re-encrypt existing entries for them as well")
+
+	case "remove":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot recipients remove <name>")
+			os.Exit(1)
+		}
+		if err := crypto.RemoveRecipient(args[1]); err != nil {
+			fmt.Printf("Error removing recipient: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed recipient '%s'; entries written before now remain readable with their key\n", args[1])
+		fmt.Println("Run `jot key rotate` to re-encrypt existing entries without it")
+
+	case "list":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot recipients list")
+			os.Exit(1)
+		}
+		own, err := crypto.RestorePublicKey()
+		if err != nil {
+			fmt.Printf("Error loading key: %v\n", err)
+			os.Exit(1)
+		}
+		ownKey := own.PublicKeyString()
+		own.Clear()
+
+		recipients, err := crypto.LoadRecipients()
+		if err != nil {
+			fmt.Printf("Error loading recipients: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("  %-16s %s (your key; share it to be added elsewhere)\n", "me", ownKey)
+		for _, r := range recipients {
+			fmt.Printf("  %-16s %s added %s\n", r.Name, r.PublicKey, ui.FormatTime(r.Added))
+		}
+
+	default:
+		fmt.Printf("Unknown recipients command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/veritome/jot/internal/notify"
+	"github.com/veritome/jot/internal/prompt"
+	"github.com/veritome/jot/internal/remind"
+)
+
+func handleRemindCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot remind <install|remove|run> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "install":
+		fs := flag.NewFlagSet("remind install", flag.ExitOnError)
+		at := fs.String("at", "20:00", "Time of day for the reminder (HH:MM)")
+		backendFlag := fs.String("backend", "", "Scheduler to use: cron, systemd or launchd (auto-detected by default)")
+		fs.Parse(args[1:])
+
+		sched, err := remind.ParseSchedule(*at)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		backend := remindBackend(*backendFlag)
+
+		binary, err := os.Executable()
+		if err != nil {
+			fmt.Printf("Error locating jot binary: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := remind.Install(backend, sched, binary); err != nil {
+			fmt.Printf("Error installing reminder: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Installed daily reminder at %s using %s\n", sched, backend)
+		if n, err := notify.Current(); err == nil {
+			fmt.Printf("It will be shown with %s (notify.backend)\n", n.Name())
+		}
+
+	case "remove":
+		fs := flag.NewFlagSet("remind remove", flag.ExitOnError)
+		backendFlag := fs.String("backend", "", "Scheduler to use: cron, systemd or launchd (auto-detected by default)")
+		fs.Parse(args[1:])
+
+		backend := remindBackend(*backendFlag)
+		if err := remind.Uninstall(backend); err != nil {
+			fmt.Printf("Error removing reminder: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed daily reminder from %s\n", backend)
+
+	case "run":
+		prompts, err := prompt.Load()
+		if err != nil {
+			fmt.Printf("Error loading prompts: %v\n", err)
+			os.Exit(1)
+		}
+		message := fmt.Sprintf("Today's prompt: %s", prompt.ForDay(prompts, time.Now()))
+		if err := notify.Send("Time to jot!", message); err != nil {
+			fmt.Printf("Warning: failed to show notification: %v\n", err)
+		}
+
+	default:
+		fmt.Printf("Unknown remind command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// remindBackend returns the requested backend, detecting one when name is empty
+func remindBackend(name string) remind.Backend {
+	if name != "" {
+		return remind.Backend(name)
+	}
+	backend, err := remind.DetectBackend()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return backend
+}
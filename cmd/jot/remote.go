@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/pkg/client"
+)
+
+// handleRemote runs the subset of commands that can be served by a remote
+// jot server: listing journals, jot journal read and delete-entry, and
+// adding an entry from text that does not start with a command. The token
+// is read from the JOT_TOKEN environment variable. With confirm.remote set,
+// changes ask first unless yes is set by --yes.
+func handleRemote(remoteURL, journalName string, args []string, yes bool) {
+	c := client.New(remoteURL, os.Getenv("JOT_TOKEN"))
+	ask := confirmConfig().Bool("confirm.remote")
+	ctx := context.Background()
+
+	switch {
+	case collectionCommands[args[0]]:
+		journals, err := c.Journals(ctx)
+		if err != nil {
+			fmt.Printf("Error listing journals: %v\n", err)
+			os.Exit(1)
+		}
+		if len(journals) == 0 {
+			fmt.Println("No journals found")
+			return
+		}
+		sort.Slice(journals, func(a, b int) bool { return journals[a].Name < journals[b].Name })
+
+		fmt.Println("Available Journals:")
+		fmt.Println("------------------")
+		for _, j := range journals {
+			if j.Default {
+				fmt.Printf("  %s *\n", j.Name)
+			} else {
+				fmt.Printf("  %s\n", j.Name)
+			}
+		}
+		fmt.Println("\nNote: * indicates default journal")
+
+	case journalCommands[args[0]] && len(args) == 3 && args[1] == "read":
+		entries, err := c.Entries(ctx, args[2])
+		if err != nil {
+			fmt.Printf("Error reading journal: %v\n", err)
+			os.Exit(1)
+		}
+		for i, e := range entries {
+			if i > 0 {
+				fmt.Println()
+			}
+			body := e.Body
+			if ui.Discreet() {
+				body = ui.HiddenPreview()
+			}
+			fmt.Printf("%s %s\n%s\n", e.ID, ui.FormatTime(e.Created), body)
+		}
+
+	case journalCommands[args[0]] && len(args) == 4 && args[1] == "delete-entry":
+		if ask && !confirm(yes, fmt.Sprintf("Delete entry %s from journal '%s' on %s?", args[3], args[2], remoteURL), "change a remote journal") {
+			return
+		}
+		if err := c.DeleteEntryFrom(ctx, args[2], args[3]); err != nil {
+			fmt.Printf("Error deleting entry: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Entry %s deleted from journal '%s'\n", args[3], args[2])
+
+	case journalCommands[args[0]], commands[args[0]]:
+		fmt.Printf("'%s' is not available with --remote\n", strings.Join(args, " "))
+		os.Exit(1)
+
+	default:
+		if entryMood != "" || entryEnergy != "" || entryWeather != "" || entryLocation != "" || entryDate != "" || entryTime != "" {
+			fmt.Println("--mood, --energy, --weather, --location, --date and --time are not available with --remote")
+			os.Exit(1)
+		}
+		if ask && !confirm(yes, fmt.Sprintf("Add the entry to %s?", remoteURL), "change a remote journal") {
+			return
+		}
+		e, err := c.CreateEntry(ctx, client.NewEntry{
+			Journal:         journalName,
+			Title:           entryTitle,
+			Body:            strings.Join(args, " "),
+			Tags:            entryTags,
+			Sensitive:       entrySensitive,
+			AllowDuplicates: allowDuplicates,
+		})
+		if err != nil {
+			fmt.Printf("Error creating entry: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Entry added to journal '%s'\n", e.Journal)
+	}
+}
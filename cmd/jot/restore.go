@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veritome/jot/internal/restore"
+	"golang.org/x/term"
+)
+
+// maxListedFailures caps the unreadable entries listed by jot restore
+const maxListedFailures = 10
+
+func handleRestoreCommand(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	keysDir := fs.String("from-keys", "", "Key backup: a copy of the backup/ directory or of a whole data directory")
+	dataDir := fs.String("from-data", "", "Data backup: a copy of the data directory, with or without keys")
+	yes := fs.Bool("yes", false, "Replace the live data without asking for confirmation")
+	if rest := parseArgs(fs, args); len(rest) != 0 || *keysDir == "" || *dataDir == "" {
+		fmt.Println("Usage: jot restore --from-keys <dir> --from-data <dir> [--yes]")
+		os.Exit(1)
+	}
+
+	fmt.Println("Step 1/3: Combining the backups in a staging directory")
+	staged, err := restore.Stage(*keysDir, *dataDir)
+	if err != nil {
+		fmt.Printf("Error staging backups: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  Staged in %s\n", staged.Dir)
+
+	fmt.Println("Step 2/3: Checking that every entry can be decrypted with the restored keys")
+	if err := staged.Verify(); err != nil {
+		staged.Discard()
+		fmt.Printf("Error verifying backups: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  %d journals, %d entries\n", staged.Journals, staged.Entries)
+	if len(staged.Failed) > 0 {
+		staged.Discard()
+		fmt.Printf("  %d entries cannot be read:\n", len(staged.Failed))
+		for i, f := range staged.Failed {
+			if i == maxListedFailures {
+				fmt.Printf("    ... and %d more\n", len(staged.Failed)-maxListedFailures)
+				break
+			}
+			fmt.Printf("    %s\n", f)
+		}
+		fmt.Println("Nothing was changed. Make sure the key backup is from the same installation,")
+		fmt.Println("including the retired/ keys of past rotations.")
+		os.Exit(1)
+	}
+	fmt.Println("  All entries are readable")
+
+	fmt.Printf("Step 3/3: Replacing %s\n", staged.Live)
+	if !*yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			staged.Discard()
+			fmt.Println("Refusing to replace the data directory without confirmation; pass --yes to restore non-interactively")
+			os.Exit(1)
+		}
+		fmt.Printf("Existing data will be moved aside, not deleted. Continue? (y/N): ")
+		response, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			staged.Discard()
+			fmt.Printf("Error reading response: %v\n", err)
+			os.Exit(1)
+		}
+		response = strings.TrimSpace(response)
+		if response != "y" && response != "Y" {
+			staged.Discard()
+			fmt.Println("Operation cancelled")
+			return
+		}
+	}
+
+	kept, err := staged.Commit()
+	if err != nil {
+		staged.Discard()
+		fmt.Printf("Error replacing data directory: %v\n", err)
+		os.Exit(1)
+	}
+	if kept != "" {
+		fmt.Printf("  Previous data moved to %s\n", kept)
+	}
+	fmt.Printf("Restored %d journals and %d entries\n", staged.Journals, staged.Entries)
+}
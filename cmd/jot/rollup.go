@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/rollup"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/internal/when"
+)
+
+const rollupUsage = "Usage: jot rollup [--week | --month] [--date date] [--journal name] [--no-edit] [--list]"
+
+// handleRollupCommand writes the review of a week or month as a rollup
+// entry linking to the entries written in it, or opens the existing one
+func handleRollupCommand(journalName string, args []string) {
+	fs := flag.NewFlagSet("rollup", flag.ExitOnError)
+	week := fs.Bool("week", false, "Review a week, Monday to Sunday (the default)")
+	month := fs.Bool("month", false, "Review a month")
+	dateFlag := fs.String("date", "", "Any day of the period to review: YYYY-MM-DD, yesterday, last friday, ... (defaults to today)")
+	fs.StringVar(&journalName, "journal", journalName, "Journal to review")
+	noEdit := fs.Bool("no-edit", false, "Save the drafted rollup without opening it in the editor")
+	list := fs.Bool("list", false, "List the journal's rollups instead")
+	if rest := parseArgs(fs, args); len(rest) != 0 || (*week && *month) {
+		fmt.Println(rollupUsage)
+		os.Exit(1)
+	}
+	if journalName == "" {
+		journalName = defaultJournal()
+		if journalName == "" {
+			fmt.Println("No default journal set. Please specify a journal with --journal or set a default journal.")
+			os.Exit(1)
+		}
+	}
+	j, exists := journalCollection.Journals[journalName]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", journalName)
+		os.Exit(1)
+	}
+	wrappedJ := journal.FromType(j)
+	entries, err := wrappedJ.GetEntries()
+	if err != nil {
+		fmt.Printf("Error loading entries: %v\n", err)
+		os.Exit(1)
+	}
+	if *list {
+		listRollups(journalName, entries)
+		return
+	}
+
+	period := rollup.Week
+	if *month {
+		period = rollup.Month
+	}
+	day := time.Now()
+	if *dateFlag != "" {
+		if day, err = when.Parse(*dateFlag, "00:00", time.Now()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	start, end := rollup.Period(period, day)
+	title := rollup.Title(period, start)
+
+	// A period has one rollup; running again opens it
+	var items []rollup.Item
+	var ids []string
+	for _, e := range entries {
+		r, err := e.GetRollup()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if r != nil {
+			if r.Period == period && r.Start.Equal(start) {
+				fmt.Printf("Opening the existing rollup %s\n", e.ID)
+				handleEditEntry([]string{"edit", journalName, e.ID})
+				return
+			}
+			continue
+		}
+		if e.Created.Before(start) || !e.Created.Before(end) {
+			continue
+		}
+		items = append(items, rollupItem(e))
+		ids = append(ids, e.ID)
+	}
+
+	text := rollup.Body(period, items)
+	if !*noEdit {
+		written, ok, err := ui.HandleCompose(title, text)
+		if err != nil {
+			fmt.Printf("Error writing rollup: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			fmt.Println("Rollup not saved")
+			return
+		}
+		text = written
+	}
+
+	e, err := wrappedJ.NewEntry(text)
+	if err != nil {
+		fmt.Printf("Error creating entry: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.SetTitle(title); err != nil {
+		fmt.Printf("Error setting title: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.SetRollup(&types.Rollup{Period: period, Start: start, Entries: ids}); err != nil {
+		fmt.Printf("Error saving rollup: %v\n", err)
+		os.Exit(1)
+	}
+	saveNewEntry(wrappedJ, e)
+	fmt.Printf("Rollup %s saved to '%s': %s, linking %s\n", e.ID, journalName, title, plural(len(ids), "entry"))
+	warnQuota(wrappedJ.Journal)
+}
+
+// rollupItem describes e in a rollup, leaving out what sensitive entries
+// hide
+func rollupItem(e *entry.Entry) rollup.Item {
+	item := rollup.Item{ID: e.ID, Created: e.Created.Local()}
+	if e.Sensitive {
+		return item
+	}
+	title, err := e.GetTitle()
+	if err != nil {
+		fmt.Printf("Error reading title: %v\n", err)
+		os.Exit(1)
+	}
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		fmt.Printf("Error decrypting entry %s: %v\n", e.ID, err)
+		os.Exit(1)
+	}
+	if item.Tags, err = e.GetTags(); err != nil {
+		fmt.Printf("Error reading tags: %v\n", err)
+		os.Exit(1)
+	}
+	item.Summary = ui.Summary(title, body, 60)
+	return item
+}
+
+// listRollups prints the rollups among entries in the order they were
+// written
+func listRollups(journalName string, entries []*entry.Entry) {
+	found := false
+	for _, e := range entries {
+		r, err := e.GetRollup()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if r == nil {
+			continue
+		}
+		found = true
+		fmt.Printf("%s  %-5s  %-32s  %s\n", e.ID, r.Period, rollup.Title(r.Period, r.Start.Local()), plural(len(r.Entries), "entry"))
+	}
+	if !found {
+		fmt.Printf("No rollups in '%s' yet; write one with jot rollup --week or --month\n", journalName)
+	}
+}
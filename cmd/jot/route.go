@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/route"
+)
+
+// routeEntry picks the journal of a quick entry by the prefix of its first
+// word, following capture.routes. It returns the journal, empty for the
+// default one, and the text left once the prefix is taken off.
+func routeEntry(text string) (string, string) {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	rules, err := route.Parse(cfg.String("capture.routes"))
+	if err != nil {
+		fmt.Printf("Error in capture.routes: %v\n", err)
+		os.Exit(1)
+	}
+	rule, name, rest := route.Match(rules, text)
+	if rule == nil {
+		return "", text
+	}
+	if _, exists := journalCollection.Journals[name]; !exists && rule.Wildcard() {
+		// Likely a mention rather than a journal, so the text is kept whole
+		fmt.Printf("No journal '%s'; adding the entry as written to the default journal\n", name)
+		return "", text
+	}
+	if rest == "" {
+		fmt.Printf("Nothing to add to '%s'; write the entry after the prefix\n", name)
+		os.Exit(1)
+	}
+	return name, rest
+}
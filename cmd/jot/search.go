@@ -0,0 +1,305 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/mood"
+	"github.com/veritome/jot/internal/ui"
+	"github.com/veritome/jot/internal/when"
+)
+
+func handleSearchCommand(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	var tags stringList
+	fs.Var(&tags, "tag", "Only entries with this tag (repeatable)")
+	titleFlag := fs.String("title", "", "Only entries whose title contains this text")
+	fromFlag := fs.String("from", "", "Only entries written on or after this date (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Only entries written on or before this date (YYYY-MM-DD)")
+	whenFlag := fs.String("when", "", "Only entries written in this period: last week, this month, march 2023, 2023-03, last 10 days, ...")
+	onFlag := fs.String("on", "", "Only entries written on this day: YYYY-MM-DD, yesterday, last friday, ...")
+	journalFlag := fs.String("journal", "", "Only search this journal")
+	reveal := fs.Bool("reveal", false, "Show the previews of sensitive entries, even in discreet mode")
+	filterFlags := fieldFlags(fs)
+	words := parseArgs(fs, args)
+	query := strings.ToLower(strings.Join(words, " "))
+	titleQuery := strings.ToLower(strings.TrimSpace(*titleFlag))
+	filter := filterFlags()
+
+	dates := 0
+	for _, set := range []bool{*fromFlag != "" || *toFlag != "", *whenFlag != "", *onFlag != ""} {
+		if set {
+			dates++
+		}
+	}
+	if (query == "" && titleQuery == "" && len(tags) == 0 && dates == 0 && filter.Empty()) || dates > 1 {
+		fmt.Println("Usage: jot search [text] [--title text] [--tag tag] [--from YYYY-MM-DD] [--to YYYY-MM-DD | --when period | --on date] [--mood range] [--energy range] [--weather text] [--location text] [--journal name] [--reveal]")
+		os.Exit(1)
+	}
+	from, to := parseSearchDate(*fromFlag), parseSearchDate(*toFlag)
+	var period string
+	switch {
+	case *whenFlag != "":
+		var err error
+		if from, to, err = when.Period(*whenFlag, time.Now()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		period = describePeriod(from, to)
+	case *onFlag != "":
+		from, to, period = readPeriod(*onFlag, "")
+	}
+
+	// A search of a period reads archived years in it; others leave them be
+	if !from.IsZero() || !to.IsZero() {
+		thawPeriod(from, to)
+	}
+	ensureIndex()
+
+	if *journalFlag != "" {
+		if _, exists := journalCollection.Journals[*journalFlag]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", *journalFlag)
+			os.Exit(1)
+		}
+	}
+
+	// Candidates are the entries listed by the searched journals
+	candidates := make(map[string]string)
+	for name, j := range journalCollection.Journals {
+		if *journalFlag != "" && name != *journalFlag {
+			continue
+		}
+		for _, id := range j.EntryIDs {
+			candidates[id] = name
+		}
+	}
+	archived, err := entry.ArchivedIDs()
+	if err != nil {
+		fmt.Printf("Error listing archives: %v\n", err)
+		os.Exit(1)
+	}
+	for id := range archived {
+		delete(candidates, id)
+	}
+
+	loaded := make(map[string]*entry.Entry)
+	load := func(id string) *entry.Entry {
+		if e, ok := loaded[id]; ok {
+			return e
+		}
+		e, err := entry.Load(id)
+		if err != nil {
+			fmt.Printf("Error loading entry: %v\n", err)
+			os.Exit(1)
+		}
+		loaded[id] = e
+		return e
+	}
+	// Narrow down with the index before loading anything; while it is
+	// being built, every entry is loaded instead
+	indexed := journalCollection.Index != nil
+	if !from.IsZero() || !to.IsZero() {
+		inRange := journalCollection.IndexedDates(from, to)
+		for id := range candidates {
+			if (indexed && !inRange[id]) || (!indexed && !createdWithin(load(id).Created, from, to)) {
+				delete(candidates, id)
+			}
+		}
+	}
+	for _, tag := range tags {
+		keys, err := entry.TagKeys(tag)
+		if err != nil {
+			fmt.Printf("Error looking up tag: %v\n", err)
+			os.Exit(1)
+		}
+		matches, encrypted := journalCollection.IndexedTag(keys)
+		needsDecryption := make(map[string]bool, len(encrypted))
+		for _, id := range encrypted {
+			needsDecryption[id] = true
+		}
+		for id := range candidates {
+			if matches[id] {
+				continue
+			}
+			if needsDecryption[id] || !indexed {
+				ok, err := load(id).HasTag(tag)
+				if err != nil {
+					fmt.Printf("Error reading tags: %v\n", err)
+					os.Exit(1)
+				}
+				if ok {
+					continue
+				}
+			}
+			delete(candidates, id)
+		}
+	}
+
+	var results []journalEntry
+	for id, name := range candidates {
+		e := load(id)
+		if ok, err := matchFields(e, filter); err != nil {
+			fmt.Printf("Error reading fields of entry %s: %v\n", id, err)
+			os.Exit(1)
+		} else if !ok {
+			continue
+		}
+		if titleQuery != "" {
+			title, err := e.GetTitle()
+			if err != nil {
+				fmt.Printf("Error reading title of entry %s: %v\n", id, err)
+				os.Exit(1)
+			}
+			if !strings.Contains(strings.ToLower(title), titleQuery) {
+				continue
+			}
+		}
+		if query != "" {
+			body, err := e.GetDecryptedBody()
+			if err != nil {
+				fmt.Printf("Error decrypting entry %s: %v\n", id, err)
+				os.Exit(1)
+			}
+			if !strings.Contains(strings.ToLower(body), query) {
+				continue
+			}
+		}
+		results = append(results, journalEntry{journal: name, entry: e})
+	}
+	if len(results) == 0 {
+		if period != "" {
+			fmt.Printf("No matching entries %s\n", period)
+		} else {
+			fmt.Println("No matching entries")
+		}
+		return
+	}
+	sort.Slice(results, func(a, b int) bool { return results[a].entry.Created.Before(results[b].entry.Created) })
+	if period != "" {
+		// Natural dates can be read more than one way; say which was meant
+		fmt.Printf("Entries written %s:\n", period)
+	}
+
+	for _, r := range results {
+		preview, err := r.entry.GetPreview()
+		if err != nil {
+			fmt.Printf("Error decrypting entry %s: %v\n", r.entry.ID, err)
+			os.Exit(1)
+		}
+		entryTags, err := r.entry.GetTags()
+		if err != nil {
+			fmt.Printf("Error reading tags: %v\n", err)
+			os.Exit(1)
+		}
+		title, err := r.entry.GetTitle()
+		if err != nil {
+			fmt.Printf("Error reading title: %v\n", err)
+			os.Exit(1)
+		}
+		fields, err := r.entry.GetFields()
+		if err != nil {
+			fmt.Printf("Error reading fields: %v\n", err)
+			os.Exit(1)
+		}
+		line := ui.Summary(title, preview.Line, 70)
+		if ui.Masked(r.entry.Sensitive) && !*reveal {
+			line = ui.HiddenPreview()
+		}
+		fmt.Printf("%s  %-12s %s", r.entry.ID, r.journal, ui.FormatTime(r.entry.Created))
+		if !ui.Masked(r.entry.Sensitive) || *reveal {
+			for _, t := range entryTags {
+				fmt.Printf(" #%s", t)
+			}
+			if fields != nil {
+				fmt.Printf(" (%s)", mood.Format(fields))
+			}
+		}
+		fmt.Printf("\n    %s\n", line)
+	}
+	savePreviews()
+}
+
+// describePeriod says which days from and to, the first and last, cover,
+// to show how a period was understood
+func describePeriod(from, to time.Time) string {
+	switch {
+	case from.Equal(to):
+		return "on " + from.Format("Monday, January 2, 2006")
+	case from.Day() == 1 && to.AddDate(0, 0, 1).Day() == 1 && from.Month() == to.Month() && from.Year() == to.Year():
+		return "in " + from.Format("January 2006")
+	case from.YearDay() == 1 && to.AddDate(0, 0, 1).YearDay() == 1 && from.Year() == to.Year():
+		return "in " + from.Format("2006")
+	default:
+		return fmt.Sprintf("from %s to %s", from.Format("Mon Jan 2, 2006"), to.Format("Mon Jan 2, 2006"))
+	}
+}
+
+// ensureIndex builds the tag and date index if there is none yet. Data
+// written before the index existed, or synced in from another machine, is
+// indexed on first use. An archive of more than index.background_entries
+// entries is indexed in the background instead, and the index left nil
+// until it is done: callers then look at the entries themselves.
+func ensureIndex() {
+	if journalCollection.Index != nil || indexInBackground() {
+		return
+	}
+	if _, err := entry.RebuildIndex(); err != nil {
+		fmt.Printf("Error building index: %v\n", err)
+		os.Exit(1)
+	}
+	loadCollection()
+}
+
+// indexInBackground starts building a large index in the background, or
+// finds one already being built, and reports whether the command should go
+// on without it
+func indexInBackground() bool {
+	if build := runningIndexBuild(); build != nil {
+		fmt.Printf("The index is being built in the background (%d of %d entries), so this may be slow\n", build.Done, build.Total)
+		return true
+	}
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	n := 0
+	for _, j := range journalCollection.Journals {
+		n += len(j.EntryIDs)
+	}
+	if limit := cfg.Int("index.background_entries"); limit <= 0 || n <= limit {
+		return false
+	}
+	if err := startIndexBuild(); err != nil {
+		fmt.Printf("Error starting index build: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Indexing %s in the background (see jot index status), so this may be slow\n", plural(n, "entry"))
+	return true
+}
+
+// createdWithin reports whether t falls between the local days from and
+// to, inclusive, like the index's date lookups. A zero bound is open.
+func createdWithin(t, from, to time.Time) bool {
+	day := t.Local().Format("2006-01-02")
+	return (from.IsZero() || day >= from.Format("2006-01-02")) && (to.IsZero() || day <= to.Format("2006-01-02"))
+}
+
+// parseSearchDate parses a YYYY-MM-DD flag value in local time
+func parseSearchDate(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		fmt.Printf("Invalid date '%s': expected YYYY-MM-DD\n", value)
+		os.Exit(1)
+	}
+	return t
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/replace"
+	"github.com/veritome/jot/internal/ui"
+	"golang.org/x/term"
+)
+
+const sedUsage = "Usage: jot sed <old/new> [--search text] [--journal name] [--ignore-case] [--word] [--dry-run] [--yes]"
+
+// sedMatch is an entry with text the rule changes
+type sedMatch struct {
+	journal       string
+	entry         *entry.Entry
+	oldTitle      string
+	body, title   string // Body and title with the text replaced
+	bodyN, titleN int    // Replacements in each
+	changes       []replace.Change
+}
+
+// handleSedCommand replaces text in the entries matching a search, showing
+// each change and asking before saving it
+func handleSedCommand(args []string) {
+	fs := flag.NewFlagSet("sed", flag.ExitOnError)
+	search := fs.String("search", "", "Only entries containing this text")
+	journalFlag := fs.String("journal", "", "Only entries in this journal")
+	ignoreCase := fs.Bool("ignore-case", false, "Match the old text regardless of case")
+	word := fs.Bool("word", false, "Only match the old text as a whole word")
+	dryRun := fs.Bool("dry-run", false, "Show the changes without saving any")
+	yes := fs.Bool("yes", false, "Save every change without asking")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println(sedUsage)
+		os.Exit(1)
+	}
+	rule, err := replace.Parse(rest[0], *ignoreCase, *word)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var names []string
+	if *journalFlag != "" {
+		if _, exists := journalCollection.Journals[*journalFlag]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", *journalFlag)
+			os.Exit(1)
+		}
+		names = []string{*journalFlag}
+	} else {
+		for name := range journalCollection.Journals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	// Archived years are thawed, so their entries get the replacement too
+	var ids []string
+	for _, name := range names {
+		ids = append(ids, journalCollection.Journals[name].EntryIDs...)
+	}
+	thawEntries(ids)
+
+	matches, skipped := findSedMatches(names, rule, strings.ToLower(strings.TrimSpace(*search)))
+	for _, name := range names {
+		if n := skipped[name]; n > 0 {
+			fmt.Printf("Skipped %s in append-only journal '%s', which cannot be rewritten\n", plural(n, "entry"), name)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println("No entries to change")
+		return
+	}
+
+	if !*dryRun && !*yes && !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Println("Refusing to change entries without confirmation; pass --yes to run non-interactively")
+		os.Exit(1)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	all := *yes
+	changed, replacements := 0, 0
+	for _, m := range matches {
+		printSedMatch(m)
+		if *dryRun {
+			continue
+		}
+		if !all {
+			switch askSed(reader) {
+			case "n":
+				continue
+			case "q":
+				fmt.Println("Stopped")
+				printSedTotal(changed, replacements)
+				return
+			case "a":
+				all = true
+			}
+		}
+		if err := saveSedMatch(m); err != nil {
+			fmt.Printf("Error saving entry %s: %v\n", m.entry.ID, err)
+			os.Exit(1)
+		}
+		changed++
+		replacements += m.bodyN + m.titleN
+	}
+
+	if *dryRun {
+		total := 0
+		for _, m := range matches {
+			total += m.bodyN + m.titleN
+		}
+		fmt.Printf("Would make %s in %s\n", plural(total, "replacement"), plural(len(matches), "entry"))
+		return
+	}
+	printSedTotal(changed, replacements)
+}
+
+// findSedMatches returns the entries of the named journals containing
+// search, if given, whose body or title the rule changes, oldest first. It
+// also counts the matching entries of append-only journals per journal.
+func findSedMatches(names []string, rule *replace.Rule, search string) ([]*sedMatch, map[string]int) {
+	var matches []*sedMatch
+	skipped := make(map[string]int)
+	for _, name := range names {
+		j := journal.FromType(journalCollection.Journals[name])
+		entries, err := j.GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			body, err := e.GetDecryptedBody()
+			if err != nil {
+				fmt.Printf("Error decrypting entry %s: %v\n", e.ID, err)
+				os.Exit(1)
+			}
+			if search != "" && !strings.Contains(strings.ToLower(body), search) {
+				continue
+			}
+			title, err := e.GetTitle()
+			if err != nil {
+				fmt.Printf("Error reading title of entry %s: %v\n", e.ID, err)
+				os.Exit(1)
+			}
+
+			m := &sedMatch{journal: name, entry: e, oldTitle: title}
+			m.body, m.bodyN = rule.Apply(body)
+			m.title, m.titleN = rule.Apply(title)
+			if m.bodyN+m.titleN == 0 {
+				continue
+			}
+			if j.AppendOnly {
+				skipped[name]++
+				continue
+			}
+			m.changes = replace.Changes(body, m.body)
+			matches = append(matches, m)
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].entry.Created.Before(matches[b].entry.Created) })
+	return matches, skipped
+}
+
+// printSedMatch shows the lines a match changes, or only the entry in
+// discreet mode and for hidden sensitive entries
+func printSedMatch(m *sedMatch) {
+	fmt.Printf("\n%s in '%s', %s, %s\n", m.entry.ID, m.journal, ui.FormatTime(m.entry.Created), plural(m.bodyN+m.titleN, "replacement"))
+	if ui.Masked(m.entry.Sensitive) {
+		fmt.Printf("  %s\n", ui.HiddenPreview())
+		return
+	}
+	if m.titleN > 0 {
+		fmt.Printf("  - # %s\n  + # %s\n", m.oldTitle, m.title)
+	}
+	for _, c := range m.changes {
+		fmt.Printf("  %d - %s\n  %d + %s\n", c.Line, c.Old, c.Line, c.New)
+	}
+}
+
+// askSed asks whether to save a change, returning y, n, a (all remaining)
+// or q (quit)
+func askSed(reader *bufio.Reader) string {
+	for {
+		fmt.Print("Save this change? [y]es, [n]o, [a]ll remaining, [q]uit: ")
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return "q"
+		}
+		switch answer := strings.ToLower(strings.TrimSpace(response)); answer {
+		case "y", "n", "a", "q":
+			return answer
+		case "yes", "no", "all", "quit":
+			return answer[:1]
+		}
+	}
+}
+
+// saveSedMatch saves the replaced body and title, keeping the previous body
+// as a revision
+func saveSedMatch(m *sedMatch) error {
+	if m.bodyN > 0 {
+		if err := m.entry.Update(m.body); err != nil {
+			return err
+		}
+	}
+	if m.titleN > 0 {
+		if err := m.entry.SetTitle(m.title); err != nil {
+			return err
+		}
+	}
+	return m.entry.Save()
+}
+
+func printSedTotal(changed, replacements int) {
+	fmt.Printf("Made %s in %s", plural(replacements, "replacement"), plural(changed, "entry"))
+	if changed > 0 {
+		fmt.Print("; see the previous versions with jot journal history <journal> <id>")
+	}
+	fmt.Println()
+}
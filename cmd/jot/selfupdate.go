@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/veritome/jot/internal/update"
+)
+
+// Set at release build time via -ldflags "-X main.version=... -X main.releaseKey=..."
+var (
+	version    = "dev"
+	releaseKey = ""
+)
+
+func handleSelfUpdateCommand(args []string) {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	checkOnly := fs.Bool("check", false, "Only report whether an update is available")
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Error locating jot binary: %v\n", err)
+		os.Exit(1)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	if manager := update.ManagedBy(exe); manager != "" {
+		fmt.Printf("jot was installed by %s; use it to upgrade instead\n", manager)
+		os.Exit(1)
+	}
+	if version == "dev" || releaseKey == "" {
+		fmt.Println("This is a development build and cannot update itself; reinstall with `go install` instead")
+		os.Exit(1)
+	}
+
+	release, err := update.Latest()
+	if err != nil {
+		fmt.Printf("Error checking for updates: %v\n", err)
+		os.Exit(1)
+	}
+	if err := release.Verify(releaseKey); err != nil {
+		fmt.Printf("Error verifying release: %v\n", err)
+		os.Exit(1)
+	}
+	if !update.Newer(release.Version, version) {
+		fmt.Printf("jot %s is up to date\n", version)
+		return
+	}
+
+	if *checkOnly {
+		fmt.Printf("Update available: %s -> %s\n", version, release.Version)
+		return
+	}
+
+	fmt.Printf("Updating jot %s -> %s...\n", version, release.Version)
+	if err := update.Apply(release, exe); err != nil {
+		fmt.Printf("Error updating jot: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated to %s\n", release.Version)
+}
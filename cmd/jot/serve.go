@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/veritome/jot/internal/backup"
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/server"
+	"google.golang.org/grpc"
+)
+
+func handleServeCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:7777", "Address to listen on")
+	grpcAddr := fs.String("grpc-addr", "", "Also serve the gRPC API on this address")
+	rotateToken := fs.Bool("rotate-token", false, "Replace the API token before starting")
+	drain := fs.Duration("shutdown-timeout", 10*time.Second, "How long to let open requests finish when stopping")
+	delay := fs.Duration("drain-delay", 0, "How long health checks fail before new requests are refused when stopping")
+	if rest := parseArgs(fs, args); len(rest) != 0 || *delay < 0 {
+		fmt.Println("Usage: jot serve [--addr host:port] [--grpc-addr host:port] [--rotate-token] [--shutdown-timeout 10s] [--drain-delay 5s]")
+		os.Exit(1)
+	}
+
+	token, tokenPath := loadAPIToken(*rotateToken)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Serving the jot API on http://%s (token in %s)\n", *addr, tokenPath)
+	if *grpcAddr != "" {
+		fmt.Printf("Serving the gRPC API on %s\n", *grpcAddr)
+	}
+	serveAPI(ctx, *addr, *grpcAddr, token, *delay, *drain)
+	flushPendingWork()
+}
+
+// handleTokenCommand mints, lists and revokes API tokens scoped to one
+// journal, for plugins and other programs that should not get the full token
+func handleTokenCommand(args []string) {
+	usage := "Usage: jot token <new <read|append> <journal>|list|revoke <id>>"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "new":
+		if len(args) != 3 {
+			fmt.Println("Usage: jot token new <read|append> <journal>")
+			os.Exit(1)
+		}
+		token, scope, err := server.MintToken(args[2], args[1])
+		if err != nil {
+			fmt.Printf("Error creating token: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created token %s to %s journal '%s':\n%s\n", scope.ID, scope.Access, args[2], token)
+		fmt.Println("It is not shown again; revoke it with `jot token revoke` if it leaks")
+
+	case "list":
+		scopes, err := server.ScopedTokens()
+		if err != nil {
+			fmt.Printf("Error listing tokens: %v\n", err)
+			os.Exit(1)
+		}
+		if len(scopes) == 0 {
+			fmt.Println("No scoped tokens")
+			return
+		}
+		coll, err := collection.Load()
+		if err != nil {
+			fmt.Printf("Error loading collection: %v\n", err)
+			os.Exit(1)
+		}
+		for _, scope := range scopes {
+			name := coll.JournalName(scope.JournalID)
+			if name == "" {
+				name = "(deleted journal)"
+			}
+			fmt.Printf("%s  %-6s  %s  created %s\n", scope.ID, scope.Access, name, scope.Created.Format("2006-01-02"))
+		}
+
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot token revoke <id>")
+			os.Exit(1)
+		}
+		if err := server.RevokeToken(args[1]); err != nil {
+			fmt.Printf("Error revoking token: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Revoked token %s\n", args[1])
+
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
+
+// flushPendingWork brings the index up to date and takes a snapshot if
+// backup.schedule calls for one, so that stopping jot serve leaves nothing
+// for the next command to catch up on
+func flushPendingWork() {
+	if err := refreshIndex(context.Background()); err != nil {
+		fmt.Printf("Error updating index: %v\n", err)
+	}
+	snapshot, pruned, err := backup.RunDue(time.Now())
+	if err != nil {
+		fmt.Printf("Error taking snapshot: %v\n", err)
+		return
+	}
+	if snapshot != nil {
+		fmt.Printf("Saved %s\n", snapshot.Path)
+	}
+	printPruned(pruned)
+}
+
+// loadAPIToken returns the API token, replacing it first when rotate is
+// set, and the file it is kept in
+func loadAPIToken(rotate bool) (string, string) {
+	var token string
+	var err error
+	if rotate {
+		token, err = server.RotateToken()
+	} else {
+		token, err = server.LoadOrCreateToken()
+	}
+	if err != nil {
+		fmt.Printf("Error loading API token: %v\n", err)
+		os.Exit(1)
+	}
+	tokenPath, err := server.TokenPath()
+	if err != nil {
+		fmt.Printf("Error loading API token: %v\n", err)
+		os.Exit(1)
+	}
+	return token, tokenPath
+}
+
+// serveAPI serves the API on addr, and over gRPC on grpcAddr unless it is
+// empty, until ctx is cancelled. Health checks then fail for delay while
+// requests are still served, so load balancers notice and stop sending
+// them, and those already open get up to drain to finish before serveAPI
+// returns.
+func serveAPI(ctx context.Context, addr, grpcAddr, token string, delay, drain time.Duration) {
+	warnIfReachable(addr)
+	api := server.New(token)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	var rpc *grpc.Server
+	if grpcAddr != "" {
+		warnIfReachable(grpcAddr)
+		l, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			fmt.Printf("Error serving gRPC API: %v\n", err)
+			os.Exit(1)
+		}
+		rpc = api.GRPCServer()
+		go func() {
+			if err := rpc.Serve(l); err != nil {
+				fmt.Printf("Error serving gRPC API: %v\n", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		api.Drain()
+		if delay > 0 {
+			fmt.Printf("Stopping in %s\n", delay)
+			time.Sleep(delay)
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+		defer cancel()
+
+		rpcStopped := make(chan struct{})
+		go func() {
+			defer close(rpcStopped)
+			if rpc != nil {
+				rpc.GracefulStop()
+			}
+		}()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			fmt.Printf("Stopped before every request finished: %v\n", err)
+		}
+		select {
+		case <-rpcStopped:
+		case <-shutdownCtx.Done():
+			rpc.Stop()
+			fmt.Println("Stopped before every gRPC call finished")
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error serving API: %v\n", err)
+		os.Exit(1)
+	}
+	<-stopped
+}
+
+// warnIfReachable warns when addr leaves loopback, since entry bodies are
+// served decrypted
+func warnIfReachable(addr string) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			fmt.Printf("Warning: %s is reachable from other machines and traffic is not encrypted\n", addr)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleJoinJournal(args []string) {
+	if len(args) != 3 {
+		fmt.Println("Usage: jot journal join <folder> <name>")
+		os.Exit(1)
+	}
+	name := collection.NormalizeName(args[2])
+	if name == "" {
+		fmt.Println("Journal name cannot be empty")
+		os.Exit(1)
+	}
+
+	j, err := journal.JoinShared(name, args[1])
+	if errors.Is(err, journal.ErrNotMember) {
+		fmt.Printf("Error joining journal: %v\n", err)
+		publicKey, signingKey, err := journal.OwnMemberKeys()
+		if err != nil {
+			fmt.Printf("Error loading key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Ask a member to run, with the name you want to write under:")
+		fmt.Printf("  jot journal add-member <journal> <your-name> %s %s\n", publicKey, signingKey)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error joining journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Whoever can write to the folder can change the manifest, so the
+	// members new entries are encrypted to are confirmed here
+	fmt.Println("New entries will be encrypted to these members:")
+	for _, member := range j.Members {
+		fmt.Printf("  %-16s %s\n", member.Name, member.PublicKey)
+	}
+	if !confirm(false, "Are these the people you expect?", "join a shared journal") {
+		return
+	}
+	if err := journalCollection.AddJournal(j.AsType()); err != nil {
+		fmt.Printf("Error adding journal: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Joined shared journal '%s' in %s\n", name, j.SharedDir)
+}
+
+// sharedJournal returns the named journal, exiting unless it is shared
+func sharedJournal(name string) *journal.Journal {
+	j, exists := journalCollection.Journals[name]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", name)
+		os.Exit(1)
+	}
+	wrappedJ := journal.FromType(j)
+	if !wrappedJ.IsShared() {
+		fmt.Printf("Journal '%s' is not shared; create one with `jot journal new <name> --shared <folder> --as <member>`\n", name)
+		os.Exit(1)
+	}
+	return wrappedJ
+}
+
+func handleJournalMembers(args []string) {
+	fs := flag.NewFlagSet("journal members", flag.ExitOnError)
+	accept := fs.Bool("accept", false, "Confirm the members another member added or removed")
+	rest := parseArgs(fs, args[1:])
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot journal members <name> [--accept]")
+		os.Exit(1)
+	}
+	j := sharedJournal(rest[0])
+	m, added, removed, err := j.MemberChanges()
+	if err != nil {
+		fmt.Printf("Error reading shared journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	own, err := crypto.RestorePublicKey()
+	if err != nil {
+		fmt.Printf("Error loading key: %v\n", err)
+		os.Exit(1)
+	}
+	ownKey := own.PublicKeyString()
+	own.Clear()
+
+	member := false
+	for _, r := range m.Members {
+		you := ""
+		if r.PublicKey == ownKey {
+			you, member = " (you)", true
+		}
+		fmt.Printf("  %-16s %s added %s%s\n", r.Name, r.PublicKey, ui.FormatTime(r.Added), you)
+	}
+	if !member {
+		fmt.Printf("\nYour key %s is not a member; new entries cannot be written until a member adds it\n", ownKey)
+		if _, signingKey, err := journal.OwnMemberKeys(); err == nil {
+			fmt.Printf("Ask a member to run `jot journal add-member <journal> <your-name> %s %s`\n", ownKey, signingKey)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+
+	fmt.Println("\nThe members changed since you last confirmed them:")
+	for _, changed := range added {
+		fmt.Printf("  + %-14s %s\n", changed.Name, changed.PublicKey)
+	}
+	for _, changed := range removed {
+		fmt.Printf("  - %-14s %s\n", changed.Name, changed.PublicKey)
+	}
+	if signer := m.Signer(j.Members); signer != nil {
+		fmt.Printf("The change is signed by '%s'\n", signer.Name)
+	} else {
+		fmt.Println("Warning: the change is not signed by a member you confirmed; anyone with access to the folder could have made it")
+	}
+	if !*accept {
+		fmt.Printf("New entries cannot be written until you confirm them with `jot journal members %s --accept`\n", rest[0])
+		return
+	}
+	if !confirm(false, "Encrypt new entries to these members?", "confirm the members of a shared journal") {
+		return
+	}
+	if err := j.AcceptMembers(m); err != nil {
+		fmt.Printf("Error confirming members: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Confirmed the members of '%s'\n", rest[0])
+}
+
+func handleAddMember(args []string) {
+	if len(args) != 5 {
+		fmt.Println("Usage: jot journal add-member <name> <member> <public-key> <signing-key>")
+		fmt.Println("The new member's `jot journal join` prints both keys")
+		os.Exit(1)
+	}
+	j := sharedJournal(args[1])
+	if err := j.AddMember(args[2], args[3], args[4]); err != nil {
+		fmt.Printf("Error adding member: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added '%s' to '%s'\n", args[2], args[1])
+
+	// Entries written so far are re-encrypted so the new member can read them
+	updated, unreadable, err := j.Reshare()
+	if err != nil {
+		fmt.Printf("Warning: existing entries were not re-encrypted for '%s': %v\n", args[2], err)
+	} else {
+		fmt.Printf("Re-encrypted %s for the new member\n", plural(updated, "entry"))
+		if unreadable > 0 {
+			fmt.Printf("%s written before you joined could not be re-encrypted; another member can run `jot journal reshare`\n", plural(unreadable, "entry"))
+		}
+	}
+	fmt.Println("They can join with `jot journal join <folder> <name>` once the folder has synced")
+}
+
+func handleRemoveMember(args []string) {
+	if len(args) != 3 {
+		fmt.Println("Usage: jot journal remove-member <name> <member>")
+		os.Exit(1)
+	}
+	j := sharedJournal(args[1])
+	if err := j.RemoveMember(args[2]); err != nil {
+		fmt.Printf("Error removing member: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed '%s' from '%s'; entries written before now remain readable with their key\n", args[2], args[1])
+	fmt.Printf("Run `jot journal reshare %s` to re-encrypt existing entries without it\n", args[1])
+}
+
+func handleReshare(args []string) {
+	if len(args) != 2 {
+		fmt.Println("Usage: jot journal reshare <name>")
+		os.Exit(1)
+	}
+	j := sharedJournal(args[1])
+	updated, unreadable, err := j.Reshare()
+	if err != nil {
+		fmt.Printf("Error re-encrypting entries: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Re-encrypted %s for the current members of '%s'\n", plural(updated, "entry"), args[1])
+	if unreadable > 0 {
+		fmt.Printf("Skipped %s you cannot read\n", plural(unreadable, "entry"))
+	}
+}
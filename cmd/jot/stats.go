@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/mood"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// fieldFlags adds the --mood, --energy, --weather and --location filters to
+// fs. The returned function reads them once fs is parsed, exiting when a
+// range is invalid.
+func fieldFlags(fs *flag.FlagSet) func() mood.Filter {
+	moodFlag := fs.String("mood", "", "Only entries with a mood in this range: 7, 6-8, 6+ or an emoji")
+	energyFlag := fs.String("energy", "", "Only entries with an energy level in this range: 7, 6-8 or 6+")
+	weather := fs.String("weather", "", "Only entries whose weather contains this text")
+	location := fs.String("location", "", "Only entries whose location contains this text")
+	return func() mood.Filter {
+		f := mood.Filter{Weather: *weather, Location: *location}
+		for _, r := range []struct {
+			value string
+			dest  **mood.Range
+		}{{*moodFlag, &f.Mood}, {*energyFlag, &f.Energy}} {
+			if r.value == "" {
+				continue
+			}
+			parsed, err := mood.ParseRange(r.value)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			*r.dest = &parsed
+		}
+		return f
+	}
+}
+
+// matchFields reports whether the fields of e pass filter, decrypting them
+// only when the entry has any
+func matchFields(e *entry.Entry, filter mood.Filter) (bool, error) {
+	if filter.Empty() {
+		return true, nil
+	}
+	fields, err := e.GetFields()
+	if err != nil {
+		return false, err
+	}
+	return filter.Match(fields), nil
+}
+
+func handleStatsCommand(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	journalFlag := fs.String("journal", "", "Only count entries in this journal")
+	months := fs.Int("months", 12, "Number of months to summarize")
+	if rest := parseArgs(fs, args); len(rest) != 0 || *months < 1 {
+		fmt.Println("Usage: jot stats [--journal name] [--months N]")
+		os.Exit(1)
+	}
+	if ui.Discreet() {
+		fmt.Println("Moods and locations are hidden in discreet mode")
+		return
+	}
+
+	var names []string
+	if *journalFlag != "" {
+		if _, exists := journalCollection.Journals[*journalFlag]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", *journalFlag)
+			os.Exit(1)
+		}
+		names = []string{*journalFlag}
+	} else {
+		for n := range journalCollection.Journals {
+			names = append(names, n)
+		}
+	}
+
+	now := time.Now()
+	var records []mood.Record
+	for _, n := range names {
+		entries, err := journal.FromType(journalCollection.Journals[n]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			fields, err := e.GetFields()
+			if err != nil {
+				fmt.Printf("Error reading fields: %v\n", err)
+				os.Exit(1)
+			}
+			records = append(records, mood.Record{Created: e.Created, Fields: fields, Done: e.Done})
+		}
+	}
+
+	summary := mood.Monthly(records, now, *months)
+	if len(summary) == 0 {
+		fmt.Printf("No entries in the last %s\n", plural(*months, "month"))
+		return
+	}
+
+	// Completed tasks get a column once there are any
+	tasks := false
+	for _, m := range summary {
+		tasks = tasks || m.TasksDone > 0
+	}
+	done := func(m *mood.Month) string {
+		if !tasks {
+			return ""
+		}
+		return fmt.Sprintf("  %10d", m.TasksDone)
+	}
+
+	recorded := false
+	header := fmt.Sprintf("%-8s  %7s", "Month", "Entries")
+	if tasks {
+		header += fmt.Sprintf("  %10s", "Tasks done")
+	}
+	fmt.Printf("%s  %-10s  %-10s  %-14s  %s\n", header, "Mood", "Energy", "Weather", "Location")
+	for _, m := range summary {
+		fmt.Printf("%-8s  %7d%s  %-10s  %-10s  %-14s  %s\n",
+			m.Start.Format("Jan 2006"), m.Entries, done(m),
+			average(m.MoodMean, m.Moods), average(m.EnergyMean, m.Energies),
+			orDash(m.Weather), orDash(m.Location))
+		recorded = recorded || m.Moods > 0 || m.Energies > 0 || m.Weather != "" || m.Location != ""
+	}
+	if !recorded {
+		fmt.Println("\nNo moods recorded yet; add them with jot --mood 7 --energy 5 --weather rain --location home \"...\"")
+	}
+}
+
+// average renders a mean and how many values it is taken over
+func average(mean float64, n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%d)", formatNumber(mean), n)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
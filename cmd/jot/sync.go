@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/notify"
+	"github.com/veritome/jot/internal/objsync"
+	"golang.org/x/term"
+)
+
+func handleSyncCommand(args []string) {
+	pull := len(args) > 0 && args[0] == "pull"
+	if pull {
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	backend := fs.String("backend", "", "Object storage to sync with (default: sync.backend)")
+	dryRun := fs.Bool("dry-run", false, "Show what would be copied without changing anything")
+	force := fs.Bool("force", false, "Overwrite changes made on the other side")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot sync [pull] [--backend s3] [--dry-run] [--force]")
+		os.Exit(1)
+	}
+
+	remote, err := objsync.Open(*backend)
+	if err != nil {
+		syncFailed(fmt.Sprintf("Error opening bucket: %v", err))
+	}
+	opts := objsync.Options{DryRun: *dryRun, Force: *force}
+
+	if !pull {
+		result, err := objsync.Push(context.Background(), remote, opts)
+		if err == objsync.ErrRemoteChanged {
+			syncFailed(fmt.Sprintf("Error pushing to %s: %v, or --force to overwrite them", remote.URL, err))
+		}
+		if err != nil {
+			syncFailed(fmt.Sprintf("Error pushing to %s: %v", remote.URL, err))
+		}
+		printSyncResult(result, *dryRun, "upload", "Uploaded", "from the bucket")
+		if !*dryRun {
+			fmt.Printf("%s is at revision %d\n", remote.URL, result.Revision)
+		}
+		return
+	}
+
+	// A fresh machine has no keys yet; they never go to the bucket
+	if !crypto.HasKey() {
+		dir, _ := config.DataDir()
+		fmt.Printf("No key pair in %s/backup; copy your key backup there before pulling\n", dir)
+		os.Exit(1)
+	}
+	result, err := objsync.Pull(context.Background(), remote, opts)
+	if err == objsync.ErrConflict {
+		conflict := fmt.Sprintf("Error pulling from %s: %s changed both here and in the bucket", remote.URL, plural(len(result.Conflicts), "file"))
+		fmt.Printf("%s:\n", conflict)
+		for _, path := range result.Conflicts {
+			fmt.Printf("  %s\n", path)
+		}
+		fmt.Println("Nothing was changed. Push from here with --force to keep this machine's copy,")
+		fmt.Println("or pull with --force to replace it with the bucket's.")
+		alertSyncFailure(conflict)
+		os.Exit(1)
+	}
+	if err != nil {
+		syncFailed(fmt.Sprintf("Error pulling from %s: %v", remote.URL, err))
+	}
+	printSyncResult(result, *dryRun, "download", "Downloaded", "here")
+	if !*dryRun {
+		fmt.Printf("Up to date with revision %d of %s\n", result.Revision, remote.URL)
+	}
+}
+
+// syncFailed reports why jot sync failed and exits
+func syncFailed(message string) {
+	fmt.Println(message)
+	alertSyncFailure(message)
+	os.Exit(1)
+}
+
+// alertSyncFailure shows a failure as a desktop notification when no
+// terminal shows the output, such as when jot sync runs from cron
+func alertSyncFailure(message string) {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	if err := notify.Desktop("jot sync failed", message); err != nil {
+		fmt.Printf("Warning: failed to show notification: %v\n", err)
+	}
+}
+
+// printSyncResult lists the files a dry run would copy or remove, or
+// counts those a sync did
+func printSyncResult(result *objsync.Result, dryRun bool, verb, done, removedFrom string) {
+	if dryRun {
+		for _, path := range result.Copied {
+			fmt.Printf("  %s %s\n", verb, path)
+		}
+		for _, path := range result.Removed {
+			fmt.Printf("  remove %s\n", path)
+		}
+		fmt.Printf("Would %s %s and remove %s %s\n", verb, plural(len(result.Copied), "file"), plural(len(result.Removed), "file"), removedFrom)
+		return
+	}
+	if len(result.Copied)+len(result.Removed) == 0 {
+		fmt.Println("Nothing changed")
+		return
+	}
+	fmt.Printf("%s %s, removed %s %s\n", done, plural(len(result.Copied), "file"), plural(len(result.Removed), "file"), removedFrom)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleTagsCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot tags <list|find|migrate> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("tags list", flag.ExitOnError)
+		journalFlag := fs.String("journal", "", "Only count entries in this journal")
+		if rest := parseArgs(fs, args[1:]); len(rest) != 0 {
+			fmt.Println("Usage: jot tags list [--journal name]")
+			os.Exit(1)
+		}
+
+		counts := make(map[string]int)
+		for _, je := range tagEntries(*journalFlag) {
+			tags, err := je.entry.GetTags()
+			if err != nil {
+				fmt.Printf("Error reading tags: %v\n", err)
+				os.Exit(1)
+			}
+			for _, t := range tags {
+				counts[t]++
+			}
+		}
+		if len(counts) == 0 {
+			fmt.Println("No tagged entries")
+			return
+		}
+		tags := make([]string, 0, len(counts))
+		for t := range counts {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+		for _, t := range tags {
+			fmt.Printf("  #%-20s %d\n", t, counts[t])
+		}
+
+	case "find":
+		fs := flag.NewFlagSet("tags find", flag.ExitOnError)
+		journalFlag := fs.String("journal", "", "Only search this journal")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 {
+			fmt.Println("Usage: jot tags find <tag> [--journal name]")
+			os.Exit(1)
+		}
+
+		found := 0
+		for _, je := range tagEntries(*journalFlag) {
+			ok, err := je.entry.HasTag(rest[0])
+			if err != nil {
+				fmt.Printf("Error reading tags: %v\n", err)
+				os.Exit(1)
+			}
+			if ok {
+				fmt.Printf("  %s  %-12s %s\n", je.entry.ID, je.journal, ui.FormatTime(je.entry.Created))
+				found++
+			}
+		}
+		if found == 0 {
+			fmt.Printf("No entries tagged #%s\n", rest[0])
+		}
+
+	case "migrate":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot tags migrate")
+			os.Exit(1)
+		}
+		mode := entry.MetadataMode()
+		migrated, skipped := 0, 0
+		for _, je := range tagEntries("") {
+			e := je.entry
+			if e.Sealed() {
+				// Append-only entries are never rewritten
+				skipped++
+				continue
+			}
+			changed, err := e.MigrateMetadata(mode)
+			if err != nil {
+				fmt.Printf("Error migrating entry %s: %v\n", e.ID, err)
+				os.Exit(1)
+			}
+			if !changed {
+				continue
+			}
+			if err := e.Save(); err != nil {
+				fmt.Printf("Error saving entry %s: %v\n", e.ID, err)
+				os.Exit(1)
+			}
+			migrated++
+		}
+		fmt.Printf("Stored tags and titles of %d entries as %s\n", migrated, mode)
+		if skipped > 0 {
+			fmt.Printf("%d sealed entries in append-only journals keep their original storage\n", skipped)
+		}
+
+	default:
+		fmt.Printf("Unknown tags command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// tagEntries loads the entries of the named journal, or of every journal if
+// name is empty
+func tagEntries(name string) []journalEntry {
+	names := make([]string, 0, len(journalCollection.Journals))
+	if name != "" {
+		if _, exists := journalCollection.Journals[name]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", name)
+			os.Exit(1)
+		}
+		names = append(names, name)
+	} else {
+		for n := range journalCollection.Journals {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+
+	var all []journalEntry
+	for _, n := range names {
+		entries, err := journal.FromType(journalCollection.Journals[n]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries for journal '%s': %v\n", n, err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			all = append(all, journalEntry{journal: n, entry: e})
+		}
+	}
+	return all
+}
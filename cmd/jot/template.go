@@ -0,0 +1,233 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/template"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleTemplateCommand(journalName string, args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot template <list|edit|delete|use|schedule|unschedule|run|drafts> [args]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		handleTemplateList()
+
+	case "edit":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot template edit <name>")
+			os.Exit(1)
+		}
+		current, err := template.Load(args[1])
+		if err != nil {
+			current = ""
+		}
+		text, ok, err := ui.HandleCompose(fmt.Sprintf("Template %s ({date}, {weekday}, {week}, {month} and {year} are filled in)", args[1]), current)
+		if err != nil {
+			fmt.Printf("Error editing template: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok || text == current {
+			fmt.Println("No changes made")
+			return
+		}
+		if err := template.Save(args[1], text); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved template '%s'\n", args[1])
+
+	case "delete":
+		if len(args) != 2 {
+			fmt.Println("Usage: jot template delete <name>")
+			os.Exit(1)
+		}
+		for name, j := range journalCollection.Journals {
+			for _, r := range j.Recurring {
+				if r.Template == args[1] {
+					fmt.Printf("Template '%s' is scheduled in '%s'; run `jot template unschedule %s --journal %s` first\n", args[1], name, args[1], name)
+					os.Exit(1)
+				}
+			}
+		}
+		if err := template.Delete(args[1]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted template '%s'\n", args[1])
+
+	case "use":
+		fs := flag.NewFlagSet("template use", flag.ExitOnError)
+		fs.StringVar(&journalName, "journal", journalName, "Journal to write the entry in (default journal if omitted)")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 {
+			fmt.Println("Usage: jot template use <name> [--journal name]")
+			os.Exit(1)
+		}
+		text, err := template.Load(rest[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		text = template.Render(text, time.Now())
+		written, ok, err := ui.HandleCompose("New entry from template "+rest[0], text)
+		if err != nil {
+			fmt.Printf("Error composing entry: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok || written == text {
+			fmt.Println("No entry written")
+			return
+		}
+		handleNewEntry(journalName, written, nil, nil)
+
+	case "schedule":
+		fs := flag.NewFlagSet("template schedule", flag.ExitOnError)
+		fs.StringVar(&journalName, "journal", journalName, "Journal receiving the drafts (default journal if omitted)")
+		every := fs.String("every", "", "day, weekday, month or a day of the week such as monday")
+		at := fs.String("at", template.DefaultAt, "Time of day to create the draft, HH:MM")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 || *every == "" {
+			fmt.Println("Usage: jot template schedule <name> --every <day|weekday|month|monday...> [--at HH:MM] [--journal name]")
+			os.Exit(1)
+		}
+		period, err := template.ParseEvery(*every)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		clock, err := template.ParseAt(*at)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		name := goalJournal(journalName)
+		now := time.Now()
+		r, err := template.Schedule(name, rest[0], period, clock, now)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("A draft from '%s' will be added to '%s' every %s at %s, first on %s\n",
+			rest[0], name, r.Every, r.At, ui.FormatTime(template.Next(r, now)))
+		fmt.Println("Drafts are created by jot daemon, or by jot template run from a scheduler")
+
+	case "unschedule":
+		fs := flag.NewFlagSet("template unschedule", flag.ExitOnError)
+		fs.StringVar(&journalName, "journal", journalName, "Journal the template is scheduled in (default journal if omitted)")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 {
+			fmt.Println("Usage: jot template unschedule <name> [--journal name]")
+			os.Exit(1)
+		}
+		name := goalJournal(journalName)
+		if err := template.Unschedule(name, rest[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("No more drafts from '%s' in '%s'\n", rest[0], name)
+
+	case "run":
+		if len(args) != 1 {
+			fmt.Println("Usage: jot template run")
+			os.Exit(1)
+		}
+		created, err := template.RunDue(time.Now())
+		printCreatedDrafts(created)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "drafts":
+		if len(args) > 2 {
+			fmt.Println("Usage: jot template drafts [journal]")
+			os.Exit(1)
+		}
+		handleTemplateDrafts(args[1:])
+
+	default:
+		fmt.Printf("Unknown template command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleTemplateList() {
+	names, err := template.Names()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(names) == 0 {
+		fmt.Println("No templates; create one with jot template edit <name>")
+		return
+	}
+
+	journals := make([]string, 0, len(journalCollection.Journals))
+	for name := range journalCollection.Journals {
+		journals = append(journals, name)
+	}
+	sort.Strings(journals)
+
+	now := time.Now()
+	for _, name := range names {
+		fmt.Println(name)
+		for _, j := range journals {
+			for _, r := range journalCollection.Journals[j].Recurring {
+				if r.Template == name {
+					fmt.Printf("  every %s at %s in '%s', next %s\n", r.Every, r.At, j, ui.FormatTime(template.Next(r, now)))
+				}
+			}
+		}
+	}
+}
+
+// handleTemplateDrafts lists the drafts not written in yet, of one journal
+// or all of them
+func handleTemplateDrafts(args []string) {
+	var names []string
+	if len(args) == 1 {
+		names = []string{goalJournal(args[0])}
+	} else {
+		for name := range journalCollection.Journals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	found := 0
+	for _, name := range names {
+		entries, err := journal.FromType(journalCollection.Journals[name]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			if e.Draft {
+				fmt.Printf("%s  %s  %s\n", name, e.ID, ui.FormatTime(e.Created))
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		fmt.Println("No drafts")
+		return
+	}
+	fmt.Println("Fill one in with jot journal edit <journal> <id>")
+}
+
+// printCreatedDrafts reports the drafts created from schedules
+func printCreatedDrafts(created []template.Created) {
+	for _, c := range created {
+		fmt.Printf("%s Added draft %s from '%s' to '%s'\n", time.Now().Format("15:04:05"), c.EntryID, c.Template, c.Journal)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleTimestampCommand(journalName string, args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: jot timestamp <id> | verify <id>")
+		os.Exit(1)
+	}
+	if args[0] == "verify" {
+		handleVerifyTimestamps(journalName, args[1:])
+		return
+	}
+
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("timestamp", flag.ExitOnError)
+	authority := fs.String("authority", cfg.String("timestamp.authority"), "URL of the RFC 3161 timestamping authority")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 || *authority == "" {
+		fmt.Println("Usage: jot timestamp <id> [--journal name] [--authority url]")
+		os.Exit(1)
+	}
+
+	_, e := findEntry(journalName, rest[0])
+	ts, err := e.Timestamp(context.Background(), *authority)
+	if err != nil {
+		fmt.Printf("Error timestamping entry: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.Save(); err != nil {
+		fmt.Printf("Error saving entry: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Entry %s timestamped by %s at %s\n", e.ID, ts.Authority, ui.FormatTime(ts.Time))
+}
+
+func handleVerifyTimestamps(journalName string, args []string) {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("timestamp verify", flag.ExitOnError)
+	caFile := fs.String("ca", cfg.String("timestamp.ca_file"), "PEM certificates trusted to sign timestamps")
+	exportDir := fs.String("export", "", "Write each statement and token to this folder, to check them with openssl ts")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot timestamp verify <id> [--journal name] [--ca file] [--export dir]")
+		os.Exit(1)
+	}
+
+	var roots *x509.CertPool
+	if *caFile != "" {
+		pem, err := os.ReadFile(*caFile)
+		if err != nil {
+			fmt.Printf("Error reading certificates: %v\n", err)
+			os.Exit(1)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			fmt.Printf("No PEM certificates found in %s\n", *caFile)
+			os.Exit(1)
+		}
+	}
+
+	name, e := findEntry(journalName, rest[0])
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		fmt.Printf("Error decrypting entry: %v\n", err)
+		os.Exit(1)
+	}
+	stamps, err := e.Timestamps()
+	if err != nil {
+		fmt.Printf("Error reading timestamps: %v\n", err)
+		os.Exit(1)
+	}
+	if *exportDir != "" {
+		if err := os.MkdirAll(*exportDir, 0700); err != nil {
+			fmt.Printf("Error creating export folder: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Entry %s (%s), written %s\n", e.ID, name, ui.FormatTime(e.Created))
+	if len(stamps) == 0 {
+		fmt.Println("  Not timestamped")
+		os.Exit(1)
+	}
+	valid, invalid := 0, 0
+	for i, ts := range stamps {
+		status, token := e.VerifyTimestamp(ts, body, roots)
+		switch status {
+		case entry.ProofValid:
+			valid++
+		case entry.ProofInvalid:
+			invalid++
+			fmt.Printf("  %-12s %s\n", status, ts.Authority)
+			continue
+		}
+		fmt.Printf("  %-12s %s by %s (%s)\n", status, ui.FormatTime(token.Time), token.Signer.Subject.CommonName, ts.Authority)
+		if token.Untrusted != nil {
+			fmt.Printf("               authority not trusted: %v\n", token.Untrusted)
+		}
+
+		if *exportDir != "" {
+			base := filepath.Join(*exportDir, fmt.Sprintf("%s-%d", e.ID, i+1))
+			if err := os.WriteFile(base+".txt", e.TimestampStatement(ts), 0600); err != nil {
+				fmt.Printf("Error exporting timestamp: %v\n", err)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(base+".tsr", ts.Token, 0600); err != nil {
+				fmt.Printf("Error exporting timestamp: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("               openssl ts -verify -data %s.txt -in %s.tsr -token_in -CAfile <authority-ca.pem>\n", base, base)
+		}
+	}
+
+	switch {
+	case invalid > 0:
+		fmt.Printf("%s do not match the entry\n", plural(invalid, "timestamp"))
+		os.Exit(1)
+	case valid == 0:
+		fmt.Println("The entry was edited after every timestamp; see `jot journal history`")
+		os.Exit(1)
+	}
+}
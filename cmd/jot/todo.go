@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+const todoUsage = "Usage: jot [--journal name] todo <text> [--tag tag] | list [--journal name] [--done] | done <id> | reopen <id>"
+
+// task is a task entry and the journal holding it
+type task struct {
+	journal string
+	entry   *entry.Entry
+}
+
+func handleTodoCommand(journalName string, args []string) {
+	if len(args) == 0 {
+		fmt.Println(todoUsage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		handleTodoList(journalName, args[1:])
+
+	case "done", "reopen":
+		fs := flag.NewFlagSet("todo "+args[0], flag.ExitOnError)
+		name := fs.String("journal", journalName, "Journal holding the task (default: whichever does)")
+		rest := parseArgs(fs, args[1:])
+		if len(rest) != 1 {
+			fmt.Printf("Usage: jot todo %s <id> [--journal name]\n", args[0])
+			os.Exit(1)
+		}
+		setTaskDone(*name, rest[0], args[0] == "done")
+
+	default:
+		fs := flag.NewFlagSet("todo", flag.ExitOnError)
+		fs.Var(&entryTags, "tag", "Tag the task (repeatable)")
+		fs.StringVar(&entryTitle, "title", entryTitle, "Give the task a title")
+		rest := parseArgs(fs, args)
+		text := strings.TrimSpace(strings.Join(rest, " "))
+		if text == "" {
+			fmt.Println(todoUsage)
+			os.Exit(1)
+		}
+		entryTask = true
+		handleNewEntry(journalName, text, nil, nil)
+	}
+}
+
+func handleTodoList(journalName string, args []string) {
+	fs := flag.NewFlagSet("todo list", flag.ExitOnError)
+	name := fs.String("journal", journalName, "Only list the tasks of this journal")
+	done := fs.Bool("done", false, "List completed tasks instead, most recently done first")
+	if rest := parseArgs(fs, args); len(rest) != 0 {
+		fmt.Println("Usage: jot todo list [--journal name] [--done]")
+		os.Exit(1)
+	}
+
+	var names []string
+	if *name != "" {
+		if _, exists := journalCollection.Journals[*name]; !exists {
+			fmt.Printf("Journal '%s' does not exist\n", *name)
+			os.Exit(1)
+		}
+		names = []string{*name}
+	} else {
+		names = journalCollection.List()
+	}
+
+	var tasks []task
+	for _, n := range names {
+		entries, err := journal.FromType(journalCollection.Journals[n]).GetEntries()
+		if err != nil {
+			fmt.Printf("Error loading entries: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			if e.Task && (e.Done != nil) == *done {
+				tasks = append(tasks, task{n, e})
+			}
+		}
+	}
+
+	state := "open"
+	if *done {
+		state = "done"
+		sort.SliceStable(tasks, func(a, b int) bool { return tasks[a].entry.Done.After(*tasks[b].entry.Done) })
+	} else {
+		sort.SliceStable(tasks, func(a, b int) bool { return tasks[a].entry.Created.Before(tasks[b].entry.Created) })
+	}
+	if len(tasks) == 0 {
+		fmt.Printf("No %s tasks\n", state)
+		return
+	}
+	for _, t := range tasks {
+		at := t.entry.Created
+		if *done {
+			at = *t.entry.Done
+		}
+		fmt.Printf("%s  %-12s  %s  %s\n", t.entry.ID, t.journal, ui.FormatTime(at), queuePreview(t.entry))
+	}
+	fmt.Printf("%s %s\n", plural(len(tasks), "task"), state)
+}
+
+// setTaskDone completes the task with the given ID, or reopens it when done
+// is false
+func setTaskDone(journalName, id string, done bool) {
+	name, e := findEntry(journalName, id)
+	if !e.Task {
+		fmt.Printf("Entry %s in '%s' is not a task\n", id, name)
+		os.Exit(1)
+	}
+
+	var err error
+	switch {
+	case done && e.Done != nil:
+		fmt.Printf("Task %s was already done %s\n", id, ui.FormatTime(*e.Done))
+		return
+	case !done && e.Done == nil:
+		fmt.Printf("Task %s is already open\n", id)
+		return
+	case done:
+		err = e.Complete(time.Now())
+	default:
+		err = e.Reopen()
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.Save(); err != nil {
+		fmt.Printf("Error saving entry: %v\n", err)
+		os.Exit(1)
+	}
+
+	if done {
+		fmt.Printf("Done: %s (%s in '%s')\n", queuePreview(e), id, name)
+	} else {
+		fmt.Printf("Reopened: %s (%s in '%s')\n", queuePreview(e), id, name)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/trash"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// purgeExpiredTrash permanently removes journals whose restore window has passed
+func purgeExpiredTrash() {
+	purged, err := trash.PurgeExpired()
+	if err != nil {
+		fmt.Printf("Warning: failed to purge trash: %v\n", err)
+	}
+	for _, name := range purged {
+		fmt.Printf("Purged journal '%s' from the trash\n", name)
+	}
+}
+
+func handleDeleteJournal(args []string) {
+	fs := flag.NewFlagSet("journal delete", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "List what would be moved to the trash without deleting anything")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	rest := parseArgs(fs, args[1:])
+	if len(rest) != 1 {
+		fmt.Println("Usage: jot journal delete <name> [--dry-run] [--yes]")
+		os.Exit(1)
+	}
+	name := rest[0]
+
+	j, exists := journalCollection.Journals[name]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", name)
+		os.Exit(1)
+	}
+	if j.AppendOnly {
+		fmt.Printf("Error deleting journal: journal '%s' is append-only and cannot be deleted\n", name)
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		printEntryList(j)
+		fmt.Printf("Would move journal '%s' and its %s to the trash\n", name, plural(len(j.EntryIDs), "entry"))
+		return
+	}
+	if !confirmDelete(*yes, len(j.EntryIDs), fmt.Sprintf("Move journal '%s' and its %s to the trash?", name, plural(len(j.EntryIDs), "entry")), "delete a journal") {
+		return
+	}
+
+	purgeExpiredTrash()
+
+	// Archived entries go to the trash with the rest of the journal
+	thawEntries(j.EntryIDs)
+	j = journalCollection.Journals[name]
+
+	wasDefault := journalCollection.DefaultJournal == j.ID
+	item, err := trash.MoveJournal(j)
+	if err != nil {
+		fmt.Printf("Error moving journal to trash: %v\n", err)
+		os.Exit(1)
+	}
+	if err := journalCollection.RemoveJournal(name); err != nil {
+		fmt.Printf("Error deleting journal: %v\n", err)
+		os.Exit(1)
+	}
+	recordOp(&oplog.Op{Kind: oplog.JournalDelete, At: item.DeletedAt, Journal: name, JournalID: j.ID, WasDefault: wasDefault})
+
+	fmt.Printf("Moved journal '%s' to the trash; restore it with `jot journal restore %s` before %s\n",
+		name, name, ui.FormatTime(item.ExpiresAt()))
+}
+
+func handleRestoreJournal(args []string) {
+	if len(args) != 2 {
+		fmt.Println("Usage: jot journal restore <name>")
+		os.Exit(1)
+	}
+	name := args[1]
+
+	if _, exists := journalCollection.Journals[name]; exists {
+		fmt.Printf("A journal named '%s' already exists; rename or delete it first\n", name)
+		os.Exit(1)
+	}
+	if similar := journalCollection.SimilarJournal(name); similar != "" && collection.RejectSimilarNames() {
+		fmt.Printf("Journal '%s' differs from '%s' only by case or whitespace; rename or delete it first\n", similar, name)
+		os.Exit(1)
+	}
+
+	purgeExpiredTrash()
+
+	item, err := trash.Find(name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Listed first, so that a failed restore leaves the journal in the trash
+	if err := journalCollection.AddJournal(item.Journal); err != nil {
+		fmt.Printf("Error adding journal: %v\n", err)
+		os.Exit(1)
+	}
+	j, err := trash.Restore(item)
+	if err != nil {
+		journalCollection.RemoveJournal(item.Journal.Name)
+		fmt.Printf("Error restoring journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored journal '%s' with %d entries\n", name, len(j.EntryIDs))
+}
+
+func handleListTrash(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: jot journal trash")
+		os.Exit(1)
+	}
+
+	purgeExpiredTrash()
+
+	items, err := trash.List()
+	if err != nil {
+		fmt.Printf("Error reading trash: %v\n", err)
+		os.Exit(1)
+	}
+	if len(items) == 0 {
+		fmt.Println("The trash is empty")
+		return
+	}
+
+	fmt.Println("Deleted Journals:")
+	fmt.Println("-----------------")
+	for _, item := range items {
+		days := int(math.Ceil(time.Until(item.ExpiresAt()).Hours() / 24))
+		fmt.Printf("  %-20s %3d entries  deleted %s  (%d days left)\n",
+			item.Journal.Name, len(item.Journal.EntryIDs), ui.FormatTime(item.DeletedAt), days)
+	}
+}
+
+// printEntryList lists the entries of j one per line, for dry runs of
+// commands deleting them
+func printEntryList(j *types.Journal) {
+	entries, err := journal.FromType(j).GetEntries()
+	if err != nil {
+		fmt.Printf("Error loading entries: %v\n", err)
+		os.Exit(1)
+	}
+	for _, e := range entries {
+		fmt.Printf("  %s  %s  %s\n", e.ID, ui.FormatTime(e.Created), queuePreview(e))
+	}
+}
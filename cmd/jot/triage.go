@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// handleTriageCommand steps through a journal's entries in the TUI, moving,
+// tagging, starring and deleting them with single keys
+func handleTriageCommand(journalName string, args []string) {
+	if len(args) > 1 {
+		fmt.Println("Usage: jot triage [journal]")
+		os.Exit(1)
+	}
+	if len(args) == 1 {
+		journalName = args[0]
+	}
+	if journalName == "" {
+		journalName = defaultJournal()
+		if journalName == "" {
+			fmt.Println("No default journal set. Please specify a journal.")
+			os.Exit(1)
+		}
+	}
+	j, exists := journalCollection.Journals[journalName]
+	if !exists {
+		fmt.Printf("Journal '%s' does not exist\n", journalName)
+		os.Exit(1)
+	}
+	wrappedJ := journal.FromType(j)
+
+	names := make([]string, 0, len(journalCollection.Journals))
+	for name := range journalCollection.Journals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary, err := ui.HandleTriage(wrappedJ, names, func(id string, action ui.TriageAction, arg string) error {
+		return triageEntry(wrappedJ, id, action, arg)
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var done []string
+	for _, c := range []struct {
+		n    int
+		verb string
+	}{
+		{summary.Moved, "moved"},
+		{summary.Tagged, "tagged"},
+		{summary.Starred, "starred"},
+		{summary.Deleted, "deleted"},
+	} {
+		if c.n > 0 {
+			done = append(done, fmt.Sprintf("%d %s", c.n, c.verb))
+		}
+	}
+	if len(done) == 0 {
+		done = append(done, "nothing changed")
+	}
+	fmt.Printf("Triaged '%s': %s; %s left\n", journalName, strings.Join(done, ", "), plural(summary.Left, "entry"))
+}
+
+// triageEntry carries out an action chosen during triage
+func triageEntry(j *journal.Journal, id string, action ui.TriageAction, arg string) error {
+	if action == ui.TriageMove {
+		return j.MoveEntry(id, arg)
+	}
+
+	e, err := j.LoadEntry(id)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case ui.TriageDelete:
+		return j.DeleteEntry(e)
+	case ui.TriageTag, ui.TriageStar:
+		tags, err := e.GetTags()
+		if err != nil {
+			return err
+		}
+		if action == ui.TriageTag {
+			tags = append(tags, strings.Split(arg, ",")...)
+		} else if starred, _ := e.HasTag(ui.StarTag); starred {
+			kept := tags[:0]
+			for _, t := range tags {
+				if t != ui.StarTag {
+					kept = append(kept, t)
+				}
+			}
+			tags = kept
+		} else {
+			tags = append(tags, ui.StarTag)
+		}
+		if err := e.SetTags(tags); err != nil {
+			return err
+		}
+		return e.Save()
+	}
+	return fmt.Errorf("unknown triage action %d", action)
+}
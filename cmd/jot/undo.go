@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/trash"
+	"github.com/veritome/jot/internal/ui"
+)
+
+// goneError is returned when what an operation changed has changed again
+// since, so there is nothing left to undo
+type goneError struct {
+	reason string
+}
+
+func (e *goneError) Error() string {
+	return e.reason
+}
+
+// gone returns a goneError giving the reason
+func gone(format string, args ...interface{}) error {
+	return &goneError{reason: fmt.Sprintf(format, args...)}
+}
+
+// recordOp adds an operation to the undo history, warning when it cannot
+func recordOp(op *oplog.Op) {
+	if err := oplog.Record(op); err != nil {
+		fmt.Printf("Warning: failed to record operation for jot undo: %v\n", err)
+	}
+}
+
+// handleUndoCommand reverses the most recent recorded operation, or lists
+// the operations that can be undone
+func handleUndoCommand(args []string) {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "--list") {
+		fmt.Println("Usage: jot undo [--list]")
+		os.Exit(1)
+	}
+
+	if len(args) == 1 {
+		ops, err := oplog.List()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(ops) == 0 {
+			fmt.Println("Nothing to undo")
+			return
+		}
+		fmt.Println("Most recent first:")
+		for _, op := range ops {
+			fmt.Printf("  %s  %s\n", ui.FormatTime(op.At), op)
+		}
+		return
+	}
+
+	op, err := oplog.Last()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if op == nil {
+		fmt.Println("Nothing to undo")
+		return
+	}
+
+	err = undo(op)
+	var g *goneError
+	if errors.As(err, &g) {
+		if err := op.Drop(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cannot undo: %s, since %v; forgot it. Run jot undo again for the operation before\n", op, g)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error undoing: %s: %v\n", op, err)
+		os.Exit(1)
+	}
+	if err := op.Drop(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Undid: %s\n", op)
+}
+
+// undo reverses op
+func undo(op *oplog.Op) error {
+	switch op.Kind {
+	case oplog.EntryCreate:
+		return undoEntryCreate(op)
+	case oplog.EntryDelete:
+		return undoEntryDelete(op)
+	case oplog.JournalDelete:
+		return undoJournalDelete(op)
+	case oplog.DefaultChange:
+		return undoDefaultChange(op)
+	case oplog.EntryChange:
+		return undoEntryChange(op)
+	}
+	return fmt.Errorf("unknown operation %s", op.Kind)
+}
+
+// opJournal returns the journal an operation was on, found by ID so a
+// rename in between does not matter
+func opJournal(op *oplog.Op) (*journal.Journal, error) {
+	j, exists := journalCollection.JournalByID(op.JournalID)
+	if !exists {
+		return nil, gone("journal '%s' no longer exists", op.Journal)
+	}
+	return journal.FromType(j), nil
+}
+
+func undoEntryCreate(op *oplog.Op) error {
+	j, err := opJournal(op)
+	if err != nil {
+		return err
+	}
+	listed := false
+	for _, id := range j.EntryIDs {
+		listed = listed || id == op.EntryID
+	}
+	if !listed {
+		return gone("entry %s is no longer in '%s'", op.EntryID, j.Name)
+	}
+
+	e, err := entry.Load(op.EntryID)
+	if err != nil {
+		return err
+	}
+	// The journal lets go of the entry first, so that a failure never
+	// leaves it listing a missing file
+	if err := j.RemoveEntry(op.EntryID); err != nil {
+		return err
+	}
+	return e.Delete()
+}
+
+func undoEntryDelete(op *oplog.Op) error {
+	j, err := opJournal(op)
+	if err != nil {
+		return err
+	}
+	if err := op.RestoreEntry(); err != nil {
+		return err
+	}
+	if err := j.AddEntry(op.EntryID); err != nil {
+		return err
+	}
+
+	// Saving again puts the entry back in the search index
+	e, err := entry.Load(op.EntryID)
+	if err != nil {
+		return err
+	}
+	return e.Save()
+}
+
+func undoJournalDelete(op *oplog.Op) error {
+	items, err := trash.List()
+	if err != nil {
+		return err
+	}
+	var item *trash.Item
+	for _, i := range items {
+		if i.Journal.ID == op.JournalID && i.DeletedAt.Equal(op.At) {
+			item = i
+		}
+	}
+	if item == nil {
+		return gone("journal '%s' is no longer in the trash", op.Journal)
+	}
+
+	if _, exists := journalCollection.Journals[op.Journal]; exists {
+		return fmt.Errorf("a journal named '%s' exists; rename or delete it first", op.Journal)
+	}
+
+	// The journal is listed again before its entries leave the trash, and
+	// taken out again if they cannot, so that it is never lost from both
+	if err := journalCollection.AddJournal(item.Journal); err != nil {
+		return err
+	}
+	j, err := trash.Restore(item)
+	if err != nil {
+		if rmErr := journalCollection.RemoveJournal(item.Journal.Name); rmErr != nil {
+			return fmt.Errorf("%v; journal '%s' is listed but its entries are still in the trash: %w", err, item.Journal.Name, rmErr)
+		}
+		return err
+	}
+	if op.WasDefault {
+		return journalCollection.SetDefaultJournal(j.Name)
+	}
+	return nil
+}
+
+func undoDefaultChange(op *oplog.Op) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		if coll.DefaultJournal != op.JournalID {
+			return gone("the default journal was changed again")
+		}
+		if _, exists := coll.JournalByID(op.PreviousDefault); op.PreviousDefault != "" && !exists {
+			return gone("journal '%s' no longer exists", op.PreviousName)
+		}
+		coll.DefaultJournal = op.PreviousDefault
+		return nil
+	})
+	return err
+}
+
+func undoEntryChange(op *oplog.Op) error {
+	changed, err := op.EntryChangedSince()
+	if err != nil {
+		return err
+	}
+	if changed {
+		return gone("entry %s was changed or deleted again", op.EntryID)
+	}
+
+	if op.MovedFromID != "" {
+		j, err := opJournal(op)
+		if err != nil {
+			return err
+		}
+		from, exists := journalCollection.JournalByID(op.MovedFromID)
+		if !exists {
+			return gone("journal '%s' no longer exists", op.MovedFrom)
+		}
+		if err := j.MoveEntry(op.EntryID, from.Name); err != nil {
+			return err
+		}
+	}
+	if err := op.RevertEntry(); err != nil {
+		return err
+	}
+
+	// Saving again puts the previous date and tags back in the search index
+	e, err := entry.Load(op.EntryID)
+	if err != nil {
+		return err
+	}
+	return e.Save()
+}
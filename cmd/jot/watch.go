@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/watch"
+)
+
+func handleWatchCommand(journalName string, args []string) {
+	cfg, err := config.Current()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	dirFlag := fs.String("dir", cfg.String("watch.dir"), "Folder or named pipe to ingest from (default <data dir>/inbox)")
+	journalFlag := fs.String("journal", journalName, "Journal that receives the entries")
+	interval := fs.Duration("interval", 2*time.Second, "How often to check the folder")
+	if rest := parseArgs(fs, args); len(rest) != 0 || *interval <= 0 {
+		fmt.Println("Usage: jot watch [--dir path] [--journal name] [--interval 2s]")
+		os.Exit(1)
+	}
+
+	dir := *dirFlag
+	if dir == "" {
+		jotDir, err := config.DataDir()
+		if err != nil {
+			fmt.Printf("Error getting data directory: %v\n", err)
+			os.Exit(1)
+		}
+		dir = filepath.Join(jotDir, "inbox")
+	}
+
+	target := *journalFlag
+	if target == "" {
+		target = cfg.String("watch.journal")
+	}
+	if target == "" {
+		target = defaultJournal()
+	}
+	if target == "" {
+		fmt.Println("No default journal set. Please specify a journal with --journal or set watch.journal.")
+		os.Exit(1)
+	}
+	if _, exists := journalCollection.Journals[target]; !exists {
+		fmt.Printf("Journal '%s' does not exist\n", target)
+		os.Exit(1)
+	}
+
+	logf := func(format string, args ...interface{}) {
+		fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+	}
+	ingest := func(text, name string) error {
+		// Reload so changes made by other jot processes are not overwritten
+		coll, err := collection.Load()
+		if err != nil {
+			return err
+		}
+		j, exists := coll.Journals[target]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", target)
+		}
+		wrappedJ := journal.FromType(j)
+		if !allowDuplicates {
+			dup, err := wrappedJ.FindDuplicate(text, time.Now())
+			if err != nil {
+				return err
+			}
+			if dup != nil {
+				logf("Skipped %s: same text as entry %s", name, dup.ID)
+				return nil
+			}
+		}
+		e, err := wrappedJ.NewEntry(text)
+		if err != nil {
+			return err
+		}
+		if err := wrappedJ.SaveEntry(e); err != nil {
+			return err
+		}
+		logf("Added entry %s to '%s' from %s", e.ID, target, name)
+		warnQuota(wrappedJ.Journal)
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if watch.IsFIFO(dir) {
+		fmt.Printf("Reading entries for '%s' from pipe %s\n", target, dir)
+		err = watch.FIFO(ctx, dir, ingest, logf)
+	} else {
+		fmt.Printf("Watching %s for entries for '%s'\n", dir, target)
+		err = watch.Dir(ctx, dir, *interval, ingest, logf)
+	}
+	if err != nil {
+		fmt.Printf("Error watching %s: %v\n", dir, err)
+		os.Exit(1)
+	}
+}
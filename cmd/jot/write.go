@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veritome/jot/internal/ui"
+)
+
+func handleWriteCommand(journalName string, args []string) {
+	fs := flag.NewFlagSet("write", flag.ExitOnError)
+	goal := fs.Int("goal", 750, "Number of words to aim for")
+	if rest := parseArgs(fs, args); len(rest) != 0 || *goal < 1 {
+		fmt.Println("Usage: jot [-journal <name>] write [--goal words]")
+		os.Exit(1)
+	}
+
+	result, ok, err := ui.HandleWritingSession(*goal)
+	if err != nil {
+		fmt.Printf("Error running writing session: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		fmt.Println("Nothing saved, session discarded")
+		return
+	}
+
+	handleEntry(journalName, result.Text)
+	if result.Reached {
+		fmt.Printf("Goal reached: %d words in %s\n", result.Words, result.Duration)
+	} else {
+		fmt.Printf("Saved %d of %d words in %s\n", result.Words, *goal, result.Duration)
+	}
+}
+
+// handleNewCommand writes an entry in the editor, for when the text is not
+// given on the command line
+func handleNewCommand(journalName string, args []string) {
+	if len(args) != 0 {
+		fmt.Println("Usage: jot [-journal <name>] new")
+		os.Exit(1)
+	}
+
+	text, ok, err := ui.HandleCompose("New entry", "")
+	if err != nil {
+		fmt.Printf("Error composing entry: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok || strings.TrimSpace(text) == "" {
+		fmt.Println("Nothing written, entry discarded")
+		return
+	}
+
+	handleEntry(journalName, text)
+}
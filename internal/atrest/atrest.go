@@ -0,0 +1,278 @@
+// Package atrest encrypts every file jot stores in the data directory. Each
+// file is sealed under a data key of its own, and the data key is wrapped
+// by a master key kept with the key pairs, so that reading and writing need
+// neither the private key nor the security key that may guard it. A copy of
+// the master key, sealed to the key pair of every device, is stored as
+// master.key in the data directory for machines that do not have it yet.
+//
+// Wrap puts the encryption beneath the storage package, so a file added to
+// the data directory is encrypted without its code doing anything. Only the
+// files listed in plaintext stay readable, such as the keys themselves and
+// the files users edit by hand.
+package atrest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/storage"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// Sealed files are laid out as
+//
+//	"JOTR" | version | master key ID | nonce | wrapped data key | nonce | secretbox
+//
+// where the data key is wrapped with secretbox under the master key with
+// that ID, the first bytes of its SHA-256 hash
+const (
+	magic       = "JOTR"
+	version     = 1
+	keyIDSize   = 8
+	wrappedSize = 32 + secretbox.Overhead
+	headerSize  = len(magic) + 1 + keyIDSize + 24 + wrappedSize + 24
+)
+
+// plaintext lists the entries of the data directory left unencrypted: the
+// keys and the sealed copy of the master key, which encrypt the rest, the
+// files users write themselves, the server token other programs read,
+// snapshots, already encrypted to the key pair, and the lock and sync
+// state, which hold nothing of the journals
+var plaintext = map[string]bool{
+	"backup":        true,
+	masterFile:      true,
+	"jot.lock":      true,
+	"prompts.txt":   true,
+	"checkins":      true,
+	"templates":     true,
+	"anonymize.txt": true,
+	"inbox":         true,
+	"server.token":  true,
+	"snapshots":     true,
+	"sync.json":     true,
+}
+
+// IsSealed reports whether data is a sealed file
+func IsSealed(data []byte) bool {
+	return len(data) >= headerSize && string(data[:len(magic)]) == magic && data[len(magic)] == version
+}
+
+// Sealable reports whether the file at path is encrypted when stored: it is
+// in the data directory, outside the key directory, and not one of the
+// files kept in plaintext
+func Sealable(path string) bool {
+	dir, err := config.DataDir()
+	if err != nil {
+		return false
+	}
+	rel, ok := within(dir, path)
+	if !ok {
+		return false
+	}
+	if keyDir, err := crypto.KeyPairDir(); err == nil {
+		if _, inKeys := within(keyDir, path); inKeys {
+			return false
+		}
+	}
+	top := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+	return rel != "." && !plaintext[top]
+}
+
+// within returns path relative to dir, reporting whether it is inside it
+func within(dir, path string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// Seal encrypts data under a fresh data key wrapped by the current master
+// key, generating the master key on first use
+func Seal(data []byte) ([]byte, error) {
+	master, err := currentKey()
+	if err != nil {
+		return nil, err
+	}
+	return seal(data, master)
+}
+
+func seal(data []byte, master *[32]byte) ([]byte, error) {
+	var dataKey [32]byte
+	if _, err := rand.Read(dataKey[:]); err != nil {
+		return nil, fmt.Errorf("key generation failed: %w", err)
+	}
+	defer clear32(&dataKey)
+
+	var wrapNonce, nonce [24]byte
+	if _, err := rand.Read(wrapNonce[:]); err != nil {
+		return nil, fmt.Errorf("nonce generation failed: %w", err)
+	}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("nonce generation failed: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.Grow(headerSize + len(data) + secretbox.Overhead)
+	out.WriteString(magic)
+	out.WriteByte(version)
+	id := keyID(master)
+	out.Write(id[:])
+	out.Write(secretbox.Seal(wrapNonce[:], dataKey[:], &wrapNonce, master))
+	out.Write(secretbox.Seal(nonce[:], data, &nonce, &dataKey))
+	return out.Bytes(), nil
+}
+
+// Open decrypts a sealed file with the master key it was sealed under
+func Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, fmt.Errorf("not a sealed file")
+	}
+	var id [keyIDSize]byte
+	copy(id[:], data[len(magic)+1:])
+	master, err := findKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[len(magic)+1+keyIDSize:]
+	var wrapNonce, nonce [24]byte
+	copy(wrapNonce[:], rest[:24])
+	keyBytes, ok := secretbox.Open(nil, rest[24:24+wrappedSize], &wrapNonce, master)
+	if !ok || len(keyBytes) != 32 {
+		return nil, fmt.Errorf("decryption failed")
+	}
+	var dataKey [32]byte
+	copy(dataKey[:], keyBytes)
+	defer clear32(&dataKey)
+
+	rest = rest[24+wrappedSize:]
+	copy(nonce[:], rest[:24])
+	plain, ok := secretbox.Open(nil, rest[24:], &nonce, &dataKey)
+	if !ok {
+		return nil, fmt.Errorf("decryption failed")
+	}
+	return plain, nil
+}
+
+// Subkey returns a key for purpose derived from the current master key,
+// generating the master key on first use. It keys hashes stored in the
+// data directory, which must not be keyed by anything stored there too.
+func Subkey(purpose string) ([]byte, error) {
+	master, err := currentKey()
+	if err != nil {
+		return nil, err
+	}
+	return subkey(master, purpose), nil
+}
+
+func subkey(master *[32]byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, master[:])
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// keyID identifies a master key in the files sealed under it
+func keyID(key *[32]byte) [keyIDSize]byte {
+	sum := sha256.Sum256(key[:])
+	var id [keyIDSize]byte
+	copy(id[:], sum[:])
+	return id
+}
+
+func clear32(key *[32]byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
+
+// ErrUnsealed is returned for a file stored in plaintext where a sealed one
+// belongs. Files written before encryption at rest are only read as they
+// are until this machine has a master key; after that, one may just as
+// well have been put in the sync folder by someone without the key.
+var ErrUnsealed = errors.New("not encrypted at rest; if an earlier jot wrote it, run jot doctor --fix")
+
+// Backend encrypts the files it stores in the data directory and decrypts
+// them when read, leaving everything else to the backend it wraps
+type Backend struct {
+	storage.Backend
+}
+
+// Wrap returns b encrypting the data directory. The master keys are read
+// from b afresh.
+func Wrap(b storage.Backend) *Backend {
+	mu.Lock()
+	cache = make(map[string][]*[32]byte)
+	mu.Unlock()
+	return &Backend{Backend: b}
+}
+
+func (b *Backend) ReadFile(name string) ([]byte, error) {
+	data, err := b.Backend.ReadFile(name)
+	if err != nil || !Sealable(name) {
+		return data, err
+	}
+	if !IsSealed(data) {
+		if err := b.checkUnsealed(name, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	plain, err := Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", filepath.Base(name), err)
+	}
+	return plain, nil
+}
+
+// WriteFile seals data first, unless it is sealed already, such as a file
+// copied from another data directory
+func (b *Backend) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if len(data) > 0 && !IsSealed(data) && Sealable(name) {
+		sealed, err := Seal(data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", filepath.Base(name), err)
+		}
+		data = sealed
+	}
+	return b.Backend.WriteFile(name, data, perm)
+}
+
+// checkUnsealed refuses the plaintext data of name once this machine has a
+// master key, or the sealed copy of one, unless Migrate is sealing it.
+// Empty files, which reserve entry IDs, hold nothing to trust.
+func (b *Backend) checkUnsealed(name string, data []byte) error {
+	if len(data) == 0 || migrating(name) {
+		return nil
+	}
+	var paths []string
+	if dir, err := crypto.KeyPairDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, keyFile))
+	}
+	if path, err := masterPath(); err == nil {
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if _, err := b.Backend.Stat(path); err == nil {
+			return fmt.Errorf("%s is %w", filepath.Base(name), ErrUnsealed)
+		}
+	}
+	return nil
+}
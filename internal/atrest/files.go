@@ -0,0 +1,119 @@
+package atrest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// Files returns every file of the data directory that is sealed when
+// stored. Hidden files, such as those being written, and the empty files
+// reserving entry IDs are left out.
+func Files() ([]string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	var files []string
+	var walk func(string) error
+	walk = func(path string) error {
+		entries, err := storage.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			child := filepath.Join(path, e.Name())
+			if strings.HasPrefix(e.Name(), ".") || !Sealable(child) {
+				continue
+			}
+			if e.IsDir() {
+				if err := walk(child); err != nil {
+					return err
+				}
+				continue
+			}
+			if info, err := e.Info(); err == nil && info.Mode().IsRegular() && info.Size() > 0 {
+				files = append(files, child)
+			}
+		}
+		return nil
+	}
+	if err := walk(dir); err != nil {
+		return nil, fmt.Errorf("failed to list data directory: %w", err)
+	}
+	return files, nil
+}
+
+// unsealed holds the files Migrate is sealing, which are read in
+// plaintext meanwhile
+var (
+	unsealedMu sync.Mutex
+	unsealed   = make(map[string]bool)
+)
+
+func migrating(path string) bool {
+	unsealedMu.Lock()
+	defer unsealedMu.Unlock()
+	return unsealed[path]
+}
+
+// Migrate seals files stored in plaintext, as written before encryption at
+// rest, which are refused with ErrUnsealed otherwise. Only the user can
+// tell such a file from one someone put in the sync folder, so it is left
+// to jot doctor --fix.
+func Migrate(paths []string) error {
+	unsealedMu.Lock()
+	for _, path := range paths {
+		unsealed[path] = true
+	}
+	unsealedMu.Unlock()
+	defer func() {
+		unsealedMu.Lock()
+		for _, path := range paths {
+			delete(unsealed, path)
+		}
+		unsealedMu.Unlock()
+	}()
+	return SealFiles(paths)
+}
+
+// SealFiles seals each of the files again under the current master key,
+// keeping its modification time. The data lock is held meanwhile, so none
+// changes underneath.
+func SealFiles(paths []string) error {
+	dir, err := config.DataDir()
+	if err != nil {
+		return fmt.Errorf("failed to get data directory: %w", err)
+	}
+	lock, err := storage.LockDir(dir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	for _, path := range paths {
+		info, err := storage.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		data, err := storage.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		sealed, err := Seal(data)
+		if err != nil {
+			return err
+		}
+		if err := storage.WriteFile(path, sealed, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if err := storage.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
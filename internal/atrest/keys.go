@@ -0,0 +1,404 @@
+package atrest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/device"
+	"github.com/veritome/jot/internal/storage"
+)
+
+const (
+	// keyFile, in the key directory, holds the master keys, one per line,
+	// the current one first. Earlier ones are kept for files sealed before
+	// a rotation, such as those synced in from another machine. With a
+	// security key enrolled, the lines are wrapped like the private keys
+	// and follow wrappedPrefix.
+	keyFile       = "jot.master"
+	wrappedPrefix = "fido2 "
+	// masterFile, in the data directory, holds keyFile sealed to the key
+	// pair of this machine and of every device
+	masterFile = "master.key"
+)
+
+// errNoKey is returned for a key file without keys, such as one another
+// jot process is still writing
+var errNoKey = errors.New("no master key")
+
+var (
+	mu    sync.Mutex
+	cache = make(map[string][]*[32]byte) // Key directory -> its master keys
+)
+
+// currentKey returns the master key new files are sealed under
+func currentKey() (*[32]byte, error) {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return nil, err
+	}
+	keys, generated, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if generated {
+		if err := Share(); err != nil {
+			return nil, err
+		}
+	}
+	return keys[0], nil
+}
+
+// findKey returns the master key with the given ID. A key this machine does
+// not have is looked for in the sealed copy, which another machine may have
+// rotated.
+func findKey(id [keyIDSize]byte) (*[32]byte, error) {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return nil, err
+	}
+	keys, _, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if key := match(keys, id); key != nil {
+		return key, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	shared, err := unshare()
+	if err != nil {
+		return nil, err
+	}
+	key := match(shared, id)
+	if key == nil {
+		return nil, fmt.Errorf("sealed under a master key this machine does not have; copy %s from the machine that wrote it", keyFile)
+	}
+	merged := merge(shared, cache[dir])
+	if err := writeKeys(filepath.Join(dir, keyFile), merged); err != nil {
+		return nil, err
+	}
+	cache[dir] = merged
+	return key, nil
+}
+
+// match returns the key among keys with the given ID, or nil
+func match(keys []*[32]byte, id [keyIDSize]byte) *[32]byte {
+	for _, key := range keys {
+		if keyID(key) == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// merge returns the keys of first followed by those of second it lacks
+func merge(first, second []*[32]byte) []*[32]byte {
+	merged := append([]*[32]byte{}, first...)
+	for _, key := range second {
+		if match(merged, keyID(key)) == nil {
+			merged = append(merged, key)
+		}
+	}
+	return merged
+}
+
+// load returns the master keys of the key directory dir. On first use, they
+// are taken from the sealed copy in the data directory, which needs the
+// private key once, or else generated, which generated reports.
+func load(dir string) (keys []*[32]byte, generated bool, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if keys, ok := cache[dir]; ok {
+		return keys, false, nil
+	}
+
+	path := filepath.Join(dir, keyFile)
+	keys, err = readKeys(path)
+	if os.IsNotExist(err) || errors.Is(err, errNoKey) {
+		keys, generated, err = create(path)
+	} else if err == nil {
+		err = wrapKeys(path, keys)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	cache[dir] = keys
+	return keys, generated, nil
+}
+
+// create writes the key file at path, reserving it first so that two jot
+// processes starting at once do not each make a master key of their own
+func create(path string) ([]*[32]byte, bool, error) {
+	if err := storage.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := storage.CreateExclusive(path, 0600); err != nil {
+		if !os.IsExist(err) {
+			return nil, false, fmt.Errorf("failed to create master key: %w", err)
+		}
+		// Another jot process is writing it
+		for i := 0; i < 50; i++ {
+			time.Sleep(100 * time.Millisecond)
+			if keys, err := readKeys(path); err == nil {
+				return keys, false, nil
+			}
+		}
+		return nil, false, fmt.Errorf("%s is empty; remove it if no other jot process is running", path)
+	}
+
+	keys, err := unshare()
+	generated := false
+	if err == nil && keys == nil {
+		var key *[32]byte
+		if key, err = generate(); err == nil {
+			keys, generated = []*[32]byte{key}, true
+		}
+	}
+	if err == nil {
+		err = writeKeys(path, keys)
+	}
+	if err != nil {
+		storage.Remove(path)
+		return nil, false, err
+	}
+	return keys, generated, nil
+}
+
+func generate() (*[32]byte, error) {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, fmt.Errorf("key generation failed: %w", err)
+	}
+	return &key, nil
+}
+
+// readKeys reads a key file, unwrapping it with the security key if
+// needed
+func readKeys(path string) ([]*[32]byte, error) {
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	if wrapped, ok := strings.CutPrefix(text, wrappedPrefix); ok {
+		if text, err = crypto.UnwrapSecret(wrapped); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	keys, err := parseKeys(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// wrapKeys writes the key file at path again if a security key was
+// enrolled since it was written in plaintext
+func wrapKeys(path string, keys []*[32]byte) error {
+	if !crypto.FIDO2Enrolled() {
+		return nil
+	}
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read master key: %w", err)
+	}
+	if strings.HasPrefix(string(data), wrappedPrefix) {
+		return nil
+	}
+	return writeKeys(path, keys)
+}
+
+// WrapKeys wraps the master keys with the security key just enrolled, as
+// the private keys are
+func WrapKeys() error {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return err
+	}
+	keys, _, err := load(dir)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return wrapKeys(filepath.Join(dir, keyFile), keys)
+}
+
+// parseKeys reads Base64 keys, one per line
+func parseKeys(text string) ([]*[32]byte, error) {
+	var keys []*[32]byte
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid master key")
+		}
+		var key [32]byte
+		copy(key[:], b)
+		keys = append(keys, &key)
+	}
+	if len(keys) == 0 {
+		return nil, errNoKey
+	}
+	return keys, nil
+}
+
+func formatKeys(keys []*[32]byte) string {
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(base64.StdEncoding.EncodeToString(key[:]))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func writeKeys(path string, keys []*[32]byte) error {
+	text := formatKeys(keys)
+	if crypto.FIDO2Enrolled() {
+		wrapped, err := crypto.WrapSecret(text)
+		if err != nil {
+			return err
+		}
+		text = wrappedPrefix + wrapped + "\n"
+	}
+	if err := storage.WriteFile(path, []byte(text), 0600); err != nil {
+		return fmt.Errorf("failed to write master key: %w", err)
+	}
+	return nil
+}
+
+// masterPath returns the location of the sealed copy of the master keys
+func masterPath() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(dir, masterFile), nil
+}
+
+// unshare opens the sealed copy of the master keys with the private keys,
+// returning nil when there is none
+func unshare() ([]*[32]byte, error) {
+	path, err := masterPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", masterFile, err)
+	}
+	return open(data)
+}
+
+// open opens a sealed copy of the master keys with the private keys
+func open(sealed []byte) ([]*[32]byte, error) {
+	keyring, err := crypto.Keyring()
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearAll(keyring)
+	text, err := crypto.DecryptWithKeyring(sealed, keyring)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", masterFile, err)
+	}
+	keys, err := parseKeys(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", masterFile, err)
+	}
+	return keys, nil
+}
+
+// Share seals the master keys to the key pair of this machine and of every
+// device that is not revoked, for machines that do not have them yet. It is
+// called again whenever one of those keys changes.
+func Share() error {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return err
+	}
+	keys, _, err := load(dir)
+	if err != nil {
+		return err
+	}
+	own, err := crypto.RestorePublicKey()
+	if err != nil {
+		return err
+	}
+	defer own.Clear()
+	devices, err := device.OtherKeys()
+	if err != nil {
+		return err
+	}
+	sealed, err := crypto.EncryptFor(formatKeys(keys), own, devices)
+	if err != nil {
+		return fmt.Errorf("failed to seal master key: %w", err)
+	}
+	path, err := masterPath()
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFile(path, sealed, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", masterFile, err)
+	}
+	return nil
+}
+
+// Rotate makes a new master key the current one and seals every file of
+// the data directory under it, then shares it, returning the number of
+// files sealed. Earlier master keys are kept, for files synced in from
+// machines that still used them.
+func Rotate() (int, error) {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return 0, err
+	}
+	keys, _, err := load(dir)
+	if err != nil {
+		return 0, err
+	}
+	key, err := generate()
+	if err != nil {
+		return 0, err
+	}
+
+	mu.Lock()
+	rotated := merge([]*[32]byte{key}, keys)
+	err = writeKeys(filepath.Join(dir, keyFile), rotated)
+	if err == nil {
+		cache[dir] = rotated
+	}
+	mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := Share(); err != nil {
+		return 0, err
+	}
+	files, err := Files()
+	if err != nil {
+		return 0, err
+	}
+	if err := SealFiles(files); err != nil {
+		return 0, err
+	}
+	return len(files), nil
+}
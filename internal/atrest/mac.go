@@ -0,0 +1,99 @@
+package atrest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/veritome/jot/internal/crypto"
+)
+
+// ErrNoMasterKey is returned by MAC on a machine without a master key
+var ErrNoMasterKey = errors.New("this machine has no master key yet")
+
+// MAC returns an HMAC-SHA256 of data, in hex, under the subkey for purpose
+// of the current master key. Unlike Seal it never generates a master key:
+// data authenticated by a key no other machine has is of no use.
+func MAC(purpose string, data []byte) (string, error) {
+	keys, err := known()
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", ErrNoMasterKey
+	}
+	return macWith(keys[0], purpose, data), nil
+}
+
+// CheckMAC reports whether mac was made by MAC for data under any master
+// key this machine has. Keys of sealedMaster, a sealed copy of the master
+// keys such as one synced in, are tried too when this machine has none yet,
+// or when the copy also holds a key it has: a copy made by someone without
+// the master key can then not vouch for data, unless this machine never
+// had one.
+func CheckMAC(purpose string, data []byte, mac string, sealedMaster []byte) (bool, error) {
+	keys, err := known()
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		if hmac.Equal([]byte(macWith(key, purpose, data)), []byte(mac)) {
+			return true, nil
+		}
+	}
+	if sealedMaster == nil {
+		return false, nil
+	}
+
+	shared, err := open(sealedMaster)
+	if err != nil {
+		return false, err
+	}
+	if len(keys) > 0 {
+		vouched := false
+		for _, key := range keys {
+			vouched = vouched || match(shared, keyID(key)) != nil
+		}
+		if !vouched {
+			return false, nil
+		}
+	}
+	for _, key := range shared {
+		if hmac.Equal([]byte(macWith(key, purpose, data)), []byte(mac)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func macWith(master *[32]byte, purpose string, data []byte) string {
+	mac := hmac.New(sha256.New, subkey(master, purpose))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// known returns the master keys of this machine without generating any,
+// nil when it has none yet
+func known() ([]*[32]byte, error) {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if keys, ok := cache[dir]; ok {
+		return keys, nil
+	}
+	keys, err := readKeys(filepath.Join(dir, keyFile))
+	if os.IsNotExist(err) || errors.Is(err, errNoKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cache[dir] = keys
+	return keys, nil
+}
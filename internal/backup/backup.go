@@ -0,0 +1,377 @@
+// Package backup takes encrypted snapshots of the data directory on a
+// schedule and prunes old ones
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/fsutil"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/when"
+)
+
+const (
+	// snapshotDir holds the snapshots when backup.dir is not set
+	snapshotDir = "snapshots"
+	// keyDir holds the key pairs, which are never part of a snapshot
+	keyDir = "backup"
+
+	snapshotPrefix = "jot-"
+	snapshotSuffix = ".snapshot"
+	snapshotLayout = "20060102-150405"
+)
+
+// skipped are files of the data directory that only make sense while jot
+// runs
+var skipped = map[string]bool{fsutil.LockFile: true, "agent.sock": true}
+
+// Snapshot is an encrypted copy of the data directory
+type Snapshot struct {
+	Path  string
+	Taken time.Time
+	Size  int64
+}
+
+// Dir returns the directory holding the snapshots: backup.dir, or
+// snapshots/ in the data directory
+func Dir() (string, error) {
+	cfg, err := config.Current()
+	if err != nil {
+		return "", err
+	}
+	if dir := cfg.String("backup.dir"); dir != "" {
+		return config.ExpandHome(dir)
+	}
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, snapshotDir), nil
+}
+
+// Take writes a snapshot of the data directory, without the keys, as a
+// gzipped tar archive encrypted to your public key and every extra
+// recipient. Only public keys are needed, so it runs unattended.
+func Take(now time.Time) (*Snapshot, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, snapshotPrefix+now.Local().Format(snapshotLayout)+snapshotSuffix)
+	tmp, err := os.CreateTemp(dir, ".snapshot-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return &Snapshot{Path: path, Taken: now, Size: int64(len(sealed))}, nil
+}
+
+// Stream writes a snapshot to w rather than the snapshot directory, for
+// piping it into another backup tool, and returns its size. Extract reads
+// it back like a snapshot file.
+func Stream(w io.Writer) (int64, error) {
+	dir, err := Dir()
+	if err != nil {
+		return 0, err
+	}
+	sealed, err := seal(dir)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(sealed)
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return int64(n), nil
+}
+
+// seal archives the data directory, leaving out the snapshot directory dir,
+// and encrypts the archive to your public key and every extra recipient
+func seal(dir string) ([]byte, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	keyPair, err := crypto.RestorePublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("snapshots are encrypted to your jot key pair: %w", err)
+	}
+	recipients, err := crypto.RecipientKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := archiveDir(jotDir, dir)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := crypto.EncryptFor(string(archive), keyPair, recipients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt snapshot: %w", err)
+	}
+	return sealed, nil
+}
+
+// archiveDir packs the data directory jotDir into a gzipped tar archive
+// while holding the data lock, leaving out the keys, runtime files and the
+// snapshot directory skip if it lies inside
+func archiveDir(jotDir, skip string) ([]byte, error) {
+	lock, err := storage.LockDir(jotDir)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(jotDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(jotDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if path == skip || rel == keyDir || skipped[rel] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive data directory: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to archive data directory: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to archive data directory: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// List returns the snapshots in the snapshot directory, newest first
+func List() ([]Snapshot, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var snapshots []Snapshot
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		taken, err := time.ParseInLocation(snapshotLayout, strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Path: filepath.Join(dir, name), Taken: taken, Size: info.Size()})
+	}
+	sort.Slice(snapshots, func(a, b int) bool { return snapshots[a].Taken.After(snapshots[b].Taken) })
+	return snapshots, nil
+}
+
+// Prune deletes all but the newest keep snapshots and returns those it
+// deleted. keep 0 keeps them all.
+func Prune(keep int) ([]Snapshot, error) {
+	snapshots, err := List()
+	if err != nil || keep <= 0 || len(snapshots) <= keep {
+		return nil, err
+	}
+	var pruned []Snapshot
+	for _, s := range snapshots[keep:] {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return pruned, fmt.Errorf("failed to delete %s: %w", s.Path, err)
+		}
+		pruned = append(pruned, s)
+	}
+	return pruned, nil
+}
+
+// Due reports whether schedule calls for a snapshot at now, given when the
+// last one was taken: once per hour, calendar day or week (starting on
+// calendar.week_start), so that checking late does not shift the following
+// snapshots
+func Due(schedule string, last, now time.Time) bool {
+	if last.IsZero() {
+		return schedule != "off"
+	}
+	last, now = last.Local(), now.Local()
+	switch schedule {
+	case "hourly":
+		return last.Format("2006-01-02 15") != now.Format("2006-01-02 15")
+	case "daily":
+		return last.Format("2006-01-02") != now.Format("2006-01-02")
+	case "weekly":
+		return !when.StartOfWeek(last).Equal(when.StartOfWeek(now))
+	}
+	return false
+}
+
+// RunDue takes a snapshot if backup.schedule calls for one and then prunes
+// all but the newest backup.keep. It returns the snapshot taken, if any,
+// and those pruned.
+func RunDue(now time.Time) (*Snapshot, []Snapshot, error) {
+	cfg, err := config.Current()
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshots, err := List()
+	if err != nil {
+		return nil, nil, err
+	}
+	var last time.Time
+	if len(snapshots) > 0 {
+		last = snapshots[0].Taken
+	}
+	if !Due(cfg.String("backup.schedule"), last, now) {
+		return nil, nil, nil
+	}
+	taken, err := Take(now)
+	if err != nil {
+		return nil, nil, err
+	}
+	pruned, err := Prune(cfg.Int("backup.keep"))
+	return taken, pruned, err
+}
+
+// Extract decrypts the snapshot at path, or standard input for "-", with
+// keys and unpacks it into the new directory dest, returning the number of
+// files written. The result is a data directory without keys, for jot
+// restore --from-data.
+func Extract(path, dest string, keys []*crypto.KeyPair) (int, error) {
+	var sealed []byte
+	var err error
+	if path == "-" {
+		sealed, err = io.ReadAll(os.Stdin)
+	} else {
+		sealed, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	archive, err := crypto.DecryptWithKeyring(sealed, keys)
+	if err != nil {
+		return 0, errors.New("failed to decrypt snapshot: it was not encrypted to any of your keys")
+	}
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		return 0, fmt.Errorf("%s is not empty", dest)
+	}
+	if err := os.MkdirAll(dest, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+
+	gz, err := gzip.NewReader(strings.NewReader(archive))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	n := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return n, fmt.Errorf("snapshot holds an unsafe path: %s", header.Name)
+		}
+		target := filepath.Join(dest, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return n, fmt.Errorf("failed to create %s: %w", target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return n, fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+			}
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return n, fmt.Errorf("failed to write %s: %w", target, err)
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return n, fmt.Errorf("failed to write %s: %w", target, err)
+			}
+			n++
+		}
+	}
+}
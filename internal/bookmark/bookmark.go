@@ -0,0 +1,163 @@
+// Package bookmark reads what web pages say about themselves, their title
+// and description, for bookmark entries.
+package bookmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/types"
+	"golang.org/x/net/html"
+)
+
+// maxPageBytes limits how much of a page is read; the head comes first
+const maxPageBytes = 1 << 20
+
+// httpClient is used for all page requests
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Page is what a web page says about itself. Either field may be empty.
+type Page struct {
+	Title       string
+	Description string
+}
+
+// NormalizeURL checks that raw is a web address, adding https:// when it
+// has no scheme, as in "example.com/post"
+func NormalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid URL %s: only http and https links can be bookmarked", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid URL %s: no host", raw)
+	}
+	return u.String(), nil
+}
+
+// Fetch downloads the start of the page at rawURL and reads its title and
+// description
+func Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "jot (bookmark)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to fetch page: server returned %s", resp.Status)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil &&
+		mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("not a web page but %s", mediaType)
+	}
+	return parse(io.LimitReader(resp.Body, maxPageBytes)), nil
+}
+
+// parse reads the <title> and description of an HTML document, falling
+// back to their Open Graph versions. It stops at the end of <head>.
+func parse(r io.Reader) *Page {
+	var title, ogTitle, description, ogDescription string
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return page(title, ogTitle, description, ogDescription)
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return page(title, ogTitle, description, ogDescription)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = title == ""
+			case "body":
+				return page(title, ogTitle, description, ogDescription)
+			case "meta":
+				var key, content string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch strings.ToLower(string(k)) {
+					case "name", "property":
+						key = strings.ToLower(string(v))
+					case "content":
+						content = string(v)
+					}
+				}
+				switch key {
+				case "description":
+					description = content
+				case "og:title":
+					ogTitle = content
+				case "og:description":
+					ogDescription = content
+				}
+			}
+		}
+	}
+}
+
+func page(title, ogTitle, description, ogDescription string) *Page {
+	p := &Page{Title: clean(title), Description: clean(description)}
+	if p.Title == "" {
+		p.Title = clean(ogTitle)
+	}
+	if p.Description == "" {
+		p.Description = clean(ogDescription)
+	}
+	return p
+}
+
+// clean collapses the whitespace of text taken from a page
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// FallbackTitle names a page that could not be fetched after its address,
+// such as "example.com/post"
+func FallbackTitle(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(u.Host, "www.")+u.EscapedPath(), "/")
+}
+
+// Body returns the readable body of a bookmark entry: the address, then
+// the description, so both show up in searches and exports
+func Body(b *types.Bookmark) string {
+	if b.Description == "" {
+		return b.URL
+	}
+	return b.URL + "\n\n" + b.Description
+}
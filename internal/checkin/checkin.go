@@ -0,0 +1,199 @@
+// Package checkin runs structured check-ins: entries answering the questions
+// of a template, whose answers are kept as data so they can be charted over
+// time.
+package checkin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Question kinds
+const (
+	Number = "number"
+	YesNo  = "yesno"
+	Text   = "text"
+)
+
+// templatesDir holds one template per file, <name>.txt
+const templatesDir = "checkins"
+
+// DefaultTemplate is used when no template is named
+const DefaultTemplate = "daily"
+
+// defaultQuestions make up the built-in daily template
+var defaultQuestions = []Question{
+	{Text: "Hours slept", Kind: Number},
+	{Text: "Mood from 1 to 5", Kind: Number},
+	{Text: "Exercised", Kind: YesNo},
+	{Text: "Notes", Kind: Text},
+}
+
+// Question is one question of a template
+type Question struct {
+	Text string
+	Kind string
+}
+
+// Template is a named list of questions
+type Template struct {
+	Name      string
+	Questions []Question
+	Builtin   bool // Not read from a file
+}
+
+// Dir returns the directory holding the user's templates
+func Dir() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, templatesDir), nil
+}
+
+// Load reads the template called name. The daily template is built in
+// unless the user has written their own.
+func Load(name string) (*Template, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid template name '%s'", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name+".txt"))
+	if os.IsNotExist(err) {
+		if name == DefaultTemplate {
+			return &Template{Name: name, Questions: defaultQuestions, Builtin: true}, nil
+		}
+		return nil, fmt.Errorf("no check-in template '%s' in %s", name, dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return Parse(name, string(data))
+}
+
+// List returns every template, sorted by name
+func List() ([]*Template, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	names := []string{DefaultTemplate}
+	for _, p := range paths {
+		if name := strings.TrimSuffix(filepath.Base(p), ".txt"); name != DefaultTemplate {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	templates := make([]*Template, 0, len(names))
+	for _, name := range names {
+		t, err := Load(name)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// Parse reads a template: one question per line, optionally ending in its
+// kind in brackets, e.g. "Hours slept [number]" or "Exercised [yes/no]".
+// Questions without a kind take text. Blank lines and lines starting with
+// '#' are ignored.
+func Parse(name, data string) (*Template, error) {
+	t := &Template{Name: name}
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		q := Question{Text: line, Kind: Text}
+		if strings.HasSuffix(line, "]") {
+			if open := strings.LastIndex(line, "["); open >= 0 {
+				kind, ok := parseKind(line[open+1 : len(line)-1])
+				if !ok {
+					return nil, fmt.Errorf("template '%s' line %d: unknown kind '%s': expected number, yes/no or text", name, i+1, line[open+1:len(line)-1])
+				}
+				q = Question{Text: strings.TrimSpace(line[:open]), Kind: kind}
+			}
+		}
+		if q.Text == "" {
+			return nil, fmt.Errorf("template '%s' line %d: missing question", name, i+1)
+		}
+		t.Questions = append(t.Questions, q)
+	}
+	if len(t.Questions) == 0 {
+		return nil, fmt.Errorf("template '%s' has no questions", name)
+	}
+	return t, nil
+}
+
+func parseKind(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "number":
+		return Number, true
+	case "yes/no", "yesno":
+		return YesNo, true
+	case "text":
+		return Text, true
+	}
+	return "", false
+}
+
+// Hint returns how a question of the kind is answered
+func Hint(kind string) string {
+	switch kind {
+	case Number:
+		return "number"
+	case YesNo:
+		return "y/n"
+	}
+	return "text"
+}
+
+// ParseAnswer checks an answer to a question of the kind and returns it in
+// its stored form: numbers as written, yes/no as "yes" or "no"
+func ParseAnswer(kind, answer string) (string, error) {
+	answer = strings.TrimSpace(answer)
+	switch kind {
+	case Number:
+		if _, err := strconv.ParseFloat(answer, 64); err != nil {
+			return "", fmt.Errorf("'%s' is not a number", answer)
+		}
+	case YesNo:
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return "yes", nil
+		case "n", "no":
+			return "no", nil
+		}
+		return "", fmt.Errorf("answer y or n")
+	}
+	return answer, nil
+}
+
+// Body renders a check-in as the readable text of its entry
+func Body(c *types.Checkin) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Check-in: %s\n", c.Template)
+	for _, a := range c.Answers {
+		fmt.Fprintf(&b, "\n%s: %s", a.Question, a.Value)
+	}
+	return b.String()
+}
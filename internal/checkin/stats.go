@@ -0,0 +1,128 @@
+package checkin
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// Record is a check-in and when it was written
+type Record struct {
+	Created time.Time
+	Checkin *types.Checkin
+}
+
+// Series is the answers to one question, one value per day
+type Series struct {
+	Question string
+	Kind     string
+	Values   []float64 // Oldest first; NaN where unanswered. Numbers are averaged per day, yes/no is 1 when any answer was yes.
+	Answered int       // Days with an answer
+	Yes      int       // Days answered yes, for yes/no questions
+	Min, Max float64   // Smallest and largest daily value, for numbers
+	Mean     float64   // Mean of the daily values, for numbers
+}
+
+// Stats charts the answers of the template's check-ins over the last days
+// days, ending with today, question by question in the order of the newest
+// check-in. Text answers are only counted.
+func Stats(records []Record, template string, now time.Time, days int) []*Series {
+	loc := now.Location()
+	last := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	first := last.AddDate(0, 0, -(days - 1))
+
+	type sum struct {
+		total float64
+		n     int
+	}
+	var order []*Series
+	series := make(map[string]*Series)
+	sums := make(map[string][]sum)
+
+	// Newest first, so questions come in the order of the current template
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.Checkin == nil || r.Checkin.Template != template {
+			continue
+		}
+		created := r.Created.In(loc)
+		day := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, loc)
+		if day.Before(first) || day.After(last) {
+			continue
+		}
+		// Days, not hours, so DST changes do not shift the index
+		index := int(math.Round(day.Sub(first).Hours() / 24))
+
+		for _, a := range r.Checkin.Answers {
+			s, exists := series[a.Question]
+			if !exists {
+				s = &Series{Question: a.Question, Kind: a.Kind}
+				series[a.Question] = s
+				sums[a.Question] = make([]sum, days)
+				order = append(order, s)
+			}
+			v, ok := answerValue(a)
+			if !ok {
+				continue
+			}
+			d := &sums[a.Question][index]
+			if a.Kind == YesNo {
+				// A yes on any check-in of the day counts
+				d.total = math.Max(d.total, v)
+				d.n = 1
+			} else {
+				d.total += v
+				d.n++
+			}
+		}
+	}
+
+	for _, s := range order {
+		s.Values = make([]float64, days)
+		total := 0.0
+		for i, d := range sums[s.Question] {
+			if d.n == 0 {
+				s.Values[i] = math.NaN()
+				continue
+			}
+			v := d.total
+			if s.Kind == Number {
+				v /= float64(d.n)
+			}
+			s.Values[i] = v
+			if s.Answered == 0 || v < s.Min {
+				s.Min = v
+			}
+			if s.Answered == 0 || v > s.Max {
+				s.Max = v
+			}
+			s.Answered++
+			total += v
+			if s.Kind == YesNo && v == 1 {
+				s.Yes++
+			}
+		}
+		if s.Answered > 0 {
+			s.Mean = total / float64(s.Answered)
+		}
+	}
+	return order
+}
+
+// answerValue returns the numeric value of an answer: the number, or 1 for
+// yes and 0 for no. Text answers count as 1.
+func answerValue(a types.Answer) (float64, bool) {
+	switch a.Kind {
+	case Number:
+		v, err := strconv.ParseFloat(a.Value, 64)
+		return v, err == nil
+	case YesNo:
+		if a.Value == "yes" {
+			return 1, true
+		}
+		return 0, a.Value == "no"
+	}
+	return 1, a.Value != ""
+}
@@ -1,18 +1,46 @@
 package collection
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/veritome/jot/internal/config"
 	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/storage"
 	"github.com/veritome/jot/internal/types"
 )
 
+// The collection is split over several files of the data directory, so
+// that syncing it between machines only conflicts when both changed the
+// same thing: collection.json holds the default journal, each journal has
+// a file of its own in journals/, and the index, which can always be
+// rebuilt from the entries, is kept in index.json.
+const (
+	collectionFile = "collection.json"
+	journalsDir    = "journals"
+	indexFile      = "index.json"
+)
+
 // Collection represents all journals and their metadata
 type Collection struct {
 	*types.Collection
+
+	// staleIndex is set when the stored index does not match the journals,
+	// such as after another machine's changes were synced in. Such an
+	// index is never loaded nor saved until it is rebuilt.
+	staleIndex bool
+
+	// records are the entry records of each journal as read, by journal
+	// ID. Saving removes only these, never records or journal files
+	// another machine added meanwhile.
+	records map[string][]record
 }
 
 // NewCollection creates a new journal collection
@@ -24,119 +52,346 @@ func NewCollection() (*Collection, error) {
 	}, nil
 }
 
-// Save persists the collection to disk
+// Save persists the collection to disk, overwriting the stored state.
+// Prefer Update for read-modify-write changes.
 func (c *Collection) Save() error {
-	homeDir, err := os.UserHomeDir()
+	lock, err := lockDataDir()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
 	}
+	defer lock.Unlock()
 
-	jotDir := filepath.Join(homeDir, ".jot")
-	if err := os.MkdirAll(jotDir, 0700); err != nil {
+	return c.save()
+}
+
+// save writes the collection; the caller must hold the data lock. Only
+// files whose content changed are rewritten, and journal files are written
+// before collection.json, which may name one of them as the default.
+func (c *Collection) save() error {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return fmt.Errorf("failed to get data directory: %w", err)
+	}
+
+	dir := filepath.Join(jotDir, journalsDir)
+	if err := storage.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("failed to create jot directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(c.Collection, "", "  ")
+	kept := make(map[string]bool, len(c.Journals))
+	for _, j := range c.Journals {
+		// The entries are kept as records, not in the journal file
+		stored := *j
+		stored.EntryIDs, stored.Revision = nil, 0
+		data, err := json.MarshalIndent(stored, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal journal '%s': %w", j.Name, err)
+		}
+		kept[j.ID] = true
+		if err := writeIfChanged(filepath.Join(dir, journalFile(j.ID)), data); err != nil {
+			return fmt.Errorf("failed to write journal file: %w", err)
+		}
+		if err := c.saveMembers(dir, j); err != nil {
+			return err
+		}
+	}
+
+	// Only journals removed since the collection was read are deleted;
+	// files of journals it does not know, such as one another machine
+	// just created, stay
+	for id := range c.records {
+		if kept[id] {
+			continue
+		}
+		if err := storage.Remove(filepath.Join(dir, journalFile(id))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove journal file: %w", err)
+		}
+		if err := c.removeMembers(dir, id); err != nil {
+			return err
+		}
+	}
+
+	head := *c.Collection
+	head.Journals, head.Index = nil, nil
+	data, err := json.MarshalIndent(head, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal collection: %w", err)
 	}
-
-	if err := os.WriteFile(filepath.Join(jotDir, "collection.json"), data, 0600); err != nil {
+	if err := writeIfChanged(filepath.Join(jotDir, collectionFile), data); err != nil {
 		return fmt.Errorf("failed to write collection file: %w", err)
 	}
 
-	return nil
+	return c.saveIndex(jotDir)
 }
 
 // Load loads the collection from disk
 func Load() (*Collection, error) {
-	// Try to restore NaCl keys first
-	keyPair, err := crypto.RestoreNaclFromBackup()
-	if err != nil {
-		// If keys don't exist, generate them
+	// Generate NaCl keys on first use. Existing keys are not unwrapped here,
+	// so a security key is only needed once entries are decrypted.
+	if !crypto.HasKey() {
 		if _, err := crypto.GenerateNaclKey(); err != nil {
 			return nil, fmt.Errorf("failed to generate NaCl keys: %w", err)
 		}
-	} else {
-		keyPair.Clear() // Clear the keys from memory
 	}
 
-	homeDir, err := os.UserHomeDir()
+	return read()
+}
+
+// read loads the collection without touching the keys. Journals still
+// listed in collection.json, as written before they had files of their
+// own, are moved out on the next save.
+func read() (*Collection, error) {
+	jotDir, err := config.DataDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
 	}
 
-	collectionPath := filepath.Join(homeDir, ".jot", "collection.json")
-	if _, err := os.Stat(collectionPath); os.IsNotExist(err) {
-		// If collection doesn't exist, create a new one
-		return NewCollection()
+	var collection types.Collection
+	data, err := storage.ReadFile(filepath.Join(jotDir, collectionFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read collection file: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &collection); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal collection: %w", err)
+		}
+	}
+	legacy := collection.Journals != nil
+	if collection.Journals == nil {
+		collection.Journals = make(map[string]*types.Journal)
 	}
 
-	data, err := os.ReadFile(collectionPath)
+	c := &Collection{Collection: &collection}
+	if err := c.readJournals(filepath.Join(jotDir, journalsDir)); err != nil {
+		return nil, err
+	}
+	c.assignLegacyIDs()
+	if err := c.readMembers(filepath.Join(jotDir, journalsDir)); err != nil {
+		return nil, err
+	}
+	if !legacy {
+		if err := c.readIndex(jotDir); err != nil {
+			return nil, err
+		}
+	} else if c.Index != nil {
+		// The index was saved together with the journals, so it is current
+		c.stampIndex()
+	}
+	return c, nil
+}
+
+// readJournals adds the journals stored in dir. Should two have the same
+// name, as when machines syncing the data directory each created one, the
+// one with the greater ID is listed under its name followed by its ID.
+func (c *Collection) readJournals(dir string) error {
+	files, err := storage.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read collection file: %w", err)
+		return fmt.Errorf("failed to read journals directory: %w", err)
 	}
 
-	var collection types.Collection
-	if err := json.Unmarshal(data, &collection); err != nil {
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := storage.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read journal file: %w", err)
+		}
+		var j types.Journal
+		if err := json.Unmarshal(data, &j); err != nil {
+			return fmt.Errorf("failed to unmarshal journal file %s: %w", f.Name(), err)
+		}
+		if _, taken := c.Journals[j.Name]; taken {
+			j.Name = fmt.Sprintf("%s-%s", j.Name, j.ID)
+		}
+		c.Journals[j.Name] = &j
+	}
+	return nil
+}
+
+// Modified returns when the collection's files last changed, or the zero
+// time when there are none
+func Modified() time.Time {
+	var latest time.Time
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return latest
+	}
+	paths, _ := filepath.Glob(filepath.Join(jotDir, journalsDir, "*"))
+	paths = append(paths, filepath.Join(jotDir, journalsDir), filepath.Join(jotDir, collectionFile), filepath.Join(jotDir, indexFile))
+	for _, path := range paths {
+		if info, err := storage.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// Devices returns the approved devices from collection.json alone, without
+// reading the journals, for encrypting to their keys
+func Devices() ([]*types.Device, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	data, err := storage.ReadFile(filepath.Join(jotDir, collectionFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection file: %w", err)
+	}
+	var head types.Collection
+	if err := json.Unmarshal(data, &head); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal collection: %w", err)
 	}
+	return head.Devices, nil
+}
+
+// journalFile returns the name of the file in journalsDir holding the
+// journal with the given ID. IDs of journals created before IDs existed
+// are their names, which may contain any character.
+func journalFile(id string) string {
+	return url.PathEscape(id) + ".json"
+}
+
+// writeIfChanged atomically writes data to path unless it already holds
+// exactly that, so that file sync tools only see real changes
+func writeIfChanged(path string, data []byte) error {
+	if existing, err := storage.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return storage.WriteFile(path, data, 0600)
+}
+
+// Update applies fn to the latest stored collection while holding the data
+// lock and saves the result, so concurrent jot processes cannot overwrite
+// each other's changes. Nothing is saved if fn returns an error.
+func Update(fn func(*Collection) error) (*Collection, error) {
+	lock, err := lockDataDir()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	coll, err := read()
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(coll); err != nil {
+		return nil, err
+	}
+	if err := coll.save(); err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
+
+// update runs fn through Update and refreshes c with the saved state
+func (c *Collection) update(fn func(*Collection) error) error {
+	coll, err := Update(fn)
+	if err != nil {
+		return err
+	}
+	c.Collection, c.staleIndex, c.records = coll.Collection, coll.staleIndex, coll.records
+	return nil
+}
 
-	return &Collection{Collection: &collection}, nil
+// lockDataDir acquires the advisory lock guarding the data directory
+func lockDataDir() (*storage.Lock, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return storage.LockDir(jotDir)
 }
 
 // SetDefaultJournal sets the specified journal as the default
 func (c *Collection) SetDefaultJournal(name string) error {
-	if _, exists := c.Journals[name]; !exists {
-		return fmt.Errorf("journal '%s' does not exist", name)
-	}
-	c.DefaultJournal = name
-	return c.Save()
+	return c.update(func(coll *Collection) error {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", name)
+		}
+		coll.DefaultJournal = j.ID
+		return nil
+	})
 }
 
 // GetDefaultJournal returns the name of the default journal
 func (c *Collection) GetDefaultJournal() string {
-	return c.DefaultJournal
+	if j, ok := c.JournalByID(c.DefaultJournal); ok {
+		return j.Name
+	}
+	return ""
 }
 
-// List returns a formatted list of all journals, with an asterisk next to the default
+// ResolveDefaultJournal returns the journal used when none is given: the
+// configured default_journal, else the collection's default
+func (c *Collection) ResolveDefaultJournal() string {
+	if cfg, err := config.Current(); err == nil && cfg.String("default_journal") != "" {
+		return cfg.String("default_journal")
+	}
+	return c.GetDefaultJournal()
+}
+
+// List returns the names of all journals, sorted. ResolveDefaultJournal
+// tells which is the default.
 func (c *Collection) List() []string {
-	var list []string
+	list := make([]string, 0, len(c.Journals))
 	for name := range c.Journals {
-		if name == c.DefaultJournal {
-			list = append(list, fmt.Sprintf("%s *", name))
-		} else {
-			list = append(list, name)
-		}
+		list = append(list, name)
 	}
+	sort.Strings(list)
 	return list
 }
 
-// AddJournal adds a journal to the collection and sets it as default if it's the first one
+// AddJournal adds a journal to the collection and sets it as default if it's
+// the first one. Names differing from an existing journal only by case or
+// whitespace are rejected unless journal.similar_names is "warn". A journal
+// without an ID, such as one trashed before IDs existed, gets its name as ID.
 func (c *Collection) AddJournal(j *types.Journal) error {
-	if _, exists := c.Journals[j.Name]; exists {
-		return fmt.Errorf("journal '%s' already exists", j.Name)
-	}
-
-	c.Journals[j.Name] = j
+	return c.update(func(coll *Collection) error {
+		if _, exists := coll.Journals[j.Name]; exists {
+			return fmt.Errorf("journal '%s' already exists", j.Name)
+		}
+		if similar := coll.SimilarJournal(j.Name); similar != "" && RejectSimilarNames() {
+			return fmt.Errorf("journal '%s' is too similar to existing journal '%s'", j.Name, similar)
+		}
+		if j.ID == "" {
+			j.ID = j.Name
+		}
+		if other, exists := coll.JournalByID(j.ID); exists {
+			return fmt.Errorf("journal ID %s is already used by '%s'", j.ID, other.Name)
+		}
 
-	// If this is the first journal, set it as default
-	if len(c.Journals) == 1 {
-		c.DefaultJournal = j.Name
-	}
+		coll.Journals[j.Name] = j
 
-	return c.Save()
+		// If this is the first journal, set it as default
+		if len(coll.Journals) == 1 {
+			coll.DefaultJournal = j.ID
+		}
+		return nil
+	})
 }
 
 // RemoveJournal removes a journal from the collection
 func (c *Collection) RemoveJournal(name string) error {
-	if _, exists := c.Journals[name]; !exists {
-		return fmt.Errorf("journal '%s' does not exist", name)
-	}
-	if name == c.DefaultJournal {
-		c.DefaultJournal = ""
-	}
-	delete(c.Journals, name)
-	return c.Save()
+	return c.update(func(coll *Collection) error {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", name)
+		}
+		if j.AppendOnly {
+			return fmt.Errorf("journal '%s' is append-only and cannot be deleted", name)
+		}
+		if j.ID == coll.DefaultJournal {
+			coll.DefaultJournal = ""
+		}
+		delete(coll.Journals, name)
+		return nil
+	})
 }
-
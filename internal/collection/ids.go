@@ -0,0 +1,73 @@
+package collection
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// Journals are keyed by name, but entries and the default pointer refer to
+// a journal by its ID, which never changes. Journals created before IDs
+// existed use their original name as ID, which is what their entries and
+// the default pointer already refer to.
+
+// NewJournalID returns a random journal ID
+func NewJournalID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate journal ID: %w", err)
+	}
+	return "j-" + hex.EncodeToString(b), nil
+}
+
+// assignLegacyIDs gives journals without an ID their name as ID
+func (c *Collection) assignLegacyIDs() {
+	for name, j := range c.Journals {
+		if j.ID == "" {
+			j.ID = name
+		}
+	}
+}
+
+// JournalByID returns the journal with the given ID
+func (c *Collection) JournalByID(id string) (*types.Journal, bool) {
+	for _, j := range c.Journals {
+		if j.ID == id {
+			return j, true
+		}
+	}
+	return nil, false
+}
+
+// JournalName returns the name of the journal with the given ID, or the ID
+// itself if no journal has it
+func (c *Collection) JournalName(id string) string {
+	if j, ok := c.JournalByID(id); ok {
+		return j.Name
+	}
+	return id
+}
+
+// RenameJournal changes the name of a journal. Entries refer to the
+// journal's ID, so none of them need to be rewritten.
+func (c *Collection) RenameJournal(name, newName string) error {
+	return c.update(func(coll *Collection) error {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", name)
+		}
+		if _, exists := coll.Journals[newName]; exists {
+			return fmt.Errorf("journal '%s' already exists", newName)
+		}
+		if similar := coll.SimilarJournal(newName); similar != "" && similar != name && RejectSimilarNames() {
+			return fmt.Errorf("journal '%s' is too similar to existing journal '%s'", newName, similar)
+		}
+
+		delete(coll.Journals, name)
+		j.Name = newName
+		coll.Journals[newName] = j
+		return nil
+	})
+}
@@ -0,0 +1,278 @@
+package collection
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+// dateLayout is the key format of the date index
+const dateLayout = "2006-01-02"
+
+// readIndex loads index.json when it matches the journals. A missing,
+// unreadable or outdated index is left unloaded and marked stale, unless
+// there is nothing to index yet.
+func (c *Collection) readIndex(jotDir string) error {
+	data, err := storage.ReadFile(filepath.Join(jotDir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	var index types.Index
+	if err != nil || json.Unmarshal(data, &index) != nil || !c.current(&index) {
+		c.staleIndex = c.hasEntries()
+		return nil
+	}
+	c.Index = &index
+	return nil
+}
+
+// current reports whether index was saved with the journals as they are
+func (c *Collection) current(index *types.Index) bool {
+	if len(index.Revisions) != len(c.Journals) {
+		return false
+	}
+	for _, j := range c.Journals {
+		if revision, ok := index.Revisions[j.ID]; !ok || revision != j.Revision {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Collection) hasEntries() bool {
+	for _, j := range c.Journals {
+		if len(j.EntryIDs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stampIndex records the journals' revisions as those the index is current with
+func (c *Collection) stampIndex() {
+	c.Index.Revisions = make(map[string]int64, len(c.Journals))
+	for _, j := range c.Journals {
+		c.Index.Revisions[j.ID] = j.Revision
+	}
+}
+
+// saveIndex writes index.json, unless the index is stale
+func (c *Collection) saveIndex(jotDir string) error {
+	if c.Index == nil || c.staleIndex {
+		return nil
+	}
+	c.stampIndex()
+	data, err := json.MarshalIndent(c.Index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+	if err := writeIfChanged(filepath.Join(jotDir, indexFile), data); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// IndexStale reports whether the stored index does not match the journals
+// and needs to be rebuilt
+func (c *Collection) IndexStale() bool {
+	return c.staleIndex
+}
+
+// ResetIndex empties the index, to be rebuilt from every entry
+func (c *Collection) ResetIndex() {
+	c.Index = &types.Index{}
+	c.staleIndex = false
+}
+
+// SetIndex replaces the index with one built from every entry, such as by
+// a scratch collection, and marks it current
+func (c *Collection) SetIndex(index *types.Index) {
+	c.Index = index
+	c.staleIndex = false
+}
+
+// IndexedEntries returns how many entries the index holds
+func (c *Collection) IndexedEntries() int {
+	if c.Index == nil {
+		return 0
+	}
+	n := 0
+	for _, ids := range c.Index.Dates {
+		n += len(ids)
+	}
+	return n
+}
+
+// IndexEntry records an entry's creation date and stored tags in the index,
+// replacing what was recorded for it before. encrypted marks entries whose
+// tags can only be matched by decrypting them. It reports whether the index
+// changed.
+func (c *Collection) IndexEntry(id string, created time.Time, tags []string, encrypted bool) bool {
+	if c.Index == nil {
+		c.Index = &types.Index{}
+	}
+	date := created.Local().Format(dateLayout)
+
+	if c.indexed(id, date, tags, encrypted) {
+		return false
+	}
+	c.UnindexEntry(id)
+
+	if c.Index.Dates == nil {
+		c.Index.Dates = make(map[string][]string)
+	}
+	c.Index.Dates[date] = appendID(c.Index.Dates[date], id)
+	if len(tags) > 0 && c.Index.Tags == nil {
+		c.Index.Tags = make(map[string][]string)
+	}
+	for _, t := range tags {
+		c.Index.Tags[t] = appendID(c.Index.Tags[t], id)
+	}
+	if encrypted {
+		c.Index.Encrypted = appendID(c.Index.Encrypted, id)
+	}
+	return true
+}
+
+// indexed reports whether the index already holds exactly this information
+func (c *Collection) indexed(id, date string, tags []string, encrypted bool) bool {
+	if !containsID(c.Index.Dates[date], id) || containsID(c.Index.Encrypted, id) != encrypted {
+		return false
+	}
+	n := 0
+	for _, ids := range c.Index.Tags {
+		if containsID(ids, id) {
+			n++
+		}
+	}
+	if n != len(tags) {
+		return false
+	}
+	for _, t := range tags {
+		if !containsID(c.Index.Tags[t], id) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnindexEntry removes every trace of an entry from the index. It reports
+// whether the index changed.
+func (c *Collection) UnindexEntry(id string) bool {
+	if c.Index == nil {
+		return false
+	}
+	changed := false
+	for _, m := range []map[string][]string{c.Index.Dates, c.Index.Tags} {
+		for key, ids := range m {
+			if kept, removed := removeID(ids, id); removed {
+				changed = true
+				if len(kept) == 0 {
+					delete(m, key)
+				} else {
+					m[key] = kept
+				}
+			}
+		}
+	}
+	if kept, removed := removeID(c.Index.Encrypted, id); removed {
+		changed = true
+		c.Index.Encrypted = kept
+	}
+	return changed
+}
+
+// IndexedDates returns the IDs of entries created between from and to
+// (inclusive, by local date). A zero bound is open.
+func (c *Collection) IndexedDates(from, to time.Time) map[string]bool {
+	ids := make(map[string]bool)
+	if c.Index == nil {
+		return ids
+	}
+	lo, hi := "", "9999-99-99"
+	if !from.IsZero() {
+		lo = from.Format(dateLayout)
+	}
+	if !to.IsZero() {
+		hi = to.Format(dateLayout)
+	}
+	for date, list := range c.Index.Dates {
+		if date >= lo && date <= hi {
+			for _, id := range list {
+				ids[id] = true
+			}
+		}
+	}
+	return ids
+}
+
+// LastIndexedDates returns the local date of the newest entry of each
+// journal with entries, by journal name, as YYYY-MM-DD
+func (c *Collection) LastIndexedDates() map[string]string {
+	last := make(map[string]string)
+	if c.Index == nil {
+		return last
+	}
+	dates := make(map[string]string)
+	for date, list := range c.Index.Dates {
+		for _, id := range list {
+			dates[id] = date
+		}
+	}
+	for name, j := range c.Journals {
+		for _, id := range j.EntryIDs {
+			if d := dates[id]; d > last[name] {
+				last[name] = d
+			}
+		}
+	}
+	return last
+}
+
+// IndexedTag returns the IDs of entries stored with any of the given tag
+// keys, and the IDs of entries whose tags must be decrypted to tell
+func (c *Collection) IndexedTag(keys []string) (matches map[string]bool, encrypted []string) {
+	matches = make(map[string]bool)
+	if c.Index == nil {
+		return matches, nil
+	}
+	for _, k := range keys {
+		for _, id := range c.Index.Tags[k] {
+			matches[id] = true
+		}
+	}
+	return matches, c.Index.Encrypted
+}
+
+func appendID(ids []string, id string) []string {
+	if containsID(ids, id) {
+		return ids
+	}
+	ids = append(ids, id)
+	sort.Strings(ids)
+	return ids
+}
+
+func containsID(ids []string, id string) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+func removeID(ids []string, id string) ([]string, bool) {
+	for i, existing := range ids {
+		if existing == id {
+			return append(ids[:i:i], ids[i+1:]...), true
+		}
+	}
+	return ids, false
+}
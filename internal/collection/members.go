@@ -0,0 +1,283 @@
+package collection
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+// A journal's entries are not listed in its file, which two machines
+// adding entries at the same time would both rewrite. Each entry instead
+// has a record of its own in journals/<id>/, named after when it was added
+// so that the records sort in that order, and removing the entry removes
+// its record. Syncing two machines' additions then only brings in new
+// files.
+//
+// A journal's revision is the newest of its record stamps and the stamp in
+// its changedFile, which is moved forward whenever a record is removed or
+// renamed. Stamps only grow, so the revision never returns to an earlier
+// value.
+
+// stampWidth is the number of digits of the time a record is named after
+const stampWidth = 20
+
+// changedFile holds the stamp of the last time a record of the journal was
+// removed or renamed
+const changedFile = "changed"
+
+// record is a file saying that an entry belongs to a journal
+type record struct {
+	name    string
+	stamp   int64
+	entryID string
+
+	// renamedFrom is the name the record is stored under until it is
+	// saved, when its entry was given a new ID
+	renamedFrom string
+}
+
+// recordName returns the name of the record of entryID added at stamp
+func recordName(stamp int64, entryID string) string {
+	return fmt.Sprintf("%0*d-%s", stampWidth, stamp, entryID)
+}
+
+// membersDir returns the directory holding the records of the journal id
+func membersDir(dir, id string) string {
+	return filepath.Join(dir, url.PathEscape(id))
+}
+
+// readMembers sets the entries of every journal from its records. IDs
+// still listed in a journal file, as written before records existed, are
+// kept after those with records and moved to records on the next save.
+func (c *Collection) readMembers(dir string) error {
+	c.records = make(map[string][]record, len(c.Journals))
+	for _, j := range c.Journals {
+		mdir := membersDir(dir, j.ID)
+		records, err := readRecords(mdir)
+		if err != nil {
+			return err
+		}
+		changed, err := readChanged(mdir)
+		if err != nil {
+			return err
+		}
+		c.records[j.ID] = records
+
+		listed := make(map[string]bool, len(records))
+		ids := make([]string, 0, len(records)+len(j.EntryIDs))
+		for _, r := range records {
+			listed[r.entryID] = true
+			ids = append(ids, r.entryID)
+		}
+		for _, id := range j.EntryIDs {
+			if !listed[id] {
+				ids = append(ids, id)
+			}
+		}
+		j.EntryIDs = ids
+		j.Revision = latest(records, changed)
+	}
+	return nil
+}
+
+// readRecords returns the records in dir, oldest first
+func readRecords(dir string) ([]record, error) {
+	files, err := storage.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal entries: %w", err)
+	}
+	records := make([]record, 0, len(files))
+	for _, f := range files {
+		if r, ok := parseRecord(f.Name()); ok && !f.IsDir() {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(a, b int) bool { return records[a].name < records[b].name })
+	return records, nil
+}
+
+// readChanged returns the stamp in the changedFile of dir, or 0 if there
+// is none
+func readChanged(dir string) (int64, error) {
+	data, err := storage.ReadFile(filepath.Join(dir, changedFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read journal revision: %w", err)
+	}
+	stamp, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse journal revision: %w", err)
+	}
+	return stamp, nil
+}
+
+// latest returns the newest of the stamps of records and changed
+func latest(records []record, changed int64) int64 {
+	for _, r := range records {
+		if r.stamp > changed {
+			changed = r.stamp
+		}
+	}
+	return changed
+}
+
+func parseRecord(name string) (record, bool) {
+	if len(name) < stampWidth+2 || name[stampWidth] != '-' {
+		return record{}, false
+	}
+	stamp, err := strconv.ParseInt(name[:stampWidth], 10, 64)
+	if err != nil {
+		return record{}, false
+	}
+	return record{name: name, stamp: stamp, entryID: name[stampWidth+1:]}, true
+}
+
+// saveMembers brings the records of j in line with its entries: records of
+// entries it no longer has are removed and entries without one get a new
+// record. Records added by another machine since the collection was read
+// are left alone. Every change moves the revision of j past its previous
+// value.
+func (c *Collection) saveMembers(dir string, j *types.Journal) error {
+	want := make(map[string]int, len(j.EntryIDs))
+	for _, id := range j.EntryIDs {
+		want[id]++
+	}
+
+	mdir := membersDir(dir, j.ID)
+	stamp := time.Now().UnixNano()
+	if stamp <= j.Revision {
+		stamp = j.Revision + 1
+	}
+	var kept []record
+	// A new journal is given a revision of its own, so that callers can
+	// expect it to still be empty
+	changed := j.Revision == 0
+	for _, r := range c.records[j.ID] {
+		if want[r.entryID] > 0 {
+			want[r.entryID]--
+			if r.renamedFrom != "" {
+				changed = true
+				if err := storage.WriteFile(filepath.Join(mdir, r.name), []byte(r.entryID), 0600); err != nil {
+					return fmt.Errorf("failed to write entry record: %w", err)
+				}
+				if err := storage.Remove(filepath.Join(mdir, r.renamedFrom)); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to remove entry record: %w", err)
+				}
+				r.renamedFrom = ""
+			}
+			kept = append(kept, r)
+			if r.stamp >= stamp {
+				stamp = r.stamp + 1
+			}
+			continue
+		}
+		changed = true
+		name := r.name
+		if r.renamedFrom != "" {
+			name = r.renamedFrom
+		}
+		if err := storage.Remove(filepath.Join(mdir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove entry record: %w", err)
+		}
+	}
+
+	if changed {
+		if err := storage.MkdirAll(mdir, 0700); err != nil {
+			return fmt.Errorf("failed to create journal entries directory: %w", err)
+		}
+		if err := storage.WriteFile(filepath.Join(mdir, changedFile), []byte(strconv.FormatInt(stamp, 10)), 0600); err != nil {
+			return fmt.Errorf("failed to write journal revision: %w", err)
+		}
+		j.Revision = stamp
+		stamp++
+	}
+	for _, id := range j.EntryIDs {
+		if want[id] == 0 {
+			continue
+		}
+		want[id]--
+		if err := storage.MkdirAll(mdir, 0700); err != nil {
+			return fmt.Errorf("failed to create journal entries directory: %w", err)
+		}
+		r := record{name: recordName(stamp, id), stamp: stamp, entryID: id}
+		if err := storage.WriteFile(filepath.Join(mdir, r.name), []byte(id), 0600); err != nil {
+			return fmt.Errorf("failed to write entry record: %w", err)
+		}
+		kept = append(kept, r)
+		j.Revision = stamp
+		stamp++
+	}
+
+	c.setRecords(j.ID, kept)
+	return nil
+}
+
+// RenameEntry gives the entry oldID the ID newID in every journal listing
+// it. The entry keeps its place: its record is renamed rather than
+// replaced when the collection is saved. It reports whether any journal
+// listed the entry.
+func (c *Collection) RenameEntry(oldID, newID string) bool {
+	found := false
+	for _, j := range c.Journals {
+		for i, id := range j.EntryIDs {
+			if id == oldID {
+				j.EntryIDs[i] = newID
+				found = true
+			}
+		}
+		for i, r := range c.records[j.ID] {
+			if r.entryID != oldID {
+				continue
+			}
+			from := r.name
+			if r.renamedFrom != "" {
+				from = r.renamedFrom
+			}
+			c.records[j.ID][i] = record{name: recordName(r.stamp, newID), stamp: r.stamp, entryID: newID, renamedFrom: from}
+		}
+	}
+	return found
+}
+
+// removeMembers removes the records of a journal removed from the
+// collection, and their directory once it is empty
+func (c *Collection) removeMembers(dir, id string) error {
+	mdir := membersDir(dir, id)
+	for _, r := range c.records[id] {
+		name := r.name
+		if r.renamedFrom != "" {
+			name = r.renamedFrom
+		}
+		if err := storage.Remove(filepath.Join(mdir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove entry record: %w", err)
+		}
+	}
+	if err := storage.Remove(filepath.Join(mdir, changedFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal revision: %w", err)
+	}
+	if files, err := storage.ReadDir(mdir); err == nil && len(files) == 0 {
+		storage.Remove(mdir)
+	}
+	delete(c.records, id)
+	return nil
+}
+
+func (c *Collection) setRecords(id string, records []record) {
+	if c.records == nil {
+		c.records = make(map[string][]record)
+	}
+	c.records[id] = records
+}
@@ -0,0 +1,51 @@
+package collection
+
+import (
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+)
+
+// NormalizeName applies the journal.normalize_names rule to the name of a
+// journal about to be created
+func NormalizeName(name string) string {
+	rule := "trim"
+	if cfg, err := config.Current(); err == nil {
+		rule = cfg.String("journal.normalize_names")
+	}
+
+	switch rule {
+	case "trim":
+		return strings.Join(strings.Fields(name), " ")
+	case "lower":
+		return strings.ToLower(strings.Join(strings.Fields(name), " "))
+	}
+	return name
+}
+
+// foldName returns the key under which names that differ only by case or
+// whitespace collide
+func foldName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// SimilarJournal returns the name of an existing journal that differs from
+// name only by case or whitespace, or "" if there is none
+func (c *Collection) SimilarJournal(name string) string {
+	key := foldName(name)
+	for existing := range c.Journals {
+		if existing != name && foldName(existing) == key {
+			return existing
+		}
+	}
+	return ""
+}
+
+// RejectSimilarNames reports whether journal.similar_names forbids
+// near-duplicate names
+func RejectSimilarNames() bool {
+	if cfg, err := config.Current(); err == nil {
+		return cfg.String("journal.similar_names") == "reject"
+	}
+	return true
+}
@@ -0,0 +1,419 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind is the value type of a configuration key
+type Kind int
+
+// Supported value kinds
+const (
+	String Kind = iota
+	Bool
+	Int
+)
+
+// Key describes a supported configuration setting
+type Key struct {
+	Name        string
+	Kind        Kind
+	Default     string
+	Description string
+	Allowed     []string // Permitted values; empty means any value of Kind
+}
+
+// keys lists every supported setting. Dotted names live in a TOML section,
+// e.g. "sync.remote" is written as "remote" under "[sync]".
+var keys = []Key{
+	{Name: "default_journal", Kind: String, Description: "Journal used when none is given (overrides the collection default)"},
+	{Name: "default_command", Kind: String, Default: "help", Description: "What jot does without arguments: help, find (browse entries), new (write an entry in the editor) or today (list today's entries)", Allowed: []string{"help", "find", "new", "today"}},
+	{Name: "editor", Kind: String, Description: "External editor for composing entries (empty uses the built-in editor)"},
+	{Name: "compose.typewriter", Kind: Bool, Default: "false", Description: "Start the editor in typewriter mode: no going back past the current sentence, finished paragraphs hidden"},
+	{Name: "date_format", Kind: String, Default: "2006-01-02T15:04:05Z07:00", Description: "Go time layout used when displaying dates"},
+	{Name: "calendar.week_start", Kind: String, Default: "monday", Description: "Day weeks start on in heatmaps, rollups, weekly goals and periods such as last week", Allowed: []string{"monday", "sunday", "saturday"}},
+	{Name: "calendar.week_numbers", Kind: String, Default: "iso", Description: "Week numbering: iso (ISO 8601) or simple (week 1 holds January 1, weeks starting on calendar.week_start)", Allowed: []string{"iso", "simple"}},
+	{Name: "discreet", Kind: Bool, Default: "false", Description: "Mask entry titles, tags and bodies on screen, showing only IDs and dates (for presenting or pairing)"},
+	{Name: "color", Kind: String, Default: "auto", Description: "Colored output", Allowed: []string{"auto", "always", "never"}},
+	{Name: "data_dir", Kind: String, Default: "~/.jot", Description: "Directory holding journals, entries and keys"},
+	{Name: "crypto.backend", Kind: String, Default: "nacl", Description: "Encryption for new entries", Allowed: []string{"nacl", "gpg"}},
+	{Name: "crypto.fido2_device", Kind: String, Description: "Security key used to unwrap private keys (empty uses the first one found)"},
+	{Name: "crypto.key_cache", Kind: String, Default: "locked", Description: "How key pairs are kept once read: in memory locked against swapping, in ordinary memory, or read again for every use", Allowed: []string{"locked", "memory", "off"}},
+	{Name: "crypto.key_dir", Kind: String, Description: "Directory holding the key pairs, such as secrets mounted into a container (empty uses <data_dir>/backup)"},
+	{Name: "crypto.gpg_recipient", Kind: String, Description: "GPG key ID, fingerprint or email new entries are encrypted to when crypto.backend is gpg"},
+	{Name: "entry.duplicate_window", Kind: Int, Default: "10", Description: "Minutes within which a new entry with the same text as another in its journal is a duplicate (0 disables the check)"},
+	{Name: "entry.id_format", Kind: String, Default: "sequential", Description: "Entry IDs: sequential, date, ulid or a format string such as \"{date:2006}-{seq:4}\""},
+	{Name: "journal.normalize_names", Kind: String, Default: "trim", Description: "How new journal names are cleaned up: none, trim (collapse whitespace) or lower (trim and lowercase)", Allowed: []string{"none", "trim", "lower"}},
+	{Name: "journal.similar_names", Kind: String, Default: "reject", Description: "New journal names differing from an existing one only by case or whitespace: reject or warn", Allowed: []string{"reject", "warn"}},
+	{Name: "metadata.mode", Kind: String, Default: "encrypted", Description: "How tags and titles are stored: encrypted (private), hashed (salted hashes, fast filtering) or plain", Allowed: []string{"encrypted", "hashed", "plain"}},
+	{Name: "storage.compression", Kind: String, Default: "gzip", Description: "Compression of entry bodies before they are encrypted", Allowed: []string{"gzip", "none"}},
+	{Name: "storage.blob_threshold", Kind: Int, Default: "4096", Description: "Bodies of at least this many bytes are stored once in blobs/ and shared by identical entries (0 disables)"},
+	{Name: "index.batch_size", Kind: Int, Default: "200", Description: "Entries read between pauses when the index is built in the background"},
+	{Name: "index.pause_ms", Kind: Int, Default: "100", Description: "Milliseconds a background index build pauses after each batch, leaving the disk to other programs"},
+	{Name: "index.background_entries", Kind: Int, Default: "5000", Description: "A missing index of more entries than this is built in the background instead of holding up the command needing it (0 always waits)"},
+	{Name: "server.token_file", Kind: String, Description: "File holding the API token of jot serve and jot daemon (empty uses <data_dir>/server.token)"},
+	{Name: "export.html_theme", Kind: String, Default: "auto", Description: "Colour theme of jot export --format html", Allowed: []string{"auto", "light", "dark", "sepia"}},
+	{Name: "sync.backend", Kind: String, Default: "s3", Description: "Object storage used by jot sync", Allowed: []string{"s3"}},
+	{Name: "sync.remote", Kind: String, Description: "Bucket and prefix used by jot sync, as s3://bucket/prefix"},
+	{Name: "sync.endpoint", Kind: String, Description: "URL of an S3-compatible service such as B2, MinIO or R2 (empty uses AWS S3)"},
+	{Name: "sync.region", Kind: String, Default: "us-east-1", Description: "Region of the bucket (auto for R2)"},
+	{Name: "notify.backend", Kind: String, Default: "auto", Description: "Desktop notifications of reminders, digests and sync failures (auto picks the platform's own)", Allowed: []string{"auto", "notify-send", "osascript", "toast", "terminal", "none"}},
+	{Name: "digest.days", Kind: Int, Default: "7", Description: "Days summarised by jot digest"},
+	{Name: "digest.template", Kind: String, Description: "Go template for the digest message (empty uses the built-in message)"},
+	{Name: "digest.payload", Kind: String, Description: "Go template for generic webhook request bodies (empty sends {\"text\": message})"},
+	{Name: "digest.slack_url", Kind: String, Description: "Slack incoming webhook URL"},
+	{Name: "digest.discord_url", Kind: String, Description: "Discord webhook URL"},
+	{Name: "digest.webhook_url", Kind: String, Description: "Generic webhook URL"},
+	{Name: "bookmark.journal", Kind: String, Default: "bookmarks", Description: "Journal receiving entries from jot bookmark, created on first use"},
+	{Name: "bookmark.fetch", Kind: Bool, Default: "true", Description: "Read the title and description of bookmarked pages (false never goes online)"},
+	{Name: "enrich.location", Kind: String, Default: "off", Description: "Record where new entries are written: off, fixed (enrich.place) or ip (looked up at enrich.location_url)", Allowed: []string{"off", "fixed", "ip"}},
+	{Name: "enrich.place", Kind: String, Description: "Location recorded when enrich.location is fixed, such as Lisbon, Portugal"},
+	{Name: "enrich.location_url", Kind: String, Default: "https://ipinfo.io/json", Description: "Service answering with the city and country of your IP address as JSON"},
+	{Name: "enrich.weather", Kind: Bool, Default: "false", Description: "Record the current weather with new entries, looked up at enrich.weather_url"},
+	{Name: "enrich.weather_url", Kind: String, Default: "https://wttr.in/{place}?format=%C+%t", Description: "Service answering with a one-line weather report; {place} is the entry's location"},
+	{Name: "capture.routes", Kind: String, Description: "Prefix rules picking the journal of quick entries, comma-separated: @* sends \"jot @work ...\" to work, !todo=todo sends \"jot !todo ...\" to todo"},
+	{Name: "watch.dir", Kind: String, Description: "Folder or named pipe read by jot watch (empty uses <data_dir>/inbox)"},
+	{Name: "watch.journal", Kind: String, Description: "Journal receiving entries from jot watch (empty uses the default journal)"},
+	{Name: "timestamp.authority", Kind: String, Default: "https://freetsa.org/tsr", Description: "RFC 3161 timestamping authority used by jot timestamp"},
+	{Name: "timestamp.ca_file", Kind: String, Description: "PEM certificates trusted to sign timestamps (empty uses the system's roots)"},
+	{Name: "confirm.delete_entries", Kind: Int, Default: "0", Description: "Deleting more than this many entries asks for confirmation (0 asks before every deletion)"},
+	{Name: "confirm.plaintext_export", Kind: Bool, Default: "false", Description: "Ask before jot export writes out decrypted entries"},
+	{Name: "confirm.remote", Kind: Bool, Default: "false", Description: "Ask before adding or deleting entries on a --remote server"},
+	{Name: "backup.schedule", Kind: String, Default: "off", Description: "How often jot backup run and the daemon take an encrypted snapshot of the data directory", Allowed: []string{"off", "hourly", "daily", "weekly"}},
+	{Name: "backup.keep", Kind: Int, Default: "14", Description: "Snapshots kept; older ones are deleted after each new one (0 keeps them all)"},
+	{Name: "backup.dir", Kind: String, Description: "Directory receiving snapshots, ideally on another disk (empty uses <data_dir>/snapshots)"},
+	{Name: "trash.retention_days", Kind: Int, Default: "30", Description: "Days a deleted journal can be restored before it is purged"},
+	{Name: "undo.history", Kind: Int, Default: "20", Description: "Operations kept for jot undo; older ones can no longer be undone"},
+}
+
+// Lookup returns the definition of the named key
+func Lookup(name string) (Key, bool) {
+	for _, k := range keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Keys returns all supported keys sorted by name
+func Keys() []Key {
+	sorted := make([]Key, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Name < sorted[b].Name })
+	return sorted
+}
+
+// validate checks value against the key's kind and allowed values
+func (k Key) validate(value string) error {
+	switch k.Kind {
+	case Bool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false", k.Name)
+		}
+	case Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be an integer", k.Name)
+		}
+	}
+	if len(k.Allowed) > 0 {
+		for _, a := range k.Allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of: %s", k.Name, strings.Join(k.Allowed, ", "))
+	}
+	return nil
+}
+
+// EnvName returns the environment variable overriding the named setting,
+// e.g. JOT_SYNC_REMOTE for "sync.remote"
+func EnvName(name string) string {
+	return "JOT_" + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// Config holds the settings read from the config file and the environment
+type Config struct {
+	path      string
+	values    map[string]string
+	env       map[string]string // Values of JOT_* variables, which win over the file
+	overrides map[string]string // Values set with Override, which win over both
+}
+
+var (
+	loadOnce sync.Once
+	current  *Config
+	loadErr  error
+)
+
+// Current returns the configuration from the config file, loading it on first use
+func Current() (*Config, error) {
+	loadOnce.Do(func() {
+		path, err := Path()
+		if err != nil {
+			loadErr = err
+			return
+		}
+		current, loadErr = Load(path)
+	})
+	return current, loadErr
+}
+
+// Use makes c the configuration returned by Current until the returned
+// function puts the previous one back
+func Use(c *Config) (restore func()) {
+	loaded := true
+	loadOnce.Do(func() { loaded = false })
+	previous, previousErr := current, loadErr
+	current, loadErr = c, nil
+	return func() {
+		current, loadErr = previous, previousErr
+		if !loaded {
+			loadOnce = sync.Once{}
+		}
+	}
+}
+
+// New returns a configuration holding values, read from neither a file nor
+// the environment. Saving it keeps the values in memory.
+func New(values map[string]string) (*Config, error) {
+	c := &Config{values: make(map[string]string), env: make(map[string]string), overrides: make(map[string]string)}
+	for name, value := range values {
+		if err := c.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Path returns the location of the config file: $JOT_CONFIG, or else
+// $XDG_CONFIG_HOME/jot/config.toml or ~/.config/jot/config.toml
+func Path() (string, error) {
+	if path := os.Getenv("JOT_CONFIG"); path != "" {
+		return path, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "jot", "config.toml"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", "jot", "config.toml"), nil
+}
+
+// removed lists settings of earlier versions, which are ignored when read
+// and dropped when the config is saved again
+var removed = map[string]bool{"storage.backend": true}
+
+// Load reads the config file at path and the JOT_* environment variables
+// overriding it. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	env, err := readEnv()
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{path: path, values: make(map[string]string), env: env, overrides: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values, err := parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for name, value := range values {
+		if removed[name] {
+			continue
+		}
+		k, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse %s: unknown setting '%s'", path, name)
+		}
+		if err := k.validate(value); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		c.values[name] = value
+	}
+
+	return c, nil
+}
+
+// readEnv returns the settings given by environment variables. A setting
+// can also be read from the file named by its variable with _FILE
+// appended, such as a secret mounted into a container.
+func readEnv() (map[string]string, error) {
+	env := make(map[string]string)
+	for _, k := range keys {
+		name := EnvName(k.Name)
+		value, set := os.LookupEnv(name)
+		if file := os.Getenv(name + "_FILE"); file != "" {
+			if set {
+				return nil, fmt.Errorf("both %s and %s_FILE are set", name, name)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+			}
+			value, set = strings.TrimRight(string(data), "\r\n"), true
+		}
+		if !set {
+			continue
+		}
+		if err := k.validate(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		env[k.Name] = value
+	}
+	return env, nil
+}
+
+// Get returns the value of a setting, or its default when unset
+func (c *Config) Get(name string) (string, error) {
+	k, ok := Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("unknown setting '%s'", name)
+	}
+	if v, ok := c.overrides[name]; ok {
+		return v, nil
+	}
+	if v, ok := c.env[name]; ok {
+		return v, nil
+	}
+	if v, ok := c.values[name]; ok {
+		return v, nil
+	}
+	return k.Default, nil
+}
+
+// IsSet reports whether the setting is explicitly present in the config file
+func (c *Config) IsSet(name string) bool {
+	_, ok := c.values[name]
+	return ok
+}
+
+// FromEnv reports whether the setting is given by an environment variable,
+// which hides the value in the config file
+func (c *Config) FromEnv(name string) bool {
+	_, ok := c.env[name]
+	return ok
+}
+
+// Stored returns the value of a setting in the config file, ignoring the
+// environment
+func (c *Config) Stored(name string) (string, bool) {
+	v, ok := c.values[name]
+	return v, ok
+}
+
+// Override changes a setting for the rest of the process, whatever the file
+// and the environment say, until the returned function undoes it. Nothing
+// is saved.
+func (c *Config) Override(name, value string) (func(), error) {
+	k, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown setting '%s'", name)
+	}
+	if err := k.validate(value); err != nil {
+		return nil, err
+	}
+	previous, had := c.overrides[name]
+	c.overrides[name] = value
+	return func() {
+		if had {
+			c.overrides[name] = previous
+		} else {
+			delete(c.overrides, name)
+		}
+	}, nil
+}
+
+// Set validates and stores a setting. Call Save to persist it.
+func (c *Config) Set(name, value string) error {
+	k, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown setting '%s'", name)
+	}
+	if err := k.validate(value); err != nil {
+		return err
+	}
+	c.values[name] = value
+	return nil
+}
+
+// Unset removes a setting so its default applies again. Call Save to persist it.
+func (c *Config) Unset(name string) error {
+	if _, ok := Lookup(name); !ok {
+		return fmt.Errorf("unknown setting '%s'", name)
+	}
+	delete(c.values, name)
+	return nil
+}
+
+// Save writes the config back to its file
+func (c *Config) Save() error {
+	if c.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(c.path, []byte(format(c.values)), 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// String returns the value of a string setting, falling back to its default
+func (c *Config) String(name string) string {
+	v, _ := c.Get(name)
+	return v
+}
+
+// Bool returns the value of a boolean setting, falling back to its default
+func (c *Config) Bool(name string) bool {
+	v, _ := c.Get(name)
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// Int returns the value of an integer setting, falling back to its default
+func (c *Config) Int(name string) int {
+	v, _ := c.Get(name)
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+// DataDir returns the directory holding all jot data, creating nothing.
+// A leading "~" in the configured path is expanded to the home directory.
+func DataDir() (string, error) {
+	c, err := Current()
+	if err != nil {
+		return "", err
+	}
+	return ExpandHome(c.String("data_dir"))
+}
+
+// ExpandHome replaces a leading "~" in path with the home directory
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parse reads the small subset of TOML used by the config file: comments,
+// [section] headers and key = value pairs whose values are quoted strings,
+// booleans or integers. Keys inside a section are returned as "section.key".
+func parse(data string) (map[string]string, error) {
+	values := make(map[string]string)
+	section := ""
+
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header", n+1)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == "" {
+				return nil, fmt.Errorf("line %d: empty section name", n+1)
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", n+1)
+		}
+		value, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, exists := values[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", n+1, key)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// parseValue decodes a single TOML value, dropping any trailing comment
+func parseValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, `"`) {
+		// Find the closing quote, skipping escaped characters
+		end := -1
+		for i := 1; i < len(raw); i++ {
+			if raw[i] == '\\' {
+				i++
+				continue
+			}
+			if raw[i] == '"' {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected text after string")
+		}
+		return strconv.Unquote(raw[:end+1])
+	}
+
+	if i := strings.Index(raw, "#"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	if raw == "true" || raw == "false" {
+		return raw, nil
+	}
+	if _, err := strconv.Atoi(raw); err == nil {
+		return raw, nil
+	}
+	return "", fmt.Errorf("unsupported value %s (strings must be quoted)", raw)
+}
+
+// format renders values as TOML, top-level keys first and then one table per section
+func format(values map[string]string) string {
+	sections := make(map[string][]string)
+	for name := range values {
+		section := ""
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			section = name[:i]
+		}
+		sections[section] = append(sections[section], name)
+	}
+
+	names := make([]string, 0, len(sections))
+	for s := range sections {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# jot configuration; manage with `jot config set <key> <value>`\n")
+	for _, section := range names {
+		b.WriteString("\n")
+		if section != "" {
+			fmt.Fprintf(&b, "[%s]\n", section)
+		}
+		keys := sections[section]
+		sort.Strings(keys)
+		for _, name := range keys {
+			fmt.Fprintf(&b, "%s = %s\n", strings.TrimPrefix(name, section+"."), formatValue(name, values[name]))
+		}
+	}
+	return b.String()
+}
+
+// formatValue quotes string settings and leaves booleans and integers bare
+func formatValue(name, value string) string {
+	if k, ok := Lookup(name); ok && k.Kind != String {
+		return value
+	}
+	return strconv.Quote(value)
+}
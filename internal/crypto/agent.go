@@ -0,0 +1,257 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/fsutil"
+)
+
+// jot daemon runs a key agent so that the security key is touched once per
+// session rather than once per command: it holds the unwrapped key pairs
+// and decrypts, signs and unwraps for jot commands connecting to a socket
+// in the data directory. Like ssh-agent, it never hands out the keys
+// themselves, so a process that used it cannot go on without the security
+// key once the daemon stops. It refuses to listen in a data directory other
+// users can reach, and commands only ask a socket owned by the user.
+const (
+	agentSocket  = "agent.sock"
+	agentTimeout = 2 * time.Second
+)
+
+// ErrAgentRunning is returned by ListenAgent when another daemon already
+// serves the data directory
+var ErrAgentRunning = errors.New("a jot daemon is already running for this data directory")
+
+// AgentPath returns the location of the key agent's socket
+func AgentPath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, agentSocket), nil
+}
+
+// Unlock asks the security key for the key-encryption key, if one is
+// enrolled, and checks that it unwraps every key pair
+func Unlock() error {
+	keys, err := Keyring()
+	if err != nil {
+		return err
+	}
+	ClearAll(keys)
+	return nil
+}
+
+// ListenAgent opens the key agent's socket, replacing one left behind by a
+// daemon that did not exit cleanly
+func ListenAgent() (net.Listener, error) {
+	path, err := AgentPath()
+	if err != nil {
+		return nil, err
+	}
+	// The data directory may be a link to where it is kept
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find data directory: %w", err)
+	}
+	if err := fsutil.CheckPrivate(dir); err != nil {
+		return nil, fmt.Errorf("refusing to serve keys on a socket other users could reach: %w", err)
+	}
+	if conn, err := net.DialTimeout("unix", path, agentTimeout); err == nil {
+		conn.Close()
+		return nil, ErrAgentRunning
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale agent socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open agent socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to restrict agent socket: %w", err)
+	}
+	return l, nil
+}
+
+// ServeAgent answers requests on l until it is closed. Requests are single
+// lines of a verb and Base64 arguments, answered by "ok" and a Base64
+// result or by "error" and a message:
+//
+//	has <public key>               whether the agent holds that key pair
+//	open <public key> <slot>       the data key of an envelope slot
+//	box <public key> <data>        data in the single-key format, decrypted
+//	sign <public key> <message>    the signature of the member signing key
+//	signing-key <public key>       the public half of that signing key
+//	unwrap <wrapped>               a secret wrapped by WrapSecret
+//
+// The key-encryption key and the private keys never leave the agent.
+func ServeAgent(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("agent socket failed: %w", err)
+		}
+		go answerAgent(conn)
+	}
+}
+
+func answerAgent(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentTimeout))
+
+	request, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	result, err := agentAnswer(strings.Fields(request))
+	if err != nil {
+		fmt.Fprintln(conn, "error", err)
+		return
+	}
+	fmt.Fprintln(conn, "ok", base64.StdEncoding.EncodeToString(result))
+}
+
+// agentAnswer does what a request asks with the keys of this process
+func agentAnswer(fields []string) ([]byte, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty request")
+	}
+	args := make([][]byte, len(fields)-1)
+	for i, field := range fields[1:] {
+		arg, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument")
+		}
+		args[i] = arg
+	}
+	if localKEK() == nil {
+		return nil, fmt.Errorf("no security key unlocked")
+	}
+
+	verb, want := fields[0], 2
+	switch verb {
+	case "has", "signing-key", "unwrap":
+		want = 1
+	case "open", "box", "sign":
+	default:
+		return nil, fmt.Errorf("unknown request")
+	}
+	if len(args) != want {
+		return nil, fmt.Errorf("%s takes %d arguments", verb, want)
+	}
+	if verb == "unwrap" {
+		text, err := UnwrapSecret(string(args[0]))
+		return []byte(text), err
+	}
+
+	keyPair, err := agentKeyPair(args[0])
+	if err != nil {
+		return nil, err
+	}
+	defer keyPair.Clear()
+	switch verb {
+	case "open":
+		return openSlot(args[1], keyPair)
+	case "box":
+		text, err := decryptSingle(args[1], keyPair)
+		return []byte(text), err
+	case "sign":
+		signature, err := keyPair.Sign(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(signature)
+	case "signing-key":
+		public, err := keyPair.SigningKeyString()
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(public)
+	}
+	return nil, nil
+}
+
+// agentKeyPair returns the key pair of the keyring with publicKey
+func agentKeyPair(publicKey []byte) (*KeyPair, error) {
+	keys, err := Keyring()
+	if err != nil {
+		return nil, err
+	}
+	var found *KeyPair
+	for _, k := range keys {
+		if found == nil && k.PrivateKey != nil && bytes.Equal(k.PublicKey[:], publicKey) {
+			found = k
+		} else {
+			k.Clear()
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no such key pair")
+	}
+	return found, nil
+}
+
+// askAgent sends a request to a running daemon, returning its result.
+// errNoAgent is returned when no daemon of this user is running.
+func askAgent(verb string, args ...[]byte) ([]byte, error) {
+	path, err := AgentPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := fsutil.CheckPrivate(path); err != nil {
+		return nil, errNoAgent
+	}
+	conn, err := net.DialTimeout("unix", path, agentTimeout)
+	if err != nil {
+		return nil, errNoAgent
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentTimeout))
+
+	request := []string{verb}
+	for _, arg := range args {
+		request = append(request, base64.StdEncoding.EncodeToString(arg))
+	}
+	if _, err := fmt.Fprintln(conn, strings.Join(request, " ")); err != nil {
+		return nil, fmt.Errorf("failed to ask key agent: %w", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to ask key agent: %w", err)
+	}
+	status, result, _ := strings.Cut(strings.TrimSpace(reply), " ")
+	if status != "ok" {
+		return nil, fmt.Errorf("key agent: %s", result)
+	}
+	data, err := base64.StdEncoding.DecodeString(result)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reply from key agent")
+	}
+	return data, nil
+}
+
+// errNoAgent is returned by askAgent when no daemon is running
+var errNoAgent = errors.New("no key agent running")
+
+// agentHolds reports whether a running daemon holds the key pair with
+// publicKey, so that it can decrypt and sign for this process
+func agentHolds(publicKey *[32]byte) bool {
+	_, err := askAgent("has", publicKey[:])
+	return err == nil
+}
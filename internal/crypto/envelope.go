@@ -0,0 +1,190 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// Envelopes encrypt the text once with a random per-entry key and seal that
+// key to every recipient's public key with an anonymous sealed box, so
+// writing never needs a private key:
+//
+//	"JOTM" | version | count | count × (public key | sealed key) | nonce | secretbox
+const (
+	envelopeMagic   = "JOTM"
+	envelopeVersion = 1
+	sealedKeySize   = 32 + box.AnonymousOverhead
+	slotSize        = 32 + sealedKeySize
+)
+
+// EncryptFor encrypts text for the owner of keyPair and every public key in
+// recipients. Only the public key of keyPair is used.
+func EncryptFor(text string, keyPair *KeyPair, recipients []*[32]byte) ([]byte, error) {
+	return seal(text, append([]*[32]byte{keyPair.PublicKey}, recipients...))
+}
+
+// seal encrypts text under a fresh key sealed to each of the public keys
+// in all
+func seal(text string, all []*[32]byte) ([]byte, error) {
+	if len(all) > 255 {
+		return nil, fmt.Errorf("too many recipients")
+	}
+
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, fmt.Errorf("key generation failed: %w", err)
+	}
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+
+	var out bytes.Buffer
+	out.WriteString(envelopeMagic)
+	out.WriteByte(envelopeVersion)
+	out.WriteByte(byte(len(all)))
+	for _, pub := range all {
+		sealed, err := box.SealAnonymous(nil, key[:], pub, rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seal key to recipient: %w", err)
+		}
+		out.Write(pub[:])
+		out.Write(sealed)
+	}
+
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("nonce generation failed: %w", err)
+	}
+	out.Write(secretbox.Seal(nonce[:], []byte(text), &nonce, &key))
+
+	return out.Bytes(), nil
+}
+
+// openEnvelope decrypts a multi-recipient envelope with keyPair. ok is false
+// when data is not an envelope, so the caller can try the single-key format.
+func openEnvelope(data []byte, keyPair *KeyPair) (text string, ok bool, err error) {
+	if len(data) < 6 || string(data[:4]) != envelopeMagic || data[4] != envelopeVersion {
+		return "", false, nil
+	}
+	count := int(data[5])
+	slots := data[6:]
+	if count == 0 || len(slots) < count*slotSize+24 {
+		return "", false, nil
+	}
+
+	for i := 0; i < count; i++ {
+		slot := slots[i*slotSize : (i+1)*slotSize]
+		if !bytes.Equal(slot[:32], keyPair.PublicKey[:]) {
+			continue
+		}
+		keyBytes, err := openSlot(slot[32:], keyPair)
+		if err != nil {
+			return "", true, err
+		}
+		var key [32]byte
+		copy(key[:], keyBytes)
+
+		rest := slots[count*slotSize:]
+		var nonce [24]byte
+		copy(nonce[:], rest[:24])
+		plain, opened := secretbox.Open(nil, rest[24:], &nonce, &key)
+		for j := range key {
+			key[j] = 0
+		}
+		if !opened {
+			return "", true, fmt.Errorf("decryption failed")
+		}
+		return string(plain), true, nil
+	}
+
+	return "", true, fmt.Errorf("decryption failed: not a recipient")
+}
+
+// openSlot returns the data key sealed in an envelope slot to keyPair,
+// asking the key agent to open it when the agent holds the private key
+func openSlot(sealed []byte, keyPair *KeyPair) ([]byte, error) {
+	if keyPair.agent {
+		keyBytes, err := askAgent("open", keyPair.PublicKey[:], sealed)
+		if err != nil {
+			return nil, fmt.Errorf("decryption failed: %w", err)
+		}
+		if len(keyBytes) != 32 {
+			return nil, fmt.Errorf("decryption failed")
+		}
+		return keyBytes, nil
+	}
+	keyBytes, opened := box.OpenAnonymous(nil, sealed, keyPair.PublicKey, keyPair.PrivateKey)
+	if !opened || len(keyBytes) != 32 {
+		return nil, fmt.Errorf("decryption failed")
+	}
+	return keyBytes, nil
+}
+
+// envelopeKeys returns the public keys an envelope is sealed to. ok is
+// false when data is not an envelope.
+func envelopeKeys(data []byte) (keys []*[32]byte, ok bool) {
+	if len(data) < 6 || string(data[:4]) != envelopeMagic || data[4] != envelopeVersion {
+		return nil, false
+	}
+	count := int(data[5])
+	slots := data[6:]
+	if count == 0 || len(slots) < count*slotSize+24 {
+		return nil, false
+	}
+	for i := 0; i < count; i++ {
+		var key [32]byte
+		copy(key[:], slots[i*slotSize:])
+		keys = append(keys, &key)
+	}
+	return keys, true
+}
+
+// SealedTo reports whether data is an envelope sealed to the public key pub
+func SealedTo(data []byte, pub *[32]byte) bool {
+	keys, _ := envelopeKeys(data)
+	for _, k := range keys {
+		if *k == *pub {
+			return true
+		}
+	}
+	return false
+}
+
+// Reseal re-encrypts an envelope sealed to the public key drop under a
+// fresh key, for every other key it was sealed to, opening it with any of
+// keys. It reports whether data was sealed to drop; when it was not, as
+// with other formats, data is returned as is without being decrypted.
+func Reseal(data []byte, keys []*KeyPair, drop *[32]byte) ([]byte, bool, error) {
+	sealedTo, ok := envelopeKeys(data)
+	if !ok {
+		return data, false, nil
+	}
+	kept := make([]*[32]byte, 0, len(sealedTo))
+	for _, pub := range sealedTo {
+		if *pub != *drop {
+			kept = append(kept, pub)
+		}
+	}
+	if len(kept) == len(sealedTo) {
+		return data, false, nil
+	}
+	if len(kept) == 0 {
+		return nil, false, fmt.Errorf("data is sealed to no other key")
+	}
+
+	text, err := DecryptWithKeyring(data, keys)
+	if err != nil {
+		return nil, false, err
+	}
+	resealed, err := seal(text, kept)
+	if err != nil {
+		return nil, false, err
+	}
+	return resealed, true, nil
+}
@@ -0,0 +1,370 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// With a FIDO2 security key enrolled, private keys are stored wrapped by a
+// key-encryption key that only the token can produce: the hmac-secret
+// extension output for a fixed salt. The libfido2 command-line tools talk to
+// the token.
+const (
+	fido2File          = "fido2.json"
+	naclWrappedKeyFile = "jot.sec.fido2"
+	fido2RelyingParty  = "jot"
+)
+
+// fido2Credential identifies the credential and salt used to derive the
+// key-encryption key
+type fido2Credential struct {
+	CredentialID string `json:"credential_id"`
+	Salt         string `json:"salt"`
+}
+
+var (
+	kekMu sync.Mutex
+	kek   *[32]byte // Key-encryption key, cached so each process asks for one touch
+)
+
+func fido2Path() (string, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fido2File), nil
+}
+
+// FIDO2Enrolled reports whether private keys are wrapped by a security key
+func FIDO2Enrolled() bool {
+	path, err := fido2Path()
+	if err != nil {
+		return false
+	}
+	_, err = storage.Stat(path)
+	return err == nil
+}
+
+// EnrollFIDO2 creates a credential on the security key at device (empty uses
+// the first one found) and wraps every stored private key with it. Afterwards
+// the private keys can only be unwrapped with the token present.
+func EnrollFIDO2(device string) error {
+	if FIDO2Enrolled() {
+		return fmt.Errorf("a security key is already enrolled")
+	}
+	if HasPendingKey() {
+		return fmt.Errorf("a key rotation is unfinished; run jot key rotate first")
+	}
+	if device == "" {
+		var err error
+		if device, err = fido2Device(); err != nil {
+			return err
+		}
+	}
+
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+	dirs := []string{dir}
+	retired, err := storage.ReadDir(filepath.Join(dir, retiredKeyDir))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read retired keys: %w", err)
+	}
+	for _, d := range retired {
+		if d.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, retiredKeyDir, d.Name()))
+		}
+	}
+
+	// Read every key before anything is wrapped
+	keys := make([]*KeyPair, 0, len(dirs))
+	defer func() { ClearAll(keys) }()
+	for _, d := range dirs {
+		k, err := restoreKeyFiles(d)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	fmt.Fprintln(os.Stderr, "Touch your security key to create a credential...")
+	credID, err := fido2MakeCredential(device)
+	if err != nil {
+		return err
+	}
+	cred := fido2Credential{CredentialID: credID}
+	var salt [32]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return fmt.Errorf("salt generation failed: %w", err)
+	}
+	cred.Salt = base64.StdEncoding.EncodeToString(salt[:])
+
+	fmt.Fprintln(os.Stderr, "Touch your security key again to confirm...")
+	secret, err := fido2HMACSecret(device, cred)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cred, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential: %w", err)
+	}
+	path := filepath.Join(dir, fido2File)
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+
+	kekMu.Lock()
+	kek = secret
+	kekMu.Unlock()
+
+	// writeKeyFiles wraps the private key now that a credential is enrolled
+	for i, d := range dirs {
+		err := writeKeyFiles(d,
+			base64.StdEncoding.EncodeToString(keys[i].PublicKey[:]),
+			base64.StdEncoding.EncodeToString(keys[i].PrivateKey[:]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localKEK returns the key-encryption key if this process holds it
+func localKEK() *[32]byte {
+	kekMu.Lock()
+	defer kekMu.Unlock()
+	return kek
+}
+
+// keyEncryptionKey returns the key that wraps private keys, asking the
+// security key for it on first use
+func keyEncryptionKey() (*[32]byte, error) {
+	kekMu.Lock()
+	defer kekMu.Unlock()
+	if kek != nil {
+		return kek, nil
+	}
+
+	path, err := fido2Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security key credential: %w", err)
+	}
+	var cred fido2Credential
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return nil, fmt.Errorf("failed to parse security key credential: %w", err)
+	}
+
+	device, err := fido2Device()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, "Touch your security key to unlock jot...")
+	secret, err := fido2HMACSecret(device, cred)
+	if err != nil {
+		return nil, err
+	}
+	kek = secret
+	return kek, nil
+}
+
+// Secrets other than private keys are wrapped with a key derived from the
+// key-encryption key, so that the key agent can unwrap them for other
+// processes without being able to unwrap a private key for them
+const secretKeyPurpose = "jot wrapped secrets"
+
+// secretKey returns the key that wraps secrets other than private keys
+func secretKey() (*[32]byte, error) {
+	key, err := keyEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(secretKeyPurpose))
+	var derived [32]byte
+	copy(derived[:], mac.Sum(nil))
+	return &derived, nil
+}
+
+// wrapPrivateKey encrypts a Base64 private key with the key-encryption key
+func wrapPrivateKey(privKeyStr string) (string, error) {
+	key, err := keyEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	return sealSecret(privKeyStr, key)
+}
+
+// unwrapPrivateKey reverses wrapPrivateKey
+func unwrapPrivateKey(wrapped string) (string, error) {
+	key, err := keyEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	return openSecret(wrapped, key)
+}
+
+// WrapSecret encrypts a secret kept with the key pairs, such as the master
+// keys, under the security key
+func WrapSecret(secret string) (string, error) {
+	key, err := secretKey()
+	if err != nil {
+		return "", err
+	}
+	return sealSecret(secret, key)
+}
+
+// UnwrapSecret reverses WrapSecret, asking a running jot daemon to unwrap
+// it rather than the security key when this process has not been unlocked
+func UnwrapSecret(wrapped string) (string, error) {
+	if localKEK() == nil {
+		plain, err := askAgent("unwrap", []byte(strings.TrimSpace(wrapped)))
+		if err == nil {
+			return string(plain), nil
+		}
+		if err != errNoAgent {
+			return "", err
+		}
+	}
+	key, err := secretKey()
+	if err != nil {
+		return "", err
+	}
+	return openSecret(wrapped, key)
+}
+
+func sealSecret(secret string, key *[32]byte) (string, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", fmt.Errorf("nonce generation failed: %w", err)
+	}
+	sealed := secretbox.Seal(nonce[:], []byte(secret), &nonce, key)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openSecret(wrapped string, key *[32]byte) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(wrapped))
+	if err != nil || len(sealed) < 24 {
+		return "", fmt.Errorf("invalid wrapped key")
+	}
+	var nonce [24]byte
+	copy(nonce[:], sealed[:24])
+	plain, ok := secretbox.Open(nil, sealed[24:], &nonce, key)
+	if !ok {
+		return "", fmt.Errorf("failed to unwrap key: wrong security key?")
+	}
+	return string(plain), nil
+}
+
+// fido2Device returns the configured security key, or the first one found
+func fido2Device() (string, error) {
+	if cfg, err := config.Current(); err == nil {
+		if device := cfg.String("crypto.fido2_device"); device != "" {
+			return device, nil
+		}
+	}
+
+	out, err := run("fido2-token", nil, "-L")
+	if err != nil {
+		return "", fmt.Errorf("failed to list security keys (is libfido2 installed?): %w", err)
+	}
+	// Lines look like "/dev/hidraw4: vendor=0x1050, product=0x0407 (Yubico ...)"
+	for _, line := range strings.Split(string(out), "\n") {
+		if i := strings.Index(line, ": "); i > 0 {
+			return line[:i], nil
+		}
+	}
+	return "", fmt.Errorf("no security key found; plug it in and try again")
+}
+
+// fido2MakeCredential creates a credential with the hmac-secret extension and
+// returns its Base64 ID
+func fido2MakeCredential(device string) (string, error) {
+	userID := make([]byte, 32)
+	if _, err := rand.Read(userID); err != nil {
+		return "", fmt.Errorf("failed to generate user ID: %w", err)
+	}
+	input := fido2Input(
+		clientDataHash(),
+		fido2RelyingParty,
+		"jot",
+		base64.StdEncoding.EncodeToString(userID),
+	)
+
+	out, err := run("fido2-cred", input, "-M", "-h", device)
+	if err != nil {
+		return "", fmt.Errorf("failed to create credential: %w", err)
+	}
+	// Output: client data hash, relying party, format, authenticator data,
+	// credential ID, ...
+	lines := fido2Lines(out)
+	if len(lines) < 5 {
+		return "", fmt.Errorf("failed to create credential: unexpected fido2-cred output")
+	}
+	return lines[4], nil
+}
+
+// fido2HMACSecret asks the token for the hmac-secret output of cred
+func fido2HMACSecret(device string, cred fido2Credential) (*[32]byte, error) {
+	input := fido2Input(clientDataHash(), fido2RelyingParty, cred.CredentialID, cred.Salt)
+
+	out, err := run("fido2-assert", input, "-G", "-h", device)
+	if err != nil {
+		return nil, fmt.Errorf("security key assertion failed: %w", err)
+	}
+	// Output: client data hash, relying party, authenticator data,
+	// signature, hmac-secret
+	lines := fido2Lines(out)
+	if len(lines) < 5 {
+		return nil, fmt.Errorf("security key returned no hmac-secret; does it support the extension?")
+	}
+	secret, err := base64.StdEncoding.DecodeString(lines[4])
+	if err != nil || len(secret) != 32 {
+		return nil, fmt.Errorf("security key returned an invalid hmac-secret")
+	}
+
+	var key [32]byte
+	copy(key[:], secret)
+	return &key, nil
+}
+
+// clientDataHash returns a random Base64 client data hash; jot only needs
+// the hmac-secret, not a verifiable signature
+func clientDataHash() string {
+	var challenge [32]byte
+	rand.Read(challenge[:])
+	sum := sha256.Sum256(challenge[:])
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func fido2Input(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func fido2Lines(out []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	return lines
+}
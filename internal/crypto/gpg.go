@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gpgMagic marks entry bodies encrypted by GPG rather than NaCl
+const gpgMagic = "JOTG"
+
+// IsGPG reports whether data was produced by EncryptGPG
+func IsGPG(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(gpgMagic))
+}
+
+// EncryptGPG encrypts text to recipient (a key ID, fingerprint or email)
+// with the user's gpg installation. The result is marked so DecryptGPG and
+// IsGPG recognise it.
+func EncryptGPG(text, recipient string) ([]byte, error) {
+	out, err := run("gpg", []byte(text), "--batch", "--quiet", "--encrypt", "--recipient", recipient)
+	if err != nil {
+		return nil, fmt.Errorf("gpg encryption failed: %w", err)
+	}
+	return append([]byte(gpgMagic), out...), nil
+}
+
+// ArmorGPG encrypts text to recipient as an ASCII-armored OpenPGP message,
+// which any OpenPGP tool can decrypt
+func ArmorGPG(text, recipient string) (string, error) {
+	out, err := run("gpg", []byte(text), "--batch", "--quiet", "--armor", "--encrypt", "--recipient", recipient)
+	if err != nil {
+		return "", fmt.Errorf("gpg encryption failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// DecryptGPG decrypts data produced by EncryptGPG. gpg-agent takes care of
+// passphrase, smartcard PIN and touch prompts.
+func DecryptGPG(data []byte) (string, error) {
+	if !IsGPG(data) {
+		return "", fmt.Errorf("not a GPG encrypted entry")
+	}
+	out, err := run("gpg", data[len(gpgMagic):], "--quiet", "--decrypt")
+	if err != nil {
+		return "", fmt.Errorf("gpg decryption failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// run executes an external tool with args, feeding it input and returning its output
+func run(name string, input []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,201 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const (
+	// pendingKeyDir holds the next key pair while a rotation is in progress
+	pendingKeyDir = "pending"
+	// retiredKeyDir holds previous key pairs, one sub-directory per rotation
+	retiredKeyDir = "retired"
+)
+
+// backupDir returns the directory holding the key pairs, which is
+// crypto.key_dir when set
+func backupDir() (string, error) {
+	cfg, err := config.Current()
+	if err != nil {
+		return "", err
+	}
+	if dir := cfg.String("crypto.key_dir"); dir != "" {
+		return config.ExpandHome(dir)
+	}
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, naclBackupDir), nil
+}
+
+// KeyPairDir returns the directory holding the key pairs: crypto.key_dir,
+// or backup/ in the data directory
+func KeyPairDir() (string, error) {
+	return backupDir()
+}
+
+// GeneratePendingKey creates the key pair a rotation will switch to
+func GeneratePendingKey() error {
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key pair: %w", err)
+	}
+
+	return writeKeyFiles(filepath.Join(dir, pendingKeyDir),
+		base64.StdEncoding.EncodeToString(publicKey[:]),
+		base64.StdEncoding.EncodeToString(privateKey[:]))
+}
+
+// RestorePendingKey reads the key pair of the rotation in progress
+func RestorePendingKey() (*KeyPair, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return nil, err
+	}
+	return restoreKeyFiles(filepath.Join(dir, pendingKeyDir))
+}
+
+// HasPendingKey reports whether a rotation has staged a new key pair
+func HasPendingKey() bool {
+	dir, err := backupDir()
+	if err != nil {
+		return false
+	}
+	return hasKeyFiles(filepath.Join(dir, pendingKeyDir))
+}
+
+// PromotePendingKey makes the pending key pair current. The current pair is
+// first copied to the retired directory so that data still encrypted with it
+// remains readable. Each step is safe to repeat if interrupted.
+func PromotePendingKey() error {
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+	pendingDir := filepath.Join(dir, pendingKeyDir)
+	defer ForgetKeys()
+
+	// Retire the current pair unless an interrupted promotion already
+	// started moving the pending files into place
+	if _, err := storage.Stat(filepath.Join(pendingDir, naclPubKeyFile)); err == nil {
+		// The files are copied as they are, so a key the agent holds or
+		// that is wrapped by the security key is retired without unwrapping
+		retired := filepath.Join(dir, retiredKeyDir, strconv.FormatInt(time.Now().UnixNano(), 10))
+		if err := copyKeyFiles(dir, retired); err != nil {
+			return fmt.Errorf("failed to retire current key: %w", err)
+		}
+	}
+
+	for _, name := range []string{naclPubKeyFile, naclSecKeyFile, naclWrappedKeyFile} {
+		err := storage.Rename(filepath.Join(pendingDir, name), filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to promote %s: %w", name, err)
+		}
+	}
+
+	if err := storage.RemoveAll(pendingDir); err != nil {
+		return fmt.Errorf("failed to remove pending key: %w", err)
+	}
+	return nil
+}
+
+// copyKeyFiles copies the key pair stored in dir to dest
+func copyKeyFiles(dir, dest string) error {
+	if !hasKeyFiles(dir) {
+		return ErrPrivateKeyOffline
+	}
+	if err := storage.MkdirAll(dest, 0700); err != nil {
+		return err
+	}
+	for _, name := range []string{naclPubKeyFile, naclSecKeyFile, naclWrappedKeyFile} {
+		data, err := storage.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		perm := os.FileMode(0600)
+		if name == naclPubKeyFile {
+			perm = 0644
+		}
+		if err := storage.WriteFile(filepath.Join(dest, name), data, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keyring returns every key pair that may have encrypted stored data: the
+// current pair first, then the pending pair of an unfinished rotation, then
+// retired pairs from newest to oldest. Call Clear on each when done.
+func Keyring() ([]*KeyPair, error) {
+	current, err := RestoreNaclFromBackup()
+	if err != nil {
+		return nil, err
+	}
+	keys := []*KeyPair{current}
+
+	if HasPendingKey() {
+		pending, err := RestorePendingKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pending)
+	}
+
+	dir, err := backupDir()
+	if err != nil {
+		return nil, err
+	}
+	retired, err := storage.ReadDir(filepath.Join(dir, retiredKeyDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read retired keys: %w", err)
+	}
+	sort.Slice(retired, func(a, b int) bool { return retired[a].Name() > retired[b].Name() })
+	for _, d := range retired {
+		if !d.IsDir() {
+			continue
+		}
+		k, err := restoreKeyFiles(filepath.Join(dir, retiredKeyDir, d.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore retired key %s: %w", d.Name(), err)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// DecryptWithKeyring decrypts data with the first key pair in keys that opens it
+func DecryptWithKeyring(data []byte, keys []*KeyPair) (string, error) {
+	for _, k := range keys {
+		if text, err := DecryptNacl(data, k); err == nil {
+			return text, nil
+		}
+	}
+	return "", fmt.Errorf("decryption failed")
+}
+
+// ClearAll zeros every key pair in keys
+func ClearAll(keys []*KeyPair) {
+	for _, k := range keys {
+		k.Clear()
+	}
+}
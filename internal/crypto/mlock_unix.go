@@ -0,0 +1,24 @@
+//go:build !windows
+
+package crypto
+
+import "golang.org/x/sys/unix"
+
+// lockedAlloc maps size bytes of memory of their own and locks them, so
+// that keys kept there are never written to swap
+func lockedAlloc(size int) ([]byte, error) {
+	data, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Mlock(data); err != nil {
+		unix.Munmap(data)
+		return nil, err
+	}
+	return data, nil
+}
+
+func lockedFree(data []byte) {
+	unix.Munlock(data)
+	unix.Munmap(data)
+}
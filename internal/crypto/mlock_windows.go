@@ -0,0 +1,29 @@
+//go:build windows
+
+package crypto
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockedAlloc allocates size bytes of memory of their own and locks them,
+// so that keys kept there are never written to the page file
+func lockedAlloc(size int) ([]byte, error) {
+	addr, err := windows.VirtualAlloc(0, uintptr(size), windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, err
+	}
+	if err := windows.VirtualLock(addr, uintptr(size)); err != nil {
+		windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, err
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(addr)), size), nil
+}
+
+func lockedFree(data []byte) {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	windows.VirtualUnlock(addr, uintptr(len(data)))
+	windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+}
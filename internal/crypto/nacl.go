@@ -7,11 +7,12 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/veritome/jot/internal/storage"
 	"golang.org/x/crypto/nacl/box"
 )
 
 const (
-	naclBackupDir  = ".jot/backup"
+	naclBackupDir  = "backup"
 	naclPubKeyFile = "jot.pub"
 	naclSecKeyFile = "jot.sec"
 )
@@ -20,6 +21,10 @@ const (
 type KeyPair struct {
 	PublicKey  *[32]byte
 	PrivateKey *[32]byte
+
+	// agent is set when PrivateKey is nil because a running jot daemon
+	// holds the private key, and decrypts and signs with it for this process
+	agent bool
 }
 
 // GenerateNaclKey generates a new NaCl key pair for the journal
@@ -43,76 +48,179 @@ func GenerateNaclKey() (string, error) {
 
 // backupNaclKey exports and saves both public and private keys to the backup directory
 func backupNaclKey(pubKeyStr, privKeyStr string) error {
-	homeDir, err := os.UserHomeDir()
+	dir, err := backupDir()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
 	}
 
-	backupPath := filepath.Join(homeDir, naclBackupDir)
-	if err := os.MkdirAll(backupPath, 0700); err != nil {
+	return writeKeyFiles(dir, pubKeyStr, privKeyStr)
+}
+
+// writeKeyFiles saves a Base64 encoded key pair into dir
+func writeKeyFiles(backupPath, pubKeyStr, privKeyStr string) error {
+	defer ForgetKeys()
+	if err := storage.MkdirAll(backupPath, 0700); err != nil {
 		return fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
 	// Save public key
 	pubKeyPath := filepath.Join(backupPath, naclPubKeyFile)
-	if err := os.WriteFile(pubKeyPath, []byte(pubKeyStr), 0644); err != nil {
+	if err := storage.WriteFile(pubKeyPath, []byte(pubKeyStr), 0644); err != nil {
 		return fmt.Errorf("failed to save public key backup: %w", err)
 	}
 
-	// Save private key with restricted permissions
+	// Save private key with restricted permissions, wrapped by the security
+	// key if one is enrolled
 	secKeyPath := filepath.Join(backupPath, naclSecKeyFile)
-	if err := os.WriteFile(secKeyPath, []byte(privKeyStr), 0600); err != nil {
+	if FIDO2Enrolled() {
+		wrapped, err := wrapPrivateKey(privKeyStr)
+		if err != nil {
+			return err
+		}
+		if err := storage.WriteFile(filepath.Join(backupPath, naclWrappedKeyFile), []byte(wrapped), 0600); err != nil {
+			return fmt.Errorf("failed to save private key backup: %w", err)
+		}
+		if err := storage.Remove(secKeyPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove unwrapped private key: %w", err)
+		}
+		return nil
+	}
+	if err := storage.WriteFile(secKeyPath, []byte(privKeyStr), 0600); err != nil {
 		return fmt.Errorf("failed to save private key backup: %w", err)
 	}
 
 	return nil
 }
 
+// hasKeyFiles reports whether dir holds a key pair, wrapped or not
+func hasKeyFiles(dir string) bool {
+	if _, err := storage.Stat(filepath.Join(dir, naclPubKeyFile)); err != nil {
+		return false
+	}
+	for _, name := range []string{naclSecKeyFile, naclWrappedKeyFile} {
+		if _, err := storage.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyDir returns the directory holding the key pairs of the data directory
+// dataDir. If dir itself holds a key pair, such as a copy of that directory,
+// dir is returned as is.
+func KeyDir(dir string) string {
+	if hasKeyFiles(dir) {
+		return dir
+	}
+	return filepath.Join(dir, naclBackupDir)
+}
+
+// HasKeyPair reports whether dir holds a key pair, wrapped or not
+func HasKeyPair(dir string) bool {
+	return hasKeyFiles(dir)
+}
+
+// HasKey reports whether a key pair exists, without unwrapping it. The
+// public key is enough, since the private key may be kept offline.
+func HasKey() bool {
+	dir, err := backupDir()
+	if err != nil {
+		return false
+	}
+	_, err = storage.Stat(filepath.Join(dir, naclPubKeyFile))
+	return err == nil
+}
+
+// HasPrivateKey reports whether the current private key is on this machine,
+// wrapped or not
+func HasPrivateKey() bool {
+	dir, err := backupDir()
+	if err != nil {
+		return false
+	}
+	return hasKeyFiles(dir)
+}
+
+// RestorePublicKey reads only the public half of the current key pair, which
+// never needs the security key. PrivateKey is nil.
+func RestorePublicKey() (*KeyPair, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return nil, err
+	}
+	return provider.Public(dir)
+}
+
 // RestoreNaclFromBackup attempts to restore the NaCl key pair from backup
 func RestoreNaclFromBackup() (*KeyPair, error) {
-	homeDir, err := os.UserHomeDir()
+	dir, err := backupDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
 	}
 
-	backupPath := filepath.Join(homeDir, naclBackupDir)
+	return restoreKeyFiles(dir)
+}
+
+// restoreKeyFiles returns the key pair stored in backupPath, read once per
+// process
+func restoreKeyFiles(backupPath string) (*KeyPair, error) {
+	return provider.Restore(backupPath)
+}
+
+// readKeyFiles reads the key pair stored in backupPath
+func readKeyFiles(backupPath string) (*KeyPair, error) {
 	pubKeyPath := filepath.Join(backupPath, naclPubKeyFile)
 	secKeyPath := filepath.Join(backupPath, naclSecKeyFile)
 
 	// Check if backup files exist
-	if _, err := os.Stat(pubKeyPath); err != nil {
+	if _, err := storage.Stat(pubKeyPath); err != nil {
 		return nil, fmt.Errorf("public key backup not found: %w", err)
 	}
-	if _, err := os.Stat(secKeyPath); err != nil {
-		return nil, fmt.Errorf("private key backup not found: %w", err)
+	wrapped := false
+	if _, err := storage.Stat(secKeyPath); err != nil {
+		secKeyPath = filepath.Join(backupPath, naclWrappedKeyFile)
+		if _, werr := storage.Stat(secKeyPath); werr != nil {
+			return nil, ErrPrivateKeyOffline
+		}
+		wrapped = true
 	}
 
 	// Read public key
-	pubKeyData, err := os.ReadFile(pubKeyPath)
+	pubKeyData, err := storage.ReadFile(pubKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read public key: %w", err)
 	}
+	pubKeyBytes, err := base64.StdEncoding.DecodeString(string(pubKeyData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	var publicKey [32]byte
+	copy(publicKey[:], pubKeyBytes)
+
+	// A running daemon that holds the key spares this process the touch of
+	// the security key
+	if wrapped && localKEK() == nil && agentHolds(&publicKey) {
+		return &KeyPair{PublicKey: &publicKey, agent: true}, nil
+	}
 
 	// Read private key
-	privKeyData, err := os.ReadFile(secKeyPath)
+	privKeyData, err := storage.ReadFile(secKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read private key: %w", err)
 	}
-
-	// Decode keys from Base64
-	pubKeyBytes, err := base64.StdEncoding.DecodeString(string(pubKeyData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	if wrapped {
+		plain, err := unwrapPrivateKey(string(privKeyData))
+		if err != nil {
+			return nil, err
+		}
+		privKeyData = []byte(plain)
 	}
 
 	privKeyBytes, err := base64.StdEncoding.DecodeString(string(privKeyData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode private key: %w", err)
 	}
-
-	// Convert to key pair
-	var publicKey, privateKey [32]byte
-	copy(publicKey[:], pubKeyBytes)
+	var privateKey [32]byte
 	copy(privateKey[:], privKeyBytes)
 
 	return &KeyPair{
@@ -121,21 +229,24 @@ func RestoreNaclFromBackup() (*KeyPair, error) {
 	}, nil
 }
 
-// EncryptNacl encrypts the given text using NaCl box
-func EncryptNacl(text string, keyPair *KeyPair) ([]byte, error) {
-	// Generate random nonce
-	var nonce [24]byte
-	if _, err := rand.Read(nonce[:]); err != nil {
-		return nil, fmt.Errorf("nonce generation failed: %w", err)
+// DecryptNacl decrypts envelopes written by EncryptFor, as well as data
+// sealed with the single-key box format of earlier versions
+func DecryptNacl(data []byte, keyPair *KeyPair) (string, error) {
+	text, isEnvelope, envErr := openEnvelope(data, keyPair)
+	if isEnvelope && envErr == nil {
+		return text, nil
 	}
 
-	// Encrypt message
-	encrypted := box.Seal(nonce[:], []byte(text), &nonce, keyPair.PublicKey, keyPair.PrivateKey)
-	return encrypted, nil
+	text, err := decryptSingle(data, keyPair)
+	if err != nil && isEnvelope {
+		return "", envErr
+	}
+	return text, err
 }
 
-// DecryptNacl decrypts the given data using NaCl box
-func DecryptNacl(data []byte, keyPair *KeyPair) (string, error) {
+// decryptSingle decrypts data in the single-key format: a nonce followed by
+// a box from the key pair to itself
+func decryptSingle(data []byte, keyPair *KeyPair) (string, error) {
 	if len(data) < 24 {
 		return "", fmt.Errorf("invalid encrypted data: too short")
 	}
@@ -144,6 +255,14 @@ func DecryptNacl(data []byte, keyPair *KeyPair) (string, error) {
 	var nonce [24]byte
 	copy(nonce[:], data[:24])
 
+	if keyPair.agent {
+		decrypted, err := askAgent("box", keyPair.PublicKey[:], data)
+		if err != nil {
+			return "", fmt.Errorf("decryption failed: %w", err)
+		}
+		return string(decrypted), nil
+	}
+
 	// Decrypt message
 	decrypted, ok := box.Open(nil, data[24:], &nonce, keyPair.PublicKey, keyPair.PrivateKey)
 	if !ok {
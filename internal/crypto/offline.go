@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// Entries are sealed to public keys, so the private keys can live offline,
+// on a USB stick for example, while jot keeps writing entries. Only reading
+// needs them back.
+
+// ErrPrivateKeyOffline is returned when data has to be decrypted but the
+// private key is not on this machine
+var ErrPrivateKeyOffline = errors.New("the private key is not on this machine; writing works without it, but reading needs it back: jot key online <dir>")
+
+// privateKeyFiles returns the private key files of the current and retired
+// key pairs under dir, relative to it
+func privateKeyFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == pendingKeyDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name := d.Name(); name == naclSecKeyFile || name == naclWrappedKeyFile {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list private keys: %w", err)
+	}
+	return files, nil
+}
+
+// MoveKeysOffline copies every private key, current and retired, to dest
+// with the layout of the backup directory, then removes them from this
+// machine. Keys dest already holds identical copies of are not copied again.
+// It returns the number of keys moved.
+func MoveKeysOffline(dest string) (int, error) {
+	if HasPendingKey() {
+		return 0, fmt.Errorf("a key rotation is unfinished; run jot key rotate first")
+	}
+	dir, err := backupDir()
+	if err != nil {
+		return 0, err
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve %s: %w", dest, err)
+	}
+	if strings.HasPrefix(dest+string(filepath.Separator), dir+string(filepath.Separator)) {
+		return 0, fmt.Errorf("%s is inside the key directory", dest)
+	}
+
+	files, err := privateKeyFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, ErrPrivateKeyOffline
+	}
+
+	// Every key is safely in dest before any is removed
+	defer ForgetKeys()
+	for _, rel := range files {
+		if err := copyKeyFile(filepath.Join(dir, rel), filepath.Join(dest, rel)); err != nil {
+			return 0, err
+		}
+	}
+	for _, rel := range files {
+		if err := os.Remove(filepath.Join(dir, rel)); err != nil {
+			return 0, fmt.Errorf("failed to remove %s: %w", rel, err)
+		}
+	}
+	return len(files), nil
+}
+
+// BringKeysOnline copies the private keys moved to src by MoveKeysOffline
+// back into place, leaving src untouched. Each key must belong to the public
+// key next to its destination. It returns the number of keys restored.
+func BringKeysOnline(src string) (int, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return 0, err
+	}
+	files, err := privateKeyFiles(src)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no private keys found in %s", src)
+	}
+
+	for _, rel := range files {
+		target := filepath.Join(dir, rel)
+		if err := checkKeyMatches(filepath.Join(src, rel), filepath.Join(filepath.Dir(target), naclPubKeyFile)); err != nil {
+			return 0, fmt.Errorf("%s: %w", rel, err)
+		}
+	}
+	defer ForgetKeys()
+	for _, rel := range files {
+		if err := copyKeyFile(filepath.Join(src, rel), filepath.Join(dir, rel)); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// checkKeyMatches verifies that the private key at secPath belongs to the
+// public key at pubPath. Keys wrapped by a security key cannot be checked
+// without it and are only required to have a public key.
+func checkKeyMatches(secPath, pubPath string) error {
+	pubData, err := os.ReadFile(pubPath)
+	if err != nil {
+		return fmt.Errorf("no matching public key: %w", err)
+	}
+	if filepath.Base(secPath) == naclWrappedKeyFile {
+		return nil
+	}
+
+	secData, err := os.ReadFile(secPath)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+	pub, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(pubData)))
+	if err != nil {
+		return fmt.Errorf("failed to decode public key: %w", err)
+	}
+	sec, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(secData)))
+	if err != nil {
+		return fmt.Errorf("failed to decode private key: %w", err)
+	}
+	derived, err := curve25519.X25519(sec, curve25519.Basepoint)
+	for i := range sec {
+		sec[i] = 0
+	}
+	if err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+	if subtle.ConstantTimeCompare(derived, pub) != 1 {
+		return fmt.Errorf("private key does not belong to this installation's public key")
+	}
+	return nil
+}
+
+// copyKeyFile copies a private key file to dst. An identical existing copy
+// is kept; a different one is an error rather than being overwritten.
+func copyKeyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	if existing, err := os.ReadFile(dst); err == nil {
+		if !bytes.Equal(existing, data) {
+			return fmt.Errorf("%s already holds a different key", dst)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	// The original is removed next, so the copy has to be on disk first
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", dst, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,226 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// KeyProvider keeps the key pairs it reads, so that a command encrypting
+// or decrypting many entries reads and unwraps each key once rather than
+// once per entry. A cached pair is checked against the size and time of its
+// files before use, so keys changed by another jot process are read again.
+//
+// With crypto.key_cache set to locked, the cached keys live in memory
+// locked against swapping; where that is not allowed, they are kept in
+// ordinary memory. Callers get copies, which they clear as before.
+type KeyProvider struct {
+	mu   sync.Mutex
+	keys map[string]*cachedKey // Key directory -> its key pair
+}
+
+// cachedKey is a key pair and the state of the files it was read from
+type cachedKey struct {
+	buf    *keyBuffer
+	pair   KeyPair // PrivateKey is nil when only the public key was read, or the agent holds it
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// keyBuffer is memory holding a public and a private key
+type keyBuffer struct {
+	data   []byte
+	locked bool
+}
+
+// provider caches the key pairs of this process
+var provider = &KeyProvider{}
+
+// ForgetKeys clears every key pair cached by this process
+func ForgetKeys() {
+	provider.Forget()
+}
+
+// Restore returns a copy of the key pair stored in dir
+func (p *KeyProvider) Restore(dir string) (*KeyPair, error) {
+	mode := cacheMode()
+	if mode == "off" {
+		return readKeyFiles(dir)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c := p.fresh(dir); c != nil && (c.pair.PrivateKey != nil || c.pair.agent) {
+		return c.copy(), nil
+	}
+	k, err := readKeyFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	p.store(dir, k, mode == "locked")
+	return k, nil
+}
+
+// Public returns a copy of the public key stored in dir. PrivateKey is nil.
+func (p *KeyProvider) Public(dir string) (*KeyPair, error) {
+	mode := cacheMode()
+	if mode == "off" {
+		return readPublicKey(dir)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c := p.fresh(dir); c != nil {
+		k := c.copy()
+		k.PrivateKey, k.agent = nil, false
+		return k, nil
+	}
+	k, err := readPublicKey(dir)
+	if err != nil {
+		return nil, err
+	}
+	p.store(dir, k, mode == "locked")
+	return k, nil
+}
+
+// Forget clears every cached key pair
+func (p *KeyProvider) Forget() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for dir, c := range p.keys {
+		c.buf.free()
+		delete(p.keys, dir)
+	}
+}
+
+// fresh returns the cached pair of dir if its files are unchanged,
+// dropping it otherwise
+func (p *KeyProvider) fresh(dir string) *cachedKey {
+	c, ok := p.keys[dir]
+	if !ok {
+		return nil
+	}
+	for path, stamp := range c.stamps {
+		if current, err := statKeyFile(path); err != nil || current != stamp {
+			c.buf.free()
+			delete(p.keys, dir)
+			return nil
+		}
+	}
+	return c
+}
+
+// store caches a copy of k, read from dir
+func (p *KeyProvider) store(dir string, k *KeyPair, locked bool) {
+	c := &cachedKey{buf: newKeyBuffer(locked), stamps: make(map[string]fileStamp)}
+	paths := []string{filepath.Join(dir, naclPubKeyFile)}
+	if k.PrivateKey != nil || k.agent {
+		paths = append(paths, secKeyPath(dir))
+	}
+	for _, path := range paths {
+		stamp, err := statKeyFile(path)
+		if err != nil {
+			c.buf.free()
+			return
+		}
+		c.stamps[path] = stamp
+	}
+
+	c.pair.PublicKey = (*[32]byte)(c.buf.data[:32])
+	*c.pair.PublicKey = *k.PublicKey
+	c.pair.agent = k.agent
+	if k.PrivateKey != nil {
+		c.pair.PrivateKey = (*[32]byte)(c.buf.data[32:64])
+		*c.pair.PrivateKey = *k.PrivateKey
+	}
+	if p.keys == nil {
+		p.keys = make(map[string]*cachedKey)
+	}
+	if old, ok := p.keys[dir]; ok {
+		old.buf.free()
+	}
+	p.keys[dir] = c
+}
+
+// copy returns a copy of the cached pair the caller may clear
+func (c *cachedKey) copy() *KeyPair {
+	publicKey := *c.pair.PublicKey
+	k := &KeyPair{PublicKey: &publicKey, agent: c.pair.agent}
+	if c.pair.PrivateKey != nil {
+		privateKey := *c.pair.PrivateKey
+		k.PrivateKey = &privateKey
+	}
+	return k
+}
+
+// cacheMode returns crypto.key_cache: locked, memory or off
+func cacheMode() string {
+	cfg, err := config.Current()
+	if err != nil {
+		return "locked"
+	}
+	return cfg.String("crypto.key_cache")
+}
+
+func statKeyFile(path string) (fileStamp, error) {
+	info, err := storage.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// secKeyPath returns the private key file of dir, wrapped or not
+func secKeyPath(dir string) string {
+	path := filepath.Join(dir, naclSecKeyFile)
+	if _, err := storage.Stat(path); err != nil {
+		return filepath.Join(dir, naclWrappedKeyFile)
+	}
+	return path
+}
+
+// readPublicKey reads only the public key stored in dir
+func readPublicKey(dir string) (*KeyPair, error) {
+	pubKeyData, err := storage.ReadFile(filepath.Join(dir, naclPubKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	pubKeyBytes, err := base64.StdEncoding.DecodeString(string(pubKeyData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	var publicKey [32]byte
+	copy(publicKey[:], pubKeyBytes)
+	return &KeyPair{PublicKey: &publicKey}, nil
+}
+
+// newKeyBuffer returns memory for a key pair, locked against swapping if
+// asked and allowed
+func newKeyBuffer(locked bool) *keyBuffer {
+	if locked {
+		if data, err := lockedAlloc(64); err == nil {
+			return &keyBuffer{data: data, locked: true}
+		}
+	}
+	return &keyBuffer{data: make([]byte, 64)}
+}
+
+// free zeros the buffer and releases it
+func (b *keyBuffer) free() {
+	for i := range b.data {
+		b.data[i] = 0
+	}
+	if b.locked {
+		lockedFree(b.data)
+	}
+	b.data = nil
+}
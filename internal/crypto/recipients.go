@@ -0,0 +1,160 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// recipientsFile lists the extra public keys every new entry is encrypted to
+const recipientsFile = "recipients.json"
+
+// Recipient is an additional public key that can read new entries, such as a
+// partner's key for a shared journal or an offline recovery key
+type Recipient struct {
+	Name       string    `json:"name"`
+	PublicKey  string    `json:"public_key"`            // Base64, as in jot.pub
+	SigningKey string    `json:"signing_key,omitempty"` // Base64 ed25519 key a member of a shared journal signs with
+	Added      time.Time `json:"added"`
+}
+
+// Key decodes the recipient's public key
+func (r Recipient) Key() (*[32]byte, error) {
+	return decodePublicKey(r.PublicKey)
+}
+
+func decodePublicKey(s string) (*[32]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(data) != 32 {
+		return nil, fmt.Errorf("invalid public key: expected 32 Base64 encoded bytes")
+	}
+	var key [32]byte
+	copy(key[:], data)
+	return &key, nil
+}
+
+func recipientsPath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, recipientsFile), nil
+}
+
+// LoadRecipients returns the configured extra recipients
+func LoadRecipients() ([]Recipient, error) {
+	path, err := recipientsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %w", err)
+	}
+
+	var recipients []Recipient
+	if err := json.Unmarshal(data, &recipients); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+// RecipientKeys returns the decoded public keys of all extra recipients
+func RecipientKeys() ([]*[32]byte, error) {
+	recipients, err := LoadRecipients()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*[32]byte, 0, len(recipients))
+	for _, r := range recipients {
+		key, err := r.Key()
+		if err != nil {
+			return nil, fmt.Errorf("recipient '%s': %w", r.Name, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func saveRecipients(recipients []Recipient) error {
+	path, err := recipientsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(recipients, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recipients: %w", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write recipients: %w", err)
+	}
+	return nil
+}
+
+// AddRecipient adds a named public key to the recipients of new entries
+func AddRecipient(name, publicKey string) error {
+	key, err := decodePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	own, err := RestorePublicKey()
+	if err != nil {
+		return err
+	}
+	isOwn := *own.PublicKey == *key
+	own.Clear()
+	if isOwn {
+		return fmt.Errorf("this is your own public key, which is always a recipient")
+	}
+
+	recipients, err := LoadRecipients()
+	if err != nil {
+		return err
+	}
+	for _, r := range recipients {
+		if r.Name == name {
+			return fmt.Errorf("recipient '%s' already exists", name)
+		}
+		if r.PublicKey == publicKey {
+			return fmt.Errorf("key is already added as '%s'", r.Name)
+		}
+	}
+
+	recipients = append(recipients, Recipient{Name: name, PublicKey: publicKey, Added: time.Now()})
+	return saveRecipients(recipients)
+}
+
+// RemoveRecipient stops encrypting new entries to the named recipient
+func RemoveRecipient(name string) error {
+	recipients, err := LoadRecipients()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		if r.Name != name {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(recipients) {
+		return fmt.Errorf("recipient '%s' does not exist", name)
+	}
+	return saveRecipients(kept)
+}
+
+// PublicKeyString returns the Base64 public key of keyPair, as shared with others
+func (k *KeyPair) PublicKeyString() string {
+	return base64.StdEncoding.EncodeToString(k.PublicKey[:])
+}
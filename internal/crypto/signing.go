@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// Members of shared journals sign what they write with an ed25519 key
+// derived from their private key, so that it needs no backup of its own
+// and changes with the key pair on rotation.
+const signingKeyPurpose = "jot member signing key"
+
+// signingKey derives the signing key of the key pair, which needs its
+// private half
+func (k *KeyPair) signingKey() (ed25519.PrivateKey, error) {
+	if k.PrivateKey == nil {
+		return nil, fmt.Errorf("signing needs the private key")
+	}
+	mac := hmac.New(sha256.New, k.PrivateKey[:])
+	mac.Write([]byte(signingKeyPurpose))
+	return ed25519.NewKeyFromSeed(mac.Sum(nil)), nil
+}
+
+// SigningKeyString returns the Base64 public half of the key pair's
+// signing key, as given to the other members of a shared journal
+func (k *KeyPair) SigningKeyString() (string, error) {
+	if k.agent {
+		public, err := askAgent("signing-key", k.PublicKey[:])
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(public), nil
+	}
+	private, err := k.signingKey()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(private.Public().(ed25519.PublicKey)), nil
+}
+
+// CheckSigningKey checks that s is the Base64 public half of a signing key
+func CheckSigningKey(s string) error {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid signing key: expected %d Base64 encoded bytes", ed25519.PublicKeySize)
+	}
+	return nil
+}
+
+// Sign signs message with the key pair's signing key, returning the
+// Base64 signature
+func (k *KeyPair) Sign(message []byte) (string, error) {
+	if k.agent {
+		signature, err := askAgent("sign", k.PublicKey[:], message)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(signature), nil
+	}
+	private, err := k.signingKey()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(private, message)), nil
+}
+
+// VerifySignature reports whether signature, as made by Sign, is valid
+// for message under the Base64 public key
+func VerifySignature(publicKey string, message []byte, signature string) bool {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(key), message, sig)
+}
@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Witnesses countersign entries with ed25519 keys of their own, kept apart
+// from the encryption keys. A key file holds one line: a marker telling the
+// private half from the public one, the Base64 key and the witness's name.
+// The public half is stored next to the private one with a .pub suffix, as
+// ssh-keygen does.
+
+const (
+	witnessPublicMarker  = "jot-witness"
+	witnessPrivateMarker = "jot-witness-private"
+)
+
+// WitnessKey is a witness's signing key. Private is nil when only the
+// public half was read.
+type WitnessKey struct {
+	Name    string
+	Public  ed25519.PublicKey
+	Private ed25519.PrivateKey
+}
+
+// PublicKeyString returns the Base64 public key, as stored with countersignatures
+func (k *WitnessKey) PublicKeyString() string {
+	return base64.StdEncoding.EncodeToString(k.Public)
+}
+
+// GenerateWitnessKey creates a signing key for name, writing the private
+// half to path and the public half to path.pub. Existing files are never
+// overwritten.
+func GenerateWitnessKey(name, path string) (*WitnessKey, error) {
+	if strings.ContainsAny(name, " \t\n") || name == "" {
+		return nil, fmt.Errorf("witness name must be a single word")
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate witness key: %w", err)
+	}
+
+	files := []struct {
+		path, line string
+		perm       os.FileMode
+	}{
+		{path, fmt.Sprintf("%s %s %s\n", witnessPrivateMarker, base64.StdEncoding.EncodeToString(private.Seed()), name), 0600},
+		{path + ".pub", fmt.Sprintf("%s %s %s\n", witnessPublicMarker, base64.StdEncoding.EncodeToString(public), name), 0644},
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f.path); err == nil {
+			return nil, fmt.Errorf("%s already exists", f.path)
+		}
+	}
+	for _, f := range files {
+		out, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.perm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write witness key: %w", err)
+		}
+		_, err = out.WriteString(f.line)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write witness key: %w", err)
+		}
+	}
+	return &WitnessKey{Name: name, Public: public, Private: private}, nil
+}
+
+// ReadWitnessKey reads either half of a witness key from path
+func ReadWitnessKey(path string) (*WitnessKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read witness key: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("%s is not a witness key made by jot countersign keygen", path)
+	}
+	key, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid witness key: %w", path, err)
+	}
+
+	switch {
+	case fields[0] == witnessPrivateMarker && len(key) == ed25519.SeedSize:
+		private := ed25519.NewKeyFromSeed(key)
+		return &WitnessKey{Name: fields[2], Public: private.Public().(ed25519.PublicKey), Private: private}, nil
+	case fields[0] == witnessPublicMarker && len(key) == ed25519.PublicKeySize:
+		return &WitnessKey{Name: fields[2], Public: ed25519.PublicKey(key)}, nil
+	}
+	return nil, fmt.Errorf("%s is not a witness key made by jot countersign keygen", path)
+}
+
+// ReadWitnessSigningKey reads the private half of a witness key. Given the
+// public half's path, it looks for the private half next to it.
+func ReadWitnessSigningKey(path string) (*WitnessKey, error) {
+	k, err := ReadWitnessKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if k.Private != nil {
+		return k, nil
+	}
+	if private := strings.TrimSuffix(path, ".pub"); private != path {
+		if k, err := ReadWitnessKey(private); err == nil && k.Private != nil {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is a public key; countersigning needs the private key made with it", path)
+}
+
+// Sign signs message with the private half of the key
+func (k *WitnessKey) Sign(message []byte) (string, error) {
+	if k.Private == nil {
+		return "", fmt.Errorf("witness key of '%s' has no private half", k.Name)
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.Private, message)), nil
+}
+
+// VerifyWitnessSignature reports whether signature, as made by Sign, is
+// valid for message under the Base64 public key
+func VerifyWitnessSignature(publicKey string, message []byte, signature string) bool {
+	return VerifySignature(publicKey, message, signature)
+}
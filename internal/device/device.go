@@ -0,0 +1,369 @@
+// Package device adds machines to the collection with a key ceremony: the
+// new device generates a key pair of its own, a device already approved
+// shows a code derived from it for the user to compare, then records it.
+// Entries are encrypted to the key of every approved device, so each one
+// can be revoked on its own.
+package device
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+// requestFile, in the key directory, remembers the request this machine
+// made, so asking again does not replace its key once more
+const requestFile = "device-request.json"
+
+// Request is what a new device asks to be approved with
+type Request struct {
+	Name      string    `json:"name"`
+	PublicKey string    `json:"public_key"` // Base64 key of the device's own key pair
+	Requested time.Time `json:"requested"`
+}
+
+// Code returns the code both devices show for a request, so one altered on
+// its way between them is noticed. It is long enough that no key with the
+// same code can be searched for: five groups of four digits.
+func Code(name, publicKey string) string {
+	sum := sha256.Sum256([]byte(name + "\n" + publicKey))
+	groups := make([]string, 5)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%04d", binary.BigEndian.Uint32(sum[i*4:])%10000)
+	}
+	return strings.Join(groups, " ")
+}
+
+// NewRequest makes this machine ask to be approved as name. It generates
+// the device's own key pair, which replaces the current one; that is
+// retired, so what it encrypted stays readable here. Asking again under
+// the same name returns the earlier request rather than another key.
+func NewRequest(name string) (*Request, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	devices, err := collection.Devices()
+	if err != nil {
+		return nil, err
+	}
+	own, err := ownKey()
+	if err != nil {
+		return nil, err
+	}
+	// A revoked machine asks again with a new key
+	self := find(devices, own)
+	if self != nil && self.Revoked == nil {
+		return nil, fmt.Errorf("this machine is already approved as '%s'", self.Name)
+	}
+	for _, d := range devices {
+		if d.Name == name {
+			return nil, fmt.Errorf("a device named '%s' is already recorded", name)
+		}
+	}
+
+	earlier, err := readRequest()
+	if err != nil {
+		return nil, err
+	}
+	if earlier != nil && earlier.Name == name && earlier.PublicKey == own && self == nil {
+		return earlier, nil
+	}
+
+	if crypto.HasPendingKey() {
+		return nil, fmt.Errorf("a key rotation is unfinished; run jot key rotate first")
+	}
+	if err := crypto.GeneratePendingKey(); err != nil {
+		return nil, err
+	}
+	if err := crypto.PromotePendingKey(); err != nil {
+		return nil, err
+	}
+	if own, err = ownKey(); err != nil {
+		return nil, err
+	}
+
+	r := &Request{Name: name, PublicKey: own, Requested: time.Now()}
+	if err := writeRequest(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Approve records the device name with publicKey once the user compared
+// its code, and pins it on this machine. Only an approved device can
+// approve others; when none is recorded yet, this machine is recorded
+// first as the primary device, under self. A device another machine
+// approved is only pinned, as this machine does not encrypt to it before.
+func Approve(name, publicKey, self string) (*types.Device, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	if _, err := (crypto.Recipient{PublicKey: publicKey}).Key(); err != nil {
+		return nil, err
+	}
+	own, err := ownKey()
+	if err != nil {
+		return nil, err
+	}
+	if publicKey == own {
+		return nil, fmt.Errorf("this is the key of this machine, not of a new device")
+	}
+
+	now := time.Now()
+	added := &types.Device{Name: name, PublicKey: publicKey, Added: now}
+	var primary *types.Device
+	_, err = collection.Update(func(coll *collection.Collection) error {
+		if len(coll.Devices) == 0 {
+			if err := checkName(self); err != nil {
+				return err
+			}
+			primary = &types.Device{Name: self, PublicKey: own, Added: now, Primary: true}
+			coll.Devices = append(coll.Devices, primary)
+		} else if !approved(coll.Devices, own) {
+			return fmt.Errorf("this machine is not an approved device, so it cannot approve others")
+		}
+		for _, d := range coll.Devices {
+			if d.Name == name && d.PublicKey == publicKey && d.Revoked == nil {
+				added = d
+				return nil
+			}
+			if d.Name == name {
+				return fmt.Errorf("a device named '%s' is already recorded", name)
+			}
+			if d.PublicKey == publicKey {
+				return fmt.Errorf("key is already recorded as '%s'", d.Name)
+			}
+		}
+		coll.Devices = append(coll.Devices, added)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save device: %w", err)
+	}
+
+	pins := []*types.Device{added}
+	if primary != nil {
+		pins = append(pins, primary)
+	}
+	if err := pin(pins...); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// Revoke stops encrypting entries to the device name and returns its
+// record, with the time it was revoked. Revoking a device again returns it
+// as it is, so that what is still sealed to its key can be re-encrypted
+// once more, such as entries synced in from a device that did not know of
+// the revocation yet.
+func Revoke(name string) (*types.Device, error) {
+	own, err := ownKey()
+	if err != nil {
+		return nil, err
+	}
+	var revoked *types.Device
+	_, err = collection.Update(func(coll *collection.Collection) error {
+		for _, d := range coll.Devices {
+			if d.Name == name {
+				revoked = d
+			}
+		}
+		switch {
+		case revoked == nil:
+			return fmt.Errorf("device '%s' does not exist", name)
+		case revoked.PublicKey == own:
+			return fmt.Errorf("'%s' is this machine; revoke it from another device", name)
+		case !approved(coll.Devices, own):
+			return fmt.Errorf("this machine is not an approved device, so it cannot revoke others")
+		}
+		if revoked.Revoked == nil {
+			now := time.Now()
+			revoked.Revoked = &now
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke device: %w", err)
+	}
+	if err := unpinKey(revoked.PublicKey, *revoked.Revoked); err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// Self returns the record of this machine among the devices, or nil when
+// it is not one. The record may be revoked.
+func Self() (*types.Device, error) {
+	devices, err := collection.Devices()
+	if err != nil {
+		return nil, err
+	}
+	own, err := ownKey()
+	if err != nil {
+		return nil, err
+	}
+	return find(devices, own), nil
+}
+
+// PendingRequest returns the request this machine made and that has not
+// been approved yet, or nil
+func PendingRequest() (*Request, error) {
+	r, err := readRequest()
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if self, err := Self(); err != nil || self != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OtherKeys returns the public keys of the devices other than this machine
+// that it pinned and that are not revoked, which entries are encrypted to
+// besides its own key
+func OtherKeys() ([]*[32]byte, error) {
+	devices, err := Pinned()
+	if err != nil || len(devices) == 0 {
+		return nil, err
+	}
+	own, err := ownKey()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*[32]byte, 0, len(devices))
+	for _, d := range devices {
+		if d.PublicKey == own || d.Revoked != nil {
+			continue
+		}
+		key, err := (crypto.Recipient{PublicKey: d.PublicKey}).Key()
+		if err != nil {
+			return nil, fmt.Errorf("device '%s': %w", d.Name, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Rekey moves the record of this machine from its key old to the current
+// one after a key rotation, so the other devices encrypt to the new key
+func Rekey(old string) error {
+	devices, err := collection.Devices()
+	if err != nil || find(devices, old) == nil {
+		return err
+	}
+	own, err := ownKey()
+	if err != nil || own == old {
+		return err
+	}
+	var moved types.Device
+	_, err = collection.Update(func(coll *collection.Collection) error {
+		if d := find(coll.Devices, old); d != nil {
+			d.PublicKey = own
+			moved = *d
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save device: %w", err)
+	}
+
+	pins, err := Pinned()
+	if err != nil {
+		return err
+	}
+	if p := find(pins, old); p != nil {
+		p.PublicKey = own
+		return writePins(pins)
+	}
+	return pin(&moved)
+}
+
+// find returns the device with publicKey, or nil
+func find(devices []*types.Device, publicKey string) *types.Device {
+	for _, d := range devices {
+		if d.PublicKey == publicKey {
+			return d
+		}
+	}
+	return nil
+}
+
+// approved reports whether the device with publicKey is approved and not
+// revoked
+func approved(devices []*types.Device, publicKey string) bool {
+	d := find(devices, publicKey)
+	return d != nil && d.Revoked == nil
+}
+
+func checkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("a device needs a name")
+	}
+	if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("device name '%s' contains a space", name)
+	}
+	return nil
+}
+
+// ownKey returns the Base64 public key of this machine
+func ownKey() (string, error) {
+	k, err := crypto.RestorePublicKey()
+	if err != nil {
+		return "", err
+	}
+	defer k.Clear()
+	return k.PublicKeyString(), nil
+}
+
+func requestPath() (string, error) {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, requestFile), nil
+}
+
+func readRequest() (*Request, error) {
+	path, err := requestPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device request: %w", err)
+	}
+	var r Request
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal device request: %w", err)
+	}
+	return &r, nil
+}
+
+func writeRequest(r *Request) error {
+	path, err := requestPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal device request: %w", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write device request: %w", err)
+	}
+	return nil
+}
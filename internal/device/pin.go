@@ -0,0 +1,160 @@
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+// pinFile, in the key directory, holds the devices this machine approved or
+// accepted. The devices of collection.json come through the sync folder,
+// where anyone able to write could add a key of their own, so only those
+// pinned here are encrypted to.
+const pinFile = "devices.json"
+
+// Pinned returns the devices this machine encrypts to, revoked ones
+// included. The first time this machine finds itself among the approved
+// devices, such as right after being approved or after upgrading, it pins
+// those recorded then. Revocations recorded in the collection are always
+// followed, and kept here so that an older record cannot undo them.
+func Pinned() ([]*types.Device, error) {
+	devices, err := collection.Devices()
+	if err != nil {
+		return nil, err
+	}
+	pins, err := readPins()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	if pins == nil {
+		own, err := ownKey()
+		if err != nil {
+			return nil, err
+		}
+		if !approved(devices, own) {
+			return nil, nil
+		}
+		pins, changed = devices, true
+	}
+	for _, d := range devices {
+		if p := find(pins, d.PublicKey); p != nil && p.Revoked == nil && d.Revoked != nil {
+			p.Revoked = d.Revoked
+			changed = true
+		}
+	}
+	if changed {
+		if err := writePins(pins); err != nil {
+			return nil, err
+		}
+	}
+	return pins, nil
+}
+
+// Unpinned returns the devices of the collection that are not revoked and
+// that this machine has not approved, which it does not encrypt to
+func Unpinned() ([]*types.Device, error) {
+	devices, err := collection.Devices()
+	if err != nil {
+		return nil, err
+	}
+	pins, err := Pinned()
+	if err != nil {
+		return nil, err
+	}
+	var unpinned []*types.Device
+	for _, d := range devices {
+		if d.Revoked == nil && find(pins, d.PublicKey) == nil {
+			unpinned = append(unpinned, d)
+		}
+	}
+	return unpinned, nil
+}
+
+// pin records devices among the pinned ones, replacing the record of the
+// same key
+func pin(devices ...*types.Device) error {
+	pins, err := Pinned()
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		record := *d
+		if p := find(pins, d.PublicKey); p != nil {
+			*p = record
+		} else {
+			pins = append(pins, &record)
+		}
+	}
+	return writePins(pins)
+}
+
+// unpinKey marks the pinned device with publicKey revoked
+func unpinKey(publicKey string, revoked time.Time) error {
+	pins, err := Pinned()
+	if err != nil {
+		return err
+	}
+	if p := find(pins, publicKey); p != nil && p.Revoked == nil {
+		p.Revoked = &revoked
+		return writePins(pins)
+	}
+	return nil
+}
+
+func pinPath() (string, error) {
+	dir, err := crypto.KeyPairDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, pinFile), nil
+}
+
+// readPins returns the pinned devices, nil when none were pinned yet
+func readPins() ([]*types.Device, error) {
+	path, err := pinPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned devices: %w", err)
+	}
+	pins := []*types.Device{}
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pinned devices: %w", err)
+	}
+	return pins, nil
+}
+
+func writePins(pins []*types.Device) error {
+	path, err := pinPath()
+	if err != nil {
+		return err
+	}
+	if pins == nil {
+		pins = []*types.Device{}
+	}
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pinned devices: %w", err)
+	}
+	if err := storage.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write pinned devices: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Package digest summarises recent journaling activity and posts the summary
+// to chat webhooks. Only counts leave the machine, never entry text.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/types"
+)
+
+// DefaultMessage is the message template used when digest.template is unset
+const DefaultMessage = `Journaling {{.From.Format "Jan 2"}} – {{.To.Format "Jan 2"}}: ` +
+	`{{.Entries}} entries, {{.Words}} words on {{.ActiveDays}} of {{.Days}} days. ` +
+	`Current streak: {{.Streak}} days.{{if .TasksDone}} Tasks done: {{.TasksDone}}.{{end}}`
+
+// DefaultPayload is the request body template for generic webhooks
+const DefaultPayload = `{"text": {{json .Text}}}`
+
+// httpClient is used for all webhook requests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Stats are the numbers available to message and payload templates
+type Stats struct {
+	From       time.Time
+	To         time.Time
+	Days       int
+	Entries    int
+	Words      int
+	ActiveDays int
+	Streak     int // Consecutive days with an entry, ending today or yesterday
+	TasksDone  int // Tasks completed in the period, whenever they were written
+	Journals   []JournalStats
+
+	Text string // The rendered message, for payload templates
+}
+
+// JournalStats are per-journal counts for the period
+type JournalStats struct {
+	Name    string
+	Entries int
+	Words   int
+}
+
+// Compute gathers statistics for the days ending at now
+func Compute(journals map[string]*types.Journal, now time.Time, days int) (*Stats, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -(days - 1))
+	s := &Stats{From: from, To: now, Days: days}
+
+	names := make([]string, 0, len(journals))
+	for name := range journals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	active := make(map[string]bool) // Days with entries, over all time
+	inPeriod := make(map[string]bool)
+	for _, name := range names {
+		entries, err := journal.FromType(journals[name]).GetEntries()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load entries for journal '%s': %w", name, err)
+		}
+
+		js := JournalStats{Name: name}
+		for _, e := range entries {
+			if e.Done != nil && !e.Done.Before(from) && !e.Done.After(now) {
+				s.TasksDone++
+			}
+			created := e.Created.In(now.Location())
+			day := created.Format("2006-01-02")
+			active[day] = true
+			if created.Before(from) || created.After(now) {
+				continue
+			}
+
+			preview, err := e.GetPreview()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+			}
+			words := preview.Words
+			js.Entries++
+			js.Words += words
+			inPeriod[day] = true
+		}
+
+		s.Entries += js.Entries
+		s.Words += js.Words
+		if js.Entries > 0 {
+			s.Journals = append(s.Journals, js)
+		}
+	}
+	s.ActiveDays = len(inPeriod)
+
+	// A streak still counts if today's entry has not been written yet
+	day := today
+	if !active[day.Format("2006-01-02")] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for active[day.Format("2006-01-02")] {
+		s.Streak++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return s, nil
+}
+
+var funcs = template.FuncMap{
+	// json encodes a value for embedding in a JSON payload
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Render executes the text/template tmpl against s
+func Render(tmpl string, s *Stats) (string, error) {
+	t, err := template.New("digest").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, s); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Target is a kind of webhook receiver
+type Target string
+
+// Supported webhook targets
+const (
+	Slack   Target = "slack"
+	Discord Target = "discord"
+	Webhook Target = "webhook"
+)
+
+// Payload builds the request body for target. Generic webhooks use the
+// payloadTemplate; chat targets wrap the message in their expected field.
+func Payload(target Target, s *Stats, payloadTemplate string) ([]byte, error) {
+	switch target {
+	case Slack:
+		return json.Marshal(map[string]string{"text": s.Text})
+	case Discord:
+		return json.Marshal(map[string]string{"content": s.Text})
+	case Webhook:
+		body, err := Render(payloadTemplate, s)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(body), nil
+	}
+	return nil, fmt.Errorf("unknown target '%s'", target)
+}
+
+// Post sends payload as JSON to the webhook URL
+func Post(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post digest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,551 @@
+package doctor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/atrest"
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/trash"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Ways --fix gives a home to the entries of a journal that no longer exists
+const (
+	AdoptOrphans    = "adopt"    // Move them into RecoveredJournal
+	RecreateOrphans = "recreate" // Recreate their journal under a new name
+)
+
+// RecoveredJournal is the journal adopted entries are moved into, created
+// when needed
+const RecoveredJournal = "recovered"
+
+// staleReservation is how old an empty file reserving an entry ID must be
+// before it is considered left behind by a crashed jot process
+const staleReservation = time.Hour
+
+// Problem is one inconsistency found in the data directory
+type Problem struct {
+	Subject string // What is affected, e.g. "entry 0042"
+	Message string
+	Fixable bool // Whether --fix can repair it
+	Fixed   bool
+}
+
+// Report is the outcome of a check
+type Report struct {
+	Journals int
+	Entries  int
+	Files    int // Files and directories looked at by CheckIsolation
+	Problems []*Problem
+}
+
+// Unresolved returns the number of problems that were not fixed
+func (r *Report) Unresolved() int {
+	n := 0
+	for _, p := range r.Problems {
+		if !p.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+// checker accumulates problems while walking the data directory
+type checker struct {
+	fix     bool
+	orphans string // AdoptOrphans or RecreateOrphans
+	dir     string
+	report  *Report
+}
+
+// problem records a problem; repair, if given, is run in fix mode
+func (c *checker) problem(subject, message string, repair func() error) {
+	p := &Problem{Subject: subject, Message: message, Fixable: repair != nil}
+	if c.fix && repair != nil {
+		if err := repair(); err != nil {
+			p.Message += fmt.Sprintf(" (fix failed: %v)", err)
+		} else {
+			p.Fixed = true
+		}
+	}
+	c.report.Problems = append(c.report.Problems, p)
+}
+
+// Check verifies the data directory: keys and their permissions, encryption
+// at rest, journal references, entry files and whether every entry can be
+// decrypted. With fix set, problems that can be repaired without losing data
+// are repaired.
+// orphans chooses how entries of journals that no longer exist are
+// repaired: AdoptOrphans or RecreateOrphans.
+func Check(fix bool, orphans string) (*Report, error) {
+	if orphans != AdoptOrphans && orphans != RecreateOrphans {
+		return nil, fmt.Errorf("unknown way to fix orphaned entries '%s': use %s or %s", orphans, AdoptOrphans, RecreateOrphans)
+	}
+	dir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	c := &checker{fix: fix, orphans: orphans, dir: dir, report: &Report{}}
+
+	if !crypto.HasKey() {
+		c.problem("keys", "no key pair found in backup/; entries cannot be decrypted", nil)
+	}
+	if err := c.checkPermissions(); err != nil {
+		return nil, err
+	}
+	plain, err := c.checkSealed()
+	if err != nil {
+		return nil, err
+	}
+	if plain && !fix {
+		// The collection cannot be read before they are sealed
+		return c.report, nil
+	}
+
+	coll, err := collection.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection: %w", err)
+	}
+	c.report.Journals = len(coll.Journals)
+
+	referenced := c.checkReferences(coll)
+	if err := c.checkEntryFiles(coll, referenced); err != nil {
+		return nil, err
+	}
+
+	return c.report, nil
+}
+
+// checkPermissions flags files and directories other users can access.
+// Public keys are meant to be shared and are skipped.
+func (c *checker) checkPermissions() error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		c.checkMode(path, info)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check permissions: %w", err)
+	}
+	return nil
+}
+
+// checkMode flags path when its permissions let other users access it.
+// Links, named pipes and public keys are left alone.
+func (c *checker) checkMode(path string, info fs.FileInfo) {
+	if runtime.GOOS == "windows" || strings.HasSuffix(info.Name(), ".pub") ||
+		info.Mode()&(fs.ModeNamedPipe|fs.ModeSymlink) != 0 || info.Mode().Perm()&0077 == 0 {
+		return
+	}
+
+	want := os.FileMode(0600)
+	if info.IsDir() {
+		want = 0700
+	}
+	c.problem(c.subject(path), fmt.Sprintf("permissions %04o allow access by other users, want %04o", info.Mode().Perm(), want),
+		func() error { return os.Chmod(path, want) })
+}
+
+// checkSealed flags the files of the data directory stored unencrypted,
+// as written by versions without encryption at rest, reporting whether
+// there are any; fixing seals them, so that jot trusts them from then on
+func (c *checker) checkSealed() (bool, error) {
+	files, err := atrest.Files()
+	if err != nil {
+		return false, err
+	}
+	var plain []string
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", c.subject(path), err)
+		}
+		if !atrest.IsSealed(data) {
+			plain = append(plain, path)
+		}
+	}
+	const unlessPlaced = "; fix only if an earlier jot wrote it, not someone with access to the sync folder"
+	if len(plain) == 1 {
+		c.problem(c.subject(plain[0]), "is not encrypted at rest"+unlessPlaced, func() error { return atrest.Migrate(plain) })
+	} else if len(plain) > 1 {
+		c.problem("data directory", fmt.Sprintf("%d files are not encrypted at rest, such as %s%s", len(plain), c.subject(plain[0]), unlessPlaced),
+			func() error { return atrest.Migrate(plain) })
+	}
+	return len(plain) > 0, nil
+}
+
+// subject names path in problems: relative to the data directory when it
+// is inside it
+func (c *checker) subject(path string) string {
+	if rel, err := filepath.Rel(c.dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// checkReferences verifies the entry IDs listed by every journal and returns
+// the journal each referenced ID belongs to
+func (c *checker) checkReferences(coll *collection.Collection) map[string]string {
+	names := make([]string, 0, len(coll.Journals))
+	for name := range coll.Journals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	referenced := make(map[string]string) // Entry ID -> journal listing it
+	ids := make(map[string]string)        // Journal ID -> journal name
+	archived, err := entry.ArchivedIDs()
+	if err != nil {
+		c.problem("archive", fmt.Sprintf("cannot be listed: %v", err), nil)
+	}
+	for _, name := range names {
+		j := coll.Journals[name]
+		subject := fmt.Sprintf("journal '%s'", name)
+		seen := make(map[string]bool)
+
+		if other, taken := ids[j.ID]; taken {
+			c.problem(subject, fmt.Sprintf("has the same ID as journal '%s'", other), nil)
+		}
+		ids[j.ID] = name
+
+		for _, id := range j.EntryIDs {
+			id := id
+			if seen[id] {
+				c.problem(subject, fmt.Sprintf("lists entry %s more than once", id), unlessAppendOnly(j.AppendOnly, func() error {
+					return dedupe(name, id)
+				}))
+				continue
+			}
+			seen[id] = true
+
+			if _, cold := archived[id]; cold {
+				// Kept in the archive of its year until read again
+				continue
+			}
+			info, err := os.Stat(filepath.Join(c.dir, "entries", id+".json"))
+			if err != nil || info.Size() == 0 {
+				c.problem(subject, fmt.Sprintf("lists entry %s, whose file is missing", id),
+					unlessAppendOnly(j.AppendOnly, func() error { return removeIDs(name, []string{id}) }))
+				continue
+			}
+
+			other, listed := referenced[id]
+			if !listed {
+				referenced[id] = name
+				continue
+			}
+
+			// Keep the entry in the journal it says it belongs to
+			keep, drop := other, name
+			if e, err := entry.Load(id); err == nil && e.JournalID == j.ID {
+				keep, drop = name, other
+			}
+			referenced[id] = keep
+			c.problem(subject, fmt.Sprintf("lists entry %s, which journal '%s' lists as well", id, other),
+				unlessAppendOnly(coll.Journals[drop].AppendOnly, func() error { return removeIDs(drop, []string{id}) }))
+		}
+
+		if j.AppendOnly {
+			if err := journal.FromType(j).Verify(); err != nil {
+				c.problem(fmt.Sprintf("journal '%s'", name), err.Error(), nil)
+			}
+		}
+	}
+	return referenced
+}
+
+// checkEntryFiles looks at every file in entries/: stale reservations,
+// unreadable or undecryptable entries and entries no journal lists
+func (c *checker) checkEntryFiles(coll *collection.Collection, referenced map[string]string) error {
+	entriesDir := filepath.Join(c.dir, "entries")
+	files, err := os.ReadDir(entriesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read entries directory: %w", err)
+	}
+
+	trashed, err := trash.EntryFiles()
+	if err != nil {
+		return err
+	}
+	inTrash := make(map[string]bool, len(trashed))
+	for _, name := range trashed {
+		inTrash[strings.TrimSuffix(name, ".json")] = true
+	}
+
+	orphans := make(map[string][]*entry.Entry) // Journal ID -> its entries
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(file.Name(), ".json")
+		path := filepath.Join(entriesDir, file.Name())
+		subject := "entry " + id
+
+		info, err := file.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.Size() == 0 {
+			if time.Since(info.ModTime()) > staleReservation {
+				c.problem(subject, "empty file left by an interrupted write", func() error { return os.Remove(path) })
+			}
+			continue
+		}
+
+		e, err := entry.Load(id)
+		if err != nil {
+			c.problem(subject, err.Error(), nil)
+			continue
+		}
+		c.report.Entries++
+
+		if e.ID != id {
+			c.problem(subject, fmt.Sprintf("file contains entry %s", e.ID), nil)
+		}
+		if _, err := e.GetDecryptedBody(); err != nil {
+			c.problem(subject, fmt.Sprintf("cannot be decrypted: %v", err), nil)
+		} else if _, err := e.GetTags(); err != nil {
+			c.problem(subject, fmt.Sprintf("tags cannot be decrypted: %v", err), nil)
+		}
+
+		listedIn, listed := referenced[id]
+		owner := coll.JournalName(e.JournalID)
+		switch {
+		case listed && e.JournalID != coll.Journals[listedIn].ID:
+			listedID := coll.Journals[listedIn].ID
+			c.problem(subject, fmt.Sprintf("belongs to journal '%s' but is listed in '%s'", owner, listedIn),
+				unlessSealed(e, func() error {
+					e.JournalID = listedID
+					return e.Save()
+				}))
+		case !listed && !inTrash[id]:
+			j, exists := coll.JournalByID(e.JournalID)
+			if !exists {
+				orphans[e.JournalID] = append(orphans[e.JournalID], e)
+				continue
+			}
+			if e.Sealed() {
+				c.problem(subject, fmt.Sprintf("is not listed in any journal (belongs to '%s')", owner), nil)
+				continue
+			}
+			c.problem(subject, fmt.Sprintf("is not listed in journal '%s'", owner), unlessAppendOnly(j.AppendOnly, func() error {
+				return journal.FromType(j).AddEntry(id)
+			}))
+		}
+	}
+	c.checkOrphans(coll, orphans)
+	return nil
+}
+
+// checkOrphans offers a home to the entries of journals that no longer
+// exist, which nothing shows otherwise. Entries of append-only journals
+// cannot move, so their journal is always recreated.
+func (c *checker) checkOrphans(coll *collection.Collection, orphans map[string][]*entry.Entry) {
+	journalIDs := make([]string, 0, len(orphans))
+	for id := range orphans {
+		journalIDs = append(journalIDs, id)
+	}
+	sort.Strings(journalIDs)
+
+	taken := make(map[string]bool)
+	for _, journalID := range journalIDs {
+		entries := orphans[journalID]
+		sort.SliceStable(entries, func(a, b int) bool { return entries[a].Created.Before(entries[b].Created) })
+		sealed := false
+		for _, e := range entries {
+			sealed = sealed || e.Sealed()
+		}
+
+		subject := fmt.Sprintf("journal %s", journalID)
+		found := fmt.Sprintf("no longer exists but has %s left", countEntries(len(entries)))
+		if c.orphans == AdoptOrphans && !sealed {
+			c.problem(subject, fmt.Sprintf("%s; fixing adopts them into '%s'", found, RecoveredJournal), func() error {
+				return adopt(entries)
+			})
+			continue
+		}
+
+		name := recreatedName(coll, journalID, taken)
+		taken[name] = true
+		if c.orphans == AdoptOrphans {
+			found += ", which are append-only and cannot move"
+		}
+		c.problem(subject, fmt.Sprintf("%s; fixing recreates it as '%s'", found, name), func() error {
+			return recreate(journalID, name, entries, sealed)
+		})
+	}
+}
+
+// countEntries returns "1 entry" or "n entries"
+func countEntries(n int) string {
+	if n == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", n)
+}
+
+// recreatedName returns a free name for a recreated journal. Journals from
+// before IDs were generated used their name as ID, so that name is used
+// again when it is free.
+func recreatedName(coll *collection.Collection, journalID string, taken map[string]bool) string {
+	free := func(name string) bool {
+		_, exists := coll.Journals[name]
+		return !exists && !taken[name]
+	}
+	if !strings.HasPrefix(journalID, "j-") && free(journalID) {
+		return journalID
+	}
+	short := strings.TrimPrefix(journalID, "j-")
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	name := "recovered-" + short
+	for n := 2; !free(name); n++ {
+		name = fmt.Sprintf("recovered-%s-%d", short, n)
+	}
+	return name
+}
+
+// adopt moves entries into RecoveredJournal, creating it if needed
+func adopt(entries []*entry.Entry) error {
+	coll, err := collection.Load()
+	if err != nil {
+		return err
+	}
+	j, exists := coll.Journals[RecoveredJournal]
+	if !exists {
+		created, err := journal.New(RecoveredJournal)
+		if err != nil {
+			return err
+		}
+		if err := coll.AddJournal(created.AsType()); err != nil {
+			return err
+		}
+		j = created.AsType()
+	}
+	recovered := journal.FromType(j)
+	if recovered.AppendOnly || recovered.IsShared() {
+		return fmt.Errorf("journal '%s' is append-only or shared; rename it, or fix with %s", RecoveredJournal, RecreateOrphans)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		e.JournalID = recovered.ID
+		if err := e.Save(); err != nil {
+			return err
+		}
+		ids = append(ids, e.ID)
+	}
+	return recovered.AddEntries(ids)
+}
+
+// recreate adds a journal with the ID the entries belong to, listing them
+// oldest first
+func recreate(journalID, name string, entries []*entry.Entry, appendOnly bool) error {
+	j := &types.Journal{
+		ID:         journalID,
+		Name:       name,
+		Created:    entries[0].Created,
+		EntryIDs:   make([]string, 0, len(entries)),
+		AppendOnly: appendOnly,
+	}
+	for _, e := range entries {
+		j.EntryIDs = append(j.EntryIDs, e.ID)
+	}
+	if appendOnly {
+		j.ChainHead = entries[len(entries)-1].Hash
+	}
+	coll, err := collection.Load()
+	if err != nil {
+		return err
+	}
+	return coll.AddJournal(j)
+}
+
+// unlessAppendOnly returns repair, or nil for append-only journals whose
+// entry lists must not be rewritten
+func unlessAppendOnly(appendOnly bool, repair func() error) func() error {
+	if appendOnly {
+		return nil
+	}
+	return repair
+}
+
+// unlessSealed returns repair, or nil for sealed entries
+func unlessSealed(e *entry.Entry, repair func() error) func() error {
+	if e.Sealed() {
+		return nil
+	}
+	return repair
+}
+
+// dedupe keeps only the first occurrence of id in the journal
+func dedupe(name, id string) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", name)
+		}
+		ids := make([]string, 0, len(j.EntryIDs))
+		seen := false
+		for _, existing := range j.EntryIDs {
+			if existing == id {
+				if seen {
+					continue
+				}
+				seen = true
+			}
+			ids = append(ids, existing)
+		}
+		j.EntryIDs = ids
+		return nil
+	})
+	return err
+}
+
+// removeIDs removes every occurrence of ids from the journal
+func removeIDs(name string, ids []string) error {
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", name)
+		}
+		kept := make([]string, 0, len(j.EntryIDs))
+		for _, id := range j.EntryIDs {
+			if !remove[id] {
+				kept = append(kept, id)
+			}
+		}
+		j.EntryIDs = kept
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update journal '%s': %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/fsutil"
+	"github.com/veritome/jot/internal/server"
+)
+
+// draftPattern matches the files the external editor is handed
+const draftPattern = "jot-*.md"
+
+// CheckIsolation looks for ways other users of the machine could read or
+// tamper with jot's files: files they own or can access in the data and key
+// directories, a lock file or agent socket replaced by a link, and
+// temporary files outside the user's own temporary directory. When fix is
+// set, permissions are tightened and stray temporary files removed.
+func CheckIsolation(fix bool) (*Report, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	c := &checker{fix: fix, dir: dir, report: &Report{}}
+
+	if err := c.checkTree(dir); err != nil {
+		return nil, err
+	}
+	cfg, err := config.Current()
+	if err != nil {
+		return nil, err
+	}
+	if keyDir := cfg.String("crypto.key_dir"); keyDir != "" {
+		if keyDir, err = config.ExpandHome(keyDir); err != nil {
+			return nil, err
+		}
+		if err := c.checkTree(keyDir); err != nil {
+			return nil, err
+		}
+	}
+	tokenPath, err := server.TokenPath()
+	if err != nil {
+		return nil, err
+	}
+	if c.subject(tokenPath) == tokenPath {
+		if err := c.checkTree(tokenPath); err != nil {
+			return nil, err
+		}
+	}
+
+	agentPath, err := crypto.AgentPath()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range []string{filepath.Join(dir, fsutil.LockFile), agentPath} {
+		if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			path := path
+			c.problem(c.subject(path), "is a link, which could lead jot to another user's file", func() error { return os.Remove(path) })
+		}
+	}
+
+	if err := c.checkTemp(); err != nil {
+		return nil, err
+	}
+	return c.report, nil
+}
+
+// checkTree flags the files under root owned by another user or accessible
+// to them. A missing root is not a problem; a link to it is followed.
+func (c *checker) checkTree(root string) error {
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		c.report.Files++
+		if uid, ok := fsutil.OtherOwner(info); ok {
+			c.problem(c.subject(path), fmt.Sprintf("is owned by another user (uid %d); change its owner back to you", uid), nil)
+		}
+		c.checkMode(path, info)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", root, err)
+	}
+	return nil
+}
+
+// checkTemp checks the user's own temporary directory, and looks for drafts
+// left by an editor session that was interrupted, in it or, from versions
+// of jot before it existed, in the shared temporary directory
+func (c *checker) checkTemp() error {
+	shared := os.TempDir()
+	own := filepath.Join(shared, fsutil.TempDirName())
+	info, err := os.Lstat(own)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to check temporary directory: %w", err)
+	case info.Mode()&fs.ModeSymlink != 0 || !info.IsDir():
+		c.problem(own, "is not a directory; jot cannot open the editor until it is removed", nil)
+	default:
+		if uid, ok := fsutil.OtherOwner(info); ok {
+			c.problem(own, fmt.Sprintf("is owned by another user (uid %d); jot cannot open the editor until it is removed", uid), nil)
+		} else {
+			c.checkMode(own, info)
+		}
+		c.report.Files++
+	}
+
+	for _, dir := range []string{own, shared} {
+		drafts, err := filepath.Glob(filepath.Join(dir, draftPattern))
+		if err != nil {
+			return err
+		}
+		for _, path := range drafts {
+			info, err := os.Lstat(path)
+			if err != nil || !info.Mode().IsRegular() || time.Since(info.ModTime()) < staleReservation {
+				continue
+			}
+			if _, ok := fsutil.OtherOwner(info); ok {
+				continue
+			}
+			c.report.Files++
+			path := path
+			c.problem(path, "draft left behind by an interrupted editor session", func() error { return os.Remove(path) })
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package enrich records where and in what weather new entries are written.
+// Nothing is looked up unless enrich.location or enrich.weather is turned
+// on, and both services can be replaced with ones of your own.
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Location providers selectable with enrich.location
+const (
+	Off   = "off"
+	Fixed = "fixed" // enrich.place, set by hand
+	IP    = "ip"    // Looked up from the public IP address at enrich.location_url
+)
+
+// maxAnswerBytes limits how much of a service's answer is read
+const maxAnswerBytes = 64 << 10
+
+// httpClient is used for all lookups; entries wait for them, so it gives
+// up quickly
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// settings are the enrich.* settings
+type settings struct {
+	location    string
+	place       string
+	locationURL string
+	weather     bool
+	weatherURL  string
+}
+
+func current() settings {
+	cfg, err := config.Current()
+	if err != nil {
+		return settings{location: Off}
+	}
+	return settings{
+		location:    cfg.String("enrich.location"),
+		place:       strings.TrimSpace(cfg.String("enrich.place")),
+		locationURL: cfg.String("enrich.location_url"),
+		weather:     cfg.Bool("enrich.weather"),
+		weatherURL:  cfg.String("enrich.weather_url"),
+	}
+}
+
+// Enabled reports whether new entries get a location or weather recorded
+func Enabled() bool {
+	s := current()
+	return (s.location != Off && s.location != "") || s.weather
+}
+
+// Fill sets the location and weather of f that are still empty, as
+// configured. A location given by hand is used for the weather. What could
+// not be looked up is left empty and reported in the error.
+func Fill(ctx context.Context, f *types.Fields) error {
+	s := current()
+	var errs []string
+
+	if f.Location == "" {
+		switch s.location {
+		case Fixed:
+			if s.place == "" {
+				errs = append(errs, "enrich.location is fixed but enrich.place is empty")
+			}
+			f.Location = s.place
+		case IP:
+			place, err := lookupLocation(ctx, s.locationURL)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			f.Location = place
+		}
+	}
+
+	if f.Weather == "" && s.weather {
+		weather, err := lookupWeather(ctx, s.weatherURL, f.Location)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		f.Weather = weather
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// get fetches rawURL, returning at most maxAnswerBytes of the answer
+func get(ctx context.Context, rawURL, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// wttr.in answers curl-like clients with text rather than a web page
+	req.Header.Set("User-Agent", "curl/8 (jot)")
+	req.Header.Set("Accept", accept)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxAnswerBytes))
+}
+
+// lookupLocation asks a geolocation service such as ipinfo.io or ipapi.co
+// for the city and country of the public IP address
+func lookupLocation(ctx context.Context, rawURL string) (string, error) {
+	data, err := get(ctx, rawURL, "application/json")
+	if err != nil {
+		return "", fmt.Errorf("failed to look up location: %w", err)
+	}
+	var answer struct {
+		City        string `json:"city"`
+		Country     string `json:"country"`
+		CountryName string `json:"country_name"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return "", fmt.Errorf("failed to look up location: unexpected answer from %s", rawURL)
+	}
+	country := answer.CountryName
+	if country == "" {
+		country = answer.Country
+	}
+	var parts []string
+	for _, p := range []string{answer.City, country} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("failed to look up location: %s did not know it", rawURL)
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// lookupWeather fetches a one-line description of the current weather at
+// place from a service such as wttr.in. {place} in urlTemplate is replaced
+// with the place, or left empty for the service to work it out.
+func lookupWeather(ctx context.Context, urlTemplate, place string) (string, error) {
+	rawURL := strings.ReplaceAll(urlTemplate, "{place}", url.PathEscape(place))
+	data, err := get(ctx, rawURL, "text/plain")
+	if err != nil {
+		return "", fmt.Errorf("failed to look up weather: %w", err)
+	}
+	// A page or a multi-line report means the URL does not ask for one line
+	text := strings.TrimSpace(string(data))
+	if text == "" || strings.Contains(text, "\n") || strings.HasPrefix(text, "<") {
+		return "", fmt.Errorf("failed to look up weather: unexpected answer from %s", rawURL)
+	}
+	return strings.Join(strings.Fields(text), " "), nil
+}
@@ -0,0 +1,411 @@
+package entry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// The entries of a past year can be moved into a cold archive: the entry
+// files and the blobs of their bodies, compressed and encrypted like an
+// entry body, in archive/<year>.jota. Next to it, archive/<year>.json lists
+// the IDs of the entries it holds, so telling whether an entry is archived
+// never decrypts anything; it is written last and removed first, so an
+// archive without it is unfinished or being thawed. Archived entries are
+// left out of journal listings and the index, and loading one by its ID
+// thaws its whole year.
+const (
+	archiveDir    = "archive"
+	archiveSuffix = ".jota"
+	headerSuffix  = ".json"
+)
+
+// Archive describes an archived year
+type Archive struct {
+	Year     int       `json:"year"`
+	Entries  []string  `json:"entries"`
+	Archived time.Time `json:"archived"`
+	Size     int64     `json:"size"` // Bytes of the archive file
+}
+
+// archiveContent is what an archive file holds once decrypted and
+// decompressed
+type archiveContent struct {
+	Entries map[string][]byte `json:"entries"` // Entry files by ID
+	Blobs   map[string][]byte `json:"blobs"`   // Blob files by ref
+}
+
+// ArchiveYear moves the entries with the given IDs, all written in year,
+// into the archive of that year, removing their files. Entries archived
+// before in the same year are thawed and archived again with them, which
+// needs the private key; archiving alone only needs the public one. The
+// blobs of archived bodies are copied into the archive and left for jot gc.
+func ArchiveYear(year int, ids []string) (*Archive, error) {
+	earlier, err := readHeader(year)
+	if err != nil {
+		return nil, err
+	}
+	if earlier != nil {
+		if _, err := Thaw(year); err != nil {
+			return nil, err
+		}
+		ids = append(ids, earlier.Entries...)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no entries to archive in %d", year)
+	}
+
+	a, err := writeArchive(year, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := reindex(a.Entries, nil); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// writeArchive stores the archive of the entries with the given IDs and
+// removes their files, holding the data lock so none changes meanwhile
+func writeArchive(year int, ids []string) (*Archive, error) {
+	path, err := archivePath(year, archiveSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := lockDataDir()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	content := archiveContent{Entries: make(map[string][]byte), Blobs: make(map[string][]byte)}
+	for _, id := range ids {
+		if _, done := content.Entries[id]; done {
+			continue
+		}
+		entryPath, err := getEntryPath(id)
+		if err != nil {
+			return nil, err
+		}
+		data, err := storage.ReadFile(entryPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read entry %s: %w", id, err)
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e.Entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal entry %s: %w", id, err)
+		}
+		if e.Created.Local().Year() != year {
+			return nil, fmt.Errorf("entry %s was written in %d, not %d", id, e.Created.Local().Year(), year)
+		}
+		content.Entries[id] = data
+		for _, ref := range e.BlobRefs() {
+			blob, err := blobPath(ref)
+			if err != nil {
+				return nil, err
+			}
+			if content.Blobs[ref], err = storage.ReadFile(blob); err != nil {
+				return nil, fmt.Errorf("failed to read blob of entry %s: %w", id, err)
+			}
+		}
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal archive: %w", err)
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	sealed, err := encrypt(compressed.String())
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{Year: year, Archived: time.Now(), Size: int64(len(sealed))}
+	for id := range content.Entries {
+		a.Entries = append(a.Entries, id)
+	}
+	sort.Strings(a.Entries)
+	header, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal archive header: %w", err)
+	}
+
+	if err := storage.WriteFile(path, sealed, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := storage.WriteFile(strings.TrimSuffix(path, archiveSuffix)+headerSuffix, header, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write archive header: %w", err)
+	}
+	for _, id := range a.Entries {
+		entryPath, err := getEntryPath(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := storage.Remove(entryPath); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove archived entry %s: %w", id, err)
+		}
+	}
+	return a, nil
+}
+
+// Thaw brings the entries archived in year back as files of their own and
+// removes the archive, returning how many entries it held. Entries whose
+// file exists meanwhile, such as one synced in from another machine, are
+// kept as they are.
+func Thaw(year int) (int, error) {
+	a, err := readHeader(year)
+	if err != nil {
+		return 0, err
+	}
+	if a == nil {
+		return 0, fmt.Errorf("%d is not archived", year)
+	}
+	path, err := archivePath(year, archiveSuffix)
+	if err != nil {
+		return 0, err
+	}
+	sealed, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Another process thawed it meanwhile
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read archive: %w", err)
+	}
+	compressed, err := decrypt(sealed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt archive of %d: %w", year, err)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(compressed))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	var content archiveContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal archive: %w", err)
+	}
+
+	if err := restoreArchive(year, &content); err != nil {
+		return 0, err
+	}
+
+	entries := make(map[string]*Entry, len(content.Entries))
+	for id := range content.Entries {
+		path, err := getEntryPath(id)
+		if err != nil {
+			return 0, err
+		}
+		if entries[id], err = LoadFile(path); err != nil {
+			return 0, fmt.Errorf("failed to load entry %s: %w", id, err)
+		}
+	}
+	if err := reindex(nil, entries); err != nil {
+		return 0, err
+	}
+	return len(content.Entries), nil
+}
+
+// restoreArchive writes the files of an archive back and removes it,
+// holding the data lock
+func restoreArchive(year int, content *archiveContent) error {
+	path, err := archivePath(year, archiveSuffix)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockDataDir()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	for ref, data := range content.Blobs {
+		blob, err := blobPath(ref)
+		if err != nil {
+			return err
+		}
+		if _, err := storage.Stat(blob); err == nil {
+			continue
+		}
+		if err := storage.MkdirAll(filepath.Dir(blob), 0700); err != nil {
+			return fmt.Errorf("failed to create blob directory: %w", err)
+		}
+		if err := storage.WriteFile(blob, data, 0600); err != nil {
+			return fmt.Errorf("failed to restore blob: %w", err)
+		}
+	}
+	for id, data := range content.Entries {
+		entryPath, err := getEntryPath(id)
+		if err != nil {
+			return err
+		}
+		if info, err := storage.Stat(entryPath); err == nil && info.Size() > 0 {
+			continue
+		}
+		if err := storage.WriteFile(entryPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to restore entry %s: %w", id, err)
+		}
+	}
+
+	// The header goes first, so the archive is never listed without it
+	if err := storage.Remove(strings.TrimSuffix(path, archiveSuffix) + headerSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove archive header: %w", err)
+	}
+	if err := storage.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove archive: %w", err)
+	}
+	return nil
+}
+
+// reindex removes the entries with the IDs in removed from the index and
+// records those in added, in a single update
+func reindex(removed []string, added map[string]*Entry) error {
+	_, err := collection.Update(func(c *collection.Collection) error {
+		changed := false
+		for _, id := range removed {
+			changed = c.UnindexEntry(id) || changed
+		}
+		for id, e := range added {
+			tags, encrypted := e.indexTags()
+			changed = c.IndexEntry(id, e.Created, tags, encrypted) || changed
+		}
+		if !changed {
+			return errIndexUnchanged
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errIndexUnchanged) {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// Archives returns the archived years, oldest first
+func Archives() ([]*Archive, error) {
+	dir, err := archiveDirPath()
+	if err != nil {
+		return nil, err
+	}
+	files, err := storage.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	var archives []*Archive
+	for _, f := range files {
+		year, err := strconv.Atoi(strings.TrimSuffix(f.Name(), headerSuffix))
+		if err != nil || !strings.HasSuffix(f.Name(), headerSuffix) {
+			continue
+		}
+		a, err := readHeader(year)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			archives = append(archives, a)
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Year < archives[j].Year })
+	return archives, nil
+}
+
+// ArchivedIDs returns the year each archived entry is archived in, by ID
+func ArchivedIDs() (map[string]int, error) {
+	archives, err := Archives()
+	if err != nil {
+		return nil, err
+	}
+	years := make(map[string]int)
+	for _, a := range archives {
+		for _, id := range a.Entries {
+			years[id] = a.Year
+		}
+	}
+	return years, nil
+}
+
+// thawFor thaws the year the entry with the given ID is archived in,
+// reporting whether it was archived
+func thawFor(id string) (bool, error) {
+	years, err := ArchivedIDs()
+	if err != nil {
+		return false, err
+	}
+	year, archived := years[id]
+	if !archived {
+		return false, nil
+	}
+	if _, err := Thaw(year); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// readHeader returns the header of the archive of year, or nil when the
+// year is not archived
+func readHeader(year int) (*Archive, error) {
+	path, err := archivePath(year, headerSuffix)
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	var a Archive
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal archive header of %d: %w", year, err)
+	}
+	return &a, nil
+}
+
+// archivePath returns the file of the archive of year with the given suffix
+func archivePath(year int, suffix string) (string, error) {
+	dir, err := archiveDirPath()
+	if err != nil {
+		return "", err
+	}
+	if err := storage.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return filepath.Join(dir, strconv.Itoa(year)+suffix), nil
+}
+
+func archiveDirPath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, archiveDir), nil
+}
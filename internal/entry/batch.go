@@ -0,0 +1,114 @@
+package entry
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/veritome/jot/internal/collection"
+)
+
+// Batch is a set of new entries written together, for importers and API
+// clients that write many at once. Entries of the data directory are
+// encrypted with keys loaded once, get their IDs under a single data lock
+// and are indexed in one collection update when the batch is saved.
+type Batch struct {
+	Entries []*Entry
+
+	sealer *sealer
+}
+
+// NewBatch creates an entry of the journal for each of texts, in order.
+// Close must be called once the batch is saved or abandoned.
+func NewBatch(journalID string, texts []string) (*Batch, error) {
+	s, err := loadSealer()
+	if err != nil {
+		return nil, err
+	}
+	b := &Batch{Entries: make([]*Entry, 0, len(texts)), sealer: s}
+
+	// Encrypt everything first, so a failure leaves no reserved IDs behind
+	for _, text := range texts {
+		e, err := newEntry(journalID, text, s)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		b.Entries = append(b.Entries, e)
+	}
+
+	ids, err := generateIDs(len(texts))
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("failed to generate entry IDs: %w", err)
+	}
+	for i, id := range ids {
+		b.Entries[i].ID = id
+	}
+	return b, nil
+}
+
+// NewSharedBatch creates an entry of a shared journal written by author for
+// each of texts, in order. Shared entries have nothing to amortize beyond
+// saving them together.
+func NewSharedBatch(s *Shared, journalID, author string, texts []string) (*Batch, error) {
+	b := &Batch{Entries: make([]*Entry, 0, len(texts))}
+	for _, text := range texts {
+		e, err := NewShared(s, journalID, author, text)
+		if err != nil {
+			return nil, err
+		}
+		b.Entries = append(b.Entries, e)
+	}
+	return b, nil
+}
+
+// Save writes every entry of the batch and records them in the index
+func (b *Batch) Save() error {
+	indexed := make([]*Entry, 0, len(b.Entries))
+	for _, e := range b.Entries {
+		if e.shared != nil {
+			if err := e.writeShared(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.write(); err != nil {
+			return err
+		}
+		indexed = append(indexed, e)
+	}
+	if len(indexed) == 0 {
+		return nil
+	}
+
+	_, err := collection.Update(func(c *collection.Collection) error {
+		changed := false
+		for _, e := range indexed {
+			tags, encrypted := e.indexTags()
+			if c.IndexEntry(e.ID, e.Created, tags, encrypted) {
+				changed = true
+			}
+		}
+		if !changed {
+			return errIndexUnchanged
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errIndexUnchanged) {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// Close clears the batch's keys from memory. Entries of the batch load the
+// keys again if they are changed afterwards.
+func (b *Batch) Close() {
+	if b.sealer == nil {
+		return
+	}
+	b.sealer.clear()
+	b.sealer = nil
+	for _, e := range b.Entries {
+		e.sealer = nil
+	}
+}
@@ -0,0 +1,306 @@
+package entry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// Large bodies are stored content-addressed in blobs/<ab>/<ref>, where ref
+// is a salted hash of the plaintext, so identical content pasted into
+// several entries or kept in several revisions is stored once. Entries only
+// hold the ref; unreferenced blobs are removed by jot gc.
+const (
+	blobsDir     = "blobs"
+	blobSaltFile = "blobs.salt"
+)
+
+// BlobGracePeriod is how long a blob is kept after it was written or reused
+// even when nothing references it, covering entries that are still being
+// saved when the garbage collector runs
+const BlobGracePeriod = time.Hour
+
+// Blob is a stored body shared by the entries referencing it
+type Blob struct {
+	Ref      string
+	Size     int64
+	Modified time.Time
+}
+
+// storeBody compresses text and encrypts it with encrypt for an entry body
+// or revision, also returning the codec it was compressed with. Bodies
+// reaching storage.blob_threshold go to the blob store and only their ref
+// is returned; smaller ones are returned encrypted.
+func storeBody(text string, encrypt func(string) ([]byte, error)) ([]byte, string, string, error) {
+	compressed, codec, err := compress(text)
+	if err != nil {
+		return nil, "", "", err
+	}
+	threshold := 0
+	if cfg, err := config.Current(); err == nil {
+		threshold = cfg.Int("storage.blob_threshold")
+	}
+	if threshold <= 0 || len(text) < threshold {
+		body, err := encrypt(compressed)
+		return body, "", codec, err
+	}
+
+	ref, err := blobRef(text, codec)
+	if err != nil {
+		return nil, "", "", err
+	}
+	path, err := blobPath(ref)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	reused, err := touchBlob(path)
+	if err != nil || reused {
+		return nil, ref, codec, err
+	}
+
+	data, err := encrypt(compressed)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if err := storage.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, "", "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	// Concurrent writers of the same content write equivalent files, so
+	// the atomic rename is enough without the data lock
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return nil, "", "", fmt.Errorf("failed to write blob %s: %w", ref, err)
+	}
+	return nil, ref, codec, nil
+}
+
+// touchBlob reports whether the blob at path exists, renewing its grace
+// period under the data lock so the garbage collector cannot remove it
+// before the entry reusing it is saved
+func touchBlob(path string) (bool, error) {
+	lock, err := lockDataDir()
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	if _, err := storage.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to stat blob: %w", err)
+	}
+	now := time.Now()
+	if err := storage.Chtimes(path, now, now); err != nil {
+		return false, fmt.Errorf("failed to touch blob: %w", err)
+	}
+	return true, nil
+}
+
+// loadBody decrypts an entry body or revision with decrypt and decompresses
+// it as stored with codec, reading it from the blob store when ref is set.
+// A blob whose content does not hash to its ref is rejected, so blobs
+// cannot be swapped between entries.
+func loadBody(body []byte, ref, codec string, decrypt func([]byte) (string, error)) (string, error) {
+	if ref == "" {
+		compressed, err := decrypt(body)
+		if err != nil {
+			return "", err
+		}
+		return decompress(compressed, codec)
+	}
+
+	data, err := readBlob(ref)
+	if err != nil {
+		return "", err
+	}
+	compressed, err := decrypt(data)
+	if err != nil {
+		return "", err
+	}
+	text, err := decompress(compressed, codec)
+	if err != nil {
+		return "", err
+	}
+	if want, err := blobRef(text, codec); err != nil {
+		return "", err
+	} else if want != ref {
+		return "", fmt.Errorf("blob %s does not match its content", ref)
+	}
+	return text, nil
+}
+
+// readBlob returns the encrypted content of a blob
+func readBlob(ref string) ([]byte, error) {
+	path, err := blobPath(ref)
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", ref, err)
+	}
+	return data, nil
+}
+
+// writeBlob replaces the encrypted content of an existing blob
+func writeBlob(ref string, data []byte) error {
+	path, err := blobPath(ref)
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", ref, err)
+	}
+	return nil
+}
+
+// blobRef returns the salted hash naming the blob of text stored with
+// codec. Compressed blobs are hashed with the codec added to the salt, so
+// they never share a name with an uncompressed blob of the same text.
+func blobRef(text, codec string) (string, error) {
+	salt, err := readSalt(blobSaltFile)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, append(salt, codec...))
+	mac.Write([]byte(text))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// blobPath returns where the blob named ref is stored
+func blobPath(ref string) (string, error) {
+	if len(ref) != 2*sha256.Size {
+		return "", fmt.Errorf("invalid blob reference '%s'", ref)
+	}
+	if _, err := hex.DecodeString(ref); err != nil {
+		return "", fmt.Errorf("invalid blob reference '%s'", ref)
+	}
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, blobsDir, ref[:2], ref), nil
+}
+
+// BlobRefs returns the blobs referenced by the body and revisions of the
+// entry, once per reference
+func (e *Entry) BlobRefs() []string {
+	var refs []string
+	if e.BodyBlob != "" {
+		refs = append(refs, e.BodyBlob)
+	}
+	for _, r := range e.Revisions {
+		if r.Blob != "" {
+			refs = append(refs, r.Blob)
+		}
+	}
+	return refs
+}
+
+// StoredSize returns the bytes the entries with the given IDs take up in
+// the data directory: their files and the blobs their bodies and revisions
+// are kept in, each blob counted once. Missing entries are skipped.
+func StoredSize(ids []string) (int64, error) {
+	var size int64
+	counted := make(map[string]bool)
+	for _, id := range ids {
+		path, err := getEntryPath(id)
+		if err != nil {
+			return 0, err
+		}
+		info, err := storage.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat entry %s: %w", id, err)
+		}
+		size += info.Size()
+
+		e, err := LoadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load entry %s: %w", id, err)
+		}
+		for _, ref := range e.BlobRefs() {
+			if counted[ref] {
+				continue
+			}
+			counted[ref] = true
+			blob, err := blobPath(ref)
+			if err != nil {
+				return 0, err
+			}
+			if info, err := storage.Stat(blob); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return size, nil
+}
+
+// ListBlobs returns every blob in the blob store, sorted by ref
+func ListBlobs() ([]Blob, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(jotDir, blobsDir, "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	blobs := make([]Blob, 0, len(paths))
+	for _, p := range paths {
+		info, err := storage.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat blob: %w", err)
+		}
+		// Leftover temporary files of interrupted writes are not blobs
+		if !info.Mode().IsRegular() || len(info.Name()) != 2*sha256.Size {
+			continue
+		}
+		blobs = append(blobs, Blob{Ref: info.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	sort.Slice(blobs, func(a, b int) bool { return blobs[a].Ref < blobs[b].Ref })
+	return blobs, nil
+}
+
+// RemoveBlob deletes a blob unless it was written or reused after since. It
+// reports whether the blob was removed.
+func RemoveBlob(ref string, since time.Time) (bool, error) {
+	path, err := blobPath(ref)
+	if err != nil {
+		return false, err
+	}
+
+	lock, err := lockDataDir()
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	info, err := storage.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat blob: %w", err)
+	}
+	if info.ModTime().After(since) {
+		return false, nil
+	}
+	if err := storage.Remove(path); err != nil {
+		return false, fmt.Errorf("failed to remove blob %s: %w", ref, err)
+	}
+	// Drop the fan-out directory once it is empty
+	storage.Remove(filepath.Dir(path))
+	return true, nil
+}
@@ -0,0 +1,43 @@
+package entry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// SetBookmark stores the link of a bookmark entry, encrypted like the body.
+// The body keeps a readable copy for every other view.
+func (e *Entry) SetBookmark(b *types.Bookmark) error {
+	if e.Sealed() {
+		return ErrAppendOnly
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to encode bookmark: %w", err)
+	}
+	link, err := e.encrypt(string(data))
+	if err != nil {
+		return err
+	}
+	e.Link = link
+	return nil
+}
+
+// GetBookmark returns the link of the entry, or nil when it is not a
+// bookmark
+func (e *Entry) GetBookmark() (*types.Bookmark, error) {
+	if len(e.Link) == 0 {
+		return nil, nil
+	}
+	data, err := e.decrypt(e.Link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt bookmark of entry %s: %w", e.ID, err)
+	}
+	var b types.Bookmark
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return nil, fmt.Errorf("failed to decode bookmark of entry %s: %w", e.ID, err)
+	}
+	return &b, nil
+}
@@ -0,0 +1,102 @@
+package entry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrAppendOnly is returned when trying to modify or delete a sealed entry
+var ErrAppendOnly = errors.New("entry is append-only and cannot be modified or deleted")
+
+// Seal links the entry into a hash chain after prevHash. A sealed entry can
+// never be rewritten or deleted through this package.
+func (e *Entry) Seal(prevHash string) {
+	e.PrevHash = prevHash
+	e.Hash = e.computeHash()
+}
+
+// Sealed reports whether the entry is part of an append-only hash chain
+func (e *Entry) Sealed() bool {
+	return e.Hash != ""
+}
+
+// VerifyHash reports whether the stored hash matches the entry contents
+func (e *Entry) VerifyHash() bool {
+	if !e.Sealed() {
+		return false
+	}
+	if !strings.HasPrefix(e.Hash, hashVersion) {
+		// Sealed before tags, title and the flags were hashed
+		return e.Hash == e.legacyHash()
+	}
+	return e.Hash == e.computeHash()
+}
+
+// hashVersion prefixes hashes covering every stored field. A hash cannot be
+// swapped for an older kind without breaking the link to the next entry.
+const hashVersion = "v2:"
+
+// computeHash hashes the previous chain hash together with every stored
+// field of the entry. Left out are the attestations added after writing
+// (countersignatures and timestamps) and the state that may change on a
+// sealed entry: its queue state and when a task was done.
+func (e *Entry) computeHash() string {
+	h := sha256.New()
+	e.writeHashed(h)
+	if e.Updated != nil {
+		fmt.Fprintf(h, "\nupdated:%s", e.Updated.UTC().Format(time.RFC3339Nano))
+	}
+	fmt.Fprintf(h, "\ndigest:%s\nauthor:%q\ntitle:%q\ntags:%q\nmeta:%x",
+		e.Digest, e.Author, e.Title, e.Tags, e.Meta)
+	fmt.Fprintf(h, "\nsensitive:%t\ndraft:%t\ntask:%t", e.Sensitive, e.Draft, e.Task)
+	for _, r := range e.Revisions {
+		fmt.Fprintf(h, "\nrevision:%d:%s:%x:%s:%s", r.Number,
+			r.Replaced.UTC().Format(time.RFC3339Nano), r.Body, r.Blob, r.Codec)
+	}
+	return hashVersion + hex.EncodeToString(h.Sum(nil))
+}
+
+// legacyHash is the hash of entries sealed before computeHash covered
+// every stored field
+func (e *Entry) legacyHash() string {
+	h := sha256.New()
+	e.writeHashed(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashed writes the fields hashed since the first chains: the ID, the
+// encrypted body or the ref of its blob, and any check-in answers, bookmark,
+// fields or rollup. Blobs are named by a hash of their content, so the ref
+// covers it too.
+func (e *Entry) writeHashed(h io.Writer) {
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s\n",
+		e.PrevHash,
+		e.ID,
+		e.JournalID,
+		e.Created.UTC().Format(time.RFC3339Nano),
+		e.Supersedes)
+	h.Write(e.Body)
+	if e.BodyBlob != "" {
+		fmt.Fprintf(h, "\nblob:%s", e.BodyBlob)
+	}
+	if e.Codec != "" {
+		fmt.Fprintf(h, "\ncodec:%s", e.Codec)
+	}
+	if len(e.Answers) > 0 {
+		fmt.Fprintf(h, "\nanswers:%x", e.Answers)
+	}
+	if len(e.Link) > 0 {
+		fmt.Fprintf(h, "\nlink:%x", e.Link)
+	}
+	if len(e.Entry.Fields) > 0 {
+		fmt.Fprintf(h, "\nfields:%x", e.Entry.Fields)
+	}
+	if len(e.Entry.Rollup) > 0 {
+		fmt.Fprintf(h, "\nrollup:%x", e.Entry.Rollup)
+	}
+}
@@ -0,0 +1,83 @@
+package entry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+func sealedEntry(prevHash string) *Entry {
+	e := &Entry{Entry: &types.Entry{
+		ID:        "0001",
+		Created:   time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC),
+		Body:      []byte("encrypted body"),
+		JournalID: "j1",
+		Title:     "Morning",
+		Tags:      []string{"work"},
+	}}
+	e.Seal(prevHash)
+	return e
+}
+
+func TestSealVerifies(t *testing.T) {
+	e := sealedEntry("")
+	if !e.Sealed() || !strings.HasPrefix(e.Hash, hashVersion) {
+		t.Fatalf("Hash = %q, want a %s hash", e.Hash, hashVersion)
+	}
+	if !e.VerifyHash() {
+		t.Error("VerifyHash rejected a freshly sealed entry")
+	}
+	if (&Entry{Entry: &types.Entry{ID: "0002"}}).VerifyHash() {
+		t.Error("VerifyHash accepted an entry that was never sealed")
+	}
+}
+
+func TestVerifyHashDetectsChanges(t *testing.T) {
+	for name, change := range map[string]func(e *Entry){
+		"body":      func(e *Entry) { e.Body = []byte("other body") },
+		"title":     func(e *Entry) { e.Title = "Evening" },
+		"tags":      func(e *Entry) { e.Tags = nil },
+		"created":   func(e *Entry) { e.Created = e.Created.Add(time.Hour) },
+		"id":        func(e *Entry) { e.ID = "0009" },
+		"prev hash": func(e *Entry) { e.PrevHash = "elsewhere" },
+		"sensitive": func(e *Entry) { e.Sensitive = true },
+	} {
+		e := sealedEntry("")
+		change(e)
+		if e.VerifyHash() {
+			t.Errorf("VerifyHash accepted a change of the %s", name)
+		}
+	}
+}
+
+func TestVerifyHashIgnoresAttestations(t *testing.T) {
+	e := sealedEntry("")
+	done := time.Now()
+	e.Countersigns = []byte("countersignatures")
+	e.Entry.Timestamps = []byte("timestamps")
+	e.State = "read"
+	e.Done = &done
+	if !e.VerifyHash() {
+		t.Error("VerifyHash rejected an entry given attestations and a state after sealing")
+	}
+}
+
+func TestVerifyHashLegacy(t *testing.T) {
+	e := sealedEntry("previous")
+	e.Hash = e.legacyHash()
+	if !e.VerifyHash() {
+		t.Fatal("VerifyHash rejected an entry sealed before hashes covered every field")
+	}
+
+	// Legacy hashes never covered the title, but do cover the body
+	e.Title = "Evening"
+	if !e.VerifyHash() {
+		t.Error("VerifyHash rejected a legacy entry over a field its hash does not cover")
+	}
+	e.Body = []byte("other body")
+	if e.VerifyHash() {
+		t.Error("VerifyHash accepted a change of a legacy entry's body")
+	}
+}
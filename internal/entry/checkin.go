@@ -0,0 +1,43 @@
+package entry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// SetCheckin stores the structured answers of a check-in entry, encrypted
+// like the body. The body keeps a readable copy for every other view.
+func (e *Entry) SetCheckin(c *types.Checkin) error {
+	if e.Sealed() {
+		return ErrAppendOnly
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode check-in: %w", err)
+	}
+	answers, err := e.encrypt(string(data))
+	if err != nil {
+		return err
+	}
+	e.Answers = answers
+	return nil
+}
+
+// GetCheckin returns the check-in answers of the entry, or nil when it is
+// not a check-in
+func (e *Entry) GetCheckin() (*types.Checkin, error) {
+	if len(e.Answers) == 0 {
+		return nil, nil
+	}
+	data, err := e.decrypt(e.Answers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt check-in of entry %s: %w", e.ID, err)
+	}
+	var c types.Checkin
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		return nil, fmt.Errorf("failed to decode check-in of entry %s: %w", e.ID, err)
+	}
+	return &c, nil
+}
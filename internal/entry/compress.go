@@ -0,0 +1,66 @@
+package entry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+)
+
+// Bodies are compressed before they are encrypted, when that makes them
+// smaller; the entry records the codec next to the body
+const (
+	codecGzip = "gzip"
+	// compressMin is the shortest body compressed; shorter ones gain
+	// less than the codec's own header costs
+	compressMin = 256
+)
+
+// compress returns text compressed with the codec set by
+// storage.compression and that codec, or text itself and no codec when
+// compressing would not save anything
+func compress(text string) (string, string, error) {
+	codec := codecGzip
+	if cfg, err := config.Current(); err == nil {
+		codec = cfg.String("storage.compression")
+	}
+	if codec != codecGzip || len(text) < compressMin {
+		return text, "", nil
+	}
+
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write([]byte(text)); err != nil {
+		return "", "", fmt.Errorf("failed to compress body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", "", fmt.Errorf("failed to compress body: %w", err)
+	}
+	if b.Len() >= len(text) {
+		return text, "", nil
+	}
+	return b.String(), codecGzip, nil
+}
+
+// decompress reverses compress for a body stored with codec
+func decompress(data, codec string) (string, error) {
+	switch codec {
+	case "":
+		return data, nil
+	case codecGzip:
+		zr, err := gzip.NewReader(strings.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress body: %w", err)
+		}
+		text, err := io.ReadAll(zr)
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress body: %w", err)
+		}
+		return string(text), nil
+	default:
+		return "", fmt.Errorf("unknown body codec '%s'", codec)
+	}
+}
@@ -0,0 +1,374 @@
+package entry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Countersignatures are kept encrypted with the entry, since a signature
+// over a hash of the body would let anyone holding the file confirm a
+// guess of what it says. Like timestamps, they are not covered by the hash
+// chain, so entries of append-only journals can be countersigned after
+// sealing.
+//
+// The witness signs on their own machine: the owner exports a request
+// with the entry's body, the witness signs it with a key that never leaves
+// them, and the owner imports the signature, which is checked against the
+// witness's public key. Every countersignature also goes into a log in
+// countersigns/, and names the one logged before it in what the witness
+// signs, so removing one from an entry or the log shows up when verifying,
+// and each witness's copy of their signature vouches for all before it.
+
+// States of a countersignature or timestamp checked against the entry
+const (
+	ProofValid       = "valid"        // It holds for the current body
+	ProofBodyChanged = "body changed" // It holds, but the body was edited afterwards
+	ProofInvalid     = "invalid"      // It does not match what it claims to cover
+)
+
+// countersignDir holds the countersignature log in the data directory
+const countersignDir = "countersigns"
+
+// firstInLog is the Previous of the first countersignature logged
+const firstInLog = "none"
+
+// CountersignRequest is an entry as shown to a witness to countersign
+type CountersignRequest struct {
+	Entry    string    `json:"entry"`
+	Created  time.Time `json:"created"`
+	Body     string    `json:"body"`
+	Previous string    `json:"previous"` // Log head the countersignature will follow
+}
+
+// Witnessed is a countersignature with the entry it is for, as a witness
+// returns it and as kept in the log
+type Witnessed struct {
+	Entry   string    `json:"entry"`
+	Created time.Time `json:"created"`
+	types.Countersignature
+}
+
+// CountersignRequest returns the request for a witness to countersign the
+// entry's current body, following the newest countersignature logged
+func (e *Entry) CountersignRequest() (*CountersignRequest, error) {
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		return nil, err
+	}
+	head, err := countersignHead()
+	if err != nil {
+		return nil, err
+	}
+	return &CountersignRequest{Entry: e.ID, Created: e.Created, Body: body, Previous: head}, nil
+}
+
+// SignCountersignRequest countersigns r with the witness key. It is run by
+// the witness and needs nothing of the journal but the request.
+func SignCountersignRequest(r *CountersignRequest, key *crypto.WitnessKey) (*Witnessed, error) {
+	if r.Entry == "" || r.Previous == "" {
+		return nil, fmt.Errorf("not a countersignature request made by jot countersign")
+	}
+	w := &Witnessed{
+		Entry:   r.Entry,
+		Created: r.Created,
+		Countersignature: types.Countersignature{
+			Witness:   key.Name,
+			PublicKey: key.PublicKeyString(),
+			Signed:    time.Now().UTC(),
+			BodyHash:  hashBody(r.Body),
+			Previous:  r.Previous,
+		},
+	}
+	var err error
+	if w.Signature, err = key.Sign(w.Statement()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// AddCountersignature checks that w was signed by the witness key for the
+// entry's current body, following the newest countersignature logged, and
+// adds it to the log and the entry. The caller is responsible for saving
+// the entry afterwards.
+func (e *Entry) AddCountersignature(w *Witnessed, witness *crypto.WitnessKey) error {
+	if w.PublicKey != witness.PublicKeyString() {
+		return fmt.Errorf("the countersignature was made with another key than '%s'", witness.Name)
+	}
+	if w.Entry != e.ID || !w.Created.Equal(e.Created) {
+		return fmt.Errorf("the countersignature is for entry %s, not %s", w.Entry, e.ID)
+	}
+	if !crypto.VerifyWitnessSignature(w.PublicKey, w.Statement(), w.Signature) {
+		return fmt.Errorf("the countersignature does not match what it claims to sign")
+	}
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		return err
+	}
+	if w.BodyHash != hashBody(body) {
+		return fmt.Errorf("entry %s was edited after the countersignature was requested; request it again", e.ID)
+	}
+	existing, err := e.Countersignatures()
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.PublicKey == w.PublicKey && other.BodyHash == w.BodyHash {
+			return fmt.Errorf("entry %s is already countersigned by '%s'", e.ID, other.Witness)
+		}
+	}
+
+	if err := logCountersignature(w); err != nil {
+		return err
+	}
+	data, err := json.Marshal(append(existing, w.Countersignature))
+	if err != nil {
+		return fmt.Errorf("failed to encode countersignatures: %w", err)
+	}
+	e.Countersigns, err = e.encrypt(string(data))
+	return err
+}
+
+// Countersignatures returns the entry's countersignatures, oldest first
+func (e *Entry) Countersignatures() ([]types.Countersignature, error) {
+	if len(e.Countersigns) == 0 {
+		return nil, nil
+	}
+	data, err := e.decrypt(e.Countersigns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt countersignatures of entry %s: %w", e.ID, err)
+	}
+	var cs []types.Countersignature
+	if err := json.Unmarshal([]byte(data), &cs); err != nil {
+		return nil, fmt.Errorf("failed to decode countersignatures of entry %s: %w", e.ID, err)
+	}
+	return cs, nil
+}
+
+// CountersignStatement returns the text a countersignature signs. Anyone
+// holding it, the witness's public key and the body can check the
+// signature with any ed25519 tool.
+func (e *Entry) CountersignStatement(c types.Countersignature) []byte {
+	return (&Witnessed{Entry: e.ID, Created: e.Created, Countersignature: c}).Statement()
+}
+
+// Statement returns the text the countersignature signs
+func (w *Witnessed) Statement() []byte {
+	statement := fmt.Sprintf("jot countersignature\nentry: %s\ncreated: %s\nbody-sha256: %s\nwitness: %s\npublic-key: %s\nsigned: %s\n",
+		w.Entry,
+		w.Created.UTC().Format(time.RFC3339Nano),
+		w.BodyHash,
+		w.Witness,
+		w.PublicKey,
+		w.Signed.UTC().Format(time.RFC3339Nano))
+	if w.Previous != "" {
+		statement += fmt.Sprintf("previous: %s\n", w.Previous)
+	}
+	return []byte(statement)
+}
+
+// hash identifies the countersignature in the log
+func (w *Witnessed) hash() string {
+	sum := sha256.Sum256(append(w.Statement(), w.Signature...))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyCountersignature checks c against the entry and its decrypted body
+func (e *Entry) VerifyCountersignature(c types.Countersignature, body string) string {
+	if !crypto.VerifyWitnessSignature(c.PublicKey, e.CountersignStatement(c), c.Signature) {
+		return ProofInvalid
+	}
+	if c.BodyHash != hashBody(body) {
+		return ProofBodyChanged
+	}
+	return ProofValid
+}
+
+func hashBody(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// countersignLogDir returns the directory of the countersignature log
+func countersignLogDir() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, countersignDir), nil
+}
+
+// readCountersignLog returns the logged countersignatures in the order
+// they were logged
+func readCountersignLog() ([]*Witnessed, error) {
+	dir, err := countersignLogDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := storage.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read countersignature log: %w", err)
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".json" {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	log := make([]*Witnessed, 0, len(names))
+	for _, name := range names {
+		data, err := storage.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read countersignature log: %w", err)
+		}
+		var w Witnessed
+		if err := json.Unmarshal(data, &w); err != nil {
+			return nil, fmt.Errorf("failed to decode countersignature %s: %w", name, err)
+		}
+		log = append(log, &w)
+	}
+	return log, nil
+}
+
+// countersignHead returns the hash of the newest countersignature logged,
+// or firstInLog when there is none
+func countersignHead() (string, error) {
+	log, err := readCountersignLog()
+	if err != nil {
+		return "", err
+	}
+	if len(log) == 0 {
+		return firstInLog, nil
+	}
+	return log[len(log)-1].hash(), nil
+}
+
+// logCountersignature appends w to the log, holding the data lock, when it
+// follows the newest countersignature logged and is not in it yet
+func logCountersignature(w *Witnessed) error {
+	dir, err := countersignLogDir()
+	if err != nil {
+		return err
+	}
+	lock, err := lockDataDir()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	log, err := readCountersignLog()
+	if err != nil {
+		return err
+	}
+	head := firstInLog
+	for _, logged := range log {
+		// Logged already by an import that failed to save the entry
+		if logged.Signature == w.Signature && logged.PublicKey == w.PublicKey {
+			return nil
+		}
+		head = logged.hash()
+	}
+	if w.Previous != head {
+		return fmt.Errorf("another countersignature was added since this one was requested; request it again")
+	}
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode countersignature: %w", err)
+	}
+	if err := storage.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create countersignature log: %w", err)
+	}
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), w.hash()[:16])
+	if err := storage.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write countersignature log: %w", err)
+	}
+	return nil
+}
+
+// VerifyCountersignLog checks that the log is one unbroken chain of valid
+// signatures, and that the entry each is for still carries it, returning
+// a description of every problem found. entries returns the
+// countersignatures an entry carries, and false when it does not exist.
+func VerifyCountersignLog(entries func(id string) ([]types.Countersignature, bool, error)) ([]string, error) {
+	log, err := readCountersignLog()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	previous := firstInLog
+	for _, w := range log {
+		if !crypto.VerifyWitnessSignature(w.PublicKey, w.Statement(), w.Signature) {
+			problems = append(problems, fmt.Sprintf("countersignature by '%s' of entry %s does not match what it signs", w.Witness, w.Entry))
+		}
+		if w.Previous != previous {
+			problems = append(problems, fmt.Sprintf("a countersignature logged before the one by '%s' of entry %s was removed", w.Witness, w.Entry))
+		}
+		previous = w.hash()
+
+		cs, exists, err := entries(w.Entry)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		found := false
+		for _, c := range cs {
+			found = found || (c.PublicKey == w.PublicKey && c.Signature == w.Signature)
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("entry %s no longer carries its countersignature by '%s'", w.Entry, w.Witness))
+		}
+	}
+	return problems, nil
+}
+
+// CountersignLogged reports whether w is in the log, so a witness holding
+// their signature can check it was not dropped
+func CountersignLogged(w *Witnessed) (bool, error) {
+	log, err := readCountersignLog()
+	if err != nil {
+		return false, err
+	}
+	for _, logged := range log {
+		if logged.Signature == w.Signature && logged.PublicKey == w.PublicKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// onlyAttested reports whether the stored entry data differs from e in
+// nothing but its countersignatures, timestamps, state and task completion,
+// none of which the chain covers
+func (e *Entry) onlyAttested(stored []byte) bool {
+	var old types.Entry
+	if err := json.Unmarshal(stored, &old); err != nil {
+		return false
+	}
+	current := *e.Entry
+	old.Countersigns, current.Countersigns = nil, nil
+	old.Timestamps, current.Timestamps = nil, nil
+	old.State, current.State = "", ""
+	old.Done, current.Done = nil, nil
+	a, errA := json.Marshal(old)
+	b, errB := json.Marshal(current)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
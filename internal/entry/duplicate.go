@@ -0,0 +1,49 @@
+package entry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/veritome/jot/internal/atrest"
+	"github.com/veritome/jot/internal/config"
+)
+
+// DuplicateWindow returns how far apart two entries with the same body may
+// have been created to count as duplicates. Zero disables the check.
+func DuplicateWindow() time.Duration {
+	if cfg, err := config.Current(); err == nil {
+		return time.Duration(cfg.Int("entry.duplicate_window")) * time.Minute
+	}
+	return 0
+}
+
+// BodyDigest returns the keyed hash stored to spot duplicate bodies without
+// decrypting anything. The key is derived from the at-rest master key, kept
+// with the key pairs rather than in the data directory, so the digests of
+// a copied data directory cannot be checked against guessed bodies.
+func BodyDigest(text string) (string, error) {
+	key, err := atrest.Subkey("jot body digest")
+	if err != nil {
+		return "", fmt.Errorf("failed to derive digest key: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(text))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// IsDuplicate reports whether the entry's body has the given digest and it
+// was created within window of created. Entries written before digests
+// were stored never are.
+func (e *Entry) IsDuplicate(digest string, created time.Time, window time.Duration) bool {
+	if e.Digest == "" || e.Digest != digest {
+		return false
+	}
+	d := e.Created.Sub(created)
+	if d < 0 {
+		d = -d
+	}
+	return d <= window
+}
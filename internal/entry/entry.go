@@ -2,92 +2,347 @@ package entry
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/veritome/jot/internal/config"
 	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/device"
+	"github.com/veritome/jot/internal/idgen"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/trash"
 	"github.com/veritome/jot/internal/types"
 )
 
 // Entry represents a single journal entry
 type Entry struct {
 	*types.Entry
+
+	// meta is the metadata as last stored by this process, so that setting
+	// the tags and then the title of a new entry never decrypts anything
+	meta *metadata
+
+	// shared is set for entries of a shared journal, which are stored in
+	// its folder instead of the data directory
+	shared *Shared
+
+	// claimed is the author a shared entry names when its signature does
+	// not verify, which is what is stored again
+	claimed string
+
+	// sealer is set while the entry is part of a batch, whose entries are
+	// encrypted with the same loaded keys
+	sealer *sealer
+
+	// opener is set while entries loaded together are decrypted with the
+	// same restored keys
+	opener *opener
 }
 
 // New creates a new entry with the given text
 func New(journalID string, text string) (*Entry, error) {
-	// Get NaCl keys
-	keyPair, err := crypto.RestoreNaclFromBackup()
+	e, err := newEntry(journalID, text, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to restore NaCl keys: %w", err)
+		return nil, err
 	}
-	defer keyPair.Clear()
-
-	// Encrypt the entry body
-	encryptedBody, err := crypto.EncryptNacl(text, keyPair)
+	ids, err := generateIDs(1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt entry with NaCl: %w", err)
+		return nil, fmt.Errorf("failed to generate entry ID: %w", err)
 	}
+	e.ID = ids[0]
+	return e, nil
+}
 
-	return &Entry{
+// newEntry encrypts text as the body of a new entry, with s or the
+// configured keys when s is nil. The entry has no ID yet.
+func newEntry(journalID string, text string, s *sealer) (*Entry, error) {
+	e := &Entry{
 		Entry: &types.Entry{
-			ID:        generateID(),
 			Created:   time.Now(),
-			Body:      encryptedBody,
 			JournalID: journalID,
 		},
-	}, nil
+		sealer: s,
+	}
+
+	var err error
+	if e.Body, e.BodyBlob, e.Codec, err = storeBody(text, e.encrypt); err != nil {
+		return nil, err
+	}
+	if e.Digest, err = BodyDigest(text); err != nil {
+		return nil, err
+	}
+	return e, nil
 }
 
-// generateID creates a unique four-digit identifier for the entry
-func generateID() string {
+// generateIDs creates n unique entry identifiers using the configured
+// entry.id_format. Each ID is reserved by creating an empty entry file while
+// holding the data lock, so concurrent jot processes never hand out the same ID.
+func generateIDs(n int) ([]string, error) {
+	format := "sequential"
+	if cfg, err := config.Current(); err == nil {
+		format = cfg.String("entry.id_format")
+	}
+	gen, err := idgen.New(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry.id_format: %w", err)
+	}
+
+	lock, err := lockDataDir()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
 	// Get the entries directory
-	homeDir, err := os.UserHomeDir()
+	entriesDir, err := getEntriesDir()
 	if err != nil {
-		panic("Unable to access home directory")
+		return nil, err
 	}
 
-	entriesDir := filepath.Join(homeDir, ".jot", "entries")
-	files, err := os.ReadDir(entriesDir)
+	taken, err := takenIDs(entriesDir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// If the directory doesn't exist, start with 0001
-			return "0001"
+		return nil, err
+	}
+
+	ids := make([]string, 0, n)
+	for len(ids) < n {
+		id, err := gen.Next(taken, time.Now())
+		if err != nil {
+			return nil, err
 		}
-		panic("Unable to read entries directory")
+		if err := storage.CreateExclusive(filepath.Join(entriesDir, id+".json"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to reserve entry ID %s: %w", id, err)
+		}
+		ids = append(ids, id)
+		taken = append(taken, id)
+	}
+
+	return ids, nil
+}
+
+// TakenIDs returns every entry ID in use: those of stored entries, and of
+// the trashed, archived and deleted entries that can still come back
+func TakenIDs() ([]string, error) {
+	entriesDir, err := getEntriesDir()
+	if err != nil {
+		return nil, err
 	}
 
-	maxID := 0
+	lock, err := lockDataDir()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	return takenIDs(entriesDir)
+}
+
+// takenIDs implements TakenIDs; the caller holds the data lock
+func takenIDs(entriesDir string) ([]string, error) {
+	files, err := storage.ReadDir(entriesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries directory: %w", err)
+	}
+	names := make([]string, 0, len(files))
 	for _, file := range files {
-		// Remove the .json extension and try to parse the ID
-		name := strings.TrimSuffix(file.Name(), ".json")
-		id, err := strconv.Atoi(name)
-		if err == nil && id > maxID {
-			maxID = id
-		}
+		names = append(names, file.Name())
+	}
+
+	// IDs of trashed entries stay taken so they can be restored
+	trashed, err := trash.EntryFiles()
+	if err != nil {
+		return nil, err
 	}
+	names = append(names, trashed...)
 
-	// Increment the maximum ID found and format as a four-digit string
-	return fmt.Sprintf("%04d", maxID+1)
+	// So do those of deleted entries jot undo can bring back
+	kept, err := oplog.EntryFiles()
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, kept...)
+
+	// And those of archived entries
+	archived, err := ArchivedIDs()
+	if err != nil {
+		return nil, err
+	}
+	for id := range archived {
+		names = append(names, id+".json")
+	}
+
+	taken := make([]string, 0, len(names))
+	for _, file := range names {
+		if strings.HasSuffix(file, ".json") {
+			taken = append(taken, strings.TrimSuffix(file, ".json"))
+		}
+	}
+	return taken, nil
 }
 
 // GetDecryptedBody returns the decrypted entry content
 func (e *Entry) GetDecryptedBody() (string, error) {
-	keyPair, err := crypto.RestoreNaclFromBackup()
+	return loadBody(e.Body, e.BodyBlob, e.Codec, e.decrypt)
+}
+
+// encrypt seals text with the configured backend: to the user's GPG key, or
+// to the current NaCl key and every configured recipient
+func encrypt(text string) ([]byte, error) {
+	s, err := loadSealer()
+	if err != nil {
+		return nil, err
+	}
+	defer s.clear()
+	return s.seal(text)
+}
+
+// sealer holds the keys new data is encrypted to, so that a batch of
+// entries loads them only once
+type sealer struct {
+	gpgRecipient string
+	keyPair      *crypto.KeyPair
+	recipients   []*[32]byte
+}
+
+func loadSealer() (*sealer, error) {
+	if cfg, err := config.Current(); err == nil && cfg.String("crypto.backend") == "gpg" {
+		recipient := cfg.String("crypto.gpg_recipient")
+		if recipient == "" {
+			return nil, fmt.Errorf("crypto.backend is gpg but crypto.gpg_recipient is not set")
+		}
+		return &sealer{gpgRecipient: recipient}, nil
+	}
+
+	// Encrypting only needs the public key, so writing never asks for the
+	// security key and works with the private key kept offline
+	keyPair, err := crypto.RestorePublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore NaCl keys: %w", err)
+	}
+	recipients, err := recipientKeys()
+	if err != nil {
+		keyPair.Clear()
+		return nil, err
+	}
+	return &sealer{keyPair: keyPair, recipients: recipients}, nil
+}
+
+// recipientKeys returns the public keys entries are encrypted to besides
+// the owner's: the configured recipients and the other approved devices
+func recipientKeys() ([]*[32]byte, error) {
+	recipients, err := crypto.RecipientKeys()
+	if err != nil {
+		return nil, err
+	}
+	devices, err := device.OtherKeys()
+	if err != nil {
+		return nil, err
+	}
+	return append(recipients, devices...), nil
+}
+
+func (s *sealer) seal(text string) ([]byte, error) {
+	if s.gpgRecipient != "" {
+		return crypto.EncryptGPG(text, s.gpgRecipient)
+	}
+	encrypted, err := crypto.EncryptFor(text, s.keyPair, s.recipients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt entry with NaCl: %w", err)
+	}
+	return encrypted, nil
+}
+
+func (s *sealer) clear() {
+	if s.keyPair != nil {
+		s.keyPair.Clear()
+	}
+}
+
+// decrypt opens data with GPG or whichever known key pair encrypted it, so
+// entries stay readable during and after a key rotation or backend change
+func decrypt(data []byte) (string, error) {
+	if crypto.IsGPG(data) {
+		return crypto.DecryptGPG(data)
+	}
+
+	keys, err := crypto.Keyring()
 	if err != nil {
 		return "", fmt.Errorf("failed to restore NaCl keys: %w", err)
 	}
-	defer keyPair.Clear()
+	defer crypto.ClearAll(keys)
+
+	return crypto.DecryptWithKeyring(data, keys)
+}
+
+// opener holds the key pairs a set of entries is decrypted with. They are
+// restored when the first of them is decrypted, rather than once for every
+// entry, and cleared by Close once the entries are loaded. Entries decrypted
+// later restore the keys themselves.
+type opener struct {
+	mu     sync.RWMutex
+	once   sync.Once
+	keys   []*crypto.KeyPair
+	err    error
+	closed bool
+}
+
+func (o *opener) open(data []byte) (string, error) {
+	if crypto.IsGPG(data) {
+		return crypto.DecryptGPG(data)
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		return decrypt(data)
+	}
+	o.once.Do(func() {
+		if o.keys, o.err = crypto.Keyring(); o.err != nil {
+			o.err = fmt.Errorf("failed to restore NaCl keys: %w", o.err)
+		}
+	})
+	if o.err != nil {
+		return "", o.err
+	}
+	return crypto.DecryptWithKeyring(data, o.keys)
+}
 
-	return crypto.DecryptNacl(e.Body, keyPair)
+// Close clears the restored key pairs
+func (o *opener) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	crypto.ClearAll(o.keys)
+	o.keys, o.closed = nil, true
 }
 
-// Save persists the entry to storage
+// decrypt opens data stored in the entry, with the keys shared by the
+// entries it was loaded with if any
+func (e *Entry) decrypt(data []byte) (string, error) {
+	if e.opener != nil {
+		return e.opener.open(data)
+	}
+	return decrypt(data)
+}
+
+// Save persists the entry to storage and updates the collection's index
 func (e *Entry) Save() error {
+	// Shared entries are not in the index, which only covers this data directory
+	if e.shared != nil {
+		return e.writeShared()
+	}
+	if err := e.write(); err != nil {
+		return err
+	}
+	return e.updateIndex()
+}
+
+// write stores the entry file while holding the data lock
+func (e *Entry) write() error {
 	data, err := json.MarshalIndent(e.Entry, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal entry: %w", err)
@@ -98,35 +353,88 @@ func (e *Entry) Save() error {
 		return fmt.Errorf("failed to get entry path: %w", err)
 	}
 
-	if err := os.WriteFile(entryPath, data, 0600); err != nil {
+	lock, err := lockDataDir()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return e.writeLocked(entryPath, data)
+}
+
+// writeLocked stores data as the entry file at entryPath. The caller holds
+// the data lock.
+func (e *Entry) writeLocked(entryPath string, data []byte) error {
+	// Sealed entries are written exactly once; only the empty file
+	// reserving the ID may be replaced, countersignatures and timestamps
+	// added or the state changed
+	if e.Sealed() {
+		if stored, err := storage.ReadFile(entryPath); err == nil && len(stored) > 0 && !e.onlyAttested(stored) {
+			return ErrAppendOnly
+		}
+	}
+
+	if err := storage.WriteFile(entryPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write entry file: %w", err)
 	}
 
 	return nil
 }
 
-// Delete removes the entry from storage
+// Delete removes the entry from storage and the collection's index
 func (e *Entry) Delete() error {
+	if e.Sealed() {
+		return ErrAppendOnly
+	}
+	if e.shared != nil {
+		return e.removeShared()
+	}
+	if err := e.remove(); err != nil {
+		return err
+	}
+	return unindex(e.ID)
+}
+
+// remove deletes the entry file while holding the data lock
+func (e *Entry) remove() error {
 	entryPath, err := getEntryPath(e.ID)
 	if err != nil {
 		return fmt.Errorf("failed to get entry path: %w", err)
 	}
 
-	if err := os.Remove(entryPath); err != nil && !os.IsNotExist(err) {
+	lock, err := lockDataDir()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := storage.Remove(entryPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete entry file: %w", err)
 	}
 
 	return nil
 }
 
-// Load loads an entry from storage by its ID
+// Load loads an entry from storage by its ID, thawing the year it is
+// archived in if it is
 func Load(id string) (*Entry, error) {
 	entryPath, err := getEntryPath(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get entry path: %w", err)
 	}
+	e, err := LoadFile(entryPath)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		if thawed, thawErr := thawFor(id); thawErr != nil {
+			return nil, thawErr
+		} else if thawed {
+			return LoadFile(entryPath)
+		}
+	}
+	return e, err
+}
 
-	data, err := os.ReadFile(entryPath)
+// LoadFile loads an entry from the file at path, such as a trashed entry
+func LoadFile(path string) (*Entry, error) {
+	data, err := storage.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read entry file: %w", err)
 	}
@@ -139,26 +447,129 @@ func Load(id string) (*Entry, error) {
 	return &Entry{Entry: &entry}, nil
 }
 
+// ListIDs returns the IDs of all stored entries, skipping files that only
+// reserve an ID for an entry still being written
+func ListIDs() ([]string, error) {
+	entriesDir, err := getEntriesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := storage.ReadDir(entriesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries directory: %w", err)
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		if info, err := file.Info(); err != nil || info.Size() == 0 {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(file.Name(), ".json"))
+	}
+	return ids, nil
+}
+
 // getEntryPath returns the path where an entry should be stored
 func getEntryPath(id string) (string, error) {
-	homeDir, err := os.UserHomeDir()
+	entriesDir, err := getEntriesDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(entriesDir, fmt.Sprintf("%s.json", id)), nil
+}
+
+// getEntriesDir returns the entries directory, creating it if needed
+func getEntriesDir() (string, error) {
+	jotDir, err := config.DataDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", fmt.Errorf("failed to get data directory: %w", err)
 	}
 
-	entriesDir := filepath.Join(homeDir, ".jot", "entries")
-	if err := os.MkdirAll(entriesDir, 0700); err != nil {
+	entriesDir := filepath.Join(jotDir, "entries")
+	if err := storage.MkdirAll(entriesDir, 0700); err != nil {
 		return "", fmt.Errorf("failed to create entries directory: %w", err)
 	}
 
-	return filepath.Join(entriesDir, fmt.Sprintf("%s.json", id)), nil
+	return entriesDir, nil
 }
 
-// LoadJournalEntries loads all entries for a given journal
+// lockDataDir acquires the advisory lock guarding the data directory
+func lockDataDir() (*storage.Lock, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return storage.LockDir(jotDir)
+}
+
+// loadWorkers is the number of entry files read and decrypted at once
+const loadWorkers = 8
+
+// LoadJournalEntries loads all entries for a given journal, reading their
+// files and decrypting their metadata and previews on a few workers with
+// keys restored once. Archived entries are left out rather than thawed.
 func LoadJournalEntries(entryIDs []string) ([]*Entry, error) {
+	return loadEntries(entryIDs, true)
+}
+
+// ReadJournalEntries loads all entries for a given journal like
+// LoadJournalEntries, without decrypting anything
+func ReadJournalEntries(entryIDs []string) ([]*Entry, error) {
+	return loadEntries(entryIDs, false)
+}
+
+func loadEntries(entryIDs []string, prefetch bool) ([]*Entry, error) {
+	o := &opener{}
+	defer o.Close()
+
+	loaded := make([]*Entry, len(entryIDs))
+	errs := make([]error, len(entryIDs))
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < loadWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				path, err := getEntryPath(entryIDs[i])
+				if err != nil {
+					errs[i] = fmt.Errorf("failed to get entry path: %w", err)
+					continue
+				}
+				if loaded[i], errs[i] = LoadFile(path); errs[i] == nil && prefetch {
+					loaded[i].prefetch(o)
+				}
+			}
+		}()
+	}
+	for i := range entryIDs {
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+
+	// Missing files are looked for in the archives one at a time, since
+	// loading one may thaw its year
 	entries := make([]*Entry, 0, len(entryIDs))
-	for _, id := range entryIDs {
-		e, err := Load(id)
+	var archived map[string]int
+	for i, id := range entryIDs {
+		e, err := loaded[i], errs[i]
+		if err != nil && errors.Is(err, fs.ErrNotExist) {
+			if archived == nil {
+				if archived, err = ArchivedIDs(); err != nil {
+					return nil, err
+				}
+			}
+			if _, ok := archived[id]; ok {
+				continue
+			}
+			e, err = Load(id)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to load entry %s: %w", id, err)
 		}
@@ -166,3 +577,20 @@ func LoadJournalEntries(entryIDs []string) ([]*Entry, error) {
 	}
 	return entries, nil
 }
+
+// prefetch decrypts the metadata and preview of the entry with the keys of
+// o, so that list views find them ready. Errors are left for the entry's
+// getters to report.
+func (e *Entry) prefetch(o *opener) {
+	e.opener = o
+	defer func() { e.opener = nil }()
+	if len(e.Meta) > 0 {
+		if m, err := e.loadMetadata(); err == nil {
+			e.meta = &m
+		}
+	}
+	// Previews of shared entries are not cached, so there is nothing to keep
+	if e.shared == nil {
+		e.GetPreview()
+	}
+}
@@ -0,0 +1,47 @@
+package entry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// SetFields stores the mood, energy, weather and location of the entry,
+// encrypted like the body. Empty fields remove them.
+func (e *Entry) SetFields(f *types.Fields) error {
+	if e.Sealed() {
+		return ErrAppendOnly
+	}
+	if f == nil || *f == (types.Fields{}) {
+		e.Entry.Fields = nil
+		return nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode fields: %w", err)
+	}
+	fields, err := e.encrypt(string(data))
+	if err != nil {
+		return err
+	}
+	e.Entry.Fields = fields
+	return nil
+}
+
+// GetFields returns the mood, energy, weather and location of the entry, or
+// nil when none are set
+func (e *Entry) GetFields() (*types.Fields, error) {
+	if len(e.Entry.Fields) == 0 {
+		return nil, nil
+	}
+	data, err := e.decrypt(e.Entry.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt fields of entry %s: %w", e.ID, err)
+	}
+	var f types.Fields
+	if err := json.Unmarshal([]byte(data), &f); err != nil {
+		return nil, fmt.Errorf("failed to decode fields of entry %s: %w", e.ID, err)
+	}
+	return &f, nil
+}
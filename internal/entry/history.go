@@ -0,0 +1,96 @@
+package entry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// Limits on how much history is kept per entry. The oldest revisions are
+// dropped first once either limit is exceeded.
+const (
+	maxRevisions    = 20
+	maxHistoryBytes = 1 << 20 // 1 MiB of encrypted revision bodies, not counting blobs
+)
+
+// Update replaces the entry body with text, keeping the previous body as a
+// revision. The caller is responsible for saving the entry afterwards.
+func (e *Entry) Update(text string) error {
+	if e.Sealed() {
+		return ErrAppendOnly
+	}
+
+	encryptedBody, blob, codec, err := e.storeBody(text)
+	if err != nil {
+		return err
+	}
+	digest, err := BodyDigest(text)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	number := 1
+	if n := len(e.Revisions); n > 0 {
+		number = e.Revisions[n-1].Number + 1
+	}
+	e.Revisions = append(e.Revisions, types.Revision{
+		Number:   number,
+		Replaced: now,
+		Body:     e.Body,
+		Blob:     e.BodyBlob,
+		Codec:    e.Codec,
+	})
+	e.Body = encryptedBody
+	e.BodyBlob = blob
+	e.Codec = codec
+	e.Digest = digest
+	e.Updated = &now
+	e.pruneRevisions()
+
+	return nil
+}
+
+// Revision returns the revision with the given number
+func (e *Entry) Revision(number int) (*types.Revision, error) {
+	for i := range e.Revisions {
+		if e.Revisions[i].Number == number {
+			return &e.Revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("entry %s has no revision %d", e.ID, number)
+}
+
+// DecryptRevision returns the decrypted body of a revision
+func (e *Entry) DecryptRevision(r *types.Revision) (string, error) {
+	return loadBody(r.Body, r.Blob, r.Codec, e.decrypt)
+}
+
+// Revert restores the body of the given revision. The current body is kept
+// as a new revision, so a revert can itself be undone.
+func (e *Entry) Revert(number int) error {
+	r, err := e.Revision(number)
+	if err != nil {
+		return err
+	}
+
+	text, err := e.DecryptRevision(r)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt revision %d: %w", number, err)
+	}
+
+	return e.Update(text)
+}
+
+// pruneRevisions drops the oldest revisions until the history fits the limits
+func (e *Entry) pruneRevisions() {
+	size := 0
+	for _, r := range e.Revisions {
+		size += len(r.Body)
+	}
+	for len(e.Revisions) > 0 && (len(e.Revisions) > maxRevisions || size > maxHistoryBytes) {
+		size -= len(e.Revisions[0].Body)
+		e.Revisions = e.Revisions[1:]
+	}
+}
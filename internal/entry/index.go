@@ -0,0 +1,92 @@
+package entry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/veritome/jot/internal/collection"
+)
+
+// errIndexUnchanged aborts a collection update whose index is already current,
+// so that saving an entry only rewrites the collection when needed
+var errIndexUnchanged = errors.New("index unchanged")
+
+// updateIndex records the entry in the collection's tag and date index
+func (e *Entry) updateIndex() error {
+	tags, encrypted := e.indexTags()
+	_, err := collection.Update(func(c *collection.Collection) error {
+		if !c.IndexEntry(e.ID, e.Created, tags, encrypted) {
+			return errIndexUnchanged
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errIndexUnchanged) {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// unindex removes an entry from the collection's index
+func unindex(id string) error {
+	_, err := collection.Update(func(c *collection.Collection) error {
+		if !c.UnindexEntry(id) {
+			return errIndexUnchanged
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errIndexUnchanged) {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// indexTags returns the tags as stored (plain or hashed), and whether the
+// tags are only available encrypted
+func (e *Entry) indexTags() ([]string, bool) {
+	if len(e.Tags) == 0 && len(e.Meta) > 0 {
+		return nil, true
+	}
+	return e.Tags, false
+}
+
+// TagKeys returns the forms a tag may be stored in, for index lookups
+func TagKeys(tag string) ([]string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	hashed, err := hashTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return []string{tag, hashed}, nil
+}
+
+// RebuildIndex regenerates the index from every stored entry and returns the
+// number of entries indexed
+func RebuildIndex() (int, error) {
+	ids, err := ListIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	entries := make([]*Entry, 0, len(ids))
+	for _, id := range ids {
+		e, err := Load(id)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+
+	_, err = collection.Update(func(c *collection.Collection) error {
+		c.ResetIndex()
+		for _, e := range entries {
+			tags, encrypted := e.indexTags()
+			c.IndexEntry(e.ID, e.Created, tags, encrypted)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to save index: %w", err)
+	}
+	return len(entries), nil
+}
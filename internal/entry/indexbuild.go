@@ -0,0 +1,210 @@
+package entry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// indexBuildFile records the progress of the last index built in batches
+const indexBuildFile = "index-build.json"
+
+// indexBuildTimeout is how long a build may go without saving its progress,
+// besides its pauses, before it is taken to have died
+const indexBuildTimeout = time.Minute
+
+// IndexBuild is the progress of an index built in batches, saved after
+// every batch so that other jot processes can follow it
+type IndexBuild struct {
+	PID      int           `json:"pid"`
+	Started  time.Time     `json:"started"`
+	Updated  time.Time     `json:"updated"`         // Last time progress was saved
+	Finished time.Time     `json:"finished"`        // Zero while the build runs
+	Done     int           `json:"done"`            // Entries indexed so far
+	Total    int           `json:"total"`           // Entries found when the build started
+	Pause    time.Duration `json:"pause"`           // Pause after each batch
+	Error    string        `json:"error,omitempty"` // Why the build stopped before finishing
+}
+
+// Running reports whether the build is still under way
+func (b *IndexBuild) Running() bool {
+	return b.Finished.IsZero() && time.Since(b.Updated) < indexBuildTimeout+b.Pause
+}
+
+// ReadIndexBuild returns the progress of the last index built in batches,
+// or nil if there has been none
+func ReadIndexBuild() (*IndexBuild, error) {
+	path, err := indexBuildPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index build progress: %w", err)
+	}
+	var b IndexBuild
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to read index build progress: %w", err)
+	}
+	return &b, nil
+}
+
+func (b *IndexBuild) save() error {
+	path, err := indexBuildPath()
+	if err != nil {
+		return err
+	}
+	b.Updated = time.Now()
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index build progress: %w", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save index build progress: %w", err)
+	}
+	return nil
+}
+
+func indexBuildPath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, indexBuildFile), nil
+}
+
+// BuildIndex regenerates the index like RebuildIndex, but in batches of
+// batch entries with a pause after each, so that a large archive can be
+// indexed in the background without hogging the disk or the data lock.
+// Progress is saved after every batch for ReadIndexBuild and passed to
+// progress, if not nil. The index in use is only replaced once every entry
+// is indexed, catching up with entries saved or deleted in the meantime.
+// When ctx is done the build stops after the current batch, returning
+// ctx's error.
+func BuildIndex(ctx context.Context, batch int, pause time.Duration, progress func(*IndexBuild)) (int, error) {
+	if batch < 1 {
+		batch = 1
+	}
+	ids, err := ListIDs()
+	if err != nil {
+		return 0, err
+	}
+	build := &IndexBuild{PID: os.Getpid(), Started: time.Now(), Total: len(ids), Pause: pause}
+	if err := build.save(); err != nil {
+		return 0, err
+	}
+	fail := func(err error) (int, error) {
+		build.Finished = time.Now()
+		build.Error = err.Error()
+		_ = build.save()
+		return 0, err
+	}
+
+	scratch, _ := collection.NewCollection()
+	scratch.ResetIndex()
+	seen := make(map[string]time.Time, len(ids))
+	for start := 0; start < len(ids); start += batch {
+		end := start + batch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		for _, id := range ids[start:end] {
+			modified, err := indexEntryFile(scratch, id)
+			if errors.Is(err, fs.ErrNotExist) {
+				// Deleted since the build started
+				continue
+			}
+			if err != nil {
+				return fail(err)
+			}
+			seen[id] = modified
+		}
+
+		build.Done = end
+		if err := build.save(); err != nil {
+			return fail(err)
+		}
+		if progress != nil {
+			progress(build)
+		}
+		if end == len(ids) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fail(fmt.Errorf("stopped before finishing: %w", ctx.Err()))
+		case <-time.After(pause):
+		}
+	}
+
+	n := 0
+	_, err = collection.Update(func(c *collection.Collection) error {
+		current, err := ListIDs()
+		if err != nil {
+			return err
+		}
+		listed := make(map[string]bool, len(current))
+		for _, id := range current {
+			listed[id] = true
+			path, err := getEntryPath(id)
+			if err != nil {
+				return err
+			}
+			if info, err := storage.Stat(path); err == nil && info.ModTime().Equal(seen[id]) {
+				continue
+			}
+			if _, err := indexEntryFile(scratch, id); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		for id := range seen {
+			if !listed[id] {
+				scratch.UnindexEntry(id)
+			}
+		}
+		c.SetIndex(scratch.Index)
+		n = len(current)
+		return nil
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to save index: %w", err))
+	}
+
+	build.Finished = time.Now()
+	if err := build.save(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// indexEntryFile records the stored entry id in c's index and returns when
+// its file was last modified
+func indexEntryFile(c *collection.Collection, id string) (time.Time, error) {
+	path, err := getEntryPath(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	info, err := storage.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	e, err := LoadFile(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load entry %s: %w", id, err)
+	}
+	tags, encrypted := e.indexTags()
+	c.IndexEntry(e.ID, e.Created, tags, encrypted)
+	return info.ModTime(), nil
+}
@@ -0,0 +1,278 @@
+package entry
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// Metadata modes trade privacy for filtering speed:
+//
+//   - plain: tags and title are stored as written and filtered without
+//     decryption
+//   - hashed: tags are stored as salted hashes for filtering, and encrypted
+//     in Meta together with the title for display
+//   - encrypted: tags and title only exist encrypted in Meta, so filtering
+//     decrypts every entry
+const (
+	MetadataPlain     = "plain"
+	MetadataHashed    = "hashed"
+	MetadataEncrypted = "encrypted"
+)
+
+// tagSaltFile holds the random salt of hashed tags
+const tagSaltFile = "tags.salt"
+
+// metadata is the plaintext stored encrypted in Entry.Meta
+type metadata struct {
+	Title string   `json:"title,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// MetadataMode returns the configured metadata.mode
+func MetadataMode() string {
+	if cfg, err := config.Current(); err == nil {
+		return cfg.String("metadata.mode")
+	}
+	return MetadataEncrypted
+}
+
+// NormalizeTags lowercases tags, strips a leading '#' and drops empty and
+// duplicate tags
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "#"))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetTags replaces the entry's tags, storing them in the configured mode
+func (e *Entry) SetTags(tags []string) error {
+	m, err := e.loadMetadata()
+	if err != nil {
+		return err
+	}
+	m.Tags = NormalizeTags(tags)
+	return e.storeMetadata(m, e.storedMode())
+}
+
+// SetTitle replaces the entry's title, storing it in the configured mode
+func (e *Entry) SetTitle(title string) error {
+	m, err := e.loadMetadata()
+	if err != nil {
+		return err
+	}
+	m.Title = strings.TrimSpace(title)
+	return e.storeMetadata(m, e.storedMode())
+}
+
+// GetTitle returns the entry's title, decrypting it if needed
+func (e *Entry) GetTitle() (string, error) {
+	m, err := e.loadMetadata()
+	if err != nil {
+		return "", err
+	}
+	return m.Title, nil
+}
+
+// GetTags returns the entry's tags, decrypting them if needed
+func (e *Entry) GetTags() ([]string, error) {
+	m, err := e.loadMetadata()
+	if err != nil {
+		return nil, err
+	}
+	return m.Tags, nil
+}
+
+// HasTag reports whether the entry carries tag. Plain and hashed tags are
+// compared without decrypting anything.
+func (e *Entry) HasTag(tag string) (bool, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if len(e.Tags) == 0 && len(e.Meta) == 0 {
+		return false, nil
+	}
+
+	tags := e.Tags
+	switch e.metadataMode() {
+	case MetadataHashed:
+		hashed, err := hashTag(tag)
+		if err != nil {
+			return false, err
+		}
+		tag = hashed
+	case MetadataEncrypted:
+		var err error
+		if tags, err = e.GetTags(); err != nil {
+			return false, err
+		}
+	}
+
+	for _, t := range tags {
+		if t == tag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MigrateMetadata rewrites the entry's metadata in mode, reporting whether
+// anything changed. The caller saves the entry afterwards.
+func (e *Entry) MigrateMetadata(mode string) (bool, error) {
+	current := e.metadataMode()
+	if current == mode {
+		return false, nil
+	}
+	m, err := e.loadMetadata()
+	if err != nil {
+		return false, err
+	}
+	// Without tags there is nothing to hash, so both modes look the same
+	if len(m.Tags) == 0 && current != MetadataPlain && mode != MetadataPlain {
+		return false, nil
+	}
+	if err := e.storeMetadata(m, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// storedMode returns the mode new metadata of the entry is stored in: the
+// configured one, or always encrypted for shared entries
+func (e *Entry) storedMode() string {
+	if e.shared != nil {
+		return MetadataEncrypted
+	}
+	return MetadataMode()
+}
+
+// metadataMode works out which mode the entry's metadata was stored in.
+// Entries without metadata count as being in every mode.
+func (e *Entry) metadataMode() string {
+	switch {
+	case len(e.Meta) == 0 && len(e.Tags) == 0 && e.Title == "":
+		return e.storedMode()
+	case len(e.Meta) == 0:
+		return MetadataPlain
+	case len(e.Tags) > 0:
+		return MetadataHashed
+	}
+	return MetadataEncrypted
+}
+
+func (e *Entry) loadMetadata() (metadata, error) {
+	var m metadata
+	if e.meta != nil {
+		return *e.meta, nil
+	}
+	if len(e.Meta) == 0 {
+		m.Title, m.Tags = e.Title, e.Tags
+		return m, nil
+	}
+	text, err := e.decrypt(e.Meta)
+	if err != nil {
+		return m, fmt.Errorf("failed to decrypt metadata of entry %s: %w", e.ID, err)
+	}
+	if err := json.Unmarshal([]byte(text), &m); err != nil {
+		return m, fmt.Errorf("failed to parse metadata of entry %s: %w", e.ID, err)
+	}
+	return m, nil
+}
+
+func (e *Entry) storeMetadata(m metadata, mode string) error {
+	e.Title, e.Tags, e.Meta, e.meta = "", nil, nil, nil
+	if len(m.Tags) == 0 && m.Title == "" {
+		return nil
+	}
+
+	if mode == MetadataPlain {
+		e.Title, e.Tags = m.Title, m.Tags
+		return nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if e.Meta, err = e.encrypt(string(data)); err != nil {
+		return err
+	}
+
+	if mode == MetadataHashed {
+		for _, t := range m.Tags {
+			hashed, err := hashTag(t)
+			if err != nil {
+				return err
+			}
+			e.Tags = append(e.Tags, hashed)
+		}
+		sort.Strings(e.Tags)
+	}
+	e.meta = &m
+	return nil
+}
+
+// hashTag returns the salted hash stored for tag in hashed mode
+func hashTag(tag string) (string, error) {
+	salt, err := readSalt(tagSaltFile)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(tag))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// readSalt reads the random salt stored in the named file of the data
+// directory, creating it on first use
+func readSalt(name string) ([]byte, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	path := filepath.Join(jotDir, name)
+
+	salt, err := storage.ReadFile(path)
+	if err == nil {
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	lock, err := lockDataDir()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	// Another process may have created it while we waited for the lock
+	if salt, err := storage.ReadFile(path); err == nil {
+		return salt, nil
+	}
+	salt = make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate %s: %w", name, err)
+	}
+	if err := storage.WriteFile(path, salt, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save %s: %w", name, err)
+	}
+	return salt, nil
+}
@@ -0,0 +1,169 @@
+package entry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// previewFile holds the encrypted preview cache
+const previewFile = "previews.cache"
+
+// previewLength is the most runes of the first line kept in a preview
+const previewLength = 200
+
+// Preview is what list and statistics views show of an entry: the first
+// line of its body and how many words it has
+type Preview struct {
+	Line  string `json:"line"`
+	Words int    `json:"words"`
+}
+
+// cachedPreview is a preview along with the hash of the ciphertext it was
+// computed from
+type cachedPreview struct {
+	Hash string `json:"hash"`
+	Preview
+}
+
+// previews caches the previews of stored entries by entry ID. It is loaded
+// on first use and only written back by SavePreviews, once per command.
+var previews struct {
+	sync.Mutex
+	loaded  bool
+	changed bool
+	byID    map[string]cachedPreview
+}
+
+// GetPreview returns the entry's preview, decrypting the body only when the
+// preview cache has none for its current ciphertext. Saving the entry
+// changes the ciphertext, so an edited entry is never shown stale.
+func (e *Entry) GetPreview() (Preview, error) {
+	// Shared entries live outside the data directory and are not cached
+	if e.shared != nil {
+		return e.computePreview()
+	}
+
+	hash := e.bodyHash()
+	previews.Lock()
+	if !previews.loaded {
+		previews.byID = loadPreviews()
+		previews.loaded = true
+	}
+	cached, ok := previews.byID[e.ID]
+	previews.Unlock()
+	if ok && cached.Hash == hash {
+		return cached.Preview, nil
+	}
+
+	p, err := e.computePreview()
+	if err != nil {
+		return Preview{}, err
+	}
+	previews.Lock()
+	previews.byID[e.ID] = cachedPreview{Hash: hash, Preview: p}
+	previews.changed = true
+	previews.Unlock()
+	return p, nil
+}
+
+func (e *Entry) computePreview() (Preview, error) {
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		return Preview{}, err
+	}
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(body), "\n", 2)[0])
+	if runes := []rune(line); len(runes) > previewLength {
+		line = string(runes[:previewLength])
+	}
+	return Preview{Line: line, Words: len(strings.Fields(body))}, nil
+}
+
+// bodyHash identifies the stored body: the hash of its ciphertext, or of
+// the blob ref, which names the content of a body kept in the blob store
+func (e *Entry) bodyHash() string {
+	h := sha256.New()
+	h.Write(e.Body)
+	h.Write([]byte(e.BodyBlob))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadPreviews reads the preview cache, starting afresh when it is missing
+// or cannot be decrypted, since every preview can be computed again
+func loadPreviews() map[string]cachedPreview {
+	byID := make(map[string]cachedPreview)
+	path, err := previewPath()
+	if err != nil {
+		return byID
+	}
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		return byID
+	}
+	text, err := decrypt(data)
+	if err != nil {
+		return byID
+	}
+	if err := json.Unmarshal([]byte(text), &byID); err != nil {
+		return make(map[string]cachedPreview)
+	}
+	return byID
+}
+
+// SavePreviews writes the preview cache back, encrypted like an entry, if
+// previews were computed since it was loaded. Previews of entries that no
+// longer exist are dropped.
+func SavePreviews() error {
+	previews.Lock()
+	defer previews.Unlock()
+	if !previews.changed {
+		return nil
+	}
+
+	ids, err := ListIDs()
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		stored[id] = true
+	}
+	for id := range previews.byID {
+		if !stored[id] {
+			delete(previews.byID, id)
+		}
+	}
+
+	data, err := json.Marshal(previews.byID)
+	if err != nil {
+		return fmt.Errorf("failed to marshal preview cache: %w", err)
+	}
+	sealed, err := encrypt(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt preview cache: %w", err)
+	}
+	path, err := previewPath()
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFile(path, sealed, 0600); err != nil {
+		return fmt.Errorf("failed to save preview cache: %w", err)
+	}
+	previews.changed = false
+	return nil
+}
+
+func previewPath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, previewFile), nil
+}
@@ -0,0 +1,208 @@
+package entry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/veritome/jot/internal/crypto"
+)
+
+// Reencrypt re-encrypts the body, metadata, check-in answers,
+// countersignatures, timestamps, every revision and the blobs they
+// reference for the key pair to, the configured recipients and the other
+// approved devices, opening them with any of keys. It reports whether
+// anything changed, so an entry already under the new key is left
+// untouched. The caller is responsible for saving the entry afterwards.
+func (e *Entry) Reencrypt(keys []*crypto.KeyPair, to *crypto.KeyPair) (bool, error) {
+	if e.Sealed() {
+		return false, ErrAppendOnly
+	}
+
+	recipients, err := recipientKeys()
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	reencrypt := func(data []byte) ([]byte, error) {
+		// GPG entries are protected by the user's GPG key, not the NaCl key
+		if crypto.IsGPG(data) {
+			return data, nil
+		}
+		if _, err := crypto.DecryptNacl(data, to); err == nil {
+			return data, nil
+		}
+		text, err := crypto.DecryptWithKeyring(data, keys)
+		if err != nil {
+			return nil, err
+		}
+		changed = true
+		return crypto.EncryptFor(text, to, recipients)
+	}
+	if err := e.rewrite(reencrypt); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// Revoke re-encrypts the parts of the entry sealed to the public key
+// revoked, and the blobs they reference, under fresh keys for every other
+// key they were sealed to, opening them with any of keys. Parts not sealed
+// to it are not decrypted. It reports whether anything changed; the caller
+// is responsible for saving the entry afterwards. ErrAppendOnly is only
+// returned for sealed entries the key can open.
+func (e *Entry) Revoke(keys []*crypto.KeyPair, revoked *[32]byte) (bool, error) {
+	if e.Sealed() {
+		opens := false
+		err := e.rewrite(func(data []byte) ([]byte, error) {
+			opens = opens || crypto.SealedTo(data, revoked)
+			return data, nil
+		})
+		if err != nil || !opens {
+			return false, err
+		}
+		return false, ErrAppendOnly
+	}
+
+	changed := false
+	err := e.rewrite(func(data []byte) ([]byte, error) {
+		resealed, ok, err := crypto.Reseal(data, keys, revoked)
+		changed = changed || ok
+		return resealed, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// Rewrite loads the entry id, lets change re-encrypt it and saves it if
+// change reports that anything changed, holding the data lock throughout so
+// that an edit saved meanwhile by another jot process is neither lost nor
+// overwritten with older content.
+func Rewrite(id string, change func(e *Entry) (bool, error)) (bool, error) {
+	// Loading it once first thaws the entry if it was archived
+	if _, err := Load(id); err != nil {
+		return false, err
+	}
+	entryPath, err := getEntryPath(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to get entry path: %w", err)
+	}
+
+	lock, err := lockDataDir()
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	e, err := LoadFile(entryPath)
+	if err != nil {
+		return false, err
+	}
+	changed, err := change(e)
+	if err != nil || !changed {
+		return false, err
+	}
+	data, err := json.MarshalIndent(e.Entry, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal entry: %w", err)
+	}
+	if err := e.writeLocked(entryPath, data); err != nil {
+		return false, fmt.Errorf("failed to save entry %s: %w", id, err)
+	}
+	return true, nil
+}
+
+// rewrite replaces the body, metadata, check-in answers, bookmark, fields,
+// rollup, countersignatures, timestamps and every revision of the entry
+// with what reencrypt makes of them
+func (e *Entry) rewrite(reencrypt func([]byte) ([]byte, error)) error {
+	// Blobs are re-encrypted in place; a blob shared with an entry
+	// handled earlier is already done and left alone
+	reencryptBody := func(body []byte, ref string) ([]byte, error) {
+		if ref == "" {
+			return reencrypt(body)
+		}
+		data, err := readBlob(ref)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := reencrypt(data)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(updated, data) {
+			if err := writeBlob(ref, updated); err != nil {
+				return nil, err
+			}
+		}
+		return body, nil
+	}
+
+	body, err := reencryptBody(e.Body, e.BodyBlob)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt entry %s: %w", e.ID, err)
+	}
+	var meta []byte
+	if len(e.Meta) > 0 {
+		if meta, err = reencrypt(e.Meta); err != nil {
+			return fmt.Errorf("failed to re-encrypt metadata of entry %s: %w", e.ID, err)
+		}
+	}
+	var answers []byte
+	if len(e.Answers) > 0 {
+		if answers, err = reencrypt(e.Answers); err != nil {
+			return fmt.Errorf("failed to re-encrypt check-in of entry %s: %w", e.ID, err)
+		}
+	}
+	var link []byte
+	if len(e.Link) > 0 {
+		if link, err = reencrypt(e.Link); err != nil {
+			return fmt.Errorf("failed to re-encrypt bookmark of entry %s: %w", e.ID, err)
+		}
+	}
+	var fields []byte
+	if len(e.Entry.Fields) > 0 {
+		if fields, err = reencrypt(e.Entry.Fields); err != nil {
+			return fmt.Errorf("failed to re-encrypt fields of entry %s: %w", e.ID, err)
+		}
+	}
+	var rollup []byte
+	if len(e.Entry.Rollup) > 0 {
+		if rollup, err = reencrypt(e.Entry.Rollup); err != nil {
+			return fmt.Errorf("failed to re-encrypt rollup of entry %s: %w", e.ID, err)
+		}
+	}
+	var countersigns []byte
+	if len(e.Countersigns) > 0 {
+		if countersigns, err = reencrypt(e.Countersigns); err != nil {
+			return fmt.Errorf("failed to re-encrypt countersignatures of entry %s: %w", e.ID, err)
+		}
+	}
+	var timestamps []byte
+	if len(e.Entry.Timestamps) > 0 {
+		if timestamps, err = reencrypt(e.Entry.Timestamps); err != nil {
+			return fmt.Errorf("failed to re-encrypt timestamps of entry %s: %w", e.ID, err)
+		}
+	}
+	for i := range e.Revisions {
+		r := &e.Revisions[i]
+		data, err := reencryptBody(r.Body, r.Blob)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt revision %d of entry %s: %w", r.Number, e.ID, err)
+		}
+		r.Body = data
+	}
+	e.Body = body
+	e.Meta = meta
+	e.Answers = answers
+	e.Link = link
+	e.Entry.Fields = fields
+	e.Entry.Rollup = rollup
+	e.Countersigns = countersigns
+	e.Entry.Timestamps = timestamps
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package entry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// Rename gives the entry the ID newID: its file is moved while holding the
+// data lock, so that newID cannot be handed out meanwhile, and the journals
+// listing the entry and the index follow. Entries referring to the old ID
+// are left to the caller, see RemapReferences.
+func (e *Entry) Rename(newID string) error {
+	if e.Sealed() {
+		return ErrAppendOnly
+	}
+	if e.shared != nil {
+		return fmt.Errorf("entry %s is in a shared journal and cannot be renamed", e.ID)
+	}
+
+	oldID := e.ID
+	oldPath, err := getEntryPath(oldID)
+	if err != nil {
+		return fmt.Errorf("failed to get entry path: %w", err)
+	}
+	newPath, err := getEntryPath(newID)
+	if err != nil {
+		return fmt.Errorf("failed to get entry path: %w", err)
+	}
+
+	if err := e.move(oldPath, newPath, newID); err != nil {
+		return err
+	}
+
+	// An interruption here leaves an entry no journal lists, which
+	// jot doctor --fix gives back to its journal
+	_, err = collection.Update(func(c *collection.Collection) error {
+		c.RenameEntry(oldID, newID)
+		c.UnindexEntry(oldID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename entry %s in its journal: %w", oldID, err)
+	}
+	return e.updateIndex()
+}
+
+// move writes the entry as newID and removes the file of its old ID
+func (e *Entry) move(oldPath, newPath, newID string) error {
+	lock, err := lockDataDir()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := storage.CreateExclusive(newPath, 0600); err != nil {
+		return fmt.Errorf("failed to reserve entry ID %s: %w", newID, err)
+	}
+	oldID := e.ID
+	e.ID = newID
+	data, err := json.MarshalIndent(e.Entry, "", "  ")
+	if err == nil {
+		err = e.writeLocked(newPath, data)
+	}
+	if err != nil {
+		e.ID = oldID
+		storage.Remove(newPath)
+		return fmt.Errorf("failed to write entry %s: %w", newID, err)
+	}
+	if err := storage.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove entry file: %w", err)
+	}
+	return nil
+}
+
+// RemapReferences rewrites the entry's references to entries whose IDs
+// changed, from old to new ID in ids: the entry it supersedes and, for a
+// rollup, the entries it links to and lists in its body. Rewriting a
+// rollup's body keeps the previous body as a revision. It reports whether
+// anything changed; the caller saves the entry.
+func (e *Entry) RemapReferences(ids map[string]string) (bool, error) {
+	if e.Sealed() {
+		return false, ErrAppendOnly
+	}
+
+	changed := false
+	if to, ok := ids[e.Supersedes]; ok {
+		e.Supersedes = to
+		changed = true
+	}
+	if !e.IsRollup() {
+		return changed, nil
+	}
+
+	r, err := e.GetRollup()
+	if err != nil {
+		return false, err
+	}
+	linked := false
+	for i, id := range r.Entries {
+		if to, ok := ids[id]; ok {
+			r.Entries[i] = to
+			linked = true
+		}
+	}
+	if !linked {
+		return changed, nil
+	}
+	if err := e.SetRollup(r); err != nil {
+		return false, err
+	}
+
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		return false, err
+	}
+	if remapped := remapList(body, ids); remapped != body {
+		if err := e.Update(remapped); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// remapList rewrites the IDs at the start of the "- <id> <date>" lines a
+// rollup lists its entries with
+func remapList(body string, ids map[string]string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		rest := strings.TrimPrefix(line, "- ")
+		if rest == line {
+			continue
+		}
+		id, tail, ok := strings.Cut(rest, " ")
+		if !ok {
+			continue
+		}
+		if to, ok := ids[id]; ok {
+			lines[i] = "- " + to + " " + tail
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,48 @@
+package entry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// SetRollup marks the entry as a review of a week or month, storing the
+// period and the entries it links to encrypted like the body
+func (e *Entry) SetRollup(r *types.Rollup) error {
+	if e.Sealed() {
+		return ErrAppendOnly
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode rollup: %w", err)
+	}
+	rollup, err := e.encrypt(string(data))
+	if err != nil {
+		return err
+	}
+	e.Entry.Rollup = rollup
+	return nil
+}
+
+// GetRollup returns the period the entry reviews, or nil when it is not a
+// rollup
+func (e *Entry) GetRollup() (*types.Rollup, error) {
+	if len(e.Entry.Rollup) == 0 {
+		return nil, nil
+	}
+	data, err := e.decrypt(e.Entry.Rollup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt rollup of entry %s: %w", e.ID, err)
+	}
+	var r types.Rollup
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return nil, fmt.Errorf("failed to decode rollup of entry %s: %w", e.ID, err)
+	}
+	return &r, nil
+}
+
+// IsRollup reports whether the entry reviews a week or month
+func (e *Entry) IsRollup() bool {
+	return len(e.Entry.Rollup) > 0
+}
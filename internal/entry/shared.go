@@ -0,0 +1,313 @@
+package entry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/idgen"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Entries of a shared journal live in the entries directory of a folder
+// kept in sync between its members by any file-sync tool. Each entry is a
+// file of its own named by a ULID, so members writing at the same time
+// never touch the same file and no lock is needed.
+
+// Shared is the folder of a shared journal and the public keys of its
+// members, which every entry written to it is encrypted to
+type Shared struct {
+	Dir     string
+	Members []*[32]byte
+
+	// Signers maps the names of members to the keys their entries are
+	// signed with. An entry whose author's signature does not verify is
+	// loaded with Unverified after its author.
+	Signers map[string]string
+
+	// Self and Signer are the user's member name and key pair, set to
+	// write: the entries the user wrote are signed when saved
+	Self   string
+	Signer *crypto.KeyPair
+}
+
+// Unverified follows the author of a shared entry whose signature does not
+// verify, as when another member edited it, it predates signatures or its
+// author was forged
+const Unverified = " (unverified)"
+
+// NewShared creates an entry of a shared journal written by author. It is
+// encrypted to every member, never stored as a blob and its metadata is
+// always encrypted, since other members have neither the blobs nor the tag
+// salt of this data directory.
+func NewShared(s *Shared, journalID, author, text string) (*Entry, error) {
+	gen, err := idgen.New("ulid")
+	if err != nil {
+		return nil, err
+	}
+	id, err := gen.Next(nil, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate entry ID: %w", err)
+	}
+
+	e := &Entry{
+		Entry: &types.Entry{
+			ID:        id,
+			Created:   time.Now(),
+			JournalID: journalID,
+			Author:    author,
+		},
+		shared: s,
+	}
+	if e.Body, _, e.Codec, err = e.storeBody(text); err != nil {
+		return nil, err
+	}
+	if e.Digest, err = BodyDigest(text); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// LoadShared loads every entry in the folder of a shared journal,
+// decrypting their metadata with keys restored once. Files that are not entries, such as the
+// conflicting copies some sync tools create, are skipped.
+func LoadShared(s *Shared) ([]*Entry, error) {
+	files, err := storage.ReadDir(filepath.Join(s.Dir, "entries"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared entries: %w", err)
+	}
+
+	o := &opener{}
+	defer o.Close()
+	entries := make([]*Entry, 0, len(files))
+	for _, file := range files {
+		id := strings.TrimSuffix(file.Name(), ".json")
+		if file.IsDir() || id == file.Name() {
+			continue
+		}
+		if info, err := file.Info(); err != nil || info.Size() == 0 {
+			continue
+		}
+		e, err := LoadSharedEntry(s, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load entry %s: %w", id, err)
+		}
+		if e.ID != id {
+			continue
+		}
+		e.prefetch(o)
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].ID < entries[b].ID })
+	return entries, nil
+}
+
+// LoadSharedEntry loads an entry from the folder of a shared journal by its ID
+func LoadSharedEntry(s *Shared, id string) (*Entry, error) {
+	if id == "" || filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid entry ID %q", id)
+	}
+	e, err := LoadFile(filepath.Join(s.Dir, "entries", id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	e.shared = s
+	if e.Author != "" && !e.verifyAuthor() {
+		e.claimed = e.Author
+		e.Author += Unverified
+	}
+	return e, nil
+}
+
+// statement returns what the author of a shared entry signs: where it
+// belongs, when and by whom it was written and a hash of its body
+func (e *Entry) statement(author string) ([]byte, error) {
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(body))
+	return []byte(fmt.Sprintf("jot shared entry\njournal: %s\nid: %s\ncreated: %s\nauthor: %s\nbody: %s\n",
+		e.JournalID, e.ID, e.Created.UTC().Format(time.RFC3339Nano), author, hex.EncodeToString(sum[:]))), nil
+}
+
+// verifyAuthor reports whether the entry is signed by the key of the
+// member it names as its author
+func (e *Entry) verifyAuthor() bool {
+	key, ok := e.shared.Signers[e.Author]
+	if !ok || e.Signature == "" {
+		return false
+	}
+	statement, err := e.statement(e.Author)
+	if err != nil {
+		return false
+	}
+	return crypto.VerifySignature(key, statement, e.Signature)
+}
+
+// sign signs a shared entry the user wrote. Entries that claimed the user
+// as author without a valid signature are left unsigned.
+func (e *Entry) sign() error {
+	if e.shared.Signer == nil || e.claimed != "" || e.Author != e.shared.Self {
+		return nil
+	}
+	statement, err := e.statement(e.Author)
+	if err != nil {
+		return fmt.Errorf("failed to sign entry: %w", err)
+	}
+	if e.Signature, err = e.shared.Signer.Sign(statement); err != nil {
+		return fmt.Errorf("failed to sign entry: %w", err)
+	}
+	return nil
+}
+
+// Reshare re-encrypts the body, metadata, check-in answers, fields,
+// countersignatures, timestamps and revisions of a shared entry to the
+// current members of its journal, opening them with any of keys. The
+// caller is responsible for saving the entry afterwards.
+func (e *Entry) Reshare(keys []*crypto.KeyPair) error {
+	if e.shared == nil {
+		return fmt.Errorf("entry %s is not shared", e.ID)
+	}
+	reseal := func(data []byte) ([]byte, error) {
+		if len(data) == 0 {
+			return data, nil
+		}
+		text, err := crypto.DecryptWithKeyring(data, keys)
+		if err != nil {
+			return nil, err
+		}
+		return e.encrypt(text)
+	}
+
+	body, err := reseal(e.Body)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt entry %s: %w", e.ID, err)
+	}
+	meta, err := reseal(e.Meta)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt metadata of entry %s: %w", e.ID, err)
+	}
+	answers, err := reseal(e.Answers)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt check-in of entry %s: %w", e.ID, err)
+	}
+	link, err := reseal(e.Link)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt bookmark of entry %s: %w", e.ID, err)
+	}
+	fields, err := reseal(e.Entry.Fields)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt fields of entry %s: %w", e.ID, err)
+	}
+	rollup, err := reseal(e.Entry.Rollup)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt rollup of entry %s: %w", e.ID, err)
+	}
+	countersigns, err := reseal(e.Countersigns)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt countersignatures of entry %s: %w", e.ID, err)
+	}
+	timestamps, err := reseal(e.Entry.Timestamps)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt timestamps of entry %s: %w", e.ID, err)
+	}
+	revisions := make([][]byte, len(e.Revisions))
+	for i, r := range e.Revisions {
+		if revisions[i], err = reseal(r.Body); err != nil {
+			return fmt.Errorf("failed to re-encrypt revision %d of entry %s: %w", r.Number, e.ID, err)
+		}
+	}
+
+	e.Body, e.Meta, e.Answers, e.Link, e.Entry.Fields, e.Entry.Rollup, e.Countersigns, e.Entry.Timestamps, e.meta = body, meta, answers, link, fields, rollup, countersigns, timestamps, nil
+	for i := range e.Revisions {
+		e.Revisions[i].Body = revisions[i]
+	}
+	return nil
+}
+
+// encrypt seals text for the entry: to every member of its shared journal,
+// or with the configured backend otherwise
+func (e *Entry) encrypt(text string) ([]byte, error) {
+	if e.shared == nil && e.sealer != nil {
+		return e.sealer.seal(text)
+	}
+	if e.shared == nil {
+		return encrypt(text)
+	}
+
+	keyPair, err := crypto.RestorePublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore NaCl keys: %w", err)
+	}
+	defer keyPair.Clear()
+
+	// Our own key is always added by EncryptFor
+	others := make([]*[32]byte, 0, len(e.shared.Members))
+	for _, key := range e.shared.Members {
+		if *key != *keyPair.PublicKey {
+			others = append(others, key)
+		}
+	}
+
+	encrypted, err := crypto.EncryptFor(text, keyPair, others)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt entry with NaCl: %w", err)
+	}
+	return encrypted, nil
+}
+
+// storeBody compresses and encrypts text for the entry's body or a
+// revision. Shared entries never use the blob store.
+func (e *Entry) storeBody(text string) ([]byte, string, string, error) {
+	if e.shared == nil {
+		return storeBody(text, e.encrypt)
+	}
+	compressed, codec, err := compress(text)
+	if err != nil {
+		return nil, "", "", err
+	}
+	body, err := e.encrypt(compressed)
+	return body, "", codec, err
+}
+
+// writeShared signs a shared entry and stores it in the journal's folder,
+// under the author it was loaded with
+func (e *Entry) writeShared() error {
+	if err := e.sign(); err != nil {
+		return err
+	}
+	stored := *e.Entry
+	if e.claimed != "" {
+		stored.Author = e.claimed
+	}
+	data, err := json.MarshalIndent(&stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal entry: %w", err)
+	}
+	dir := filepath.Join(e.shared.Dir, "entries")
+	if err := storage.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create shared entries directory: %w", err)
+	}
+	if err := storage.WriteFile(filepath.Join(dir, e.ID+".json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write entry file: %w", err)
+	}
+	return nil
+}
+
+// removeShared deletes a shared entry from the journal's folder
+func (e *Entry) removeShared() error {
+	path := filepath.Join(e.shared.Dir, "entries", e.ID+".json")
+	if err := storage.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete entry file: %w", err)
+	}
+	return nil
+}
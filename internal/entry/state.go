@@ -0,0 +1,39 @@
+package entry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Entry states let a journal serve as a queue: entries arrive unread and
+// are marked read or archived once processed. The state is not part of the
+// chain, so entries of append-only journals can be processed too.
+const (
+	StateUnread   = "unread"
+	StateRead     = "read"
+	StateArchived = "archived"
+)
+
+// States lists the valid entry states
+var States = []string{StateUnread, StateRead, StateArchived}
+
+// GetState returns the entry's state
+func (e *Entry) GetState() string {
+	if e.State == "" {
+		return StateUnread
+	}
+	return e.State
+}
+
+// SetState changes the entry's state. Call Save to persist it.
+func (e *Entry) SetState(state string) error {
+	switch state {
+	case StateUnread:
+		e.State = ""
+	case StateRead, StateArchived:
+		e.State = state
+	default:
+		return fmt.Errorf("unknown state '%s': must be one of %s", state, strings.Join(States, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package entry
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tasks are entries with a completion state: open until done, then stamped
+// with when they were completed. Like the queue state, it is not part of
+// the chain, so tasks of append-only journals can be completed too.
+
+// OpenTask reports whether the entry is a task not done yet
+func (e *Entry) OpenTask() bool {
+	return e.Task && e.Done == nil
+}
+
+// Complete marks the task done at the given time. Call Save to persist it.
+func (e *Entry) Complete(at time.Time) error {
+	if !e.Task {
+		return fmt.Errorf("entry %s is not a task", e.ID)
+	}
+	e.Done = &at
+	return nil
+}
+
+// Reopen marks a completed task open again. Call Save to persist it.
+func (e *Entry) Reopen() error {
+	if !e.Task {
+		return fmt.Errorf("entry %s is not a task", e.ID)
+	}
+	e.Done = nil
+	return nil
+}
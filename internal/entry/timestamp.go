@@ -0,0 +1,100 @@
+package entry
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/veritome/jot/internal/tsa"
+	"github.com/veritome/jot/internal/types"
+)
+
+// A timestamping authority is sent only the hash of a statement mixing the
+// body's hash with a random salt, so neither it nor anyone reading its
+// logs can confirm a guess of what the entry says. The salt is kept
+// encrypted with the entry and revealed only to whoever the proof is for.
+
+// Timestamp has the authority at url timestamp the entry's current body
+// and stores the token with the entry. The caller is responsible for
+// saving the entry afterwards.
+func (e *Entry) Timestamp(ctx context.Context, url string) (*types.Timestamp, error) {
+	body, err := e.GetDecryptedBody()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := e.Timestamps()
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	ts := types.Timestamp{Authority: url, BodyHash: hashBody(body), Salt: hex.EncodeToString(salt)}
+	digest := sha256.Sum256(e.TimestampStatement(ts))
+	if ts.Token, err = tsa.Request(ctx, url, digest[:]); err != nil {
+		return nil, err
+	}
+	token, err := tsa.Verify(ts.Token, digest[:], nil)
+	if err != nil {
+		return nil, err
+	}
+	ts.Time = token.Time.UTC()
+
+	data, err := json.Marshal(append(existing, ts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode timestamps: %w", err)
+	}
+	if e.Entry.Timestamps, err = e.encrypt(string(data)); err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// Timestamps returns the entry's timestamps, oldest first
+func (e *Entry) Timestamps() ([]types.Timestamp, error) {
+	if len(e.Entry.Timestamps) == 0 {
+		return nil, nil
+	}
+	data, err := e.decrypt(e.Entry.Timestamps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt timestamps of entry %s: %w", e.ID, err)
+	}
+	var ts []types.Timestamp
+	if err := json.Unmarshal([]byte(data), &ts); err != nil {
+		return nil, fmt.Errorf("failed to decode timestamps of entry %s: %w", e.ID, err)
+	}
+	return ts, nil
+}
+
+// TimestampStatement returns the text whose SHA-256 hash a timestamp
+// covers. Anyone holding it and the token can check the timestamp with
+// other RFC 3161 tools, such as openssl ts.
+func (e *Entry) TimestampStatement(ts types.Timestamp) []byte {
+	return []byte(fmt.Sprintf("jot timestamp\nentry: %s\ncreated: %s\nbody-sha256: %s\nsalt: %s\n",
+		e.ID,
+		e.Created.UTC().Format(time.RFC3339Nano),
+		ts.BodyHash,
+		ts.Salt))
+}
+
+// VerifyTimestamp checks ts against the entry and its decrypted body. The
+// authority's certificate is checked against roots, or the system's roots
+// when roots is nil; the returned token's Untrusted tells the outcome.
+func (e *Entry) VerifyTimestamp(ts types.Timestamp, body string, roots *x509.CertPool) (string, *tsa.Token) {
+	digest := sha256.Sum256(e.TimestampStatement(ts))
+	token, err := tsa.Verify(ts.Token, digest[:], roots)
+	if err != nil {
+		return ProofInvalid, nil
+	}
+	if ts.BodyHash != hashBody(body) {
+		return ProofBodyChanged, token
+	}
+	return ProofValid, token
+}
@@ -0,0 +1,204 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/veritome/jot/internal/checkin"
+	"github.com/veritome/jot/internal/config"
+)
+
+// namesFile is the user-maintained list of names replaced by --anonymize
+const namesFile = "anonymize.txt"
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\(?\d[\d ().-]{5,}\d`)
+	datePattern  = regexp.MustCompile(`^\d{1,4}([.-])\d{1,2}([.-])\d{1,4}$`)
+)
+
+// Anonymizer replaces names from the user's list, email addresses and phone
+// numbers with placeholders
+type Anonymizer struct {
+	names        *regexp.Regexp // Every name and alias, longest first
+	placeholders map[string]string
+}
+
+// NamesPath returns the location of the user's list of names
+func NamesPath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, namesFile), nil
+}
+
+// LoadAnonymizer reads the user's list of names. Each line holds a name and
+// its aliases separated by commas, optionally followed by "= placeholder";
+// names without one become [Person A], [Person B] and so on in the order
+// listed. Blank lines and lines starting with '#' are ignored. Without a
+// list only email addresses and phone numbers are replaced.
+func LoadAnonymizer() (*Anonymizer, error) {
+	path, err := NamesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read names file: %w", err)
+	}
+	return ParseNames(string(data))
+}
+
+// ParseNames builds an Anonymizer from the contents of a names file
+func ParseNames(data string) (*Anonymizer, error) {
+	a := &Anonymizer{placeholders: make(map[string]string)}
+	person := 0
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names, placeholder := line, ""
+		if eq := strings.Index(line, "="); eq >= 0 {
+			names, placeholder = line[:eq], strings.TrimSpace(line[eq+1:])
+			if placeholder == "" {
+				return nil, fmt.Errorf("names file line %d: missing placeholder after '='", i+1)
+			}
+		}
+		if placeholder == "" {
+			placeholder = fmt.Sprintf("[Person %s]", personLabel(person))
+			person++
+		}
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				a.placeholders[strings.ToLower(name)] = placeholder
+			}
+		}
+	}
+
+	if len(a.placeholders) > 0 {
+		names := make([]string, 0, len(a.placeholders))
+		for name := range a.placeholders {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+		// Longest first, so "Anna Smith" wins over "Anna"
+		sort.Slice(names, func(x, y int) bool {
+			if len(names[x]) != len(names[y]) {
+				return len(names[x]) > len(names[y])
+			}
+			return names[x] < names[y]
+		})
+		a.names = regexp.MustCompile(`(?i)` + strings.Join(names, "|"))
+	}
+	return a, nil
+}
+
+// personLabel returns A to Z, then AA, AB and so on
+func personLabel(n int) string {
+	label := ""
+	for n >= 0 {
+		label = string(rune('A'+n%26)) + label
+		n = n/26 - 1
+	}
+	return label
+}
+
+// Apply returns text with names, email addresses and phone numbers replaced
+func (a *Anonymizer) Apply(text string) string {
+	// Email addresses go first, since they may contain listed names
+	text = emailPattern.ReplaceAllString(text, "[email]")
+	text = phonePattern.ReplaceAllStringFunc(text, func(s string) string {
+		if isPhone(s) {
+			return "[phone]"
+		}
+		return s
+	})
+	if a.names == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range a.names.FindAllStringIndex(text, -1) {
+		// Only whole words, so "Al" leaves "Alps" alone
+		if !wordBoundary(text, m[0], m[1]) {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(a.placeholders[strings.ToLower(text[m[0]:m[1]])])
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Entries anonymizes the authors, titles, tags, bodies, check-in text
+// answers and bookmarked pages of entries in place, dropping their
+// locations
+func (a *Anonymizer) Entries(entries []Entry) {
+	for i := range entries {
+		e := &entries[i]
+		e.Author = a.Apply(e.Author)
+		e.Title = a.Apply(e.Title)
+		e.Body = a.Apply(e.Body)
+		for t := range e.Tags {
+			e.Tags[t] = a.Apply(e.Tags[t])
+		}
+		if e.Checkin != nil {
+			for n := range e.Checkin.Answers {
+				if answer := &e.Checkin.Answers[n]; answer.Kind == checkin.Text {
+					answer.Value = a.Apply(answer.Value)
+				}
+			}
+		}
+		if b := e.Bookmark; b != nil {
+			b.URL = a.Apply(b.URL)
+			b.Title = a.Apply(b.Title)
+			b.Description = a.Apply(b.Description)
+		}
+		if f := e.Fields; f != nil {
+			f.Location = ""
+		}
+	}
+}
+
+// isPhone reports whether a match of phonePattern is a phone number rather
+// than a date or a plain number
+func isPhone(s string) bool {
+	if datePattern.MatchString(s) {
+		return false
+	}
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < 7 || digits > 15 {
+		return false
+	}
+	// Long runs of digits are numbers unless written like a phone number
+	return digits >= 10 || strings.HasPrefix(s, "+") || strings.ContainsAny(s, " ().-")
+}
+
+// wordBoundary reports whether text[start:end] is not part of a longer word
+func wordBoundary(text string, start, end int) bool {
+	if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(r) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"filippo.io/age"
+)
+
+// bundleFormat and bundleVersion identify the contents of an encrypted
+// bundle once decrypted
+const (
+	bundleFormat  = "jot-bundle"
+	bundleVersion = 1
+)
+
+// bundle is the JSON document an encrypted bundle holds
+type bundle struct {
+	Format  string      `json:"format"`
+	Version int         `json:"version"`
+	Created time.Time   `json:"created"`
+	Entries []jsonEntry `json:"entries"`
+}
+
+// Bundle is the decrypted contents of an encrypted bundle
+type Bundle struct {
+	Created time.Time // When the bundle was written
+	Entries []Entry
+}
+
+// WriteBundle writes entries to w as a single gzipped JSON document
+// encrypted with passphrase in the age format, which the age tool can also
+// decrypt
+func WriteBundle(w io.Writer, passphrase string, entries []Entry) error {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to derive key: %w", err)
+	}
+	encrypted, err := age.Encrypt(w, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt bundle: %w", err)
+	}
+
+	b := bundle{Format: bundleFormat, Version: bundleVersion, Created: time.Now().UTC(), Entries: make([]jsonEntry, len(entries))}
+	for i, e := range entries {
+		b.Entries[i] = toJSON(e)
+	}
+	gz := gzip.NewWriter(encrypted)
+	if err := json.NewEncoder(gz).Encode(b); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := encrypted.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle decrypts a bundle written by WriteBundle
+func ReadBundle(r io.Reader, passphrase string) (*Bundle, error) {
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	decrypted, err := age.Decrypt(r, identity)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, errors.New("wrong passphrase, or not a bundle encrypted with one")
+		}
+		return nil, fmt.Errorf("failed to decrypt bundle: %w", err)
+	}
+	gz, err := gzip.NewReader(decrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	var b bundle
+	if err := json.NewDecoder(gz).Decode(&b); err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if b.Format != bundleFormat {
+		return nil, errors.New("not a jot bundle")
+	}
+	if b.Version > bundleVersion {
+		return nil, fmt.Errorf("bundle version %d is newer than this jot understands; update jot", b.Version)
+	}
+
+	out := &Bundle{Created: b.Created, Entries: make([]Entry, len(b.Entries))}
+	for i, e := range b.Entries {
+		out.Entries[i] = fromJSON(e)
+	}
+	return out, nil
+}
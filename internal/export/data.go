@@ -0,0 +1,136 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// jsonEntry is the JSON form of an exported entry
+type jsonEntry struct {
+	ID        string          `json:"id"`
+	Journal   string          `json:"journal"`
+	Author    string          `json:"author,omitempty"`
+	Created   time.Time       `json:"created"`
+	Updated   *time.Time      `json:"updated,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	Tags      []string        `json:"tags"`
+	Sensitive bool            `json:"sensitive,omitempty"`
+	State     string          `json:"state,omitempty"`
+	Body      string          `json:"body"`
+	Checkin   *types.Checkin  `json:"checkin,omitempty"`
+	Bookmark  *types.Bookmark `json:"bookmark,omitempty"`
+	Fields    *types.Fields   `json:"fields,omitempty"`
+	Rollup    *types.Rollup   `json:"rollup,omitempty"`
+}
+
+// JSON writes entries as an indented JSON array
+func JSON(w io.Writer, entries []Entry) error {
+	out := make([]jsonEntry, len(entries))
+	for i, e := range entries {
+		out[i] = toJSON(e)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+func toJSON(e Entry) jsonEntry {
+	j := jsonEntry{
+		ID:        e.ID,
+		Journal:   e.Journal,
+		Author:    e.Author,
+		Created:   e.Created,
+		Updated:   e.Updated,
+		Title:     e.Title,
+		Tags:      e.Tags,
+		Sensitive: e.Sensitive,
+		State:     e.State,
+		Body:      e.Body,
+		Checkin:   e.Checkin,
+		Bookmark:  e.Bookmark,
+		Fields:    e.Fields,
+		Rollup:    e.Rollup,
+	}
+	if j.Tags == nil {
+		j.Tags = []string{}
+	}
+	return j
+}
+
+func fromJSON(j jsonEntry) Entry {
+	return Entry{
+		ID:        j.ID,
+		Journal:   j.Journal,
+		Author:    j.Author,
+		Created:   j.Created,
+		Updated:   j.Updated,
+		Title:     j.Title,
+		Tags:      j.Tags,
+		Sensitive: j.Sensitive,
+		State:     j.State,
+		Body:      j.Body,
+		Checkin:   j.Checkin,
+		Bookmark:  j.Bookmark,
+		Fields:    j.Fields,
+		Rollup:    j.Rollup,
+	}
+}
+
+// csvHeader names the columns written by CSV
+var csvHeader = []string{"id", "journal", "author", "created", "updated", "title", "tags", "sensitive", "mood", "energy", "weather", "location", "body"}
+
+// CSV writes entries with a header row. Times are RFC 3339 and tags are
+// separated by semicolons; check-in answers are only part of the body.
+func CSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, e := range entries {
+		updated := ""
+		if e.Updated != nil {
+			updated = e.Updated.Format(time.RFC3339)
+		}
+		var fields types.Fields
+		if e.Fields != nil {
+			fields = *e.Fields
+		}
+		energy := ""
+		if fields.Energy != 0 {
+			energy = strconv.Itoa(fields.Energy)
+		}
+		record := []string{
+			e.ID,
+			e.Journal,
+			e.Author,
+			e.Created.Format(time.RFC3339),
+			updated,
+			e.Title,
+			strings.Join(e.Tags, ";"),
+			strconv.FormatBool(e.Sensitive),
+			fields.Mood,
+			energy,
+			fields.Weather,
+			fields.Location,
+			e.Body,
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
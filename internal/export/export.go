@@ -0,0 +1,86 @@
+// Package export writes decrypted copies of journals in formats other tools
+// and browsers can read.
+package export
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Entry is a decrypted entry ready to be exported
+type Entry struct {
+	ID        string
+	Journal   string
+	Author    string // Member who wrote it, for entries of shared journals
+	Created   time.Time
+	Updated   *time.Time
+	Title     string
+	Tags      []string
+	Body      string
+	Sensitive bool
+	State     string          // "read" or "archived"; empty while unread
+	Checkin   *types.Checkin  // Answers, when the entry is a check-in
+	Bookmark  *types.Bookmark // Link, when the entry is a bookmark
+	Fields    *types.Fields   // Mood, energy, weather and location, if any
+	Rollup    *types.Rollup   // Period reviewed, when the entry is a rollup
+}
+
+// Load decrypts every entry of j, oldest first
+func Load(j *journal.Journal) ([]Entry, error) {
+	entries, err := j.GetEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entries: %w", err)
+	}
+
+	out := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		body, err := e.GetDecryptedBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+		}
+		title, err := e.GetTitle()
+		if err != nil {
+			return nil, err
+		}
+		tags, err := e.GetTags()
+		if err != nil {
+			return nil, err
+		}
+		checkin, err := e.GetCheckin()
+		if err != nil {
+			return nil, err
+		}
+		bookmark, err := e.GetBookmark()
+		if err != nil {
+			return nil, err
+		}
+		fields, err := e.GetFields()
+		if err != nil {
+			return nil, err
+		}
+		rollup, err := e.GetRollup()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Entry{
+			ID:        e.ID,
+			Journal:   j.Name,
+			Author:    e.Author,
+			Created:   e.Created,
+			Updated:   e.Updated,
+			Title:     title,
+			Tags:      tags,
+			Body:      body,
+			Sensitive: e.Sensitive,
+			State:     e.State,
+			Checkin:   checkin,
+			Bookmark:  bookmark,
+			Fields:    fields,
+			Rollup:    rollup,
+		})
+	}
+	return out, nil
+}
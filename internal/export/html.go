@@ -0,0 +1,355 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Themes lists the colour themes of HTML exports. auto follows the
+// browser's light or dark preference.
+var Themes = []string{"auto", "light", "dark", "sepia"}
+
+// Files of an HTML export
+const (
+	htmlIndex      = "index.html"
+	htmlStyle      = "style.css"
+	htmlEntriesDir = "entries"
+)
+
+// HTML writes entries as a static site in dir: index.html lists them by
+// month and searches them in the browser, and each entry has its own page
+// under entries/. The site needs no server or network access. Everything is
+// decrypted, so it is written readable only by the current user. dir must be
+// new, empty or a previous export.
+func HTML(dir, journal string, entries []Entry, theme string) error {
+	css, ok := themeCSS[theme]
+	if !ok {
+		return fmt.Errorf("unknown theme '%s': expected one of %s", theme, strings.Join(Themes, ", "))
+	}
+	if err := checkExportDir(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, htmlEntriesDir), 0700); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	// Pages of entries deleted since the previous export go too
+	stale, err := filepath.Glob(filepath.Join(dir, htmlEntriesDir, "*.html"))
+	if err != nil {
+		return fmt.Errorf("failed to list previous export: %w", err)
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove previous export: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, htmlStyle), []byte(baseCSS+css), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", htmlStyle, err)
+	}
+
+	pages := make([]htmlEntry, len(entries))
+	for i, e := range entries {
+		pages[i] = newHTMLEntry(e)
+	}
+
+	for i, p := range pages {
+		data := entryPage{Journal: journal, Entry: p}
+		if i > 0 {
+			data.Older = &pages[i-1]
+		}
+		if i < len(pages)-1 {
+			data.Newer = &pages[i+1]
+		}
+		if err := writeTemplate(filepath.Join(dir, htmlEntriesDir, p.File), "entry", data); err != nil {
+			return err
+		}
+	}
+
+	search, err := searchData(pages)
+	if err != nil {
+		return err
+	}
+	index := indexPage{Journal: journal, Count: len(pages), Months: byMonth(pages), Search: search, Exported: time.Now()}
+	return writeTemplate(filepath.Join(dir, htmlIndex), "index", index)
+}
+
+// checkExportDir refuses to write into a directory holding anything but a
+// previous export
+func checkExportDir(dir string) error {
+	names, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read export directory: %w", err)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, htmlIndex)); err != nil {
+		return fmt.Errorf("%s is not empty and holds no previous export", dir)
+	}
+	return nil
+}
+
+// htmlEntry is an entry as shown on the site
+type htmlEntry struct {
+	Entry
+	File  string // Page name under entries/
+	Label string // Title or first line, or a placeholder for sensitive entries
+	Date  string
+}
+
+func newHTMLEntry(e Entry) htmlEntry {
+	summary := e.Title
+	if summary == "" {
+		summary = strings.TrimSpace(strings.SplitN(strings.TrimSpace(e.Body), "\n", 2)[0])
+	}
+	if runes := []rune(summary); len(runes) > 80 {
+		summary = string(runes[:77]) + "..."
+	}
+	if e.Sensitive {
+		summary = "Sensitive entry"
+	}
+	return htmlEntry{
+		Entry: e,
+		File:  pageName(e.ID),
+		Label: summary,
+		Date:  e.Created.Local().Format("Mon 2 Jan 2006, 15:04"),
+	}
+}
+
+// pageName returns the file name of an entry's page. IDs come from
+// entry.id_format and may hold characters unsafe in file names.
+func pageName(id string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, id)
+	return safe + ".html"
+}
+
+// month is the entries of one month, newest first
+type month struct {
+	Name    string
+	Entries []htmlEntry
+}
+
+// byMonth groups entries sorted oldest first into months, newest first
+func byMonth(entries []htmlEntry) []month {
+	var months []month
+	for i := len(entries) - 1; i >= 0; i-- {
+		name := entries[i].Created.Local().Format("January 2006")
+		if len(months) == 0 || months[len(months)-1].Name != name {
+			months = append(months, month{Name: name})
+		}
+		m := &months[len(months)-1]
+		m.Entries = append(m.Entries, entries[i])
+	}
+	return months
+}
+
+// searchData returns the JSON searched by the index page. Sensitive
+// entries can only be found by their date.
+func searchData(entries []htmlEntry) (template.JS, error) {
+	type record struct {
+		File  string   `json:"file"`
+		Date  string   `json:"date"`
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+		Text  string   `json:"text"`
+	}
+	records := make([]record, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		r := record{File: e.File, Date: e.Date, Title: e.Label, Tags: []string{}}
+		if !e.Sensitive {
+			r.Text = e.Body
+			r.Tags = append(r.Tags, e.Tags...)
+		}
+		records = append(records, r)
+	}
+	// json.Marshal escapes <, > and &, so the data cannot close the script
+	data, err := json.Marshal(records)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode search data: %w", err)
+	}
+	return template.JS(data), nil
+}
+
+type indexPage struct {
+	Journal  string
+	Count    int
+	Months   []month
+	Search   template.JS
+	Exported time.Time
+}
+
+type entryPage struct {
+	Journal string
+	Entry   htmlEntry
+	Older   *htmlEntry
+	Newer   *htmlEntry
+}
+
+func writeTemplate(path, name string, data interface{}) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(f, name, data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+var htmlTemplates = template.Must(template.New("").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.}}</title>
+{{end}}
+
+{{define "tags"}}{{range .}} <span class="tag">#{{.}}</span>{{end}}{{end}}
+
+{{define "index"}}{{template "head" .Journal}}<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<header>
+<h1>{{.Journal}}</h1>
+<p class="meta">{{.Count}} entries, exported {{.Exported.Format "2 January 2006"}}</p>
+<input id="search" type="search" placeholder="Search entries" autocomplete="off">
+</header>
+<main>
+<ul id="results" class="entries" hidden></ul>
+<div id="months">
+{{range .Months}}<section>
+<h2>{{.Name}}</h2>
+<ul class="entries">
+{{range .Entries}}<li><a href="entries/{{.File}}"><span class="date">{{.Date}}</span> <span{{if .Sensitive}} class="hidden"{{end}}>{{.Label}}</span></a>{{if not .Sensitive}}{{template "tags" .Tags}}{{end}}</li>
+{{end}}</ul>
+</section>
+{{end}}</div>
+</main>
+<script>
+const entries = {{.Search}};
+const search = document.getElementById("search");
+const results = document.getElementById("results");
+const months = document.getElementById("months");
+search.addEventListener("input", () => {
+  const words = search.value.toLowerCase().split(/\s+/).filter(w => w);
+  months.hidden = words.length > 0;
+  results.hidden = words.length === 0;
+  results.replaceChildren();
+  if (!words.length) return;
+  for (const e of entries) {
+    const text = [e.date, e.title, e.text, ...e.tags.map(t => "#" + t)].join(" ").toLowerCase();
+    if (!words.every(w => text.includes(w))) continue;
+    const li = document.createElement("li");
+    const a = document.createElement("a");
+    a.href = "entries/" + e.file;
+    const date = document.createElement("span");
+    date.className = "date";
+    date.textContent = e.date;
+    a.append(date, " ", e.title);
+    li.append(a);
+    results.append(li);
+  }
+  if (!results.children.length) {
+    const li = document.createElement("li");
+    li.className = "meta";
+    li.textContent = "No matching entries";
+    results.append(li);
+  }
+});
+</script>
+</body>
+</html>
+{{end}}
+
+{{define "entry"}}{{template "head" .Entry.Label}}<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+<header>
+<p class="meta"><a href="../index.html">{{.Journal}}</a></p>
+{{with .Entry}}{{if and .Title (not .Sensitive)}}<h1>{{.Title}}</h1>{{end}}
+<p class="meta">{{.Date}}{{if .Author}} by {{.Author}}{{end}}{{if .Updated}}, edited {{.Updated.Local.Format "2 Jan 2006"}}{{end}}</p>
+{{end}}</header>
+<main>
+{{with .Entry}}{{if .Sensitive}}<details>
+<summary>Sensitive entry: show</summary>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+{{if .Tags}}<p>{{template "tags" .Tags}}</p>{{end}}
+<div class="body">{{.Body}}</div>
+</details>{{else}}{{if .Tags}}<p>{{template "tags" .Tags}}</p>{{end}}
+<div class="body">{{.Body}}</div>{{end}}{{end}}
+</main>
+<nav>
+{{with .Older}}<a href="{{.File}}">&larr; {{.Label}}</a>{{end}}
+{{with .Newer}}<a class="newer" href="{{.File}}">{{.Label}} &rarr;</a>{{end}}
+</nav>
+</body>
+</html>
+{{end}}
+`))
+
+// baseCSS lays out every theme; the themes only set colours
+const baseCSS = `body {
+  max-width: 42rem;
+  margin: 0 auto;
+  padding: 2rem 1rem;
+  font: 17px/1.6 Georgia, "Times New Roman", serif;
+  background: var(--bg);
+  color: var(--fg);
+}
+a { color: var(--link); text-decoration: none; }
+a:hover { text-decoration: underline; }
+h1, h2 { font-weight: normal; line-height: 1.3; }
+h2 { margin-top: 2rem; border-bottom: 1px solid var(--rule); }
+.meta, .date, .tag, .hidden, summary { color: var(--muted); font-size: 0.9em; }
+.date { display: inline-block; min-width: 11rem; }
+.tag { margin-left: 0.3em; }
+.entries { list-style: none; padding: 0; }
+.entries li { margin: 0.3rem 0; }
+.body { white-space: pre-wrap; overflow-wrap: anywhere; }
+summary { cursor: pointer; }
+#search {
+  width: 100%;
+  box-sizing: border-box;
+  padding: 0.5rem;
+  font: inherit;
+  background: var(--bg);
+  color: var(--fg);
+  border: 1px solid var(--rule);
+}
+nav { display: flex; margin-top: 3rem; border-top: 1px solid var(--rule); padding-top: 1rem; }
+nav .newer { margin-left: auto; }
+`
+
+const (
+	lightCSS = `:root { --bg: #ffffff; --fg: #222222; --muted: #777777; --link: #1a5fb4; --rule: #dddddd; }
+`
+	darkCSS = `:root { --bg: #1e1e1e; --fg: #dddddd; --muted: #999999; --link: #8ab4f8; --rule: #444444; }
+`
+	sepiaCSS = `:root { --bg: #f4ecd8; --fg: #433422; --muted: #8a7558; --link: #8b4513; --rule: #d8c9a8; }
+`
+)
+
+// themeCSS holds the colours of each theme
+var themeCSS = map[string]string{
+	"auto":  lightCSS + "@media (prefers-color-scheme: dark) {\n" + darkCSS + "}\n",
+	"light": lightCSS,
+	"dark":  darkCSS,
+	"sepia": sepiaCSS,
+}
@@ -0,0 +1,120 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Logseq keeps daily notes in journals/, one page per day named after the
+// date in its default file name format
+const (
+	logseqJournalsDir = "journals"
+	logseqPageLayout  = "2006_01_02"
+)
+
+// logseqMarker is the page property marking day pages written by jot, which
+// a later export may replace
+const logseqMarker = "source:: jot"
+
+// Logseq writes entries into the Logseq graph in dir as journal pages, one
+// per day under journals/, holding a block for each entry written that day
+// with its journal and tags as page links. Day pages written by a previous
+// export are replaced, including those of days left without entries. Other
+// pages of the graph are never touched, and a day page written in Logseq
+// itself stops the export before anything is written.
+func Logseq(dir string, entries []Entry) (int, error) {
+	journalsDir := filepath.Join(dir, logseqJournalsDir)
+	days := make(map[string][]Entry)
+	for _, e := range entries {
+		name := e.Created.Local().Format(logseqPageLayout) + ".md"
+		days[name] = append(days[name], e)
+	}
+
+	previous, err := filepath.Glob(filepath.Join(journalsDir, "*.md"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list journal pages: %w", err)
+	}
+	var stale []string
+	for _, path := range previous {
+		ours, err := writtenByJot(path)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case ours && days[filepath.Base(path)] == nil:
+			stale = append(stale, path)
+		case !ours && days[filepath.Base(path)] != nil:
+			return 0, fmt.Errorf("%s was not written by jot; move it out of the graph or export elsewhere", path)
+		}
+	}
+
+	if err := os.MkdirAll(journalsDir, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return 0, fmt.Errorf("failed to remove previous export: %w", err)
+		}
+	}
+	for name, list := range days {
+		sort.SliceStable(list, func(a, b int) bool { return list[a].Created.Before(list[b].Created) })
+		if err := os.WriteFile(filepath.Join(journalsDir, name), []byte(logseqPage(list)), 0600); err != nil {
+			return 0, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return len(days), nil
+}
+
+// writtenByJot reports whether the day page at path starts with the marker
+// of an export
+func writtenByJot(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+	first, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && first == "" {
+		return false, nil
+	}
+	return strings.TrimSpace(first) == logseqMarker, nil
+}
+
+// logseqPage returns the Markdown of a day page holding entries
+func logseqPage(entries []Entry) string {
+	var b strings.Builder
+	b.WriteString(logseqMarker + "\n\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "- **%s**", e.Created.Local().Format("15:04"))
+		if e.Title != "" {
+			fmt.Fprintf(&b, " %s", e.Title)
+		}
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "  journal:: [[%s]]\n", e.Journal)
+		if len(e.Tags) > 0 {
+			links := make([]string, len(e.Tags))
+			for i, t := range e.Tags {
+				links[i] = "[[" + t + "]]"
+			}
+			fmt.Fprintf(&b, "  tags:: %s\n", strings.Join(links, ", "))
+		}
+		if e.Bookmark != nil {
+			fmt.Fprintf(&b, "  url:: %s\n", e.Bookmark.URL)
+		}
+		fmt.Fprintf(&b, "  jot-id:: %s\n", e.ID)
+		// Continuation lines are indented to stay in the entry's block;
+		// lists in the body become its child blocks
+		for _, line := range strings.Split(strings.TrimSpace(e.Body), "\n") {
+			if strings.TrimSpace(line) == "" {
+				b.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LockFile is the name of the advisory lock file inside the data directory
+const LockFile = "jot.lock"
+
+// WriteFileAtomic writes data to path by writing a temporary file in the same
+// directory and renaming it over path, so readers never observe a partially
+// written file and a crash leaves either the old or the new contents.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Lock is an exclusive advisory lock on a data directory. It serializes
+// mutations between jot processes; it is not reentrant.
+type Lock struct {
+	f *os.File
+}
+
+// LockDir blocks until it holds the exclusive lock for dir
+func LockDir(dir string) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// A lock file planted by another user would let them hold jot up, or
+	// point it at a file of theirs
+	path := filepath.Join(dir, LockFile)
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil, &NotPrivateError{path, "is a symbolic link"}
+		}
+		if uid, ok := OtherOwner(info); ok {
+			return nil, &NotPrivateError{path, fmt.Sprintf("is owned by another user (uid %d)", uid)}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFD(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock
+func (l *Lock) Unlock() error {
+	if err := unlockFD(l.f); err != nil {
+		l.f.Close()
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package fsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFD(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFD(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
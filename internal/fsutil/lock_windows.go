@@ -0,0 +1,20 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Lock the first byte of the file; the lock file never holds any data
+func lockFD(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol)
+}
+
+func unlockFD(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
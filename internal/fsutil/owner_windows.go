@@ -0,0 +1,10 @@
+//go:build windows
+
+package fsutil
+
+import "os"
+
+// Files have no uid on Windows
+func owner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
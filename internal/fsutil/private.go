@@ -0,0 +1,93 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// NotPrivateError reports a file or directory other users can reach or
+// swap, such as one they own
+type NotPrivateError struct {
+	Path   string
+	Reason string
+}
+
+func (e *NotPrivateError) Error() string {
+	return fmt.Sprintf("%s %s", e.Path, e.Reason)
+}
+
+// CheckPrivate returns a *NotPrivateError when path is a symbolic link, is
+// owned by another user or allows other users access. Permissions and
+// owners are not checked on Windows, where files under the profile are
+// already private.
+func CheckPrivate(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return &NotPrivateError{path, "is a symbolic link"}
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if uid, ok := OtherOwner(info); ok {
+		return &NotPrivateError{path, fmt.Sprintf("is owned by another user (uid %d)", uid)}
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return &NotPrivateError{path, fmt.Sprintf("allows access by other users (permissions %04o)", info.Mode().Perm())}
+	}
+	return nil
+}
+
+// OtherOwner returns the uid of the user owning the file described by info
+// when that is not the current user
+func OtherOwner(info os.FileInfo) (int, bool) {
+	uid, ok := owner(info)
+	if !ok || uid == os.Getuid() {
+		return 0, false
+	}
+	return uid, true
+}
+
+// PrivateDir creates dir readable only by the current user, or checks with
+// CheckPrivate that an existing one is theirs alone
+func PrivateDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err == nil || !os.IsExist(err) {
+		return err
+	}
+	if err := CheckPrivate(dir); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &NotPrivateError{dir, "is not a directory"}
+	}
+	return nil
+}
+
+// TempDir returns the current user's own directory for temporary files,
+// jot-<uid> in the system's temporary directory, creating it when needed.
+// Other users can see neither the names nor the contents of files in it.
+func TempDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), TempDirName())
+	if err := PrivateDir(dir); err != nil {
+		return "", fmt.Errorf("failed to prepare temporary directory: %w", err)
+	}
+	return dir, nil
+}
+
+// TempDirName is the name of the current user's directory in the system's
+// temporary directory
+func TempDirName() string {
+	if runtime.GOOS == "windows" {
+		// The temporary directory is under the user's profile already
+		return "jot"
+	}
+	return fmt.Sprintf("jot-%d", os.Getuid())
+}
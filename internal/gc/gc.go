@@ -0,0 +1,112 @@
+package gc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/trash"
+)
+
+// Result summarises a garbage collection of the blob store
+type Result struct {
+	Blobs      int   // Blobs in the store before collecting
+	Referenced int   // Blobs referenced by at least one entry or revision
+	Shared     int   // Blobs referenced more than once
+	Saved      int64 // Bytes saved by sharing blobs instead of copying them
+	Removed    int   // Unreferenced blobs removed, or that would be with dryRun
+	Freed      int64 // Bytes of the removed blobs
+	Recent     int   // Unreferenced blobs kept because they were written recently
+}
+
+// Run counts the references to every blob from live and trashed entries,
+// including their revisions and the deleted entries jot undo can restore,
+// and removes the blobs nothing references. Blobs written within
+// entry.BlobGracePeriod are kept, since the entry referencing them may not
+// be saved yet. With dryRun nothing is removed.
+func Run(dryRun bool) (*Result, error) {
+	// Blobs are listed before references are counted, so a blob written
+	// meanwhile is either unlisted or recent
+	blobs, err := entry.ListBlobs()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := countRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Blobs: len(blobs)}
+	cutoff := time.Now().Add(-entry.BlobGracePeriod)
+	for _, b := range blobs {
+		if n := refs[b.Ref]; n > 0 {
+			result.Referenced++
+			if n > 1 {
+				result.Shared++
+				result.Saved += int64(n-1) * b.Size
+			}
+			continue
+		}
+		if b.Modified.After(cutoff) {
+			result.Recent++
+			continue
+		}
+
+		if !dryRun {
+			removed, err := entry.RemoveBlob(b.Ref, cutoff)
+			if err != nil {
+				return result, err
+			}
+			if !removed {
+				// Reused since it was listed
+				result.Recent++
+				continue
+			}
+		}
+		result.Removed++
+		result.Freed += b.Size
+	}
+	return result, nil
+}
+
+// countRefs returns how many times each blob is referenced by stored,
+// trashed and undoable deleted entries. An entry that cannot be read aborts the count, since its
+// blobs would otherwise be collected.
+func countRefs() (map[string]int, error) {
+	ids, err := entry.ListIDs()
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := trash.EntryPaths()
+	if err != nil {
+		return nil, err
+	}
+	kept, err := oplog.EntryPaths()
+	if err != nil {
+		return nil, err
+	}
+	trashed = append(trashed, kept...)
+
+	refs := make(map[string]int)
+	count := func(e *entry.Entry) {
+		for _, ref := range e.BlobRefs() {
+			refs[ref]++
+		}
+	}
+	for _, id := range ids {
+		e, err := entry.Load(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load entry %s: %w", id, err)
+		}
+		count(e)
+	}
+	for _, path := range trashed {
+		e, err := entry.LoadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trashed entry %s: %w", path, err)
+		}
+		count(e)
+	}
+	return refs, nil
+}
@@ -0,0 +1,173 @@
+// Package goal tracks writing goals: how many entries a journal should get
+// each day or week, and how well that has gone. Only the date index is
+// read, so checking goals never decrypts anything.
+package goal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/when"
+)
+
+// Goal periods
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// Days and weeks looked back at for missed periods and recent activity
+const (
+	missedDays  = 30
+	missedWeeks = 12
+	recentCount = 7
+)
+
+// Status is the progress of a journal towards its goal
+type Status struct {
+	Journal string
+	Goal    types.Goal
+	Done    int   // Entries in the current period
+	Streak  int   // Consecutive periods meeting the goal, ending with the current one once met
+	Best    int   // Longest streak ever
+	Missed  int   // Finished periods since the goal was set that fell short, within the window
+	Checked int   // Finished periods since the goal was set, within the window
+	Recent  []int // Entries in the last periods, oldest first, ending with the current one
+}
+
+// Met reports whether the goal of the current period has been reached
+func (s *Status) Met() bool {
+	return s.Done >= s.Goal.Entries
+}
+
+// New returns a goal of entries per period starting today
+func New(period string, entries int, now time.Time) (*types.Goal, error) {
+	if period != Daily && period != Weekly {
+		return nil, fmt.Errorf("unknown goal period '%s'", period)
+	}
+	if entries < 1 {
+		return nil, fmt.Errorf("a goal needs at least one entry per period")
+	}
+	return &types.Goal{Period: period, Entries: entries, Since: start(now, period)}, nil
+}
+
+// Set stores g as the goal of journal name, or removes its goal when g is nil
+func Set(name string, g *types.Goal) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", name)
+		}
+		j.Goal = g
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save goal: %w", err)
+	}
+	return nil
+}
+
+// Compute works out the progress of journal name towards its goal from the
+// collection's date index
+func Compute(coll *collection.Collection, name string, now time.Time) (*Status, error) {
+	j, exists := coll.Journals[name]
+	if !exists {
+		return nil, fmt.Errorf("journal '%s' does not exist", name)
+	}
+	if j.Goal == nil {
+		return nil, fmt.Errorf("journal '%s' has no goal", name)
+	}
+	g := *j.Goal
+	s := &Status{Journal: name, Goal: g}
+
+	// Entries per period, keyed by the period's first day
+	counts := make(map[string]int)
+	first := ""
+	if coll.Index != nil {
+		listed := make(map[string]bool, len(j.EntryIDs))
+		for _, id := range j.EntryIDs {
+			listed[id] = true
+		}
+		for date, ids := range coll.Index.Dates {
+			day, err := time.ParseInLocation("2006-01-02", date, now.Location())
+			if err != nil {
+				continue
+			}
+			key := start(day, g.Period).Format("2006-01-02")
+			for _, id := range ids {
+				if listed[id] {
+					counts[key]++
+					if first == "" || key < first {
+						first = key
+					}
+				}
+			}
+		}
+	}
+
+	current := start(now, g.Period)
+	count := func(p time.Time) int { return counts[p.Format("2006-01-02")] }
+	s.Done = count(current)
+
+	// The current period joins the streak once met; until then the
+	// streak ending with the previous period still stands
+	p := current
+	if !s.Met() {
+		p = step(p, g.Period, -1)
+	}
+	for count(p) >= g.Entries {
+		s.Streak++
+		p = step(p, g.Period, -1)
+	}
+
+	if first != "" {
+		run := 0
+		for p, _ := time.ParseInLocation("2006-01-02", first, now.Location()); !p.After(current); p = step(p, g.Period, 1) {
+			if count(p) >= g.Entries {
+				run++
+				if run > s.Best {
+					s.Best = run
+				}
+			} else if !p.Equal(current) {
+				run = 0
+			}
+		}
+	}
+
+	window := missedDays
+	if g.Period == Weekly {
+		window = missedWeeks
+	}
+	since := start(g.Since.In(now.Location()), g.Period)
+	for i, p := 0, step(current, g.Period, -1); i < window && !p.Before(since); i, p = i+1, step(p, g.Period, -1) {
+		s.Checked++
+		if count(p) < g.Entries {
+			s.Missed++
+		}
+	}
+
+	for i := recentCount - 1; i >= 0; i-- {
+		s.Recent = append(s.Recent, count(step(current, g.Period, -i)))
+	}
+	return s, nil
+}
+
+// start returns the first day of the period containing t: its day, or the
+// first day of its week, starting on calendar.week_start
+func start(t time.Time, period string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if period == Weekly {
+		day = when.StartOfWeek(day)
+	}
+	return day
+}
+
+// step moves n periods from the period starting at p
+func step(p time.Time, period string, n int) time.Time {
+	if period == Weekly {
+		return p.AddDate(0, 0, 7*n)
+	}
+	return p.AddDate(0, 0, n)
+}
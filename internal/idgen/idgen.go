@@ -0,0 +1,237 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator produces entry IDs. taken holds every ID already in use,
+// including those of trashed entries; the returned ID must not be among them.
+type Generator interface {
+	Next(taken []string, now time.Time) (string, error)
+}
+
+// Built-in generators selectable by name
+var presets = map[string]string{
+	"sequential": "{seq:4}",                 // 0001, 0002, ...
+	"date":       "{date:20060102}-{seq:3}", // 20240501-001, restarting daily
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs and {rand}
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// validID matches IDs that are safe to use as file names
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// New returns the generator for spec, which is either the name of a built-in
+// generator ("sequential", "date" or "ulid") or a format string made of
+// literal text and the placeholders:
+//
+//	{seq} or {seq:N}    counter one above the highest existing match, zero-padded to N digits
+//	{date:LAYOUT}       the current date in Go time layout LAYOUT
+//	{rand:N}            N random base32 characters
+func New(spec string) (Generator, error) {
+	if spec == "ulid" {
+		return ulid{}, nil
+	}
+	if format, ok := presets[spec]; ok {
+		spec = format
+	}
+	return parseFormat(spec)
+}
+
+// part is a literal or placeholder of a format string
+type part struct {
+	kind  string // "", "seq", "date" or "rand"
+	value string // Literal text or date layout
+	width int
+}
+
+// formatGenerator renders IDs from a parsed format string
+type formatGenerator struct {
+	format string
+	parts  []part
+}
+
+var placeholder = regexp.MustCompile(`\{([a-z]+)(?::([^}]*))?\}`)
+
+func parseFormat(format string) (*formatGenerator, error) {
+	g := &formatGenerator{format: format}
+
+	last := 0
+	for _, m := range placeholder.FindAllStringSubmatchIndex(format, -1) {
+		if m[0] > last {
+			g.parts = append(g.parts, part{value: format[last:m[0]]})
+		}
+		last = m[1]
+
+		name := format[m[2]:m[3]]
+		arg := ""
+		if m[4] >= 0 {
+			arg = format[m[4]:m[5]]
+		}
+
+		p := part{kind: name}
+		switch name {
+		case "seq", "rand":
+			if arg != "" {
+				n, err := strconv.Atoi(arg)
+				if err != nil || n < 1 || n > 32 {
+					return nil, fmt.Errorf("invalid width '%s' in {%s}", arg, name)
+				}
+				p.width = n
+			} else if name == "rand" {
+				p.width = 8
+			}
+		case "date":
+			if arg == "" {
+				return nil, fmt.Errorf("{date} needs a layout, e.g. {date:20060102}")
+			}
+			p.value = arg
+		default:
+			return nil, fmt.Errorf("unknown placeholder {%s}", name)
+		}
+		g.parts = append(g.parts, p)
+	}
+	if last < len(format) {
+		g.parts = append(g.parts, part{value: format[last:]})
+	}
+
+	if len(g.parts) == 0 {
+		return nil, fmt.Errorf("ID format is empty")
+	}
+	return g, nil
+}
+
+// Next renders the format. The {seq} counter continues from the highest
+// existing ID with the same surrounding text, so with a date prefix it
+// restarts each day and numbers are never reused after a deletion.
+func (g *formatGenerator) Next(taken []string, now time.Time) (string, error) {
+	hasSeq, hasRand := false, false
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, p := range g.parts {
+		switch p.kind {
+		case "seq":
+			hasSeq = true
+			pattern.WriteString(`(\d+)`)
+		case "rand":
+			hasRand = true
+			fmt.Fprintf(&pattern, "[%s]{%d}", crockford, p.width)
+		case "date":
+			pattern.WriteString(regexp.QuoteMeta(now.Format(p.value)))
+		default:
+			pattern.WriteString(regexp.QuoteMeta(p.value))
+		}
+	}
+	pattern.WriteString("$")
+
+	seq := 1
+	if hasSeq {
+		re, err := regexp.Compile(pattern.String())
+		if err != nil {
+			return "", fmt.Errorf("invalid ID format '%s': %w", g.format, err)
+		}
+		for _, id := range taken {
+			m := re.FindStringSubmatch(id)
+			if m == nil {
+				continue
+			}
+			if n, err := strconv.Atoi(m[1]); err == nil && n >= seq {
+				seq = n + 1
+			}
+		}
+	}
+
+	inUse := make(map[string]bool, len(taken))
+	for _, id := range taken {
+		inUse[id] = true
+	}
+
+	// Random parts may collide; retry a few times before giving up
+	for attempt := 0; attempt < 10; attempt++ {
+		id, err := g.render(seq, now)
+		if err != nil {
+			return "", err
+		}
+		if !validID.MatchString(id) {
+			return "", fmt.Errorf("ID format '%s' produced '%s'; IDs may only contain letters, digits, '.', '_' and '-'", g.format, id)
+		}
+		if !inUse[id] {
+			return id, nil
+		}
+		if !hasRand {
+			break
+		}
+	}
+	return "", fmt.Errorf("ID format '%s' produced an ID that is already in use; add {seq} or {rand}", g.format)
+}
+
+func (g *formatGenerator) render(seq int, now time.Time) (string, error) {
+	var b strings.Builder
+	for _, p := range g.parts {
+		switch p.kind {
+		case "seq":
+			fmt.Fprintf(&b, "%0*d", p.width, seq)
+		case "rand":
+			s, err := randomString(p.width)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+		case "date":
+			b.WriteString(now.Format(p.value))
+		default:
+			b.WriteString(p.value)
+		}
+	}
+	return b.String(), nil
+}
+
+// randomString returns n random characters of the Crockford base32 alphabet
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random ID: %w", err)
+	}
+	for i := range buf {
+		buf[i] = crockford[buf[i]%32]
+	}
+	return string(buf), nil
+}
+
+// ulid generates Universally Unique Lexicographically Sortable Identifiers:
+// a 48-bit millisecond timestamp followed by 80 random bits, in base32
+type ulid struct{}
+
+func (ulid) Next(taken []string, now time.Time) (string, error) {
+	var data [16]byte
+	ms := uint64(now.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		data[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := rand.Read(data[6:]); err != nil {
+		return "", fmt.Errorf("failed to generate ULID: %w", err)
+	}
+
+	// 128 bits encode to 26 characters, the first holding only 3 bits
+	var out [26]byte
+	var acc uint32
+	bits := 2 // Pad to 130 bits so the groups line up
+	pos := 0
+	for _, b := range data {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockford[(acc>>uint(bits))&31]
+			pos++
+		}
+	}
+	return string(out[:]), nil
+}
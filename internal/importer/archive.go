@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// errNotFound stops a walk once the wanted file is found
+var errNotFound = errors.New("not found")
+
+// readExport returns the contents of the first file of an app's export
+// that wanted accepts, given its path inside the export. path may be the
+// file itself, a zip archive as the apps download it or the folder it was
+// extracted to.
+func readExport(path string, wanted func(name string, data []byte) bool) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		var found []byte
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(path, p)
+			if wanted(filepath.ToSlash(rel), data) {
+				found = data
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			return nil, errNotFound
+		}
+		return found, nil
+	}
+
+	if z, err := zip.OpenReader(path); err == nil {
+		defer z.Close()
+		for _, f := range z.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			data, err := readZipFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if wanted(f.Name, data) {
+				return data, nil
+			}
+		}
+		return nil, errNotFound
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !wanted(filepath.Base(path), data) {
+		return nil, errNotFound
+	}
+	return data, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
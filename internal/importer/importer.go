@@ -0,0 +1,48 @@
+// Package importer reads notes written with other tools so they can be
+// saved as entries.
+package importer
+
+import (
+	"time"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// Note is a document read from another tool, ready to become an entry
+type Note struct {
+	Source  string // Where the note came from, such as its path relative to the imported folder
+	Journal string // Journal to import into; empty for the default journal
+	Created time.Time
+	Title   string
+	Tags    []string
+	Body    string
+
+	// Only entries restored from a jot bundle carry these
+	Sensitive bool
+	State     string
+	Checkin   *types.Checkin
+	Bookmark  *types.Bookmark
+	Fields    *types.Fields
+	Rollup    *types.Rollup
+}
+
+// dateLayouts are the timestamp formats accepted in imported notes, most
+// precise first. Layouts without a zone are read in local time.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDate reads a timestamp in any of dateLayouts
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
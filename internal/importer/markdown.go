@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Folder modes decide what the subfolders of an imported Markdown folder mean
+const (
+	FoldersNone     = "none"     // Subfolders are only walked
+	FoldersJournals = "journals" // The top-level folder names the journal
+	FoldersTags     = "tags"     // Every folder on the way becomes a tag
+)
+
+// FolderModes lists the accepted folder modes
+var FolderModes = []string{FoldersNone, FoldersJournals, FoldersTags}
+
+// Markdown reads every .md file below dir, oldest first, as in an Obsidian
+// vault. The timestamp is taken from the front matter's date or created
+// field, a file name starting with a date as daily notes have, or the
+// file's modification time. The title is the front matter's title or the
+// file name, and tags come from its tags field. A journal field in the
+// front matter takes precedence over folders. Hidden files and folders
+// such as .obsidian and empty notes are skipped.
+func Markdown(dir, folders string) ([]Note, error) {
+	var notes []Note
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		note, ok, err := readMarkdown(path, rel, folders)
+		if err != nil {
+			return err
+		}
+		if ok {
+			notes = append(notes, note)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	sort.SliceStable(notes, func(a, b int) bool { return notes[a].Created.Before(notes[b].Created) })
+	return notes, nil
+}
+
+// readMarkdown reads the note at path, reporting false when it is empty
+func readMarkdown(path, rel, folders string) (Note, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Note{}, false, err
+	}
+	fields, body := frontMatter(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return Note{}, false, nil
+	}
+
+	name := strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))
+	note := Note{Source: rel, Title: name, Body: body}
+
+	// A date file name, as daily notes have, is no title
+	if len(name) >= 10 {
+		if t, ok := parseDate(name[:10]); ok {
+			note.Created = t
+			if strings.TrimLeft(name[10:], " -_") == "" {
+				note.Title = ""
+			}
+		}
+	}
+	for _, key := range []string{"date", "created"} {
+		if v := fields[key]; len(v) > 0 {
+			t, ok := parseDate(v[0])
+			if !ok {
+				return Note{}, false, fmt.Errorf("%s: unrecognised %s %q", rel, key, v[0])
+			}
+			note.Created = t
+			break
+		}
+	}
+	if note.Created.IsZero() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return Note{}, false, err
+		}
+		note.Created = info.ModTime()
+	}
+
+	if v := fields["title"]; len(v) > 0 {
+		note.Title = v[0]
+	}
+	for _, v := range fields["tags"] {
+		note.Tags = append(note.Tags, strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })...)
+	}
+
+	dirs := strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/")
+	if dirs[0] == "." {
+		dirs = nil
+	}
+	switch folders {
+	case FoldersJournals:
+		if len(dirs) > 0 {
+			note.Journal = dirs[0]
+		}
+	case FoldersTags:
+		for _, d := range dirs {
+			note.Tags = append(note.Tags, strings.ReplaceAll(d, " ", "-"))
+		}
+	}
+	if v := fields["journal"]; len(v) > 0 {
+		note.Journal = v[0]
+	}
+	return note, true, nil
+}
+
+// frontMatter splits a leading YAML front matter block off text, returning
+// its fields by lowercased key. Only the forms notes commonly use are
+// understood: "key: value", inline lists "[a, b]" and "- item" lines
+// following an empty "key:".
+func frontMatter(text string) (map[string][]string, string) {
+	fields := make(map[string][]string)
+	if !strings.HasPrefix(text, "---\n") {
+		return fields, text
+	}
+	// Searching from the opening line's newline also finds an empty block
+	end := strings.Index(text[3:], "\n---")
+	if end < 0 {
+		return fields, text
+	}
+	block, body := "", text[3+end+4:]
+	if end > 0 {
+		block = text[4 : 3+end]
+	}
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 && strings.TrimSpace(body[:nl]) == "" {
+		body = body[nl+1:]
+	}
+
+	key := ""
+	for _, line := range strings.Split(block, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") && key != "" {
+			fields[key] = append(fields[key], unquote(trimmed[2:]))
+			continue
+		}
+		colon := strings.Index(trimmed, ":")
+		if colon < 0 {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(trimmed[:colon]))
+		value := strings.TrimSpace(trimmed[colon+1:])
+		switch {
+		case value == "":
+			// The values follow as a block list
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(item); item != "" {
+					fields[key] = append(fields[key], item)
+				}
+			}
+		default:
+			fields[key] = []string{unquote(value)}
+		}
+	}
+	return fields, body
+}
+
+// unquote trims spaces and YAML quotes around a value
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// simplenoteExport is notes.json of a Simplenote export
+type simplenoteExport struct {
+	ActiveNotes []simplenoteNote `json:"activeNotes"`
+}
+
+type simplenoteNote struct {
+	ID           string    `json:"id"`
+	Content      string    `json:"content"`
+	CreationDate time.Time `json:"creationDate"`
+	Tags         []string  `json:"tags"`
+}
+
+// Simplenote reads the notes of a Simplenote export, oldest first: the zip
+// archive downloaded from its settings, the folder it was extracted to or
+// the notes.json inside. The first line of a note becomes its title. Notes
+// in the trash and empty notes are skipped.
+func Simplenote(exportPath string) ([]Note, error) {
+	data, err := readExport(exportPath, func(name string, _ []byte) bool {
+		return path.Base(name) == "notes.json"
+	})
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("%s holds no notes.json; is it a Simplenote export?", exportPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", exportPath, err)
+	}
+	var export simplenoteExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to read notes.json: %w", err)
+	}
+
+	var notes []Note
+	for _, n := range export.ActiveNotes {
+		title, body := splitTitle(n.Content)
+		if body == "" {
+			continue
+		}
+		notes = append(notes, Note{
+			Source:  "note " + n.ID,
+			Created: n.CreationDate.Local(),
+			Title:   title,
+			Tags:    n.Tags,
+			Body:    body,
+		})
+	}
+	sort.SliceStable(notes, func(a, b int) bool { return notes[a].Created.Before(notes[b].Created) })
+	return notes, nil
+}
+
+// splitTitle takes the first line of a note whose title is its first line,
+// as in Simplenote, leaving the rest as the body. A note of one line is all
+// body.
+func splitTitle(content string) (string, string) {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	first, rest, found := strings.Cut(content, "\n")
+	rest = strings.TrimSpace(rest)
+	if !found || rest == "" {
+		return "", content
+	}
+	return strings.TrimSpace(strings.TrimLeft(first, "# ")), rest
+}
@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// standardNotesBackup is the decrypted backup file of Standard Notes
+type standardNotesBackup struct {
+	Items []standardNotesItem `json:"items"`
+}
+
+type standardNotesItem struct {
+	UUID        string          `json:"uuid"`
+	ContentType string          `json:"content_type"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Content     json.RawMessage `json:"content"`
+}
+
+// standardNotesContent is the content of a note or tag; encrypted backups
+// hold a string instead
+type standardNotesContent struct {
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	Trashed    bool   `json:"trashed"`
+	References []struct {
+		UUID        string `json:"uuid"`
+		ContentType string `json:"content_type"`
+	} `json:"references"`
+}
+
+// StandardNotes reads the notes of a decrypted Standard Notes backup,
+// oldest first: the backup file, the zip archive holding it or the folder
+// it was extracted to. Tags are those referring to each note. Notes in the
+// trash, empty notes and other items such as editors are skipped.
+func StandardNotes(exportPath string) ([]Note, error) {
+	data, err := readExport(exportPath, func(name string, data []byte) bool {
+		var probe standardNotesBackup
+		return (strings.HasSuffix(name, ".txt") || strings.HasSuffix(name, ".json")) &&
+			json.Unmarshal(data, &probe) == nil && len(probe.Items) > 0
+	})
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("%s holds no backup file; is it a Standard Notes backup?", exportPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", exportPath, err)
+	}
+	var backup standardNotesBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	contents := make(map[string]*standardNotesContent, len(backup.Items))
+	for _, item := range backup.Items {
+		if item.ContentType != "Note" && item.ContentType != "Tag" {
+			continue
+		}
+		var c standardNotesContent
+		if err := json.Unmarshal(item.Content, &c); err != nil {
+			var encrypted string
+			if json.Unmarshal(item.Content, &encrypted) == nil {
+				return nil, errors.New("the backup is encrypted; export a decrypted backup from Standard Notes' Backups settings")
+			}
+			return nil, fmt.Errorf("failed to read item %s: %w", item.UUID, err)
+		}
+		contents[item.UUID] = &c
+	}
+
+	tags := make(map[string][]string)
+	for _, item := range backup.Items {
+		if item.ContentType != "Tag" || contents[item.UUID].Trashed {
+			continue
+		}
+		tag := contents[item.UUID].Title
+		for _, ref := range contents[item.UUID].References {
+			if ref.ContentType == "Note" {
+				tags[ref.UUID] = append(tags[ref.UUID], tag)
+			}
+		}
+	}
+
+	var notes []Note
+	for _, item := range backup.Items {
+		c := contents[item.UUID]
+		if item.ContentType != "Note" || c.Trashed || strings.TrimSpace(c.Text) == "" {
+			continue
+		}
+		notes = append(notes, Note{
+			Source:  "note " + item.UUID,
+			Created: item.CreatedAt.Local(),
+			Title:   strings.TrimSpace(c.Title),
+			Tags:    tags[item.UUID],
+			Body:    strings.TrimSpace(strings.ReplaceAll(c.Text, "\r\n", "\n")),
+		})
+	}
+	sort.SliceStable(notes, func(a, b int) bool { return notes[a].Created.Before(notes[b].Created) })
+	return notes, nil
+}
@@ -1,31 +1,47 @@
 package journal
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/veritome/jot/internal/collection"
 	"github.com/veritome/jot/internal/crypto"
 	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/oplog"
 	"github.com/veritome/jot/internal/types"
 )
 
+// ErrRevisionConflict is returned when a journal was modified by another
+// writer since it was loaded. Callers should reload the journal and retry.
+var ErrRevisionConflict = errors.New("journal was modified concurrently")
+
 // Journal represents a collection of entries
 type Journal struct {
 	*types.Journal
+
+	// expected is set by Expect: changes must then find the journal still
+	// at the revision it was loaded at
+	expected bool
 }
 
 // New creates a new journal with the given name
 func New(name string) (*Journal, error) {
 	// Verify NaCl keys exist
-	keyPair, err := crypto.RestoreNaclFromBackup()
+	if !crypto.HasKey() {
+		return nil, fmt.Errorf("failed to restore NaCl keys: no key pair found")
+	}
+
+	id, err := collection.NewJournalID()
 	if err != nil {
-		return nil, fmt.Errorf("failed to restore NaCl keys: %w", err)
+		return nil, err
 	}
-	defer keyPair.Clear()
 
 	return &Journal{
 		Journal: &types.Journal{
+			ID:       id,
 			Name:     name,
 			Created:  time.Now(),
 			EntryIDs: make([]string, 0),
@@ -45,10 +61,8 @@ func FromType(j *types.Journal) *Journal {
 
 // Delete removes a journal and its associated data
 func (j *Journal) Delete() error {
-	// Load the collection to ensure we're working with the latest state
-	coll, err := collection.Load()
-	if err != nil {
-		return fmt.Errorf("failed to load collection: %w", err)
+	if j.AppendOnly {
+		return fmt.Errorf("journal '%s' is append-only and cannot be deleted", j.Name)
 	}
 
 	// Remove all entries associated with this journal
@@ -64,92 +78,320 @@ func (j *Journal) Delete() error {
 		}
 	}
 
-	// Remove the journal from the collection
-	delete(coll.Journals, j.Name)
+	// Remove the journal from the latest collection state
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		delete(coll.Journals, j.Name)
 
-	// If this was the default journal, clear the default
-	if coll.DefaultJournal == j.Name {
-		coll.DefaultJournal = ""
-	}
-
-	// Save the updated collection
-	if err := coll.Save(); err != nil {
+		// If this was the default journal, clear the default
+		if coll.DefaultJournal == j.ID {
+			coll.DefaultJournal = ""
+		}
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to save collection after journal deletion: %w", err)
 	}
 
 	return nil
 }
 
-// AddEntry adds a new entry to the journal
+// AddEntry adds a new entry to the journal. The entry is appended to the
+// latest stored state of the journal, so entries added concurrently by other
+// processes are kept. Append-only journals, and journals given an Expect,
+// instead fail with ErrRevisionConflict, since the entry was chained to a
+// stale head or the caller wrote it against an older state.
 func (j *Journal) AddEntry(entryID string) error {
-	j.EntryIDs = append(j.EntryIDs, entryID)
-
-	// Load the collection to ensure we update the journal state
-	coll, err := collection.Load()
-	if err != nil {
-		return fmt.Errorf("failed to load collection: %w", err)
-	}
+	return j.AddEntries([]string{entryID})
+}
 
-	// Update the journal in the collection
-	coll.Journals[j.Name] = j.Journal
+// AddEntries adds several new entries to the journal in one collection
+// update, like AddEntry
+func (j *Journal) AddEntries(entryIDs []string) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		if j.AppendOnly || j.expected {
+			if err := j.checkRevision(coll); err != nil {
+				return err
+			}
+		}
+		stored, exists := coll.Journals[j.Name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", j.Name)
+		}
 
-	// Save the updated collection
-	if err := coll.Save(); err != nil {
+		stored.EntryIDs = append(stored.EntryIDs, entryIDs...)
+		stored.ChainHead = j.ChainHead
+		j.Journal = stored
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to save collection after adding entry: %w", err)
 	}
 
 	return nil
 }
 
-// GetEntries returns all entries in the journal
+// GetEntries returns all entries in the journal, oldest first. Backdated
+// entries are ordered by their Created time, not by when they were written.
 func (j *Journal) GetEntries() ([]*entry.Entry, error) {
-	return entry.LoadJournalEntries(j.EntryIDs)
+	var entries []*entry.Entry
+	var err error
+	if j.IsShared() {
+		entries, err = j.sharedEntries()
+	} else {
+		entries, err = entry.LoadJournalEntries(j.EntryIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].Created.Before(entries[b].Created) })
+	return entries, nil
+}
+
+// FindDuplicate returns an entry of the journal with text as its body,
+// created within entry.duplicate_window of created, or nil if there is
+// none. Nothing is decrypted: bodies are compared by their stored digests,
+// and the index narrows down the entries loaded.
+func (j *Journal) FindDuplicate(text string, created time.Time) (*entry.Entry, error) {
+	window := entry.DuplicateWindow()
+	if window <= 0 {
+		return nil, nil
+	}
+	digest, err := entry.BodyDigest(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*entry.Entry
+	if j.IsShared() {
+		entries, err := j.sharedEntries()
+		if err != nil {
+			return nil, err
+		}
+		candidates = entries
+	} else {
+		ids := j.EntryIDs
+		if coll, err := collection.Load(); err == nil && coll.Index != nil && coll.Index.Dates != nil {
+			inRange := coll.IndexedDates(created.Add(-window).Local(), created.Add(window).Local())
+			ids = make([]string, 0, len(inRange))
+			for _, id := range j.EntryIDs {
+				if inRange[id] {
+					ids = append(ids, id)
+				}
+			}
+		}
+		entries, err := entry.ReadJournalEntries(ids)
+		if err != nil {
+			return nil, err
+		}
+		candidates = entries
+	}
+
+	for _, c := range candidates {
+		if c.IsDuplicate(digest, created, window) {
+			return c, nil
+		}
+	}
+	return nil, nil
 }
 
 // Describe returns journal metadata
 func (j *Journal) Describe() string {
-	return fmt.Sprintf("Journal: %s\nCreated: %s\nEntries: %d",
+	count := len(j.EntryIDs)
+	if j.IsShared() {
+		if entries, err := j.sharedEntries(); err == nil {
+			count = len(entries)
+		}
+	}
+	desc := fmt.Sprintf("Journal: %s\nID: %s\nCreated: %s\nEntries: %d\nRevision: %d",
 		j.Name,
+		j.ID,
 		j.Created.Format(time.RFC3339),
-		len(j.EntryIDs))
+		count,
+		j.Revision)
+	if j.AppendOnly {
+		desc += fmt.Sprintf("\nAppend-only: yes\nChain head: %s", j.ChainHead)
+	}
+	if j.IsShared() {
+		desc += fmt.Sprintf("\nShared folder: %s", j.SharedDir)
+		names := make([]string, 0, len(j.Members))
+		for _, member := range j.Members {
+			names = append(names, member.Name)
+		}
+		desc += fmt.Sprintf("\nMembers: %s", strings.Join(names, ", "))
+	}
+	return desc
 }
 
-// RemoveEntry removes an entry from the journal
-func (j *Journal) RemoveEntry(entryID string) error {
-	// Find and remove the entry ID from the journal's EntryIDs
-	found := false
-	newEntryIDs := make([]string, 0, len(j.EntryIDs))
-	for _, id := range j.EntryIDs {
-		if id == entryID {
-			found = true
-			continue
-		}
-		newEntryIDs = append(newEntryIDs, id)
+// Chain links e into the journal's hash chain when the journal is
+// append-only. It must be called before the entry is saved and added.
+func (j *Journal) Chain(e *entry.Entry) {
+	if !j.AppendOnly {
+		return
 	}
+	e.Seal(j.ChainHead)
+	j.ChainHead = e.Hash
+}
 
-	if !found {
-		return fmt.Errorf("entry %s not found in journal", entryID)
+// SaveEntry chains a freshly created entry (for append-only journals),
+// saves it and adds it to the journal. Entries of a shared journal are
+// only saved to its folder, which is what lists them.
+func (j *Journal) SaveEntry(e *entry.Entry) error {
+	j.Chain(e)
+
+	if err := e.Save(); err != nil {
+		return fmt.Errorf("failed to save entry: %w", err)
+	}
+	if j.IsShared() {
+		return nil
 	}
+	return j.AddEntry(e.ID)
+}
 
-	j.EntryIDs = newEntryIDs
+// SaveBatch chains, saves and adds every entry of b like SaveEntry, with a
+// single update of the collection for the whole batch
+func (j *Journal) SaveBatch(b *entry.Batch) error {
+	ids := make([]string, 0, len(b.Entries))
+	for _, e := range b.Entries {
+		j.Chain(e)
+		ids = append(ids, e.ID)
+	}
 
-	// Load the collection to ensure we update the journal state
-	coll, err := collection.Load()
+	if err := b.Save(); err != nil {
+		return fmt.Errorf("failed to save entries: %w", err)
+	}
+	if j.IsShared() || len(ids) == 0 {
+		return nil
+	}
+	return j.AddEntries(ids)
+}
+
+// Verify checks the hash chain of an append-only journal, returning an error
+// describing the first entry that was altered, removed or reordered.
+func (j *Journal) Verify() error {
+	if !j.AppendOnly {
+		return fmt.Errorf("journal '%s' is not append-only", j.Name)
+	}
+
+	// The chain follows the order entries were added in
+	entries, err := entry.ReadJournalEntries(j.EntryIDs)
 	if err != nil {
-		return fmt.Errorf("failed to load collection: %w", err)
+		return err
+	}
+
+	prev := ""
+	for _, e := range entries {
+		if e.PrevHash != prev {
+			return fmt.Errorf("entry %s does not follow the previous entry in the chain", e.ID)
+		}
+		if !e.VerifyHash() {
+			return fmt.Errorf("entry %s has been modified", e.ID)
+		}
+		prev = e.Hash
+	}
+
+	if prev != j.ChainHead {
+		return fmt.Errorf("chain ends at %q but the journal records head %q", prev, j.ChainHead)
+	}
+
+	return nil
+}
+
+// checkRevision verifies that the stored copy of the journal has not moved
+// past the revision this instance was loaded at.
+func (j *Journal) checkRevision(coll *collection.Collection) error {
+	stored, exists := coll.Journals[j.Name]
+	if !exists {
+		return fmt.Errorf("journal '%s' does not exist", j.Name)
+	}
+	if stored.Revision != j.Revision {
+		return fmt.Errorf("%w: '%s' is at revision %d, expected %d",
+			ErrRevisionConflict, j.Name, stored.Revision, j.Revision)
+	}
+	return nil
+}
+
+// Expect fails with ErrRevisionConflict unless the journal is at revision,
+// and makes the changes that follow fail the same way if another writer
+// moves it on before they are saved
+func (j *Journal) Expect(revision int64) error {
+	if j.Revision != revision {
+		return fmt.Errorf("%w: '%s' is at revision %d, expected %d",
+			ErrRevisionConflict, j.Name, j.Revision, revision)
+	}
+	j.expected = true
+	return nil
+}
+
+// RemoveEntry removes an entry from the latest stored state of the journal.
+// Shared journals list whatever is in their folder, so there is nothing to
+// remove once the entry is deleted.
+func (j *Journal) RemoveEntry(entryID string) error {
+	if j.AppendOnly {
+		return fmt.Errorf("journal '%s' is append-only; entries cannot be removed", j.Name)
+	}
+	if j.IsShared() {
+		return nil
 	}
 
-	// Update the journal in the collection
-	coll.Journals[j.Name] = j.Journal
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		if j.expected {
+			if err := j.checkRevision(coll); err != nil {
+				return err
+			}
+		}
+		stored, exists := coll.Journals[j.Name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", j.Name)
+		}
+
+		// Find and remove the entry ID from the journal's EntryIDs
+		found := false
+		newEntryIDs := make([]string, 0, len(stored.EntryIDs))
+		for _, id := range stored.EntryIDs {
+			if id == entryID {
+				found = true
+				continue
+			}
+			newEntryIDs = append(newEntryIDs, id)
+		}
 
-	// Save the updated collection
-	if err := coll.Save(); err != nil {
+		if !found {
+			return fmt.Errorf("entry %s not found in journal", entryID)
+		}
+
+		stored.EntryIDs = newEntryIDs
+		j.Journal = stored
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to save collection after removing entry: %w", err)
 	}
 
 	return nil
 }
 
+// DeleteEntry deletes an entry of the journal and removes it from the
+// journal, recording the deletion so jot undo can bring the entry back.
+// Entries of shared journals live in their folder and are deleted for good.
+func (j *Journal) DeleteEntry(e *entry.Entry) error {
+	if j.AppendOnly {
+		return fmt.Errorf("journal '%s' is append-only; entries cannot be removed", j.Name)
+	}
+	if j.IsShared() {
+		return e.Delete()
+	}
+
+	op, err := oplog.RecordEntryDelete(j.Name, j.ID, e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record deletion: %w", err)
+	}
+	if err := e.Delete(); err != nil {
+		op.Drop()
+		return err
+	}
+	return j.RemoveEntry(e.ID)
+}
+
 // LoadAllJournals returns all journals from the collection
 func LoadAllJournals() ([]*Journal, error) {
 	coll, err := collection.Load()
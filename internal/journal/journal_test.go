@@ -0,0 +1,137 @@
+package journal_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/pkg/jottest"
+)
+
+// appendOnly creates an append-only journal holding an entry for each of
+// texts and returns the IDs of the entries
+func appendOnly(t *testing.T, name string, texts ...string) []string {
+	t.Helper()
+	j, err := journal.New(name)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	j.AppendOnly = true
+	coll, err := collection.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := coll.AddJournal(j.AsType()); err != nil {
+		t.Fatalf("AddJournal: %v", err)
+	}
+
+	var ids []string
+	for _, text := range texts {
+		e, err := j.NewEntry(text)
+		if err != nil {
+			t.Fatalf("NewEntry: %v", err)
+		}
+		if err := j.SaveEntry(e); err != nil {
+			t.Fatalf("SaveEntry: %v", err)
+		}
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func verify(t *testing.T, name string) error {
+	t.Helper()
+	coll, err := collection.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return journal.FromType(coll.Journals[name]).Verify()
+}
+
+// rewrite changes a stored field of an entry behind jot's back
+func rewrite(t *testing.T, id, field string, value interface{}) {
+	t.Helper()
+	path := filepath.Join(jottest.DataDir, "entries", id+".json")
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	fields[field] = value
+	if data, err = json.Marshal(fields); err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	jottest.New(t)
+	appendOnly(t, "log", "first", "second", "third")
+	if err := verify(t, "log"); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestVerifyDetectsChangedEntry(t *testing.T) {
+	jottest.New(t)
+	ids := appendOnly(t, "log", "first", "second", "third")
+	rewrite(t, ids[1], "title", "Added later")
+
+	err := verify(t, "log")
+	if err == nil || !strings.Contains(err.Error(), ids[1]) {
+		t.Errorf("Verify error = %v, want entry %s reported as modified", err, ids[1])
+	}
+}
+
+func TestVerifyDetectsBrokenLink(t *testing.T) {
+	jottest.New(t)
+	ids := appendOnly(t, "log", "first", "second")
+	rewrite(t, ids[1], "prev_hash", "")
+
+	if err := verify(t, "log"); err == nil {
+		t.Error("Verify accepted an entry no longer linked to the one before it")
+	}
+}
+
+func TestAppendOnlyRefusesStaleWriter(t *testing.T) {
+	jottest.New(t)
+	appendOnly(t, "log", "first")
+	coll, err := collection.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	stale := journal.FromType(coll.Journals["log"])
+
+	coll, err = collection.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	current := journal.FromType(coll.Journals["log"])
+	e, err := current.NewEntry("second")
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+	if err := current.SaveEntry(e); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+
+	e, err = stale.NewEntry("chained to an old head")
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+	if err := stale.SaveEntry(e); err == nil {
+		t.Error("SaveEntry chained an entry to a stale head")
+	}
+	if err := verify(t, "log"); err != nil {
+		t.Errorf("Verify after the refused entry: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package journal
+
+import "sync"
+
+// Locks hands out one read/write lock per journal name so that a
+// long-running process can serialize mutations of a journal while still
+// allowing any number of concurrent readers.
+type Locks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.RWMutex
+}
+
+// NewLocks creates an empty lock registry
+func NewLocks() *Locks {
+	return &Locks{locks: make(map[string]*sync.RWMutex)}
+}
+
+// For returns the lock guarding the named journal, creating it on first use
+func (l *Locks) For(name string) *sync.RWMutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, exists := l.locks[name]
+	if !exists {
+		lock = &sync.RWMutex{}
+		l.locks[name] = lock
+	}
+	return lock
+}
@@ -0,0 +1,148 @@
+package journal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/entry"
+)
+
+// MergeInto moves every entry of the journal into dest, keeping entries in
+// chronological order, and deletes the emptied journal. It returns the
+// number of entries moved. Append-only journals cannot be merged, since
+// their hash chains cover each entry's journal, and neither can shared
+// journals, whose entries live in their folder.
+func (j *Journal) MergeInto(dest string) (int, error) {
+	if j.Name == dest {
+		return 0, fmt.Errorf("cannot merge journal '%s' into itself", dest)
+	}
+
+	var moved []string
+	var destID string
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		source, exists := coll.Journals[j.Name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", j.Name)
+		}
+		target, exists := coll.Journals[dest]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", dest)
+		}
+		for _, name := range []string{j.Name, dest} {
+			if coll.Journals[name].AppendOnly {
+				return fmt.Errorf("journal '%s' is append-only and cannot be merged", name)
+			}
+			if coll.Journals[name].SharedDir != "" {
+				return fmt.Errorf("journal '%s' is shared and cannot be merged", name)
+			}
+		}
+
+		moved = source.EntryIDs
+		target.EntryIDs = sortByCreated(append(target.EntryIDs, source.EntryIDs...))
+		delete(coll.Journals, j.Name)
+		if coll.DefaultJournal == source.ID {
+			coll.DefaultJournal = target.ID
+		}
+		destID = target.ID
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge journal: %w", err)
+	}
+
+	// The collection now lists the entries under dest; an interruption here
+	// leaves entries that jot doctor --fix reassigns
+	for _, id := range moved {
+		e, err := entry.Load(id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load entry %s: %w", id, err)
+		}
+		e.JournalID = destID
+		if err := e.Save(); err != nil {
+			return 0, fmt.Errorf("failed to save entry %s: %w", id, err)
+		}
+	}
+
+	return len(moved), nil
+}
+
+// MoveEntry moves one entry of the journal into dest, in chronological
+// order among dest's entries. Like MergeInto it refuses append-only and
+// shared journals on either side.
+func (j *Journal) MoveEntry(entryID, dest string) error {
+	if j.Name == dest {
+		return fmt.Errorf("entry %s is already in journal '%s'", entryID, dest)
+	}
+
+	var destID string
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		source, exists := coll.Journals[j.Name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", j.Name)
+		}
+		target, exists := coll.Journals[dest]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", dest)
+		}
+		for _, name := range []string{j.Name, dest} {
+			if coll.Journals[name].AppendOnly {
+				return fmt.Errorf("journal '%s' is append-only; entries cannot be moved", name)
+			}
+			if coll.Journals[name].SharedDir != "" {
+				return fmt.Errorf("journal '%s' is shared; entries cannot be moved", name)
+			}
+		}
+
+		remaining := make([]string, 0, len(source.EntryIDs))
+		for _, id := range source.EntryIDs {
+			if id != entryID {
+				remaining = append(remaining, id)
+			}
+		}
+		if len(remaining) == len(source.EntryIDs) {
+			return fmt.Errorf("entry %s not found in journal", entryID)
+		}
+
+		source.EntryIDs = remaining
+		target.EntryIDs = sortByCreated(append(target.EntryIDs, entryID))
+		j.Journal = source
+		destID = target.ID
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move entry: %w", err)
+	}
+
+	// As in MergeInto, jot doctor --fix reassigns the entry if this fails
+	e, err := entry.Load(entryID)
+	if err != nil {
+		return fmt.Errorf("failed to load entry %s: %w", entryID, err)
+	}
+	e.JournalID = destID
+	if err := e.Save(); err != nil {
+		return fmt.Errorf("failed to save entry %s: %w", entryID, err)
+	}
+	return nil
+}
+
+// sortByCreated orders entry IDs by creation time. Entries that cannot be
+// loaded keep their relative position at the end.
+func sortByCreated(ids []string) []string {
+	created := make(map[string]time.Time, len(ids))
+	for _, id := range ids {
+		if e, err := entry.Load(id); err == nil {
+			created[id] = e.Created
+		}
+	}
+	sort.SliceStable(ids, func(a, b int) bool {
+		ta, okA := created[ids[a]]
+		tb, okB := created[ids[b]]
+		if okA != okB {
+			return okA
+		}
+		return ta.Before(tb)
+	})
+	return ids
+}
@@ -0,0 +1,512 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+// manifestFile describes a shared journal in its folder
+const manifestFile = "jot-shared.json"
+
+// ErrNotMember is returned when the user's public key is not among the
+// members of a shared journal
+var ErrNotMember = errors.New("your public key is not a member of this shared journal")
+
+// ErrMembersChanged is returned when writing to a shared journal whose
+// members differ from those last confirmed in this data directory.
+// Anyone who can write to the folder can change the manifest, so new
+// entries are only encrypted to confirmed members.
+var ErrMembersChanged = errors.New("the members of this shared journal changed since you last confirmed them; review them with jot journal members")
+
+// Manifest is the description of a shared journal kept in its folder, so
+// every member sees the same ID and the same list of members. The member
+// who last changed it signs it.
+type Manifest struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"` // Name given by the member who created it
+	Created   time.Time          `json:"created"`
+	Members   []crypto.Recipient `json:"members"`
+	SignedBy  string             `json:"signed_by,omitempty"`
+	Signature string             `json:"signature,omitempty"`
+}
+
+// signed returns what the signature of the manifest covers: all of it but
+// the signature
+func (m *Manifest) signed() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal shared journal: %w", err)
+	}
+	return data, nil
+}
+
+// sign signs the manifest as the member self
+func (m *Manifest) sign(self string, key *crypto.KeyPair) error {
+	m.SignedBy = self
+	data, err := m.signed()
+	if err != nil {
+		return err
+	}
+	m.Signature, err = key.Sign(data)
+	return err
+}
+
+// Signer returns the member among members who signed the manifest, or
+// nil when none of them did
+func (m *Manifest) Signer(members []types.Member) *types.Member {
+	data, err := m.signed()
+	if err != nil {
+		return nil
+	}
+	for i, member := range members {
+		if member.Name == m.SignedBy && member.SigningKey != "" &&
+			crypto.VerifySignature(member.SigningKey, data, m.Signature) {
+			return &members[i]
+		}
+	}
+	return nil
+}
+
+// pinned returns the members of the manifest as they are pinned
+func (m *Manifest) pinned() []types.Member {
+	members := make([]types.Member, 0, len(m.Members))
+	for _, r := range m.Members {
+		members = append(members, types.Member{Name: r.Name, PublicKey: r.PublicKey, SigningKey: r.SigningKey})
+	}
+	return members
+}
+
+// NewShared creates a journal whose entries are stored in dir, which must
+// not exist yet or be empty, with the user as its only member
+func NewShared(name, dir, member string) (*Journal, error) {
+	j, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	if j.SharedDir, err = filepath.Abs(dir); err != nil {
+		return nil, fmt.Errorf("failed to resolve shared folder: %w", err)
+	}
+
+	files, err := storage.ReadDir(j.SharedDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read shared folder: %w", err)
+	}
+	if len(files) > 0 {
+		return nil, fmt.Errorf("shared folder %s is not empty", j.SharedDir)
+	}
+
+	own, err := ownPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	signer, signingKey, err := ownSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		ID:      j.ID,
+		Name:    name,
+		Created: j.Created,
+		Members: []crypto.Recipient{{Name: member, PublicKey: own, SigningKey: signingKey, Added: j.Created}},
+	}
+	if err := m.sign(member, signer); err != nil {
+		return nil, err
+	}
+	if err := storage.MkdirAll(filepath.Join(j.SharedDir, "entries"), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create shared folder: %w", err)
+	}
+	if err := writeManifest(j.SharedDir, m); err != nil {
+		return nil, err
+	}
+	j.Members = m.pinned()
+	return j, nil
+}
+
+// JoinShared returns the shared journal in dir under the local name, with
+// the members of its manifest pinned: the caller has them confirmed first.
+// The user must already have been added as a member.
+func JoinShared(name, dir string) (*Journal, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve shared folder: %w", err)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := self(m); err != nil {
+		return nil, err
+	}
+
+	return &Journal{
+		Journal: &types.Journal{
+			ID:        m.ID,
+			Name:      name,
+			Created:   m.Created,
+			EntryIDs:  make([]string, 0),
+			SharedDir: dir,
+			Members:   m.pinned(),
+		},
+	}, nil
+}
+
+// ReadManifest reads the description of the shared journal in dir
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := storage.ReadFile(filepath.Join(dir, manifestFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s is not a shared journal folder", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared journal: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shared journal: %w", err)
+	}
+	return &m, nil
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal shared journal: %w", err)
+	}
+	if err := storage.WriteFile(filepath.Join(dir, manifestFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write shared journal: %w", err)
+	}
+	return nil
+}
+
+// IsShared reports whether the journal's entries live in a shared folder
+func (j *Journal) IsShared() bool {
+	return j.SharedDir != ""
+}
+
+// Manifest reads the description of a shared journal from its folder
+func (j *Journal) Manifest() (*Manifest, error) {
+	if !j.IsShared() {
+		return nil, fmt.Errorf("journal '%s' is not shared", j.Name)
+	}
+	return ReadManifest(j.SharedDir)
+}
+
+// AddMember adds a named public key, with the key the member signs with,
+// to the members of a shared journal. Entries written from then on can be
+// read with it. Adding a member recorded without a signing key again
+// records it.
+func (j *Journal) AddMember(name, publicKey, signingKey string) error {
+	m, err := j.confirmedManifest()
+	if err != nil {
+		return err
+	}
+	added := crypto.Recipient{Name: name, PublicKey: publicKey, SigningKey: signingKey, Added: time.Now()}
+	if _, err := added.Key(); err != nil {
+		return err
+	}
+	if err := crypto.CheckSigningKey(signingKey); err != nil {
+		return err
+	}
+
+	found := false
+	for i, member := range m.Members {
+		if member.Name == name && member.PublicKey == publicKey && member.SigningKey == "" {
+			m.Members[i].SigningKey, found = signingKey, true
+			continue
+		}
+		if member.Name == name {
+			return fmt.Errorf("member '%s' already exists", name)
+		}
+		if member.PublicKey == publicKey {
+			return fmt.Errorf("key is already a member as '%s'", member.Name)
+		}
+	}
+	if !found {
+		m.Members = append(m.Members, added)
+	}
+	return j.saveManifest(m)
+}
+
+// Reshare re-encrypts every entry of a shared journal the user can read to
+// the current members, so a new member can read what was written before
+// they joined. It returns the number of entries re-encrypted and of those
+// the user cannot read, which are left as they are.
+func (j *Journal) Reshare() (updated, unreadable int, err error) {
+	if _, err := j.confirmedManifest(); err != nil {
+		return 0, 0, err
+	}
+	entries, err := j.sharedEntries()
+	if err != nil {
+		return 0, 0, err
+	}
+	keys, err := crypto.Keyring()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to restore NaCl keys: %w", err)
+	}
+	defer crypto.ClearAll(keys)
+
+	for _, e := range entries {
+		if err := e.Reshare(keys); err != nil {
+			unreadable++
+			continue
+		}
+		if err := e.Save(); err != nil {
+			return updated, unreadable, err
+		}
+		updated++
+	}
+	return updated, unreadable, nil
+}
+
+// RemoveMember stops encrypting new entries of a shared journal to the
+// named member. Entries written before stay readable with their key.
+func (j *Journal) RemoveMember(name string) error {
+	m, err := j.confirmedManifest()
+	if err != nil {
+		return err
+	}
+	kept := make([]crypto.Recipient, 0, len(m.Members))
+	for _, member := range m.Members {
+		if member.Name != name {
+			kept = append(kept, member)
+		}
+	}
+	if len(kept) == len(m.Members) {
+		return fmt.Errorf("member '%s' does not exist", name)
+	}
+	if len(kept) == 0 {
+		return fmt.Errorf("'%s' is the last member of the journal", name)
+	}
+	m.Members = kept
+	return j.saveManifest(m)
+}
+
+// saveManifest signs the manifest changed by the user, writes it and pins
+// its members
+func (j *Journal) saveManifest(m *Manifest) error {
+	self, err := self(m)
+	if err != nil {
+		return err
+	}
+	signer, _, err := ownSigningKey()
+	if err != nil {
+		return err
+	}
+	if err := m.sign(self, signer); err != nil {
+		return err
+	}
+	if err := writeManifest(j.SharedDir, m); err != nil {
+		return err
+	}
+	return j.pin(m.pinned())
+}
+
+// MemberChanges compares the members of the manifest with those confirmed
+// in this data directory, returning the manifest and the members added to
+// and removed from it since. A member whose keys changed is both.
+func (j *Journal) MemberChanges() (m *Manifest, added, removed []types.Member, err error) {
+	if m, err = j.Manifest(); err != nil {
+		return nil, nil, nil, err
+	}
+	current := m.pinned()
+	return m, missing(current, j.Members), missing(j.Members, current), nil
+}
+
+// missing returns the members of a that b lacks
+func missing(a, b []types.Member) []types.Member {
+	var out []types.Member
+	for _, member := range a {
+		found := false
+		for _, other := range b {
+			found = found || member == other
+		}
+		if !found {
+			out = append(out, member)
+		}
+	}
+	return out
+}
+
+// AcceptMembers pins the members of m, as read by MemberChanges, once the
+// user confirmed them
+func (j *Journal) AcceptMembers(m *Manifest) error {
+	return j.pin(m.pinned())
+}
+
+// pin records members as the confirmed members of the journal
+func (j *Journal) pin(members []types.Member) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		stored, ok := coll.JournalByID(j.ID)
+		if !ok {
+			return fmt.Errorf("journal '%s' does not exist", j.Name)
+		}
+		stored.Members = members
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save members: %w", err)
+	}
+	j.Members = members
+	return nil
+}
+
+// confirmedManifest reads the manifest, failing with ErrMembersChanged
+// unless its members are the confirmed ones
+func (j *Journal) confirmedManifest() (*Manifest, error) {
+	m, added, removed, err := j.MemberChanges()
+	if err != nil {
+		return nil, err
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		return nil, ErrMembersChanged
+	}
+	return m, nil
+}
+
+// NewEntry creates an entry with the given text. Entries of a shared
+// journal are encrypted to every current member and attributed to the
+// user's member name.
+func (j *Journal) NewEntry(text string) (*entry.Entry, error) {
+	if !j.IsShared() {
+		return entry.New(j.ID, text)
+	}
+	s, err := j.writableStore()
+	if err != nil {
+		return nil, err
+	}
+	return entry.NewShared(s, j.ID, s.Self, text)
+}
+
+// NewBatch creates an entry of the journal for each of texts; see
+// entry.Batch. Close must be called on the batch once it is saved.
+func (j *Journal) NewBatch(texts []string) (*entry.Batch, error) {
+	if !j.IsShared() {
+		return entry.NewBatch(j.ID, texts)
+	}
+	s, err := j.writableStore()
+	if err != nil {
+		return nil, err
+	}
+	return entry.NewSharedBatch(s, j.ID, s.Self, texts)
+}
+
+// LoadEntry loads an entry of the journal by its ID, from the journal's
+// folder when it is shared. The caller checks that the entry belongs to
+// the journal.
+func (j *Journal) LoadEntry(id string) (*entry.Entry, error) {
+	if !j.IsShared() {
+		return entry.Load(id)
+	}
+	s, err := j.sharedStore()
+	if err != nil {
+		return nil, err
+	}
+	return entry.LoadSharedEntry(s, id)
+}
+
+// sharedEntries loads the entries in the folder of a shared journal
+func (j *Journal) sharedEntries() ([]*entry.Entry, error) {
+	s, err := j.sharedStore()
+	if err != nil {
+		return nil, err
+	}
+	return entry.LoadShared(s)
+}
+
+// sharedStore returns the folder of a shared journal with the keys of its
+// confirmed members, for reading
+func (j *Journal) sharedStore() (*entry.Shared, error) {
+	s := &entry.Shared{Dir: j.SharedDir, Signers: make(map[string]string)}
+	for _, member := range j.Members {
+		key, err := (crypto.Recipient{PublicKey: member.PublicKey}).Key()
+		if err != nil {
+			return nil, fmt.Errorf("member '%s': %w", member.Name, err)
+		}
+		s.Members = append(s.Members, key)
+		if member.SigningKey != "" {
+			s.Signers[member.Name] = member.SigningKey
+		}
+	}
+	return s, nil
+}
+
+// writableStore returns the store of sharedStore set to sign as the user,
+// once the members of the manifest are the confirmed ones
+func (j *Journal) writableStore() (*entry.Shared, error) {
+	m, err := j.confirmedManifest()
+	if err != nil {
+		return nil, err
+	}
+	s, err := j.sharedStore()
+	if err != nil {
+		return nil, err
+	}
+	if s.Self, err = self(m); err != nil {
+		return nil, err
+	}
+	if s.Signer, _, err = ownSigningKey(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// self returns the member name of the user's public key
+func self(m *Manifest) (string, error) {
+	own, err := ownPublicKey()
+	if err != nil {
+		return "", err
+	}
+	for _, member := range m.Members {
+		if member.PublicKey == own {
+			return member.Name, nil
+		}
+	}
+	return "", ErrNotMember
+}
+
+// ownSigningKey returns the key pair the user signs with in shared
+// journals and the public half of its signing key
+func ownSigningKey() (*crypto.KeyPair, string, error) {
+	keyPair, err := crypto.RestoreNaclFromBackup()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to restore NaCl keys: %w", err)
+	}
+	public, err := keyPair.SigningKeyString()
+	if err != nil {
+		return nil, "", err
+	}
+	return keyPair, public, nil
+}
+
+// OwnMemberKeys returns the user's public key and signing key, which a
+// member adds to a shared journal
+func OwnMemberKeys() (publicKey, signingKey string, err error) {
+	if publicKey, err = ownPublicKey(); err != nil {
+		return "", "", err
+	}
+	if _, signingKey, err = ownSigningKey(); err != nil {
+		return "", "", err
+	}
+	return publicKey, signingKey, nil
+}
+
+// ownPublicKey returns the user's current public key as shared with others
+func ownPublicKey() (string, error) {
+	keyPair, err := crypto.RestorePublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to restore NaCl keys: %w", err)
+	}
+	defer keyPair.Clear()
+	return keyPair.PublicKeyString(), nil
+}
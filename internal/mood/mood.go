@@ -0,0 +1,183 @@
+// Package mood checks the mood, energy, weather and location fields of
+// entries, filters entries by them and summarizes them per month.
+package mood
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// Scores run from Min to Max
+const (
+	Min = 1
+	Max = 10
+)
+
+// emoji maps the moods that may be given as an emoji to their score
+var emoji = map[string]int{
+	"😭": 1, "😡": 2, "😢": 2, "😞": 3, "😟": 3, "😕": 4, "😴": 4,
+	"😐": 5, "🙂": 6, "😌": 7, "😊": 7, "😀": 8, "😄": 8, "😁": 9, "🥰": 9, "🤩": 10,
+}
+
+// Emoji returns the emoji accepted as moods, from the lowest score up
+func Emoji() string {
+	faces := make([]string, 0, len(emoji))
+	for face := range emoji {
+		faces = append(faces, face)
+	}
+	sort.Slice(faces, func(a, b int) bool {
+		if emoji[faces[a]] != emoji[faces[b]] {
+			return emoji[faces[a]] < emoji[faces[b]]
+		}
+		return faces[a] < faces[b]
+	})
+	return strings.Join(faces, " ")
+}
+
+// ParseMood checks a mood given as a score or an emoji, returning its
+// stored form
+func ParseMood(s string) (string, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\ufe0f", "")
+	if _, ok := emoji[s]; ok {
+		return s, nil
+	}
+	n, err := parseScore(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid mood '%s': use %d to %d or one of %s", s, Min, Max, Emoji())
+	}
+	return strconv.Itoa(n), nil
+}
+
+// ParseEnergy checks an energy level
+func ParseEnergy(s string) (int, error) {
+	n, err := parseScore(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid energy '%s': use %d to %d", s, Min, Max)
+	}
+	return n, nil
+}
+
+func parseScore(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < Min || n > Max {
+		return 0, fmt.Errorf("not a score")
+	}
+	return n, nil
+}
+
+// Score returns the score of a stored mood, reporting false when there is
+// none
+func Score(mood string) (float64, bool) {
+	if n, ok := emoji[mood]; ok {
+		return float64(n), true
+	}
+	n, err := strconv.Atoi(mood)
+	if err != nil {
+		return 0, false
+	}
+	return float64(n), true
+}
+
+// Range is an inclusive range of scores
+type Range struct {
+	From, To int
+}
+
+// ParseRange parses a score range written as 7, 6-8 or 6+. A mood filter
+// also takes an emoji, standing for its score.
+func ParseRange(s string) (Range, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\ufe0f", "")
+	if n, ok := emoji[s]; ok {
+		return Range{n, n}, nil
+	}
+	invalid := fmt.Errorf("invalid range '%s': use a score such as 7, a range such as 6-8 or a minimum such as 6+", s)
+	if strings.HasSuffix(s, "+") {
+		n, err := parseScore(strings.TrimSuffix(s, "+"))
+		if err != nil {
+			return Range{}, invalid
+		}
+		return Range{n, Max}, nil
+	}
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		to = from
+	}
+	a, err := parseScore(from)
+	if err != nil {
+		return Range{}, invalid
+	}
+	b, err := parseScore(to)
+	if err != nil || b < a {
+		return Range{}, invalid
+	}
+	return Range{a, b}, nil
+}
+
+// Contains reports whether score lies in the range
+func (r Range) Contains(score float64) bool {
+	return score >= float64(r.From) && score <= float64(r.To)
+}
+
+// Filter selects entries by their fields. Unset conditions match every
+// entry; weather and location match when they contain the text, ignoring
+// case.
+type Filter struct {
+	Mood     *Range
+	Energy   *Range
+	Weather  string
+	Location string
+}
+
+// Empty reports whether the filter lets every entry through
+func (f Filter) Empty() bool {
+	return f.Mood == nil && f.Energy == nil && f.Weather == "" && f.Location == ""
+}
+
+// Match reports whether entry fields, nil when the entry has none, pass
+// the filter
+func (f Filter) Match(fields *types.Fields) bool {
+	if f.Empty() {
+		return true
+	}
+	if fields == nil {
+		return false
+	}
+	if f.Mood != nil {
+		score, ok := Score(fields.Mood)
+		if !ok || !f.Mood.Contains(score) {
+			return false
+		}
+	}
+	if f.Energy != nil && (fields.Energy == 0 || !f.Energy.Contains(float64(fields.Energy))) {
+		return false
+	}
+	return contains(fields.Weather, f.Weather) && contains(fields.Location, f.Location)
+}
+
+func contains(value, text string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(strings.TrimSpace(text)))
+}
+
+// Format renders fields on one line, such as "mood 7, energy 5, rain, Lisbon"
+func Format(f *types.Fields) string {
+	if f == nil {
+		return ""
+	}
+	var parts []string
+	if f.Mood != "" {
+		parts = append(parts, "mood "+f.Mood)
+	}
+	if f.Energy != 0 {
+		parts = append(parts, "energy "+strconv.Itoa(f.Energy))
+	}
+	for _, s := range []string{f.Weather, f.Location} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,114 @@
+package mood
+
+import (
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/types"
+)
+
+// Record is the fields of an entry and when it was written
+type Record struct {
+	Created time.Time
+	Fields  *types.Fields // nil when the entry has none
+	Done    *time.Time    // When the entry, a task, was completed
+}
+
+// Month summarizes the entries of one calendar month
+type Month struct {
+	Start      time.Time // Midnight on the 1st
+	Entries    int
+	Moods      int     // Entries with a mood
+	MoodMean   float64 // Mean mood score, when Moods > 0
+	Energies   int     // Entries with an energy level
+	EnergyMean float64 // Mean energy, when Energies > 0
+	Weather    string  // Most common weather, empty when none was given
+	Location   string  // Most common location, empty when none was given
+	TasksDone  int     // Tasks completed in the month, whenever written
+}
+
+// Monthly summarizes records per month in the location of now, for the
+// last months months ending with the current one. Months without entries
+// or completed tasks are left out.
+func Monthly(records []Record, now time.Time, months int) []*Month {
+	loc := now.Location()
+	first := time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, loc)
+
+	byStart := make(map[time.Time]*Month)
+	weather := make(map[time.Time]map[string]int)
+	location := make(map[time.Time]map[string]int)
+	// month returns the summary of the month of t, nil when out of range
+	month := func(t time.Time) *Month {
+		t = t.In(loc)
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		if start.Before(first) || t.After(now) {
+			return nil
+		}
+		m, exists := byStart[start]
+		if !exists {
+			m = &Month{Start: start}
+			byStart[start] = m
+			weather[start] = make(map[string]int)
+			location[start] = make(map[string]int)
+		}
+		return m
+	}
+	for _, r := range records {
+		if r.Done != nil {
+			if m := month(*r.Done); m != nil {
+				m.TasksDone++
+			}
+		}
+		m := month(r.Created)
+		if m == nil {
+			continue
+		}
+		start := m.Start
+		m.Entries++
+		f := r.Fields
+		if f == nil {
+			continue
+		}
+		if score, ok := Score(f.Mood); ok {
+			m.MoodMean += score
+			m.Moods++
+		}
+		if f.Energy != 0 {
+			m.EnergyMean += float64(f.Energy)
+			m.Energies++
+		}
+		if f.Weather != "" {
+			weather[start][f.Weather]++
+		}
+		if f.Location != "" {
+			location[start][f.Location]++
+		}
+	}
+
+	out := make([]*Month, 0, len(byStart))
+	for start, m := range byStart {
+		if m.Moods > 0 {
+			m.MoodMean /= float64(m.Moods)
+		}
+		if m.Energies > 0 {
+			m.EnergyMean /= float64(m.Energies)
+		}
+		m.Weather = mostCommon(weather[start])
+		m.Location = mostCommon(location[start])
+		out = append(out, m)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Start.Before(out[b].Start) })
+	return out
+}
+
+// mostCommon returns the value counted most often, the first in sort order
+// on a tie
+func mostCommon(counts map[string]int) string {
+	best := ""
+	for value, n := range counts {
+		if n > counts[best] || (n == counts[best] && value < best) {
+			best = value
+		}
+	}
+	return best
+}
@@ -0,0 +1,193 @@
+// Package notify shows desktop notifications for reminders, digests and
+// failures of commands run by a scheduler, where nobody watches the
+// terminal.
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+)
+
+// appName is shown as the sender of notifications where the platform has one
+const appName = "jot"
+
+// Notifier delivers a notification with a title and a message
+type Notifier interface {
+	Notify(title, message string) error
+	Name() string
+}
+
+// Backends selectable with notify.backend
+const (
+	Auto       = "auto"
+	NotifySend = "notify-send"
+	OSAScript  = "osascript"
+	Toast      = "toast"
+	Terminal   = "terminal"
+	None       = "none"
+)
+
+// Current returns the notifier chosen by notify.backend
+func Current() (Notifier, error) {
+	backend := Auto
+	if cfg, err := config.Current(); err == nil {
+		backend = cfg.String("notify.backend")
+	}
+	return New(backend)
+}
+
+// New returns the notifier of a backend, detecting the platform's own for
+// auto
+func New(backend string) (Notifier, error) {
+	switch backend {
+	case Auto, "":
+		return Detect(), nil
+	case NotifySend:
+		return notifySend{}, nil
+	case OSAScript:
+		return osascript{}, nil
+	case Toast:
+		return toast{}, nil
+	case Terminal:
+		return terminal{w: os.Stdout}, nil
+	case None:
+		return none{}, nil
+	}
+	return nil, fmt.Errorf("unknown notification backend '%s': use auto, notify-send, osascript, toast, terminal or none", backend)
+}
+
+// Detect returns the native notifier of the platform, or one printing to
+// the terminal when there is none
+func Detect() Notifier {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("osascript"); err == nil {
+			return osascript{}
+		}
+	case "windows":
+		if _, err := exec.LookPath("powershell"); err == nil {
+			return toast{}
+		}
+	default:
+		if _, err := exec.LookPath("notify-send"); err == nil {
+			return notifySend{}
+		}
+	}
+	return terminal{w: os.Stdout}
+}
+
+// Send shows a notification with the notifier of notify.backend, printing
+// it instead when that fails so it is never lost
+func Send(title, message string) error {
+	n, err := Current()
+	if err != nil {
+		terminal{w: os.Stdout}.Notify(title, message)
+		return err
+	}
+	if err := n.Notify(title, message); err != nil {
+		if _, ok := n.(terminal); !ok {
+			terminal{w: os.Stdout}.Notify(title, message)
+		}
+		return err
+	}
+	return nil
+}
+
+// Desktop shows a notification already printed to the terminal, doing
+// nothing when notify.backend prints notifications too
+func Desktop(title, message string) error {
+	n, err := Current()
+	if err != nil {
+		return err
+	}
+	if _, ok := n.(terminal); ok {
+		return nil
+	}
+	return n.Notify(title, message)
+}
+
+// run executes a notification command, including its output in the error
+func run(name string, cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return fmt.Errorf("%s failed: %v: %s", name, err, msg)
+	}
+	return fmt.Errorf("%s failed: %w", name, err)
+}
+
+// notifySend uses notify-send from libnotify, found on most Linux and BSD
+// desktops
+type notifySend struct{}
+
+func (notifySend) Name() string { return NotifySend }
+
+func (notifySend) Notify(title, message string) error {
+	return run(NotifySend, exec.Command("notify-send", "--app-name="+appName, "--", title, message))
+}
+
+// osascript uses AppleScript's display notification on macOS
+type osascript struct{}
+
+func (osascript) Name() string { return OSAScript }
+
+func (osascript) Notify(title, message string) error {
+	// The text is passed as arguments so it needs no AppleScript quoting
+	cmd := exec.Command("osascript",
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title, message)
+	return run(OSAScript, cmd)
+}
+
+// toastScript shows a Windows toast with the text of two environment
+// variables, so it needs no PowerShell quoting. Toasts are sent as
+// PowerShell, the one sender every Windows install has registered.
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:JOT_NOTIFY_TITLE)) | Out-Null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:JOT_NOTIFY_MESSAGE)) | Out-Null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($xml)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe').Show($toast)
+`
+
+// toast uses Windows toast notifications through PowerShell
+type toast struct{}
+
+func (toast) Name() string { return Toast }
+
+func (toast) Notify(title, message string) error {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+	cmd.Env = append(os.Environ(), "JOT_NOTIFY_TITLE="+title, "JOT_NOTIFY_MESSAGE="+message)
+	return run(Toast, cmd)
+}
+
+// terminal prints notifications, for machines without a desktop
+type terminal struct {
+	w io.Writer
+}
+
+func (terminal) Name() string { return Terminal }
+
+func (t terminal) Notify(title, message string) error {
+	_, err := fmt.Fprintf(t.w, "%s\n%s\n", title, message)
+	return err
+}
+
+// none drops notifications
+type none struct{}
+
+func (none) Name() string { return None }
+
+func (none) Notify(title, message string) error { return nil }
@@ -0,0 +1,180 @@
+package objsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// httpClient is used for all object storage requests
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// S3 is a bucket of an S3-compatible object store, such as AWS S3,
+// Backblaze B2, MinIO or Cloudflare R2. Requests are signed with AWS
+// Signature Version 4.
+type S3 struct {
+	Endpoint     string // Base URL for path-style requests; empty for AWS S3
+	Region       string
+	Bucket       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// Get downloads the object at key
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, key, nil)
+}
+
+// Put uploads data as the object at key
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.do(ctx, http.MethodPut, key, data)
+	return err
+}
+
+// Delete removes the object at key; removing a missing object is not an error
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// objectURL returns the URL of key: virtual-hosted on AWS, path-style on
+// other endpoints, which is what S3-compatible stores support everywhere
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	path := "/" + escapePath(key)
+	if s.Endpoint == "" {
+		return url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com%s", s.Bucket, s.Region, path))
+	}
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/") + "/" + escapePath(s.Bucket) + path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync.endpoint: %w", err)
+	}
+	return u, nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body []byte) ([]byte, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// The escaped path is what gets signed, so it must be sent unchanged
+	req.URL.RawPath = u.EscapedPath()
+	if body == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("%s %s: %s: %s", method, key, e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, key, resp.Status)
+	}
+	return data, nil
+}
+
+// sign adds the Signature Version 4 authorization headers to req
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		headers.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signed, signature))
+	req.Header.Del("Host")
+}
+
+// escapePath percent-encodes every character of an object key except the
+// unreserved ones and slashes, as Signature Version 4 requires
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
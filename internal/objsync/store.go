@@ -0,0 +1,84 @@
+// Package objsync mirrors the encrypted contents of the data directory to
+// object storage, so a second machine can be set up from it or kept in
+// step with it.
+package objsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veritome/jot/internal/config"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Backends lists the supported sync backends
+var Backends = []string{"s3"}
+
+// Store is an object store holding a copy of the data directory
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Remote is a store and the prefix below which the data directory is kept
+type Remote struct {
+	Store  Store
+	URL    string // As configured in sync.remote
+	Prefix string
+}
+
+// key returns the object key of a path relative to the data directory
+func (r *Remote) key(path string) string {
+	if r.Prefix == "" {
+		return path
+	}
+	return r.Prefix + "/" + path
+}
+
+// Open returns the remote configured in sync.remote for backend. S3
+// credentials are taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, so they never end up in the config file.
+func Open(backend string) (*Remote, error) {
+	cfg, err := config.Current()
+	if err != nil {
+		return nil, err
+	}
+	if backend == "" {
+		backend = cfg.String("sync.backend")
+	}
+	remote := cfg.String("sync.remote")
+	if remote == "" {
+		return nil, fmt.Errorf("no bucket configured; set one with `jot config set sync.remote s3://bucket/prefix`")
+	}
+
+	switch backend {
+	case "s3":
+		rest, ok := strings.CutPrefix(remote, "s3://")
+		if !ok {
+			return nil, fmt.Errorf("sync.remote must look like s3://bucket/prefix, not %q", remote)
+		}
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("sync.remote %q names no bucket", remote)
+		}
+		s := &S3{
+			Endpoint:     cfg.String("sync.endpoint"),
+			Region:       cfg.String("sync.region"),
+			Bucket:       bucket,
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if s.AccessKey == "" || s.SecretKey == "" {
+			return nil, fmt.Errorf("set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to the bucket's credentials")
+		}
+		return &Remote{Store: s, URL: remote, Prefix: strings.Trim(prefix, "/")}, nil
+	}
+	return nil, fmt.Errorf("unknown sync backend %q; use %s", backend, strings.Join(Backends, ", "))
+}
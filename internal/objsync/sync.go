@@ -0,0 +1,572 @@
+package objsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veritome/jot/internal/atrest"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/fsutil"
+)
+
+const (
+	manifestKey = "jot-manifest.json" // Object listing every synced file, next to them
+	stateFile   = "sync.json"         // The manifest as of the last sync, in the data directory
+	workers     = 8                   // Transfers running at once
+)
+
+// synced lists the files and folders of the data directory copied to the
+// bucket. Keys, the server token, the anonymizer's list of real names and
+// the inbox of jot watch stay on the machine, and each machine rebuilds the
+// index from the entries when it no longer matches the journals.
+var synced = []string{
+	"master.key",
+	"collection.json",
+	"journals",
+	"recipients.json",
+	"prompts.txt",
+	"entries",
+	"countersigns",
+	"blobs",
+	"trash",
+	"archive",
+	"checkins",
+	"templates",
+	"tags.salt",
+	"blobs.salt",
+}
+
+// syncedDirs are the folders among synced; the rest are single files
+var syncedDirs = map[string]bool{
+	"journals":     true,
+	"entries":      true,
+	"countersigns": true,
+	"blobs":        true,
+	"trash":        true,
+	"archive":      true,
+	"checkins":     true,
+	"templates":    true,
+}
+
+// masterFile is the sealed copy of the master keys, which signs manifests
+const masterFile = "master.key"
+
+// sealed files are encrypted to the owner's key before upload, since the
+// salts are what keep hashed tags and blob names from being guessed
+var sealed = map[string]bool{
+	"tags.salt":  true,
+	"blobs.salt": true,
+}
+
+var (
+	// ErrRemoteChanged is returned by Push when another machine pushed
+	// since this one last synced
+	ErrRemoteChanged = errors.New("the bucket has changes from another machine; run jot sync pull first")
+	// ErrConflict is returned by Pull when files changed both here and in
+	// the bucket since the last sync
+	ErrConflict = errors.New("files changed both here and in the bucket")
+	// ErrNothingPushed is returned by Pull when the bucket holds no data
+	ErrNothingPushed = errors.New("nothing has been pushed to the bucket yet")
+	// ErrUnauthenticated is returned by Pull when the manifest was not
+	// written by a machine holding the master key
+	ErrUnauthenticated = errors.New("the bucket's manifest is not signed with this collection's master key; push from a machine you trust with --force to replace it")
+)
+
+// manifestPurpose derives the key manifests are signed with
+const manifestPurpose = "jot sync manifest"
+
+// Manifest describes the copy of the data directory in the bucket
+type Manifest struct {
+	Revision int64           `json:"revision"` // Incremented by every push
+	Pushed   time.Time       `json:"pushed"`
+	Host     string          `json:"host,omitempty"` // Machine that pushed last
+	Files    map[string]File `json:"files"`          // By slash-separated path relative to the data directory
+	MAC      string          `json:"mac,omitempty"`  // HMAC of the rest under the master key, see sign
+}
+
+// File is a synced file
+type File struct {
+	Hash   string `json:"hash"` // SHA-256 of the file in the data directory
+	Size   int64  `json:"size"`
+	Sealed bool   `json:"sealed,omitempty"` // Encrypted to the owner's key in the bucket
+}
+
+// state is what this machine knows of the bucket since its last sync
+type state struct {
+	Remote string `json:"remote"`
+	Manifest
+}
+
+// Options change how Push and Pull behave
+type Options struct {
+	DryRun bool // Only report what would change
+	Force  bool // Overwrite changes made on the other side
+}
+
+// Result lists what a push or pull changed, or would change in a dry run
+type Result struct {
+	Revision  int64    // Revision of the bucket afterwards
+	Copied    []string // Files uploaded or downloaded
+	Removed   []string // Files deleted from the bucket or the data directory
+	Conflicts []string // Files changed on both sides, for ErrConflict
+}
+
+// Push uploads the files that changed since the bucket's manifest was
+// written and removes those deleted here. The data directory is locked
+// meanwhile, so the copy is consistent.
+func Push(ctx context.Context, r *Remote, opts Options) (*Result, error) {
+	dir, lock, err := lockDataDir()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	remote, err := r.manifest(ctx)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	last, err := readState(dir, r.URL)
+	if err != nil {
+		return nil, err
+	}
+	if remote != nil && !opts.Force && (last == nil || last.Revision != remote.Revision) {
+		return nil, ErrRemoteChanged
+	}
+	if remote == nil {
+		remote = &Manifest{Files: map[string]File{}}
+	}
+
+	local, err := scan(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Revision: remote.Revision}
+	for path, f := range local {
+		if remote.Files[path].Hash != f.Hash {
+			result.Copied = append(result.Copied, path)
+		}
+	}
+	for path := range remote.Files {
+		if _, ok := local[path]; !ok {
+			result.Removed = append(result.Removed, path)
+		}
+	}
+	sort.Strings(result.Copied)
+	sort.Strings(result.Removed)
+	if opts.DryRun || len(result.Copied)+len(result.Removed) == 0 && remote.Revision > 0 {
+		return result, nil
+	}
+
+	err = parallel(result.Copied, func(path string) error {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return err
+		}
+		if local[path].Sealed {
+			if data, err = seal(data); err != nil {
+				return fmt.Errorf("failed to seal %s: %w", path, err)
+			}
+		}
+		return r.Store.Put(ctx, r.key(path), data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload: %w", err)
+	}
+
+	// The manifest goes up before anything is deleted, so a pull never
+	// finds it pointing at missing files
+	next := &Manifest{Revision: remote.Revision + 1, Pushed: time.Now().UTC(), Files: local}
+	next.Host, _ = os.Hostname()
+	if err := next.sign(); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := r.Store.Put(ctx, r.key(manifestKey), data); err != nil {
+		return nil, fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	if err := writeState(dir, r.URL, next); err != nil {
+		return nil, err
+	}
+	result.Revision = next.Revision
+
+	err = parallel(result.Removed, func(path string) error {
+		return r.Store.Delete(ctx, r.key(path))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove deleted files from the bucket: %w", err)
+	}
+	return result, nil
+}
+
+// Pull downloads the files that changed in the bucket and removes those
+// deleted there. Files changed here since the last sync are never
+// overwritten unless opts.Force is set; ErrConflict lists them instead.
+// On a fresh machine everything is downloaded.
+func Pull(ctx context.Context, r *Remote, opts Options) (*Result, error) {
+	dir, lock, err := lockDataDir()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	remote, err := r.manifest(ctx)
+	if err == ErrNotFound {
+		return nil, ErrNothingPushed
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.verify(ctx, remote); err != nil {
+		return nil, err
+	}
+	last, err := readState(dir, r.URL)
+	if err != nil {
+		return nil, err
+	}
+	local, err := scan(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// A local file is untouched if it is as it was at the last sync
+	untouched := func(path string) bool {
+		f, ok := local[path]
+		if last == nil {
+			return !ok
+		}
+		synced, wasSynced := last.Files[path]
+		return ok == wasSynced && (!ok || f.Hash == synced.Hash)
+	}
+
+	result := &Result{Revision: remote.Revision}
+	for path, f := range remote.Files {
+		if local[path].Hash == f.Hash {
+			continue
+		}
+		if !untouched(path) {
+			result.Conflicts = append(result.Conflicts, path)
+		}
+		result.Copied = append(result.Copied, path)
+	}
+	for path := range local {
+		if _, ok := remote.Files[path]; ok || last == nil {
+			continue
+		}
+		if _, wasSynced := last.Files[path]; !wasSynced {
+			continue // Added here since the last sync
+		}
+		if !untouched(path) {
+			result.Conflicts = append(result.Conflicts, path)
+		}
+		result.Removed = append(result.Removed, path)
+	}
+	sort.Strings(result.Copied)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Conflicts)
+	if len(result.Conflicts) > 0 && !opts.Force {
+		return result, ErrConflict
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	var keys []*crypto.KeyPair
+	for _, path := range result.Copied {
+		if remote.Files[path].Sealed {
+			if keys, err = crypto.Keyring(); err != nil {
+				return nil, err
+			}
+			defer crypto.ClearAll(keys)
+			break
+		}
+	}
+
+	// The key directory may be inside the data directory, and no file of
+	// the bucket ever belongs there
+	keyDir, err := crypto.KeyPairDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries and blobs are written before the collection refers to them
+	var files, collection []string
+	for _, path := range result.Copied {
+		if path == "collection.json" {
+			collection = append(collection, path)
+		} else {
+			files = append(files, path)
+		}
+	}
+	download := func(path string) error {
+		data, err := r.Store.Get(ctx, r.key(path))
+		if err != nil {
+			return err
+		}
+		if remote.Files[path].Sealed {
+			text, err := crypto.DecryptWithKeyring(data, keys)
+			if err != nil {
+				return fmt.Errorf("failed to unseal %s: %w", path, err)
+			}
+			data = []byte(text)
+		}
+		if got := sha256Hex(data); got != remote.Files[path].Hash {
+			return fmt.Errorf("%s does not match the manifest; it may still be uploading", path)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if keyDir != "" && within(keyDir, target) {
+			return fmt.Errorf("refusing to write into the key directory")
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		return fsutil.WriteFileAtomic(target, data, 0600)
+	}
+	for _, batch := range [][]string{files, collection} {
+		if err := parallel(batch, download); err != nil {
+			return nil, fmt.Errorf("failed to download: %w", err)
+		}
+	}
+	for _, path := range result.Removed {
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(path))); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	if err := writeState(dir, r.URL, remote); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sign sets the manifest's MAC, made with the master key so that a pull
+// can tell that the manifest, and the paths it lists, come from a machine
+// holding it rather than from whoever can write to the bucket
+func (m *Manifest) sign() error {
+	data, err := m.signed()
+	if err != nil {
+		return err
+	}
+	if m.MAC, err = atrest.MAC(manifestPurpose, data); err != nil {
+		return fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	return nil
+}
+
+// signed returns what the MAC of the manifest covers
+func (m *Manifest) signed() ([]byte, error) {
+	unsigned := *m
+	unsigned.MAC = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return data, nil
+}
+
+// verify checks that the manifest was signed with the master key and lists
+// only files that are synced. A fresh machine without the master key yet
+// takes it from the sealed copy in the bucket; a machine that has one only
+// accepts a newer key from a copy that also holds its own.
+func (r *Remote) verify(ctx context.Context, m *Manifest) error {
+	for path, f := range m.Files {
+		if !syncedPath(path, f) {
+			return fmt.Errorf("the bucket's manifest lists %q, which is not a synced file", path)
+		}
+	}
+	if m.MAC == "" {
+		return ErrUnauthenticated
+	}
+	data, err := m.signed()
+	if err != nil {
+		return err
+	}
+
+	var sealedMaster []byte
+	if _, ok := m.Files[masterFile]; ok {
+		if sealedMaster, err = r.Store.Get(ctx, r.key(masterFile)); err != nil {
+			return fmt.Errorf("failed to download %s: %w", masterFile, err)
+		}
+	}
+	ok, err := atrest.CheckMAC(manifestPurpose, data, m.MAC, sealedMaster)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// syncedPath reports whether name, as listed in a manifest, is a clean
+// relative path to one of the synced files or into one of the synced
+// folders, and is sealed in the bucket when it should be
+func syncedPath(name string, f File) bool {
+	if name == "" || strings.ContainsAny(name, "\\:") || path.Clean(name) != name || path.IsAbs(name) {
+		return false
+	}
+	parts := strings.Split(name, "/")
+	for _, part := range parts {
+		// Hidden files, and so . and .., are never synced
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	for _, top := range synced {
+		if parts[0] == top && (len(parts) == 1) == !syncedDirs[top] {
+			return f.Sealed == sealed[top]
+		}
+	}
+	return false
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// manifest downloads the bucket's manifest
+func (r *Remote) manifest(ctx context.Context) (*Manifest, error) {
+	data, err := r.Store.Get(ctx, r.key(manifestKey))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]File{}
+	}
+	return &m, nil
+}
+
+// scan hashes every synced file of the data directory. Hidden files, such
+// as those being written atomically, and the empty files reserving entry
+// IDs are left out.
+func scan(dir string) (map[string]File, error) {
+	files := make(map[string]File)
+	for _, name := range synced {
+		root := filepath.Join(dir, name)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") || d.IsDir() {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if len(data) == 0 {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = File{Hash: sha256Hex(data), Size: int64(len(data)), Sealed: sealed[name]}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data directory: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// seal encrypts data to the owner's public key
+func seal(data []byte) ([]byte, error) {
+	keyPair, err := crypto.RestorePublicKey()
+	if err != nil {
+		return nil, err
+	}
+	defer keyPair.Clear()
+	return crypto.EncryptFor(string(data), keyPair, nil)
+}
+
+// parallel runs fn for every path on a few workers, returning the first error
+func parallel(paths []string, fn func(path string) error) error {
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var first error
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range ch {
+				if err := fn(path); err != nil {
+					once.Do(func() { first = fmt.Errorf("%s: %w", path, err) })
+				}
+			}
+		}()
+	}
+	for _, path := range paths {
+		ch <- path
+	}
+	close(ch)
+	wg.Wait()
+	return first
+}
+
+func lockDataDir() (string, *fsutil.Lock, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	lock, err := fsutil.LockDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, lock, nil
+}
+
+// readState returns the manifest as of the last sync with remote, or nil
+// if this machine never synced with it
+func readState(dir, remote string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sync state: %w", err)
+	}
+	if s.Remote != remote {
+		return nil, nil
+	}
+	return &s.Manifest, nil
+}
+
+func writeState(dir, remote string, m *Manifest) error {
+	data, err := json.MarshalIndent(state{Remote: remote, Manifest: *m}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, stateFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+// Package oplog keeps a short history of the operations jot undo can
+// reverse, one directory per operation under oplog/ in the data directory.
+// Deleted entries are kept there until their operation is undone or
+// forgotten.
+package oplog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+const (
+	oplogDir   = "oplog"
+	opFile     = "op.json"
+	entriesDir = "entries"
+)
+
+// Kind is the kind of an operation
+type Kind string
+
+// Operations jot undo can reverse
+const (
+	EntryCreate   Kind = "entry-create"
+	EntryDelete   Kind = "entry-delete"
+	JournalDelete Kind = "journal-delete"
+	DefaultChange Kind = "default-change"
+	EntryChange   Kind = "entry-change"
+)
+
+// Op is a recorded operation
+type Op struct {
+	Kind      Kind      `json:"kind"`
+	At        time.Time `json:"at"`
+	Journal   string    `json:"journal"`            // Journal operated on, or the new default
+	JournalID string    `json:"journal_id"`         // ID of that journal
+	EntryID   string    `json:"entry_id,omitempty"` // Entry created or deleted
+
+	// WasDefault is set when a deleted journal was the default
+	WasDefault bool `json:"was_default,omitempty"`
+
+	// PreviousDefault and PreviousName identify the default journal before
+	// a default change, empty when there was none
+	PreviousDefault string `json:"previous_default,omitempty"`
+	PreviousName    string `json:"previous_name,omitempty"`
+
+	// Changed names what an entry change changed, such as "date" or "tags",
+	// never the values, and EntryHash is the hash of the entry file it left.
+	// MovedFrom and MovedFromID identify the journal the entry was moved out
+	// of, if it was.
+	Changed     []string `json:"changed,omitempty"`
+	EntryHash   string   `json:"entry_hash,omitempty"`
+	MovedFrom   string   `json:"moved_from,omitempty"`
+	MovedFromID string   `json:"moved_from_id,omitempty"`
+
+	dir string // Directory holding the operation
+}
+
+// String describes what the operation did
+func (op *Op) String() string {
+	switch op.Kind {
+	case EntryCreate:
+		return fmt.Sprintf("created entry %s in '%s'", op.EntryID, op.Journal)
+	case EntryDelete:
+		return fmt.Sprintf("deleted entry %s from '%s'", op.EntryID, op.Journal)
+	case JournalDelete:
+		return fmt.Sprintf("deleted journal '%s'", op.Journal)
+	case DefaultChange:
+		if op.PreviousName == "" {
+			return fmt.Sprintf("made '%s' the default journal", op.Journal)
+		}
+		return fmt.Sprintf("changed the default journal from '%s' to '%s'", op.PreviousName, op.Journal)
+	case EntryChange:
+		return fmt.Sprintf("changed the %s of entry %s in '%s'", strings.Join(op.Changed, ", "), op.EntryID, op.Journal)
+	}
+	return string(op.Kind)
+}
+
+// historySize returns how many operations are kept
+func historySize() int {
+	if cfg, err := config.Current(); err == nil {
+		return cfg.Int("undo.history")
+	}
+	return 20
+}
+
+func dataDir() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return jotDir, nil
+}
+
+// Record adds op to the history, forgetting the oldest operations beyond
+// undo.history
+func Record(op *Op) error {
+	return record(op, "", nil)
+}
+
+// RecordEntryDelete records the deletion of an entry, keeping a copy of its
+// file so the deletion can be undone. It must be called before the entry is
+// deleted; if deleting fails, Drop the returned operation.
+func RecordEntryDelete(journalName, journalID, entryID string) (*Op, error) {
+	op := &Op{Kind: EntryDelete, Journal: journalName, JournalID: journalID, EntryID: entryID}
+	if err := record(op, entryID, nil); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// ReadEntry returns the stored entry file of id, to be passed to
+// RecordEntryChange once the entry is changed
+func ReadEntry(id string) ([]byte, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(filepath.Join(jotDir, entriesDir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entry %s: %w", id, err)
+	}
+	return data, nil
+}
+
+// RecordEntryChange records a change to the metadata of an entry, which
+// was saved as before, read with ReadEntry. The previous file is kept so the
+// change can be undone. op gives the journal the entry is in now and, when
+// it was moved, the one it came from.
+func RecordEntryChange(op *Op, before []byte) error {
+	after, err := ReadEntry(op.EntryID)
+	if err != nil {
+		return err
+	}
+	op.Kind = EntryChange
+	op.EntryHash = hashEntry(after)
+	return record(op, op.EntryID, before)
+}
+
+func hashEntry(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// record writes op, with a copy of the entry file of keepID if not empty:
+// kept if given, or the file as it is now
+func record(op *Op, keepID string, kept []byte) error {
+	jotDir, err := dataDir()
+	if err != nil {
+		return err
+	}
+
+	lock, err := storage.LockDir(jotDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if op.At.IsZero() {
+		op.At = time.Now()
+	}
+	op.dir = filepath.Join(jotDir, oplogDir, strconv.FormatInt(op.At.UnixNano(), 10))
+	if err := storage.MkdirAll(filepath.Join(op.dir, entriesDir), 0700); err != nil {
+		return fmt.Errorf("failed to create operation directory: %w", err)
+	}
+
+	if keepID != "" {
+		name := keepID + ".json"
+		data := kept
+		if data == nil {
+			if data, err = storage.ReadFile(filepath.Join(jotDir, entriesDir, name)); err != nil {
+				storage.RemoveAll(op.dir)
+				return fmt.Errorf("failed to read entry %s: %w", keepID, err)
+			}
+		}
+		if err := storage.WriteFile(filepath.Join(op.dir, entriesDir, name), data, 0600); err != nil {
+			storage.RemoveAll(op.dir)
+			return fmt.Errorf("failed to keep entry %s: %w", keepID, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(op, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation: %w", err)
+	}
+	if err := storage.WriteFile(filepath.Join(op.dir, opFile), data, 0600); err != nil {
+		storage.RemoveAll(op.dir)
+		return fmt.Errorf("failed to write operation: %w", err)
+	}
+
+	return prune(jotDir)
+}
+
+// prune forgets the oldest operations beyond undo.history
+func prune(jotDir string) error {
+	dirs, err := opDirs(jotDir)
+	if err != nil {
+		return err
+	}
+	for len(dirs) > historySize() && len(dirs) > 0 {
+		if err := storage.RemoveAll(dirs[0]); err != nil {
+			return fmt.Errorf("failed to forget operation: %w", err)
+		}
+		dirs = dirs[1:]
+	}
+	return nil
+}
+
+// opDirs returns the directories of all operations, oldest first
+func opDirs(jotDir string) ([]string, error) {
+	files, err := storage.ReadDir(filepath.Join(jotDir, oplogDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operation log: %w", err)
+	}
+
+	var dirs []string
+	for _, f := range files {
+		if f.IsDir() {
+			dirs = append(dirs, f.Name())
+		}
+	}
+	// Names are nanosecond timestamps; compare them as numbers
+	sort.Slice(dirs, func(a, b int) bool {
+		if len(dirs[a]) != len(dirs[b]) {
+			return len(dirs[a]) < len(dirs[b])
+		}
+		return dirs[a] < dirs[b]
+	})
+	for i, d := range dirs {
+		dirs[i] = filepath.Join(jotDir, oplogDir, d)
+	}
+	return dirs, nil
+}
+
+// List returns the recorded operations, most recent first
+func List() ([]*Op, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := opDirs(jotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]*Op, 0, len(dirs))
+	for i := len(dirs) - 1; i >= 0; i-- {
+		data, err := storage.ReadFile(filepath.Join(dirs[i], opFile))
+		if os.IsNotExist(err) {
+			// Left by an interrupted record
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read operation %s: %w", filepath.Base(dirs[i]), err)
+		}
+		var op Op
+		if err := json.Unmarshal(data, &op); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal operation %s: %w", filepath.Base(dirs[i]), err)
+		}
+		op.dir = dirs[i]
+		ops = append(ops, &op)
+	}
+	return ops, nil
+}
+
+// Last returns the most recent operation, nil when there is none
+func Last() (*Op, error) {
+	ops, err := List()
+	if err != nil || len(ops) == 0 {
+		return nil, err
+	}
+	return ops[0], nil
+}
+
+// Drop forgets the operation, along with any entry kept for it
+func (op *Op) Drop() error {
+	if err := storage.RemoveAll(op.dir); err != nil {
+		return fmt.Errorf("failed to forget operation: %w", err)
+	}
+	return nil
+}
+
+// RestoreEntry moves the entry kept by an entry deletion back into the
+// entries directory. The caller adds it back to its journal.
+func (op *Op) RestoreEntry() error {
+	jotDir, err := dataDir()
+	if err != nil {
+		return err
+	}
+
+	lock, err := storage.LockDir(jotDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	name := op.EntryID + ".json"
+	dest := filepath.Join(jotDir, entriesDir, name)
+	if _, err := storage.Stat(dest); err == nil {
+		return fmt.Errorf("entry file %s already exists", name)
+	}
+	if err := storage.MkdirAll(filepath.Join(jotDir, entriesDir), 0700); err != nil {
+		return fmt.Errorf("failed to create entries directory: %w", err)
+	}
+	if err := storage.Rename(filepath.Join(op.dir, entriesDir, name), dest); err != nil {
+		return fmt.Errorf("failed to restore entry %s: %w", op.EntryID, err)
+	}
+	return nil
+}
+
+// EntryChangedSince reports whether the entry of an entry change was saved
+// again after it, or no longer exists
+func (op *Op) EntryChangedSince() (bool, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return false, err
+	}
+	data, err := storage.ReadFile(filepath.Join(jotDir, entriesDir, op.EntryID+".json"))
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read entry %s: %w", op.EntryID, err)
+	}
+	return hashEntry(data) != op.EntryHash, nil
+}
+
+// RevertEntry puts back the entry file kept by an entry change, replacing
+// the changed one. The caller lists the entry in its journal again.
+func (op *Op) RevertEntry() error {
+	jotDir, err := dataDir()
+	if err != nil {
+		return err
+	}
+
+	lock, err := storage.LockDir(jotDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	name := op.EntryID + ".json"
+	if err := storage.Rename(filepath.Join(op.dir, entriesDir, name), filepath.Join(jotDir, entriesDir, name)); err != nil {
+		return fmt.Errorf("failed to restore entry %s: %w", op.EntryID, err)
+	}
+	return nil
+}
+
+// EntryFiles returns the file names of the entries kept for undo, whose IDs
+// stay taken so they can be restored
+func EntryFiles() ([]string, error) {
+	paths, err := EntryPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	return names, nil
+}
+
+// EntryPaths returns the paths of the entry files kept for undo
+func EntryPaths() ([]string, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := opDirs(jotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		files, err := storage.ReadDir(filepath.Join(dir, entriesDir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list kept entries: %w", err)
+		}
+		for _, f := range files {
+			if strings.HasSuffix(f.Name(), ".json") {
+				paths = append(paths, filepath.Join(dir, entriesDir, f.Name()))
+			}
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,165 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/template"
+	"github.com/veritome/jot/internal/types"
+)
+
+// DefaultAt is the time of day a journal's prompt is due when none is given
+const DefaultAt = "20:00"
+
+// ParseQuestions reads the questions of a journal's prompt, one per line.
+// Blank lines and lines starting with '#' are ignored.
+func ParseQuestions(text string) ([]string, error) {
+	var questions []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		questions = append(questions, line)
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("a prompt needs at least one question")
+	}
+	return questions, nil
+}
+
+// Set gives journalName the questions of its prompt, keeping its schedule
+func Set(journalName string, questions []string) error {
+	return update(journalName, func(j *types.Journal) error {
+		if j.Prompt == nil {
+			j.Prompt = &types.Prompt{}
+		}
+		j.Prompt.Questions = questions
+		return nil
+	})
+}
+
+// Remove deletes the prompt of journalName, with its schedule
+func Remove(journalName string) error {
+	return update(journalName, func(j *types.Journal) error {
+		if j.Prompt == nil {
+			return fmt.Errorf("journal '%s' has no prompt", journalName)
+		}
+		j.Prompt = nil
+		return nil
+	})
+}
+
+// Schedule has the prompt of journalName come due every time the schedule
+// comes round, starting after now. every and at are as for templates.
+func Schedule(journalName, every, at string, now time.Time) (*types.Prompt, error) {
+	var p *types.Prompt
+	err := update(journalName, func(j *types.Journal) error {
+		if j.Prompt == nil {
+			return fmt.Errorf("journal '%s' has no prompt; add its questions with jot prompt edit %s", journalName, journalName)
+		}
+		j.Prompt.Every, j.Prompt.At, j.Prompt.Last = every, at, now
+		p = j.Prompt
+		return nil
+	})
+	return p, err
+}
+
+// Unschedule stops reminders of the prompt of journalName, keeping its
+// questions
+func Unschedule(journalName string) error {
+	return update(journalName, func(j *types.Journal) error {
+		if j.Prompt == nil || j.Prompt.Every == "" {
+			return fmt.Errorf("the prompt of journal '%s' is not scheduled", journalName)
+		}
+		j.Prompt.Every, j.Prompt.At, j.Prompt.Last = "", "", time.Time{}
+		return nil
+	})
+}
+
+// Next returns when p next comes due after now, or the zero time when it
+// is not scheduled
+func Next(p *types.Prompt, now time.Time) time.Time {
+	if p.Every == "" {
+		return time.Time{}
+	}
+	return template.Next(recurring(p), now)
+}
+
+// RunDue claims the prompts whose time has come and returns the names of
+// their journals, for reminding of them. A prompt missed several times,
+// such as while the machine was off, is returned once.
+func RunDue(now time.Time) ([]string, error) {
+	coll, err := collection.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(due(coll, now)) == 0 {
+		return nil, nil
+	}
+
+	// Another process may have claimed them meanwhile
+	var claimed []string
+	_, err = collection.Update(func(coll *collection.Collection) error {
+		claimed = due(coll, now)
+		for _, name := range claimed {
+			p := coll.Journals[name].Prompt
+			p.Last = template.Latest(recurring(p), now)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save prompt schedule: %w", err)
+	}
+	return claimed, nil
+}
+
+// due returns the names of the journals whose prompt came due by now,
+// sorted
+func due(coll *collection.Collection, now time.Time) []string {
+	var names []string
+	for name, j := range coll.Journals {
+		p := j.Prompt
+		if p != nil && p.Every != "" && template.Latest(recurring(p), now).After(p.Last) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Body renders the answers to the questions of a prompt as the text of one
+// entry, each answer under its question. Unanswered questions are left out.
+func Body(questions, answers []string) string {
+	var parts []string
+	for i, q := range questions {
+		if i >= len(answers) || strings.TrimSpace(answers[i]) == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("> %s\n\n%s", q, strings.TrimSpace(answers[i])))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// recurring returns the schedule of p in the form templates use
+func recurring(p *types.Prompt) *types.Recurring {
+	return &types.Recurring{Every: p.Every, At: p.At, Last: p.Last}
+}
+
+// update applies fn to the journal journalName and saves the collection
+func update(journalName string, fn func(*types.Journal) error) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		j, exists := coll.Journals[journalName]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", journalName)
+		}
+		return fn(j)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save prompt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+)
+
+// promptsFile is the user-editable prompt list, one prompt per line
+const promptsFile = "prompts.txt"
+
+// defaultPrompts are used when the user has not configured their own list
+var defaultPrompts = []string{
+	"What is on your mind right now?",
+	"What are three things you are grateful for today?",
+	"What did you learn today?",
+	"What was the best part of your day?",
+	"What is something you are looking forward to?",
+	"What challenged you today, and how did you respond?",
+	"Describe a conversation that stayed with you.",
+	"What would you like to let go of?",
+	"What made you smile recently?",
+	"What is one thing you want to remember about this week?",
+}
+
+// Path returns the location of the user's prompt list
+func Path() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, promptsFile), nil
+}
+
+// Load returns the configured prompts, falling back to the built-in list
+// when no prompt file exists. Blank lines and lines starting with '#' are ignored.
+func Load() ([]string, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return defaultPrompts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	var prompts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prompts = append(prompts, line)
+	}
+	if len(prompts) == 0 {
+		return defaultPrompts, nil
+	}
+
+	return prompts, nil
+}
+
+// ForDay picks the prompt for the given day. The choice rotates through the
+// list one prompt per calendar day, so every invocation on the same day
+// shows the same prompt.
+func ForDay(prompts []string, t time.Time) string {
+	if len(prompts) == 0 {
+		return ""
+	}
+	y, m, d := t.Date()
+	days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+	return prompts[int(days%int64(len(prompts)))]
+}
@@ -0,0 +1,82 @@
+// Package quota checks journals against their soft limits: a number of
+// entries or megabytes past which jot warns and suggests archiving, without
+// ever refusing new entries
+package quota
+
+import (
+	"fmt"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/types"
+)
+
+// Usage is what a journal takes up, as counted against its quota
+type Usage struct {
+	Entries int
+	Bytes   int64 // Entry files and the blobs they use
+}
+
+// Over reports whether u exceeds any limit of q
+func (u *Usage) Over(q *types.Quota) bool {
+	return (q.Entries > 0 && u.Entries > q.Entries) || (q.Bytes > 0 && u.Bytes > q.Bytes)
+}
+
+// New returns a quota of entries and megabytes, zero meaning no limit
+func New(entries, megabytes int) (*types.Quota, error) {
+	if entries < 0 || megabytes < 0 {
+		return nil, fmt.Errorf("limits cannot be negative")
+	}
+	if entries == 0 && megabytes == 0 {
+		return nil, fmt.Errorf("a quota needs a limit of entries or megabytes")
+	}
+	return &types.Quota{Entries: entries, Bytes: int64(megabytes) << 20}, nil
+}
+
+// Set stores q as the quota of journal name, or removes its quota when q is
+// nil. Shared journals cannot have one, since their entries are not kept in
+// the data directory.
+func Set(name string, q *types.Quota) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", name)
+		}
+		if q != nil && j.SharedDir != "" {
+			return fmt.Errorf("journal '%s' is shared; its entries are not counted", name)
+		}
+		j.Quota = q
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save quota: %w", err)
+	}
+	return nil
+}
+
+// Measure returns what journal j takes up
+func Measure(j *types.Journal) (*Usage, error) {
+	size, err := entry.StoredSize(j.EntryIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{Entries: len(j.EntryIDs), Bytes: size}, nil
+}
+
+// Check reports whether journal j is over its quota, along with what it
+// takes up. The size is only worked out when the quota limits it, since
+// that reads every entry file.
+func Check(j *types.Journal) (*Usage, bool, error) {
+	if j.Quota == nil {
+		return nil, false, nil
+	}
+	u := &Usage{Entries: len(j.EntryIDs)}
+	if j.Quota.Bytes > 0 {
+		size, err := entry.StoredSize(j.EntryIDs)
+		if err != nil {
+			return nil, false, err
+		}
+		u.Bytes = size
+	}
+	return u, u.Over(j.Quota), nil
+}
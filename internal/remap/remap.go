@@ -0,0 +1,301 @@
+// Package remap moves entries from the legacy sequential IDs, such as 0042,
+// to IDs of another format, rewriting what refers to them.
+package remap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/idgen"
+	"github.com/veritome/jot/internal/oplog"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/trash"
+)
+
+// stateFile records the IDs of an unfinished remapping so it can be resumed
+const stateFile = "remap.json"
+
+// State is the persisted mapping of an unfinished remapping
+type State struct {
+	StartedAt time.Time         `json:"started_at"`
+	Order     []string          `json:"order"` // Legacy IDs, oldest entry first
+	IDs       map[string]string `json:"ids"`   // New ID of each legacy ID
+}
+
+// Plan is what remapping changes
+type Plan struct {
+	State
+	Resumed bool // The plan is that of an unfinished remapping
+
+	// Kept are the legacy IDs left alone, with why: entries of append-only
+	// journals, whose hash chain covers the ID, countersigned and
+	// timestamped entries, whose proofs name it, and trashed, archived or
+	// deleted entries, which come back under the ID they left with
+	Kept map[string]string
+
+	// References are the entries whose references to remapped entries are
+	// rewritten: those superseding one and rollups, which are only found
+	// to link to one when decrypted
+	References []string
+}
+
+// Result summarises a finished remapping
+type Result struct {
+	Renamed    int
+	References int // Entries whose references were rewritten
+	Forgotten  int // Operations jot undo can no longer reverse
+}
+
+// Legacy reports whether id is a legacy sequential ID: digits only
+func Legacy(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// statePath returns the location of the remapping state file
+func statePath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, stateFile), nil
+}
+
+// Status returns the state of the unfinished remapping, or nil if none is in
+// progress
+func Status() (*State, error) {
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remapping state: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal remapping state: %w", err)
+	}
+	return &s, nil
+}
+
+func (s *State) save() error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal remapping state: %w", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write remapping state: %w", err)
+	}
+	return nil
+}
+
+// NewPlan works out the new ID gen gives each entry with a legacy ID, in
+// the order the entries were created and as of their creation, so that
+// date-based IDs carry the day the entry was written. An unfinished
+// remapping is resumed with the IDs it planned, whatever gen is.
+func NewPlan(gen idgen.Generator) (*Plan, error) {
+	state, err := Status()
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Resumed: state != nil, Kept: make(map[string]string)}
+	if state != nil {
+		plan.State = *state
+	} else {
+		plan.State = State{IDs: make(map[string]string)}
+	}
+
+	ids, err := entry.ListIDs()
+	if err != nil {
+		return nil, err
+	}
+	var legacy []*entry.Entry
+	var others []*entry.Entry
+	for _, id := range ids {
+		e, err := entry.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case Legacy(id) && e.Sealed():
+			plan.Kept[id] = "in an append-only journal"
+		case Legacy(id) && (len(e.Countersigns) > 0 || len(e.Entry.Timestamps) > 0):
+			plan.Kept[id] = "countersigned or timestamped under this ID"
+		case Legacy(id):
+			legacy = append(legacy, e)
+		default:
+			others = append(others, e)
+		}
+	}
+	if err := keepUnlisted(plan.Kept); err != nil {
+		return nil, err
+	}
+
+	if !plan.Resumed {
+		taken, err := entry.TakenIDs()
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(legacy, func(a, b int) bool { return legacy[a].Created.Before(legacy[b].Created) })
+		for _, e := range legacy {
+			id, err := gen.Next(taken, e.Created)
+			if err != nil {
+				return nil, err
+			}
+			if Legacy(id) {
+				return nil, fmt.Errorf("the ID format gives legacy IDs such as %s; choose another", id)
+			}
+			plan.Order = append(plan.Order, e.ID)
+			plan.IDs[e.ID] = id
+			taken = append(taken, id)
+		}
+	}
+
+	for _, e := range append(legacy, others...) {
+		if e.Sealed() {
+			continue
+		}
+		if _, ok := plan.IDs[e.Supersedes]; ok || e.IsRollup() {
+			plan.References = append(plan.References, e.ID)
+		}
+	}
+	sort.Strings(plan.References)
+	return plan, nil
+}
+
+// keepUnlisted adds the legacy IDs of trashed, archived and deleted entries
+// to kept
+func keepUnlisted(kept map[string]string) error {
+	trashed, err := trash.EntryFiles()
+	if err != nil {
+		return err
+	}
+	deleted, err := oplog.EntryFiles()
+	if err != nil {
+		return err
+	}
+	archived, err := entry.ArchivedIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, files := range []struct {
+		names  []string
+		reason string
+	}{{trashed, "trashed"}, {deleted, "deleted, kept for jot undo"}} {
+		for _, name := range files.names {
+			if id := trimJSON(name); Legacy(id) {
+				kept[id] = files.reason
+			}
+		}
+	}
+	for id, year := range archived {
+		if Legacy(id) {
+			kept[id] = fmt.Sprintf("archived in %d", year)
+		}
+	}
+	return nil
+}
+
+func trimJSON(name string) string {
+	if ext := filepath.Ext(name); ext == ".json" {
+		return name[:len(name)-len(ext)]
+	}
+	return ""
+}
+
+// Apply renames the entries of the plan, rewrites the references to them
+// and forgets the operations jot undo would reverse under an old ID. The
+// plan is saved to the state file first, so that calling NewPlan and Apply
+// after an interruption finishes it. progress, if not nil, is called after
+// each entry renamed with the number done so far and the total.
+func (p *Plan) Apply(progress func(done, total int)) (*Result, error) {
+	if !p.Resumed {
+		p.StartedAt = time.Now()
+		if err := p.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{}
+	for i, id := range p.Order {
+		e, err := entry.Load(id)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Renamed before an interruption
+		case err != nil:
+			return result, err
+		default:
+			if err := e.Rename(p.IDs[id]); err != nil {
+				return result, fmt.Errorf("failed to rename entry %s: %w", id, err)
+			}
+			result.Renamed++
+		}
+		if progress != nil {
+			progress(i+1, len(p.Order))
+		}
+	}
+
+	for _, id := range p.References {
+		if to, ok := p.IDs[id]; ok {
+			id = to
+		}
+		changed, err := entry.Rewrite(id, func(e *entry.Entry) (bool, error) {
+			return e.RemapReferences(p.IDs)
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to rewrite references of entry %s: %w", id, err)
+		}
+		if changed {
+			result.References++
+		}
+	}
+
+	ops, err := oplog.List()
+	if err != nil {
+		return result, err
+	}
+	for _, op := range ops {
+		if _, ok := p.IDs[op.EntryID]; !ok {
+			continue
+		}
+		if err := op.Drop(); err != nil {
+			return result, err
+		}
+		result.Forgotten++
+	}
+
+	path, err := statePath()
+	if err != nil {
+		return result, err
+	}
+	if err := storage.Remove(path); err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to remove remapping state: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,287 @@
+package remind
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// cronMarker tags the crontab line owned by jot so it can be replaced or removed
+	cronMarker = "# jot-remind"
+
+	systemdUnit    = "jot-remind"
+	launchdLabel   = "com.veritome.jot.remind"
+	launchAgents   = "Library/LaunchAgents"
+	systemdUserDir = ".config/systemd/user"
+)
+
+// Backend identifies the scheduler used to fire reminders
+type Backend string
+
+// Supported scheduler backends
+const (
+	Cron    Backend = "cron"
+	Systemd Backend = "systemd"
+	Launchd Backend = "launchd"
+)
+
+// Schedule is a daily time of day at which the reminder fires
+type Schedule struct {
+	Hour   int
+	Minute int
+}
+
+// ParseSchedule parses a time of day in HH:MM format
+func ParseSchedule(s string) (Schedule, error) {
+	var sched Schedule
+	if _, err := fmt.Sscanf(s, "%d:%d", &sched.Hour, &sched.Minute); err != nil {
+		return Schedule{}, fmt.Errorf("invalid time '%s': expected HH:MM", s)
+	}
+	if sched.Hour < 0 || sched.Hour > 23 || sched.Minute < 0 || sched.Minute > 59 {
+		return Schedule{}, fmt.Errorf("invalid time '%s': out of range", s)
+	}
+	return sched, nil
+}
+
+// String formats the schedule as HH:MM
+func (s Schedule) String() string {
+	return fmt.Sprintf("%02d:%02d", s.Hour, s.Minute)
+}
+
+// DetectBackend picks the scheduler appropriate for the current platform
+func DetectBackend() (Backend, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return Launchd, nil
+	case "linux":
+		if _, err := exec.LookPath("systemctl"); err == nil {
+			return Systemd, nil
+		}
+		return Cron, nil
+	case "freebsd", "openbsd", "netbsd":
+		return Cron, nil
+	default:
+		return "", fmt.Errorf("scheduled reminders are not supported on %s", runtime.GOOS)
+	}
+}
+
+// Install registers a daily job running "<binary> remind run" with the given backend
+func Install(b Backend, s Schedule, binary string) error {
+	switch b {
+	case Cron:
+		return installCron(s, binary)
+	case Systemd:
+		return installSystemd(s, binary)
+	case Launchd:
+		return installLaunchd(s, binary)
+	default:
+		return fmt.Errorf("unknown reminder backend '%s'", b)
+	}
+}
+
+// Uninstall removes the reminder job from the given backend
+func Uninstall(b Backend) error {
+	switch b {
+	case Cron:
+		return uninstallCron()
+	case Systemd:
+		return uninstallSystemd()
+	case Launchd:
+		return uninstallLaunchd()
+	default:
+		return fmt.Errorf("unknown reminder backend '%s'", b)
+	}
+}
+
+// readCrontab returns the current user's crontab without the jot reminder line
+func readCrontab() ([]string, error) {
+	out, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		// crontab -l exits non-zero when the user has no crontab yet
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read crontab: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if line == "" || strings.HasSuffix(line, cronMarker) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// writeCrontab replaces the current user's crontab with lines
+func writeCrontab(lines []string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to write crontab: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func installCron(s Schedule, binary string) error {
+	lines, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	lines = append(lines, fmt.Sprintf("%d %d * * * %q remind run %s", s.Minute, s.Hour, binary, cronMarker))
+	return writeCrontab(lines)
+}
+
+func uninstallCron() error {
+	lines, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	return writeCrontab(lines)
+}
+
+func systemdDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, systemdUserDir), nil
+}
+
+func installSystemd(s Schedule, binary string) error {
+	dir, err := systemdDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create systemd user directory: %w", err)
+	}
+
+	service := fmt.Sprintf(`[Unit]
+Description=jot daily journaling reminder
+
+[Service]
+Type=oneshot
+ExecStart=%q remind run
+`, binary)
+
+	timer := fmt.Sprintf(`[Unit]
+Description=jot daily journaling reminder
+
+[Timer]
+OnCalendar=*-*-* %02d:%02d:00
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, s.Hour, s.Minute)
+
+	if err := os.WriteFile(filepath.Join(dir, systemdUnit+".service"), []byte(service), 0600); err != nil {
+		return fmt.Errorf("failed to write systemd service: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, systemdUnit+".timer"), []byte(timer), 0600); err != nil {
+		return fmt.Errorf("failed to write systemd timer: %w", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", systemdUnit+".timer")
+}
+
+func uninstallSystemd() error {
+	dir, err := systemdDir()
+	if err != nil {
+		return err
+	}
+
+	// Ignore failures to disable a timer that was never enabled
+	_ = systemctl("disable", "--now", systemdUnit+".timer")
+
+	for _, name := range []string{systemdUnit + ".service", systemdUnit + ".timer"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return systemctl("daemon-reload")
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("systemctl %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func launchdPlist() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, launchAgents, launchdLabel+".plist"), nil
+}
+
+func installLaunchd(s Schedule, binary string) error {
+	path, err := launchdPlist()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+	}
+
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>remind</string>
+		<string>run</string>
+	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+		<key>Hour</key>
+		<integer>%d</integer>
+		<key>Minute</key>
+		<integer>%d</integer>
+	</dict>
+</dict>
+</plist>
+`, launchdLabel, binary, s.Hour, s.Minute)
+
+	// Unload any previous version so the new schedule takes effect
+	_ = exec.Command("launchctl", "unload", path).Run()
+
+	if err := os.WriteFile(path, []byte(plist), 0600); err != nil {
+		return fmt.Errorf("failed to write launchd plist: %w", err)
+	}
+	if out, err := exec.Command("launchctl", "load", "-w", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl load failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func uninstallLaunchd() error {
+	path, err := launchdPlist()
+	if err != nil {
+		return err
+	}
+	_ = exec.Command("launchctl", "unload", "-w", path).Run()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove launchd plist: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Package replace rewrites text in entries for jot sed, such as a name
+// misspelled the same way in many entries.
+package replace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule replaces every occurrence of a literal text
+type Rule struct {
+	Old, New   string
+	IgnoreCase bool // Match Old regardless of case
+	Word       bool // Only match Old as a whole word
+	pattern    *regexp.Regexp
+}
+
+// Parse reads a rule written as old/new, or sed's s/old/new/. A slash
+// inside either text is written \/.
+func Parse(expr string, ignoreCase, word bool) (*Rule, error) {
+	parts := split(expr)
+	if len(parts) == 4 && parts[0] == "s" && parts[3] == "" {
+		parts = parts[1:3]
+	}
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid replacement '%s': write it as old/new", expr)
+	}
+
+	r := &Rule{Old: parts[0], New: parts[1], IgnoreCase: ignoreCase, Word: word}
+	pattern := regexp.QuoteMeta(r.Old)
+	if word {
+		pattern = `\b` + pattern + `\b`
+	}
+	if ignoreCase {
+		pattern = `(?i)` + pattern
+	}
+	var err error
+	if r.pattern, err = regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("invalid replacement '%s': %w", expr, err)
+	}
+	return r, nil
+}
+
+// split splits expr at slashes not escaped with a backslash, unescaping
+// them
+func split(expr string) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\\' && i+1 < len(expr) && expr[i+1] == '/':
+			b.WriteByte('/')
+			i++
+		case expr[i] == '/':
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(expr[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+// Apply returns text with every match of the rule replaced, and the number
+// of replacements
+func (r *Rule) Apply(text string) (string, int) {
+	n := len(r.pattern.FindAllStringIndex(text, -1))
+	if n == 0 {
+		return text, 0
+	}
+	return r.pattern.ReplaceAllLiteralString(text, r.New), n
+}
+
+// Change is a line changed by a rule
+type Change struct {
+	Line     int // 1-based
+	Old, New string
+}
+
+// Changes returns the lines that differ between text and the result of
+// applying a rule to it. Rules never add or remove newlines unless Old or
+// New contains one, in which case the whole text is one change.
+func Changes(text, replaced string) []Change {
+	before, after := strings.Split(text, "\n"), strings.Split(replaced, "\n")
+	if len(before) != len(after) {
+		return []Change{{Line: 1, Old: text, New: replaced}}
+	}
+	var changes []Change
+	for i := range before {
+		if before[i] != after[i] {
+			changes = append(changes, Change{Line: i + 1, Old: before[i], New: after[i]})
+		}
+	}
+	return changes
+}
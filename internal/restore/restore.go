@@ -0,0 +1,222 @@
+package restore
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/entry"
+)
+
+// keyDirName is the directory of the data directory holding the key pairs
+const keyDirName = "backup"
+
+// Staged is a data directory assembled from a key backup and a data backup
+// next to the live data directory, so it can be verified before replacing it
+type Staged struct {
+	Dir      string
+	Live     string
+	Journals int
+	Entries  int
+	Failed   []string // Entries that cannot be read, with the reason
+}
+
+// Stage copies the data backup at dataDir, minus any keys it contains, and
+// the key pairs found in keysDir into a new staging directory
+func Stage(keysDir, dataDir string) (*Staged, error) {
+	keysDir = crypto.KeyDir(keysDir)
+	if !crypto.HasKeyPair(keysDir) {
+		return nil, fmt.Errorf("no key pair found in %s", keysDir)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "collection.json")); err != nil {
+		return nil, fmt.Errorf("no collection.json found in %s", dataDir)
+	}
+
+	live, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data directory: %w", err)
+	}
+	live, err = filepath.Abs(live)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve data directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(live), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(live), err)
+	}
+
+	// Staging next to the live directory lets Commit rename it into place
+	dir, err := os.MkdirTemp(filepath.Dir(live), filepath.Base(live)+"-restore-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	s := &Staged{Dir: dir, Live: live}
+
+	if err := copyTree(dataDir, dir, keyDirName); err != nil {
+		s.Discard()
+		return nil, fmt.Errorf("failed to copy data backup: %w", err)
+	}
+	if err := copyTree(keysDir, filepath.Join(dir, keyDirName), ""); err != nil {
+		s.Discard()
+		return nil, fmt.Errorf("failed to copy key backup: %w", err)
+	}
+	return s, nil
+}
+
+// Verify reads and decrypts every entry of the staged data directory with
+// the staged keys, recording the entries that fail in Failed
+func (s *Staged) Verify() error {
+	cfg, err := config.Current()
+	if err != nil {
+		return err
+	}
+
+	// Point jot at the staging directory and its keys while verifying
+	resetDir, err := cfg.Override("data_dir", s.Dir)
+	if err != nil {
+		return err
+	}
+	defer resetDir()
+	resetKeys, err := cfg.Override("crypto.key_dir", "")
+	if err != nil {
+		return err
+	}
+	defer resetKeys()
+
+	coll, err := collection.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load staged collection: %w", err)
+	}
+	names := make([]string, 0, len(coll.Journals))
+	for name := range coll.Journals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.Journals, s.Entries, s.Failed = len(names), 0, nil
+	for _, name := range names {
+		for _, id := range coll.Journals[name].EntryIDs {
+			s.Entries++
+			if err := readEntry(id); err != nil {
+				s.Failed = append(s.Failed, fmt.Sprintf("%s/%s: %v", name, id, err))
+			}
+		}
+	}
+	return nil
+}
+
+// readEntry loads and decrypts everything stored for an entry
+func readEntry(id string) error {
+	e, err := entry.Load(id)
+	if err != nil {
+		return err
+	}
+	if _, err := e.GetDecryptedBody(); err != nil {
+		return err
+	}
+	if _, err := e.GetTags(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Commit replaces the live data directory with the staged one. Existing
+// data is moved aside, never deleted; its new location is returned, or ""
+// if there was nothing to keep.
+func (s *Staged) Commit() (string, error) {
+	var kept string
+	files, err := os.ReadDir(s.Live)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return "", fmt.Errorf("failed to read %s: %w", s.Live, err)
+	case len(files) == 0:
+		if err := os.Remove(s.Live); err != nil {
+			return "", fmt.Errorf("failed to remove empty %s: %w", s.Live, err)
+		}
+	default:
+		kept = fmt.Sprintf("%s.before-restore-%s", s.Live, time.Now().Format("20060102-150405"))
+		for n := 2; exists(kept); n++ {
+			kept = fmt.Sprintf("%s.before-restore-%s-%d", s.Live, time.Now().Format("20060102-150405"), n)
+		}
+		if err := os.Rename(s.Live, kept); err != nil {
+			return "", fmt.Errorf("failed to move existing data aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(s.Dir, s.Live); err != nil {
+		if kept != "" {
+			os.Rename(kept, s.Live)
+		}
+		return "", fmt.Errorf("failed to move restored data into place: %w", err)
+	}
+	return kept, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Discard removes the staging directory
+func (s *Staged) Discard() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// copyTree copies the regular files under src into dst, skipping the
+// top-level entry named skip. Permissions are tightened to 0700 and 0600,
+// except for public keys.
+func copyTree(src, dst, skip string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if skip != "" && rel == skip {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0700)
+		case !d.Type().IsRegular():
+			// Pipes and links are not part of a backup
+			return nil
+		}
+		perm := os.FileMode(0600)
+		if filepath.Ext(path) == ".pub" {
+			perm = 0644
+		}
+		return copyFile(path, target, perm)
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
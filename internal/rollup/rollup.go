@@ -0,0 +1,67 @@
+// Package rollup drafts review entries for a week or a month, listing the
+// entries written in it with room to reflect on them.
+package rollup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/when"
+)
+
+// Periods a rollup can review
+const (
+	Week  = "week"
+	Month = "month"
+)
+
+// Period returns the first day of the week, starting on calendar.week_start,
+// or month containing t, and the first day after it
+func Period(period string, t time.Time) (start, end time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if period == Month {
+		start = day.AddDate(0, 0, 1-day.Day())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = when.StartOfWeek(day)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// Title names the rollup of the period starting at start
+func Title(period string, start time.Time) string {
+	if period == Month {
+		return start.Format("January 2006") + " in review"
+	}
+	return "Week of " + start.Format("January 2, 2006")
+}
+
+// Item is an entry written during the period
+type Item struct {
+	ID      string
+	Created time.Time
+	Summary string // Title or first line; empty for sensitive entries
+	Tags    []string
+}
+
+// Body drafts the text of a rollup: the period's entries, oldest first,
+// then headings to write the review under
+func Body(period string, items []Item) string {
+	var b strings.Builder
+	b.WriteString("## Entries\n\n")
+	if len(items) == 0 {
+		fmt.Fprintf(&b, "Nothing written this %s.\n", period)
+	}
+	for _, it := range items {
+		fmt.Fprintf(&b, "- %s %s", it.ID, it.Created.Format("Mon Jan 2"))
+		if it.Summary != "" {
+			fmt.Fprintf(&b, ": %s", it.Summary)
+		}
+		for _, t := range it.Tags {
+			fmt.Fprintf(&b, " #%s", t)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\n## Highlights\n\n\n## What I learned\n\n\n## Next %s\n", period)
+	return b.String()
+}
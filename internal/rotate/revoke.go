@@ -0,0 +1,72 @@
+package rotate
+
+import (
+	"errors"
+
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/entry"
+)
+
+// RevokeResult summarises re-encrypting entries without a revoked key
+type RevokeResult struct {
+	Reencrypted int
+	Skipped     []string // Sealed entries the key can still open
+}
+
+// Revoke re-encrypts every entry sealed to the public key revoked under
+// fresh keys for the other keys it was sealed to, so the revoked key opens
+// none of them anymore. Unlike Run, the keys of this machine are kept, and
+// entries not sealed to revoked are left alone without being decrypted, so
+// calling Revoke again after an interruption only handles the rest.
+// progress, if not nil, is called after each entry with the number handled
+// so far and the total.
+//
+// Archived years are thawed to be re-encrypted and archived again; an
+// interrupted call leaves them thawed. Sealed entries of append-only
+// journals cannot be rewritten; they stay readable with the revoked key and
+// are listed in the result.
+func Revoke(revoked *[32]byte, progress func(done, total int)) (*RevokeResult, error) {
+	if !crypto.HasPrivateKey() {
+		return nil, crypto.ErrPrivateKeyOffline
+	}
+	keys, err := crypto.Keyring()
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearAll(keys)
+
+	archived, err := archivedEntries()
+	if err != nil {
+		return nil, err
+	}
+	if err := thaw(archived); err != nil {
+		return nil, err
+	}
+
+	ids, err := entry.ListIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RevokeResult{}
+	for i, id := range ids {
+		changed, err := entry.Rewrite(id, func(e *entry.Entry) (bool, error) {
+			return e.Revoke(keys, revoked)
+		})
+		switch {
+		case errors.Is(err, entry.ErrAppendOnly):
+			result.Skipped = append(result.Skipped, id)
+		case err != nil:
+			return nil, err
+		case changed:
+			result.Reencrypted++
+		}
+		if progress != nil {
+			progress(i+1, len(ids))
+		}
+	}
+	if err := rearchive(archived); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,305 @@
+package rotate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veritome/jot/internal/atrest"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/device"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// stateFile records the progress of a key rotation so it can be resumed
+const stateFile = "rotation.json"
+
+// checkpointEvery is how many entries are re-encrypted between state saves
+const checkpointEvery = 50
+
+// Rotation phases
+const (
+	PhaseReencrypt = "reencrypt"
+	PhasePromote   = "promote"
+)
+
+// State is the persisted progress of an unfinished key rotation
+type State struct {
+	StartedAt time.Time `json:"started_at"`
+	Phase     string    `json:"phase"`
+	Done      []string  `json:"done"` // Entry IDs already handled
+	Skipped   []string  `json:"skipped,omitempty"`
+	From      string    `json:"from,omitempty"` // Public key being rotated away from
+	// Archived lists the entries of each archived year, thawed to be
+	// re-encrypted and archived again under the new key
+	Archived map[int][]string `json:"archived,omitempty"`
+}
+
+// Result summarises a finished rotation
+type Result struct {
+	Reencrypted int
+	Skipped     []string // Sealed entries left under their old key
+	Resumed     bool
+}
+
+// statePath returns the location of the rotation state file
+func statePath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, stateFile), nil
+}
+
+// Status returns the state of the unfinished rotation, or nil if none is in progress
+func Status() (*State, error) {
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rotation state: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rotation state: %w", err)
+	}
+	return &s, nil
+}
+
+func (s *State) save() error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rotation state: %w", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write rotation state: %w", err)
+	}
+	return nil
+}
+
+// Run rotates the encryption key: every entry is re-encrypted with a new key
+// pair, which then replaces the current one. Progress is checkpointed to the
+// state file, so calling Run after an interruption continues the unfinished
+// rotation instead of starting over. progress, if not nil, is called after
+// each entry with the number handled so far and the total.
+//
+// Sealed entries of append-only journals cannot be rewritten without breaking
+// their hash chain; they keep their old key, which is retired rather than
+// deleted so they stay readable.
+func Run(progress func(done, total int)) (*Result, error) {
+	// Rotating decrypts every entry, so it needs the private keys
+	if !crypto.HasPrivateKey() {
+		return nil, crypto.ErrPrivateKeyOffline
+	}
+
+	state, err := Status()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Resumed: state != nil}
+	if state == nil {
+		from, err := crypto.RestorePublicKey()
+		if err != nil {
+			return nil, err
+		}
+		state = &State{StartedAt: time.Now(), Phase: PhaseReencrypt, From: from.PublicKeyString()}
+		from.Clear()
+		if state.Archived, err = archivedEntries(); err != nil {
+			return nil, err
+		}
+		if err := state.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	if state.Phase == PhaseReencrypt {
+		// Archives are sealed to the old key like entries; the years
+		// are thawed again when resuming, which does nothing once done
+		if err := thaw(state.Archived); err != nil {
+			return nil, err
+		}
+		if err := reencryptAll(state, result, progress); err != nil {
+			return nil, err
+		}
+		state.Phase = PhasePromote
+		if err := state.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := crypto.PromotePendingKey(); err != nil {
+		return nil, err
+	}
+	// The other devices encrypt to this machine's key as recorded with it
+	if err := device.Rekey(state.From); err != nil {
+		return nil, err
+	}
+	// The master key is sealed to the key pair too
+	if err := atrest.Share(); err != nil {
+		return nil, err
+	}
+	if err := rearchive(state.Archived); err != nil {
+		return nil, err
+	}
+
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove rotation state: %w", err)
+	}
+
+	result.Skipped = state.Skipped
+	return result, nil
+}
+
+// reencryptAll re-encrypts every entry not yet recorded as done
+func reencryptAll(state *State, result *Result, progress func(done, total int)) error {
+	// The state file is written before the key, so a missing key means the
+	// previous run stopped before generating it
+	if !crypto.HasPendingKey() {
+		if err := crypto.GeneratePendingKey(); err != nil {
+			return err
+		}
+	}
+
+	to, err := crypto.RestorePendingKey()
+	if err != nil {
+		return err
+	}
+	defer to.Clear()
+
+	keys, err := crypto.Keyring()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearAll(keys)
+
+	ids, err := entry.ListIDs()
+	if err != nil {
+		return err
+	}
+
+	done := make(map[string]bool, len(state.Done))
+	for _, id := range state.Done {
+		done[id] = true
+	}
+
+	sinceCheckpoint := 0
+	for _, id := range ids {
+		if done[id] {
+			continue
+		}
+
+		changed, err := entry.Rewrite(id, func(e *entry.Entry) (bool, error) {
+			return e.Reencrypt(keys, to)
+		})
+		switch {
+		case errors.Is(err, entry.ErrAppendOnly):
+			state.Skipped = append(state.Skipped, id)
+		case err != nil:
+			return err
+		case changed:
+			result.Reencrypted++
+		}
+
+		state.Done = append(state.Done, id)
+		done[id] = true
+		if progress != nil {
+			progress(len(state.Done), len(ids))
+		}
+
+		sinceCheckpoint++
+		if sinceCheckpoint == checkpointEvery {
+			if err := state.save(); err != nil {
+				return err
+			}
+			sinceCheckpoint = 0
+		}
+	}
+
+	return state.save()
+}
+
+// archivedEntries returns the IDs of the entries archived in each year
+func archivedEntries() (map[int][]string, error) {
+	archives, err := entry.Archives()
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return nil, nil
+	}
+	years := make(map[int][]string, len(archives))
+	for _, a := range archives {
+		years[a.Year] = a.Entries
+	}
+	return years, nil
+}
+
+// thaw thaws the archived years among years, so their entries can be
+// re-encrypted with the rest
+func thaw(years map[int][]string) error {
+	archives, err := entry.Archives()
+	if err != nil {
+		return err
+	}
+	for _, a := range archives {
+		if _, ok := years[a.Year]; !ok {
+			continue
+		}
+		if _, err := entry.Thaw(a.Year); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rearchive archives the entries of each year again, leaving out those
+// deleted since they were thawed
+func rearchive(years map[int][]string) error {
+	if len(years) == 0 {
+		return nil
+	}
+	ids, err := entry.ListIDs()
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = true
+	}
+	for year, archived := range years {
+		var keep []string
+		for _, id := range archived {
+			if exists[id] {
+				keep = append(keep, id)
+			}
+		}
+		if len(keep) == 0 {
+			continue
+		}
+		if _, err := entry.ArchiveYear(year, keep); err != nil {
+			return fmt.Errorf("failed to archive %d again: %w", year, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package route picks the journal of a quick entry from the prefix of its
+// first word, following the rules of capture.routes
+package route
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Rule sends entries whose first word matches it to a journal
+type Rule struct {
+	Prefix  string // The whole first word, or the sigil starting it when Journal is empty
+	Journal string // Journal receiving the entries; empty takes the rest of the word
+}
+
+// Wildcard reports whether the rule routes to the journal named by the
+// rest of the word
+func (r Rule) Wildcard() bool {
+	return r.Journal == ""
+}
+
+// Parse reads comma-separated rules: "!todo=todo" sends entries starting
+// with the word !todo to the journal todo, and "@*" sends entries starting
+// with @name to the journal name
+func Parse(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.IndexFunc(field, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("rule '%s' contains a space", field)
+		}
+		if sigil, ok := strings.CutSuffix(field, "*"); ok {
+			if sigil == "" || strings.Contains(sigil, "=") {
+				return nil, fmt.Errorf("rule '%s' needs a prefix before the *, such as @*", field)
+			}
+			rules = append(rules, Rule{Prefix: sigil})
+			continue
+		}
+		prefix, journal, ok := strings.Cut(field, "=")
+		if !ok || prefix == "" || journal == "" {
+			return nil, fmt.Errorf("rule '%s' is neither prefix=journal nor prefix*", field)
+		}
+		rules = append(rules, Rule{Prefix: prefix, Journal: journal})
+	}
+	return rules, nil
+}
+
+// Match returns the first rule the first word of text matches, the journal
+// it routes to and text without that word. It returns a nil rule when none
+// matches.
+func Match(rules []Rule, text string) (*Rule, string, string) {
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	end := strings.IndexFunc(trimmed, unicode.IsSpace)
+	if end < 0 {
+		end = len(trimmed)
+	}
+	word, rest := trimmed[:end], strings.TrimLeftFunc(trimmed[end:], unicode.IsSpace)
+
+	for i, r := range rules {
+		switch {
+		case r.Wildcard():
+			if name := strings.TrimPrefix(word, r.Prefix); name != word && name != "" {
+				return &rules[i], name, rest
+			}
+		case word == r.Prefix:
+			return &rules[i], r.Journal, rest
+		}
+	}
+	return nil, "", text
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/pkg/client"
+)
+
+// maxBatchEntries limits the number of entries of a batch request
+const maxBatchEntries = 1000
+
+// maxBatchBytes limits the size of batch request bodies
+const maxBatchBytes = 32 << 20
+
+// handleCreateEntries serves POST /v1/entries/batch
+func (s *Server) handleCreateEntries(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+
+	var batch []client.NewEntry
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&batch); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	results, err := s.createEntries(scopeFrom(r.Context()), batch)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, results)
+}
+
+// createEntries writes each journal's entries of batch as one entry.Batch
+// while holding its lock; the journals are locked in name order so
+// concurrent batches cannot deadlock. Nothing is written unless every
+// journal is at the revision its entries expect.
+func (s *Server) createEntries(sc *Scope, batch []client.NewEntry) ([]client.BatchEntry, error) {
+	if len(batch) == 0 {
+		return nil, fail(http.StatusBadRequest, "batch is empty")
+	}
+	if len(batch) > maxBatchEntries {
+		return nil, fail(http.StatusBadRequest, "batch has %d entries, at most %d are allowed", len(batch), maxBatchEntries)
+	}
+
+	coll, err := collection.Load()
+	if err != nil {
+		return nil, err
+	}
+	defaultName := coll.ResolveDefaultJournal()
+	byJournal := make(map[string][]int)
+	for i, ne := range batch {
+		if strings.TrimSpace(ne.Body) == "" {
+			return nil, fail(http.StatusBadRequest, "entry %d: entry body is empty", i)
+		}
+		name, err := scopedJournal(sc, coll, ne.Journal)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			name = defaultName
+		}
+		if name == "" {
+			return nil, fail(http.StatusBadRequest, "entry %d: no journal given and no default journal set", i)
+		}
+		byJournal[name] = append(byJournal[name], i)
+	}
+	names := make([]string, 0, len(byJournal))
+	for name := range byJournal {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lock := s.locks.For(name)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	// Re-read under the journal locks so the chain heads are current
+	coll, err = collection.Load()
+	if err != nil {
+		return nil, err
+	}
+	journals := make(map[string]*journal.Journal, len(names))
+	for _, name := range names {
+		j, exists := coll.Journals[name]
+		if !exists {
+			return nil, fail(http.StatusNotFound, "journal '%s' does not exist", name)
+		}
+		journals[name] = journal.FromType(j)
+		for _, i := range byJournal[name] {
+			if expected := batch[i].ExpectedRevision; expected != 0 {
+				if err := journals[name].Expect(expected); err != nil {
+					return nil, saveFailed(fmt.Errorf("entry %d: %w", i, err))
+				}
+			}
+		}
+	}
+
+	results := make([]client.BatchEntry, len(batch))
+	for _, name := range names {
+		if err := createBatch(journals[name], batch, byJournal[name], results); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// createBatch writes the entries of batch at indexes to j, filling in
+// their results. Duplicates, of stored entries or of each other, are
+// skipped unless allowed.
+func createBatch(j *journal.Journal, batch []client.NewEntry, indexes []int, results []client.BatchEntry) error {
+	now := time.Now()
+	firstWith := make(map[string]int)
+	copyOf := make(map[int]int)
+	var kept []int
+	var texts []string
+	for _, i := range indexes {
+		ne := batch[i]
+		if !ne.AllowDuplicates {
+			dup, err := j.FindDuplicate(ne.Body, now)
+			if err != nil {
+				return err
+			}
+			if dup != nil {
+				results[i].Duplicate = dup.ID
+				continue
+			}
+			digest, err := entry.BodyDigest(ne.Body)
+			if err != nil {
+				return err
+			}
+			if first, seen := firstWith[digest]; seen {
+				copyOf[i] = first
+				continue
+			}
+			firstWith[digest] = i
+		}
+		kept = append(kept, i)
+		texts = append(texts, ne.Body)
+	}
+	if len(kept) > 0 {
+		b, err := j.NewBatch(texts)
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+
+		for k, e := range b.Entries {
+			ne := batch[kept[k]]
+			if err := e.SetTags(ne.Tags); err != nil {
+				return err
+			}
+			if err := e.SetTitle(ne.Title); err != nil {
+				return err
+			}
+			e.Sensitive = ne.Sensitive
+		}
+		if err := j.SaveBatch(b); err != nil {
+			return saveFailed(err)
+		}
+		for k, e := range b.Entries {
+			created := createdEntry(e, j.Name, batch[kept[k]])
+			results[kept[k]].Entry = &created
+		}
+	}
+
+	for i, first := range copyOf {
+		results[i].Duplicate = results[first].Entry.ID
+	}
+	return nil
+}
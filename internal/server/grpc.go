@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/pkg/client"
+	"github.com/veritome/jot/pkg/client/jotpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCServer returns a gRPC server offering the API of proto/jot/v1 and the
+// standard health service, which is the only one reachable without the token
+func (s *Server) GRPCServer() *grpc.Server {
+	g := grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxBatchBytes),
+		grpc.UnaryInterceptor(s.authenticateRPC),
+		grpc.StreamInterceptor(s.authenticateStream),
+	)
+	jotpb.RegisterJotServer(g, &grpcAPI{s: s})
+	healthpb.RegisterHealthServer(g, &grpcHealth{s: s})
+	return g
+}
+
+func (s *Server) authenticateRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.checkToken(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.checkToken(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &scopedStream{ServerStream: ss, ctx: ctx})
+}
+
+// scopedStream is a stream whose context carries the scope of its token
+type scopedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *scopedStream) Context() context.Context { return ss.ctx }
+
+// rpcAccess is what each method needs of a scoped token
+var rpcAccess = map[string]string{
+	"CreateEntry":   AccessAppend,
+	"CreateEntries": AccessAppend,
+	"DeleteEntry":   accessDelete,
+}
+
+// checkToken rejects calls without the bearer token or a scoped token
+// permitting them, except health checks, and returns ctx carrying the scope
+func (s *Server) checkToken(ctx context.Context, method string) (context.Context, error) {
+	if strings.HasPrefix(method, "/grpc.health.v1.Health/") {
+		return ctx, nil
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = strings.TrimPrefix(values[0], "Bearer ")
+		}
+	}
+	sc, err := s.scopeOf(token)
+	if err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.status == http.StatusUnauthorized {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+	op, ok := rpcAccess[method[strings.LastIndex(method, "/")+1:]]
+	if !ok {
+		op = AccessRead
+	}
+	if sc != nil && !sc.permits(op) {
+		return nil, status.Error(codes.PermissionDenied, "token does not allow this call")
+	}
+	return context.WithValue(ctx, scopeKey{}, sc), nil
+}
+
+// grpcHealth answers health checks like /healthz
+type grpcHealth struct {
+	healthpb.UnimplementedHealthServer
+	s *Server
+}
+
+func (h *grpcHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service != "" && req.Service != jotpb.Jot_ServiceDesc.ServiceName {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.Service)
+	}
+	serving := healthpb.HealthCheckResponse_SERVING
+	if h.s.draining.Load() || !crypto.HasKey() {
+		serving = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return &healthpb.HealthCheckResponse{Status: serving}, nil
+}
+
+// grpcAPI implements jotpb.JotServer on top of the operations shared with
+// the HTTP API
+type grpcAPI struct {
+	jotpb.UnimplementedJotServer
+	s *Server
+}
+
+func (a *grpcAPI) ListJournals(ctx context.Context, req *jotpb.ListJournalsRequest) (*jotpb.ListJournalsResponse, error) {
+	journals, err := a.s.journals(scopeFrom(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &jotpb.ListJournalsResponse{Journals: make([]*jotpb.Journal, len(journals))}
+	for i, j := range journals {
+		resp.Journals[i] = &jotpb.Journal{
+			Name:     j.Name,
+			Created:  timestamppb.New(j.Created),
+			Entries:  int64(j.Entries),
+			Revision: j.Revision,
+			Default:  j.Default,
+		}
+	}
+	return resp, nil
+}
+
+func (a *grpcAPI) ListEntries(ctx context.Context, req *jotpb.ListEntriesRequest) (*jotpb.ListEntriesResponse, error) {
+	entries, err := a.s.journalEntries(scopeFrom(ctx), req.Journal)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &jotpb.ListEntriesResponse{Entries: toProtoEntries(entries)}, nil
+}
+
+func (a *grpcAPI) GetEntry(ctx context.Context, req *jotpb.GetEntryRequest) (*jotpb.Entry, error) {
+	e, err := a.s.entry(scopeFrom(ctx), req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProtoEntry(e), nil
+}
+
+func (a *grpcAPI) CreateEntry(ctx context.Context, req *jotpb.NewEntry) (*jotpb.Entry, error) {
+	e, err := a.s.createEntry(scopeFrom(ctx), fromProtoNewEntry(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProtoEntry(e), nil
+}
+
+func (a *grpcAPI) CreateEntries(ctx context.Context, req *jotpb.CreateEntriesRequest) (*jotpb.CreateEntriesResponse, error) {
+	batch := make([]client.NewEntry, len(req.Entries))
+	for i, ne := range req.Entries {
+		batch[i] = fromProtoNewEntry(ne)
+	}
+	results, err := a.s.createEntries(scopeFrom(ctx), batch)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &jotpb.CreateEntriesResponse{Results: make([]*jotpb.BatchEntry, len(results))}
+	for i, r := range results {
+		resp.Results[i] = &jotpb.BatchEntry{Duplicate: r.Duplicate}
+		if r.Entry != nil {
+			resp.Results[i].Entry = toProtoEntry(*r.Entry)
+		}
+	}
+	return resp, nil
+}
+
+func (a *grpcAPI) DeleteEntry(ctx context.Context, req *jotpb.DeleteEntryRequest) (*jotpb.DeleteEntryResponse, error) {
+	if err := a.s.deleteEntry(scopeFrom(ctx), req.Id, req.Journal, req.ExpectedRevision); err != nil {
+		return nil, grpcError(err)
+	}
+	return &jotpb.DeleteEntryResponse{}, nil
+}
+
+func (a *grpcAPI) Search(ctx context.Context, req *jotpb.SearchRequest) (*jotpb.SearchResponse, error) {
+	matches, err := a.s.search(scopeFrom(ctx), req.Query, req.Journal)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &jotpb.SearchResponse{Entries: toProtoEntries(matches)}, nil
+}
+
+// grpcError converts an error of the shared operations to a gRPC status
+func grpcError(err error) error {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	code := codes.Unknown
+	switch apiErr.status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+		if errors.Is(err, journal.ErrRevisionConflict) {
+			code = codes.Aborted
+		}
+	}
+	return status.Error(code, err.Error())
+}
+
+func fromProtoNewEntry(ne *jotpb.NewEntry) client.NewEntry {
+	return client.NewEntry{
+		Journal:         ne.Journal,
+		Title:           ne.Title,
+		Body:            ne.Body,
+		Tags:            ne.Tags,
+		Sensitive:       ne.Sensitive,
+		AllowDuplicates: ne.AllowDuplicates,
+
+		ExpectedRevision: ne.ExpectedRevision,
+	}
+}
+
+func toProtoEntry(e client.Entry) *jotpb.Entry {
+	return &jotpb.Entry{
+		Id:        e.ID,
+		Journal:   e.Journal,
+		Author:    e.Author,
+		Created:   timestamppb.New(e.Created),
+		Title:     e.Title,
+		Body:      e.Body,
+		Tags:      e.Tags,
+		Sensitive: e.Sensitive,
+	}
+}
+
+func toProtoEntries(entries []client.Entry) []*jotpb.Entry {
+	out := make([]*jotpb.Entry, len(entries))
+	for i, e := range entries {
+		out[i] = toProtoEntry(e)
+	}
+	return out
+}
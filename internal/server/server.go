@@ -0,0 +1,611 @@
+// Package server serves the jot HTTP JSON API described in pkg/client, and
+// the same operations over gRPC.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/crypto"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/pkg/client"
+)
+
+// maxBodyBytes limits the size of request bodies
+const maxBodyBytes = 1 << 20
+
+// validID matches entry IDs accepted in URLs
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Server answers API requests against the local data directory
+type Server struct {
+	token    string
+	locks    *journal.Locks
+	draining atomic.Bool
+}
+
+// New creates a server that requires token on every request
+func New(token string) *Server {
+	return &Server{token: token, locks: journal.NewLocks()}
+}
+
+// Handler returns the HTTP handler serving the API. Only /healthz can be
+// reached without the token.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/journals", s.handleJournals)
+	mux.HandleFunc("/v1/journals/", s.handleJournalEntries)
+	mux.HandleFunc("/v1/entries", s.handleCreateEntry)
+	mux.HandleFunc("/v1/entries/batch", s.handleCreateEntries)
+	mux.HandleFunc("/v1/entries/", s.handleEntry)
+	mux.HandleFunc("/v1/search", s.handleSearch)
+
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", s.handleHealth)
+	root.Handle("/", s.authenticate(mux))
+	return root
+}
+
+// Drain makes health checks fail from now on, so that supervisors and load
+// balancers stop sending requests while the server shuts down
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// handleHealth reports whether the server can serve requests: it is not
+// shutting down and the data directory has a key to encrypt entries with
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	if s.draining.Load() {
+		writeError(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	if !crypto.HasKey() {
+		writeError(w, http.StatusServiceUnavailable, "no key pair in the data directory")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Status string `json:"status"`
+	}{"ok"})
+}
+
+// authenticate rejects requests without the bearer token or a scoped
+// token permitting them, passing the scope on in the request's context
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sc, err := s.scopeOf(token)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+		op := AccessRead
+		switch {
+		case r.Method == http.MethodDelete:
+			op = accessDelete
+		case r.Method == http.MethodPost:
+			op = AccessAppend
+		}
+		if sc != nil && !sc.permits(op) {
+			writeError(w, http.StatusForbidden, "token does not allow this request")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeKey{}, sc)))
+	})
+}
+
+// scopeKey is the context key of the *Scope of a request, nil for the
+// full token
+type scopeKey struct{}
+
+func scopeFrom(ctx context.Context) *Scope {
+	sc, _ := ctx.Value(scopeKey{}).(*Scope)
+	return sc
+}
+
+// scopeOf returns the scope of token, nil for the full token
+func (s *Server) scopeOf(token string) (*Scope, error) {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+		return nil, nil
+	}
+	if token != "" {
+		sc, err := findScope(token)
+		if err != nil {
+			return nil, err
+		}
+		if sc != nil {
+			return sc, nil
+		}
+	}
+	return nil, fail(http.StatusUnauthorized, "invalid or missing token")
+}
+
+// scopedJournal returns the name of the journal the request may reach: only,
+// which must be the scope's journal unless sc is nil. An empty only is
+// the scope's journal, or any journal for the full token.
+func scopedJournal(sc *Scope, coll *collection.Collection, only string) (string, error) {
+	if sc == nil {
+		return only, nil
+	}
+	name := sc.journal(coll)
+	if name == "" || (only != "" && only != name) {
+		if only == "" {
+			only = name
+		}
+		return "", fail(http.StatusNotFound, "journal '%s' does not exist", only)
+	}
+	return name, nil
+}
+
+func (s *Server) handleJournals(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	journals, err := s.journals(scopeFrom(r.Context()))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, journals)
+}
+
+// handleJournalEntries serves /v1/journals/{name}/entries
+func (s *Server) handleJournalEntries(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/journals/")
+	if !strings.HasSuffix(name, "/entries") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	name = strings.TrimSuffix(name, "/entries")
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	entries, err := s.journalEntries(scopeFrom(r.Context()), name)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleCreateEntry(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+
+	var ne client.NewEntry
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&ne); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	e, err := s.createEntry(scopeFrom(r.Context()), ne)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, e)
+}
+
+// handleEntry serves GET and DELETE /v1/entries/{id}. A delete given a
+// journal parameter only removes the entry if it belongs to that journal,
+// and one given expected_revision only if the journal is at that revision.
+func (s *Server) handleEntry(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/entries/")
+	if !validID.MatchString(id) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if !allow(w, r, http.MethodGet, http.MethodDelete) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		e, err := s.entry(scopeFrom(r.Context()), id)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, e)
+		return
+	}
+	var expected int64
+	if v := r.URL.Query().Get("expected_revision"); v != "" {
+		var err error
+		if expected, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid expected_revision: %v", err))
+			return
+		}
+	}
+	if err := s.deleteEntry(scopeFrom(r.Context()), id, r.URL.Query().Get("journal"), expected); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSearch returns entries whose body contains q, ignoring case
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	matches, err := s.search(scopeFrom(r.Context()), r.URL.Query().Get("q"), r.URL.Query().Get("journal"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, matches)
+}
+
+// The operations below serve both the HTTP and the gRPC API. Errors other
+// than *apiError are internal. They take the scope of the request, nil for
+// the full token, and treat journals outside it as not existing.
+
+// apiError is a request that cannot be served, with the HTTP status it is
+// answered with
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e *apiError) Error() string { return e.err.Error() }
+func (e *apiError) Unwrap() error { return e.err }
+
+// fail returns an *apiError with a formatted message
+func fail(status int, format string, args ...interface{}) error {
+	return &apiError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+// saveFailed classifies an error saving to a journal: losing a race to
+// another writer is a conflict, anything else is internal
+func saveFailed(err error) error {
+	if errors.Is(err, journal.ErrRevisionConflict) {
+		return &apiError{status: http.StatusConflict, err: err}
+	}
+	return err
+}
+
+func (s *Server) journals(sc *Scope) ([]client.Journal, error) {
+	coll, err := collection.Load()
+	if err != nil {
+		return nil, err
+	}
+	only := ""
+	if sc != nil {
+		if only = sc.journal(coll); only == "" {
+			return []client.Journal{}, nil
+		}
+	}
+
+	defaultName := coll.ResolveDefaultJournal()
+	journals := make([]client.Journal, 0, len(coll.Journals))
+	for _, j := range coll.Journals {
+		if only != "" && j.Name != only {
+			continue
+		}
+		journals = append(journals, client.Journal{
+			Name:     j.Name,
+			Created:  j.Created,
+			Entries:  len(j.EntryIDs),
+			Revision: j.Revision,
+			Default:  j.Name == defaultName,
+		})
+	}
+	sort.Slice(journals, func(a, b int) bool { return journals[a].Name < journals[b].Name })
+	return journals, nil
+}
+
+func (s *Server) journalEntries(sc *Scope, name string) ([]client.Entry, error) {
+	coll, err := collection.Load()
+	if err != nil {
+		return nil, err
+	}
+	if name, err = scopedJournal(sc, coll, name); err != nil {
+		return nil, err
+	}
+	j, exists := coll.Journals[name]
+	if !exists {
+		return nil, fail(http.StatusNotFound, "journal '%s' does not exist", name)
+	}
+
+	lock := s.locks.For(name)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	entries, err := journal.FromType(j).GetEntries()
+	if err != nil {
+		return nil, err
+	}
+	return decryptAll(coll, entries)
+}
+
+func (s *Server) createEntry(sc *Scope, ne client.NewEntry) (client.Entry, error) {
+	if strings.TrimSpace(ne.Body) == "" {
+		return client.Entry{}, fail(http.StatusBadRequest, "entry body is empty")
+	}
+
+	coll, err := collection.Load()
+	if err != nil {
+		return client.Entry{}, err
+	}
+	name, err := scopedJournal(sc, coll, ne.Journal)
+	if err != nil {
+		return client.Entry{}, err
+	}
+	if name == "" {
+		name = coll.ResolveDefaultJournal()
+		if name == "" {
+			return client.Entry{}, fail(http.StatusBadRequest, "no journal given and no default journal set")
+		}
+	}
+
+	lock := s.locks.For(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Re-read under the journal lock so the chain head is current
+	coll, err = collection.Load()
+	if err != nil {
+		return client.Entry{}, err
+	}
+	j, exists := coll.Journals[name]
+	if !exists {
+		return client.Entry{}, fail(http.StatusNotFound, "journal '%s' does not exist", name)
+	}
+
+	wrappedJ := journal.FromType(j)
+	if ne.ExpectedRevision != 0 {
+		if err := wrappedJ.Expect(ne.ExpectedRevision); err != nil {
+			return client.Entry{}, saveFailed(err)
+		}
+	}
+	if !ne.AllowDuplicates {
+		dup, err := wrappedJ.FindDuplicate(ne.Body, time.Now())
+		if err != nil {
+			return client.Entry{}, err
+		}
+		if dup != nil {
+			return client.Entry{}, fail(http.StatusConflict, "entry %s has the same text; set allow_duplicates to add it anyway", dup.ID)
+		}
+	}
+	e, err := wrappedJ.NewEntry(ne.Body)
+	if err != nil {
+		return client.Entry{}, err
+	}
+	if err := e.SetTags(ne.Tags); err != nil {
+		return client.Entry{}, err
+	}
+	if err := e.SetTitle(ne.Title); err != nil {
+		return client.Entry{}, err
+	}
+	e.Sensitive = ne.Sensitive
+	if err := wrappedJ.SaveEntry(e); err != nil {
+		return client.Entry{}, saveFailed(err)
+	}
+	return createdEntry(e, name, ne), nil
+}
+
+// createdEntry returns the API representation of e, just created from ne
+// in the named journal, without decrypting anything
+func createdEntry(e *entry.Entry, name string, ne client.NewEntry) client.Entry {
+	return client.Entry{
+		ID:        e.ID,
+		Journal:   name,
+		Author:    e.Author,
+		Created:   e.Created,
+		Title:     strings.TrimSpace(ne.Title),
+		Body:      ne.Body,
+		Tags:      entry.NormalizeTags(ne.Tags),
+		Sensitive: e.Sensitive,
+	}
+}
+
+// loadEntry reads an entry, reporting a missing one as not found
+func loadEntry(id string) (*entry.Entry, error) {
+	if !validID.MatchString(id) {
+		return nil, fail(http.StatusNotFound, "entry %s does not exist", id)
+	}
+	e, err := entry.Load(id)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fail(http.StatusNotFound, "entry %s does not exist", id)
+	}
+	return e, err
+}
+
+func (s *Server) entry(sc *Scope, id string) (client.Entry, error) {
+	e, err := loadEntry(id)
+	if err != nil {
+		return client.Entry{}, err
+	}
+	coll, err := collection.Load()
+	if err != nil {
+		return client.Entry{}, err
+	}
+	if sc != nil && sc.JournalID != e.JournalID {
+		return client.Entry{}, fail(http.StatusNotFound, "entry %s does not exist", id)
+	}
+	out, err := decryptAll(coll, []*entry.Entry{e})
+	if err != nil {
+		return client.Entry{}, err
+	}
+	return out[0], nil
+}
+
+// deleteEntry removes an entry, which must belong to the journal only
+// unless it is empty, and fails with 409 unless the journal is at the
+// expected revision, when one is given
+func (s *Server) deleteEntry(sc *Scope, id, only string, expected int64) error {
+	if sc != nil {
+		return fail(http.StatusForbidden, "token does not allow deleting entries")
+	}
+	e, err := loadEntry(id)
+	if err != nil {
+		return err
+	}
+	coll, err := collection.Load()
+	if err != nil {
+		return err
+	}
+
+	name := coll.JournalName(e.JournalID)
+	if only != "" && name != only {
+		return fail(http.StatusNotFound, "entry %s does not exist in journal '%s'", id, only)
+	}
+	lock := s.locks.For(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	coll, err = collection.Load()
+	if err != nil {
+		return err
+	}
+	j, exists := coll.JournalByID(e.JournalID)
+	if !exists {
+		return fail(http.StatusNotFound, "journal '%s' does not exist", name)
+	}
+	if e.Sealed() || j.AppendOnly {
+		return &apiError{status: http.StatusForbidden, err: entry.ErrAppendOnly}
+	}
+
+	wrappedJ := journal.FromType(j)
+	if expected != 0 {
+		if err := wrappedJ.Expect(expected); err != nil {
+			return saveFailed(err)
+		}
+	}
+	// The entry leaves the journal first, so that a conflict keeps both
+	if err := wrappedJ.RemoveEntry(id); err != nil {
+		return saveFailed(err)
+	}
+	return e.Delete()
+}
+
+// search returns the entries whose body contains query, ignoring case, in
+// the journal only or in all journals when it is empty
+func (s *Server) search(sc *Scope, query, only string) ([]client.Entry, error) {
+	query = strings.ToLower(query)
+	if query == "" {
+		return nil, fail(http.StatusBadRequest, "missing query parameter q")
+	}
+
+	coll, err := collection.Load()
+	if err != nil {
+		return nil, err
+	}
+	if only, err = scopedJournal(sc, coll, only); err != nil {
+		return nil, err
+	}
+	if only != "" {
+		if _, exists := coll.Journals[only]; !exists {
+			return nil, fail(http.StatusNotFound, "journal '%s' does not exist", only)
+		}
+	}
+
+	names := make([]string, 0, len(coll.Journals))
+	for name := range coll.Journals {
+		if only == "" || name == only {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	matches := make([]client.Entry, 0)
+	for _, name := range names {
+		entries, err := journal.FromType(coll.Journals[name]).GetEntries()
+		if err != nil {
+			return nil, err
+		}
+		decrypted, err := decryptAll(coll, entries)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range decrypted {
+			if strings.Contains(strings.ToLower(e.Body), query) {
+				matches = append(matches, e)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// decryptAll converts entries to their API representation, naming their
+// journals as listed in coll
+func decryptAll(coll *collection.Collection, entries []*entry.Entry) ([]client.Entry, error) {
+	out := make([]client.Entry, 0, len(entries))
+	for _, e := range entries {
+		body, err := e.GetDecryptedBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+		}
+		tags, err := e.GetTags()
+		if err != nil {
+			return nil, err
+		}
+		title, err := e.GetTitle()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, client.Entry{
+			ID:        e.ID,
+			Journal:   coll.JournalName(e.JournalID),
+			Author:    e.Author,
+			Created:   e.Created,
+			Title:     title,
+			Body:      body,
+			Tags:      tags,
+			Sensitive: e.Sensitive,
+		})
+	}
+	return out, nil
+}
+
+// allow reports whether the request method is one of methods, answering
+// 405 Method Not Allowed otherwise
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeFailure answers with the status of an *apiError, or 500 for other
+// errors
+func writeFailure(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		status = apiErr.status
+	}
+	writeError(w, status, err.Error())
+}
+
+// writeError answers with the error body understood by client.Error
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{message})
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+)
+
+// tokenFile holds the API token clients must present
+const tokenFile = "server.token"
+
+// scopedTokenFile, in the data directory, lists the tokens minted for
+// plugins and other programs trusted with a single journal. Only a hash of
+// each token is kept.
+const scopedTokenFile = "server.tokens"
+
+// Access levels of scoped tokens
+const (
+	AccessRead   = "read"
+	AccessAppend = "append"
+
+	// accessDelete is needed to delete entries, which no scoped token has
+	accessDelete = "delete"
+)
+
+// Scope is what a scoped token may do: read one journal, and with
+// AccessAppend add entries to it. No scoped token can delete.
+type Scope struct {
+	ID        string    `json:"id"`
+	Hash      string    `json:"hash"`
+	JournalID string    `json:"journal_id"`
+	Access    string    `json:"access"`
+	Created   time.Time `json:"created"`
+}
+
+// TokenPath returns the location of the API token file, which is
+// server.token_file when set
+func TokenPath() (string, error) {
+	cfg, err := config.Current()
+	if err != nil {
+		return "", err
+	}
+	if path := cfg.String("server.token_file"); path != "" {
+		return config.ExpandHome(path)
+	}
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, tokenFile), nil
+}
+
+// LoadOrCreateToken returns the stored API token, generating one on first use
+func LoadOrCreateToken() (string, error) {
+	path, err := TokenPath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := storage.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read API token: %w", err)
+	}
+
+	return RotateToken()
+}
+
+// RotateToken replaces the API token with a new random one
+func RotateToken() (string, error) {
+	path, err := TokenPath()
+	if err != nil {
+		return "", err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := storage.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := storage.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write API token: %w", err)
+	}
+	return token, nil
+}
+
+// newToken returns a random token
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MintToken creates a token limited to the named journal with access,
+// AccessRead or AccessAppend. The token is only returned here; the server
+// keeps its hash.
+func MintToken(journal, access string) (string, *Scope, error) {
+	if access != AccessRead && access != AccessAppend {
+		return "", nil, fmt.Errorf("invalid access %q: use %s or %s", access, AccessRead, AccessAppend)
+	}
+	coll, err := collection.Load()
+	if err != nil {
+		return "", nil, err
+	}
+	j, exists := coll.Journals[journal]
+	if !exists {
+		return "", nil, fmt.Errorf("journal '%s' does not exist", journal)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	hash := hashToken(token)
+	scope := &Scope{ID: hash[:12], Hash: hash, JournalID: j.ID, Access: access, Created: time.Now()}
+	scopes, err := ScopedTokens()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := writeScopes(append(scopes, scope)); err != nil {
+		return "", nil, err
+	}
+	return token, scope, nil
+}
+
+// ScopedTokens returns the scoped tokens minted so far
+func ScopedTokens() ([]*Scope, error) {
+	path, err := scopesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scoped tokens: %w", err)
+	}
+	var scopes []*Scope
+	if err := json.Unmarshal(data, &scopes); err != nil {
+		return nil, fmt.Errorf("failed to parse scoped tokens: %w", err)
+	}
+	return scopes, nil
+}
+
+// RevokeToken removes the scoped token with id, or with an ID starting
+// with it if that is unambiguous
+func RevokeToken(id string) error {
+	scopes, err := ScopedTokens()
+	if err != nil {
+		return err
+	}
+	match := -1
+	for i, scope := range scopes {
+		if id != "" && strings.HasPrefix(scope.ID, id) {
+			if match >= 0 {
+				return fmt.Errorf("token ID %s is ambiguous", id)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return fmt.Errorf("no scoped token %s", id)
+	}
+	return writeScopes(append(scopes[:match], scopes[match+1:]...))
+}
+
+// findScope returns the scoped token matching token, or nil
+func findScope(token string) (*Scope, error) {
+	scopes, err := ScopedTokens()
+	if err != nil {
+		return nil, err
+	}
+	hash := hashToken(token)
+	for _, scope := range scopes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(scope.Hash)) == 1 {
+			return scope, nil
+		}
+	}
+	return nil, nil
+}
+
+// permits reports whether the token may perform op: AccessRead,
+// AccessAppend or accessDelete
+func (sc *Scope) permits(op string) bool {
+	switch op {
+	case AccessRead:
+		return true
+	case AccessAppend:
+		return sc.Access == AccessAppend
+	}
+	return false
+}
+
+// journal returns the name of the journal the token is limited to, empty
+// when that journal was deleted
+func (sc *Scope) journal(coll *collection.Collection) string {
+	if j, exists := coll.JournalByID(sc.JournalID); exists {
+		return j.Name
+	}
+	return ""
+}
+
+func scopesPath() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, scopedTokenFile), nil
+}
+
+func writeScopes(scopes []*Scope) error {
+	path, err := scopesPath()
+	if err != nil {
+		return err
+	}
+	if scopes == nil {
+		scopes = []*Scope{}
+	}
+	data, err := json.MarshalIndent(scopes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scoped tokens: %w", err)
+	}
+	if err := storage.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := storage.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write scoped tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/veritome/jot/internal/server"
+	"github.com/veritome/jot/pkg/client"
+	"github.com/veritome/jot/pkg/client/jotpb"
+	"github.com/veritome/jot/pkg/jottest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scoped starts a server with the journals work and home, each holding an
+// entry, and returns a client using a token minted for work with access,
+// the full-token client and the IDs of the work and home entries
+func scoped(t *testing.T, access string) (c, full *client.Client, work, home string) {
+	t.Helper()
+	ctx := context.Background()
+	env := jottest.New(t)
+	env.AddJournal("work")
+	env.AddJournal("home")
+
+	srv := httptest.NewServer(server.New(env.Token).Handler())
+	t.Cleanup(srv.Close)
+	full = client.New(srv.URL, env.Token)
+	for _, name := range []string{"work", "home"} {
+		e, err := full.CreateEntry(ctx, client.NewEntry{Journal: name, Body: "In " + name})
+		if err != nil {
+			t.Fatalf("CreateEntry: %v", err)
+		}
+		if name == "work" {
+			work = e.ID
+		} else {
+			home = e.ID
+		}
+	}
+
+	token, _, err := server.MintToken("work", access)
+	if err != nil {
+		t.Fatalf("MintToken: %v", err)
+	}
+	return client.New(srv.URL, token), full, work, home
+}
+
+// wantStatus fails t unless err is an *client.Error with status code
+func wantStatus(t *testing.T, what string, err error, code int) {
+	t.Helper()
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != code {
+		t.Errorf("%s error = %v, want %d", what, err, code)
+	}
+}
+
+func TestReadTokenSeesOnlyItsJournal(t *testing.T) {
+	ctx := context.Background()
+	c, _, work, home := scoped(t, server.AccessRead)
+
+	journals, err := c.Journals(ctx)
+	if err != nil {
+		t.Fatalf("Journals: %v", err)
+	}
+	if len(journals) != 1 || journals[0].Name != "work" {
+		t.Errorf("Journals = %+v, want only work", journals)
+	}
+	if _, err := c.Entry(ctx, work); err != nil {
+		t.Errorf("Entry of its journal: %v", err)
+	}
+
+	_, err = c.Entries(ctx, "home")
+	wantStatus(t, "Entries of another journal", err, http.StatusNotFound)
+	_, err = c.Entry(ctx, home)
+	wantStatus(t, "Entry of another journal", err, http.StatusNotFound)
+
+	matches, err := c.Search(ctx, "in", "")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != work {
+		t.Errorf("Search = %+v, want only %s", matches, work)
+	}
+}
+
+func TestReadTokenCannotWrite(t *testing.T) {
+	ctx := context.Background()
+	c, _, work, _ := scoped(t, server.AccessRead)
+
+	_, err := c.CreateEntry(ctx, client.NewEntry{Journal: "work", Body: "Not allowed"})
+	wantStatus(t, "CreateEntry", err, http.StatusForbidden)
+	_, err = c.CreateEntries(ctx, []client.NewEntry{{Journal: "work", Body: "Not allowed"}})
+	wantStatus(t, "CreateEntries", err, http.StatusForbidden)
+	wantStatus(t, "DeleteEntry", c.DeleteEntry(ctx, work), http.StatusForbidden)
+}
+
+func TestAppendTokenWritesOnlyItsJournal(t *testing.T) {
+	ctx := context.Background()
+	c, full, work, _ := scoped(t, server.AccessAppend)
+
+	e, err := c.CreateEntry(ctx, client.NewEntry{Body: "No journal named"})
+	if err != nil {
+		t.Fatalf("CreateEntry: %v", err)
+	}
+	if e.Journal != "work" {
+		t.Errorf("entry created in %q, want the token's journal work", e.Journal)
+	}
+
+	_, err = c.CreateEntry(ctx, client.NewEntry{Journal: "home", Body: "Elsewhere"})
+	wantStatus(t, "CreateEntry in another journal", err, http.StatusNotFound)
+	_, err = c.CreateEntries(ctx, []client.NewEntry{{Journal: "work", Body: "Here"}, {Journal: "home", Body: "Elsewhere"}})
+	wantStatus(t, "CreateEntries reaching another journal", err, http.StatusNotFound)
+	wantStatus(t, "DeleteEntry", c.DeleteEntry(ctx, work), http.StatusForbidden)
+
+	entries, err := full.Entries(ctx, "home")
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("home has %d entries, want only the one it started with", len(entries))
+	}
+}
+
+func TestRevokedToken(t *testing.T) {
+	ctx := context.Background()
+	c, _, _, _ := scoped(t, server.AccessRead)
+
+	scopes, err := server.ScopedTokens()
+	if err != nil || len(scopes) != 1 {
+		t.Fatalf("ScopedTokens = %v, %v, want the minted token", scopes, err)
+	}
+	if err := server.RevokeToken(scopes[0].ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	_, err = c.Journals(ctx)
+	wantStatus(t, "Journals with a revoked token", err, http.StatusUnauthorized)
+}
+
+func TestScopedTokenOverGRPC(t *testing.T) {
+	ctx := context.Background()
+	env := jottest.New(t)
+	env.AddJournal("work")
+	env.AddJournal("home")
+	token, _, err := server.MintToken("work", server.AccessRead)
+	if err != nil {
+		t.Fatalf("MintToken: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	srv := server.New(env.Token).GRPCServer()
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	conn, err := client.DialGRPC(l.Addr().String(), token)
+	if err != nil {
+		t.Fatalf("DialGRPC: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := jotpb.NewJotClient(conn)
+
+	resp, err := c.ListJournals(ctx, &jotpb.ListJournalsRequest{})
+	if err != nil {
+		t.Fatalf("ListJournals: %v", err)
+	}
+	if len(resp.Journals) != 1 || resp.Journals[0].Name != "work" {
+		t.Errorf("ListJournals = %v, want only work", resp.Journals)
+	}
+	if _, err := c.CreateEntry(ctx, &jotpb.NewEntry{Journal: "work", Body: "Not allowed"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CreateEntry error = %v, want PermissionDenied", err)
+	}
+	if _, err := c.ListEntries(ctx, &jotpb.ListEntriesRequest{Journal: "home"}); status.Code(err) != codes.NotFound {
+		t.Errorf("ListEntries of another journal error = %v, want NotFound", err)
+	}
+}
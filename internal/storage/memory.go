@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNotEmpty is returned when removing a directory that still has files
+var errNotEmpty = errors.New("directory not empty")
+
+// Memory is storage kept in the process, for tests. Paths are cleaned
+// with filepath.Clean; the root always exists. It is safe for concurrent
+// use.
+type Memory struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+	locks map[string]*sync.Mutex
+}
+
+type memFile struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// NewMemory returns empty in-memory storage
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string]*memFile), locks: make(map[string]*sync.Mutex)}
+}
+
+// file returns the file or directory at the cleaned path name
+func (m *Memory) file(name string) (*memFile, bool) {
+	if isRoot(name) {
+		return &memFile{mode: os.ModeDir | 0700}, true
+	}
+	f, ok := m.files[name]
+	return f, ok
+}
+
+func isRoot(name string) bool {
+	return filepath.Dir(name) == name
+}
+
+// checkParent fails unless the directory holding name exists
+func (m *Memory) checkParent(op, name string) error {
+	parent, ok := m.file(filepath.Dir(name))
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+func (m *Memory) ReadFile(name string) ([]byte, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.file(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (m *Memory) WriteFile(name string, data []byte, perm os.FileMode) error {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkParent("open", name); err != nil {
+		return err
+	}
+	if f, ok := m.file(name); ok && f.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	m.files[name] = &memFile{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) CreateExclusive(name string, perm os.FileMode) error {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkParent("open", name); err != nil {
+		return err
+	}
+	if _, ok := m.file(name); ok {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	m.files[name] = &memFile{mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) ReadDir(name string) ([]os.DirEntry, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.file(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: fs.ErrInvalid}
+	}
+	var entries []os.DirEntry
+	for path, f := range m.files {
+		if filepath.Dir(path) == name && path != name {
+			entries = append(entries, fs.FileInfoToDirEntry(newInfo(filepath.Base(path), f)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *Memory) Stat(name string) (os.FileInfo, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.file(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return newInfo(filepath.Base(name), f), nil
+}
+
+func (m *Memory) MkdirAll(path string, perm os.FileMode) error {
+	path = filepath.Clean(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(path, perm)
+}
+
+func (m *Memory) mkdirAll(path string, perm os.FileMode) error {
+	if f, ok := m.file(path); ok {
+		if !f.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrExist}
+		}
+		return nil
+	}
+	if err := m.mkdirAll(filepath.Dir(path), perm); err != nil {
+		return err
+	}
+	m.files[path] = &memFile{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) Remove(name string) error {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		for path := range m.files {
+			if filepath.Dir(path) == name {
+				return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+			}
+		}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+func (m *Memory) RemoveAll(path string) error {
+	path = filepath.Clean(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.files {
+		if name == path || within(name, path) {
+			delete(m.files, name)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Rename(oldpath, newpath string) error {
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if err := m.checkParent("rename", newpath); err != nil {
+		return err
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	if f.mode.IsDir() && within(newpath, oldpath) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if target, ok := m.files[newpath]; ok && target.mode.IsDir() {
+		for path := range m.files {
+			if filepath.Dir(path) == newpath {
+				return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errNotEmpty}
+			}
+		}
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = f
+	for path, child := range m.files {
+		if within(path, oldpath) {
+			delete(m.files, path)
+			m.files[newpath+strings.TrimPrefix(path, oldpath)] = child
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Chtimes(name string, atime, mtime time.Time) error {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	f.modTime = mtime
+	return nil
+}
+
+// Lock creates dir like the lock file on disk does, then waits for the
+// mutex of dir
+func (m *Memory) Lock(dir string) (func() error, error) {
+	dir = filepath.Clean(dir)
+	m.mu.Lock()
+	if err := m.mkdirAll(dir, 0700); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	l, ok := m.locks[dir]
+	if !ok {
+		l = new(sync.Mutex)
+		m.locks[dir] = l
+	}
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() error {
+		l.Unlock()
+		return nil
+	}, nil
+}
+
+// within reports whether path lies below dir
+func within(path, dir string) bool {
+	if isRoot(dir) {
+		return path != dir
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// memInfo describes a file of Memory
+type memInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newInfo(name string, f *memFile) memInfo {
+	return memInfo{name: name, size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() os.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() interface{}   { return nil }
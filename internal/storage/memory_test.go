@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryReadWrite(t *testing.T) {
+	m := NewMemory()
+	if err := m.MkdirAll("/jot/entries", 0700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	data := []byte("hello")
+	if err := m.WriteFile("/jot/entries/../entries/0001", data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data[0] = 'j'
+
+	got, err := m.ReadFile("/jot/entries/0001")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("ReadFile = %q, want %q unaffected by the caller's buffer", got, "hello")
+	}
+	got[0] = 'j'
+	if again, _ := m.ReadFile("/jot/entries/0001"); string(again) != "hello" {
+		t.Errorf("ReadFile = %q after changing a previous result, want %q", again, "hello")
+	}
+
+	info, err := m.Stat("/jot/entries/0001")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Name() != "0001" || info.Size() != 5 || info.Mode() != 0600 || info.IsDir() {
+		t.Errorf("Stat = %s %d %v %v, want 0001 5 -rw------- false", info.Name(), info.Size(), info.Mode(), info.IsDir())
+	}
+}
+
+func TestMemoryMissing(t *testing.T) {
+	m := NewMemory()
+	if _, err := m.ReadFile("/jot/collection.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile error = %v, want ErrNotExist", err)
+	}
+	if err := m.WriteFile("/jot/collection.json", nil, 0600); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("WriteFile without parent error = %v, want ErrNotExist", err)
+	}
+	if _, err := m.Stat("/"); err != nil {
+		t.Errorf("Stat of the root: %v", err)
+	}
+}
+
+func TestMemoryReadDir(t *testing.T) {
+	m := NewMemory()
+	m.MkdirAll("/jot/entries/sub", 0700)
+	for _, name := range []string{"b", "a", "c"} {
+		if err := m.WriteFile("/jot/entries/"+name, []byte(name), 0600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	m.WriteFile("/jot/entries/sub/deeper", nil, 0600)
+
+	entries, err := m.ReadDir("/jot/entries")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"a", "b", "c", "sub"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir = %q, want %q", names, want)
+	}
+	if !entries[3].IsDir() {
+		t.Errorf("sub is not listed as a directory")
+	}
+}
+
+func TestMemoryCreateExclusive(t *testing.T) {
+	m := NewMemory()
+	m.MkdirAll("/jot", 0700)
+	if err := m.CreateExclusive("/jot/ids/0001", 0600); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("CreateExclusive without parent error = %v, want ErrNotExist", err)
+	}
+	if err := m.CreateExclusive("/jot/0001", 0600); err != nil {
+		t.Fatalf("CreateExclusive: %v", err)
+	}
+	if err := m.CreateExclusive("/jot/0001", 0600); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("second CreateExclusive error = %v, want ErrExist", err)
+	}
+}
+
+func TestMemoryRemove(t *testing.T) {
+	m := NewMemory()
+	m.MkdirAll("/jot/trash", 0700)
+	m.WriteFile("/jot/trash/0001", nil, 0600)
+	m.WriteFile("/jot/trashed", nil, 0600)
+
+	if err := m.Remove("/jot/trash"); !errors.Is(err, errNotEmpty) {
+		t.Errorf("Remove of a directory with files error = %v, want errNotEmpty", err)
+	}
+	if err := m.RemoveAll("/jot/trash"); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if _, err := m.Stat("/jot/trash/0001"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file survived RemoveAll of its directory: %v", err)
+	}
+	if _, err := m.Stat("/jot/trashed"); err != nil {
+		t.Errorf("RemoveAll removed a file sharing the directory's prefix: %v", err)
+	}
+	if err := m.Remove("/jot/trashed"); err != nil {
+		t.Errorf("Remove: %v", err)
+	}
+	if err := m.Remove("/jot/trashed"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("second Remove error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestMemoryRename(t *testing.T) {
+	m := NewMemory()
+	m.MkdirAll("/jot/old/sub", 0700)
+	m.WriteFile("/jot/old/sub/file", []byte("moved"), 0600)
+
+	if err := m.Rename("/jot/old", "/jot/old/sub/inside"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Rename into itself error = %v, want ErrInvalid", err)
+	}
+	if err := m.Rename("/jot/old", "/jot/new"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if got, err := m.ReadFile("/jot/new/sub/file"); err != nil || string(got) != "moved" {
+		t.Errorf("ReadFile after Rename = %q, %v, want %q", got, err, "moved")
+	}
+	if _, err := m.Stat("/jot/old"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("old path still exists after Rename: %v", err)
+	}
+
+	m.MkdirAll("/jot/full", 0700)
+	m.WriteFile("/jot/full/file", nil, 0600)
+	if err := m.Rename("/jot/new", "/jot/full"); !errors.Is(err, errNotEmpty) {
+		t.Errorf("Rename over a directory with files error = %v, want errNotEmpty", err)
+	}
+}
+
+func TestMemoryChtimes(t *testing.T) {
+	m := NewMemory()
+	m.WriteFile("/file", nil, 0600)
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := m.Chtimes("/file", when, when); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if info, _ := m.Stat("/file"); !info.ModTime().Equal(when) {
+		t.Errorf("ModTime = %v, want %v", info.ModTime(), when)
+	}
+}
+
+func TestMemoryLock(t *testing.T) {
+	m := NewMemory()
+	unlock, err := m.Lock("/jot")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if info, err := m.Stat("/jot"); err != nil || !info.IsDir() {
+		t.Errorf("Lock did not create the directory: %v", err)
+	}
+
+	var mu sync.Mutex
+	released := false
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock, err := m.Lock("/jot")
+		if err != nil {
+			t.Errorf("second Lock: %v", err)
+			return
+		}
+		mu.Lock()
+		if !released {
+			t.Errorf("second Lock returned while the first was held")
+		}
+		mu.Unlock()
+		unlock()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	released = true
+	mu.Unlock()
+	unlock()
+	<-done
+}
@@ -0,0 +1,183 @@
+// Package storage is the file system jot keeps journals, entries and keys
+// on. It is the disk by default; tests switch it to memory with Use.
+package storage
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/veritome/jot/internal/fsutil"
+)
+
+// Backend is a file system holding the data directory. Errors for missing
+// files must satisfy os.IsNotExist, and for existing ones os.IsExist.
+type Backend interface {
+	ReadFile(name string) ([]byte, error)
+	// WriteFile replaces name so that readers see either the old or the
+	// new contents, never a mix
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	// CreateExclusive creates an empty file, failing when name exists
+	CreateExclusive(name string, perm os.FileMode) error
+	ReadDir(name string) ([]os.DirEntry, error)
+	Stat(name string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	Rename(oldpath, newpath string) error
+	Chtimes(name string, atime, mtime time.Time) error
+	// Lock blocks until it holds the exclusive lock for dir, returning the
+	// function releasing it
+	Lock(dir string) (func() error, error)
+}
+
+var (
+	mu      sync.RWMutex
+	current Backend = Disk{}
+)
+
+// Use makes b the storage of the process until the returned function puts
+// the previous one back
+func Use(b Backend) (restore func()) {
+	mu.Lock()
+	previous := current
+	current = b
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		current = previous
+		mu.Unlock()
+	}
+}
+
+func backend() Backend {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// ReadFile reads the named file
+func ReadFile(name string) ([]byte, error) {
+	return backend().ReadFile(name)
+}
+
+// WriteFile atomically replaces the named file with data
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	return backend().WriteFile(name, data, perm)
+}
+
+// CreateExclusive creates an empty file, failing when it already exists
+func CreateExclusive(name string, perm os.FileMode) error {
+	return backend().CreateExclusive(name, perm)
+}
+
+// ReadDir lists a directory sorted by file name
+func ReadDir(name string) ([]os.DirEntry, error) {
+	return backend().ReadDir(name)
+}
+
+// Stat describes the named file
+func Stat(name string) (os.FileInfo, error) {
+	return backend().Stat(name)
+}
+
+// MkdirAll creates a directory and any missing parents
+func MkdirAll(path string, perm os.FileMode) error {
+	return backend().MkdirAll(path, perm)
+}
+
+// Remove removes a file or empty directory
+func Remove(name string) error {
+	return backend().Remove(name)
+}
+
+// RemoveAll removes path and everything below it
+func RemoveAll(path string) error {
+	return backend().RemoveAll(path)
+}
+
+// Rename moves a file or directory
+func Rename(oldpath, newpath string) error {
+	return backend().Rename(oldpath, newpath)
+}
+
+// Chtimes changes the access and modification times of a file
+func Chtimes(name string, atime, mtime time.Time) error {
+	return backend().Chtimes(name, atime, mtime)
+}
+
+// Lock is an exclusive lock on a data directory. It serializes mutations
+// between jot processes on disk and goroutines in memory; it is not
+// reentrant.
+type Lock struct {
+	release func() error
+}
+
+// LockDir blocks until it holds the exclusive lock for dir
+func LockDir(dir string) (*Lock, error) {
+	release, err := backend().Lock(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{release: release}, nil
+}
+
+// Unlock releases the lock
+func (l *Lock) Unlock() error {
+	return l.release()
+}
+
+// Disk is the storage of the real file system
+type Disk struct{}
+
+func (Disk) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (Disk) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return fsutil.WriteFileAtomic(name, data, perm)
+}
+
+func (Disk) CreateExclusive(name string, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (Disk) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (Disk) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (Disk) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (Disk) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (Disk) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (Disk) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (Disk) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (Disk) Lock(dir string) (func() error, error) {
+	lock, err := fsutil.LockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return lock.Unlock, nil
+}
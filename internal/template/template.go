@@ -0,0 +1,380 @@
+// Package template keeps entry templates, skeletons such as a weekly
+// planning outline, and creates draft entries from them on a schedule.
+package template
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+	"github.com/veritome/jot/internal/when"
+)
+
+// templatesDir holds one template per file, <name>.txt
+const templatesDir = "templates"
+
+// DefaultAt is the time of day scheduled entries are created at when none
+// is given
+const DefaultAt = "08:00"
+
+// Schedules other than the days of the week
+const (
+	Day     = "day"
+	Weekday = "weekday"
+	Month   = "month"
+)
+
+// Dir returns the directory holding the templates
+func Dir() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return filepath.Join(jotDir, templatesDir), nil
+}
+
+// path returns the file of the template called name
+func path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid template name '%s'", name)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".txt"), nil
+}
+
+// Load returns the text of the template called name
+func Load(name string) (string, error) {
+	p, err := path(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := storage.ReadFile(p)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no template '%s'; create it with jot template edit %s", name, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	return string(data), nil
+}
+
+// Save creates or replaces the template called name
+func Save(name, text string) error {
+	p, err := path(name)
+	if err != nil {
+		return err
+	}
+	if err := storage.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return fmt.Errorf("failed to create templates directory: %w", err)
+	}
+	if err := storage.WriteFile(p, []byte(text), 0600); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the template called name
+func Delete(name string) error {
+	p, err := path(name)
+	if err != nil {
+		return err
+	}
+	if err := storage.Remove(p); os.IsNotExist(err) {
+		return fmt.Errorf("no template '%s'", name)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	return nil
+}
+
+// Names returns the names of all templates, sorted
+func Names() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := storage.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	var names []string
+	for _, f := range files {
+		if name := f.Name(); !f.IsDir() && strings.HasSuffix(name, ".txt") && !strings.HasPrefix(name, ".") {
+			names = append(names, strings.TrimSuffix(name, ".txt"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Render fills in the placeholders of a template for an entry dated t:
+// {date} (2006-01-02), {weekday}, {week} (week number, see
+// calendar.week_numbers), {month} and {year}
+func Render(text string, t time.Time) string {
+	_, week := when.Week(t)
+	return strings.NewReplacer(
+		"{date}", t.Format("2006-01-02"),
+		"{weekday}", t.Weekday().String(),
+		"{week}", strconv.Itoa(week),
+		"{month}", t.Month().String(),
+		"{year}", strconv.Itoa(t.Year()),
+	).Replace(text)
+}
+
+// ParseEvery checks a schedule, returning its stored form: "day", "weekday"
+// (Monday to Friday), "month" (the 1st) or a day of the week such as
+// "monday". "daily", "monthly" and three-letter days are accepted too.
+func ParseEvery(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case Day, "daily":
+		return Day, nil
+	case Weekday, "weekdays":
+		return Weekday, nil
+	case Month, "monthly":
+		return Month, nil
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown schedule '%s': use day, weekday, month or a day of the week", s)
+}
+
+// ParseAt checks a time of day written as HH:MM
+func ParseAt(s string) (string, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("invalid time '%s': use HH:MM, such as 08:30", s)
+	}
+	return t.Format("15:04"), nil
+}
+
+// matches reports whether an occurrence of every can fall on day
+func matches(every string, day time.Time) bool {
+	switch every {
+	case Day:
+		return true
+	case Weekday:
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	case Month:
+		return day.Day() == 1
+	}
+	return strings.ToLower(day.Weekday().String()) == every
+}
+
+// occurrence returns when r would occur on the day of t
+func occurrence(r *types.Recurring, t time.Time) time.Time {
+	at, err := time.Parse("15:04", r.At)
+	if err != nil {
+		at, _ = time.Parse("15:04", DefaultAt)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), at.Hour(), at.Minute(), 0, 0, t.Location())
+}
+
+// Latest returns the latest occurrence of r at or before now
+func Latest(r *types.Recurring, now time.Time) time.Time {
+	for day := 0; day <= 31; day++ {
+		t := occurrence(r, now.AddDate(0, 0, -day))
+		if !t.After(now) && matches(r.Every, t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Next returns the first occurrence of r after now
+func Next(r *types.Recurring, now time.Time) time.Time {
+	for day := 0; day <= 31; day++ {
+		t := occurrence(r, now.AddDate(0, 0, day))
+		if t.After(now) && matches(r.Every, t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Schedule has a draft entry created from template in journalName every
+// time r comes round, starting after now. It replaces an earlier schedule
+// of the same template in the journal.
+func Schedule(journalName, template, every, at string, now time.Time) (*types.Recurring, error) {
+	if _, err := Load(template); err != nil {
+		return nil, err
+	}
+	r := &types.Recurring{Template: template, Every: every, At: at, Last: now}
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		j, exists := coll.Journals[journalName]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", journalName)
+		}
+		if j.AppendOnly {
+			return fmt.Errorf("journal '%s' is append-only; its entries cannot be drafts filled in later", journalName)
+		}
+		kept := j.Recurring[:0]
+		for _, other := range j.Recurring {
+			if other.Template != template {
+				kept = append(kept, other)
+			}
+		}
+		j.Recurring = append(kept, r)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return r, nil
+}
+
+// Unschedule stops creating entries from template in journalName
+func Unschedule(journalName, template string) error {
+	_, err := collection.Update(func(coll *collection.Collection) error {
+		j, exists := coll.Journals[journalName]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", journalName)
+		}
+		kept := j.Recurring[:0]
+		for _, r := range j.Recurring {
+			if r.Template != template {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == len(j.Recurring) {
+			return fmt.Errorf("template '%s' is not scheduled in journal '%s'", template, journalName)
+		}
+		j.Recurring = kept
+		if len(kept) == 0 {
+			j.Recurring = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// Created is a draft entry created by RunDue
+type Created struct {
+	Journal  string
+	Template string
+	EntryID  string
+}
+
+// RunDue creates the draft entries whose time has come, dated when they
+// were due. Only the latest missed occurrence of a schedule is created, so
+// a machine that was off for weeks does not find a pile of drafts.
+func RunDue(now time.Time) ([]Created, error) {
+	coll, err := collection.Load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(coll.Journals))
+	for name, j := range coll.Journals {
+		if len(j.Recurring) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var created []Created
+	var errs []string
+	for _, name := range names {
+		for _, r := range coll.Journals[name].Recurring {
+			due := Latest(r, now)
+			if !due.After(r.Last) {
+				continue
+			}
+			id, err := runOnce(name, r.Template, due)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s in '%s': %v", r.Template, name, err))
+				continue
+			}
+			if id != "" {
+				created = append(created, Created{Journal: name, Template: r.Template, EntryID: id})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return created, fmt.Errorf("failed to create scheduled entries: %s", strings.Join(errs, "; "))
+	}
+	return created, nil
+}
+
+// runOnce claims the occurrence due of template in journalName and creates
+// its draft, handing the occurrence back if that fails. It returns an empty
+// ID when another process claimed it first.
+func runOnce(journalName, template string, due time.Time) (string, error) {
+	var previous time.Time
+	claimed := false
+	coll, err := collection.Update(func(coll *collection.Collection) error {
+		claimed = false
+		j, exists := coll.Journals[journalName]
+		if !exists {
+			return fmt.Errorf("journal '%s' does not exist", journalName)
+		}
+		for _, r := range j.Recurring {
+			if r.Template == template && due.After(r.Last) {
+				previous, r.Last, claimed = r.Last, due, true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save schedule: %w", err)
+	}
+	if !claimed {
+		return "", nil
+	}
+
+	e, err := NewDraft(journal.FromType(coll.Journals[journalName]), template, due)
+	if err != nil {
+		collection.Update(func(coll *collection.Collection) error {
+			if j, exists := coll.Journals[journalName]; exists {
+				for _, r := range j.Recurring {
+					if r.Template == template && r.Last.Equal(due) {
+						r.Last = previous
+					}
+				}
+			}
+			return nil
+		})
+		return "", err
+	}
+	return e.ID, nil
+}
+
+// NewDraft adds a draft entry to j from template, dated at
+func NewDraft(j *journal.Journal, template string, at time.Time) (*entry.Entry, error) {
+	text, err := Load(template)
+	if err != nil {
+		return nil, err
+	}
+	e, err := j.NewEntry(Render(text, at))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create entry: %w", err)
+	}
+	e.Created = at
+	e.Draft = true
+	if err := j.SaveEntry(e); err != nil {
+		return nil, fmt.Errorf("failed to save entry: %w", err)
+	}
+	return e, nil
+}
@@ -0,0 +1,233 @@
+package trash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/internal/types"
+)
+
+const (
+	trashDir    = "trash"
+	journalFile = "journal.json"
+	entriesDir  = "entries"
+)
+
+// Item is a soft-deleted journal waiting in the trash
+type Item struct {
+	Journal   *types.Journal `json:"journal"`
+	DeletedAt time.Time      `json:"deleted_at"`
+
+	dir string // Directory holding the item
+}
+
+// ExpiresAt returns when the item will be purged for good
+func (i *Item) ExpiresAt() time.Time {
+	return i.DeletedAt.AddDate(0, 0, retentionDays())
+}
+
+// retentionDays returns the configured restore window
+func retentionDays() int {
+	if cfg, err := config.Current(); err == nil {
+		return cfg.Int("trash.retention_days")
+	}
+	return 30
+}
+
+func dataDir() (string, error) {
+	jotDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+	return jotDir, nil
+}
+
+// MoveJournal moves a journal and its entry files into the trash
+func MoveJournal(j *types.Journal) (*Item, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := storage.LockDir(jotDir)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	item := &Item{Journal: j, DeletedAt: time.Now()}
+	item.dir = filepath.Join(jotDir, trashDir, fmt.Sprintf("%s-%d", j.Name, item.DeletedAt.UnixNano()))
+	if err := storage.MkdirAll(filepath.Join(item.dir, entriesDir), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal trash item: %w", err)
+	}
+	if err := storage.WriteFile(filepath.Join(item.dir, journalFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write trash item: %w", err)
+	}
+
+	for _, id := range j.EntryIDs {
+		name := id + ".json"
+		err := storage.Rename(filepath.Join(jotDir, entriesDir, name), filepath.Join(item.dir, entriesDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to move entry %s to trash: %w", id, err)
+		}
+	}
+
+	return item, nil
+}
+
+// List returns all items in the trash, most recently deleted first
+func List() ([]*Item, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := storage.ReadDir(filepath.Join(jotDir, trashDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var items []*Item
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(jotDir, trashDir, d.Name())
+		data, err := storage.ReadFile(filepath.Join(dir, journalFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trash item %s: %w", d.Name(), err)
+		}
+		var item Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trash item %s: %w", d.Name(), err)
+		}
+		item.dir = dir
+		items = append(items, &item)
+	}
+
+	sort.Slice(items, func(a, b int) bool { return items[a].DeletedAt.After(items[b].DeletedAt) })
+	return items, nil
+}
+
+// Find returns the most recently deleted item for the named journal
+func Find(name string) (*Item, error) {
+	items, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.Journal.Name == name {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("journal '%s' is not in the trash", name)
+}
+
+// Restore moves the item's entry files back into the entries directory and
+// removes it from the trash. The caller re-adds the returned journal to the
+// collection.
+func Restore(item *Item) (*types.Journal, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := storage.LockDir(jotDir)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	if err := storage.MkdirAll(filepath.Join(jotDir, entriesDir), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create entries directory: %w", err)
+	}
+
+	files, err := storage.ReadDir(filepath.Join(item.dir, entriesDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trashed entries: %w", err)
+	}
+
+	// Refuse to overwrite anything before moving a single file
+	for _, f := range files {
+		if _, err := storage.Stat(filepath.Join(jotDir, entriesDir, f.Name())); err == nil {
+			return nil, fmt.Errorf("entry file %s already exists", f.Name())
+		}
+	}
+	for _, f := range files {
+		if err := storage.Rename(filepath.Join(item.dir, entriesDir, f.Name()), filepath.Join(jotDir, entriesDir, f.Name())); err != nil {
+			return nil, fmt.Errorf("failed to restore entry %s: %w", strings.TrimSuffix(f.Name(), ".json"), err)
+		}
+	}
+
+	if err := storage.RemoveAll(item.dir); err != nil {
+		return nil, fmt.Errorf("failed to remove trash item: %w", err)
+	}
+
+	return item.Journal, nil
+}
+
+// PurgeExpired permanently removes items older than the restore window and
+// returns the names of the purged journals
+func PurgeExpired() ([]string, error) {
+	items, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+	now := time.Now()
+	for _, item := range items {
+		if now.Before(item.ExpiresAt()) {
+			continue
+		}
+		if err := storage.RemoveAll(item.dir); err != nil {
+			return purged, fmt.Errorf("failed to purge trash item for '%s': %w", item.Journal.Name, err)
+		}
+		purged = append(purged, item.Journal.Name)
+	}
+	return purged, nil
+}
+
+// EntryFiles returns the file names of all trashed entries. Entry IDs are
+// never reused while their entry is restorable.
+func EntryFiles() ([]string, error) {
+	paths, err := EntryPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	return names, nil
+}
+
+// EntryPaths returns the paths of all trashed entry files
+func EntryPaths() ([]string, error) {
+	jotDir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(jotDir, trashDir, "*", entriesDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trashed entries: %w", err)
+	}
+	return paths, nil
+}
@@ -0,0 +1,372 @@
+// Package tsa requests and checks RFC 3161 timestamps, in which a trusted
+// timestamping authority signs a hash together with the time it saw it.
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// httpClient is used for all timestamping requests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+var (
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	digestHashes = map[string]crypto.Hash{
+		"1.3.14.3.2.26":          crypto.SHA1,
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+)
+
+// Token is a timestamp whose signature was checked
+type Token struct {
+	Time      time.Time
+	Serial    *big.Int
+	Signer    *x509.Certificate // Certificate of the authority that signed it
+	Untrusted error             // Why the signer is not trusted for timestamping; nil if it is
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional,default:false"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status pkiStatusInfo
+	Token  asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type encapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     []byte `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContent     encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// Request asks the authority at url to timestamp a SHA-256 digest and
+// returns its DER encoded timestamp token, after checking it covers digest
+func Request(ctx context.Context, url string, digest []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	body, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode timestamp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach timestamping authority: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamping authority returned %s", resp.Status)
+	}
+
+	var tsr timeStampResp
+	if _, err := asn1.Unmarshal(data, &tsr); err != nil {
+		return nil, fmt.Errorf("invalid timestamp response: %w", err)
+	}
+	// 0 is granted, 1 granted with modifications
+	if tsr.Status.Status > 1 || len(tsr.Token.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp refused (status %d) %v", tsr.Status.Status, tsr.Status.StatusString)
+	}
+	token := tsr.Token.FullBytes
+
+	info, err := parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(info.imprint, digest) {
+		return nil, fmt.Errorf("timestamp covers a different hash than requested")
+	}
+	if info.nonce == nil || info.nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("timestamp response does not answer this request")
+	}
+	return token, nil
+}
+
+// Verify checks that token is a timestamp of digest signed by the
+// certificate it carries. Whether that certificate chains up to roots, or
+// the system's roots when roots is nil, and is meant for timestamping is
+// reported in Untrusted rather than as an error.
+func Verify(token, digest []byte, roots *x509.CertPool) (*Token, error) {
+	info, err := parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(info.imprint, digest) {
+		return nil, fmt.Errorf("timestamp covers a different hash")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range info.certs {
+		intermediates.AddCert(c)
+	}
+	_, err = info.signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   info.genTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	return &Token{Time: info.genTime, Serial: info.serial, Signer: info.signer, Untrusted: err}, nil
+}
+
+// info is what a signature-checked token says
+type info struct {
+	imprint []byte
+	genTime time.Time
+	serial  *big.Int
+	nonce   *big.Int
+	signer  *x509.Certificate
+	certs   []*x509.Certificate
+}
+
+// parse decodes a timestamp token and checks its signature against the
+// signer certificate included in it
+func parse(token []byte) (*info, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(token, &ci); err != nil || !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("invalid timestamp token")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+	if !sd.EncapContent.ContentType.Equal(oidTSTInfo) || len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("invalid timestamp token: not a single signed TSTInfo")
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil || len(certs) == 0 {
+		return nil, fmt.Errorf("timestamp token carries no certificate of its authority")
+	}
+
+	si := sd.SignerInfos[0]
+	signer, err := findSigner(si.SID, certs)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignature(si, signer, sd.EncapContent.Content); err != nil {
+		return nil, err
+	}
+
+	i, err := parseTSTInfo(sd.EncapContent.Content)
+	if err != nil {
+		return nil, err
+	}
+	i.signer, i.certs = signer, certs
+	return i, nil
+}
+
+// findSigner picks the certificate named by a signer identifier
+func findSigner(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c, nil
+			}
+		}
+	} else {
+		var ias issuerAndSerial
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err == nil {
+			for _, c := range certs {
+				if c.SerialNumber.Cmp(ias.Serial) == 0 && bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+					return c, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("timestamp token does not carry its signer's certificate")
+}
+
+// checkSignature verifies the signer info over the signed attributes,
+// whose message digest must match content
+func checkSignature(si signerInfo, signer *x509.Certificate, content []byte) error {
+	hash, ok := digestHashes[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("unsupported timestamp digest %s", si.DigestAlgorithm.Algorithm)
+	}
+	if len(si.SignedAttrs.FullBytes) == 0 {
+		return fmt.Errorf("timestamp token has no signed attributes")
+	}
+
+	var attrs []attribute
+	if _, err := asn1.UnmarshalWithParams(si.SignedAttrs.FullBytes, &attrs, "set,tag:0"); err != nil {
+		return fmt.Errorf("invalid signed attributes: %w", err)
+	}
+	var digest []byte
+	for _, a := range attrs {
+		if a.Type.Equal(oidMessageDigest) {
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &digest); err != nil {
+				return fmt.Errorf("invalid message digest attribute: %w", err)
+			}
+		}
+	}
+	h := hash.New()
+	h.Write(content)
+	if !bytes.Equal(digest, h.Sum(nil)) {
+		return fmt.Errorf("timestamp signature does not cover its content")
+	}
+
+	// The attributes are signed as a SET, not with their implicit tag
+	signed := append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+	algo, err := signatureAlgorithm(signer, hash, si.SignatureAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	if err := signer.CheckSignature(algo, signed, si.Signature); err != nil {
+		return fmt.Errorf("invalid timestamp signature: %w", err)
+	}
+	return nil
+}
+
+// signatureAlgorithm maps a signer's key and digest to the x509 algorithm
+// checking its signature. RSA-PSS is not supported.
+func signatureAlgorithm(signer *x509.Certificate, hash crypto.Hash, oid asn1.ObjectIdentifier) (x509.SignatureAlgorithm, error) {
+	if oid.Equal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}) {
+		return 0, fmt.Errorf("RSA-PSS timestamp signatures are not supported")
+	}
+	algorithms := map[x509.PublicKeyAlgorithm]map[crypto.Hash]x509.SignatureAlgorithm{
+		x509.RSA: {
+			crypto.SHA1:   x509.SHA1WithRSA,
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		},
+		x509.ECDSA: {
+			crypto.SHA1:   x509.ECDSAWithSHA1,
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		},
+	}
+	if algo, ok := algorithms[signer.PublicKeyAlgorithm][hash]; ok {
+		return algo, nil
+	}
+	return 0, fmt.Errorf("unsupported timestamp signature algorithm %s", oid)
+}
+
+// parseTSTInfo reads the fields of a TSTInfo this package uses. Its
+// optional fields are told apart by their tags, so they are walked one by
+// one rather than unmarshalled into a struct.
+func parseTSTInfo(der []byte) (*info, error) {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil {
+		return nil, fmt.Errorf("invalid TSTInfo: %w", err)
+	}
+	var (
+		version int
+		policy  asn1.ObjectIdentifier
+		mi      messageImprint
+		i       info
+	)
+	rest := seq.Bytes
+	var err error
+	for _, field := range []interface{}{&version, &policy, &mi, &i.serial} {
+		if rest, err = asn1.Unmarshal(rest, field); err != nil {
+			return nil, fmt.Errorf("invalid TSTInfo: %w", err)
+		}
+	}
+	if rest, err = asn1.UnmarshalWithParams(rest, &i.genTime, "generalized"); err != nil {
+		return nil, fmt.Errorf("invalid TSTInfo time: %w", err)
+	}
+	for len(rest) > 0 {
+		var v asn1.RawValue
+		if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+			return nil, fmt.Errorf("invalid TSTInfo: %w", err)
+		}
+		if v.Class == asn1.ClassUniversal && v.Tag == asn1.TagInteger {
+			i.nonce = new(big.Int)
+			if _, err := asn1.Unmarshal(v.FullBytes, &i.nonce); err != nil {
+				return nil, fmt.Errorf("invalid TSTInfo nonce: %w", err)
+			}
+		}
+	}
+	if !mi.HashAlgorithm.Algorithm.Equal(oidSHA256) {
+		return nil, fmt.Errorf("timestamp is not of a SHA-256 hash")
+	}
+	i.imprint = mi.HashedMessage
+	return &i, nil
+}
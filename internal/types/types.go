@@ -4,22 +4,196 @@ import "time"
 
 // Journal represents a collection of entries
 type Journal struct {
+	ID       string    `json:"id,omitempty"` // Stable identifier entries refer to; the name can change
 	Name     string    `json:"name"`
 	Created  time.Time `json:"created"`
-	EntryIDs []string  `json:"entry_ids"`
+	EntryIDs []string  `json:"entry_ids,omitempty"` // Stored as records in journals/<id>/; listed here only by older versions
+	Revision int64     `json:"revision,omitempty"`  // Grows whenever an entry is added, removed or renamed; derived from the records
+
+	AppendOnly bool   `json:"append_only,omitempty"` // Entries can only be added or superseded
+	ChainHead  string `json:"chain_head,omitempty"`  // Hash of the newest entry in an append-only journal
+
+	Goal      *Goal        `json:"goal,omitempty"`      // Writing goal, if one is set
+	Recurring []*Recurring `json:"recurring,omitempty"` // Draft entries created from templates on a schedule
+	Prompt    *Prompt      `json:"prompt,omitempty"`    // Questions the journal asks, if it has any
+
+	SharedDir string   `json:"shared_dir,omitempty"` // Synced folder holding the entries of a shared journal
+	Members   []Member `json:"members,omitempty"`    // Members of a shared journal as last confirmed here
+
+	Quota *Quota `json:"quota,omitempty"` // Soft limits warned about when exceeded
+}
+
+// Member is a member of a shared journal as pinned in the data directory,
+// which new entries are encrypted to and whose signatures are trusted
+type Member struct {
+	Name       string `json:"name"`
+	PublicKey  string `json:"public_key"`            // Base64, as in jot.pub
+	SigningKey string `json:"signing_key,omitempty"` // Base64 ed25519 key the member signs with
+}
+
+// Quota is a journal's soft limits: past them jot warns after adding an
+// entry, but never refuses one. Zero means no limit.
+type Quota struct {
+	Entries int   `json:"entries,omitempty"`
+	Bytes   int64 `json:"bytes,omitempty"`
+}
+
+// Goal is a journal's writing goal: a number of entries per day or week
+type Goal struct {
+	Period  string    `json:"period"` // "daily" or "weekly"
+	Entries int       `json:"entries"`
+	Since   time.Time `json:"since"` // When the goal was set; earlier days never count as missed
+}
+
+// Recurring creates a draft entry from a template on a schedule
+type Recurring struct {
+	Template string    `json:"template"`
+	Every    string    `json:"every"` // "day", "weekday", "month" or a day of the week such as "monday"
+	At       string    `json:"at"`    // Local time of day, HH:MM
+	Last     time.Time `json:"last"`  // Latest occurrence handled; earlier ones are never created
+}
+
+// Prompt is the questions a journal asks, answered one after the other in
+// a single entry, and when to be reminded of them
+type Prompt struct {
+	Questions []string  `json:"questions"`
+	Every     string    `json:"every,omitempty"` // As in Recurring; empty when never reminded
+	At        string    `json:"at,omitempty"`
+	Last      time.Time `json:"last,omitempty"` // Latest reminder handled
 }
 
 // Collection represents all journals and their metadata
 type Collection struct {
-	Journals       map[string]*Journal `json:"journals"`
-	DefaultJournal string              `json:"default_journal"`
+	Journals       map[string]*Journal `json:"journals,omitempty"` // Stored in files of their own, in collection.json only by old versions
+	DefaultJournal string              `json:"default_journal"`    // ID of the default journal
 	NaClKeyID      string              `json:"nacl_key_id,omitempty"`
+	Index          *Index              `json:"index,omitempty"`   // Stored in a file of its own, in collection.json only by old versions
+	Devices        []*Device           `json:"devices,omitempty"` // Machines approved to read the collection
+}
+
+// Device is a machine approved to read the collection with a key pair of
+// its own. Entries are encrypted to the key of every device.
+type Device struct {
+	Name      string     `json:"name"`
+	PublicKey string     `json:"public_key"` // Base64, as in jot.pub
+	Added     time.Time  `json:"added"`
+	Primary   bool       `json:"primary,omitempty"` // The device that approved the first other one
+	Revoked   *time.Time `json:"revoked,omitempty"` // When its access was revoked; entries are no longer encrypted to it
+}
+
+// Index maps tags and creation dates to entry IDs so that searches do not
+// need to load every entry
+type Index struct {
+	Tags      map[string][]string `json:"tags,omitempty"`      // Stored (plain or hashed) tag -> entry IDs
+	Dates     map[string][]string `json:"dates,omitempty"`     // Local creation date as YYYY-MM-DD -> entry IDs
+	Encrypted []string            `json:"encrypted,omitempty"` // Entries whose tags are only stored encrypted
+
+	Revisions map[string]int64 `json:"revisions,omitempty"` // Journal ID -> revision the index is current with
 }
 
 // Entry represents a single journal entry
 type Entry struct {
-	ID        string    `json:"id"`
-	Created   time.Time `json:"created"`
-	Body      []byte    `json:"body"`      // Encrypted content
-	JournalID string    `json:"journalId"` // Reference to parent journal
+	ID        string     `json:"id"`
+	Created   time.Time  `json:"created"`
+	Updated   *time.Time `json:"updated,omitempty"`   // Time of the last edit
+	Body      []byte     `json:"body"`                // Encrypted content, empty when BodyBlob is set
+	BodyBlob  string     `json:"body_blob,omitempty"` // Shared blob holding the encrypted content of large bodies
+	Codec     string     `json:"codec,omitempty"`     // Compression applied to the body before encryption, empty for none
+	Digest    string     `json:"digest,omitempty"`    // Keyed hash of the body, for spotting duplicates
+	JournalID string     `json:"journalId"`           // ID of the parent journal
+	Author    string     `json:"author,omitempty"`    // Member who wrote an entry of a shared journal
+	Signature string     `json:"signature,omitempty"` // The author's signature of a shared entry's body
+	Revisions []Revision `json:"revisions,omitempty"` // Previous bodies, oldest first
+
+	Title string   `json:"title,omitempty"` // Set only when metadata.mode is plain
+	Tags  []string `json:"tags,omitempty"`  // Plain or hashed tags, depending on metadata.mode
+	Meta  []byte   `json:"meta,omitempty"`  // Encrypted metadata unless metadata.mode is plain
+
+	Sensitive bool   `json:"sensitive,omitempty"` // Previews stay hidden in list views until revealed
+	State     string `json:"state,omitempty"`     // "read" or "archived" once processed; empty while unread
+	Draft     bool   `json:"draft,omitempty"`     // Created from a template and not written in yet
+
+	Task bool       `json:"task,omitempty"` // A todo, open until Done is set
+	Done *time.Time `json:"done,omitempty"` // When the task was completed
+
+	Answers []byte `json:"answers,omitempty"` // Encrypted Checkin, when the entry answers a check-in template
+	Link    []byte `json:"link,omitempty"`    // Encrypted Bookmark, when the entry saves a web page
+	Fields  []byte `json:"fields,omitempty"`  // Encrypted Fields: mood, energy, weather and location
+	Rollup  []byte `json:"rollup,omitempty"`  // Encrypted Rollup, when the entry reviews a week or month
+
+	Countersigns []byte `json:"countersigns,omitempty"` // Encrypted list of Countersignature, added after writing
+	Timestamps   []byte `json:"timestamps,omitempty"`   // Encrypted list of Timestamp, added after writing
+
+	Supersedes string `json:"supersedes,omitempty"` // ID of the entry this one replaces
+	PrevHash   string `json:"prev_hash,omitempty"`  // Hash of the previous entry in the journal's chain
+	Hash       string `json:"hash,omitempty"`       // Chain hash; set only for append-only entries
+}
+
+// Checkin is a check-in entry's answers to the questions of its template
+type Checkin struct {
+	Template string   `json:"template"`
+	Answers  []Answer `json:"answers"`
+}
+
+// Answer is the answer to one check-in question. Unanswered questions are
+// left out.
+type Answer struct {
+	Question string `json:"question"`
+	Kind     string `json:"kind"` // "number", "yesno" or "text"
+	Value    string `json:"value"`
+}
+
+// Bookmark is a bookmark entry's link and what the page said about itself
+// when it was saved
+type Bookmark struct {
+	URL         string     `json:"url"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Fetched     *time.Time `json:"fetched,omitempty"` // When the page was read; nil when saved without fetching it
+}
+
+// Rollup is the period a rollup entry reviews and the entries written in it
+type Rollup struct {
+	Period  string    `json:"period"` // "week" or "month"
+	Start   time.Time `json:"start"`  // First day of the period
+	Entries []string  `json:"entries"`
+}
+
+// Fields are the optional structured fields of an entry. Unset fields are
+// left out.
+type Fields struct {
+	Mood     string `json:"mood,omitempty"`   // 1 to 10, or an emoji
+	Energy   int    `json:"energy,omitempty"` // 1 to 10
+	Weather  string `json:"weather,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// Countersignature is a witness's signature over an entry's body, stating
+// that they saw it at the time of signing
+type Countersignature struct {
+	Witness   string    `json:"witness"`
+	PublicKey string    `json:"public_key"` // Base64 ed25519 key of the witness
+	Signed    time.Time `json:"signed"`
+	BodyHash  string    `json:"body_hash"`          // SHA-256 of the body as it was signed
+	Previous  string    `json:"previous,omitempty"` // Hash of the countersignature logged before it; empty for those made before the log
+	Signature string    `json:"signature"`          // Base64 ed25519 signature of the statement
+}
+
+// Timestamp is a timestamping authority's RFC 3161 token for a salted hash
+// of an entry, proving it existed at the token's time
+type Timestamp struct {
+	Authority string    `json:"authority"` // URL the token was requested from
+	Time      time.Time `json:"time"`      // Time given by the authority
+	BodyHash  string    `json:"body_hash"` // SHA-256 of the body as it was timestamped
+	Salt      string    `json:"salt"`      // Random hex mixed into the hashed statement, so the hash reveals nothing
+	Token     []byte    `json:"token"`     // DER encoded timestamp token
+}
+
+// Revision is a previous version of an entry's body
+type Revision struct {
+	Number   int       `json:"number"`
+	Replaced time.Time `json:"replaced"`        // When this version was superseded
+	Body     []byte    `json:"body"`            // Encrypted content, empty when Blob is set
+	Blob     string    `json:"blob,omitempty"`  // Shared blob holding the encrypted content
+	Codec    string    `json:"codec,omitempty"` // Compression applied before encryption, empty for none
 }
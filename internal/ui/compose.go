@@ -0,0 +1,205 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/fsutil"
+	"golang.org/x/term"
+)
+
+// ComposeModel represents the view model for writing a new entry.
+// It wraps a multi-line text area with a title line and save/cancel keys.
+type ComposeModel struct {
+	textarea textarea.Model // The underlying text input component
+	title    string         // Heading shown above the text area
+	keys     composeKeyMap  // Key bindings for the compose screen
+	tw       typewriter     // Freewriting mode state
+	saved    bool           // Whether the user chose to save the text
+	quitting bool           // Whether the view is being closed
+}
+
+// composeKeyMap defines the key bindings for the compose screen
+type composeKeyMap struct {
+	save       key.Binding
+	cancel     key.Binding
+	typewriter key.Binding
+}
+
+// newComposeKeyMap returns the bindings shared by the compose screen and
+// writing sessions, with help text for the save and cancel keys
+func newComposeKeyMap(saveHelp, cancelHelp string) composeKeyMap {
+	return composeKeyMap{
+		save: key.NewBinding(
+			key.WithKeys(saveKeys("ctrl+s", "ctrl+d")...),
+			key.WithHelp("ctrl+s", saveHelp),
+		),
+		cancel: key.NewBinding(
+			key.WithKeys("esc", "ctrl+c"),
+			key.WithHelp("esc", cancelHelp),
+		),
+		typewriter: key.NewBinding(
+			key.WithKeys("ctrl+t"),
+			key.WithHelp("ctrl+t", "typewriter"),
+		),
+	}
+}
+
+// NewComposeModel creates a new compose model with the given heading,
+// pre-filled with initial
+func NewComposeModel(title, initial string) *ComposeModel {
+	ta := textarea.New()
+	ta.Placeholder = "Start writing..."
+	ta.ShowLineNumbers = false
+	ta.CharLimit = 0
+	ta.MaxHeight = 0
+	ta.SetValue(initial)
+	ta.Focus()
+
+	m := &ComposeModel{
+		textarea: ta,
+		title:    title,
+		keys:     newComposeKeyMap("save", "cancel"),
+		tw:       newTypewriter(),
+	}
+	// Existing text is being edited, which typewriter mode would prevent
+	if initial != "" {
+		m.tw.enabled = false
+	}
+	return m
+}
+
+func (m *ComposeModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *ComposeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.save):
+			m.saved = true
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.cancel):
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.typewriter):
+			m.tw.toggle(&m.textarea)
+			return m, nil
+		}
+		if !m.tw.allow(msg, &m.textarea) {
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		h, v := itemStyle.GetFrameSize()
+		m.textarea.SetWidth(msg.Width - h)
+		// Leave room for the title and help lines
+		m.textarea.SetHeight(msg.Height - v - 4)
+	}
+
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+	m.tw.settle(&m.textarea)
+	return m, cmd
+}
+
+func (m *ComposeModel) View() string {
+	if m.quitting {
+		return ""
+	}
+	help := helpStyle.Render(m.tw.status() + "ctrl+s save • esc cancel • ctrl+t typewriter")
+	return fmt.Sprintf("%s\n\n%s\n%s", titleStyle.Render(m.title), m.textarea.View(), help)
+}
+
+// HandleCompose opens the compose screen pre-filled with initial and returns
+// the written text. The boolean result is false when the user cancelled
+// without saving. When stdin or stdout is not a terminal the text is read
+// from stdin instead.
+func HandleCompose(title, initial string) (string, bool, error) {
+	if !IsTerminal() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return readCompose(os.Stdin)
+	}
+
+	if cfg, err := config.Current(); err == nil && strings.TrimSpace(cfg.String("editor")) != "" {
+		return editExternal(cfg.String("editor"), initial)
+	}
+
+	model := NewComposeModel(title, initial)
+	p := tea.NewProgram(model, programOptions()...)
+	m, err := p.Run()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to run program: %w", err)
+	}
+
+	composeModel, ok := m.(*ComposeModel)
+	if !ok || !composeModel.saved {
+		return "", false, nil
+	}
+
+	text := strings.TrimSpace(composeModel.tw.text(&composeModel.textarea))
+	return text, text != "", nil
+}
+
+// editExternal opens initial in the given editor command and returns the
+// saved text. The temporary file is kept in the user's own temporary
+// directory, readable only by them, and removed as soon as the editor exits.
+func editExternal(editor, initial string) (string, bool, error) {
+	dir, err := fsutil.TempDir()
+	if err != nil {
+		return "", false, err
+	}
+	f, err := os.CreateTemp(dir, "jot-*.md")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+
+	if _, err := f.WriteString(initial); err != nil {
+		f.Close()
+		return "", false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// The editor setting may carry arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		return "", false, fmt.Errorf("the editor setting is empty")
+	}
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", false, fmt.Errorf("editor %s failed: %w", fields[0], err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read temporary file: %w", err)
+	}
+
+	text := strings.TrimSpace(string(data))
+	return text, text != "", nil
+}
+
+// readCompose reads the entry text from r until EOF
+func readCompose(r io.Reader) (string, bool, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read entry text: %w", err)
+	}
+
+	text := strings.TrimSpace(string(data))
+	return text, text != "", nil
+}
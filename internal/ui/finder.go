@@ -0,0 +1,393 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/veritome/jot/internal/journal"
+	"golang.org/x/term"
+)
+
+// FindAction is what the user chose to do with the entry picked in the finder
+type FindAction int
+
+const (
+	FindOpen FindAction = iota + 1
+	FindEdit
+	FindDelete
+)
+
+// FindResult is the entry picked in the finder and the action chosen for it
+type FindResult struct {
+	Action  FindAction
+	Journal string
+	EntryID string
+}
+
+var previewStyle = lipgloss.NewStyle().
+	BorderStyle(glyphs.border).
+	BorderForeground(lipgloss.Color("240")).
+	Padding(0, 1)
+
+// findItem is a decrypted entry the finder can match against
+type findItem struct {
+	journal string
+	id      string
+	title   string
+	body    string
+	tags    []string
+	created time.Time
+	text    []rune // Lowercased title, tags and body matched by the query
+
+	sensitive bool // Whether the title and body are hidden until revealed
+	revealed  bool
+}
+
+// hidden reports whether the item's title and body are hidden
+func (i *findItem) hidden() bool {
+	return Masked(i.sensitive) && !i.revealed
+}
+
+// findMatch is an item that matched the query, with its score
+type findMatch struct {
+	item  *findItem
+	score int
+}
+
+// finderKeyMap defines the key bindings of the finder. Letters go to the
+// query, so every action uses a control key.
+type finderKeyMap struct {
+	up       key.Binding
+	down     key.Binding
+	open     key.Binding
+	edit     key.Binding
+	delete   key.Binding
+	reveal   key.Binding
+	discreet key.Binding
+	quit     key.Binding
+}
+
+func newFinderKeyMap() finderKeyMap {
+	return finderKeyMap{
+		up:       key.NewBinding(key.WithKeys("up", "ctrl+p", "ctrl+k")),
+		down:     key.NewBinding(key.WithKeys("down", "ctrl+n", "ctrl+j")),
+		open:     key.NewBinding(key.WithKeys("enter")),
+		edit:     key.NewBinding(key.WithKeys("ctrl+e")),
+		delete:   key.NewBinding(key.WithKeys("ctrl+d")),
+		reveal:   key.NewBinding(key.WithKeys("ctrl+v")),
+		discreet: discreetKey,
+		quit:     key.NewBinding(key.WithKeys(quitKeys("esc", "ctrl+c")...)),
+	}
+}
+
+// FinderModel is a fuzzy finder over decrypted entries with a preview of
+// the selected one
+type FinderModel struct {
+	input         textinput.Model
+	items         []*findItem
+	matches       []findMatch
+	cursor        int
+	width, height int
+	keys          finderKeyMap
+	confirmDelete bool
+	result        *FindResult
+	quitting      bool
+}
+
+// NewFinderModel creates a finder over every entry of the given journals
+func NewFinderModel(journals []*journal.Journal) (*FinderModel, error) {
+	var items []*findItem
+	for _, j := range journals {
+		entries, err := j.GetEntries()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get entries: %w", err)
+		}
+		for _, e := range entries {
+			body, err := e.GetDecryptedBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+			}
+			title, err := e.GetTitle()
+			if err != nil {
+				return nil, err
+			}
+			tags, err := e.GetTags()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, &findItem{
+				journal:   j.Name,
+				id:        e.ID,
+				title:     title,
+				body:      body,
+				tags:      tags,
+				created:   e.Created,
+				text:      []rune(strings.ToLower(title + " " + strings.Join(tags, " ") + " " + body)),
+				sensitive: e.Sensitive,
+			})
+		}
+	}
+
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "Type to search entries"
+	input.Focus()
+
+	m := &FinderModel{input: input, items: items, keys: newFinderKeyMap()}
+	m.filter()
+	return m, nil
+}
+
+// filter recomputes the matches for the current query. Every word of the
+// query has to match; the best matches come first, then the newest entries.
+func (m *FinderModel) filter() {
+	words := strings.Fields(strings.ToLower(m.input.Value()))
+
+	m.matches = m.matches[:0]
+	for _, item := range m.items {
+		total := 0
+		matched := true
+		for _, w := range words {
+			score, ok := fuzzyScore([]rune(w), item.text)
+			if !ok {
+				matched = false
+				break
+			}
+			total += score
+		}
+		if matched {
+			m.matches = append(m.matches, findMatch{item: item, score: total})
+		}
+	}
+	sort.SliceStable(m.matches, func(a, b int) bool {
+		if m.matches[a].score != m.matches[b].score {
+			return m.matches[a].score > m.matches[b].score
+		}
+		return m.matches[a].item.created.After(m.matches[b].item.created)
+	})
+
+	if m.cursor >= len(m.matches) {
+		m.cursor = len(m.matches) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+// fuzzyScore reports whether the runes of query appear in text in order and
+// scores the match: consecutive runes and runes starting a word count extra
+func fuzzyScore(query, text []rune) (int, bool) {
+	if len(query) == 0 {
+		return 0, true
+	}
+	score, qi, prev := 0, 0, -2
+	for ti, r := range text {
+		if r != query[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 3
+		}
+		if ti == 0 || !unicode.IsLetter(text[ti-1]) && !unicode.IsDigit(text[ti-1]) {
+			score += 2
+		}
+		prev = ti
+		qi++
+		if qi == len(query) {
+			return score, true
+		}
+	}
+	return 0, false
+}
+
+func (m *FinderModel) selected() *findItem {
+	if len(m.matches) == 0 {
+		return nil
+	}
+	return m.matches[m.cursor].item
+}
+
+// finish quits the finder with action applied to the selected entry
+func (m *FinderModel) finish(action FindAction) (tea.Model, tea.Cmd) {
+	item := m.selected()
+	if item == nil {
+		return m, nil
+	}
+	m.result = &FindResult{Action: action, Journal: item.journal, EntryID: item.id}
+	m.quitting = true
+	return m, tea.Quit
+}
+
+func (m *FinderModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m *FinderModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.confirmDelete {
+			m.confirmDelete = false
+			if key.Matches(msg, m.keys.open) || msg.String() == "y" {
+				return m.finish(FindDelete)
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.quit):
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.down):
+			if m.cursor < len(m.matches)-1 {
+				m.cursor++
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.open):
+			return m.finish(FindOpen)
+		case key.Matches(msg, m.keys.edit):
+			return m.finish(FindEdit)
+		case key.Matches(msg, m.keys.delete):
+			m.confirmDelete = m.selected() != nil
+			return m, nil
+		case key.Matches(msg, m.keys.reveal):
+			if item := m.selected(); item != nil && (item.hidden() || item.revealed) {
+				item.revealed = !item.revealed
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.discreet):
+			SetDiscreet(!Discreet())
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.input.Width = msg.Width - 4
+	}
+
+	var cmd tea.Cmd
+	before := m.input.Value()
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() != before {
+		m.cursor = 0
+		m.filter()
+	}
+	return m, cmd
+}
+
+func (m *FinderModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	width, height := m.width, m.height
+	if width == 0 {
+		width, height = 100, 24
+	}
+	// Query, status and help lines take three rows
+	rows := height - 3
+	if rows < 1 {
+		rows = 1
+	}
+	listWidth := width * 2 / 5
+
+	// Keep the cursor in view
+	start := 0
+	if m.cursor >= rows {
+		start = m.cursor - rows + 1
+	}
+	var lines []string
+	for i := start; i < len(m.matches) && i < start+rows; i++ {
+		item := m.matches[i].item
+		summary := HiddenPreview()
+		if !item.hidden() {
+			summary = Summary(item.title, item.body, listWidth)
+		}
+		line := fmt.Sprintf("%s %s", item.id, summary)
+		if runes := []rune(line); len(runes) > listWidth-2 {
+			line = string(runes[:listWidth-2])
+		}
+		if i == m.cursor {
+			lines = append(lines, selectedItemStyle.Render(line))
+		} else {
+			lines = append(lines, itemStyle.Render(line))
+		}
+	}
+	list := lipgloss.NewStyle().Width(listWidth).Height(rows).Render(strings.Join(lines, "\n"))
+
+	// The preview border and padding take two rows and four columns
+	preview := previewStyle.Width(width - listWidth - 4).Height(rows - 2).
+		Render(m.preview(width-listWidth-4, rows-2))
+
+	status := fmt.Sprintf("%d/%d entries", len(m.matches), len(m.items))
+	help := "enter open • ctrl+e edit • ctrl+d delete • ctrl+v reveal • ctrl+x discreet • esc quit"
+	if m.confirmDelete {
+		help = confirmationStyle.Copy().MarginTop(0).Render(fmt.Sprintf("Delete entry %s? Press ENTER or y to confirm, any other key to cancel", m.selected().id))
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s",
+		m.input.View(),
+		lipgloss.JoinHorizontal(lipgloss.Top, list, preview),
+		helpStyle.Copy().PaddingBottom(0).Render(status),
+		helpStyle.Copy().PaddingBottom(0).Render(help))
+}
+
+// preview renders the selected entry, cut to fit in width by height
+func (m *FinderModel) preview(width, height int) string {
+	item := m.selected()
+	if item == nil {
+		return "No matching entries"
+	}
+
+	header := fmt.Sprintf("%s • %s • %s", item.journal, item.id, FormatTime(item.created))
+	if item.hidden() {
+		text := header + "\n\n" + HiddenPreview() + "\n\nPress ctrl+v to reveal this entry"
+		return lipgloss.NewStyle().Width(width).Render(text)
+	}
+	text := header + FormatTags(item.tags) + "\n"
+	if item.title != "" {
+		text += titleStyle.Copy().Padding(0).Render(item.title) + "\n"
+	}
+	text += "\n" + item.body
+
+	wrapped := strings.Split(lipgloss.NewStyle().Width(width).Render(text), "\n")
+	if height > 0 && len(wrapped) > height {
+		wrapped = wrapped[:height]
+	}
+	return strings.Join(wrapped, "\n")
+}
+
+// HandleFind runs the fuzzy finder over the given journals. The result is
+// nil when the user left without picking an entry.
+func HandleFind(journals []*journal.Journal) (*FindResult, error) {
+	if !IsTerminal() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("the finder needs an interactive terminal; use jot search instead")
+	}
+
+	model, err := NewFinderModel(journals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create finder: %w", err)
+	}
+
+	p := tea.NewProgram(model, programOptions()...)
+	m, err := p.Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run program: %w", err)
+	}
+	if finder, ok := m.(*FinderModel); ok {
+		return finder.result, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,175 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// FormModel walks through a list of questions, one answer area each
+type FormModel struct {
+	title     string
+	questions []string
+	answers   []textarea.Model
+	current   int // Index of the question shown
+	keys      formKeyMap
+	saved     bool
+	quitting  bool
+}
+
+// formKeyMap defines the key bindings for the question form
+type formKeyMap struct {
+	next   key.Binding
+	back   key.Binding
+	save   key.Binding
+	cancel key.Binding
+}
+
+// NewFormModel creates a form asking questions under the given heading
+func NewFormModel(title string, questions []string) *FormModel {
+	answers := make([]textarea.Model, len(questions))
+	for i := range answers {
+		ta := textarea.New()
+		ta.Placeholder = "Leave empty to skip the question"
+		ta.ShowLineNumbers = false
+		ta.CharLimit = 0
+		ta.MaxHeight = 0
+		answers[i] = ta
+	}
+	answers[0].Focus()
+
+	return &FormModel{
+		title:     title,
+		questions: questions,
+		answers:   answers,
+		keys: formKeyMap{
+			next:   key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next")),
+			back:   key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "back")),
+			save:   key.NewBinding(key.WithKeys(saveKeys("ctrl+s", "ctrl+d")...), key.WithHelp("ctrl+s", "save")),
+			cancel: key.NewBinding(key.WithKeys("esc", "ctrl+c"), key.WithHelp("esc", "cancel")),
+		},
+	}
+}
+
+// show moves to question i
+func (m *FormModel) show(i int) tea.Cmd {
+	m.answers[m.current].Blur()
+	m.current = i
+	return m.answers[i].Focus()
+}
+
+func (m *FormModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *FormModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.save):
+			m.saved = true
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.cancel):
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.next):
+			// Moving on from the last question finishes the form
+			if m.current == len(m.questions)-1 {
+				m.saved = true
+				m.quitting = true
+				return m, tea.Quit
+			}
+			return m, m.show(m.current + 1)
+		case key.Matches(msg, m.keys.back):
+			if m.current > 0 {
+				return m, m.show(m.current - 1)
+			}
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		h, v := itemStyle.GetFrameSize()
+		for i := range m.answers {
+			m.answers[i].SetWidth(msg.Width - h)
+			// Leave room for the title, question and help lines
+			m.answers[i].SetHeight(msg.Height - v - 6)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.answers[m.current], cmd = m.answers[m.current].Update(msg)
+	return m, cmd
+}
+
+func (m *FormModel) View() string {
+	if m.quitting {
+		return ""
+	}
+	next := "tab next"
+	if m.current == len(m.questions)-1 {
+		next = "tab finish"
+	}
+	help := helpStyle.Render(fmt.Sprintf("%d of %d • %s • shift+tab back • ctrl+s save • esc cancel", m.current+1, len(m.questions), next))
+	return fmt.Sprintf("%s\n\n%s\n\n%s\n%s", titleStyle.Render(m.title), m.questions[m.current], m.answers[m.current].View(), help)
+}
+
+// HandleForm asks the questions one after the other and returns the
+// answers, in the order of the questions; skipped ones are empty. The
+// boolean result is false when the user cancelled or answered nothing.
+// When stdin or stdout is not a terminal, each line read from stdin
+// answers the next question.
+func HandleForm(title string, questions []string) ([]string, bool, error) {
+	if len(questions) == 0 {
+		return nil, false, nil
+	}
+	if !IsTerminal() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return readForm(os.Stdin, len(questions))
+	}
+
+	model := NewFormModel(title, questions)
+	p := tea.NewProgram(model, programOptions()...)
+	m, err := p.Run()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to run program: %w", err)
+	}
+
+	formModel, ok := m.(*FormModel)
+	if !ok || !formModel.saved {
+		return nil, false, nil
+	}
+	answers := make([]string, len(questions))
+	for i := range formModel.answers {
+		answers[i] = strings.TrimSpace(formModel.answers[i].Value())
+	}
+	return answers, answered(answers), nil
+}
+
+// readForm reads one answer per line from r, until n are read or r ends
+func readForm(r io.Reader, n int) ([]string, bool, error) {
+	answers := make([]string, n)
+	scanner := bufio.NewScanner(r)
+	for i := 0; i < n && scanner.Scan(); i++ {
+		answers[i] = strings.TrimSpace(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read answers: %w", err)
+	}
+	return answers, answered(answers), nil
+}
+
+// answered reports whether any of the answers is not empty
+func answered(answers []string) bool {
+	for _, a := range answers {
+		if a != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"os"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// glyphSet holds the symbols used by the TUI that not every terminal can render
+type glyphSet struct {
+	warning string          // Prefix for destructive-action warnings
+	border  lipgloss.Border // Border drawn around warning boxes
+	hidden  string          // Stands in for the preview of a hidden entry
+	filled  string          // Done part of a progress bar
+	empty   string          // Remaining part of a progress bar
+	spark   []string        // Sparkline levels, lowest first
+	star    string          // Marks starred entries
+	cell    string          // Day of a heatmap, shaded by colour
+	heat    []string        // Heatmap levels without colour, lowest first
+}
+
+// asciiBorder is a box made only of ASCII characters
+var asciiBorder = lipgloss.Border{
+	Top:         "-",
+	Bottom:      "-",
+	Left:        "|",
+	Right:       "|",
+	TopLeft:     "+",
+	TopRight:    "+",
+	BottomLeft:  "+",
+	BottomRight: "+",
+}
+
+// glyphs is the glyph set for the current terminal
+var glyphs = newGlyphSet()
+
+func newGlyphSet() glyphSet {
+	if legacyConsole() {
+		return glyphSet{
+			warning: "!!",
+			border:  asciiBorder,
+			hidden:  "******** hidden",
+			filled:  "#",
+			empty:   "-",
+			spark:   []string{"_", ".", "-", "=", "*", "#"},
+			star:    "*",
+			cell:    "#",
+			heat:    []string{".", "-", "+", "*", "#"},
+		}
+	}
+	return glyphSet{
+		warning: "⚠️ ",
+		border:  lipgloss.NormalBorder(),
+		hidden:  "•••••••• hidden",
+		filled:  "█",
+		empty:   "░",
+		spark:   []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"},
+		star:    "★",
+		cell:    "■",
+		heat:    []string{"·", "░", "▒", "▓", "█"},
+	}
+}
+
+// legacyConsole reports whether we are running in the classic Windows console
+// host rather than Windows Terminal or another modern emulator. The classic
+// host cannot render emoji, draws box characters unreliably and handles the
+// alternate screen buffer poorly.
+func legacyConsole() bool {
+	if runtime.GOOS != "windows" {
+		return false
+	}
+	// Windows Terminal sets WT_SESSION; VS Code, ConEmu and friends set TERM_PROGRAM or ConEmuANSI
+	return os.Getenv("WT_SESSION") == "" && os.Getenv("TERM_PROGRAM") == "" && os.Getenv("ConEmuANSI") != "ON"
+}
+
+// quitKeys returns the keys that close a view. On Windows Ctrl+Z is the
+// conventional end-of-input key, so it closes views there as well.
+func quitKeys(keys ...string) []string {
+	return endOfInput(keys)
+}
+
+// saveKeys returns the keys that save text being written. Ctrl+Z ends input
+// on Windows as Ctrl+D does elsewhere, so there it saves rather than
+// throwing the text away.
+func saveKeys(keys ...string) []string {
+	return endOfInput(keys)
+}
+
+func endOfInput(keys []string) []string {
+	if runtime.GOOS == "windows" {
+		keys = append(keys, "ctrl+z")
+	}
+	return keys
+}
+
+// programOptions returns the options used to start every full-screen view.
+// The alternate screen is skipped on the legacy Windows console so output
+// is rendered inline instead of leaving a garbled buffer behind.
+func programOptions() []tea.ProgramOption {
+	if legacyConsole() {
+		return nil
+	}
+	return []tea.ProgramOption{tea.WithAltScreen()}
+}
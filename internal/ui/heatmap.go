@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/veritome/jot/internal/when"
+)
+
+// heatColors shade the days of a heatmap, from nothing written to the most
+var heatColors = []lipgloss.AdaptiveColor{
+	{Light: "254", Dark: "237"},
+	{Light: "151", Dark: "22"},
+	{Light: "114", Dark: "28"},
+	{Light: "71", Dark: "34"},
+	{Light: "28", Dark: "46"},
+}
+
+// heatRows labels every other day of the week, starting on first
+func heatRows(first time.Weekday) []string {
+	rows := make([]string, 7)
+	for d := 0; d < 7; d += 2 {
+		rows[d] = time.Weekday((int(first) + d) % 7).String()[:3]
+	}
+	return rows
+}
+
+// Heatmap renders the words written on each day from start, one count per
+// day, as a grid like a contribution graph: a column per week, its first
+// day set by calendar.week_start at the top, with month names above. Days
+// are shaded in five levels: nothing written, then the quarters of the days
+// with any words.
+func Heatmap(start time.Time, words []int) string {
+	var written []int
+	for _, w := range words {
+		if w > 0 {
+			written = append(written, w)
+		}
+	}
+	sort.Ints(written)
+	// The level of a day is the quarter its count falls in among the days
+	// written on: a day beating all the others is in the top one
+	level := func(w int) int {
+		if w == 0 {
+			return 0
+		}
+		atMost := sort.SearchInts(written, w+1)
+		return (4*atMost + len(written) - 1) / len(written)
+	}
+
+	// The grid starts on the first day of start's week
+	first := when.WeekStart()
+	offset := (int(start.Weekday()) - int(first) + 7) % 7
+	weeks := (offset + len(words) + 6) / 7
+	var b strings.Builder
+
+	months := []rune(strings.Repeat(" ", weeks))
+	free := 0
+	for w := 0; w < weeks; w++ {
+		// A month is named above the first week holding its first day
+		for d := 0; d < 7; d++ {
+			i := w*7 + d - offset
+			if i < 0 || i >= len(words) {
+				continue
+			}
+			day := start.AddDate(0, 0, i)
+			if (day.Day() == 1 || i == 0) && w >= free && w+3 <= weeks {
+				copy(months[w:], []rune(day.Format("Jan")))
+				free = w + 4
+			}
+		}
+	}
+	b.WriteString("    " + strings.TrimRight(string(months), " ") + "\n")
+
+	labels := heatRows(first)
+	for d := 0; d < 7; d++ {
+		row := labels[d] + strings.Repeat(" ", 4-len(labels[d]))
+		for w := 0; w < weeks; w++ {
+			i := w*7 + d - offset
+			if i < 0 || i >= len(words) {
+				row += " "
+				continue
+			}
+			row += heatCell(level(words[i]))
+		}
+		b.WriteString(strings.TrimRight(row, " ") + "\n")
+	}
+
+	b.WriteString("\n    Less ")
+	for l := range heatColors {
+		b.WriteString(heatCell(l))
+	}
+	b.WriteString(" More\n")
+	return b.String()
+}
+
+// heatCell renders a day of the given level: a coloured square, or a
+// shading glyph when there is no colour
+func heatCell(level int) string {
+	if lipgloss.ColorProfile() == termenv.Ascii {
+		return glyphs.heat[level]
+	}
+	return lipgloss.NewStyle().Foreground(heatColors[level]).Render(glyphs.cell)
+}
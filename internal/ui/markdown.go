@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// raw turns off Markdown rendering for the rest of the run once --raw is
+// given
+var raw bool
+
+// SetRaw shows entry bodies as written rather than rendered as Markdown
+func SetRaw(on bool) {
+	raw = on
+}
+
+var (
+	styleOnce sync.Once
+	style     string
+)
+
+// markdownStyle returns the glamour style for the terminal. It is worked out
+// once, before a full-screen view takes over the terminal and the question
+// about its background colour can no longer be asked.
+func markdownStyle() string {
+	styleOnce.Do(func() {
+		switch {
+		case legacyConsole():
+			style = "ascii"
+		case lipgloss.HasDarkBackground():
+			style = "dark"
+		default:
+			style = "light"
+		}
+	})
+	return style
+}
+
+// RenderMarkdown renders an entry body as Markdown for the terminal, wrapped
+// to its width. The body is returned as written with --raw, when stdout is
+// not a terminal, or if it cannot be rendered.
+func RenderMarkdown(body string) string {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	return renderMarkdown(body, width)
+}
+
+func renderMarkdown(body string, width int) string {
+	if raw || !IsTerminal() {
+		return body
+	}
+	r, err := glamour.NewTermRenderer(glamour.WithStandardStyle(markdownStyle()), glamour.WithWordWrap(width))
+	if err != nil {
+		return body
+	}
+	out, err := r.Render(body)
+	if err != nil {
+		return body
+	}
+	return strings.Trim(out, "\n")
+}
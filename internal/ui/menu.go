@@ -2,14 +2,16 @@ package ui
 
 import (
 	"fmt"
-	"time"
+	"os"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/veritome/jot/internal/entry"
 	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/mood"
 )
 
 // Common styles
@@ -36,7 +38,7 @@ var (
 	warningStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("196")). // Bright red
 			Bold(true).
-			BorderStyle(lipgloss.NormalBorder()).
+			BorderStyle(glyphs.border).
 			BorderForeground(lipgloss.Color("196")).
 			Padding(1, 3).
 			Margin(1, 0)
@@ -57,10 +59,20 @@ var (
 // It implements the list.Item interface from charmbracelet/bubbles.
 type entryItem struct {
 	id           string // Unique identifier for the entry
-	content      string // Decrypted content of the entry
+	title        string // Decrypted title, empty if the entry has none
+	preview      string // First line of the content, from the preview cache
+	content      string // Decrypted content, loaded when the entry is opened
+	loaded       bool   // Whether content has been loaded
+	entry        *entry.Entry
 	created      string // Creation timestamp
+	author       string // Member who wrote an entry of a shared journal
 	marked       bool   // Whether the entry is marked for deletion
 	isDeleteList bool   // Whether this item is in a deletion list view
+	sensitive    bool   // Whether the title and content are hidden until revealed
+	revealed     bool   // Whether a sensitive entry has been revealed
+	draft        bool   // Whether the entry was created from a template and not yet filled in
+	rollup       bool   // Whether the entry reviews a week or month
+	fields       string // Mood, energy, weather and location, if any
 }
 
 func (i entryItem) Title() string {
@@ -69,46 +81,115 @@ func (i entryItem) Title() string {
 		if i.marked {
 			mark = "X"
 		}
-		return fmt.Sprintf("[%s] %s", mark, i.id)
+		return fmt.Sprintf("[%s] %s", mark, i.heading())
 	}
-	return i.id
+	return i.heading()
+}
+
+// hidden reports whether the entry's title and content are hidden
+func (i entryItem) hidden() bool {
+	return Masked(i.sensitive) && !i.revealed
+}
+
+// heading is the entry ID followed by its title, if any, and whether it is
+// a draft
+func (i entryItem) heading() string {
+	h := i.id
+	if i.title != "" && !i.hidden() {
+		h = fmt.Sprintf("%s  %s", i.id, i.title)
+	}
+	if i.draft {
+		h += " (draft)"
+	}
+	if i.rollup {
+		h += " (rollup)"
+	}
+	return h
 }
 
 func (i entryItem) Description() string {
-	return fmt.Sprintf("%s | %s", i.created, i.content)
+	if i.hidden() {
+		return fmt.Sprintf("%s | %s", i.created, HiddenPreview())
+	}
+	created := i.created
+	if i.fields != "" {
+		created = fmt.Sprintf("%s | %s", i.created, i.fields)
+	}
+	if i.author != "" {
+		return fmt.Sprintf("%s | %s: %s", created, i.author, i.preview)
+	}
+	return fmt.Sprintf("%s | %s", created, i.preview)
 }
 
 func (i entryItem) FilterValue() string {
-	return i.content
+	return i.title + " " + i.preview
+}
+
+// loadContent decrypts the entry's content, which the list only previews
+func (i *entryItem) loadContent() error {
+	if i.loaded {
+		return nil
+	}
+	content, err := i.entry.GetDecryptedBody()
+	if err != nil {
+		return fmt.Errorf("failed to decrypt entry %s: %w", i.id, err)
+	}
+	i.content, i.loaded = content, true
+	return nil
 }
 
 // ListEntriesModel represents the view model for displaying journal entries.
-// It provides a scrollable list interface for viewing entries.
+// It provides a scrollable list interface for viewing entries, and a detail
+// view showing the selected entry in full with its body rendered as Markdown.
 type ListEntriesModel struct {
-	list     list.Model       // The underlying list UI component
-	journal  *journal.Journal // Reference to the journal being displayed
-	quitting bool             // Whether the view is being closed
+	list          list.Model       // The underlying list UI component
+	journal       *journal.Journal // Reference to the journal being displayed
+	quitting      bool             // Whether the view is being closed
+	anySensitive  bool             // Whether any entry is marked sensitive
+	reading       bool             // Whether the selected entry is open in the detail view
+	detail        viewport.Model   // Scrolls the open entry
+	width, height int              // Size of the terminal
 }
 
-// NewListEntriesModel creates a new model for listing entries
-func NewListEntriesModel(j *journal.Journal) (*ListEntriesModel, error) {
-	entries, err := j.GetEntries()
+// revealKey toggles whether the selected hidden entry is shown, and
+// discreetKey toggles discreet mode for every entry. readKey opens the
+// selected entry and closeKey goes back to the list.
+var (
+	revealKey   = key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "reveal"))
+	discreetKey = key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "discreet"))
+	readKey     = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "read"))
+	closeKey    = key.NewBinding(key.WithKeys(quitKeys("q", "esc", "enter", "backspace")...), key.WithHelp("q", "back"))
+)
+
+// listTitle is the title of the read list, with a hint at how to show the
+// entries it hides
+func listTitle(anySensitive bool) string {
+	switch {
+	case Discreet():
+		return "Journal Entries (discreet: v reveals one, ctrl+x shows all)"
+	case anySensitive:
+		return "Journal Entries (enter to read, v to reveal a sensitive entry)"
+	}
+	return "Journal Entries (enter to read)"
+}
+
+// NewListEntriesModel creates a new model for listing the entries keep
+// selects, or all entries when keep is nil
+func NewListEntriesModel(j *journal.Journal, keep func(*entry.Entry) (bool, error)) (*ListEntriesModel, error) {
+	entries, err := keptEntries(j, keep)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get entries: %w", err)
+		return nil, err
 	}
 
 	items := make([]list.Item, 0, len(entries))
+	anySensitive := false
 	for _, e := range entries {
-		content, err := e.GetDecryptedBody()
+		item, err := newEntryItem(e)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+			return nil, err
 		}
-		items = append(items, entryItem{
-			id:           e.ID,
-			content:      content,
-			created:      e.Created.Format(time.RFC3339),
-			isDeleteList: false,
-		})
+		items = append(items, item)
+		anySensitive = anySensitive || e.Sensitive
 	}
 
 	delegate := list.NewDefaultDelegate()
@@ -119,7 +200,7 @@ func NewListEntriesModel(j *journal.Journal) (*ListEntriesModel, error) {
 	height := len(items)*2 + 3
 
 	l := list.New(items, delegate, 0, height)
-	l.Title = "Journal Entries"
+	l.Title = listTitle(anySensitive)
 	l.Styles.Title = titleStyle
 	l.Styles.PaginationStyle = paginationStyle
 	l.Styles.HelpStyle = helpStyle
@@ -127,9 +208,44 @@ func NewListEntriesModel(j *journal.Journal) (*ListEntriesModel, error) {
 	l.SetFilteringEnabled(false)
 	l.SetShowHelp(false)
 
+	// Ask about the terminal's background while it can still answer
+	markdownStyle()
+
 	return &ListEntriesModel{
-		list:    l,
-		journal: j,
+		list:         l,
+		journal:      j,
+		anySensitive: anySensitive,
+		width:        80,
+		height:       24,
+	}, nil
+}
+
+// newEntryItem makes e into an item of the read list, its content only
+// previewed until it is opened
+func newEntryItem(e *entry.Entry) (entryItem, error) {
+	preview, err := e.GetPreview()
+	if err != nil {
+		return entryItem{}, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+	}
+	title, err := e.GetTitle()
+	if err != nil {
+		return entryItem{}, err
+	}
+	fields, err := e.GetFields()
+	if err != nil {
+		return entryItem{}, err
+	}
+	return entryItem{
+		id:        e.ID,
+		title:     title,
+		preview:   preview.Line,
+		created:   FormatTime(e.Created),
+		author:    e.Author,
+		sensitive: e.Sensitive,
+		draft:     e.Draft,
+		rollup:    e.IsRollup(),
+		fields:    mood.Format(fields),
+		entry:     e,
 	}, nil
 }
 
@@ -140,14 +256,41 @@ func (m ListEntriesModel) Init() tea.Cmd {
 func (m ListEntriesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.reading {
+			return m.updateDetail(msg)
+		}
 		switch {
-		case key.Matches(msg, key.NewBinding(key.WithKeys("q", "esc"))):
+		case key.Matches(msg, key.NewBinding(key.WithKeys(quitKeys("q", "esc")...))):
 			m.quitting = true
 			return m, tea.Quit
+		case key.Matches(msg, revealKey):
+			if item, ok := m.list.SelectedItem().(entryItem); ok && (item.hidden() || item.revealed) {
+				item.revealed = !item.revealed
+				return m, m.list.SetItem(m.list.Index(), item)
+			}
+			return m, nil
+		case key.Matches(msg, discreetKey):
+			SetDiscreet(!Discreet())
+			m.list.Title = listTitle(m.anySensitive)
+			return m, nil
+		case key.Matches(msg, readKey):
+			if item, ok := m.list.SelectedItem().(entryItem); ok {
+				if err := item.loadContent(); err != nil {
+					item.content = fmt.Sprintf("Error: %v", err)
+				}
+				m.reading = true
+				m.openDetail(item)
+				return m, m.list.SetItem(m.list.Index(), item)
+			}
+			return m, nil
 		}
 	case tea.WindowSizeMsg:
 		h, v := itemStyle.GetFrameSize()
 		m.list.SetSize(msg.Width-h, msg.Height-v)
+		m.width, m.height = msg.Width, msg.Height
+		if item, ok := m.list.SelectedItem().(entryItem); ok && m.reading {
+			m.openDetail(item)
+		}
 	}
 
 	var cmd tea.Cmd
@@ -155,10 +298,62 @@ func (m ListEntriesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// updateDetail handles a key pressed while an entry is open
+func (m ListEntriesModel) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.String() == "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case key.Matches(msg, closeKey):
+		m.reading = false
+		return m, nil
+	case key.Matches(msg, revealKey):
+		item, ok := m.list.SelectedItem().(entryItem)
+		if !ok || !(item.hidden() || item.revealed) {
+			return m, nil
+		}
+		item.revealed = !item.revealed
+		m.openDetail(item)
+		return m, m.list.SetItem(m.list.Index(), item)
+	}
+	var cmd tea.Cmd
+	m.detail, cmd = m.detail.Update(msg)
+	return m, cmd
+}
+
+// openDetail shows item in the detail view, its body rendered as Markdown
+// to the width of the terminal
+func (m *ListEntriesModel) openDetail(item entryItem) {
+	// The help line takes the last row
+	m.detail = viewport.New(m.width, m.height-1)
+	m.detail.SetContent(detailText(item, m.width))
+}
+
+// detailText is item in full as the detail view shows it, with a hint at
+// revealing it while it is hidden
+func detailText(item entryItem, width int) string {
+	meta := item.created
+	if item.author != "" {
+		meta += " | " + item.author
+	}
+	body := HiddenPreview() + "\n\nPress v to reveal this entry"
+	if !item.hidden() {
+		if item.fields != "" {
+			meta += " | " + item.fields
+		}
+		body = renderMarkdown(item.content, width)
+	}
+	return fmt.Sprintf("%s\n%s\n\n%s", titleStyle.Render(item.heading()), itemStyle.Render(meta), body)
+}
+
 func (m ListEntriesModel) View() string {
 	if m.quitting {
 		return ""
 	}
+	if m.reading {
+		help := helpStyle.Copy().PaddingBottom(0).Render("↑/↓ scroll • v reveal • q back")
+		return m.detail.View() + "\n" + help
+	}
 	return m.list.View()
 }
 
@@ -177,18 +372,20 @@ type DeleteEntriesModel struct {
 
 // keyMap defines the key bindings for the delete interface
 type keyMap struct {
-	space key.Binding
-	enter key.Binding
-	quit  key.Binding
+	space    key.Binding
+	enter    key.Binding
+	reveal   key.Binding
+	discreet key.Binding
+	quit     key.Binding
 }
 
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.space, k.enter, k.quit}
+	return []key.Binding{k.space, k.enter, k.reveal, k.discreet, k.quit}
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.space, k.enter, k.quit},
+		{k.space, k.enter, k.reveal, k.discreet, k.quit},
 	}
 }
 
@@ -203,16 +400,23 @@ func NewDeleteEntriesModel(j *journal.Journal) (*DeleteEntriesModel, error) {
 	items := make([]entryItem, 0, len(entries))
 	listItems := make([]list.Item, 0, len(entries))
 	for _, e := range entries {
-		content, err := e.GetDecryptedBody()
+		preview, err := e.GetPreview()
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
 		}
+		title, err := e.GetTitle()
+		if err != nil {
+			return nil, err
+		}
 		item := entryItem{
 			id:           e.ID,
-			content:      content,
-			created:      e.Created.Format(time.RFC3339),
+			title:        title,
+			preview:      preview.Line,
+			created:      FormatTime(e.Created),
+			author:       e.Author,
 			marked:       false,
 			isDeleteList: true,
+			sensitive:    e.Sensitive,
 		}
 		items = append(items, item)
 		listItems = append(listItems, item)
@@ -227,8 +431,10 @@ func NewDeleteEntriesModel(j *journal.Journal) (*DeleteEntriesModel, error) {
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "confirm selection"),
 		),
+		reveal:   revealKey,
+		discreet: discreetKey,
 		quit: key.NewBinding(
-			key.WithKeys("q", "esc"),
+			key.WithKeys(quitKeys("q", "esc")...),
 			key.WithHelp("q/esc", "quit"),
 		),
 	}
@@ -280,19 +486,14 @@ func (m *DeleteEntriesModel) deleteEntries(ids []string) tea.Cmd {
 	return tea.Sequence(
 		func() tea.Msg {
 			for _, id := range ids {
-				e, err := entry.Load(id)
+				e, err := m.journal.LoadEntry(id)
 				if err != nil {
 					fmt.Printf("Error loading entry %s: %v\n", id, err)
 					continue
 				}
 
-				if err := e.Delete(); err != nil {
+				if err := m.journal.DeleteEntry(e); err != nil {
 					fmt.Printf("Error deleting entry %s: %v\n", id, err)
-					continue
-				}
-
-				if err := m.journal.RemoveEntry(id); err != nil {
-					fmt.Printf("Error removing entry %s from journal: %v\n", id, err)
 				}
 			}
 
@@ -347,6 +548,16 @@ func (m *DeleteEntriesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 				return m, nil
+			case key.Matches(msg, m.keys.reveal):
+				index := m.list.Index()
+				if index < len(m.items) && (m.items[index].hidden() || m.items[index].revealed) {
+					m.items[index].revealed = !m.items[index].revealed
+					return m, m.list.SetItem(index, m.items[index])
+				}
+				return m, nil
+			case key.Matches(msg, m.keys.discreet):
+				SetDiscreet(!Discreet())
+				return m, nil
 			case key.Matches(msg, m.keys.enter):
 				if !m.confirmDelete {
 					// First enter press shows confirmation
@@ -394,7 +605,7 @@ func (m *DeleteEntriesModel) View() string {
 
 	// Add confirmation message if in confirmation mode
 	if m.confirmDelete {
-		warning := warningStyle.Render("⚠️  WARNING: This action cannot be undone!")
+		warning := warningStyle.Render(glyphs.warning + " WARNING: This action cannot be undone!")
 		entryText := "entries"
 		if m.markedCount == 1 {
 			entryText = "entry"
@@ -415,14 +626,20 @@ func contains(slice []string, str string) bool {
 	return false
 }
 
-// HandleShowEntries displays entries in a journal
-func HandleShowEntries(j *journal.Journal) error {
-	model, err := NewListEntriesModel(j)
+// HandleShowEntries displays the entries in a journal that keep selects, or
+// all of them when keep is nil.
+// When stdout is not a terminal the entries are printed as plain text instead.
+func HandleShowEntries(j *journal.Journal, keep func(*entry.Entry) (bool, error)) error {
+	if !IsTerminal() {
+		return PrintEntries(os.Stdout, j, keep)
+	}
+
+	model, err := NewListEntriesModel(j, keep)
 	if err != nil {
 		return fmt.Errorf("failed to create list model: %w", err)
 	}
 
-	p := tea.NewProgram(model, tea.WithAltScreen())
+	p := tea.NewProgram(model, programOptions()...)
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("failed to run program: %w", err)
 	}
@@ -432,12 +649,16 @@ func HandleShowEntries(j *journal.Journal) error {
 
 // HandleInteractiveDelete handles interactive deletion of entries
 func HandleInteractiveDelete(j *journal.Journal) error {
+	if !IsTerminal() {
+		return fmt.Errorf("interactive delete requires a terminal; pass an entry ID instead")
+	}
+
 	model, err := NewDeleteEntriesModel(j)
 	if err != nil {
 		return fmt.Errorf("failed to create delete model: %w", err)
 	}
 
-	p := tea.NewProgram(model, tea.WithAltScreen())
+	p := tea.NewProgram(model, programOptions()...)
 	m, err := p.Run()
 	if err != nil {
 		return fmt.Errorf("failed to run program: %w", err)
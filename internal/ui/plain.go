@@ -0,0 +1,206 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/mood"
+	"github.com/veritome/jot/internal/types"
+	"golang.org/x/term"
+)
+
+// IsTerminal reports whether stdout is attached to a terminal.
+// When it is not (e.g. output is piped into grep or redirected to a file),
+// commands should fall back to plain, unstyled and unpaged output.
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// FormatTime formats t using the configured date_format
+func FormatTime(t time.Time) string {
+	layout := time.RFC3339
+	if cfg, err := config.Current(); err == nil {
+		layout = cfg.String("date_format")
+	}
+	return t.Format(layout)
+}
+
+// FormatTags renders tags as " #a #b", or nothing without tags
+func FormatTags(tags []string) string {
+	var s string
+	for _, t := range tags {
+		s += " #" + t
+	}
+	return s
+}
+
+// discreet overrides the discreet setting for the rest of the run once
+// --discreet is given or the TUI toggle is used
+var discreet *bool
+
+// Discreet reports whether decrypted titles, tags and bodies are kept off
+// screen, leaving only entry IDs and dates
+func Discreet() bool {
+	if discreet != nil {
+		return *discreet
+	}
+	if cfg, err := config.Current(); err == nil {
+		return cfg.Bool("discreet")
+	}
+	return false
+}
+
+// SetDiscreet turns discreet mode on or off for the rest of the run
+func SetDiscreet(on bool) {
+	discreet = &on
+}
+
+// Masked reports whether an entry is hidden in list views: sensitive
+// entries always are, every entry is in discreet mode
+func Masked(sensitive bool) bool {
+	return sensitive || Discreet()
+}
+
+// HiddenPreview returns what list views show instead of the title and body
+// of a sensitive entry that has not been revealed
+func HiddenPreview() string {
+	return glyphs.hidden
+}
+
+// ProgressBar renders done out of total as a bar width characters wide
+func ProgressBar(done, total, width int) string {
+	filled := width
+	if total > 0 && done < total {
+		filled = done * width / total
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	return strings.Repeat(glyphs.filled, filled) + strings.Repeat(glyphs.empty, width-filled)
+}
+
+// Sparkline renders values as a row of bars scaled between min and max.
+// NaN values are left blank.
+func Sparkline(values []float64, min, max float64) string {
+	var b strings.Builder
+	levels := len(glyphs.spark)
+	for _, v := range values {
+		switch {
+		case math.IsNaN(v):
+			b.WriteString(" ")
+		case max <= min:
+			b.WriteString(glyphs.spark[levels-1])
+		default:
+			level := int((v - min) / (max - min) * float64(levels-1))
+			if level < 0 {
+				level = 0
+			} else if level >= levels {
+				level = levels - 1
+			}
+			b.WriteString(glyphs.spark[level])
+		}
+	}
+	return b.String()
+}
+
+// Summary returns the entry's title, or the first line of its body when it
+// has none, shortened to at most max runes
+func Summary(title, body string, max int) string {
+	line := title
+	if line == "" {
+		line = strings.TrimSpace(strings.SplitN(strings.TrimSpace(body), "\n", 2)[0])
+	}
+	if runes := []rune(line); len(runes) > max {
+		line = string(runes[:max-3]) + "..."
+	}
+	return line
+}
+
+// keptEntries returns the entries of j that keep selects, all of them when
+// keep is nil
+func keptEntries(j *journal.Journal, keep func(*entry.Entry) (bool, error)) ([]*entry.Entry, error) {
+	entries, err := j.GetEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entries: %w", err)
+	}
+	if keep == nil {
+		return entries, nil
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		ok, err := keep(e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
+
+// PrintEntries writes the entries of a journal that keep selects, or all
+// of them when keep is nil, to w as plain text, one block per entry
+// separated by a blank line.
+func PrintEntries(w io.Writer, j *journal.Journal, keep func(*entry.Entry) (bool, error)) error {
+	entries, err := keptEntries(j, keep)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		// Nothing is decrypted in discreet mode
+		if Discreet() {
+			fmt.Fprintf(w, "%s %s\n%s\n", e.ID, FormatTime(e.Created), HiddenPreview())
+			continue
+		}
+
+		content, err := e.GetDecryptedBody()
+		if err != nil {
+			return fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+		}
+		tags, err := e.GetTags()
+		if err != nil {
+			return err
+		}
+		title, err := e.GetTitle()
+		if err != nil {
+			return err
+		}
+		fields, err := e.GetFields()
+		if err != nil {
+			return err
+		}
+		PrintEntry(w, e.ID, e.Created, e.Author, title, tags, fields, content)
+	}
+
+	return nil
+}
+
+// PrintEntry writes one decrypted entry to w in the format of PrintEntries.
+// author is empty unless the entry belongs to a shared journal, and fields
+// is nil unless it has a mood, energy, weather or location.
+func PrintEntry(w io.Writer, id string, created time.Time, author, title string, tags []string, fields *types.Fields, content string) {
+	by := ""
+	if author != "" {
+		by = " by " + author
+	}
+	fmt.Fprintf(w, "%s %s%s%s\n", id, FormatTime(created), by, FormatTags(tags))
+	if title != "" {
+		fmt.Fprintf(w, "# %s\n", title)
+	}
+	if fields != nil {
+		fmt.Fprintf(w, "(%s)\n", mood.Format(fields))
+	}
+	fmt.Fprintf(w, "%s\n", content)
+}
@@ -0,0 +1,171 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/veritome/jot/internal/entry"
+)
+
+// RandomFunc picks the next entry to resurface and a line saying where it
+// comes from. It returns a nil entry once there are none left.
+type RandomFunc func() (*entry.Entry, string, error)
+
+// anotherKey replaces the resurfaced entry with another one
+var anotherKey = key.NewBinding(key.WithKeys("n", " "), key.WithHelp("n", "another one"))
+
+// RandomModel shows one resurfaced entry at a time, like the detail view
+// of the read list, and picks another on request
+type RandomModel struct {
+	next          RandomFunc
+	item          entryItem      // The entry shown
+	origin        string         // Where the entry comes from
+	empty         bool           // Whether every entry has been shown
+	err           error          // Error picking or decrypting the last entry
+	detail        viewport.Model // Scrolls the entry
+	width, height int            // Size of the terminal
+	quitting      bool
+}
+
+// pick replaces the entry shown with the next one
+func (m *RandomModel) pick() {
+	e, origin, err := m.next()
+	switch {
+	case err != nil:
+		m.err = err
+	case e == nil:
+		m.empty = true
+	default:
+		item, err := newRandomItem(e)
+		if err != nil {
+			m.err = err
+			break
+		}
+		m.item, m.origin, m.err = item, origin, nil
+	}
+	m.layout()
+}
+
+// newRandomItem makes e into an item with its content loaded, since
+// entries are shown in full straight away
+func newRandomItem(e *entry.Entry) (entryItem, error) {
+	item, err := newEntryItem(e)
+	if err != nil {
+		return entryItem{}, err
+	}
+	return item, item.loadContent()
+}
+
+// layout renders the entry shown to the size of the terminal
+func (m *RandomModel) layout() {
+	text := detailText(m.item, m.width)
+	if m.origin != "" {
+		text = itemStyle.Render(m.origin) + "\n\n" + text
+	}
+	// The help line takes the last row
+	m.detail = viewport.New(m.width, m.height-1)
+	m.detail.SetContent(text)
+}
+
+func (m *RandomModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *RandomModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, key.NewBinding(key.WithKeys(quitKeys("q", "esc", "ctrl+c")...))):
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, anotherKey):
+			if !m.empty {
+				m.pick()
+			}
+			return m, nil
+		case key.Matches(msg, revealKey):
+			if m.item.hidden() || m.item.revealed {
+				m.item.revealed = !m.item.revealed
+				m.layout()
+			}
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.detail, cmd = m.detail.Update(msg)
+	return m, cmd
+}
+
+func (m *RandomModel) View() string {
+	if m.quitting {
+		return ""
+	}
+	status := ""
+	switch {
+	case m.err != nil:
+		status = warningStyle.Render(m.err.Error()) + "\n"
+	case m.empty:
+		status = itemStyle.Render("That was the last one: every entry has been shown") + "\n"
+	}
+	help := helpStyle.Copy().PaddingBottom(0).Render("↑/↓ scroll • n another one • v reveal • q quit")
+	return status + m.detail.View() + "\n" + help
+}
+
+// HandleRandom shows the entries next picks one after another, until there
+// are none left or the user quits. When stdout is not a terminal only the
+// first is printed, as plain text.
+func HandleRandom(next RandomFunc) error {
+	e, origin, err := next()
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return fmt.Errorf("no entries to pick from")
+	}
+
+	if !IsTerminal() {
+		fmt.Println(origin)
+		fmt.Println()
+		// Nothing is decrypted in discreet mode
+		if Discreet() {
+			fmt.Printf("%s %s\n%s\n", e.ID, FormatTime(e.Created), HiddenPreview())
+			return nil
+		}
+		item, err := newRandomItem(e)
+		if err != nil {
+			return err
+		}
+		tags, err := e.GetTags()
+		if err != nil {
+			return err
+		}
+		fields, err := e.GetFields()
+		if err != nil {
+			return err
+		}
+		PrintEntry(os.Stdout, e.ID, e.Created, e.Author, item.title, tags, fields, item.content)
+		return nil
+	}
+
+	item, err := newRandomItem(e)
+	if err != nil {
+		return err
+	}
+	// Ask about the terminal's background while it can still answer
+	markdownStyle()
+
+	model := &RandomModel{next: next, item: item, origin: origin, width: 80, height: 24}
+	model.layout()
+	p := tea.NewProgram(model, programOptions()...)
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("failed to run program: %w", err)
+	}
+	return nil
+}
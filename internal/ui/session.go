@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// SessionResult describes a finished writing session
+type SessionResult struct {
+	Text     string
+	Words    int
+	Duration time.Duration
+	Reached  bool // Whether the word goal was met
+}
+
+// SessionModel is a distraction-free editor with a live word count and
+// timer. Once the goal is reached, leaving the session always saves it.
+type SessionModel struct {
+	textarea textarea.Model
+	goal     int
+	started  time.Time
+	now      time.Time
+	keys     composeKeyMap
+	tw       typewriter
+	saved    bool
+	quitting bool
+}
+
+// tickMsg refreshes the timer once a second
+type tickMsg time.Time
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+// NewSessionModel creates a writing session aiming for goal words
+func NewSessionModel(goal int) *SessionModel {
+	ta := textarea.New()
+	ta.Placeholder = fmt.Sprintf("Write %d words. Don't stop, don't edit.", goal)
+	ta.ShowLineNumbers = false
+	ta.CharLimit = 0
+	ta.MaxHeight = 0
+	ta.Prompt = ""
+	ta.Focus()
+
+	now := time.Now()
+	return &SessionModel{
+		textarea: ta,
+		goal:     goal,
+		started:  now,
+		now:      now,
+		keys:     newComposeKeyMap("finish", "quit"),
+		tw:       newTypewriter(),
+	}
+}
+
+func (m *SessionModel) words() int {
+	return len(strings.Fields(m.tw.text(&m.textarea)))
+}
+
+func (m *SessionModel) Init() tea.Cmd {
+	return tea.Batch(textarea.Blink, tick())
+}
+
+func (m *SessionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tickMsg:
+		m.now = time.Time(msg)
+		return m, tick()
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.save):
+			m.saved = true
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.cancel):
+			// Never throw away a session that met its goal
+			m.saved = m.words() >= m.goal
+			m.quitting = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.typewriter):
+			m.tw.toggle(&m.textarea)
+			return m, nil
+		}
+		if !m.tw.allow(msg, &m.textarea) {
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		h, v := itemStyle.GetFrameSize()
+		m.textarea.SetWidth(msg.Width - h)
+		// Leave room for the status line
+		m.textarea.SetHeight(msg.Height - v - 2)
+	}
+
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+	m.tw.settle(&m.textarea)
+	return m, cmd
+}
+
+func (m *SessionModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	words := m.words()
+	elapsed := m.now.Sub(m.started).Truncate(time.Second)
+	status := fmt.Sprintf("%s%d / %d words • %s", m.tw.status(), words, m.goal, formatElapsed(elapsed))
+	if words >= m.goal {
+		status += " • goal reached, esc saves"
+	} else {
+		status += " • ctrl+s finish • esc quit • ctrl+t typewriter"
+	}
+	return fmt.Sprintf("%s\n%s", m.textarea.View(), helpStyle.Render(status))
+}
+
+// formatElapsed renders d as m:ss or h:mm:ss
+func formatElapsed(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// HandleWritingSession runs a writing session with a word goal. The boolean
+// result is false when the user quit before the goal without saving.
+func HandleWritingSession(goal int) (*SessionResult, bool, error) {
+	if !IsTerminal() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, false, fmt.Errorf("a writing session needs an interactive terminal")
+	}
+
+	model := NewSessionModel(goal)
+	p := tea.NewProgram(model, programOptions()...)
+	m, err := p.Run()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to run program: %w", err)
+	}
+
+	session, ok := m.(*SessionModel)
+	if !ok || !session.saved {
+		return nil, false, nil
+	}
+
+	text := strings.TrimSpace(session.tw.text(&session.textarea))
+	if text == "" {
+		return nil, false, nil
+	}
+	words := len(strings.Fields(text))
+	return &SessionResult{
+		Text:     text,
+		Words:    words,
+		Duration: time.Since(session.started).Truncate(time.Second),
+		Reached:  words >= goal,
+	}, true, nil
+}
@@ -0,0 +1,463 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/veritome/jot/internal/entry"
+	"github.com/veritome/jot/internal/journal"
+	"golang.org/x/term"
+)
+
+// TriageAction is something done to an entry during triage
+type TriageAction int
+
+const (
+	TriageMove   TriageAction = iota + 1 // Move to the journal given as argument
+	TriageTag                            // Add the tags given as argument
+	TriageStar                           // Star the entry, or unstar a starred one
+	TriageDelete                         // Delete the entry
+)
+
+// TriageFunc carries out action on the entry id. An error is shown and
+// leaves the entry where it was.
+type TriageFunc func(id string, action TriageAction, arg string) error
+
+// TriageSummary counts what was done during triage
+type TriageSummary struct {
+	Moved, Tagged, Starred, Deleted, Left int
+}
+
+// StarTag is the tag marking starred entries
+const StarTag = "starred"
+
+// triageItem is a decrypted entry waiting for triage
+type triageItem struct {
+	id      string
+	title   string
+	body    string
+	tags    []string
+	created time.Time
+
+	sensitive bool
+	revealed  bool
+}
+
+// hidden reports whether the item's title and body are hidden
+func (i *triageItem) hidden() bool {
+	return Masked(i.sensitive) && !i.revealed
+}
+
+func (i *triageItem) starred() bool {
+	return contains(i.tags, StarTag)
+}
+
+// triagePrompt is what the triage is waiting for
+type triagePrompt int
+
+const (
+	promptAction triagePrompt = iota
+	promptJournal
+	promptTags
+	promptDelete
+)
+
+// triageKeyMap defines the single-key actions of triage
+type triageKeyMap struct {
+	move     key.Binding
+	tag      key.Binding
+	star     key.Binding
+	delete   key.Binding
+	skip     key.Binding
+	back     key.Binding
+	reveal   key.Binding
+	discreet key.Binding
+	quit     key.Binding
+}
+
+func newTriageKeyMap() triageKeyMap {
+	return triageKeyMap{
+		move:     key.NewBinding(key.WithKeys("m")),
+		tag:      key.NewBinding(key.WithKeys("t")),
+		star:     key.NewBinding(key.WithKeys("s")),
+		delete:   key.NewBinding(key.WithKeys("d")),
+		skip:     key.NewBinding(key.WithKeys(" ", "n", "right", "enter")),
+		back:     key.NewBinding(key.WithKeys("p", "left")),
+		reveal:   revealKey,
+		discreet: discreetKey,
+		quit:     key.NewBinding(key.WithKeys(quitKeys("q", "esc", "ctrl+c")...)),
+	}
+}
+
+// TriageModel steps through the entries of a journal one at a time,
+// applying single-key actions to each
+type TriageModel struct {
+	journal  string
+	journals []string // Journals entries can be moved to
+	items    []*triageItem
+	cursor   int
+	act      TriageFunc
+	keys     triageKeyMap
+	prompt   triagePrompt
+	input    textinput.Model
+	status   string
+	summary  TriageSummary
+	width    int
+	quitting bool
+}
+
+// NewTriageModel creates a triage of every entry of j, which can be moved
+// to any of journals
+func NewTriageModel(j *journal.Journal, journals []string, act TriageFunc) (*TriageModel, error) {
+	entries, err := j.GetEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entries: %w", err)
+	}
+	items := make([]*triageItem, 0, len(entries))
+	for _, e := range entries {
+		body, err := e.GetDecryptedBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt entry %s: %w", e.ID, err)
+		}
+		title, err := e.GetTitle()
+		if err != nil {
+			return nil, err
+		}
+		tags, err := e.GetTags()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &triageItem{
+			id:      e.ID,
+			title:   title,
+			body:    body,
+			tags:    tags,
+			created: e.Created,
+
+			sensitive: e.Sensitive,
+		})
+	}
+
+	targets := make([]string, 0, len(journals))
+	for _, name := range journals {
+		if name != j.Name {
+			targets = append(targets, name)
+		}
+	}
+
+	input := textinput.New()
+	input.Prompt = "> "
+	return &TriageModel{
+		journal:  j.Name,
+		journals: targets,
+		items:    items,
+		act:      act,
+		keys:     newTriageKeyMap(),
+		input:    input,
+	}, nil
+}
+
+func (m *TriageModel) selected() *triageItem {
+	if m.cursor >= len(m.items) {
+		return nil
+	}
+	return m.items[m.cursor]
+}
+
+// apply carries out action on the selected entry, reporting the outcome
+// in the status line
+func (m *TriageModel) apply(action TriageAction, arg string) error {
+	item := m.selected()
+	if err := m.act(item.id, action, arg); err != nil {
+		m.status = fmt.Sprintf("Error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// remove drops the selected entry from the triage once it left the journal
+func (m *TriageModel) remove() (tea.Model, tea.Cmd) {
+	m.items = append(m.items[:m.cursor], m.items[m.cursor+1:]...)
+	if m.cursor >= len(m.items) {
+		m.cursor = 0
+	}
+	if len(m.items) == 0 {
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// matchJournals returns the journals whose name contains the typed text,
+// those starting with it first
+func (m *TriageModel) matchJournals() []string {
+	typed := strings.ToLower(strings.TrimSpace(m.input.Value()))
+	var prefix, other []string
+	for _, name := range m.journals {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, typed):
+			prefix = append(prefix, name)
+		case strings.Contains(lower, typed):
+			other = append(other, name)
+		}
+	}
+	return append(prefix, other...)
+}
+
+// ask switches to prompt, reading its answer in the text input
+func (m *TriageModel) ask(prompt triagePrompt, placeholder string) (tea.Model, tea.Cmd) {
+	m.prompt = prompt
+	m.status = ""
+	m.input.Reset()
+	m.input.Placeholder = placeholder
+	m.input.Focus()
+	return m, textinput.Blink
+}
+
+func (m *TriageModel) Init() tea.Cmd {
+	if len(m.items) == 0 {
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m *TriageModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.input.Width = msg.Width - 4
+		return m, nil
+	case tea.KeyMsg:
+		switch m.prompt {
+		case promptJournal, promptTags:
+			return m.updateInput(msg)
+		case promptDelete:
+			m.prompt = promptAction
+			if msg.String() != "y" && msg.Type != tea.KeyEnter {
+				return m, nil
+			}
+			id := m.selected().id
+			if m.apply(TriageDelete, "") != nil {
+				return m, nil
+			}
+			m.summary.Deleted++
+			m.status = fmt.Sprintf("Deleted %s", id)
+			return m.remove()
+		}
+		return m.updateAction(msg)
+	}
+	return m, nil
+}
+
+// updateAction handles the single-key actions
+func (m *TriageModel) updateAction(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	item := m.selected()
+	switch {
+	case key.Matches(msg, m.keys.quit):
+		m.quitting = true
+		return m, tea.Quit
+	case key.Matches(msg, m.keys.skip):
+		m.status = ""
+		m.cursor = (m.cursor + 1) % len(m.items)
+	case key.Matches(msg, m.keys.back):
+		m.status = ""
+		m.cursor = (m.cursor + len(m.items) - 1) % len(m.items)
+	case key.Matches(msg, m.keys.move):
+		if len(m.journals) == 0 {
+			m.status = "There is no other journal to move entries to"
+			return m, nil
+		}
+		return m.ask(promptJournal, "Journal to move the entry to (tab completes)")
+	case key.Matches(msg, m.keys.tag):
+		return m.ask(promptTags, "Tags to add, separated by spaces or commas")
+	case key.Matches(msg, m.keys.star):
+		if m.apply(TriageStar, "") != nil {
+			return m, nil
+		}
+		if item.starred() {
+			item.tags = removeTag(item.tags, StarTag)
+			m.summary.Starred--
+			m.status = fmt.Sprintf("Unstarred %s", item.id)
+		} else {
+			item.tags = entry.NormalizeTags(append(item.tags, StarTag))
+			m.summary.Starred++
+			m.status = fmt.Sprintf("Starred %s", item.id)
+		}
+	case key.Matches(msg, m.keys.delete):
+		m.prompt = promptDelete
+		m.status = ""
+	case key.Matches(msg, m.keys.reveal):
+		if item.hidden() || item.revealed {
+			item.revealed = !item.revealed
+		}
+	case key.Matches(msg, m.keys.discreet):
+		SetDiscreet(!Discreet())
+	}
+	return m, nil
+}
+
+// updateInput handles typing the journal or tags of a move or tag action
+func (m *TriageModel) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.prompt = promptAction
+		return m, nil
+	case tea.KeyTab:
+		if m.prompt == promptJournal {
+			if matches := m.matchJournals(); len(matches) > 0 {
+				m.input.SetValue(matches[0])
+				m.input.CursorEnd()
+			}
+		}
+		return m, nil
+	case tea.KeyEnter:
+		prompt := m.prompt
+		m.prompt = promptAction
+		if prompt == promptJournal {
+			return m.move()
+		}
+		return m.tag()
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// move moves the selected entry to the journal typed, or the best match
+func (m *TriageModel) move() (tea.Model, tea.Cmd) {
+	typed := strings.TrimSpace(m.input.Value())
+	if typed == "" {
+		return m, nil
+	}
+	dest := ""
+	for _, name := range m.journals {
+		if name == typed {
+			dest = name
+		}
+	}
+	if matches := m.matchJournals(); dest == "" && len(matches) > 0 {
+		dest = matches[0]
+	}
+	if dest == "" {
+		m.status = fmt.Sprintf("Journal '%s' does not exist", typed)
+		return m, nil
+	}
+
+	id := m.selected().id
+	if m.apply(TriageMove, dest) != nil {
+		return m, nil
+	}
+	m.summary.Moved++
+	m.status = fmt.Sprintf("Moved %s to '%s'", id, dest)
+	return m.remove()
+}
+
+// tag adds the tags typed to the selected entry
+func (m *TriageModel) tag() (tea.Model, tea.Cmd) {
+	tags := strings.FieldsFunc(m.input.Value(), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(tags) == 0 {
+		return m, nil
+	}
+	item := m.selected()
+	if m.apply(TriageTag, strings.Join(tags, ",")) != nil {
+		return m, nil
+	}
+	item.tags = entry.NormalizeTags(append(item.tags, tags...))
+	m.summary.Tagged++
+	m.status = fmt.Sprintf("Tagged %s", item.id)
+	return m, nil
+}
+
+func (m *TriageModel) View() string {
+	item := m.selected()
+	if m.quitting || item == nil {
+		return ""
+	}
+	width := m.width
+	if width == 0 {
+		width = 100
+	}
+
+	header := titleStyle.Render(fmt.Sprintf("Triage %s • entry %d of %d", m.journal, m.cursor+1, len(m.items)))
+
+	text := fmt.Sprintf("%s • %s", item.id, FormatTime(item.created))
+	if item.starred() {
+		text += " " + glyphs.star
+	}
+	if item.hidden() {
+		text += "\n\n" + HiddenPreview() + "\n\nPress v to reveal this entry"
+	} else {
+		text += FormatTags(item.tags) + "\n"
+		if item.title != "" {
+			text += titleStyle.Copy().Padding(0).Render(item.title) + "\n"
+		}
+		text += "\n" + item.body
+	}
+	box := previewStyle.Width(width - 4).Render(text)
+
+	var footer string
+	switch m.prompt {
+	case promptJournal:
+		footer = m.input.View()
+		if matches := m.matchJournals(); len(matches) > 0 {
+			footer += "\n" + helpStyle.Copy().PaddingBottom(0).Render(strings.Join(matches, "  "))
+		}
+	case promptTags:
+		footer = m.input.View()
+	case promptDelete:
+		footer = confirmationStyle.Copy().MarginTop(0).Render(fmt.Sprintf("Delete entry %s? Press ENTER or y to confirm, any other key to cancel", item.id))
+	default:
+		footer = helpStyle.Copy().PaddingBottom(0).Render("m move • t tag • s star • d delete • space skip • p back • v reveal • q quit")
+	}
+	if m.status != "" {
+		footer = helpStyle.Copy().PaddingBottom(0).Render(m.status) + "\n" + footer
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s", header, box, footer)
+}
+
+// removeTag returns tags without tag
+func removeTag(tags []string, tag string) []string {
+	kept := tags[:0:0]
+	for _, t := range tags {
+		if t != tag {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// HandleTriage steps through the entries of j, moving entries to any of
+// journals, tagging, starring and deleting them with act. It returns what
+// was done.
+func HandleTriage(j *journal.Journal, journals []string, act TriageFunc) (*TriageSummary, error) {
+	if !IsTerminal() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("triage needs an interactive terminal")
+	}
+
+	model, err := NewTriageModel(j, journals, act)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create triage: %w", err)
+	}
+
+	p := tea.NewProgram(model, programOptions()...)
+	m, err := p.Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run program: %w", err)
+	}
+	summary := model.summary
+	if triage, ok := m.(*TriageModel); ok {
+		summary = triage.summary
+		summary.Left = len(triage.items)
+	}
+	return &summary, nil
+}
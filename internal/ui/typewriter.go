@@ -0,0 +1,109 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/veritome/jot/internal/config"
+)
+
+// typewriter implements the freewriting mode of the editors: text can only be
+// added at the end, backspace stops at the start of the current sentence and
+// finished paragraphs are moved out of view.
+type typewriter struct {
+	enabled   bool
+	committed string // Finished paragraphs, hidden from the text area
+	hidden    int    // Number of hidden paragraphs
+}
+
+// newTypewriter returns the typewriter state, on if compose.typewriter is set
+func newTypewriter() typewriter {
+	cfg, err := config.Current()
+	return typewriter{enabled: err == nil && cfg.Bool("compose.typewriter")}
+}
+
+// allow reports whether a key press may reach the text area
+func (t *typewriter) allow(msg tea.KeyMsg, ta *textarea.Model) bool {
+	if !t.enabled {
+		return true
+	}
+	if msg.Alt {
+		return false // Word movement and deletion
+	}
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace, tea.KeyEnter, tea.KeyTab:
+		return true
+	case tea.KeyBackspace, tea.KeyCtrlH:
+		value := ta.Value()
+		return len(value) > sentenceStart(value)
+	}
+	// Cursor movement and every other editing key
+	return false
+}
+
+// settle moves finished paragraphs out of the text area
+func (t *typewriter) settle(ta *textarea.Model) {
+	if !t.enabled {
+		return
+	}
+	value := ta.Value()
+	i := strings.LastIndex(value, "\n\n")
+	if i < 0 {
+		return
+	}
+	t.committed += value[:i+2]
+	t.hidden += strings.Count(strings.TrimSpace(value[:i]), "\n\n") + 1
+	ta.SetValue(value[i+2:])
+}
+
+// toggle switches the mode, showing the hidden paragraphs again when it is
+// turned off
+func (t *typewriter) toggle(ta *textarea.Model) {
+	if t.enabled {
+		ta.SetValue(t.committed + ta.Value())
+		t.committed, t.hidden = "", 0
+		t.enabled = false
+		return
+	}
+	t.enabled = true
+	t.settle(ta)
+}
+
+// text returns everything written, including hidden paragraphs
+func (t *typewriter) text(ta *textarea.Model) string {
+	return t.committed + ta.Value()
+}
+
+// status describes the mode for the help line
+func (t *typewriter) status() string {
+	if !t.enabled {
+		return ""
+	}
+	switch t.hidden {
+	case 0:
+		return "typewriter • "
+	case 1:
+		return "typewriter • 1 paragraph hidden • "
+	}
+	return fmt.Sprintf("typewriter • %d paragraphs hidden • ", t.hidden)
+}
+
+// sentenceStart returns the index at which the last sentence of text begins:
+// just after the final newline, or after the final '.', '!' or '?' that is
+// followed by whitespace
+func sentenceStart(text string) int {
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			start = i + 1
+		case ' ', '\t':
+			if i > 0 && strings.ContainsRune(".!?", rune(text[i-1])) {
+				start = i + 1
+			}
+		}
+	}
+	return start
+}
@@ -0,0 +1,279 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each release carries release.json, naming its version and the SHA-256 of
+// every binary, and release.json.sig, the Base64 ed25519 signature of it.
+// The version and tag of the releases API are not signed, so only the
+// manifest is trusted to say which release is offered.
+const (
+	releasesURL   = "https://api.github.com/repos/veritome/jot/releases/latest"
+	manifestFile  = "release.json"
+	signatureFile = "release.json.sig"
+)
+
+// httpClient is used for all release downloads
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// Release describes a published jot release. Version can only be relied
+// on once Verify succeeded.
+type Release struct {
+	Version string  `json:"tag_name"`
+	Assets  []Asset `json:"assets"`
+
+	manifest *manifest // Set by Verify
+}
+
+// manifest is the signed part of a release
+type manifest struct {
+	Version   string            `json:"version"`
+	Checksums map[string]string `json:"checksums"` // Asset name -> SHA-256
+}
+
+// Asset is a downloadable file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Latest fetches the most recent published release
+func Latest() (*Release, error) {
+	resp, err := httpClient.Get(releasesURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query latest release: %s", resp.Status)
+	}
+
+	var r Release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode release metadata: %w", err)
+	}
+	return &r, nil
+}
+
+// BinaryName returns the release asset name for the running platform
+func BinaryName() string {
+	name := fmt.Sprintf("jot_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// asset looks up an asset by name
+func (r *Release) asset(name string) (Asset, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("release %s has no asset named %s", r.Version, name)
+}
+
+// Newer reports whether release version latest is newer than current.
+// Versions are compared numerically component by component ("v1.10.0" > "v1.9.2").
+func Newer(latest, current string) bool {
+	l, c := versionParts(latest), versionParts(current)
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var lv, cv int
+		if i < len(l) {
+			lv = l[i]
+		}
+		if i < len(c) {
+			cv = c[i]
+		}
+		if lv != cv {
+			return lv > cv
+		}
+	}
+	return false
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	// Ignore pre-release and build suffixes
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			n = 0
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// ManagedBy returns the name of the package manager that owns the binary at
+// path, or an empty string if it appears to be a standalone install.
+func ManagedBy(path string) string {
+	p := filepath.ToSlash(strings.ToLower(path))
+	switch {
+	case strings.Contains(p, "/cellar/"), strings.Contains(p, "/homebrew/"), strings.Contains(p, "/linuxbrew/"):
+		return "Homebrew"
+	case strings.Contains(p, "/scoop/"):
+		return "Scoop"
+	case strings.Contains(p, "/nix/store/"):
+		return "Nix"
+	case strings.Contains(p, "/chocolatey/"):
+		return "Chocolatey"
+	case strings.HasPrefix(p, "/usr/bin/"), strings.HasPrefix(p, "/snap/"):
+		return "the system package manager"
+	}
+	return ""
+}
+
+// Verify checks the release's manifest against publicKey, the Base64
+// ed25519 key releases are signed with, and that it names the version the
+// release claims, so that an older or another release cannot be passed off
+// as the latest one
+func (r *Release) Verify(publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release signing key")
+	}
+
+	manifestAsset, err := r.asset(manifestFile)
+	if err != nil {
+		return err
+	}
+	signatureAsset, err := r.asset(signatureFile)
+	if err != nil {
+		return err
+	}
+	data, err := download(manifestAsset.URL)
+	if err != nil {
+		return err
+	}
+	sigData, err := download(signatureAsset.URL)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil {
+		return fmt.Errorf("failed to decode release signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("release signature verification failed")
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("failed to decode release manifest: %w", err)
+	}
+	if m.Version == "" || m.Version != r.Version {
+		return fmt.Errorf("release %s is signed as %q", r.Version, m.Version)
+	}
+	r.manifest = &m
+	return nil
+}
+
+// Apply downloads the binary for this platform from release r, checks it
+// against the checksum of its manifest and replaces the executable at path
+// with it. r must have been verified.
+func Apply(r *Release, path string) error {
+	if r.manifest == nil {
+		return fmt.Errorf("release %s was not verified", r.Version)
+	}
+	binaryAsset, err := r.asset(BinaryName())
+	if err != nil {
+		return err
+	}
+	want, ok := r.manifest.Checksums[binaryAsset.Name]
+	if !ok {
+		return fmt.Errorf("no checksum listed for %s", binaryAsset.Name)
+	}
+
+	binary, err := download(binaryAsset.URL)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(binary)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(want) {
+		return fmt.Errorf("checksum mismatch for %s", binaryAsset.Name)
+	}
+
+	return replaceExecutable(path, binary)
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// replaceExecutable atomically swaps the file at path for binary. The new
+// file is written next to the old one so the final rename stays on the same
+// filesystem.
+func replaceExecutable(path string, binary []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".jot-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set permissions on new binary: %w", err)
+	}
+
+	// Windows cannot overwrite a running executable, but it can rename it
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("failed to move old binary aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace binary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// release publishes a release of version on a test server, its manifest
+// naming signedVersion and signed with signer, and returns it with the
+// Base64 public key of the genuine release key
+func release(t *testing.T, version, signedVersion string, signer ed25519.PrivateKey) (*Release, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if signer == nil {
+		signer = private
+	}
+
+	binary := []byte("new jot binary")
+	sum := sha256.Sum256(binary)
+	manifestData, err := json.Marshal(manifest{
+		Version:   signedVersion,
+		Checksums: map[string]string{BinaryName(): hex.EncodeToString(sum[:])},
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	files := map[string][]byte{
+		manifestFile:  manifestData,
+		signatureFile: []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(signer, manifestData))),
+		BinaryName():  binary,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	r := &Release{Version: version}
+	for name := range files {
+		r.Assets = append(r.Assets, Asset{Name: name, URL: srv.URL + "/" + name})
+	}
+	return r, base64.StdEncoding.EncodeToString(public)
+}
+
+func TestVerify(t *testing.T) {
+	r, key := release(t, "v1.2.0", "v1.2.0", nil)
+	if err := r.Verify(key); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jot")
+	if err := os.WriteFile(path, []byte("old jot binary"), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := Apply(r, path); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new jot binary" {
+		t.Errorf("executable = %q after Apply, want the release binary", got)
+	}
+}
+
+func TestVerifyWrongVersion(t *testing.T) {
+	r, key := release(t, "v9.0.0", "v1.2.0", nil)
+	if err := r.Verify(key); err == nil {
+		t.Fatal("Verify accepted a manifest signed for another version")
+	}
+	if err := Apply(r, filepath.Join(t.TempDir(), "jot")); err == nil || !strings.Contains(err.Error(), "not verified") {
+		t.Errorf("Apply error = %v, want the release refused as not verified", err)
+	}
+}
+
+func TestVerifyBadSignature(t *testing.T) {
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	r, key := release(t, "v1.2.0", "v1.2.0", other)
+	if err := r.Verify(key); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("Verify error = %v, want a signature failure", err)
+	}
+}
+
+func TestVerifyMissingManifest(t *testing.T) {
+	r, key := release(t, "v1.2.0", "v1.2.0", nil)
+	var kept []Asset
+	for _, a := range r.Assets {
+		if a.Name != signatureFile {
+			kept = append(kept, a)
+		}
+	}
+	r.Assets = kept
+	if err := r.Verify(key); err == nil {
+		t.Error("Verify accepted a release without a signature")
+	}
+}
@@ -0,0 +1,208 @@
+// Package watch ingests text files dropped into a folder, or written to a
+// named pipe, as journal entries.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// IngestFunc stores text as a new entry. name identifies the source file.
+type IngestFunc func(text, name string) error
+
+// LogFunc reports progress and problems that do not stop watching
+type LogFunc func(format string, args ...interface{})
+
+// IsFIFO reports whether path is a named pipe
+func IsFIFO(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
+}
+
+// Dir polls dir every interval until ctx is cancelled. Each regular file
+// whose size and modification time are unchanged between two polls is read,
+// passed to ingest and then shredded. Hidden files and names ending in
+// "~", ".tmp", ".part" or ".swp" are ignored so that writers can create a
+// file under a temporary name and rename it into place.
+func Dir(ctx context.Context, dir string, interval time.Duration, ingest IngestFunc, logf LogFunc) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create watch directory: %w", err)
+	}
+
+	seen := make(map[string]os.FileInfo) // Last observed state per file
+	warned := make(map[string]bool)      // Files that failed and are left alone
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read watch directory: %w", err)
+		}
+		sort.Slice(files, func(a, b int) bool { return files[a].Name() < files[b].Name() })
+
+		current := make(map[string]bool, len(files))
+		for _, f := range files {
+			name := f.Name()
+			if !f.Type().IsRegular() || ignored(name) {
+				continue
+			}
+			current[name] = true
+
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			prev, ok := seen[name]
+			seen[name] = info
+			if !ok || prev.Size() != info.Size() || !prev.ModTime().Equal(info.ModTime()) {
+				delete(warned, name) // Changed files get another chance
+				continue             // Still being written, or seen for the first time
+			}
+
+			if warned[name] {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			stored, err := ingestFile(path, name, ingest)
+			if err != nil {
+				// Never retry a file that was already stored; that would
+				// create a duplicate entry on every poll
+				if stored {
+					logf("Stored %s but could not remove it: %v", name, err)
+				} else {
+					logf("Skipping %s: %v", name, err)
+				}
+				warned[name] = true
+				continue
+			}
+			delete(seen, name)
+		}
+
+		// Forget files that disappeared
+		for name := range seen {
+			if !current[name] {
+				delete(seen, name)
+				delete(warned, name)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ingestFile reads one dropped file, stores it and shreds it. stored reports
+// whether the entry was created, even if shredding failed afterwards.
+func ingestFile(path, name string, ingest IngestFunc) (stored bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return false, fmt.Errorf("not a UTF-8 text file")
+	}
+
+	if text := strings.TrimSpace(string(data)); text != "" {
+		if err := ingest(text, name); err != nil {
+			return false, err
+		}
+		stored = true
+	}
+	return stored, Shred(path)
+}
+
+// ignored reports whether name looks like a temporary or hidden file
+func ignored(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return true
+	}
+	for _, ext := range []string{".tmp", ".part", ".swp"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// FIFO reads the named pipe at path until ctx is cancelled. Everything one
+// writer sends between opening and closing the pipe becomes a single entry.
+// Opening a pipe blocks until a writer appears, so cancellation takes effect
+// after the next write.
+func FIFO(ctx context.Context, path string, ingest IngestFunc, logf LogFunc) error {
+	for ctx.Err() == nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open pipe: %w", err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read pipe: %w", err)
+		}
+
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			continue
+		}
+		if !utf8.ValidString(text) {
+			logf("Skipping pipe input: not UTF-8 text")
+			continue
+		}
+		if err := ingest(text, filepath.Base(path)); err != nil {
+			logf("Failed to store pipe input: %v", err)
+		}
+	}
+	return nil
+}
+
+// Shred overwrites a file with zeros before removing it. On copy-on-write
+// filesystems and SSDs the old blocks may survive; this only guards against
+// the plaintext lingering in the folder or being trivially undeleted.
+func Shred(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open file for shredding: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat file for shredding: %w", err)
+	}
+
+	zeros := make([]byte, 32*1024)
+	for remaining := info.Size(); remaining > 0; {
+		n := int64(len(zeros))
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := f.Write(zeros[:n]); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to overwrite file: %w", err)
+		}
+		remaining -= n
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package when
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ageRe = regexp.MustCompile(`^(\d+)\s*([dwmy])$`)
+
+// Ago returns the start of the day an age such as "1y", "6m", "2w" or
+// "30d" reaches back to from now. Any date Parse accepts is also taken as
+// that day.
+func Ago(age string, now time.Time) (time.Time, error) {
+	age = strings.ToLower(strings.TrimSpace(age))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if m := ageRe.FindStringSubmatch(age); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "d":
+			return today.AddDate(0, 0, -n), nil
+		case "w":
+			return today.AddDate(0, 0, -7*n), nil
+		case "m":
+			return today.AddDate(0, -n, 0), nil
+		default:
+			return today.AddDate(-n, 0, 0), nil
+		}
+	}
+	if day, err := parseDate(age, now); err == nil {
+		return day, nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognised age '%s': use 30d, 2w, 6m, 1y or a date", age)
+}
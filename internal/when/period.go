@@ -0,0 +1,101 @@
+package when
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	lastN     = regexp.MustCompile(`^(?:last|past) (\d+) (day|week|month|year)s?$`)
+	monthName = regexp.MustCompile(`^([a-z]{3,})\.?(?:,? (\d{4}))?$`)
+	yearOnly  = regexp.MustCompile(`^\d{4}$`)
+)
+
+// Period returns the first and last day of the period expr describes, in
+// now's location. Besides any date Parse accepts, which is a period of one
+// day, it understands "this week" and "last week" (from
+// calendar.week_start), "this month", "last month", "this year", "last
+// year", "last N days" (or weeks, months, years) up to today, a month such
+// as "march", "mar 2023" or "2023-03", and a year such as "2023". A month
+// without a year is its most recent occurrence, this month included.
+func Period(expr string, now time.Time) (from, to time.Time, err error) {
+	expr = strings.ToLower(strings.Join(strings.Fields(expr), " "))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch expr {
+	case "this week", "last week":
+		from = StartOfWeek(today)
+		if expr == "last week" {
+			from = from.AddDate(0, 0, -7)
+		}
+		return from, from.AddDate(0, 0, 6), nil
+	case "this month", "last month":
+		from = today.AddDate(0, 0, 1-today.Day())
+		if expr == "last month" {
+			from = from.AddDate(0, -1, 0)
+		}
+		return from, from.AddDate(0, 1, -1), nil
+	case "this year", "last year":
+		from = time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+		if expr == "last year" {
+			from = from.AddDate(-1, 0, 0)
+		}
+		return from, from.AddDate(1, 0, -1), nil
+	}
+
+	if m := lastN.FindStringSubmatch(expr); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n == 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("'%s' is an empty period", expr)
+		}
+		switch m[2] {
+		case "day":
+			from = today.AddDate(0, 0, 1-n)
+		case "week":
+			from = today.AddDate(0, 0, 1-7*n)
+		case "month":
+			from = today.AddDate(0, -n, 1)
+		case "year":
+			from = today.AddDate(-n, 0, 1)
+		}
+		return from, today, nil
+	}
+	if t, err := time.ParseInLocation("2006-01", expr, now.Location()); err == nil {
+		return t, t.AddDate(0, 1, -1), nil
+	}
+	if yearOnly.MatchString(expr) {
+		year, _ := strconv.Atoi(expr)
+		from = time.Date(year, time.January, 1, 0, 0, 0, 0, now.Location())
+		return from, from.AddDate(1, 0, -1), nil
+	}
+	if day, err := parseDate(expr, now); err == nil {
+		return day, day, nil
+	}
+	if m := monthName.FindStringSubmatch(expr); m != nil {
+		if month, ok := lookupMonth(m[1]); ok {
+			year := today.Year()
+			if m[2] != "" {
+				year, _ = strconv.Atoi(m[2])
+			} else if month > today.Month() {
+				year--
+			}
+			from = time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+			return from, from.AddDate(0, 1, -1), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unrecognised period '%s': use a date, last week, this month, march 2023, 2023-03, 2023 or last N days", expr)
+}
+
+// lookupMonth finds the month named by name or the first three letters or
+// more of its name
+func lookupMonth(name string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), name) {
+			return m, true
+		}
+	}
+	return 0, false
+}
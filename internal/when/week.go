@@ -0,0 +1,39 @@
+package when
+
+import (
+	"time"
+
+	"github.com/veritome/jot/internal/config"
+)
+
+// WeekStart returns the day weeks start on, set by calendar.week_start
+func WeekStart() time.Weekday {
+	if cfg, err := config.Current(); err == nil {
+		if day, ok := weekdays[cfg.String("calendar.week_start")]; ok {
+			return day
+		}
+	}
+	return time.Monday
+}
+
+// StartOfWeek returns the first day of the week holding t, at midnight in
+// t's location
+func StartOfWeek(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -(int(day.Weekday())-int(WeekStart())+7)%7)
+}
+
+// Week returns the year and number of the week holding t, as numbered by
+// calendar.week_numbers: "iso" follows ISO 8601, whose weeks start on Monday
+// and whose week 1 holds the year's first Thursday, so the first and last
+// days of a year may belong to a week of the next or previous one.
+// "simple" counts from the week holding January 1, with weeks starting on
+// calendar.week_start.
+func Week(t time.Time) (year, week int) {
+	if cfg, err := config.Current(); err != nil || cfg.String("calendar.week_numbers") != "simple" {
+		return t.ISOWeek()
+	}
+	jan1 := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	offset := (int(jan1.Weekday()) - int(WeekStart()) + 7) % 7
+	return t.Year(), (t.YearDay()-1+offset)/7 + 1
+}
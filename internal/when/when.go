@@ -0,0 +1,131 @@
+package when
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	daysAgo   = regexp.MustCompile(`^(\d+) days? ago$`)
+	clock24   = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	clock12   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))? ?(am|pm)$`)
+	monthDay  = regexp.MustCompile(`^(\d{1,2})-(\d{1,2})$`)
+	weekdayRe = regexp.MustCompile(`^(?:last )?(monday|tuesday|wednesday|thursday|friday|saturday|sunday)$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Parse combines a date and a time of day into a timestamp in now's
+// location. Either may be empty: the date defaults to today and the time to
+// the current time of day. Dates are YYYY-MM-DD, MM-DD (the most recent
+// one), "today", "yesterday", "N days ago", or a weekday, optionally
+// preceded by "last", meaning its most recent occurrence before today.
+// Times are HH:MM, 3pm, 3:30pm, "noon" or "midnight".
+func Parse(date, clock string, now time.Time) (time.Time, error) {
+	day, err := parseDate(strings.ToLower(strings.TrimSpace(date)), now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	hour, min, sec := now.Clock()
+	if clock = strings.ToLower(strings.TrimSpace(clock)); clock != "" {
+		if hour, min, err = parseClock(clock); err != nil {
+			return time.Time{}, err
+		}
+		sec = 0
+	}
+
+	t := time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, 0, now.Location())
+	if t.After(now) {
+		return time.Time{}, fmt.Errorf("%s is in the future", t.Format("2006-01-02 15:04"))
+	}
+	return t, nil
+}
+
+func parseDate(date string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch date {
+	case "", "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	if m := daysAgo.FindStringSubmatch(date); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return today.AddDate(0, 0, -n), nil
+	}
+	if m := weekdayRe.FindStringSubmatch(date); m != nil {
+		back := (int(today.Weekday()) - int(weekdays[m[1]]) + 7) % 7
+		if back == 0 {
+			back = 7
+		}
+		return today.AddDate(0, 0, -back), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", date, now.Location()); err == nil {
+		return t, nil
+	}
+	if m := monthDay.FindStringSubmatch(date); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		day, _ := strconv.Atoi(m[2])
+		t := time.Date(now.Year(), time.Month(month), day, 0, 0, 0, 0, now.Location())
+		if t.Month() != time.Month(month) || t.Day() != day {
+			return time.Time{}, fmt.Errorf("invalid date '%s'", date)
+		}
+		if t.After(today) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date '%s': use YYYY-MM-DD, MM-DD, yesterday, N days ago or a weekday", date)
+}
+
+// IsClock reports whether s is a time of day Parse accepts
+func IsClock(s string) bool {
+	_, _, err := parseClock(strings.ToLower(strings.TrimSpace(s)))
+	return err == nil
+}
+
+func parseClock(clock string) (int, int, error) {
+	switch clock {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+
+	if m := clock24.FindStringSubmatch(clock); m != nil {
+		hour, _ := strconv.Atoi(m[1])
+		min, _ := strconv.Atoi(m[2])
+		if hour < 24 && min < 60 {
+			return hour, min, nil
+		}
+	}
+	if m := clock12.FindStringSubmatch(clock); m != nil {
+		hour, _ := strconv.Atoi(m[1])
+		min := 0
+		if m[2] != "" {
+			min, _ = strconv.Atoi(m[2])
+		}
+		if hour >= 1 && hour <= 12 && min < 60 {
+			hour %= 12
+			if m[3] == "pm" {
+				hour += 12
+			}
+			return hour, min, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("unrecognised time '%s': use HH:MM, 3pm or 3:30pm", clock)
+}
@@ -0,0 +1,251 @@
+// Package client talks to a remote jot server over its HTTP JSON API.
+//
+// The API is versioned under /v1 and authenticated with a bearer token:
+//
+//	GET    /healthz                      check the server is up (no token needed)
+//	GET    /v1/journals                  list journals
+//	GET    /v1/journals/{name}/entries   list a journal's entries
+//	POST   /v1/entries                   create an entry
+//	POST   /v1/entries/batch             create several entries
+//	GET    /v1/entries/{id}              read an entry
+//	DELETE /v1/entries/{id}?journal=...  delete an entry
+//	       &expected_revision=...
+//	GET    /v1/search?q=...&journal=...  search entry bodies
+//
+// The same operations are served over gRPC when the server is started with
+// --grpc-addr; DialGRPC connects to it for use with the generated client in
+// package jotpb.
+//
+// Entry bodies travel decrypted, so the server should only be reachable over
+// loopback or a trusted, encrypted transport.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Journal is the API representation of a journal
+type Journal struct {
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Entries  int       `json:"entries"`
+	Revision int64     `json:"revision"` // Grows with every entry added or removed
+	Default  bool      `json:"default"`
+}
+
+// Entry is the API representation of a decrypted entry
+type Entry struct {
+	ID      string    `json:"id"`
+	Journal string    `json:"journal"`
+	Author  string    `json:"author,omitempty"` // Member who wrote it, for entries of shared journals
+	Created time.Time `json:"created"`
+	Title   string    `json:"title,omitempty"`
+	Body    string    `json:"body"`
+	Tags    []string  `json:"tags,omitempty"`
+
+	Sensitive bool `json:"sensitive,omitempty"` // Hide the body in list views until revealed
+}
+
+// NewEntry is the request body for creating an entry.
+// An empty Journal writes to the server's default journal.
+type NewEntry struct {
+	Journal string   `json:"journal,omitempty"`
+	Title   string   `json:"title,omitempty"`
+	Body    string   `json:"body"`
+	Tags    []string `json:"tags,omitempty"`
+
+	Sensitive bool `json:"sensitive,omitempty"`
+
+	// AllowDuplicates saves the entry even when the journal has one with the
+	// same text from around the same time; otherwise the server answers 409
+	AllowDuplicates bool `json:"allow_duplicates,omitempty"`
+
+	// ExpectedRevision, when set, saves the entry only if the journal is
+	// still at that revision; otherwise the server answers 409
+	ExpectedRevision int64 `json:"expected_revision,omitempty"`
+}
+
+// BatchEntry is the outcome of one entry of a batch: the entry as stored,
+// or the ID of the entry it duplicates when it was skipped
+type BatchEntry struct {
+	Entry     *Entry `json:"entry,omitempty"`
+	Duplicate string `json:"duplicate,omitempty"`
+}
+
+// Error is returned when the server answers with a non-2xx status
+type Error struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Client is a jot API client. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New creates a client for the server at baseURL authenticating with token
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Health checks that the server is up and able to serve requests. It
+// returns an *Error with status 503 while the server shuts down.
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/healthz", nil, nil)
+}
+
+// Journals lists all journals on the server
+func (c *Client) Journals(ctx context.Context) ([]Journal, error) {
+	var journals []Journal
+	if err := c.do(ctx, http.MethodGet, "/v1/journals", nil, &journals); err != nil {
+		return nil, err
+	}
+	return journals, nil
+}
+
+// Entries lists all entries of the named journal
+func (c *Client) Entries(ctx context.Context, journal string) ([]Entry, error) {
+	var entries []Entry
+	path := "/v1/journals/" + url.PathEscape(journal) + "/entries"
+	if err := c.do(ctx, http.MethodGet, path, nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Entry fetches a single entry by ID
+func (c *Client) Entry(ctx context.Context, id string) (*Entry, error) {
+	var e Entry
+	if err := c.do(ctx, http.MethodGet, "/v1/entries/"+url.PathEscape(id), nil, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CreateEntry writes a new entry and returns it as stored by the server
+func (c *Client) CreateEntry(ctx context.Context, ne NewEntry) (*Entry, error) {
+	var e Entry
+	if err := c.do(ctx, http.MethodPost, "/v1/entries", ne, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CreateEntries writes several entries at once, which is much cheaper than
+// creating them one by one. The results are in the order of entries.
+// Entries duplicating a stored entry or an earlier one of the batch are
+// skipped unless they allow duplicates.
+func (c *Client) CreateEntries(ctx context.Context, entries []NewEntry) ([]BatchEntry, error) {
+	var results []BatchEntry
+	if err := c.do(ctx, http.MethodPost, "/v1/entries/batch", entries, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeleteEntry removes an entry by ID
+func (c *Client) DeleteEntry(ctx context.Context, id string) error {
+	return c.DeleteEntryFrom(ctx, "", id)
+}
+
+// DeleteEntryFrom removes an entry by ID if it belongs to the named journal,
+// answering an *Error with status 404 otherwise. An empty journal deletes
+// the entry wherever it is.
+func (c *Client) DeleteEntryFrom(ctx context.Context, journal, id string) error {
+	return c.DeleteEntryAt(ctx, journal, id, 0)
+}
+
+// DeleteEntryAt removes an entry like DeleteEntryFrom, but only while its
+// journal is at revision, answering an *Error with status 409 otherwise.
+// A revision of 0 deletes the entry whatever the journal's revision.
+func (c *Client) DeleteEntryAt(ctx context.Context, journal, id string, revision int64) error {
+	path := "/v1/entries/" + url.PathEscape(id)
+	params := url.Values{}
+	if journal != "" {
+		params.Set("journal", journal)
+	}
+	if revision != 0 {
+		params.Set("expected_revision", strconv.FormatInt(revision, 10))
+	}
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	return c.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// Search returns entries whose body contains query. An empty journal searches all journals.
+func (c *Client) Search(ctx context.Context, query, journal string) ([]Entry, error) {
+	params := url.Values{"q": {query}}
+	if journal != "" {
+		params.Set("journal", journal)
+	}
+	var entries []Entry
+	if err := c.do(ctx, http.MethodGet, "/v1/search?"+params.Encode(), nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// do performs a request, encoding in as the JSON body and decoding the response into out
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
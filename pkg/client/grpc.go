@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DialGRPC connects to the gRPC API of the jot server at target, such as
+// "127.0.0.1:7778", authenticating every call with token. Use the
+// connection with jotpb.NewJotClient and close it when done.
+//
+// Like the HTTP client, it does not encrypt traffic by default; pass
+// grpc.WithTransportCredentials to reach a server behind TLS.
+func DialGRPC(target, token string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(tokenCredentials(token)),
+	}, opts...)
+	return grpc.Dial(target, opts...)
+}
+
+// tokenCredentials sends the API token with every call
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,11 @@
+// Package jotpb holds the gRPC client and messages generated from
+// proto/jot/v1/jot.proto. Connect with client.DialGRPC:
+//
+//	conn, err := client.DialGRPC("127.0.0.1:7778", token)
+//	...
+//	defer conn.Close()
+//	jot := jotpb.NewJotClient(conn)
+//	e, err := jot.CreateEntry(ctx, &jotpb.NewEntry{Journal: "inbox", Body: "..."})
+package jotpb
+
+//go:generate protoc --proto_path=../../../proto --go_out=. --go_opt=module=github.com/veritome/jot/pkg/client/jotpb --go-grpc_out=. --go-grpc_opt=module=github.com/veritome/jot/pkg/client/jotpb jot/v1/jot.proto
@@ -0,0 +1,360 @@
+// The jot API over gRPC. It offers the same operations as the HTTP JSON API
+// described in pkg/client, with the same bearer token sent as the
+// "authorization" metadata: "Bearer <token>".
+//
+// Entry bodies travel decrypted, so the server should only be reachable over
+// loopback or a trusted, encrypted transport.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: jot/v1/jot.proto
+
+package jotpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Jot_ListJournals_FullMethodName  = "/jot.v1.Jot/ListJournals"
+	Jot_ListEntries_FullMethodName   = "/jot.v1.Jot/ListEntries"
+	Jot_GetEntry_FullMethodName      = "/jot.v1.Jot/GetEntry"
+	Jot_CreateEntry_FullMethodName   = "/jot.v1.Jot/CreateEntry"
+	Jot_CreateEntries_FullMethodName = "/jot.v1.Jot/CreateEntries"
+	Jot_DeleteEntry_FullMethodName   = "/jot.v1.Jot/DeleteEntry"
+	Jot_Search_FullMethodName        = "/jot.v1.Jot/Search"
+)
+
+// JotClient is the client API for Jot service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type JotClient interface {
+	// ListJournals lists all journals
+	ListJournals(ctx context.Context, in *ListJournalsRequest, opts ...grpc.CallOption) (*ListJournalsResponse, error)
+	// ListEntries lists a journal's entries
+	ListEntries(ctx context.Context, in *ListEntriesRequest, opts ...grpc.CallOption) (*ListEntriesResponse, error)
+	// GetEntry reads a single entry
+	GetEntry(ctx context.Context, in *GetEntryRequest, opts ...grpc.CallOption) (*Entry, error)
+	// CreateEntry writes a new entry. A duplicate of a recent entry fails with
+	// ALREADY_EXISTS unless allow_duplicates is set.
+	CreateEntry(ctx context.Context, in *NewEntry, opts ...grpc.CallOption) (*Entry, error)
+	// CreateEntries writes up to 1000 entries at once. Duplicates, of stored
+	// entries or of each other, are skipped and reported instead of failing
+	// the batch.
+	CreateEntries(ctx context.Context, in *CreateEntriesRequest, opts ...grpc.CallOption) (*CreateEntriesResponse, error)
+	// DeleteEntry removes an entry. Entries of append-only journals fail with
+	// PERMISSION_DENIED.
+	DeleteEntry(ctx context.Context, in *DeleteEntryRequest, opts ...grpc.CallOption) (*DeleteEntryResponse, error)
+	// Search returns the entries whose body contains a query, ignoring case
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+}
+
+type jotClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJotClient(cc grpc.ClientConnInterface) JotClient {
+	return &jotClient{cc}
+}
+
+func (c *jotClient) ListJournals(ctx context.Context, in *ListJournalsRequest, opts ...grpc.CallOption) (*ListJournalsResponse, error) {
+	out := new(ListJournalsResponse)
+	err := c.cc.Invoke(ctx, Jot_ListJournals_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jotClient) ListEntries(ctx context.Context, in *ListEntriesRequest, opts ...grpc.CallOption) (*ListEntriesResponse, error) {
+	out := new(ListEntriesResponse)
+	err := c.cc.Invoke(ctx, Jot_ListEntries_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jotClient) GetEntry(ctx context.Context, in *GetEntryRequest, opts ...grpc.CallOption) (*Entry, error) {
+	out := new(Entry)
+	err := c.cc.Invoke(ctx, Jot_GetEntry_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jotClient) CreateEntry(ctx context.Context, in *NewEntry, opts ...grpc.CallOption) (*Entry, error) {
+	out := new(Entry)
+	err := c.cc.Invoke(ctx, Jot_CreateEntry_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jotClient) CreateEntries(ctx context.Context, in *CreateEntriesRequest, opts ...grpc.CallOption) (*CreateEntriesResponse, error) {
+	out := new(CreateEntriesResponse)
+	err := c.cc.Invoke(ctx, Jot_CreateEntries_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jotClient) DeleteEntry(ctx context.Context, in *DeleteEntryRequest, opts ...grpc.CallOption) (*DeleteEntryResponse, error) {
+	out := new(DeleteEntryResponse)
+	err := c.cc.Invoke(ctx, Jot_DeleteEntry_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jotClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, Jot_Search_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// JotServer is the server API for Jot service.
+// All implementations must embed UnimplementedJotServer
+// for forward compatibility
+type JotServer interface {
+	// ListJournals lists all journals
+	ListJournals(context.Context, *ListJournalsRequest) (*ListJournalsResponse, error)
+	// ListEntries lists a journal's entries
+	ListEntries(context.Context, *ListEntriesRequest) (*ListEntriesResponse, error)
+	// GetEntry reads a single entry
+	GetEntry(context.Context, *GetEntryRequest) (*Entry, error)
+	// CreateEntry writes a new entry. A duplicate of a recent entry fails with
+	// ALREADY_EXISTS unless allow_duplicates is set.
+	CreateEntry(context.Context, *NewEntry) (*Entry, error)
+	// CreateEntries writes up to 1000 entries at once. Duplicates, of stored
+	// entries or of each other, are skipped and reported instead of failing
+	// the batch.
+	CreateEntries(context.Context, *CreateEntriesRequest) (*CreateEntriesResponse, error)
+	// DeleteEntry removes an entry. Entries of append-only journals fail with
+	// PERMISSION_DENIED.
+	DeleteEntry(context.Context, *DeleteEntryRequest) (*DeleteEntryResponse, error)
+	// Search returns the entries whose body contains a query, ignoring case
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	mustEmbedUnimplementedJotServer()
+}
+
+// UnimplementedJotServer must be embedded to have forward compatible implementations.
+type UnimplementedJotServer struct {
+}
+
+func (UnimplementedJotServer) ListJournals(context.Context, *ListJournalsRequest) (*ListJournalsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJournals not implemented")
+}
+func (UnimplementedJotServer) ListEntries(context.Context, *ListEntriesRequest) (*ListEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEntries not implemented")
+}
+func (UnimplementedJotServer) GetEntry(context.Context, *GetEntryRequest) (*Entry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEntry not implemented")
+}
+func (UnimplementedJotServer) CreateEntry(context.Context, *NewEntry) (*Entry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEntry not implemented")
+}
+func (UnimplementedJotServer) CreateEntries(context.Context, *CreateEntriesRequest) (*CreateEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEntries not implemented")
+}
+func (UnimplementedJotServer) DeleteEntry(context.Context, *DeleteEntryRequest) (*DeleteEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteEntry not implemented")
+}
+func (UnimplementedJotServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedJotServer) mustEmbedUnimplementedJotServer() {}
+
+// UnsafeJotServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JotServer will
+// result in compilation errors.
+type UnsafeJotServer interface {
+	mustEmbedUnimplementedJotServer()
+}
+
+func RegisterJotServer(s grpc.ServiceRegistrar, srv JotServer) {
+	s.RegisterService(&Jot_ServiceDesc, srv)
+}
+
+func _Jot_ListJournals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJournalsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JotServer).ListJournals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jot_ListJournals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JotServer).ListJournals(ctx, req.(*ListJournalsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Jot_ListEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JotServer).ListEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jot_ListEntries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JotServer).ListEntries(ctx, req.(*ListEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Jot_GetEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JotServer).GetEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jot_GetEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JotServer).GetEntry(ctx, req.(*GetEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Jot_CreateEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewEntry)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JotServer).CreateEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jot_CreateEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JotServer).CreateEntry(ctx, req.(*NewEntry))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Jot_CreateEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JotServer).CreateEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jot_CreateEntries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JotServer).CreateEntries(ctx, req.(*CreateEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Jot_DeleteEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JotServer).DeleteEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jot_DeleteEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JotServer).DeleteEntry(ctx, req.(*DeleteEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Jot_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JotServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jot_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JotServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Jot_ServiceDesc is the grpc.ServiceDesc for Jot service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Jot_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "jot.v1.Jot",
+	HandlerType: (*JotServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListJournals",
+			Handler:    _Jot_ListJournals_Handler,
+		},
+		{
+			MethodName: "ListEntries",
+			Handler:    _Jot_ListEntries_Handler,
+		},
+		{
+			MethodName: "GetEntry",
+			Handler:    _Jot_GetEntry_Handler,
+		},
+		{
+			MethodName: "CreateEntry",
+			Handler:    _Jot_CreateEntry_Handler,
+		},
+		{
+			MethodName: "CreateEntries",
+			Handler:    _Jot_CreateEntries_Handler,
+		},
+		{
+			MethodName: "DeleteEntry",
+			Handler:    _Jot_DeleteEntry_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Jot_Search_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "jot/v1/jot.proto",
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Scoped handles give code such as untrusted plugins a restricted view of a
+// single journal. They hold the client unexported, so the code holding a
+// handle cannot reach the token or call methods the handle does not have.
+// With the full token that restriction is only kept here; give the client
+// a token minted with `jot token new` to have the server enforce it too.
+
+// ReadOnlyJournal can read one journal's entries but not change anything
+type ReadOnlyJournal struct {
+	client *Client
+	name   string
+}
+
+// ReadOnly returns a handle that can only read the named journal
+func (c *Client) ReadOnly(journal string) *ReadOnlyJournal {
+	return &ReadOnlyJournal{client: c, name: journal}
+}
+
+// Name returns the name of the journal the handle is scoped to
+func (j *ReadOnlyJournal) Name() string {
+	return j.name
+}
+
+// Info returns the journal's details
+func (j *ReadOnlyJournal) Info(ctx context.Context) (*Journal, error) {
+	journals, err := j.client.Journals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range journals {
+		if journals[i].Name == j.name {
+			return &journals[i], nil
+		}
+	}
+	return nil, &Error{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("journal '%s' does not exist", j.name)}
+}
+
+// Entries lists the journal's entries
+func (j *ReadOnlyJournal) Entries(ctx context.Context) ([]Entry, error) {
+	return j.client.Entries(ctx, j.name)
+}
+
+// Entry fetches a single entry by ID. Entries of other journals are
+// reported as not existing.
+func (j *ReadOnlyJournal) Entry(ctx context.Context, id string) (*Entry, error) {
+	e, err := j.client.Entry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Journal != j.name {
+		return nil, &Error{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("entry %s does not exist", id)}
+	}
+	return e, nil
+}
+
+// Search returns the journal's entries whose body contains query
+func (j *ReadOnlyJournal) Search(ctx context.Context, query string) ([]Entry, error) {
+	return j.client.Search(ctx, query, j.name)
+}
+
+// AppendOnlyJournal can read one journal and add entries to it, but not
+// delete any
+type AppendOnlyJournal struct {
+	*ReadOnlyJournal
+}
+
+// AppendOnly returns a handle that can only read and add to the named journal
+func (c *Client) AppendOnly(journal string) *AppendOnlyJournal {
+	return &AppendOnlyJournal{c.ReadOnly(journal)}
+}
+
+// Append writes a new entry to the journal and returns it as stored by the
+// server. ne.Journal must be empty or name the handle's journal.
+func (j *AppendOnlyJournal) Append(ctx context.Context, ne NewEntry) (*Entry, error) {
+	if ne.Journal != "" && ne.Journal != j.name {
+		return nil, fmt.Errorf("handle for journal '%s' cannot write to '%s'", j.name, ne.Journal)
+	}
+	ne.Journal = j.name
+	return j.client.CreateEntry(ctx, ne)
+}
+
+// AppendAll writes several entries to the journal at once; see
+// Client.CreateEntries. Each entry's Journal must be empty or name the
+// handle's journal.
+func (j *AppendOnlyJournal) AppendAll(ctx context.Context, entries []NewEntry) ([]BatchEntry, error) {
+	scoped := make([]NewEntry, len(entries))
+	for i, ne := range entries {
+		if ne.Journal != "" && ne.Journal != j.name {
+			return nil, fmt.Errorf("handle for journal '%s' cannot write to '%s'", j.name, ne.Journal)
+		}
+		ne.Journal = j.name
+		scoped[i] = ne
+	}
+	return j.client.CreateEntries(ctx, scoped)
+}
@@ -0,0 +1,121 @@
+// Package jottest runs jot on in-memory storage, with settings and a key
+// pair of its own, so that tests of jot and of programs using its API never
+// read or write the real data directory:
+//
+//	func TestSync(t *testing.T) {
+//		env := jottest.New(t)
+//		env.AddJournal("inbox")
+//		c := env.Serve()
+//		e, err := c.CreateEntry(ctx, client.NewEntry{Journal: "inbox", Body: "..."})
+//		...
+//	}
+//
+// Storage and settings are global to the process, so tests using an Env
+// must not run in parallel with each other.
+package jottest
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/veritome/jot/internal/atrest"
+	"github.com/veritome/jot/internal/collection"
+	"github.com/veritome/jot/internal/config"
+	"github.com/veritome/jot/internal/journal"
+	"github.com/veritome/jot/internal/server"
+	"github.com/veritome/jot/internal/storage"
+	"github.com/veritome/jot/pkg/client"
+	"google.golang.org/grpc"
+)
+
+// DataDir is the data directory of every Env, inside its in-memory storage
+const DataDir = "/jot"
+
+// Env is an isolated jot. It lives until the end of the test that created
+// it.
+type Env struct {
+	// Token is the API token of the servers started by Serve and ServeGRPC
+	Token string
+
+	t   testing.TB
+	cfg *config.Config
+}
+
+// New switches jot to empty in-memory storage and default settings, with
+// data_dir set to DataDir, and creates a key pair. Everything is put back
+// when t ends.
+func New(t testing.TB) *Env {
+	t.Helper()
+
+	cfg, err := config.New(map[string]string{"data_dir": DataDir})
+	if err != nil {
+		t.Fatalf("jottest: %v", err)
+	}
+	t.Cleanup(storage.Use(atrest.Wrap(storage.NewMemory())))
+	t.Cleanup(config.Use(cfg))
+
+	// Loading the empty collection generates the keys
+	if _, err := collection.Load(); err != nil {
+		t.Fatalf("jottest: %v", err)
+	}
+	token, err := server.LoadOrCreateToken()
+	if err != nil {
+		t.Fatalf("jottest: %v", err)
+	}
+
+	return &Env{Token: token, t: t, cfg: cfg}
+}
+
+// Set changes a setting of the environment, as jot config set would
+func (e *Env) Set(name, value string) {
+	e.t.Helper()
+	if err := e.cfg.Set(name, value); err != nil {
+		e.t.Fatalf("jottest: %v", err)
+	}
+}
+
+// AddJournal creates a journal, as jot journal new would
+func (e *Env) AddJournal(name string) {
+	e.t.Helper()
+	coll, err := collection.Load()
+	if err != nil {
+		e.t.Fatalf("jottest: %v", err)
+	}
+	j, err := journal.New(name)
+	if err != nil {
+		e.t.Fatalf("jottest: %v", err)
+	}
+	if err := coll.AddJournal(j.AsType()); err != nil {
+		e.t.Fatalf("jottest: %v", err)
+	}
+}
+
+// Serve starts the HTTP API on a loopback port and returns a client for it.
+// The server stops when the test ends.
+func (e *Env) Serve() *client.Client {
+	srv := httptest.NewServer(server.New(e.Token).Handler())
+	e.t.Cleanup(srv.Close)
+	return client.New(srv.URL, e.Token)
+}
+
+// ServeGRPC starts the gRPC API on a loopback port and returns a connection
+// to it, for use with jotpb.NewJotClient. Both are closed when the test
+// ends.
+func (e *Env) ServeGRPC() *grpc.ClientConn {
+	e.t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		e.t.Fatalf("jottest: %v", err)
+	}
+	srv := server.New(e.Token).GRPCServer()
+	go srv.Serve(l)
+	e.t.Cleanup(srv.Stop)
+
+	conn, err := client.DialGRPC(l.Addr().String(), e.Token)
+	if err != nil {
+		e.t.Fatalf("jottest: %v", err)
+	}
+	e.t.Cleanup(func() { conn.Close() })
+	return conn
+}
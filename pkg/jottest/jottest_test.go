@@ -0,0 +1,118 @@
+package jottest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/veritome/jot/pkg/client"
+	"github.com/veritome/jot/pkg/client/jotpb"
+	"github.com/veritome/jot/pkg/jottest"
+)
+
+func TestEntryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	env := jottest.New(t)
+	env.AddJournal("inbox")
+	c := env.Serve()
+
+	created, err := c.CreateEntry(ctx, client.NewEntry{
+		Journal: "inbox",
+		Title:   "Groceries",
+		Body:    "Buy oat milk",
+		Tags:    []string{"errand"},
+	})
+	if err != nil {
+		t.Fatalf("CreateEntry: %v", err)
+	}
+
+	got, err := c.Entry(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Entry: %v", err)
+	}
+	if got.Journal != "inbox" || got.Title != "Groceries" || got.Body != "Buy oat milk" {
+		t.Errorf("Entry = %+v, want the entry as created", got)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"errand"}) {
+		t.Errorf("Tags = %q, want [errand]", got.Tags)
+	}
+
+	entries, err := c.Entries(ctx, "inbox")
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != created.ID {
+		t.Errorf("Entries = %+v, want only %s", entries, created.ID)
+	}
+
+	matches, err := c.Search(ctx, "OAT", "")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != created.ID {
+		t.Errorf("Search = %+v, want only %s", matches, created.ID)
+	}
+}
+
+func TestDuplicateRefused(t *testing.T) {
+	ctx := context.Background()
+	env := jottest.New(t)
+	env.AddJournal("inbox")
+	c := env.Serve()
+
+	ne := client.NewEntry{Journal: "inbox", Body: "Same text"}
+	if _, err := c.CreateEntry(ctx, ne); err != nil {
+		t.Fatalf("CreateEntry: %v", err)
+	}
+	_, err := c.CreateEntry(ctx, ne)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Fatalf("second CreateEntry error = %v, want 409", err)
+	}
+}
+
+func TestEnvsAreIsolated(t *testing.T) {
+	ctx := context.Background()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	env := jottest.New(t)
+	env.AddJournal("inbox")
+	if _, err := env.Serve().CreateEntry(ctx, client.NewEntry{Journal: "inbox", Body: "kept in memory"}); err != nil {
+		t.Fatalf("CreateEntry: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".jot")); !os.IsNotExist(err) {
+		t.Errorf("data directory created on disk: %v", err)
+	}
+
+	journals, err := jottest.New(t).Serve().Journals(ctx)
+	if err != nil {
+		t.Fatalf("Journals: %v", err)
+	}
+	if len(journals) != 0 {
+		t.Errorf("new Env has journals %+v, want none", journals)
+	}
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	env := jottest.New(t)
+	env.AddJournal("inbox")
+	c := jotpb.NewJotClient(env.ServeGRPC())
+
+	created, err := c.CreateEntry(ctx, &jotpb.NewEntry{Journal: "inbox", Body: "Over gRPC"})
+	if err != nil {
+		t.Fatalf("CreateEntry: %v", err)
+	}
+	got, err := c.GetEntry(ctx, &jotpb.GetEntryRequest{Id: created.Id})
+	if err != nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	if got.Body != "Over gRPC" || got.Journal != "inbox" {
+		t.Errorf("GetEntry = %v, want the entry as created", got)
+	}
+}